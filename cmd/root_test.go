@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_runBenchmark_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "invalid assertion", args: []string{"--assert=p99<fast"}},
+		{name: "missing baseline", args: []string{"--compare=" + filepath.Join(t.TempDir(), "missing.json")}},
+		{name: "invalid regression threshold", args: []string{"--regression-threshold=fast"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags()
+			defer resetFlags()
+			_, err := pApp.Parse(append(append([]string{pRun.FullCommand(), "--server=127.0.0.1"}, tt.args...), "example.org"))
+			require.NoError(t, err)
+
+			code, res := runBenchmark(context.Background(), pRun.FullCommand(), io.Discard)
+
+			assert.Equal(t, errorExitCode, code, "the benchmark which could not be started fails")
+			assert.Nil(t, res)
+		})
+	}
+}
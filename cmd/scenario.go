@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scenario is a benchmark configuration loaded from YAML file specified by --config option. Keys of the scenario are long names of the flags
+// and queries, the benchmark can be split into phases executed one after another, each phase overrides the flags and queries of the scenario.
+type scenario struct {
+	settings map[string]interface{}
+	phases   []phase
+}
+
+// phase is a single benchmark executed as a part of the scenario.
+type phase struct {
+	name     string
+	settings map[string]interface{}
+}
+
+func loadScenario(file string) (scenario, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return scenario{}, fmt.Errorf("failed to read config due to '%v'", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return scenario{}, fmt.Errorf("failed to parse config '%s' due to '%v'", file, err)
+	}
+	return parseScenario(raw)
+}
+
+func parseScenario(raw map[string]interface{}) (scenario, error) {
+	s := scenario{settings: raw}
+	rawPhases, ok := raw["phases"]
+	if !ok {
+		return s, nil
+	}
+	delete(raw, "phases")
+	list, ok := rawPhases.([]interface{})
+	if !ok {
+		return s, fmt.Errorf("phases have to be a list, got '%v'", rawPhases)
+	}
+	for i, p := range list {
+		settings, ok := p.(map[string]interface{})
+		if !ok {
+			return s, fmt.Errorf("phase %d has to be a mapping of flags, got '%v'", i+1, p)
+		}
+		ph := phase{name: fmt.Sprintf("%d", i+1), settings: settings}
+		if name, ok := settings["name"]; ok {
+			ph.name = fmt.Sprint(name)
+			delete(settings, "name")
+		}
+		s.phases = append(s.phases, ph)
+	}
+	return s, nil
+}
+
+// args converts the settings of the scenario overridden by the settings of the phase to command line arguments,
+// options specified on the command line are skipped, so they take precedence over the scenario.
+func (s scenario) args(ph phase, cli []string, cliQueries bool) ([]string, error) {
+	settings := make(map[string]interface{}, len(s.settings)+len(ph.settings))
+	for k, v := range s.settings {
+		settings[k] = v
+	}
+	for k, v := range ph.settings {
+		settings[k] = v
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var args, queries []string
+	for _, k := range keys {
+		values, err := settingValues(k, settings[k])
+		if err != nil {
+			return nil, err
+		}
+		if k == "queries" {
+			if !cliQueries {
+				queries = values
+			}
+			continue
+		}
+		if specifiedOnCommandLine(k, cli) {
+			continue
+		}
+		if b, ok := settings[k].(bool); ok {
+			if b {
+				args = append(args, "--"+k)
+			} else {
+				args = append(args, "--no-"+k)
+			}
+			continue
+		}
+		for _, v := range values {
+			args = append(args, fmt.Sprintf("--%s=%s", k, v))
+		}
+	}
+	return append(args, queries...), nil
+}
+
+// settingValues returns values of the setting, list values are used for repeatable flags.
+func settingValues(key string, v interface{}) ([]string, error) {
+	switch val := v.(type) {
+	case []interface{}:
+		res := make([]string, 0, len(val))
+		for _, e := range val {
+			if !isScalar(e) {
+				return nil, fmt.Errorf("invalid value '%v' of '%s' in config", e, key)
+			}
+			res = append(res, fmt.Sprint(e))
+		}
+		return res, nil
+	default:
+		if !isScalar(val) {
+			return nil, fmt.Errorf("invalid value '%v' of '%s' in config", v, key)
+		}
+		return []string{fmt.Sprint(val)}, nil
+	}
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case string, bool, int, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// specifiedOnCommandLine returns true if the flag with the long name is present in the command line arguments.
+func specifiedOnCommandLine(name string, cli []string) bool {
+	var short string
+	for _, f := range pApp.Model().Flags {
+		if f.Name == name && f.Short != 0 {
+			short = "-" + string(f.Short)
+		}
+	}
+	for _, arg := range cli {
+		if arg == "--"+name || arg == "--no-"+name || strings.HasPrefix(arg, "--"+name+"=") {
+			return true
+		}
+		if short != "" && strings.HasPrefix(arg, short) && !strings.HasPrefix(arg, "--") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScenario = `
+server: 127.0.0.1:53
+concurrency: 10
+type: [A, AAAA]
+tcp: true
+distribution: false
+probability: 0.5
+queries: ["@data/2-domains"]
+phases:
+  - name: warmup
+    duration: 10s
+    silent: true
+  - duration: 1m
+    rate-limit: 1000
+    queries: [example.org, example.com]
+`
+
+func Test_loadScenario(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenario.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(testScenario), 0o600))
+
+	sc, err := loadScenario(file)
+	require.NoError(t, err)
+	require.Len(t, sc.phases, 2)
+	assert.Equal(t, "warmup", sc.phases[0].name)
+	assert.Equal(t, "2", sc.phases[1].name)
+
+	args, err := sc.args(phase{}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--concurrency=10", "--no-distribution", "--probability=0.5", "--server=127.0.0.1:53", "--tcp", "--type=A", "--type=AAAA", "@data/2-domains"}, args)
+
+	args, err = sc.args(sc.phases[1], []string{"-c", "5", "--rate-limit=10"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--no-distribution", "--duration=1m", "--probability=0.5", "--server=127.0.0.1:53", "--tcp", "--type=A", "--type=AAAA", "example.org", "example.com"}, args)
+
+	args, err = sc.args(sc.phases[0], nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--concurrency=10", "--no-distribution", "--duration=10s", "--probability=0.5", "--server=127.0.0.1:53", "--silent", "--tcp", "--type=A", "--type=AAAA"}, args)
+}
+
+func Test_loadScenario_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "invalid yaml", content: "server: [", wantErr: "failed to parse config"},
+		{name: "phases not a list", content: "phases: steady", wantErr: "phases have to be a list"},
+		{name: "phase not a mapping", content: "phases: [steady]", wantErr: "phase 1 has to be a mapping of flags"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "scenario.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.content), 0o600))
+
+			_, err := loadScenario(file)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func Test_scenario_args_invalid(t *testing.T) {
+	sc, err := parseScenario(map[string]interface{}{"server": map[string]interface{}{"host": "127.0.0.1"}})
+	require.NoError(t, err)
+
+	_, err = sc.args(phase{}, nil, false)
+	assert.EqualError(t, err, "invalid value 'map[host:127.0.0.1]' of 'server' in config")
+}
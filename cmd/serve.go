@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/tantalor93/dnspyre/v2/pkg/dnsbench"
+)
+
+// apiLocalFlags are the flags writing files or loading code on the host of the API, the clients of the API cannot set them.
+var apiLocalFlags = []string{
+	"output", "csv", "report-csv", "store", "save-raw", "hist-export", "hist-log", "series-csv", "stats-file", "plot",
+	"diff-log", "log-failures", "save-baseline", "compare", "hook-plugin",
+}
+
+// apiScenarioMu serializes parsing of the scenarios received by the control API, since the flags are parsed into the global variables.
+var apiScenarioMu sync.Mutex
+
+// parseAPIScenario converts the JSON scenario received by the control API to the benchmark, keys of the scenario are long names
+// of the flags and 'queries' like in the scenario loaded by --config option.
+func parseAPIScenario(data []byte) (*dnsbench.Benchmark, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario due to '%v'", err)
+	}
+	sc, err := parseScenario(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(sc.phases) > 0 {
+		return nil, errors.New("phases are not supported by the API, each phase has to be started as a separate run")
+	}
+	for _, f := range apiLocalFlags {
+		if _, ok := sc.settings[f]; ok {
+			return nil, fmt.Errorf("--%s is not supported by the API, files cannot be written and plugins cannot be loaded on the host of the API", f)
+		}
+	}
+	args, err := sc.args(phase{}, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	if queries, ok := sc.settings["queries"]; ok {
+		values, err := settingValues("queries", queries)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range values {
+			if strings.HasPrefix(q, "-") || strings.HasPrefix(q, "@") {
+				return nil, fmt.Errorf("query '%s' is not supported by the API, queries cannot start with '-' or '@'", q)
+			}
+		}
+	}
+
+	apiScenarioMu.Lock()
+	defer apiScenarioMu.Unlock()
+	// the arguments starting with '@' would be replaced by the content of the file on the host of the API
+	kingpin.EnableFileExpansion = false
+	defer func() { kingpin.EnableFileExpansion = true }()
+	resetFlags()
+	defer resetFlags()
+	if _, err := pApp.Parse(append([]string{pRun.FullCommand()}, args...)); err != nil {
+		return nil, err
+	}
+	if err := setServers(); err != nil {
+		return nil, err
+	}
+	if (len(servers) > 1 && !diff) || len(workers) > 0 || findMaxQPS || configFile != "" {
+		return nil, errors.New("comparison of multiple servers, --workers, --find-max-qps and --config options are not supported by the API")
+	}
+	if benchmark.HookPlugin != "" {
+		return nil, errors.New("--hook-plugin is not supported by the API, plugins can be loaded only by the local command")
+	}
+	if output != "" || saveBaselineFile != "" || compareBaselineFile != "" {
+		return nil, errors.New("--output, --save-baseline and --compare options are not supported by the API, files cannot be read or written on the host of the API")
+	}
+	b := benchmark
+	if err := b.CheckUntrusted(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseAPIScenario(t *testing.T) {
+	b, err := parseAPIScenario([]byte(`{"server": "127.0.0.1:53", "concurrency": 10, "type": ["A", "MX"], "tcp": true, "duration": "10s", "queries": ["example.org"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:53", b.Server)
+	assert.Equal(t, uint32(10), b.Concurrency)
+	assert.Equal(t, []string{"A", "MX"}, b.Types)
+	assert.True(t, b.TCP)
+	assert.Equal(t, 10*time.Second, b.Duration)
+	assert.Equal(t, []string{"example.org"}, b.Queries)
+	assert.Equal(t, "", benchmark.Server, "the parsed flags are reset")
+}
+
+func Test_parseAPIScenario_invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario string
+		wantErr  string
+	}{
+		{name: "invalid JSON", scenario: `{`, wantErr: "failed to parse scenario due to 'unexpected end of JSON input'"},
+		{name: "unknown flag", scenario: `{"bogus": 1}`, wantErr: "unknown long flag '--bogus'"},
+		{name: "phases", scenario: `{"phases": [{"duration": "10s"}]}`, wantErr: "phases are not supported by the API, each phase has to be started as a separate run"},
+		{
+			name: "multiple servers", scenario: `{"server": ["127.0.0.1", "127.0.0.2"], "queries": ["example.org"]}`,
+			wantErr: "comparison of multiple servers, --workers, --find-max-qps and --config options are not supported by the API",
+		},
+		{
+			name: "hook plugin", scenario: `{"server": "127.0.0.1", "hook-plugin": "hooks.so", "queries": ["example.org"]}`,
+			wantErr: "--hook-plugin is not supported by the API, files cannot be written and plugins cannot be loaded on the host of the API",
+		},
+		{
+			name: "output file", scenario: `{"server": "127.0.0.1", "log-failures": "/etc/passwd", "queries": ["example.org"]}`,
+			wantErr: "--log-failures is not supported by the API, files cannot be written and plugins cannot be loaded on the host of the API",
+		},
+		{
+			name: "flag in queries", scenario: `{"server": "127.0.0.1", "queries": ["--hook-plugin=/x.so"]}`,
+			wantErr: "query '--hook-plugin=/x.so' is not supported by the API, queries cannot start with '-' or '@'",
+		},
+		{
+			name: "output flag in queries", scenario: `{"server": "127.0.0.1", "queries": ["example.org", "--store=/p"]}`,
+			wantErr: "query '--store=/p' is not supported by the API, queries cannot start with '-' or '@'",
+		},
+		{
+			name: "file expansion in queries", scenario: `{"server": "127.0.0.1", "queries": ["@/etc/shadow"]}`,
+			wantErr: "query '@/etc/shadow' is not supported by the API, queries cannot start with '-' or '@'",
+		},
+		{
+			name: "file read", scenario: `{"server": "127.0.0.1", "tls-ca": "/etc/shadow", "queries": ["example.org"]}`,
+			wantErr: "--tls-ca is not supported for remote clients, files cannot be read and endpoints cannot be opened on their behalf",
+		},
+		{
+			name: "downloaded queries", scenario: `{"server": "127.0.0.1", "queries": ["http://169.254.169.254/latest"]}`,
+			wantErr: "query 'http://169.254.169.254/latest' is not supported for remote clients, files cannot be read or downloaded on their behalf",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAPIScenario([]byte(tt.scenario))
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
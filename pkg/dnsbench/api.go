@@ -0,0 +1,374 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const apiRunsPath = "/v1/runs"
+
+// apiRetainedRuns is a number of the last runs kept by the control API, the older runs are forgotten together with their results,
+// so that the memory of the long-running API does not grow with each run.
+const apiRetainedRuns = 100
+
+// states of the runs started by the control API.
+const (
+	RunStateRunning  = "running"
+	RunStateFinished = "finished"
+	RunStateStopped  = "stopped"
+	RunStateFailed   = "failed"
+)
+
+// RunStatus is a status of the benchmark run started by the control API.
+type RunStatus struct {
+	ID       string         `json:"id"`
+	State    string         `json:"state"`
+	Server   string         `json:"server"`
+	Started  time.Time      `json:"started"`
+	Finished *time.Time     `json:"finished,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Progress *ProgressStats `json:"progress,omitempty"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiRun is a benchmark run started by the control API, the progress is updated by the running benchmark and read by the API handlers.
+type apiRun struct {
+	mu     sync.Mutex
+	status RunStatus
+	// updated is closed and replaced whenever the progress or the state of the run changes, so that progress streams are notified.
+	updated chan struct{}
+	result  []byte
+	cancel  context.CancelFunc
+}
+
+func (r *apiRun) snapshot() (RunStatus, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status, r.updated
+}
+
+func (r *apiRun) update(f func(s *RunStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f(&r.status)
+	close(r.updated)
+	r.updated = make(chan struct{})
+}
+
+// apiServer executes benchmarks started by the control API, the benchmarks are executed one at a time, so that the runs
+// do not compete for the resources of the load generating host.
+type apiServer struct {
+	ctx   context.Context
+	parse func([]byte) (*Benchmark, error)
+
+	mu   sync.Mutex
+	runs map[string]*apiRun
+	// order holds the IDs of the retained runs from the oldest, at most retain runs are kept.
+	order  []string
+	retain int
+	nextID int
+	active *apiRun
+	wg     sync.WaitGroup
+}
+
+// RunAPI starts the control API listening on the provided address, the API starts benchmarks from JSON scenarios, streams their progress,
+// returns their results and stops them, only the last 100 runs are kept. The scenario is converted to the benchmark by the parse function, when it is nil, the scenario
+// is decoded as JSON encoded Benchmark and benchmarks rejected by Benchmark.CheckUntrusted are not started. The API runs until the context is cancelled, the running benchmark is stopped then.
+func RunAPI(ctx context.Context, addr string, parse func([]byte) (*Benchmark, error)) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start API listener due to '%v'", err)
+	}
+	if parse == nil {
+		parse = decodeBenchmark
+	}
+
+	s := &apiServer{ctx: ctx, parse: parse, runs: make(map[string]*apiRun), retain: apiRetainedRuns}
+	server := http.Server{Handler: s.handler(), ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	fmt.Printf("API listening on %s\n", highlightStr(listener.Addr().String()))
+	err = server.Serve(listener)
+	s.wg.Wait()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func decodeBenchmark(data []byte) (*Benchmark, error) {
+	var b Benchmark
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to decode benchmark due to '%v'", err)
+	}
+	if err := b.CheckUntrusted(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// CheckUntrusted checks that the benchmark received from an untrusted client of the API does not write or read files,
+// load plugins, open listeners or send data anywhere else than to the benchmarked servers on the host of the API.
+func (b *Benchmark) CheckUntrusted() error {
+	if b.hasLocalOutputs() {
+		return errors.New("outputs written to files are not supported by the API, files cannot be written on the host of the API")
+	}
+	return b.checkUntrustedInputs()
+}
+
+// checkUntrustedInputs checks that the benchmark does not read files, load plugins, open listeners or send data anywhere else
+// than to the benchmarked servers, so that it can be run on behalf of remote clients.
+func (b *Benchmark) checkUntrustedInputs() error {
+	for _, o := range []struct {
+		flag string
+		set  bool
+	}{
+		{"--hook-plugin", b.HookPlugin != ""},
+		{"--zone-file", b.ZoneFile != ""},
+		{"--pcap", b.Pcap != ""},
+		{"--dnstap", b.Dnstap != ""},
+		{"--tls-cert", b.TLSCert != ""},
+		{"--tls-key", b.TLSKey != ""},
+		{"--tls-ca", b.TLSCA != ""},
+		{"--prometheus", b.Prometheus != ""},
+		{"--push", len(b.Push) > 0},
+		{"--otel-endpoint", b.OtelEndpoint != ""},
+	} {
+		if o.set {
+			return fmt.Errorf("%s is not supported for remote clients, files cannot be read and endpoints cannot be opened on their behalf", o.flag)
+		}
+	}
+	for _, q := range b.Queries {
+		if ok, _ := isHTTPUrl(q); ok || strings.HasPrefix(q, "@") {
+			return fmt.Errorf("query '%s' is not supported for remote clients, files cannot be read or downloaded on their behalf", q)
+		}
+	}
+	return nil
+}
+
+// hasLocalOutputs checks whether the benchmark writes any files on the host, on which it runs.
+func (b *Benchmark) hasLocalOutputs() bool {
+	for _, f := range []string{b.FailureLog, b.DiffLog, b.StatsFile, b.Csv, b.ReportCsv, b.Store, b.SaveRaw, b.HistExport, b.HistLog, b.SeriesCsv, b.PlotDir} {
+		if f != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiRunsPath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			s.list(w)
+		case http.MethodPost:
+			s.start(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc(apiRunsPath+"/", func(w http.ResponseWriter, r *http.Request) {
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, apiRunsPath+"/"), "/")
+		s.mu.Lock()
+		run := s.runs[id]
+		s.mu.Unlock()
+		if run == nil {
+			writeAPIJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("run '%s' not found", id)})
+			return
+		}
+
+		want := http.MethodGet
+		if action == "stop" {
+			want = http.MethodPost
+		}
+		if r.Method != want {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch action {
+		case "":
+			status, _ := run.snapshot()
+			writeAPIJSON(w, http.StatusOK, status)
+		case "progress":
+			streamProgress(w, r, run)
+		case "result":
+			writeResult(w, run)
+		case "stop":
+			run.cancel()
+			status, _ := run.snapshot()
+			writeAPIJSON(w, http.StatusAccepted, status)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	return mux
+}
+
+func (s *apiServer) list(w http.ResponseWriter) {
+	s.mu.Lock()
+	runs := make([]*apiRun, 0, len(s.runs))
+	for _, r := range s.runs {
+		runs = append(runs, r)
+	}
+	s.mu.Unlock()
+
+	list := make([]RunStatus, 0, len(runs))
+	for _, r := range runs {
+		status, _ := r.snapshot()
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	writeAPIJSON(w, http.StatusOK, list)
+}
+
+func (s *apiServer) start(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeAPIJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("failed to read scenario due to '%v'", err)})
+		return
+	}
+	b, err := s.parse(data)
+	if err != nil {
+		writeAPIJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil {
+		active, _ := s.active.snapshot()
+		writeAPIJSON(w, http.StatusConflict, apiError{Error: fmt.Sprintf("run '%s' is already running", active.ID)})
+		return
+	}
+	s.nextID++
+	ctx, cancel := context.WithCancel(s.ctx)
+	run := &apiRun{
+		status:  RunStatus{ID: strconv.Itoa(s.nextID), State: RunStateRunning, Server: b.Server, Started: time.Now()},
+		updated: make(chan struct{}),
+		cancel:  cancel,
+	}
+	s.runs[run.status.ID] = run
+	s.order = append(s.order, run.status.ID)
+	// the runs are executed one at a time, so the forgotten oldest runs are never running
+	for len(s.order) > s.retain {
+		delete(s.runs, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.active = run
+	status := run.status
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		s.execute(ctx, b, run)
+		s.mu.Lock()
+		s.active = nil
+		s.mu.Unlock()
+	}()
+	writeAPIJSON(w, http.StatusCreated, status)
+}
+
+// execute runs the benchmark and keeps the JSON report of the results, the benchmark is not printing anything else.
+func (s *apiServer) execute(ctx context.Context, b *Benchmark, run *apiRun) {
+	b.JSON = true
+	b.Silent = false
+	b.UI = false
+	b.ProgressFunc = func(p ProgressStats) {
+		run.update(func(s *RunStatus) { s.Progress = &p })
+	}
+
+	start := time.Now()
+	stats, err := b.Run(ctx)
+	end := time.Now().Add(-b.WarmupDuration())
+
+	var report bytes.Buffer
+	if err == nil {
+		err = b.PrintReport(&report, stats, end.Sub(start))
+	}
+	run.mu.Lock()
+	run.result = report.Bytes()
+	run.mu.Unlock()
+	run.update(func(s *RunStatus) {
+		finished := time.Now()
+		s.Finished = &finished
+		switch {
+		case err != nil:
+			s.State = RunStateFailed
+			s.Error = err.Error()
+		case b.interrupted:
+			s.State = RunStateStopped
+		default:
+			s.State = RunStateFinished
+		}
+	})
+}
+
+// streamProgress streams the progress of the run as newline delimited JSON, the last line is the final status of the run.
+func streamProgress(w http.ResponseWriter, r *http.Request, run *apiRun) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	var last *ProgressStats
+	for {
+		status, updated := run.snapshot()
+		if status.State != RunStateRunning {
+			enc.Encode(status)
+			return
+		}
+		if status.Progress != nil && status.Progress != last {
+			last = status.Progress
+			enc.Encode(last)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeResult(w http.ResponseWriter, run *apiRun) {
+	status, _ := run.snapshot()
+	switch status.State {
+	case RunStateRunning:
+		writeAPIJSON(w, http.StatusConflict, apiError{Error: fmt.Sprintf("run '%s' is still running", status.ID)})
+	case RunStateFailed:
+		writeAPIJSON(w, http.StatusUnprocessableEntity, apiError{Error: status.Error})
+	default:
+		run.mu.Lock()
+		result := run.result
+		run.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(result)
+	}
+}
+
+func writeAPIJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,218 @@
+package dnsbench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &apiServer{ctx: ctx, parse: decodeBenchmark, runs: make(map[string]*apiRun), retain: apiRetainedRuns}
+	ts := httptest.NewServer(s.handler())
+	t.Cleanup(func() {
+		cancel()
+		s.wg.Wait()
+		ts.Close()
+	})
+	return ts
+}
+
+func startRun(t *testing.T, api string, b Benchmark) (RunStatus, int) {
+	t.Helper()
+	body, err := json.Marshal(b)
+	require.NoError(t, err)
+	resp, err := http.Post(api+apiRunsPath, "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var status RunStatus
+	json.NewDecoder(resp.Body).Decode(&status)
+	return status, resp.StatusCode
+}
+
+func getRun(t *testing.T, url string, v any) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	return resp.StatusCode
+}
+
+func TestRunAPI_run(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+	api := newTestAPI(t)
+
+	started, code := startRun(t, api.URL, createBenchmark(s.Addr, false, 1))
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, RunStateRunning, started.State)
+
+	var status RunStatus
+	require.Eventually(t, func() bool {
+		getRun(t, api.URL+apiRunsPath+"/"+started.ID, &status)
+		return status.State != RunStateRunning
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, RunStateFinished, status.State)
+	assert.NotNil(t, status.Finished)
+
+	var result jsonResult
+	require.Equal(t, http.StatusOK, getRun(t, api.URL+apiRunsPath+"/"+started.ID+"/result", &result))
+	assert.Equal(t, int64(4), result.TotalRequests)
+	assert.False(t, result.Interrupted)
+
+	var list []RunStatus
+	require.Equal(t, http.StatusOK, getRun(t, api.URL+apiRunsPath, &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, started.ID, list[0].ID)
+}
+
+func TestRunAPI_stop(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+	api := newTestAPI(t)
+
+	b := createBenchmark(s.Addr, false, 1)
+	b.Count = 0
+	b.Duration = time.Minute
+	b.Rate = 100
+	b.Progress = 50 * time.Millisecond
+	started, code := startRun(t, api.URL, b)
+	require.Equal(t, http.StatusCreated, code)
+
+	// runs are executed one at a time
+	_, code = startRun(t, api.URL, b)
+	assert.Equal(t, http.StatusConflict, code)
+
+	var apiErr apiError
+	assert.Equal(t, http.StatusConflict, getRun(t, api.URL+apiRunsPath+"/"+started.ID+"/result", &apiErr))
+
+	resp, err := http.Get(api.URL + apiRunsPath + "/" + started.ID + "/progress")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	sc := bufio.NewScanner(resp.Body)
+	require.True(t, sc.Scan())
+	var progress ProgressStats
+	require.NoError(t, json.Unmarshal(sc.Bytes(), &progress), "the progress is streamed while the benchmark runs")
+
+	stop, err := http.Post(api.URL+apiRunsPath+"/"+started.ID+"/stop", "application/json", nil)
+	require.NoError(t, err)
+	stop.Body.Close()
+	assert.Equal(t, http.StatusAccepted, stop.StatusCode)
+
+	var last string
+	for sc.Scan() {
+		last = sc.Text()
+	}
+	var status RunStatus
+	require.NoError(t, json.Unmarshal([]byte(last), &status), "the stream ends with the final status of the run")
+	assert.Equal(t, RunStateStopped, status.State)
+
+	var result jsonResult
+	require.Equal(t, http.StatusOK, getRun(t, api.URL+apiRunsPath+"/"+started.ID+"/result", &result))
+	assert.True(t, result.Interrupted)
+}
+
+func TestRunAPI_errors(t *testing.T) {
+	api := newTestAPI(t)
+
+	resp, err := http.Post(api.URL+apiRunsPath, "application/json", strings.NewReader("{"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var apiErr apiError
+	assert.Equal(t, http.StatusNotFound, getRun(t, api.URL+apiRunsPath+"/42", &apiErr))
+	assert.Equal(t, "run '42' not found", apiErr.Error)
+
+	// the benchmark without queries fails to start
+	b := createBenchmark("127.0.0.1", false, 1)
+	b.Queries = nil
+	started, code := startRun(t, api.URL, b)
+	require.Equal(t, http.StatusCreated, code)
+	var status RunStatus
+	require.Eventually(t, func() bool {
+		getRun(t, api.URL+apiRunsPath+"/"+started.ID, &status)
+		return status.State != RunStateRunning
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, RunStateFailed, status.State)
+	assert.NotEmpty(t, status.Error)
+	assert.Equal(t, http.StatusUnprocessableEntity, getRun(t, api.URL+apiRunsPath+"/"+started.ID+"/result", &apiErr))
+}
+
+func TestRunAPI_localOutputs(t *testing.T) {
+	api := newTestAPI(t)
+
+	b := createBenchmark("127.0.0.1", false, 1)
+	b.FailureLog = t.TempDir() + "/failures.jsonl"
+	_, code := startRun(t, api.URL, b)
+	assert.Equal(t, http.StatusBadRequest, code, "files cannot be written on the host of the API")
+}
+
+func TestRunAPI_retainedRuns(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &apiServer{ctx: ctx, parse: decodeBenchmark, runs: make(map[string]*apiRun), retain: 2}
+	api := httptest.NewServer(server.handler())
+	defer func() {
+		cancel()
+		server.wg.Wait()
+		api.Close()
+	}()
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		require.Eventually(t, func() bool {
+			started, code := startRun(t, api.URL, createBenchmark(s.Addr, false, 1))
+			if code == http.StatusCreated {
+				ids = append(ids, started.ID)
+			}
+			return code == http.StatusCreated
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+
+	var list []RunStatus
+	require.Equal(t, http.StatusOK, getRun(t, api.URL+apiRunsPath, &list))
+	require.Len(t, list, 2, "only the last runs are kept")
+	assert.Equal(t, ids[2], list[0].ID)
+	assert.Equal(t, ids[3], list[1].ID)
+
+	var apiErr apiError
+	assert.Equal(t, http.StatusNotFound, getRun(t, api.URL+apiRunsPath+"/"+ids[0], &apiErr))
+}
+
+func TestRunAPI_untrusted(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(b *Benchmark)
+	}{
+		{name: "zone file", modify: func(b *Benchmark) { b.ZoneFile = "/etc/shadow" }},
+		{name: "pcap", modify: func(b *Benchmark) { b.Pcap = "/etc/shadow" }},
+		{name: "tls key", modify: func(b *Benchmark) { b.TLSKey = "/etc/shadow" }},
+		{name: "prometheus", modify: func(b *Benchmark) { b.Prometheus = ":9090" }},
+		{name: "push", modify: func(b *Benchmark) { b.Push = []string{"http://127.0.0.1:9091"} }},
+		{name: "otel", modify: func(b *Benchmark) { b.OtelEndpoint = "127.0.0.1:4318" }},
+		{name: "downloaded queries", modify: func(b *Benchmark) { b.Queries = []string{"http://169.254.169.254/latest"} }},
+		{name: "file queries", modify: func(b *Benchmark) { b.Queries = []string{"@/etc/shadow"} }},
+	}
+	api := newTestAPI(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := createBenchmark("127.0.0.1", false, 1)
+			tt.modify(&b)
+			_, code := startRun(t, api.URL, b)
+			assert.Equal(t, http.StatusBadRequest, code)
+		})
+	}
+}
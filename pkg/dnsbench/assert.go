@@ -0,0 +1,144 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var assertionOperators = []string{"<=", ">=", "<", ">"}
+
+// Assertion is a condition on the benchmark results, for example p99<50ms.
+type Assertion struct {
+	raw    string
+	metric string
+	op     string
+	value  float64
+}
+
+// ParseAssertions parses assertions in format <metric><operator><value>. Supported metrics are latency percentiles p50, p75, p90, p95, p99 and
+// min, mean, max with duration values, error-rate and success with percentage values and qps with numeric value.
+func ParseAssertions(assertions []string) ([]Assertion, error) {
+	var res []Assertion
+	for _, a := range assertions {
+		parsed, err := parseAssertion(a)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, parsed)
+	}
+	return res, nil
+}
+
+func parseAssertion(s string) (Assertion, error) {
+	a := Assertion{raw: s}
+	for _, op := range assertionOperators {
+		if metric, value, ok := strings.Cut(strings.ReplaceAll(s, " ", ""), op); ok {
+			a.metric, a.op = strings.ToLower(metric), op
+			var err error
+			switch a.metric {
+			case "p50", "p75", "p90", "p95", "p99", "min", "mean", "max":
+				var d time.Duration
+				d, err = time.ParseDuration(value)
+				a.value = float64(d)
+			case "error-rate", "success":
+				a.value, err = ParsePercentage(value)
+			case "qps":
+				a.value, err = strconv.ParseFloat(value, 64)
+			default:
+				return a, fmt.Errorf("unknown metric '%s' of assertion '%s'", metric, s)
+			}
+			if err != nil {
+				return a, fmt.Errorf("invalid value '%s' of assertion '%s'", value, s)
+			}
+			return a, nil
+		}
+	}
+	return a, fmt.Errorf("invalid assertion '%s', expected format <metric><operator><value>, for example p99<50ms", s)
+}
+
+// ParsePercentage parses percentage like 99.9% or fraction like 0.999 into a fraction.
+func ParsePercentage(s string) (float64, error) {
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(p, 64)
+		return v / 100, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// actual returns the value of the metric of the assertion from the merged benchmark results.
+func (a Assertion) actual(stats *ResultStats, t time.Duration) (float64, string) {
+	total := float64(stats.Counters.Total)
+	switch a.metric {
+	case "error-rate", "success":
+		v := 0.0
+		if total > 0 {
+			if a.metric == "success" {
+				v = float64(stats.Counters.Success) / total
+			} else {
+				v = float64(stats.Counters.IOError) / total
+			}
+		}
+		return v, fmt.Sprintf("%0.2f%%", v*100)
+	case "qps":
+		v := total / t.Seconds()
+		return v, fmt.Sprintf("%0.1f", v)
+	}
+
+	var v int64
+	switch a.metric {
+	case "min":
+		v = stats.Hist.Min()
+	case "mean":
+		v = int64(stats.Hist.Mean())
+	case "max":
+		v = stats.Hist.Max()
+	default:
+		q, _ := strconv.ParseFloat(strings.TrimPrefix(a.metric, "p"), 64)
+		v = stats.Hist.ValueAtQuantile(q)
+	}
+	return float64(v), roundDuration(time.Duration(v)).String()
+}
+
+func (a Assertion) holds(v float64) bool {
+	switch a.op {
+	case "<":
+		return v < a.value
+	case "<=":
+		return v <= a.value
+	case ">":
+		return v > a.value
+	default:
+		return v >= a.value
+	}
+}
+
+// CheckAssertions evaluates the assertions against the merged benchmark results and prints the outcome of each assertion under the title,
+// false is returned if any of the assertions is violated.
+func CheckAssertions(w io.Writer, title string, assertions []Assertion, stats []*ResultStats, t time.Duration) bool {
+	if len(assertions) == 0 {
+		return true
+	}
+	merged := Merge(stats)
+
+	ok := true
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, title)
+	for _, a := range assertions {
+		if merged.Hist == nil && a.metric != "error-rate" && a.metric != "success" && a.metric != "qps" {
+			ok = false
+			errPrint(w, "\tFAIL\t%s (no latencies recorded)\n", a.raw)
+			continue
+		}
+		v, formatted := a.actual(merged, t)
+		if a.holds(v) {
+			successPrint(w, "\tPASS\t%s (actual %s)\n", a.raw, formatted)
+		} else {
+			ok = false
+			errPrint(w, "\tFAIL\t%s (actual %s)\n", a.raw, formatted)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,71 @@
+package dnsbench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseAssertions(t *testing.T) {
+	assertions, err := ParseAssertions([]string{"p99<50ms", "error-rate <= 0.1%", "success>0.99", "qps>=1000"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []Assertion{
+		{raw: "p99<50ms", metric: "p99", op: "<", value: float64(50 * time.Millisecond)},
+		{raw: "error-rate <= 0.1%", metric: "error-rate", op: "<=", value: 0.001},
+		{raw: "success>0.99", metric: "success", op: ">", value: 0.99},
+		{raw: "qps>=1000", metric: "qps", op: ">=", value: 1000},
+	}, assertions)
+
+	for _, s := range []string{"p99", "p42<50ms", "p99<50", "success>abc%", "qps>fast"} {
+		_, err := ParseAssertions([]string{s})
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_checkAssertions(t *testing.T) {
+	hist := hdrhistogram.New(0, int64(time.Second), 3)
+	for i := 1; i <= 100; i++ {
+		require.NoError(t, hist.RecordValue(int64(time.Duration(i)*time.Millisecond)))
+	}
+	stats := []*ResultStats{{Hist: hist, Counters: &Counters{Total: 100, IOError: 1, Success: 99}}}
+
+	tests := []struct {
+		name       string
+		assertions []string
+		want       bool
+	}{
+		{name: "all pass", assertions: []string{"p50<=51ms", "max<=101ms", "error-rate<2%", "success>=99%", "qps>=100"}, want: true},
+		{name: "latency violated", assertions: []string{"p99<50ms"}, want: false},
+		{name: "error rate violated", assertions: []string{"success>99%", "error-rate<0.1%"}, want: false},
+		{name: "qps violated", assertions: []string{"qps>100"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertions, err := ParseAssertions(tt.assertions)
+			require.NoError(t, err)
+
+			buf := bytes.Buffer{}
+			got := CheckAssertions(&buf, "Assertions:", assertions, stats, time.Second)
+
+			assert.Equal(t, tt.want, got)
+			if tt.want {
+				assert.NotContains(t, buf.String(), "FAIL")
+			} else {
+				assert.Contains(t, buf.String(), "FAIL")
+			}
+		})
+	}
+}
+
+func Test_checkAssertions_no_assertions(t *testing.T) {
+	buf := bytes.Buffer{}
+
+	assert.True(t, CheckAssertions(&buf, "Assertions:", nil, nil, time.Second))
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,140 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultAutoConcurrencyMax is the highest number of workers used by Benchmark.AutoConcurrency, when the concurrency is not set.
+	defaultAutoConcurrencyMax = 1000
+	// autoConcurrencyInterval is how often the number of active workers is adjusted.
+	autoConcurrencyInterval = time.Second
+	// autoConcurrencyHeadroom is a multiplier of the number of workers needed according to Little's law, so that the variance of latency
+	// does not prevent workers from reaching the rate.
+	autoConcurrencyHeadroom = 1.2
+	// autoConcurrencyTolerance is a fraction of the rate, which is considered as reached.
+	autoConcurrencyTolerance = 0.95
+)
+
+// concurrencyStep is a number of active workers set by Benchmark.AutoConcurrency at the offset from the start of the benchmark.
+type concurrencyStep struct {
+	offset  time.Duration
+	workers uint32
+	// qps is the throughput measured in the interval preceding the adjustment, it is zero for the initial step
+	qps float64
+}
+
+// autoConcurrency adjusts the number of active workers, so that the rate limit is reached with as few workers as needed. Workers with index
+// not lower than the number of active workers wait until they are activated. The controller is safe for concurrent use by benchmark workers.
+type autoConcurrency struct {
+	rate  int
+	max   uint32
+	start time.Time
+
+	mu     sync.Mutex
+	active uint32
+	// changed is closed and replaced whenever the number of active workers changes, so that waiting workers are notified.
+	changed chan struct{}
+	steps   []concurrencyStep
+}
+
+func newAutoConcurrency(rate int, max uint32) *autoConcurrency {
+	return &autoConcurrency{rate: rate, max: max, start: time.Now(), active: 1, changed: make(chan struct{}), steps: []concurrencyStep{{workers: 1}}}
+}
+
+// wait blocks the worker until it is active or the context is done.
+func (a *autoConcurrency) wait(ctx context.Context, worker uint32) error {
+	for {
+		a.mu.Lock()
+		active, changed := a.active, a.changed
+		a.mu.Unlock()
+		if worker < active {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// adjust sets the number of active workers according to the snapshot of the requests finished since the previous adjustment.
+func (a *autoConcurrency) adjust(s liveSnapshot) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := a.target(s)
+	if n == a.active {
+		return
+	}
+	a.active = n
+	a.steps = append(a.steps, concurrencyStep{offset: time.Since(a.start), workers: n, qps: s.qps})
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// target returns the number of workers needed to reach the rate, by Little's law it is the rate multiplied by mean latency of the queries.
+// When the rate is not reached even though the workers should suffice, for example due to the time the workers spend between the queries,
+// the workers are added gradually.
+func (a *autoConcurrency) target(s liveSnapshot) uint32 {
+	current := a.active
+	n := current
+	if s.mean > 0 {
+		n = uint32(math.Ceil(float64(a.rate) * s.mean.Seconds() * autoConcurrencyHeadroom))
+	}
+	if s.qps < float64(a.rate)*autoConcurrencyTolerance && n <= current {
+		n = current + current/4 + 1
+	}
+	// workers are deactivated gradually, so that a single interval of low latency does not cause oscillation
+	if n < current/2 {
+		n = current / 2
+	}
+	if n < 1 {
+		n = 1
+	}
+	if n > a.max {
+		n = a.max
+	}
+	return n
+}
+
+// run adjusts the number of active workers in the interval until the stop channel is closed.
+func (a *autoConcurrency) run(live *liveStats, w *liveWindow, stop <-chan struct{}) {
+	ticker := time.NewTicker(autoConcurrencyInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.adjust(live.snapshotOf(w))
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (a *autoConcurrency) history() []concurrencyStep {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]concurrencyStep(nil), a.steps...)
+}
+
+func printConcurrencySteps(w io.Writer, steps []concurrencyStep) {
+	if len(steps) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Concurrency over time:")
+	for _, s := range steps {
+		if s.offset == 0 {
+			fmt.Fprintf(w, "\t%s:\t%s workers\n", highlightStr(roundDuration(s.offset)), highlightStr(s.workers))
+			continue
+		}
+		fmt.Fprintf(w, "\t%s:\t%s workers (after %s QPS)\n", highlightStr(roundDuration(s.offset)), highlightStr(s.workers),
+			highlightStr(fmt.Sprintf("%0.1f", s.qps)))
+	}
+}
@@ -0,0 +1,95 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_autoConcurrency_target(t *testing.T) {
+	tests := []struct {
+		name   string
+		active uint32
+		s      liveSnapshot
+		max    uint32
+		want   uint32
+	}{
+		{name: "little's law", active: 1, s: liveSnapshot{qps: 40, mean: 20 * time.Millisecond}, want: 3},
+		{name: "rate not reached", active: 8, s: liveSnapshot{qps: 50, mean: time.Millisecond}, want: 11},
+		{name: "no responses", active: 4, s: liveSnapshot{}, want: 6},
+		{name: "gradual decrease", active: 40, s: liveSnapshot{qps: 100, mean: time.Millisecond}, want: 20},
+		{name: "rate reached", active: 3, s: liveSnapshot{qps: 100, mean: 20 * time.Millisecond}, want: 3},
+		{name: "max", active: 8, max: 10, s: liveSnapshot{qps: 100, mean: time.Second}, want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			max := tt.max
+			if max == 0 {
+				max = 100
+			}
+			a := newAutoConcurrency(100, max)
+			a.active = tt.active
+			assert.Equal(t, tt.want, a.target(tt.s))
+		})
+	}
+}
+
+func Test_autoConcurrency_wait(t *testing.T) {
+	a := newAutoConcurrency(100, 10)
+	require.NoError(t, a.wait(context.Background(), 0), "the first worker is active from the start")
+
+	done := make(chan error)
+	go func() { done <- a.wait(context.Background(), 2) }()
+	a.adjust(liveSnapshot{qps: 100, mean: 20 * time.Millisecond})
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the worker to be activated")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, a.wait(ctx, 5))
+
+	steps := a.history()
+	require.Len(t, steps, 2)
+	assert.Equal(t, uint32(1), steps[0].workers)
+	assert.Equal(t, uint32(3), steps[1].workers)
+}
+
+func TestBenchmark_Run_autoConcurrency(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		time.Sleep(20 * time.Millisecond)
+		replyHandler(w, r)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Concurrency = 50
+	bench.Count = 0
+	bench.Duration = 3 * time.Second
+	bench.Rate = 200
+	bench.AutoConcurrency = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	steps := bench.concurrencySteps
+	require.Greater(t, len(steps), 1, "the number of workers is adjusted")
+	last := steps[len(steps)-1].workers
+	assert.GreaterOrEqual(t, last, uint32(4), "a single worker cannot reach the rate")
+	assert.Less(t, last, uint32(50), "workers are not oversubscribed")
+	assert.Greater(t, Merge(rs).Counters.Total, int64(300))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, 3*time.Second))
+	assert.Contains(t, buf.String(), "Concurrency over time:")
+}
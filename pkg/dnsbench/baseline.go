@@ -0,0 +1,124 @@
+package dnsbench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Baseline is a summary of benchmark results saved to a file, so that later runs can be compared against it.
+type Baseline struct {
+	Server           string        `json:"server"`
+	TotalRequests    int64         `json:"totalRequests"`
+	TotalErrors      int64         `json:"totalErrors"`
+	QueriesPerSecond float64       `json:"queriesPerSecond"`
+	Min              time.Duration `json:"minNs"`
+	Mean             time.Duration `json:"meanNs"`
+	Max              time.Duration `json:"maxNs"`
+	P50              time.Duration `json:"p50Ns"`
+	P75              time.Duration `json:"p75Ns"`
+	P90              time.Duration `json:"p90Ns"`
+	P95              time.Duration `json:"p95Ns"`
+	P99              time.Duration `json:"p99Ns"`
+}
+
+// NewBaseline summarizes the benchmark results of the server, which took time t.
+func NewBaseline(server string, stats []*ResultStats, t time.Duration) Baseline {
+	merged := Merge(stats)
+	bl := Baseline{
+		Server:           server,
+		TotalRequests:    merged.Counters.Total,
+		TotalErrors:      merged.Counters.IOError,
+		QueriesPerSecond: float64(merged.Counters.Total) / t.Seconds(),
+	}
+	if h := merged.Hist; h != nil {
+		bl.Min = time.Duration(h.Min())
+		bl.Mean = time.Duration(h.Mean())
+		bl.Max = time.Duration(h.Max())
+		bl.P50 = time.Duration(h.ValueAtQuantile(50))
+		bl.P75 = time.Duration(h.ValueAtQuantile(75))
+		bl.P90 = time.Duration(h.ValueAtQuantile(90))
+		bl.P95 = time.Duration(h.ValueAtQuantile(95))
+		bl.P99 = time.Duration(h.ValueAtQuantile(99))
+	}
+	return bl
+}
+
+// SaveBaseline saves the baseline to the JSON file.
+func SaveBaseline(path string, bl Baseline) error {
+	data, err := json.MarshalIndent(bl, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save baseline due to '%v'", err)
+	}
+	return nil
+}
+
+// LoadBaseline loads the baseline saved by SaveBaseline.
+func LoadBaseline(path string) (Baseline, error) {
+	var bl Baseline
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bl, fmt.Errorf("failed to read baseline due to '%v'", err)
+	}
+	if err := json.Unmarshal(data, &bl); err != nil {
+		return bl, fmt.Errorf("failed to parse baseline '%s' due to '%v'", path, err)
+	}
+	return bl, nil
+}
+
+// CompareBaseline prints deltas of the latency percentiles and throughput of the current results against the baseline.
+// Latency increase or throughput decrease by more than the threshold (for example 0.1 for 10%) is reported as a regression
+// and false is returned.
+func CompareBaseline(w io.Writer, base, current Baseline, threshold float64) bool {
+	ok := true
+	var lines [][]string
+	row := func(metric string, b, c float64, format func(float64) string, higherIsBetter bool) {
+		delta := 0.0
+		if b != 0 {
+			delta = (c - b) / b
+		}
+		status := "OK"
+		if (higherIsBetter && -delta > threshold) || (!higherIsBetter && delta > threshold) {
+			status = "REGRESSION"
+			ok = false
+		}
+		lines = append(lines, []string{metric, format(b), format(c), fmt.Sprintf("%+0.2f%%", delta*100), status})
+	}
+	duration := func(v float64) string {
+		return roundDuration(time.Duration(v)).String()
+	}
+	rate := func(v float64) string {
+		return fmt.Sprintf("%0.1f", v)
+	}
+
+	row("QPS", base.QueriesPerSecond, current.QueriesPerSecond, rate, true)
+	for _, m := range []struct {
+		name string
+		b, c time.Duration
+	}{
+		{"p50", base.P50, current.P50},
+		{"p75", base.P75, current.P75},
+		{"p90", base.P90, current.P90},
+		{"p95", base.P95, current.P95},
+		{"p99", base.P99, current.P99},
+		{"mean", base.Mean, current.Mean},
+	} {
+		row(m.name, float64(m.b), float64(m.c), duration, false)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Comparison with baseline of %s (regression threshold %0.2f%%):\n", highlightStr(base.Server), threshold*100)
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Metric", "Baseline", "Current", "Delta", "Status"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+	return ok
+}
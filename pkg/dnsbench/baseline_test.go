@@ -0,0 +1,73 @@
+package dnsbench
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_baseline_save_load(t *testing.T) {
+	hist := hdrhistogram.New(0, int64(time.Second), 3)
+	for i := 1; i <= 100; i++ {
+		require.NoError(t, hist.RecordValue(int64(time.Duration(i)*time.Millisecond)))
+	}
+	stats := []*ResultStats{{Hist: hist, Counters: &Counters{Total: 100, IOError: 2}}}
+
+	bl := NewBaseline("8.8.8.8:53", stats, 2*time.Second)
+	path := filepath.Join(t.TempDir(), "results.json")
+	require.NoError(t, SaveBaseline(path, bl))
+
+	loaded, err := LoadBaseline(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, bl, loaded)
+	assert.Equal(t, "8.8.8.8:53", loaded.Server)
+	assert.Equal(t, int64(100), loaded.TotalRequests)
+	assert.Equal(t, int64(2), loaded.TotalErrors)
+	assert.Equal(t, 50.0, loaded.QueriesPerSecond)
+}
+
+func Test_loadBaseline_invalid(t *testing.T) {
+	_, err := LoadBaseline(filepath.Join(t.TempDir(), "missing.json"))
+
+	assert.Error(t, err)
+}
+
+func Test_compareBaseline(t *testing.T) {
+	base := Baseline{Server: "8.8.8.8:53", QueriesPerSecond: 1000, P50: 10 * time.Millisecond, P75: 12 * time.Millisecond,
+		P90: 15 * time.Millisecond, P95: 18 * time.Millisecond, P99: 20 * time.Millisecond, Mean: 11 * time.Millisecond}
+
+	tests := []struct {
+		name   string
+		modify func(bl *Baseline)
+		want   bool
+	}{
+		{name: "no change", modify: func(bl *Baseline) {}, want: true},
+		{name: "small latency increase", modify: func(bl *Baseline) { bl.P99 = 21 * time.Millisecond }, want: true},
+		{name: "latency regression", modify: func(bl *Baseline) { bl.P99 = 30 * time.Millisecond }, want: false},
+		{name: "throughput regression", modify: func(bl *Baseline) { bl.QueriesPerSecond = 800 }, want: false},
+		{name: "throughput improvement", modify: func(bl *Baseline) { bl.QueriesPerSecond = 2000 }, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := base
+			tt.modify(&current)
+
+			buf := bytes.Buffer{}
+			got := CompareBaseline(&buf, base, current, 0.1)
+
+			assert.Equal(t, tt.want, got)
+			if tt.want {
+				assert.NotContains(t, buf.String(), "REGRESSION")
+			} else {
+				assert.Contains(t, buf.String(), "REGRESSION")
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// batchConn is UDP socket supporting batched reads and writes, which use recvmmsg and sendmmsg syscalls on Linux.
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// batchedClient sends the queries of a group of workers over a single shared UDP socket, the queries waiting to be sent
+// are written and the responses are read in batches of up to size messages. The client is safe for concurrent use.
+type batchedClient struct {
+	b      *Benchmark
+	server string
+	size   int
+
+	queue chan *batchedQuery
+	done  chan struct{}
+
+	mu      sync.Mutex
+	conn    *net.UDPConn
+	pending map[uint16]*batchedQuery
+}
+
+type batchedQuery struct {
+	id uint16
+	// buf is the pack buffer returned to the pool, once the query is written
+	buf    *[]byte
+	packed []byte
+	resp   chan batchedResponse
+}
+
+type batchedResponse struct {
+	msg *dns.Msg
+	err error
+}
+
+func (b *Benchmark) newBatchedClient(server string, size int) *batchedClient {
+	return &batchedClient{
+		b:       b,
+		server:  server,
+		size:    size,
+		queue:   make(chan *batchedQuery, size),
+		done:    make(chan struct{}),
+		pending: make(map[uint16]*batchedQuery),
+	}
+}
+
+func (c *batchedClient) query(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	for {
+		if _, ok := c.pending[msg.Id]; !ok {
+			break
+		}
+		msg.Id = dns.Id()
+	}
+	buf := packBuffers.Get().(*[]byte)
+	packed, err := msg.PackBuffer(*buf)
+	if err != nil {
+		c.mu.Unlock()
+		packBuffers.Put(buf)
+		return nil, err
+	}
+	q := &batchedQuery{id: msg.Id, buf: buf, packed: packed, resp: make(chan batchedResponse, 1)}
+	c.pending[msg.Id] = q
+	c.mu.Unlock()
+
+	timer := time.NewTimer(c.b.ReadTimeout)
+	defer timer.Stop()
+	select {
+	case c.queue <- q:
+	case <-ctx.Done():
+		c.forget(q)
+		packBuffers.Put(q.buf)
+		return nil, ctx.Err()
+	}
+
+	select {
+	case r := <-q.resp:
+		return r.msg, r.err
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	c.forget(q)
+	return nil, timeoutError(conn.LocalAddr(), conn.RemoteAddr())
+}
+
+// connection returns the shared socket, the socket is dialed by the first query and then the goroutines writing and reading the batches are started.
+func (c *batchedClient) connection(ctx context.Context) (*net.UDPConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		return c.conn, nil
+	}
+
+	network := c.b.network("udp")
+	dialStart := time.Now()
+	conn, err := c.b.dialer(network).DialContext(ctx, network, c.server)
+	if err != nil {
+		return nil, err
+	}
+	observeConnection(ctx, conn.RemoteAddr(), time.Since(dialStart))
+	c.conn = conn.(*net.UDPConn)
+
+	var pc batchConn = ipv4.NewPacketConn(c.conn)
+	if addressFamily(c.conn.RemoteAddr()) == "IPv6" {
+		pc = ipv6.NewPacketConn(c.conn)
+	}
+	go c.write(pc)
+	go c.read(pc)
+	return c.conn, nil
+}
+
+// write writes the queries waiting in the queue in batches, until the client is closed.
+func (c *batchedClient) write(pc batchConn) {
+	msgs := make([]ipv4.Message, c.size)
+	batch := make([]*batchedQuery, 0, c.size)
+	for {
+		batch = batch[:0]
+		select {
+		case q := <-c.queue:
+			batch = append(batch, q)
+		case <-c.done:
+			return
+		}
+	drain:
+		for len(batch) < c.size {
+			select {
+			case q := <-c.queue:
+				batch = append(batch, q)
+			default:
+				break drain
+			}
+		}
+
+		for i, q := range batch {
+			msgs[i] = ipv4.Message{Buffers: [][]byte{q.packed}}
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(c.b.WriteTimeout))
+		for written := 0; written < len(batch); {
+			n, err := pc.WriteBatch(msgs[written:len(batch)], 0)
+			if err != nil {
+				c.mu.Lock()
+				for _, q := range batch[written:] {
+					if c.pending[q.id] == q {
+						delete(c.pending, q.id)
+						q.resp <- batchedResponse{err: err}
+					}
+				}
+				c.mu.Unlock()
+				break
+			}
+			written += n
+		}
+		for _, q := range batch {
+			packBuffers.Put(q.buf)
+		}
+	}
+}
+
+// read reads the responses in batches and passes them to the queries waiting for them, until the client is closed.
+func (c *batchedClient) read(pc batchConn) {
+	msgs := make([]ipv4.Message, c.size)
+	for i := range msgs {
+		msgs[i].Buffers = [][]byte{make([]byte, dns.MaxMsgSize)}
+	}
+	for {
+		n, err := pc.ReadBatch(msgs, 0)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			// errors like refused connection cannot be attributed to a single query, so all the queries in flight fail
+			c.mu.Lock()
+			for id, q := range c.pending {
+				q.resp <- batchedResponse{err: err}
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+			continue
+		}
+		for _, m := range msgs[:n] {
+			r := new(dns.Msg)
+			if err := r.Unpack(m.Buffers[0][:m.N]); err != nil {
+				continue
+			}
+			c.mu.Lock()
+			// responses to the queries, which already timed out, are dropped
+			if q, ok := c.pending[r.Id]; ok {
+				delete(c.pending, r.Id)
+				q.resp <- batchedResponse{msg: r}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// forget removes the query, which is no longer waiting for the response.
+func (c *batchedClient) forget(q *batchedQuery) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending[q.id] == q {
+		delete(c.pending, q.id)
+	}
+}
+
+func (c *batchedClient) close() {
+	close(c.done)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
@@ -0,0 +1,96 @@
+package dnsbench
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_Run_batch(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 10)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Concurrency = 4
+	bench.Batch = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 4, "Run(ctx) rstats")
+	for _, r := range rs {
+		assertResultStats(t, r)
+		assertTimings(t, r)
+	}
+	assert.Equal(t, int64(2), Merge(rs).Families["IPv4"], "Run(ctx) sockets")
+}
+
+func TestBenchmark_Run_batch_timeout(t *testing.T) {
+	// server, which never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	bench := createBenchmark(conn.LocalAddr().String(), false, 1)
+	bench.ReadTimeout = 100 * time.Millisecond
+	bench.Batch = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.Total, "Run(ctx) total counter")
+	assert.Equal(t, int64(4), merged.Counters.IOError, "Run(ctx) error counter")
+	require.NotEmpty(t, merged.Errors)
+	assert.ErrorContains(t, merged.Errors[0], "i/o timeout")
+}
+
+func TestBenchmark_normalize_batch(t *testing.T) {
+	tests := []struct {
+		name    string
+		bench   Benchmark
+		wantErr string
+	}{
+		{
+			name:    "TCP",
+			bench:   Benchmark{Server: "127.0.0.1", TCP: true, Batch: 2},
+			wantErr: "--batch is supported only for plain DNS over UDP",
+		},
+		{
+			name:    "DoH",
+			bench:   Benchmark{Server: "https://127.0.0.1/dns-query", Batch: 2},
+			wantErr: "--batch is supported only for plain DNS over UDP",
+		},
+		{
+			name:    "query per connection",
+			bench:   Benchmark{Server: "127.0.0.1", QperConn: 10, Batch: 2},
+			wantErr: "--batch cannot be combined with --query-per-conn, --fresh-connection-per-query or --tsig options",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bench.normalize()
+
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
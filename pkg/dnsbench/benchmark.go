@@ -0,0 +1,2643 @@
+package dnsbench
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/tantalor93/doh-go/doh"
+	"github.com/tantalor93/doq-go/doq"
+	"go.uber.org/ratelimit"
+	"golang.org/x/net/http2"
+)
+
+var client = http.Client{
+	Timeout: 120 * time.Second,
+}
+
+// Benchmark is representation of benchmark scenario.
+type Benchmark struct {
+	Server      string
+	Types       []string
+	Count       int64
+	Total       int64
+	Concurrency uint32
+
+	// Class is a class of the queries, IN is used when it is not set.
+	Class string
+	// Opcode is an opcode of the queries, QUERY is used when it is not set. NOTIFY queries are sent with the AA flag set,
+	// UPDATE queries carry the name of the zone in the question and have empty prerequisite and update sections.
+	Opcode string
+
+	Rate            int
+	RateLimitWorker int
+	// RateRamp is a schedule of global rate limits in format rate:duration[,rate:duration...], the benchmark runs until the schedule ends.
+	RateRamp string
+	// RateDistribution is a distribution of intervals between queries issued by the rate limiters, supported values are constant, uniform and poisson.
+	RateDistribution string
+	// OpenLoop schedules queries up front from Rate and measures latencies from the intended send times instead of the actual ones,
+	// so that the results do not suffer from coordinated omission, Concurrency limits the number of queries in flight.
+	OpenLoop bool
+	// AutoConcurrency adjusts the number of active workers during the benchmark, so that Rate is reached without oversubscribing the server,
+	// Concurrency is the highest number of workers used (1000 if not set). The number of active workers over time is reported.
+	AutoConcurrency bool
+	// Burst is a number of queries sent back-to-back at the start of each BurstInterval instead of pacing the queries evenly,
+	// Concurrency should be at least Burst, so that all the queries of the burst are sent at once. Drain time and dropped queries of
+	// the bursts and latencies by position within the burst are reported.
+	Burst         int
+	BurstInterval time.Duration
+	QperConn      int64
+	// FreshConnection forces a new connection for each query, it is equivalent to QperConn set to 1.
+	FreshConnection bool
+	// MaxInflight is a number of queries each concurrent worker keeps in flight on its TCP or DoT connection, the queries are pipelined
+	// and the responses can arrive out of order. Each in-flight query issues the queries the same way as a separate worker sharing the connection.
+	MaxInflight uint32
+	// Batch is a size of groups of workers sharing a single UDP socket, the queries of the group are sent and the responses are received
+	// in batches of up to Batch messages using sendmmsg and recvmmsg syscalls on Linux, which reduces the syscall overhead at high rates.
+	Batch uint32
+
+	Recurse bool
+
+	// DNS0x20 enables randomization of letter case in query names and verification that the responses echo the same case.
+	DNS0x20 bool
+
+	// DNSSEC enables requesting DNSSEC records and validation of signatures and chain of trust of the responses.
+	DNSSEC bool
+
+	Probability float64
+
+	// NXDomainRatio is a fraction of the queries, whose names are prefixed with random label, so that the names do not exist
+	// and the negative answers of the server are benchmarked alongside the positive ones.
+	NXDomainRatio float64
+
+	// MalformedRate is a fraction of the queries, which are mutated to malformed wire format and sent over a new connection instead
+	// of the regular queries, the outcomes of the malformed queries are reported by the mutation strategy.
+	MalformedRate float64
+
+	// Zipf is an exponent of Zipf distributed popularity of the queries, when set, queries are drawn randomly instead of being iterated in order,
+	// the first query is the most popular. Queries can be also drawn according to explicit weights provided in format hostname,type,weight.
+	Zipf float64
+
+	// Seed seeds the random generators of the benchmark, so that two runs with the same seed and configuration issue the identical sequence of queries,
+	// including the queries skipped by Probability, message IDs, expanded templates and drawn query types, and the datapoints sampled by DatapointSampleRate
+	// are the same. Random seed is used when not set.
+	Seed int64
+
+	// UDPSize enables EDNS0 advertising the UDP buffer size, the size of the OPT record of the queries is overridden when the record
+	// is added by other options. Zero keeps the queries without EDNS0.
+	UDPSize uint16
+	// EDNSSweep are comma separated EDNS buffer sizes, for example 512,1232,4096, each worker rotates the sizes advertised by its queries
+	// and the truncation rate and latencies are reported by the buffer size, see https://www.dnsflagday.net/2020/.
+	EDNSSweep string
+	// EdnsOpt are EDNS0 options attached to the queries in format code[:value], value is hexadecimal payload of the option.
+	EdnsOpt []string
+	// Cookies enables DNS Cookies, each worker sends its own client cookie and echoes the server cookie returned by the server in the following queries.
+	Cookies bool
+	// PadTo pads the queries using EDNS0 Padding option (RFC 7830) to the multiple of PadTo bytes, 468 is the block size
+	// recommended for the queries by RFC 8467.
+	PadTo int
+	// NSID requests the name server identifier (RFC 5001) of the responding servers, latencies are reported by the identifiers,
+	// so that the instances of anycast services answering the queries can be told apart.
+	NSID bool
+	// ChaosProbeInterval enables hostname.bind CH TXT probes of the identity of the server answering the queries of each worker, the server is probed
+	// before the first query and then after each interval. The probed identity is used for the responses without NSID.
+	ChaosProbeInterval time.Duration
+	// Responders receives UDP responses over unconnected sockets and records the source address of each response, latencies are reported
+	// by the responding addresses and the responses arriving from other address than the benchmarked server are counted.
+	Responders bool
+	// CacheModel tells apart the first queries of each question (name, type and class) from the repeated ones, the latencies of the first-seen
+	// questions, which are presumably resolved upstream, and the repeated questions, which are presumably answered from the cache, are reported.
+	CacheModel bool
+	// RRLInterval classifies the outcomes of the queries in each interval as answered, slipped (truncated response without answers) or dropped
+	// (timed out), the way response rate limiting (RRL) of authoritative servers responds to the limited queries. Zero disables the classification.
+	RRLInterval time.Duration
+	// ClientUsage reports the resource usage of the load generator itself during the benchmark, that is CPU, memory, open sockets and GC pauses.
+	// The usage is sampled regardless, so that the client saturated during the benchmark is warned about even if the usage is not reported.
+	ClientUsage bool
+	// FirstByte records the time until the first byte of the response and until the full response is read since the query was written
+	// to the TCP, DoT or DoH connection, so that slow streaming of large responses can be told apart from slow processing of the queries.
+	FirstByte bool
+	// ECS are client subnets attached to the queries using EDNS0 Client Subnet option, one of the subnets is chosen randomly for each query.
+	ECS []string
+
+	TCP bool
+	DOT bool
+	DOQ bool
+	// Proxy is URL of SOCKS5 or HTTP proxy, through which DoH, DoT and plain DNS over TCP connections are established, for example socks5://127.0.0.1:1080.
+	// When not set, DoH uses proxy configured by HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+	Proxy string
+	// DNSCrypt is a stamp (sdns://...) of DNSCrypt resolver, when set, the queries are encrypted using DNSCrypt protocol
+	// and sent to the address of the stamp instead of the Server.
+	DNSCrypt string
+
+	// SourceIP is a local address from which the queries are sent.
+	SourceIP string
+	// Interface is a name of network interface, whose address is used as a local address from which the queries are sent.
+	Interface string
+	// SourceIPRange is a range of local addresses in CIDR notation, from which the queries are sent, the addresses are rotated for each new connection.
+	SourceIPRange string
+
+	// IPv4 and IPv6 force connections to the server over the selected address family.
+	IPv4 bool
+	IPv6 bool
+
+	// RetryTruncated enables retrying of queries over TCP, when UDP response is truncated.
+	RetryTruncated bool
+
+	// SimulateStub makes each worker behave like a stub resolver of a host, the answers are cached according to their TTLs,
+	// CNAME records are followed, truncated responses are retried over TCP and lost queries are retransmitted with doubling timeouts.
+	// Queries answered from the cache are not sent and are counted in Counters.StubCacheHits.
+	SimulateStub bool
+
+	// FollowCNAMEs enables following of CNAME records, when the response does not contain records of the question type for the CNAME target,
+	// the target is queried until the chain is resolved. The latency of the query includes the follow-up queries.
+	FollowCNAMEs bool
+
+	// Transfer enables benchmarking of zone transfers, each query is a full zone transfer of type axfr or ixfr.
+	Transfer string
+	// IxfrSerial is a serial of the zone version known by the client, used for IXFR queries.
+	IxfrSerial uint32
+
+	// UpdateZone enables benchmarking of dynamic updates, each query is turned into UPDATE message of the zone, the name and type
+	// of the query are the owner name and type of the updated record. UpdateOp is the operation of the messages, records with random
+	// data are added, RRsets are deleted or replaced with records with random data. UpdateTTL is the TTL of the added records.
+	UpdateZone string
+	UpdateOp   string
+	UpdateTTL  uint32
+
+	// TSIG is a key used for signing queries and verifying responses in format name:algorithm:secret.
+	TSIG string
+
+	WriteTimeout   time.Duration
+	ReadTimeout    time.Duration
+	ConnectTimeout time.Duration
+	// TCPKeepalive is a period of TCP keep-alive probes of the connections, negative value disables the probes, the default of Go is used when not set.
+	TCPKeepalive time.Duration
+	// IdleTimeout closes the persistent TCP, DoT and DoH/1.1 connections idle for longer than the timeout, before the next query is sent over them.
+	IdleTimeout time.Duration
+	// EDNSTCPKeepalive adds edns-tcp-keepalive option (RFC 7828) to the queries over TCP and DoT, the connections are closed once they are idle
+	// for longer than the timeout advertised by the server and the advertised timeouts are reported.
+	EDNSTCPKeepalive bool
+	// RequestTimeout bounds the complete exchange of each query attempt using context deadline, including the whole HTTP request for DoH,
+	// queries failed due to timeouts are counted in Counters.Timeouts.
+	RequestTimeout time.Duration
+
+	// Retries is a number of retries of queries failed due to I/O errors or timeouts, each attempt is limited by RequestTimeout.
+	Retries int
+	// RetryBackoff is a delay before the first retry, the delay is doubled with each following retry.
+	RetryBackoff time.Duration
+
+	// UDPRetry is a number of retransmits of UDP queries, the query is retransmitted with fresh message ID whenever UDPRetryInterval elapses
+	// without response and the response to any of the sent messages is accepted until RequestTimeout elapses, the same way the stub resolvers do.
+	UDPRetry         int
+	UDPRetryInterval time.Duration
+
+	// DropRate is a fraction of query attempts dropped by the client without being sent, they fail once RequestTimeout elapses like the queries
+	// lost in the network. InjectDelay delays each query attempt before it is sent, it is in format delay[±jitter], for example 5ms±2ms.
+	// The injected events simulate degraded network to evaluate retries and timeouts, the queries failed due to them are not counted as errors.
+	DropRate    float64
+	InjectDelay string
+
+	Rcodes bool
+
+	// ExpectRcode is a response code expected in the responses, for example NXDOMAIN.
+	ExpectRcode string
+	// ExpectAnswerCount is an expected number of records in the answer section of the responses, for example >=1.
+	ExpectAnswerCount string
+	// ExpectIP are subnets in CIDR notation, the answer section of the responses is expected to contain A or AAAA record within any of them.
+	ExpectIP []string
+
+	HistDisplay bool
+	HistMin     time.Duration
+	HistMax     time.Duration
+	HistPre     int
+
+	// Percentiles are comma separated latency percentiles reported instead of the default p99, p95, p90, p75 and p50,
+	// for example 50,90,99,99.9,99.99.
+	Percentiles string
+	// HistBuckets is a number of buckets of equal width, into which the reported and exported latency distribution is aggregated,
+	// the buckets of the histogram are reported when not set.
+	HistBuckets int
+
+	Csv  string
+	JSON bool
+
+	// ReportCsv is a file to which a row summarizing the results of the run is appended in CSV format, so that the results of multiple runs
+	// can be collected in a single file. Response codes are counted only when Rcodes is set.
+	ReportCsv string
+
+	// Store is a SQLite file to which the configuration and the merged results of the run are appended, so that the runs can be listed
+	// and compared later using LoadRuns and PrintRuns.
+	Store string
+
+	// SaveRaw is a JSON file to which the configuration and the merged raw results of the run including the datapoints and the histograms
+	// are saved, so that the results of multiple runs can be merged and reported again later using LoadRaw.
+	SaveRaw string
+
+	// HistExport is a file to which the merged latency histogram is exported in the .hgrm percentile distribution format of HdrHistogram.
+	HistExport string
+	// HistLog is a file to which latency histograms of intervals of HistLogInterval are exported in the histogram log format of HdrHistogram.
+	HistLog         string
+	HistLogInterval time.Duration
+
+	// SeriesInterval is a length of intervals into which the results are aggregated to report time series of throughput, error rate and latencies, 0 disables the time series.
+	SeriesInterval time.Duration
+	// SeriesCsv is a file to which the time series are exported in CSV format.
+	SeriesCsv string
+	// DatapointSampleRate is a fraction of the queries, whose latencies are stored in ResultStats.Timings, for example 0.01 stores every hundredth
+	// datapoint on average. Counts reported from the sampled datapoints like throughput of plots and time series are scaled back by the rate. 0 stores all the datapoints.
+	DatapointSampleRate float64
+	// MaxDatapoints is the highest number of the datapoints stored in ResultStats.Timings and ResultStats.ErrorTimes by all the workers together.
+	// Whenever the datapoints of a worker exceed its share, its sample rate is halved and half of its datapoints are dropped, so that the memory
+	// is bounded regardless of the number of the queries. 0 does not limit the datapoints.
+	MaxDatapoints int
+	// NoDatapoints disables storing of the latencies of individual queries in ResultStats.Timings, which are held in memory until the end of the benchmark.
+	// The datapoints are needed only for plots, time series and rate ramp steps.
+	NoDatapoints bool
+
+	// WorkerStats enables reporting of the throughput, errors and latencies of each worker, so that the skew between the workers can be spotted.
+	WorkerStats bool
+
+	Silent bool
+	Color  bool
+
+	// UI enables live terminal dashboard of the running benchmark, the dashboard is rendered to stderr.
+	UI bool
+	// Progress is an interval of printing progress of the running benchmark to stderr.
+	Progress time.Duration
+	// ProgressFunc is called with the progress of the running benchmark in the interval of Progress (1s if not set) instead of printing it to stderr,
+	// it is not sent to distributed workers.
+	ProgressFunc func(ProgressStats) `json:"-"`
+
+	// RequestHook is called with each query before it is padded, signed and sent, so that the query can be modified, for example custom
+	// EDNS options can be attached. The hook is called concurrently by the workers. It is not sent to distributed workers.
+	RequestHook func(m *dns.Msg) `json:"-"`
+	// ResponseHook validates the response r to the query q, responses for which the hook returns an error are counted as rejected
+	// by the error. The hook is called concurrently by the workers. It is not sent to distributed workers.
+	ResponseHook func(q, r *dns.Msg) error `json:"-"`
+	// HookPlugin is a path of Go plugin built with -buildmode=plugin exporting RequestHook and ResponseHook functions or variables
+	// of the types of Benchmark.RequestHook and Benchmark.ResponseHook, the plugin has to be built against the same versions of the packages.
+	// The plugin is loaded only by the local run, it is not sent to distributed workers.
+	HookPlugin string `json:"-"`
+
+	// LogLevel is the level of the structured logs of the benchmark, one of debug, info and warn (default).
+	// Debug logs include connection events, retries and failed queries of each worker, info logs the phases of the benchmark.
+	LogLevel string
+	// LogOutput is where the logs are written, stderr if not set, it is not sent to distributed workers.
+	LogOutput io.Writer `json:"-"`
+
+	PlotDir    string
+	PlotFormat string
+
+	// DohMethod is the HTTP method of DoH requests, get, post or both, which alternates GET and POST requests and reports latencies by the method.
+	DohMethod   string
+	DohProtocol string
+	// DoHConnections is a number of connections of DoH transport shared by all the workers, the requests of the workers are multiplexed
+	// over the connections regardless of Concurrency. The transport decides the number of connections on its own when not set.
+	DoHConnections int
+	// DoHHeaders are headers added to DoH requests in format "Name: value", for example "Authorization: Bearer token", Host header
+	// overrides the host of the requests. The server can be also RFC 8484 URI template like https://dns.example.com/resolve{?dns}.
+	DoHHeaders []string
+	// UserAgent is User-Agent header of DoH requests, the default of Go HTTP client is used when not set.
+	UserAgent string
+
+	Insecure bool
+
+	// TLSCert and TLSKey are files with PEM encoded client certificate and its private key used for mutual TLS authentication of DoT, DoH and DoQ connections.
+	TLSCert string
+	TLSKey  string
+	// TLSCA is a file with PEM encoded CA certificates used for verification of server certificates instead of the system CA certificates.
+	TLSCA string
+	// TLSServerName is a server name sent in SNI and used for verification of server certificates instead of the server name derived from the server address.
+	TLSServerName string
+	// TLSMinVersion and TLSMaxVersion limit TLS versions used for connections, supported versions are 1.0, 1.1, 1.2 and 1.3.
+	TLSMinVersion string
+	TLSMaxVersion string
+	// TLSCipherSuites are names of cipher suites enabled for TLS 1.0-1.2 connections, TLS 1.3 cipher suites are not configurable.
+	TLSCipherSuites []string
+
+	// DiffServer is a DNS server to which each query is sent as well and its answers are compared with the answers of Server.
+	DiffServer string
+	DiffLog    string
+
+	// FailoverServers is a list of plain DNS or DoT servers, to which the queries are sent in order like by stub resolvers configured with
+	// multiple nameservers, the query unanswered within FailoverTimeout is sent to the next server. Server is set to the first server.
+	FailoverServers []string
+	FailoverTimeout time.Duration
+
+	// DualAAAA sends AAAA query concurrently with each A query like getaddrinfo does, the latency of the A query is the time until both answers
+	// are received, the latencies of both families and the time to the first usable answer are reported in ResultStats.Dual.
+	DualAAAA bool
+
+	// SearchDomains and Ndots qualify the relative query names like resolv.conf options search and ndots do, names with fewer than Ndots dots
+	// are qualified with the first search domain, see SystemResolvers.
+	SearchDomains []string
+	Ndots         int
+	// SearchExpansion looks up the relative query names by trying the names qualified with each of SearchDomains like stub resolvers do
+	// instead of qualifying them with the first search domain, the latency of the query is the latency of the whole lookup, the misses
+	// and the overhead of the expansion are reported in ResultStats.Search, see K8sPreset.
+	SearchExpansion bool
+
+	// FailureLog is a file to which the queries and the responses failing the checks like ID mismatches, unexpected response codes,
+	// expectations or DNSSEC validation are logged in JSON lines format.
+	FailureLog string
+
+	// Prometheus is an address on which live benchmark metrics are exposed for Prometheus during the benchmark.
+	Prometheus string
+
+	// Push are URLs of metrics backends, to which throughput, latency percentiles and errors of the running benchmark are pushed in the interval of PushInterval
+	// (10s if not set) and once more when the benchmark ends. Supported URLs are influxdb://host:port/database (influxdbs:// for HTTPS),
+	// graphite://host:port[/prefix] and statsd://host:port[/prefix].
+	Push         []string
+	PushInterval time.Duration
+
+	// StatsInterval is an interval of writing the results of the running benchmark to StatsFile in JSON lines format, each line contains
+	// the results of the requests finished in the interval, or since the start of the benchmark when StatsCumulative is set.
+	StatsInterval   time.Duration
+	StatsFile       string
+	StatsCumulative bool
+
+	// OtelEndpoint is an OTLP/HTTP endpoint of OpenTelemetry collector, to which spans of the sampled queries are exported.
+	OtelEndpoint string
+	// OtelSampleRate is a fraction of the queries, whose spans are exported to OtelEndpoint (0.01 if not set).
+	OtelSampleRate float64
+
+	Queries []string
+
+	Pcap string
+	// PtrFromCIDR are address ranges in CIDR notation, when set, PTR queries of all the addresses in the ranges are issued instead of the provided queries.
+	PtrFromCIDR []string
+	// PtrShuffle shuffles the PTR queries generated from PtrFromCIDR ranges, instead of issuing them in order of the addresses.
+	PtrShuffle bool
+	// ZoneFile is a zone file in the standard master file format, when set, the owner names and types of the records in the zone are queried
+	// instead of the provided queries.
+	ZoneFile string
+	// ZoneTypes are the types of the records of ZoneFile, which are queried, all the types are queried by default. When the types are weighted,
+	// like A:70,AAAA:25,MX:5, the questions are drawn randomly, so that the queries of each type follow its weight.
+	ZoneTypes []string
+	// Dnstap is a dnstap file or socket in format unix:/path/to/socket or tcp:host:port, from which the replayed queries are read.
+	Dnstap string
+	// RespectTiming replays the queries captured in Pcap or Dnstap with the original timing of the capture instead of issuing them as fast as possible,
+	// the captured queries are dispatched to the workers in the order in which they were captured.
+	RespectTiming bool
+	// ReplaySpeed is a multiplier of the original rate of the queries captured in Pcap or Dnstap, it implies RespectTiming.
+	ReplaySpeed float64
+
+	Duration time.Duration
+
+	// MaxRuntime hard-stops the whole run including the warm-up after the duration, queries planned by Count or Total, which were never sent,
+	// are counted in Counters.Unsent and queries in flight when the run is stopped are counted in Counters.Unanswered.
+	MaxRuntime time.Duration
+
+	// NetworkRTT measures the network RTT to the server before the benchmark by TCP connect (tcp) or ICMP echo (icmp) and reports
+	// the server processing latency as the latency minus the median RTT, NetworkRTTSamples is a number of the measured samples.
+	NetworkRTT        string
+	NetworkRTTSamples int
+
+	// Warmup is a duration of warm-up phase preceding the benchmark, responses to the queries issued during the warm-up are not recorded.
+	Warmup time.Duration
+	// WarmupQueries is a number of queries issued by each worker during the warm-up phase preceding the benchmark.
+	WarmupQueries int64
+
+	// internal variable so we do not have to parse the address with each request.
+	useDoH  bool
+	useQuic bool
+
+	rateSteps []rateStep
+	ecs       []ecsSubnet
+	ednsOpts  []*dns.EDNS0_LOCAL
+	tsig      *tsigKey
+	expect    *expectations
+	hooks     hooks
+	dnscrypt  *dnscryptStamp
+	proxy     *url.URL
+
+	// dohHeader and dohTemplates are the parsed DoHHeaders and UserAgent and URI templates of DoH servers keyed by the servers.
+	dohHeader    http.Header
+	dohTemplates map[string]*dohTemplate
+
+	// injectDelay and injectJitter are parsed from InjectDelay.
+	injectDelay  time.Duration
+	injectJitter time.Duration
+
+	// capture holds the queries read from Pcap or Dnstap.
+	capture []capturedQuery
+
+	source *sourceAddrs
+
+	tlsCerts   []tls.Certificate
+	tlsRootCAs *x509.CertPool
+
+	tlsMinVersion   uint16
+	tlsMaxVersion   uint16
+	tlsCipherSuites []uint16
+
+	// seen holds the questions issued by the last run, see CacheModel.
+	seen *questionSet
+
+	// warmupDuration is how long the warm-up phase of the last run took, it is not included in the benchmark duration.
+	warmupDuration time.Duration
+
+	log *logger
+
+	// pushers push the results of the running benchmark to the metrics backends of Push URLs.
+	pushers []pusher
+
+	// otelURL is the URL of the traces of OtelEndpoint, otel exports the spans of the sampled queries during the run.
+	otelURL string
+	otel    *otelExporter
+
+	// zoneTypes and zoneTypeWeights are the parsed ZoneTypes.
+	zoneTypes       []uint16
+	zoneTypeWeights []float64
+
+	// relativeNames are the relative query names looked up with the search domains keyed by the fully qualified names, see SearchExpansion.
+	relativeNames map[string]bool
+
+	// percentiles are the parsed Percentiles.
+	percentiles []float64
+
+	// ednsSweep are the parsed EDNSSweep sizes.
+	ednsSweep []uint16
+
+	// qtypes are the parsed query types, when the types are weighted, typeMix draws the type of each query from qtypes.
+	qtypes  []uint16
+	typeMix *querySampler
+
+	// qclass and opcode are the parsed Class and Opcode, qclass is zero when the class of the questions is not overridden.
+	qclass uint16
+	opcode int
+
+	// concurrencySteps are the numbers of active workers set by AutoConcurrency during the last run.
+	concurrencySteps []concurrencyStep
+
+	// interrupted marks the results as partial, since the context of the benchmark was cancelled before the benchmark finished.
+	interrupted bool
+
+	// maxRuntimeExceeded marks the results as partial, since the run was hard-stopped after MaxRuntime.
+	maxRuntimeExceeded bool
+
+	// usage is the resource usage of the load generator during the last run, see ClientUsage.
+	usage *clientUsage
+}
+
+// New creates a benchmark of the server with the queries and the same defaults as the dnspyre command, for example
+// single concurrent worker, A queries, 5s request timeout and response codes counting enabled.
+func New(server string, queries ...string) *Benchmark {
+	return &Benchmark{
+		Server:           server,
+		Queries:          queries,
+		Types:            []string{"A"},
+		Concurrency:      1,
+		RateDistribution: constantDistribution,
+		Recurse:          true,
+		Probability:      1,
+		WriteTimeout:     time.Second,
+		ReadTimeout:      3 * time.Second,
+		ConnectTimeout:   time.Second,
+		RequestTimeout:   5 * time.Second,
+		RetryBackoff:     10 * time.Millisecond,
+		Rcodes:           true,
+		HistMin:          400 * time.Microsecond,
+		HistPre:          1,
+		HistDisplay:      true,
+		HistLogInterval:  time.Second,
+		Color:            true,
+		PlotFormat:       "png",
+		DohMethod:        "post",
+		DohProtocol:      "1.1",
+	}
+}
+
+// WarmupDuration returns how long the warm-up phase of the last run took, it is not included in the measured results.
+func (b *Benchmark) WarmupDuration() time.Duration {
+	return b.warmupDuration
+}
+
+type queryFunc func(context.Context, string, *dns.Msg) (*dns.Msg, error)
+
+func (b *Benchmark) normalize() error {
+	level, err := parseLogLevel(b.LogLevel)
+	if err != nil {
+		return err
+	}
+	out := b.LogOutput
+	if out == nil {
+		out = os.Stderr
+	}
+	b.log = newLogger(out, level)
+
+	b.dnscrypt = nil
+	if b.DNSCrypt != "" {
+		stamp, err := parseDNSCryptStamp(b.DNSCrypt)
+		if err != nil {
+			return err
+		}
+		if b.DOT || b.DOQ || b.DiffServer != "" || b.Transfer != "" || b.TSIG != "" || b.RetryTruncated {
+			return errors.New("--dnscrypt cannot be combined with --dot, --doq, --diff, --transfer, --tsig or --retry-truncated options")
+		}
+		b.dnscrypt = &stamp
+		b.Server = stamp.addr
+	}
+
+	if len(b.FailoverServers) > 0 {
+		if len(b.FailoverServers) < 2 {
+			return errors.New("--servers requires at least two servers")
+		}
+		if b.FailoverTimeout <= 0 {
+			return errors.New("--failover-timeout has to be positive")
+		}
+		if b.DOQ || b.dnscrypt != nil || b.DiffServer != "" || b.Transfer != "" || b.MaxInflight > 1 || b.Batch > 1 {
+			return errors.New("--servers cannot be combined with --doq, --dnscrypt, --diff, --transfer, --max-inflight or --batch options")
+		}
+		for i, s := range b.FailoverServers {
+			if useDoH, _ := isHTTPUrl(s); useDoH || strings.HasPrefix(s, "quic://") {
+				return errors.New("--servers supports only plain DNS and DoT servers")
+			}
+			b.FailoverServers[i] = b.addPortIfMissing(s)
+		}
+		b.Server = b.FailoverServers[0]
+	}
+
+	b.useDoH, _ = isHTTPUrl(b.Server)
+	b.useQuic = b.DOQ || strings.HasPrefix(b.Server, "quic://")
+	b.Server = strings.TrimPrefix(b.Server, "quic://")
+
+	b.Server = b.addPortIfMissing(b.Server)
+
+	b.proxy = nil
+	if b.Proxy != "" {
+		u, err := parseProxy(b.Proxy)
+		if err != nil {
+			return err
+		}
+		if b.useQuic || b.dnscrypt != nil || (b.useDoH && b.DohProtocol == "3") || (!b.useDoH && !b.TCP && !b.DOT) {
+			return errors.New("--proxy is supported only for DoH over HTTP/1.1 and HTTP/2, DoT and plain DNS over TCP")
+		}
+		b.proxy = u
+	} else if b.useDoH && b.DohProtocol != "3" {
+		b.proxy = environmentProxy(b.Server)
+	}
+
+	if err := b.loadTLS(); err != nil {
+		return err
+	}
+
+	if b.DiffServer != "" {
+		useDoH, _ := isHTTPUrl(b.DiffServer)
+		useQuic := b.DOQ || strings.HasPrefix(b.DiffServer, "quic://")
+		if useDoH != b.useDoH || useQuic != b.useQuic {
+			return errors.New("compared servers have to use the same protocol")
+		}
+		b.DiffServer = b.addPortIfMissing(strings.TrimPrefix(b.DiffServer, "quic://"))
+	}
+
+	templates, err := parseDoHTemplates(b.Server, b.DiffServer)
+	if err != nil {
+		return err
+	}
+	b.dohTemplates = templates
+	header, err := parseDoHHeaders(b.DoHHeaders, b.UserAgent)
+	if err != nil {
+		return err
+	}
+	if header != nil && !b.useDoH {
+		return errors.New("--header and --user-agent are supported only for DoH")
+	}
+	b.dohHeader = header
+
+	if b.Total > 0 && b.Count > 0 {
+		return errors.New("--number and --total is specified at once, only one can be used")
+	}
+
+	if b.RateRamp != "" {
+		steps, err := parseRateRamp(b.RateRamp)
+		if err != nil {
+			return err
+		}
+		b.rateSteps = steps
+		if b.Rate > 0 || b.Count > 0 || b.Duration > 0 {
+			return errors.New("--rate-ramp cannot be combined with --rate-limit, --number or --duration options")
+		}
+	}
+
+	if b.DropRate < 0 || b.DropRate > 1 {
+		return errors.New("--drop-rate has to be between 0 and 1")
+	}
+	if b.InjectDelay != "" {
+		delay, jitter, err := parseInjectDelay(b.InjectDelay)
+		if err != nil {
+			return err
+		}
+		b.injectDelay, b.injectJitter = delay, jitter
+	}
+
+	if b.Transfer != "" {
+		if b.useDoH || b.useQuic {
+			return errors.New("zone transfers are supported only for plain DNS and DoT")
+		}
+		b.Types = []string{strings.ToUpper(b.Transfer)}
+		if !b.DOT {
+			b.TCP = true
+		}
+	}
+
+	if b.ReplaySpeed < 0 {
+		return errors.New("--speed has to be positive")
+	}
+	if b.ReplaySpeed > 0 {
+		b.RespectTiming = true
+	} else {
+		b.ReplaySpeed = 1
+	}
+	if b.Pcap != "" && b.Dnstap != "" {
+		return errors.New("--pcap and --dnstap is specified at once, only one can be used")
+	}
+	if len(b.PtrFromCIDR) > 0 && b.captureSource() != "" {
+		return errors.New("--ptr-from-cidr cannot be combined with --pcap or --dnstap options")
+	}
+	if b.PtrShuffle && len(b.PtrFromCIDR) == 0 {
+		return errors.New("--ptr-shuffle requires --ptr-from-cidr option")
+	}
+	if b.ZoneFile != "" && (len(b.PtrFromCIDR) > 0 || b.captureSource() != "") {
+		return errors.New("--zone-file cannot be combined with --ptr-from-cidr, --pcap or --dnstap options")
+	}
+	if len(b.ZoneTypes) > 0 && b.ZoneFile == "" {
+		return errors.New("--zone-types requires --zone-file option")
+	}
+	b.zoneTypes, b.zoneTypeWeights = nil, nil
+	if len(b.ZoneTypes) > 0 {
+		zoneTypes, zoneTypeWeights, err := parseTypeMix(b.ZoneTypes)
+		if err != nil {
+			return fmt.Errorf("invalid --zone-types: %v", err)
+		}
+		if len(zoneTypeWeights) > 0 && b.Zipf > 0 {
+			return errors.New("--zipf cannot be combined with weighted --zone-types")
+		}
+		b.zoneTypes, b.zoneTypeWeights = zoneTypes, zoneTypeWeights
+	}
+	if b.RespectTiming && b.captureSource() == "" {
+		return errors.New("--respect-timing and --speed options require queries captured in a pcap file or dnstap")
+	}
+	if b.RespectTiming && (b.Rate > 0 || b.RateLimitWorker > 0 || b.RateRamp != "" || b.Zipf > 0) {
+		return errors.New("--respect-timing cannot be combined with --rate-limit, --rate-limit-worker, --rate-ramp or --zipf options")
+	}
+
+	if b.Zipf < 0 {
+		return errors.New("--zipf exponent has to be positive")
+	}
+
+	if b.OpenLoop && (b.Rate == 0 || b.RateLimitWorker > 0 || b.RateRamp != "") {
+		return errors.New("--open-loop requires --rate-limit and cannot be combined with --rate-limit-worker or --rate-ramp options")
+	}
+
+	if b.AutoConcurrency {
+		if b.Rate == 0 || b.OpenLoop || b.RateRamp != "" {
+			return errors.New("--auto-concurrency requires --rate-limit and cannot be combined with --open-loop or --rate-ramp options")
+		}
+		if b.Concurrency <= 1 {
+			b.Concurrency = defaultAutoConcurrencyMax
+		}
+	}
+
+	if b.Burst < 0 {
+		return errors.New("--burst cannot be negative")
+	}
+	if b.Burst > 0 {
+		if b.BurstInterval <= 0 {
+			return errors.New("--burst-interval has to be positive")
+		}
+		if b.Rate > 0 || b.RateLimitWorker > 0 || b.RateRamp != "" || b.OpenLoop || b.RespectTiming ||
+			(b.RateDistribution != "" && b.RateDistribution != constantDistribution) {
+			return errors.New("--burst cannot be combined with --rate-limit, --rate-limit-worker, --rate-ramp, --rate-distribution, --open-loop or --respect-timing options")
+		}
+	}
+
+	if b.HistLog != "" && b.HistLogInterval <= 0 {
+		return errors.New("--hist-log-interval has to be positive")
+	}
+
+	if b.DatapointSampleRate < 0 || b.DatapointSampleRate > 1 {
+		return errors.New("--datapoint-sample-rate has to be between 0 and 1")
+	}
+
+	if b.MaxDatapoints < 0 {
+		return errors.New("--max-datapoints cannot be negative")
+	}
+
+	if b.NoDatapoints && (b.PlotDir != "" || b.SeriesInterval > 0 || b.RateRamp != "") {
+		return errors.New("--no-datapoints cannot be combined with --plot, --series-interval or --rate-ramp options")
+	}
+
+	if b.SeriesCsv != "" && b.SeriesInterval <= 0 {
+		return errors.New("--series-csv requires --series-interval option")
+	}
+
+	if b.FreshConnection {
+		if b.QperConn > 1 {
+			return errors.New("--fresh-connection-per-query and --query-per-conn is specified at once, only one can be used")
+		}
+		b.QperConn = 1
+	}
+
+	if b.MaxInflight > 1 {
+		if !b.TCP && !b.DOT || b.useDoH || b.useQuic || b.dnscrypt != nil {
+			return errors.New("--max-inflight is supported only for plain DNS over TCP and DoT")
+		}
+		if b.QperConn > 0 || b.Transfer != "" || b.TSIG != "" {
+			return errors.New("--max-inflight cannot be combined with --query-per-conn, --fresh-connection-per-query, --transfer or --tsig options")
+		}
+	}
+
+	if b.EDNSTCPKeepalive && (!b.TCP && !b.DOT || b.useDoH || b.useQuic || b.dnscrypt != nil) {
+		return errors.New("--edns-tcp-keepalive is supported only for plain DNS over TCP and DoT")
+	}
+	if b.IdleTimeout < 0 {
+		return errors.New("--idle-timeout cannot be negative")
+	}
+
+	if b.DoHConnections < 0 {
+		return errors.New("--doh-connections cannot be negative")
+	}
+	if b.DoHConnections > 0 && (!b.useDoH || b.QperConn > 0) {
+		return errors.New("--doh-connections is supported only for DoH and cannot be combined with --query-per-conn or --fresh-connection-per-query options")
+	}
+
+	if b.Batch > 1 {
+		if b.TCP || b.DOT || b.useDoH || b.useQuic || b.dnscrypt != nil {
+			return errors.New("--batch is supported only for plain DNS over UDP")
+		}
+		if b.QperConn > 0 || b.TSIG != "" {
+			return errors.New("--batch cannot be combined with --query-per-conn, --fresh-connection-per-query or --tsig options")
+		}
+	}
+
+	if b.UDPRetry < 0 {
+		return errors.New("--udp-retry cannot be negative")
+	}
+	if b.UDPRetry > 0 {
+		if b.UDPRetryInterval <= 0 {
+			return errors.New("--udp-retry-interval has to be positive")
+		}
+		if b.TCP || b.DOT || b.useDoH || b.useQuic || b.dnscrypt != nil || b.proxy != nil || b.Transfer != "" {
+			return errors.New("--udp-retry is supported only for plain DNS over UDP")
+		}
+		if b.Batch > 1 || b.TSIG != "" || b.SimulateStub {
+			return errors.New("--udp-retry cannot be combined with --batch, --tsig or --simulate-stub options")
+		}
+	}
+
+	if b.IPv4 && b.IPv6 {
+		return errors.New("-4 and -6 is specified at once, only one can be used")
+	}
+
+	source, err := parseSource(b.SourceIP, b.Interface, b.SourceIPRange)
+	if err != nil {
+		return err
+	}
+	if source != nil && (b.useQuic || (b.useDoH && b.DohProtocol == "3")) {
+		return errors.New("source address binding is not supported for DoQ and DoH/3")
+	}
+	b.source = source
+
+	if b.SimulateStub {
+		if b.TCP || b.DOT || b.useDoH || b.useQuic || b.DNSCrypt != "" || b.Transfer != "" {
+			return errors.New("--simulate-stub is supported only for plain DNS over UDP")
+		}
+		if b.Retries > 0 {
+			return errors.New("--simulate-stub retransmits the queries on its own, --retries cannot be used with it")
+		}
+		b.RetryTruncated = true
+	}
+
+	b.relativeNames = nil
+	if b.SearchExpansion {
+		if len(b.SearchDomains) == 0 {
+			return errors.New("search domain expansion requires search domains")
+		}
+		if b.SimulateStub || b.FollowCNAMEs || b.DualAAAA || b.DiffServer != "" || b.Transfer != "" || b.UpdateZone != "" || b.captureSource() != "" {
+			return errors.New("search domain expansion cannot be combined with --simulate-stub, --follow-cnames, --dual-aaaa, --diff, --transfer, --update-zone options or captured queries")
+		}
+		b.relativeNames = make(map[string]bool)
+	}
+
+	if b.DualAAAA {
+		if b.useDoH || b.useQuic || b.dnscrypt != nil || b.DiffServer != "" || b.Transfer != "" || b.MaxInflight > 1 || b.Batch > 1 || len(b.FailoverServers) > 0 {
+			return errors.New("--dual-aaaa is supported only for plain DNS and DoT and cannot be combined with --diff, --transfer, --max-inflight, --batch or --servers options")
+		}
+		if len(b.Types) > 1 || (len(b.Types) == 1 && !strings.EqualFold(b.Types[0], "A")) {
+			return errors.New("--dual-aaaa sends AAAA queries along with A queries, only A query type can be specified")
+		}
+	}
+
+	if b.FollowCNAMEs && b.Transfer != "" {
+		return errors.New("--follow-cnames cannot be used with zone transfers")
+	}
+
+	if b.RetryTruncated && (b.TCP || b.DOT || b.useDoH || b.useQuic) {
+		return errors.New("--retry-truncated is supported only for plain DNS over UDP")
+	}
+
+	if b.UI && b.Progress > 0 {
+		return errors.New("--ui and --progress is specified at once, only one can be used")
+	}
+
+	b.tsig = nil
+	if b.TSIG != "" {
+		if b.useDoH || b.useQuic {
+			return errors.New("TSIG is supported only for plain DNS and DoT")
+		}
+		key, err := parseTsigKey(b.TSIG)
+		if err != nil {
+			return err
+		}
+		b.tsig = key
+	}
+
+	b.ecs = nil
+	for _, e := range b.ECS {
+		subnet, err := parseECS(e)
+		if err != nil {
+			return err
+		}
+		b.ecs = append(b.ecs, subnet)
+	}
+
+	b.ednsOpts = nil
+	for _, o := range b.EdnsOpt {
+		opt, err := parseEdnsOpt(o)
+		if err != nil {
+			return err
+		}
+		b.ednsOpts = append(b.ednsOpts, opt)
+	}
+	if b.NSID {
+		b.ednsOpts = append(b.ednsOpts, nsidOption)
+	}
+	b.pushers = nil
+	for _, p := range b.Push {
+		pusher, err := parsePushURL(p)
+		if err != nil {
+			return err
+		}
+		b.pushers = append(b.pushers, pusher)
+	}
+
+	if b.StatsInterval < 0 {
+		return errors.New("--stats-interval cannot be negative")
+	}
+	if b.StatsInterval > 0 && b.StatsFile == "" {
+		return errors.New("--stats-interval requires --stats-file to be specified")
+	}
+
+	if b.PadTo < 0 || b.PadTo > maxMessageSize {
+		return fmt.Errorf("--pad-to has to be between 0 and %d", maxMessageSize)
+	}
+	if b.PadTo > 0 && (b.TSIG != "" || b.dnscrypt != nil) {
+		return errors.New("--pad-to cannot be combined with --tsig option or DNSCrypt, which pads the queries on its own")
+	}
+
+	b.ednsSweep = nil
+	if b.EDNSSweep != "" {
+		if b.UDPSize > 0 || b.DiffServer != "" {
+			return errors.New("--edns-sweep cannot be combined with --edns0 or --diff options")
+		}
+		if b.TCP || b.DOT || b.useDoH || b.useQuic || b.dnscrypt != nil || b.Transfer != "" {
+			return errors.New("--edns-sweep is supported only for plain DNS over UDP")
+		}
+		sizes, err := parseEDNSSweep(b.EDNSSweep)
+		if err != nil {
+			return err
+		}
+		b.ednsSweep = sizes
+	}
+
+	b.percentiles = nil
+	if b.Percentiles != "" {
+		percentiles, err := parsePercentiles(b.Percentiles)
+		if err != nil {
+			return err
+		}
+		b.percentiles = percentiles
+	}
+	if b.HistBuckets < 0 {
+		return errors.New("--hist-buckets cannot be negative")
+	}
+
+	if b.NetworkRTT != "" {
+		if b.NetworkRTT != tcpRTT && b.NetworkRTT != icmpRTT {
+			return fmt.Errorf("--network-rtt must be %s or %s", tcpRTT, icmpRTT)
+		}
+		if b.proxy != nil {
+			return errors.New("--network-rtt measures the direct path to the server and cannot be used with --proxy")
+		}
+		if b.NetworkRTTSamples < 0 {
+			return errors.New("--network-rtt-samples cannot be negative")
+		}
+		if b.NetworkRTTSamples == 0 {
+			b.NetworkRTTSamples = defaultRTTSamples
+		}
+	}
+
+	if b.RRLInterval < 0 {
+		return errors.New("--rrl-interval cannot be negative")
+	}
+
+	if b.FirstByte {
+		if !b.TCP && !b.DOT && !b.useDoH {
+			return errors.New("--first-byte is supported only for DNS over TCP, DoT and DoH")
+		}
+		if b.MaxInflight > 1 || b.dnscrypt != nil || b.Transfer != "" {
+			return errors.New("--first-byte cannot be combined with --max-inflight, --dnscrypt or --transfer options")
+		}
+	}
+
+	if b.Responders {
+		if b.TCP || b.DOT || b.useDoH || b.useQuic || b.dnscrypt != nil || b.proxy != nil || b.Transfer != "" {
+			return errors.New("--responders is supported only for plain DNS over UDP")
+		}
+		if b.Batch > 1 {
+			return errors.New("--responders cannot be combined with --batch option")
+		}
+	}
+
+	if b.MalformedRate < 0 || b.MalformedRate > 1 {
+		return errors.New("--malformed-rate has to be between 0 and 1")
+	}
+	if b.MalformedRate > 0 {
+		if b.DOT || b.useDoH || b.useQuic || b.dnscrypt != nil || b.proxy != nil || b.Transfer != "" {
+			return errors.New("--malformed-rate is supported only for plain DNS over UDP and TCP")
+		}
+		if b.Batch > 1 {
+			return errors.New("--malformed-rate cannot be combined with --batch option")
+		}
+	}
+
+	if b.NXDomainRatio < 0 || b.NXDomainRatio > 1 {
+		return errors.New("--nxdomain-ratio has to be between 0 and 1")
+	}
+	if b.NXDomainRatio > 0 {
+		if b.Transfer != "" {
+			return errors.New("--nxdomain-ratio cannot be used with --transfer")
+		}
+		// latency of the negative answers is reported by response code
+		b.Rcodes = true
+	}
+
+	if b.OtelSampleRate < 0 || b.OtelSampleRate > 1 {
+		return errors.New("--otel-sample-rate has to be between 0 and 1")
+	}
+	b.otelURL = ""
+	if b.OtelEndpoint != "" {
+		if b.otelURL, err = otelTracesURL(b.OtelEndpoint); err != nil {
+			return err
+		}
+	}
+
+	if b.ChaosProbeInterval < 0 {
+		return errors.New("--chaos-probe-interval cannot be negative")
+	}
+
+	expect, err := parseExpectations(b.ExpectRcode, b.ExpectAnswerCount, b.ExpectIP)
+	if err != nil {
+		return err
+	}
+	b.expect = expect
+
+	if err := b.loadHooks(); err != nil {
+		return err
+	}
+
+	if b.Count == 0 && b.Duration == 0 && b.Total == 0 && b.RateRamp == "" {
+		b.Count = 1
+	}
+
+	if b.Warmup > 0 && b.WarmupQueries > 0 {
+		return errors.New("--warmup and --warmup-queries is specified at once, only one can be used")
+	}
+
+	if b.Duration > 0 && b.Count > 0 {
+		return errors.New("--number and --duration is specified at once, only one can be used")
+	}
+
+	if len(b.Types) == 0 {
+		b.Types = []string{"A"}
+	}
+	qtypes, typeWeights, err := parseTypeMix(b.Types)
+	if err != nil {
+		return err
+	}
+	b.qtypes, b.typeMix = qtypes, newQuerySampler(typeWeights)
+
+	if b.Class != "" {
+		qclass, ok := dns.StringToClass[strings.ToUpper(b.Class)]
+		if !ok {
+			return fmt.Errorf("unknown query class '%s'", b.Class)
+		}
+		b.qclass = qclass
+	}
+	if b.Opcode != "" {
+		opcode, ok := dns.StringToOpcode[strings.ToUpper(b.Opcode)]
+		if !ok || opcode == dns.OpcodeIQuery {
+			return fmt.Errorf("unknown opcode '%s'", b.Opcode)
+		}
+		if opcode != dns.OpcodeQuery && (b.Transfer != "" || b.SimulateStub || b.FollowCNAMEs || b.DNSSEC) {
+			return errors.New("--opcode other than QUERY cannot be combined with --transfer, --simulate-stub, --follow-cnames or --dnssec options")
+		}
+		b.opcode = opcode
+	}
+
+	if b.UpdateZone != "" {
+		b.UpdateZone = dns.Fqdn(b.UpdateZone)
+		if b.UpdateOp == "" {
+			b.UpdateOp = updateAdd
+		}
+		if b.UpdateOp != updateAdd && b.UpdateOp != updateDelete && b.UpdateOp != updateReplace {
+			return fmt.Errorf("unknown update operation '%s', supported operations: add, delete, replace", b.UpdateOp)
+		}
+		if b.opcode != dns.OpcodeQuery && b.opcode != dns.OpcodeUpdate {
+			return errors.New("--update-zone cannot be combined with --opcode other than UPDATE")
+		}
+		if b.Transfer != "" || b.SimulateStub || b.FollowCNAMEs || b.DNSSEC || b.DualAAAA || b.DiffServer != "" || b.NXDomainRatio > 0 || b.captureSource() != "" {
+			return errors.New("--update-zone cannot be combined with --transfer, --simulate-stub, --follow-cnames, --dnssec, --dual-aaaa, --diff, --nxdomain-ratio, --pcap or --dnstap options")
+		}
+		if err := checkUpdateQuestions(nil, b.qtypes, b.UpdateZone); err != nil {
+			return err
+		}
+	}
+
+	if b.HistMax == 0 {
+		b.HistMax = b.RequestTimeout
+	}
+	return nil
+}
+
+// Run executes benchmark, if benchmark is unable to start the error is returned, otherwise array of results from parallel benchmark goroutines is returned.
+func (b *Benchmark) Run(ctx context.Context) ([]*ResultStats, error) {
+	if err := b.normalize(); err != nil {
+		return nil, err
+	}
+
+	color.NoColor = !b.Color
+
+	// runCtx is the context of the whole run bounded by MaxRuntime, its deadline bounds the I/O of the queries in flight as well,
+	// parent is kept to tell apart exceeding MaxRuntime from cancelling the run
+	parent, runCtx := ctx, ctx
+	if b.MaxRuntime > 0 {
+		timeoutCtx, cancel := context.WithTimeout(ctx, b.MaxRuntime)
+		defer cancel()
+		runCtx = timeoutCtx
+	}
+	ctx = runCtx
+
+	questions, weights, err := b.prepareQuestions()
+	if err != nil {
+		return nil, err
+	}
+	if b.UpdateZone != "" {
+		if err := checkUpdateQuestions(questions, nil, b.UpdateZone); err != nil {
+			return nil, err
+		}
+	}
+	sampler := newQuerySampler(weights)
+	b.log.info("benchmark started", "server", b.Server, "concurrency", b.Concurrency, "questions", len(questions))
+
+	if !b.Silent && !b.JSON {
+		if source := b.captureSource(); source != "" && b.RespectTiming {
+			fmt.Printf("Replaying %s queries from %s respecting original timing at %s speed\n", highlightStr(len(questions)), highlightStr(source), highlightStr(fmt.Sprintf("%gx", b.ReplaySpeed)))
+		} else if source != "" {
+			fmt.Printf("Using %s queries from %s\n", highlightStr(len(questions)), highlightStr(source))
+		} else {
+			fmt.Printf("Using %s hostnames\n", highlightStr(countHostnames(questions)))
+		}
+	}
+
+	network := "udp"
+	if b.TCP {
+		network = "tcp"
+	}
+	if b.DOT {
+		network = "tls"
+	}
+
+	var dohTransport http.RoundTripper
+	if b.useDoH {
+		dohTransport, network = b.getDoHTransport()
+	}
+
+	if b.useQuic {
+		network = "quic"
+	}
+
+	var dnscryptCert *dnscryptCert
+	if b.dnscrypt != nil {
+		dnscryptCert, err = b.fetchDNSCryptCert(ctx)
+		if err != nil {
+			return nil, err
+		}
+		network = "dnscrypt/" + network
+	}
+
+	limits := ""
+	var globalLimit ratelimit.Limiter
+	if b.Rate > 0 {
+		globalLimit = b.globalLimiter(b.Rate)
+		if b.RateLimitWorker == 0 {
+			limits = fmt.Sprintf("(limited to %s QPS overall)", highlightStr(b.Rate))
+		} else {
+			limits = fmt.Sprintf("(limited to %s QPS overall and %s QPS per concurrent worker)", highlightStr(b.Rate), highlightStr(b.RateLimitWorker))
+		}
+	}
+	if b.Rate == 0 && b.RateLimitWorker > 0 {
+		limits = fmt.Sprintf("(limited to %s QPS per concurrent worker)", highlightStr(b.RateLimitWorker))
+	}
+	var ramp *rampLimiter
+	if b.RateRamp != "" {
+		var step int64
+		ramp = newRampLimiter(b.rateSteps, func(rate int) ratelimit.Limiter {
+			step++
+			return b.newLimiter(rate, b.randSeed(rampStream, step))
+		})
+		globalLimit = ramp
+		limits = fmt.Sprintf("(ramping %s)", ramp)
+	}
+	if b.OpenLoop {
+		limits = fmt.Sprintf("(open-loop at %s QPS overall)", highlightStr(b.Rate))
+	}
+	var burst *burstLimiter
+	if b.Burst > 0 {
+		burst = newBurstLimiter(b.Burst, b.BurstInterval)
+		limits = fmt.Sprintf("(in bursts of %s queries every %s)", highlightStr(b.Burst), highlightStr(b.BurstInterval))
+	}
+	if limits != "" && b.RateDistribution != "" && b.RateDistribution != constantDistribution {
+		limits = fmt.Sprintf("%s with %s arrivals", limits, highlightStr(b.RateDistribution))
+	}
+
+	if !b.Silent && !b.JSON {
+		if b.AutoConcurrency {
+			fmt.Printf("Benchmarking %s via %s with up to %s concurrent requests adjusted automatically %s\n", highlightStr(b.Server), highlightStr(network),
+				highlightStr(b.Concurrency), limits)
+		} else {
+			fmt.Printf("Benchmarking %s via %s with %s concurrent requests %s\n", highlightStr(b.Server), highlightStr(network), highlightStr(b.Concurrency), limits)
+		}
+		if b.DiffServer != "" {
+			fmt.Printf("Comparing answers with %s\n", highlightStr(b.DiffServer))
+		}
+		if b.UpdateZone != "" {
+			fmt.Printf("Updating records of zone %s by %s operations\n", highlightStr(b.UpdateZone), highlightStr(b.UpdateOp))
+		}
+		if b.SearchExpansion {
+			fmt.Printf("Expanding names with fewer than %s dots with search domains %s\n", highlightStr(b.Ndots), highlightStr(strings.Join(b.SearchDomains, ", ")))
+		} else if len(b.SearchDomains) > 0 {
+			fmt.Printf("Qualifying names with fewer than %s dots with search domain %s\n", highlightStr(b.Ndots), highlightStr(b.SearchDomains[0]))
+		}
+		if len(b.FailoverServers) > 0 {
+			fmt.Printf("Failing over to %s after %s without answer\n", highlightStr(strings.Join(b.FailoverServers[1:], ", ")), highlightStr(b.FailoverTimeout))
+		}
+		if b.Batch > 1 {
+			fmt.Printf("Sharing UDP sockets by groups of %s workers sending queries in batches\n", highlightStr(b.Batch))
+		}
+		if b.MaxInflight > 1 {
+			fmt.Printf("Pipelining up to %s queries in flight per connection\n", highlightStr(b.MaxInflight))
+		}
+		if b.NetworkRTT != "" {
+			fmt.Printf("Measuring network RTT baseline by %s with %s samples\n", highlightStr(b.NetworkRTT), highlightStr(b.NetworkRTTSamples))
+		}
+	}
+
+	var rtt *RTTStats
+	if b.NetworkRTT != "" {
+		rtt, err = b.measureRTT(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var promMetrics *metrics
+	if b.Prometheus != "" {
+		promMetrics = newMetrics()
+		stop, err := startMetricsServer(b.Prometheus, promMetrics, b.log)
+		if err != nil {
+			return nil, err
+		}
+		defer stop()
+		if !b.Silent && !b.JSON {
+			fmt.Printf("Exposing Prometheus metrics on %s\n", highlightStr(b.Prometheus))
+		}
+	}
+
+	if b.otelURL != "" {
+		b.otel = b.startOtelExporter()
+		defer func() {
+			b.otel.close()
+			b.otel = nil
+		}()
+	}
+
+	var live *liveStats
+	if b.UI || b.Progress > 0 || b.ProgressFunc != nil || len(b.pushers) > 0 || b.AutoConcurrency || b.StatsInterval > 0 {
+		live = newLiveStats(b)
+	}
+	var autoWindow *liveWindow
+	if b.AutoConcurrency {
+		autoWindow = live.addWindow(b)
+	}
+	if len(b.pushers) > 0 {
+		defer b.startPush(live)()
+	}
+	if b.StatsInterval > 0 {
+		stop, err := b.startStatsInterval(live)
+		if err != nil {
+			return nil, err
+		}
+		defer stop()
+	}
+	if b.UI || b.Progress > 0 || b.ProgressFunc != nil {
+		interval, render := b.Progress, func(s liveSnapshot) { renderProgress(os.Stderr, s) }
+		if b.ProgressFunc != nil {
+			if interval <= 0 {
+				interval = time.Second
+			}
+			render = func(s liveSnapshot) { b.ProgressFunc(s.export()) }
+		}
+		if b.UI {
+			interval, render = time.Second, func(s liveSnapshot) { renderDashboard(os.Stderr, b.Server, s) }
+		}
+		stopLive := make(chan struct{})
+		liveDone := make(chan struct{})
+		go func() {
+			live.run(live.windows[0], stopLive, interval, render)
+			close(liveDone)
+		}()
+		defer func() {
+			close(stopLive)
+			<-liveDone
+		}()
+	}
+
+	var validator *dnssecValidator
+	if b.DNSSEC {
+		validator = newDNSSECValidator(b.RequestTimeout, b.useQuic)
+	}
+
+	var diffLog *diffLogger
+	if b.DiffLog != "" {
+		f, err := os.Create(b.DiffLog)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file for logging disagreements due to '%v'", err)
+		}
+		defer f.Close()
+		diffLog = &diffLogger{w: f}
+	}
+
+	var failureLog *failureLogger
+	if b.FailureLog != "" {
+		f, err := os.Create(b.FailureLog)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file for logging failures due to '%v'", err)
+		}
+		defer f.Close()
+		failureLog = newFailureLogger(f)
+	}
+
+	// pipelined clients are shared by the in-flight queries of each worker, each in-flight query is issued by its own goroutine
+	inflight := uint32(1)
+	var pipes []*pipelinedClient
+	if b.MaxInflight > 1 {
+		inflight = b.MaxInflight
+		pipes = make([]*pipelinedClient, b.Concurrency)
+		for i := range pipes {
+			pipes[i] = b.newPipelinedClient(b.Server)
+			defer pipes[i].close()
+		}
+	}
+
+	// batched clients are shared by the groups of workers
+	var batches []*batchedClient
+	if b.Batch > 1 {
+		batches = make([]*batchedClient, (b.Concurrency+b.Batch-1)/b.Batch)
+		for i := range batches {
+			batches[i] = b.newBatchedClient(b.Server, int(b.Batch))
+			defer batches[i].close()
+		}
+	}
+
+	stats := make([]*ResultStats, b.Concurrency*inflight)
+
+	// number of queries sent by all workers, used for capping the total number of queries
+	var sent atomic.Int64
+
+	b.seen = nil
+	if b.CacheModel {
+		b.seen = newQuestionSet()
+	}
+
+	// sequence used for expanding {seq} placeholders in query names
+	var seq atomic.Int64
+	// number of queries taken by the workers, used for counting the queries not sent due to MaxRuntime when Total is set
+	var taken atomic.Int64
+	templates := make([]bool, len(questions))
+	for i, q := range questions {
+		templates[i] = isTemplate(q.Name)
+	}
+
+	warmup := b.Warmup > 0 || b.WarmupQueries > 0
+	warmupCtx := ctx
+	if b.Warmup > 0 {
+		timeoutCtx, cancel := context.WithTimeout(ctx, b.Warmup)
+		warmupCtx = timeoutCtx
+		defer cancel()
+	}
+	if warmup && !b.Silent && !b.JSON {
+		if b.Warmup > 0 {
+			fmt.Printf("Warming up for %s\n", highlightStr(b.Warmup))
+		} else {
+			fmt.Printf("Warming up with %s queries per concurrent worker\n", highlightStr(b.WarmupQueries))
+		}
+	}
+
+	// the measured part of the benchmark starts once all workers finish the warm-up,
+	// measureCtx is set before the measure channel is closed.
+	measureCtx := ctx
+	measure := make(chan struct{})
+	// schedule of intended send times in open-loop mode, it is set before the measure channel is closed.
+	var schedule *openLoopSchedule
+	// schedule of captured queries replayed with the original timing, it is set before the measure channel is closed.
+	var replay *replaySchedule
+	// controller of the number of active workers, it is set before the measure channel is closed.
+	var auto *autoConcurrency
+	var warmupWg sync.WaitGroup
+
+	var wg sync.WaitGroup
+	var w uint32
+	for w = 0; w < b.Concurrency*inflight; w++ {
+		st := b.newResultStats(int64(w))
+		stats[w] = st
+		if b.DiffServer != "" {
+			st.Diff = b.newResultStats(int64(w))
+		}
+
+		var batch *batchedClient
+		if batches != nil {
+			batch = batches[w/b.Batch]
+		}
+
+		limit := globalLimit
+
+		var pipe *pipelinedClient
+		if pipes != nil {
+			pipe = pipes[w/inflight]
+			if w%inflight != 0 {
+				// the connection is shared by the in-flight queries of the worker
+				st.Connections.Workers = 0
+			}
+		}
+
+		var err error
+		wg.Add(1)
+		warmupWg.Add(1)
+		go func(worker uint32, st *ResultStats) {
+			log := b.log.with("worker", worker)
+			log.debug("worker started")
+			// number of queries planned by Count, which are not taken by the worker yet
+			var remaining int64
+			if b.Count > 0 && !b.RespectTiming {
+				remaining = b.Count * int64(len(questions))
+			}
+			defer func() {
+				if remaining > 0 && maxRuntimeExceeded(runCtx, parent) {
+					st.Counters.Unsent = remaining
+				}
+				log.debug("worker finished", "queries", st.Counters.Total, "errors", st.Counters.IOError)
+				wg.Done()
+			}()
+
+			// create a new lock free rand source for this goroutine, each worker uses different seed,
+			// so that randomized query names differ across workers
+			rando := rand.New(rand.NewSource(b.randSeed(workerStream, int64(worker))))
+
+			var workerLimit ratelimit.Limiter
+			if b.RateLimitWorker > 0 {
+				workerLimit = b.newLimiter(b.RateLimitWorker, b.randSeed(workerLimitStream, int64(worker)))
+			}
+
+			var i int64
+
+			// for DoH we want to share the transport, for plain DNS, DoT and DoQ we don't, each worker is using its own connection
+			var query, diffQuery queryFunc
+
+			if b.useDoH && b.QperConn > 0 {
+				query = b.getDoHConnClient(st)
+				if b.DiffServer != "" {
+					diffQuery = b.getDoHConnClient(st.Diff)
+				}
+			} else if b.useDoH {
+				query = b.getDoHClient(dohTransport, st)
+				if b.DiffServer != "" {
+					diffQuery = b.getDoHClient(dohTransport, st.Diff)
+				}
+			}
+
+			if b.useQuic {
+				query = b.getDoQClient(b.Server)
+				if b.DiffServer != "" {
+					diffQuery = b.getDoQClient(b.DiffServer)
+				}
+			}
+
+			// query function for plain DNS and DoT, which is dialing new connection when needed,
+			// connections closed by the server while idle are counted to the stats and the query is resent over a new connection
+			dnsQuery := func(server string, dnsClient *dns.Client, st *ResultStats) queryFunc {
+				var co *dns.Conn
+				var keepalive connKeepalive
+				stream := strings.HasPrefix(dnsClient.Net, "tcp")
+				// UDP responses are read by exchangeUDP, so that the responses not matching the query are counted, TSIG signed responses
+				// have to be verified by the client though
+				udp := !stream && b.tsig == nil
+				var ids udpIDs
+				var fb *firstByteConn
+				return func(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+					var err error
+					st.responder = ""
+					st.firstByte = readTimer{}
+					if co != nil && b.QperConn > 0 && i%b.QperConn == 0 {
+						co.Close()
+						co = nil
+					}
+					if co != nil && stream && keepalive.expired(b.IdleTimeout, time.Now()) {
+						log.debug("closing idle connection", "remote", co.RemoteAddr(), "idle", time.Since(keepalive.lastUsed))
+						st.Counters.IdleCloses++
+						co.Close()
+						co = nil
+					}
+
+					for {
+						reused := co != nil
+						if co == nil && b.proxy != nil {
+							co, err = b.dialProxyDNS(ctx, server)
+							if err != nil {
+								return nil, err
+							}
+						}
+						if co == nil {
+							dialStart := time.Now()
+							if b.Responders && !stream {
+								co, err = b.dialResponders(dnsClient.Net, server)
+							} else {
+								dnsClient.Dialer = b.dialer(dnsClient.Net)
+								co, err = dnsClient.Dial(server)
+							}
+							if err != nil {
+								return nil, err
+							}
+							observeConnection(ctx, co.RemoteAddr(), time.Since(dialStart))
+						}
+						if !reused {
+							keepalive = connKeepalive{}
+							ids = udpIDs{}
+							fb = nil
+							if b.FirstByte && stream {
+								fb = &firstByteConn{Conn: co.Conn}
+								co.Conn = fb
+							}
+						}
+						if b.otel != nil {
+							traceConn(co)
+							setConnTrace(co, queryTraceFrom(ctx))
+						}
+						var r *dns.Msg
+						switch {
+						case udp:
+							r, err = b.exchangeUDP(ctx, co, msg, &ids, st)
+						case b.tsig != nil:
+							// dns.Conn chains the MAC of the previous message into the TSIG of the next one like in zone transfers,
+							// each signed query is an independent transaction though, so it is sent over fresh dns.Conn wrapping the connection
+							r, _, err = dnsClient.ExchangeWithConnContext(ctx, msg, &dns.Conn{Conn: co.Conn})
+						default:
+							r, _, err = dnsClient.ExchangeWithConnContext(ctx, msg, co)
+						}
+						if r != nil && isTsigError(err) {
+							keepalive.used(r)
+							return r, err
+						}
+						if err != nil && udp && isTimeout(err) {
+							// the socket is kept open, so that the late responses to the query are counted once they arrive
+							log.debug("query timed out", "err", err)
+							return nil, err
+						}
+						if err != nil {
+							co.Close()
+							co = nil
+							if stream && reused && closedByServer(err) && ctx.Err() == nil {
+								log.debug("connection closed by server, resending query over new connection", "err", err)
+								st.Counters.ServerCloses++
+								continue
+							}
+							log.debug("closing connection after failed query", "err", err)
+							return nil, err
+						}
+						keepalive.used(r)
+						st.setResponder(co)
+						if fb != nil {
+							st.firstByte = fb.timer
+						}
+						return r, nil
+					}
+				}
+			}
+
+			if b.dnscrypt != nil {
+				query = b.getDNSCryptClient(dnscryptCert)
+			}
+
+			if pipe != nil {
+				query = pipe.query
+			}
+
+			if batch != nil {
+				query = batch.query
+			}
+
+			if b.Transfer != "" {
+				query = b.getTransferClient(st)
+				if b.DiffServer != "" {
+					diffQuery = b.getTransferClient(st.Diff)
+				}
+			}
+
+			dnsClient := b.getDNSClient()
+			tcpClient := *dnsClient
+			tcpClient.Net = b.network("tcp")
+			if query == nil && len(b.FailoverServers) > 0 {
+				queries := make([]queryFunc, len(b.FailoverServers))
+				for i, server := range b.FailoverServers {
+					queries[i] = dnsQuery(server, dnsClient, st)
+					if b.RetryTruncated {
+						queries[i] = withTCPRetry(queries[i], dnsQuery(server, &tcpClient, st), st)
+					}
+				}
+				query = withFailover(queries, b.FailoverServers, b.FailoverTimeout, st)
+			}
+			if query == nil {
+				query = dnsQuery(b.Server, dnsClient, st)
+				if b.RetryTruncated {
+					query = withTCPRetry(query, dnsQuery(b.Server, &tcpClient, st), st)
+				}
+				if b.DualAAAA {
+					// AAAA queries are sent concurrently, so they use their own clients, connections and counters
+					aaaaClient, aaaaTCPClient := *dnsClient, tcpClient
+					aaaaStats := &ResultStats{Counters: &Counters{}}
+					aaaaQuery := dnsQuery(b.Server, &aaaaClient, aaaaStats)
+					if b.RetryTruncated {
+						aaaaQuery = withTCPRetry(aaaaQuery, dnsQuery(b.Server, &aaaaTCPClient, aaaaStats), aaaaStats)
+					}
+					query = withDualAAAA(query, aaaaQuery, st, aaaaStats)
+				}
+			}
+			if diffQuery == nil && b.DiffServer != "" {
+				diffQuery = dnsQuery(b.DiffServer, dnsClient, st.Diff)
+				if b.RetryTruncated {
+					diffQuery = withTCPRetry(diffQuery, dnsQuery(b.DiffServer, &tcpClient, st.Diff), st.Diff)
+				}
+			}
+
+			if b.DropRate > 0 || b.injectDelay > 0 {
+				// nolint:gosec
+				query = b.withFaults(query, rand.New(rand.NewSource(b.randSeed(faultStream, int64(worker)))), st)
+			}
+
+			if warmup {
+				b.warmup(warmupCtx, query, questions, sampler, templates, rando, &seq, limit, workerLimit)
+				log.debug("worker warmed up")
+				// retries of the warm-up queries are not part of the results
+				st.Counters.TCPRetries = 0
+				st.Counters.InjectedDelays, st.Counters.InjectedDrops = 0, 0
+				if st.Diff != nil {
+					st.Diff.Counters.TCPRetries = 0
+				}
+			}
+			warmupWg.Done()
+			<-measure
+			ctx := measureCtx
+
+			// message reused for all the queries of the worker
+			msg := new(dns.Msg)
+			var cookies *cookieJar
+			if b.Cookies {
+				cookies = newCookieJar(rando)
+			}
+			transport := b.transport()
+			var stub *stubResolver
+			if b.SimulateStub {
+				stub = newStubResolver(rando)
+			} else if b.FollowCNAMEs {
+				stub = &stubResolver{rando: rando}
+			}
+			var probe *chaosProbe
+			if b.ChaosProbeInterval > 0 {
+				probe = &chaosProbe{interval: b.ChaosProbeInterval, zeroID: b.useQuic}
+			}
+			var sweep *ednsSweep
+			if b.ednsSweep != nil {
+				sweep = newEDNSSweep(b.ednsSweep, worker)
+			}
+			// cachedStreak counts the consecutive queries answered from the stub cache, which are returned to the budget of Total, the returns are
+			// bounded by the number of the questions, so that the run ends even when all the names are cached
+			var cachedStreak int
+			for i = 0; i < b.Count || b.Duration != 0 || b.Total != 0 || ramp != nil; i++ {
+				for qi, q := range questions {
+					if ctx.Err() != nil {
+						return
+					}
+					if sampler != nil {
+						qi = sampler.pick(rando)
+						q = questions[qi]
+					}
+					if replay != nil {
+						var at time.Time
+						var ok bool
+						if qi, at, ok = replay.take(); !ok {
+							return
+						}
+						q = questions[qi]
+						if err := waitUntil(ctx, at); err != nil {
+							return
+						}
+					}
+					if rando.Float64() > b.Probability {
+						remaining--
+						continue
+					}
+					if auto != nil {
+						if err := auto.wait(ctx, worker); err != nil {
+							return
+						}
+					}
+					if b.Total > 0 && sent.Add(1) > b.Total {
+						// the query over the budget is not sent, so that the queries returned to the budget by the other workers can still be sent
+						sent.Add(-1)
+						return
+					}
+					var intended time.Time
+					if schedule != nil {
+						intended = schedule.take()
+						if err := waitUntil(ctx, intended); err != nil {
+							return
+						}
+					} else if limit != nil {
+						if err := checkLimit(ctx, limit); err != nil {
+							return
+						}
+					} else if burst != nil {
+						tag, err := burst.wait(ctx)
+						if err != nil {
+							return
+						}
+						st.burst = tag
+					}
+					if workerLimit != nil {
+						if err := checkLimit(ctx, workerLimit); err != nil {
+							return
+						}
+					}
+					remaining--
+					if b.Total > 0 {
+						taken.Add(1)
+					}
+					var resp *dns.Msg
+
+					// the identity is probed before the query is sent, so that the probe is not included in the latency of the query
+					probed := probe.identity(ctx, query, b.Server)
+					udpSize := b.UDPSize
+					if sweep != nil {
+						udpSize = sweep.size()
+						st.ednsSize = int(udpSize)
+					}
+					m := b.newMsg(msg, q, b.capturedOpt(qi), templates[qi], rando, &seq, cookies, udpSize)
+					if b.MalformedRate > 0 && rando.Float64() < b.MalformedRate {
+						b.sendMalformed(ctx, m, rando, st)
+						continue
+					}
+					if b.NXDomainRatio > 0 && rando.Float64() < b.NXDomainRatio {
+						m.Question[0].Name = nonexistentName(rando, m.Question[0].Name)
+						st.Counters.NXDomainQueries++
+					}
+					if stub != nil {
+						if _, ok := stub.cached(m.Question[0], time.Now()); ok {
+							st.Counters.StubCacheHits++
+							if b.Total > 0 && cachedStreak < len(questions) {
+								cachedStreak++
+								sent.Add(-1)
+								taken.Add(-1)
+							}
+							continue
+						}
+						cachedStreak = 0
+					}
+
+					st.Counters.Total++
+					first := b.seen.firstSeen(m.Question[0])
+					if first {
+						st.Counters.UniqueQuestions++
+					}
+
+					start := time.Now()
+					if schedule != nil {
+						// latency is measured from the intended send time, including the time the query waited for a free worker
+						start = intended
+					}
+					if ramp != nil {
+						st.step = ramp.stepAt(start)
+					}
+
+					live.sent()
+					var attempts int
+					queryCtx, timer := withConnTimer(ctx)
+					var qt *queryTrace
+					if b.otel.sampled(rando.Float64()) {
+						qt = &queryTrace{}
+						queryCtx = withQueryTrace(queryCtx, qt)
+					}
+					if stub != nil {
+						resp, attempts, err = stub.resolve(queryCtx, b, query, m, st, log)
+					} else if b.relativeNames[q.Name] {
+						resp, attempts, err = b.search(queryCtx, query, m, rando, st, log)
+					} else {
+						resp, attempts, err = b.exchange(queryCtx, query, m, log)
+					}
+					if !ended(ctx) {
+						st.recordDual()
+						st.recordBurst(err)
+						st.recordRRL(start, resp, err)
+						st.recordEDNSSize(resp, err)
+					}
+					conns := timer.take()
+					if qt != nil {
+						b.otel.record(qt, start, time.Now(), transport, m, resp, attempts, conns, err)
+					}
+					setup := st.recordConnections(conns)
+					if log.enabled(levelDebug) {
+						for _, c := range conns {
+							log.debug("connection established", "remote", c.remote, "family", c.family, "setup", c.setup)
+						}
+					}
+					if st.Attempts != nil {
+						st.Attempts[attempts]++
+						st.Counters.Retries += int64(attempts - 1)
+					}
+					if err != nil {
+						if resp != nil && isTsigError(err) {
+							st.Counters.TSIGError++
+							promMetrics.observeRequest()
+							live.done(nil, 0, err)
+							continue
+						}
+						if ended(ctx) {
+							// the benchmark ended (duration elapsed or cancelled) while the query was in flight,
+							// so the query is not considered to be sent at all
+							st.Counters.Total--
+							if maxRuntimeExceeded(runCtx, parent) {
+								st.Counters.Unanswered++
+							}
+							live.cancelled()
+							return
+						}
+						if errors.Is(err, errInjectedDrop) {
+							// the query failed due to the simulated packet loss, so it is not a real error
+							st.Counters.InjectedFailures++
+							promMetrics.observeRequest()
+							live.done(nil, 0, err)
+							continue
+						}
+						st.recordError(err)
+						if log.enabled(levelDebug) {
+							log.debug("query failed", "question", questionString(m), "attempts", attempts, "category", errorCategory(err), "err", err)
+						}
+						if (b.SeriesInterval > 0 || b.PlotDir != "") && st.sampled() {
+							st.addErrorTime(start)
+						}
+						promMetrics.observeRequest()
+						promMetrics.observeError()
+						live.done(nil, 0, err)
+						continue
+					}
+
+					if b.tsig != nil && resp.IsTsig() == nil {
+						// unsigned response to signed query
+						st.Counters.TSIGError++
+						promMetrics.observeRequest()
+						live.done(nil, 0, errors.New("unsigned response"))
+						failureLog.log(b.Server, []string{"tsig error"}, m, resp)
+						continue
+					}
+					var before Counters
+					if failureLog != nil {
+						before = *st.Counters
+					}
+					// connection setup is recorded separately, so it is excluded from the latency of the query
+					duration := time.Since(start) - setup
+					st.record(m, resp, start, duration)
+					st.recordIdentity(resp, probed, duration)
+					st.recordCacheModel(first, duration)
+					st.recordSizes(m, resp, transport)
+					st.recordKeepalive(resp)
+					promMetrics.observeRequest()
+					promMetrics.observeResponse(resp, duration)
+					live.done(resp, duration, nil)
+					if cookies != nil {
+						cookies.update(resp, st.Counters)
+					}
+					if b.DNS0x20 && !sameCase(m, resp) {
+						st.Counters.CaseMismatch++
+					}
+					b.expect.evaluateResponse(resp, st.Counters)
+					if b.hooks.response != nil {
+						if err := b.hooks.response(m, resp); err != nil {
+							st.recordHookError(err)
+						}
+					}
+					if validator != nil {
+						if err := validator.validate(ctx, query, b.Server, resp); err != nil {
+							st.Counters.ValidationFailed++
+						} else {
+							st.Counters.ValidationOK++
+						}
+					}
+					if failureLog != nil {
+						failureLog.log(b.Server, b.failureReasons(&before, st.Counters, resp), m, resp)
+					}
+
+					if diffQuery != nil {
+						b.compare(ctx, diffQuery, m, resp, st, diffLog)
+					}
+				}
+			}
+		}(w, st)
+	}
+
+	warmupStart := time.Now()
+	warmupWg.Wait()
+	b.warmupDuration = 0
+	if warmup {
+		b.warmupDuration = time.Since(warmupStart)
+		b.log.info("warm-up finished", "duration", b.warmupDuration)
+	}
+
+	if b.Duration != 0 {
+		timeoutCtx, cancel := context.WithTimeout(ctx, b.Duration)
+		measureCtx = timeoutCtx
+		defer cancel()
+	}
+	if ramp != nil {
+		timeoutCtx, cancel := context.WithTimeout(ctx, ramp.duration())
+		measureCtx = timeoutCtx
+		defer cancel()
+		ramp.begin()
+	}
+	if b.OpenLoop {
+		schedule = b.newOpenLoopSchedule(time.Now(), b.randSeed(scheduleStream, 0))
+	}
+	if b.RespectTiming {
+		replay = b.newReplaySchedule(time.Now())
+	}
+	stopAuto := make(chan struct{})
+	b.concurrencySteps = nil
+	if b.AutoConcurrency {
+		auto = newAutoConcurrency(b.Rate, b.Concurrency*inflight)
+		go auto.run(live, autoWindow, stopAuto)
+	}
+	usage := startUsageMonitor(usageInterval)
+	close(measure)
+	measureStart := time.Now()
+
+	wg.Wait()
+	b.usage = usage.finish()
+	close(stopAuto)
+	if auto != nil {
+		b.concurrencySteps = auto.history()
+	}
+
+	// the results collected until the context was cancelled are still reported, but marked as partial
+	b.interrupted = ended(parent)
+	b.maxRuntimeExceeded = maxRuntimeExceeded(runCtx, parent)
+	if b.maxRuntimeExceeded && b.Total > 0 && len(stats) > 0 {
+		stats[0].Counters.Unsent = b.Total - taken.Load()
+	}
+	if rtt != nil && len(stats) > 0 {
+		stats[0].RTT = rtt
+	}
+	b.log.info("benchmark finished", "duration", time.Since(measureStart), "interrupted", b.interrupted, "maxRuntimeExceeded", b.maxRuntimeExceeded)
+
+	return stats, nil
+}
+
+// packBuffers are buffers reused for packing of the queries written directly to the connections.
+var packBuffers = sync.Pool{New: func() any {
+	buf := make([]byte, 4096)
+	return &buf
+}}
+
+// newMsg creates query for the question, opt is EDNS0 record of the captured query replayed by the message, if any.
+// cookies are DNS Cookies of the worker, if enabled.
+// newMsg fills the message reused by the worker with the query, so that a new message is not allocated for each query.
+func (b *Benchmark) newMsg(m *dns.Msg, q dns.Question, opt *dns.OPT, template bool, rando *rand.Rand, seq *atomic.Int64, cookies *cookieJar, udpSize uint16) *dns.Msg {
+	question, extra := m.Question[:0], m.Extra[:0]
+	*m = dns.Msg{}
+	m.RecursionDesired = b.Recurse
+
+	if q.Qtype == dns.TypeNone && b.typeMix != nil {
+		q.Qtype = b.qtypes[b.typeMix.pick(rando)]
+	}
+	m.Question = append(question, q)
+	m.Extra = extra
+	if b.qclass != 0 {
+		m.Question[0].Qclass = b.qclass
+	}
+	m.Opcode = b.opcode
+	if b.opcode == dns.OpcodeNotify {
+		m.Authoritative = true
+	}
+	if template {
+		m.Question[0].Name = expandTemplate(rando, seq, q.Name)
+	}
+	if b.DNS0x20 {
+		m.Question[0].Name = randomizeCase(rando, m.Question[0].Name)
+	}
+
+	if b.useQuic {
+		m.Id = 0
+	} else {
+		m.Id = uint16(rando.Uint32())
+	}
+
+	if b.UpdateZone != "" {
+		b.setUpdate(m, rando)
+	}
+
+	if q.Qtype == dns.TypeIXFR {
+		m.Ns = []dns.RR{&dns.SOA{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeSOA, Class: dns.ClassINET}, Ns: ".", Mbox: ".", Serial: b.IxfrSerial}}
+	}
+
+	if opt != nil {
+		m.Extra = append(m.Extra, dns.Copy(opt))
+	}
+
+	if udpSize > 0 {
+		setUDPSize(m, udpSize)
+	}
+
+	if b.DNSSEC {
+		if o := m.IsEdns0(); o != nil {
+			o.SetDo()
+		} else {
+			m.SetEdns0(4096, true)
+		}
+	}
+
+	if len(b.ednsOpts) > 0 {
+		addEdnsOpts(m, b.ednsOpts)
+	}
+
+	if b.EDNSTCPKeepalive {
+		addTCPKeepalive(m)
+	}
+
+	if cookies != nil {
+		cookies.add(m)
+	}
+
+	if len(b.ecs) > 0 {
+		addECS(m, rando, b.ecs)
+	}
+
+	if b.hooks.request != nil {
+		b.hooks.request(m)
+	}
+
+	if b.PadTo > 0 {
+		padMsg(m, b.PadTo)
+	}
+
+	// TSIG has to be the last record of the message
+	if b.tsig != nil {
+		m.SetTsig(b.tsig.name, b.tsig.algorithm, 300, time.Now().Unix())
+	}
+	return m
+}
+
+// warmup issues the questions until the warm-up duration elapses or the number of warm-up queries is sent, the responses are not recorded.
+func (b *Benchmark) warmup(ctx context.Context, query queryFunc, questions []dns.Question, sampler *querySampler, templates []bool,
+	rando *rand.Rand, seq *atomic.Int64, limit, workerLimit ratelimit.Limiter,
+) {
+	var sent int64
+	msg := new(dns.Msg)
+	for {
+		for qi, q := range questions {
+			if ctx.Err() != nil || (b.WarmupQueries > 0 && sent >= b.WarmupQueries) {
+				return
+			}
+			if sampler != nil {
+				qi = sampler.pick(rando)
+				q = questions[qi]
+			}
+			if limit != nil {
+				if err := checkLimit(ctx, limit); err != nil {
+					return
+				}
+			}
+			if workerLimit != nil {
+				if err := checkLimit(ctx, workerLimit); err != nil {
+					return
+				}
+			}
+			sent++
+
+			m := b.newMsg(msg, q, b.capturedOpt(qi), templates[qi], rando, seq, nil, b.UDPSize)
+			// the questions of the warm-up are cached by the server, so they are not first-seen in the measurement
+			b.seen.firstSeen(m.Question[0])
+			reqTimeoutCtx, cancel := context.WithTimeout(ctx, b.RequestTimeout)
+			query(reqTimeoutCtx, b.Server, m)
+			cancel()
+		}
+	}
+}
+
+// exchange sends the query and retries it according to the retry policy if it fails due to I/O error or timeout,
+// the response, the number of attempts and the error of the last attempt are returned.
+func (b *Benchmark) exchange(ctx context.Context, query queryFunc, m *dns.Msg, log *logger) (*dns.Msg, int, error) {
+	backoff := b.RetryBackoff
+	retries, timeout := b.Retries, b.RequestTimeout
+	if b.SimulateStub {
+		// stub resolvers retransmit immediately after the timeout, which doubles with each attempt
+		retries, timeout, backoff = stubRetransmits, stubRetransmitTimeout, 0
+	}
+	for attempt := 1; ; attempt++ {
+		reqTimeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+		resp, err := query(reqTimeoutCtx, b.Server, m)
+		cancel()
+		if err == nil || attempt > retries || (resp != nil && isTsigError(err)) || ended(ctx) {
+			return resp, attempt, err
+		}
+		if b.SimulateStub {
+			timeout *= 2
+		}
+		if log.enabled(levelDebug) {
+			log.debug("retrying query", "question", questionString(m), "attempt", attempt, "backoff", backoff, "err", err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, attempt, err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// newResultStats creates the results of the worker, the index of the worker distinguishes the seeds of the datapoint samplers of the workers.
+func (b *Benchmark) newResultStats(worker int64) *ResultStats {
+	st := &ResultStats{Hist: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)}
+	if b.Rcodes {
+		st.Codes = make(map[int]int64)
+		st.RcodeHists = make(map[int]*hdrhistogram.Histogram)
+	}
+	st.Qtypes = make(map[string]int64)
+	st.QtypeHists = make(map[string]*hdrhistogram.Histogram)
+	st.FlagHists = make(map[string]*hdrhistogram.Histogram)
+	if b.FollowCNAMEs || b.SimulateStub {
+		st.CNAMEDepthHists = make(map[int]*hdrhistogram.Histogram)
+	}
+	if b.DualAAAA {
+		st.Dual = newDualStats(st.Hist)
+	}
+	if b.SearchExpansion {
+		st.Search = newSearchStats(st.Hist)
+	}
+	if b.Burst > 0 {
+		st.BurstHists = make(map[int]*hdrhistogram.Histogram)
+		st.Bursts = make(map[int64]*BurstStats)
+	}
+	if b.MalformedRate > 0 {
+		st.Malformed = make(map[string]map[string]int64)
+	}
+	if len(b.FailoverServers) > 0 {
+		st.FailoverHists = make(map[string]*hdrhistogram.Histogram)
+		st.FailoverUnanswered = make(map[string]int64)
+	}
+	st.TTLs = make(map[string]*TTLStats)
+	st.Sizes = make(map[string]*SizeStats)
+	st.QtypeSizes = make(map[string]*SizeStats)
+	if b.HistLog != "" {
+		st.IntervalHists = make(map[int64]*hdrhistogram.Histogram)
+		st.histInterval = b.HistLogInterval
+	}
+	st.Counters = &Counters{}
+	st.noDatapoints = b.NoDatapoints
+	st.sampleRate = 1
+	if b.DatapointSampleRate > 0 && b.DatapointSampleRate < 1 {
+		st.sampleRate = b.DatapointSampleRate
+		st.DatapointWeight = 1 / b.DatapointSampleRate
+	}
+	if b.MaxDatapoints > 0 {
+		// each worker stores its share of the datapoints, at least one
+		st.maxDatapoints = b.MaxDatapoints / int(b.Concurrency)
+		if st.maxDatapoints == 0 {
+			st.maxDatapoints = 1
+		}
+	}
+	if st.sampleRate < 1 || st.maxDatapoints > 0 {
+		// nolint:gosec
+		st.sampler = rand.New(rand.NewSource(b.randSeed(samplerStream, worker)))
+	}
+	if b.useDoH {
+		st.DoHProtocols = make(map[string]int64)
+		st.DoH = newDoHStats()
+		if b.DohMethod == "both" {
+			st.DoHMethodHists = make(map[string]*hdrhistogram.Histogram)
+		}
+	}
+	if b.Retries > 0 || b.SimulateStub {
+		st.Attempts = make(map[int]int64)
+	}
+	if b.NSID || b.ChaosProbeInterval > 0 {
+		st.IdentityHists = make(map[string]*hdrhistogram.Histogram)
+	}
+	if b.Responders {
+		st.ResponderHists = make(map[string]*hdrhistogram.Histogram)
+	}
+	if b.CacheModel {
+		st.CacheHists = make(map[string]*hdrhistogram.Histogram)
+	}
+	if b.FirstByte {
+		st.FirstByteHists = make(map[string]*hdrhistogram.Histogram)
+	}
+	if b.ednsSweep != nil {
+		st.EDNSSizes = make(map[int]*EDNSSizeStats)
+		st.EDNSSizeHists = make(map[int]*hdrhistogram.Histogram)
+	}
+	if b.RRLInterval > 0 {
+		st.RRL = make(map[int64]*RRLStats)
+		st.rrlInterval = b.RRLInterval
+	}
+	st.Families = make(map[string]int64)
+	if b.EDNSTCPKeepalive {
+		st.KeepaliveTimeouts = make(map[string]int64)
+	}
+	st.ErrorCategories = make(map[string]int64)
+	if b.hooks.response != nil {
+		st.HookErrors = make(map[string]int64)
+	}
+	if (b.TCP || b.DOT || b.useDoH) && b.Transfer == "" {
+		st.Connections = &ConnectionStats{Setup: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre), Workers: 1}
+		if b.proxy != nil {
+			st.Connections.ProxySetup = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+		}
+	}
+	if b.Transfer != "" {
+		st.Transfer = &TransferStats{FirstRecord: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)}
+	}
+	return st
+}
+
+func (b *Benchmark) addPortIfMissing(server string) string {
+	if b.useDoH {
+		// both HTTPS and HTTP are using default ports 443 and 80 if no other port is specified
+		return server
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		if b.DOT {
+			// https://www.rfc-editor.org/rfc/rfc7858
+			return net.JoinHostPort(server, "853")
+		}
+		if b.useQuic {
+			// https://datatracker.ietf.org/doc/rfc9250
+			return net.JoinHostPort(server, "853")
+		}
+		return net.JoinHostPort(server, "53")
+	}
+	if ip := net.ParseIP(server); ip != nil {
+		return net.JoinHostPort(ip.String(), "53")
+	}
+	return server
+}
+
+func isHTTPUrl(s string) (ok bool, network string) {
+	if strings.HasPrefix(s, "http://") {
+		return true, "http"
+	}
+	if strings.HasPrefix(s, "https://") {
+		return true, "https"
+	}
+	return false, ""
+}
+
+func (b *Benchmark) getDoHTransport() (http.RoundTripper, string) {
+	_, network := isHTTPUrl(b.Server)
+	var tr http.RoundTripper
+	switch b.DohProtocol {
+	case "3":
+		network += "/3"
+		tr = b.dohPool(func() http.RoundTripper {
+			return &http3.RoundTripper{TLSClientConfig: b.tlsConfig(""), Dial: b.dialQUIC}
+		})
+	case "2":
+		network += "/2"
+		tr = b.dohPool(func() http.RoundTripper {
+			// streams over the limit of the server wait for the connection instead of opening new connections
+			return &http2.Transport{TLSClientConfig: b.tlsConfig(""), DialTLSContext: b.dialTLS, StrictMaxConcurrentStreams: b.DoHConnections > 0}
+		})
+	case "1.1":
+		fallthrough
+	default:
+		network += "/1.1"
+		t := &http.Transport{
+			TLSClientConfig: b.tlsConfig(""),
+			IdleConnTimeout: b.IdleTimeout,
+			DialContext:     b.dialTCP,
+			DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return b.dialTLS(ctx, network, addr, b.tlsConfig(""))
+			},
+		}
+		if b.DoHConnections > 0 {
+			// HTTP/1.1 connection serves a single request at once, so all the connections are kept open
+			t.MaxConnsPerHost = b.DoHConnections
+			t.MaxIdleConnsPerHost = b.DoHConnections
+		}
+		tr = t
+	}
+
+	switch b.DohMethod {
+	case "get":
+		network += " (GET)"
+	case "both":
+		network += " (GET+POST)"
+	default:
+		network += " (POST)"
+	}
+	return tr, network
+}
+
+// getDoHClient returns DoH query function using the shared transport, negotiated HTTP protocols are recorded to the provided stats.
+func (b *Benchmark) getDoHClient(tr http.RoundTripper, st *ResultStats) queryFunc {
+	if b.dohHeader != nil {
+		tr = &headerSetter{inner: tr, header: b.dohHeader}
+	}
+	c := http.Client{Transport: &protocolRecorder{inner: tr, st: st}, Timeout: b.ReadTimeout}
+	dohClient := doh.NewClient(&c)
+	var get, post queryFunc = dohClient.SendViaGet, dohClient.SendViaPost
+	if b.dohTemplates != nil {
+		get = templateDoHQuery(&c, b.dohTemplates, http.MethodGet, get)
+		post = templateDoHQuery(&c, b.dohTemplates, http.MethodPost, post)
+	}
+
+	switch b.DohMethod {
+	case "get":
+		return get
+	case "both":
+		return alternateDoHMethods(get, post, st)
+	}
+	return post
+}
+
+// getDoHConnClient returns DoH query function using its own transport, which is replaced by a new one after QperConn queries,
+// so that new connections are established.
+func (b *Benchmark) getDoHConnClient(st *ResultStats) queryFunc {
+	var tr http.RoundTripper
+	var query queryFunc
+	var n int64
+	return func(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+		if query == nil || n%b.QperConn == 0 {
+			closeTransport(tr)
+			tr, _ = b.getDoHTransport()
+			query = b.getDoHClient(tr, st)
+		}
+		n++
+		return query(ctx, server, msg)
+	}
+}
+
+// dohPool returns DoH transport created by the function, when DoHConnections is set, the requests are spread over DoHConnections transports,
+// each of them keeping a single connection to the server.
+func (b *Benchmark) dohPool(newTransport func() http.RoundTripper) http.RoundTripper {
+	if b.DoHConnections == 0 {
+		return newTransport()
+	}
+	p := &transportPool{transports: make([]http.RoundTripper, b.DoHConnections)}
+	for i := range p.transports {
+		p.transports[i] = newTransport()
+	}
+	return p
+}
+
+// transportPool spreads the requests over the transports in round-robin fashion.
+type transportPool struct {
+	transports []http.RoundTripper
+	next       atomic.Uint64
+}
+
+func (p *transportPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	return p.transports[p.next.Add(1)%uint64(len(p.transports))].RoundTrip(req)
+}
+
+func (p *transportPool) CloseIdleConnections() {
+	for _, t := range p.transports {
+		closeTransport(t)
+	}
+}
+
+func closeTransport(tr http.RoundTripper) {
+	switch t := tr.(type) {
+	case io.Closer:
+		t.Close()
+	case interface{ CloseIdleConnections() }:
+		t.CloseIdleConnections()
+	}
+}
+
+func (b *Benchmark) getDoQClient(server string) queryFunc {
+	h, _, _ := net.SplitHostPort(server)
+	addr := server
+	if b.IPv4 || b.IPv6 {
+		// DoQ client resolves the server address itself, so the address of the selected family is resolved upfront
+		udpAddr, err := net.ResolveUDPAddr(b.network("udp"), server)
+		if err != nil {
+			return func(context.Context, string, *dns.Msg) (*dns.Msg, error) {
+				return nil, err
+			}
+		}
+		addr = udpAddr.String()
+	}
+	quicClient := doq.NewClient(addr, doq.Options{
+		TLSConfig:      b.tlsConfig(h),
+		ReadTimeout:    b.ReadTimeout,
+		WriteTimeout:   b.WriteTimeout,
+		ConnectTimeout: b.ConnectTimeout,
+	})
+	return func(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+		return quicClient.Send(ctx, msg)
+	}
+}
+
+func (b *Benchmark) getDNSClient() *dns.Client {
+	network := "udp"
+	if b.TCP {
+		network = "tcp"
+	} else if b.DOT {
+		network = "tcp-tls"
+	}
+
+	dnsClient := dns.Client{
+		Net:          b.network(network),
+		DialTimeout:  b.ConnectTimeout,
+		WriteTimeout: b.WriteTimeout,
+		ReadTimeout:  b.ReadTimeout,
+		Timeout:      b.RequestTimeout,
+	}
+	if b.DOT {
+		dnsClient.TLSConfig = b.tlsConfig("")
+	}
+	if b.tsig != nil {
+		dnsClient.TsigSecret = map[string]string{b.tsig.name: b.tsig.secret}
+	}
+	return &dnsClient
+}
+
+// prepareQuestions returns questions in the order in which they are issued by each benchmark worker.
+// When pcap file or dnstap is provided, the captured queries are used instead of the provided queries and types.
+// When queries are weighted or Zipf distributed, the weights of the questions are returned as well and the questions are drawn randomly according to them.
+func (b *Benchmark) prepareQuestions() ([]dns.Question, []float64, error) {
+	if source := b.captureSource(); source != "" {
+		var queries []capturedQuery
+		var err error
+		if b.Dnstap != "" {
+			queries, err = readDnstapQueries(b.Dnstap)
+		} else {
+			queries, err = readPcapQueries(b.Pcap)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(queries) == 0 {
+			return nil, nil, fmt.Errorf("no DNS queries found in '%s'", source)
+		}
+		b.capture = queries
+		questions := make([]dns.Question, len(queries))
+		for i, q := range queries {
+			questions[i] = q.question
+		}
+		if b.Zipf > 0 {
+			return questions, zipfWeights(len(questions), b.Zipf), nil
+		}
+		return questions, nil, nil
+	}
+
+	if len(b.PtrFromCIDR) > 0 {
+		questions, err := ptrQuestions(b.PtrFromCIDR, b.PtrShuffle, b.randSeed(ptrStream, 0))
+		if err != nil {
+			return nil, nil, err
+		}
+		if b.Zipf > 0 {
+			return questions, zipfWeights(len(questions), b.Zipf), nil
+		}
+		return questions, nil, nil
+	}
+
+	if b.ZoneFile != "" {
+		questions, weights, err := zoneQuestions(b.ZoneFile, b.zoneTypes, b.zoneTypeWeights)
+		if err != nil {
+			return nil, nil, err
+		}
+		if weights == nil && b.Zipf > 0 {
+			return questions, zipfWeights(len(questions), b.Zipf), nil
+		}
+		return questions, weights, nil
+	}
+
+	if len(b.Queries) == 0 {
+		return nil, nil, errors.New("no queries provided, either queries, --pcap, --dnstap, --ptr-from-cidr or --zone-file has to be specified")
+	}
+
+	entries, err := b.prepareNames()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var questions, typedQuestions []dns.Question
+	var names []string
+	var nameWeights, typedWeights []float64
+	weighted := false
+	for _, entry := range entries {
+		if strings.Contains(entry, ",") {
+			name, qtype, weight, err := parseWeightedQuery(entry)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := validateTemplate(name); err != nil {
+				return nil, nil, err
+			}
+			weighted = true
+			if qtype == "" {
+				names = append(names, b.qualify(name))
+				nameWeights = append(nameWeights, weight)
+				continue
+			}
+			qt, ok := dns.StringToType[strings.ToUpper(qtype)]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown query type '%s' of query '%s'", qtype, entry)
+			}
+			typedQuestions = append(typedQuestions, dns.Question{Name: b.qualify(name), Qtype: qt, Qclass: dns.ClassINET})
+			typedWeights = append(typedWeights, weight)
+			continue
+		}
+		fields := strings.Fields(entry)
+		switch len(fields) {
+		case 1:
+			if err := validateTemplate(fields[0]); err != nil {
+				return nil, nil, err
+			}
+			names = append(names, b.qualify(fields[0]))
+			nameWeights = append(nameWeights, 1)
+		case 2:
+			qt, ok := dns.StringToType[strings.ToUpper(fields[1])]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown query type '%s' of query '%s'", fields[1], entry)
+			}
+			if err := validateTemplate(fields[0]); err != nil {
+				return nil, nil, err
+			}
+			typedQuestions = append(typedQuestions, dns.Question{Name: b.qualify(fields[0]), Qtype: qt, Qclass: dns.ClassINET})
+			typedWeights = append(typedWeights, 1)
+		default:
+			return nil, nil, fmt.Errorf("invalid query '%s', expected hostname optionally followed by query type or hostname,type,weight", entry)
+		}
+	}
+	if weighted && b.Zipf > 0 {
+		return nil, nil, errors.New("--zipf cannot be combined with weighted queries")
+	}
+
+	var weights []float64
+	qtypes := b.qtypes
+	if b.typeMix != nil {
+		// the type of each query is drawn from the mix when the query is created, instead of duplicating the names for each type
+		qtypes = []uint16{dns.TypeNone}
+	}
+	for _, qt := range qtypes {
+		for i, name := range names {
+			questions = append(questions, dns.Question{Name: name, Qtype: qt, Qclass: dns.ClassINET})
+			weights = append(weights, nameWeights[i])
+		}
+	}
+	// queries with explicitly specified query type are not duplicated for each of the provided types
+	questions = append(questions, typedQuestions...)
+	weights = append(weights, typedWeights...)
+
+	switch {
+	case weighted:
+		return questions, weights, nil
+	case b.Zipf > 0:
+		return questions, zipfWeights(len(questions), b.Zipf), nil
+	default:
+		return questions, nil, nil
+	}
+}
+
+// prepareNames returns the provided queries, queries provided using HTTP resources are downloaded. Each query is a hostname optionally followed by a query type.
+func (b *Benchmark) prepareNames() ([]string, error) {
+	var queries []string
+	for _, q := range b.Queries {
+		if ok, _ := isHTTPUrl(q); ok {
+			resp, err := client.Get(q)
+			if err != nil {
+				return nil, fmt.Errorf("failed to download file '%s' with error '%v'", q, err)
+			}
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return nil, fmt.Errorf("failed to download file '%s' with status '%s'", q, resp.Status)
+			}
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if line := strings.TrimSpace(scanner.Text()); line != "" {
+					queries = append(queries, line)
+				}
+			}
+		} else if q = strings.TrimSpace(q); q != "" {
+			queries = append(queries, q)
+		}
+	}
+	return queries, nil
+}
+
+// countHostnames returns number of distinct hostnames used by the questions.
+func countHostnames(questions []dns.Question) int {
+	names := make(map[string]struct{})
+	for _, q := range questions {
+		names[q.Name] = struct{}{}
+	}
+	return len(names)
+}
+
+// randomizeCase randomizes case of letters in the name, see https://datatracker.ietf.org/doc/html/draft-vixie-dnsext-dns0x20-00.
+func randomizeCase(rando *rand.Rand, name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			if rando.Intn(2) == 0 {
+				b[i] = c | 0x20
+			} else {
+				b[i] = c &^ 0x20
+			}
+		}
+	}
+	return string(b)
+}
+
+// sameCase checks whether the response echoes the question name of the request in exactly the same case.
+func sameCase(req, resp *dns.Msg) bool {
+	return len(resp.Question) > 0 && resp.Question[0].Name == req.Question[0].Name
+}
+
+// ended checks whether the benchmark context is done, the deadline of the context is checked as well,
+// since the deadline of connection can be reached before the context is cancelled by its timer.
+func ended(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ctx.Err() != nil || (ok && !time.Now().Before(deadline))
+}
+
+// maxRuntimeExceeded returns whether the context of the run ended, because the run exceeded Benchmark.MaxRuntime,
+// parent is the context the run was started with.
+func maxRuntimeExceeded(ctx, parent context.Context) bool {
+	return ctx != parent && ended(ctx) && !ended(parent)
+}
+
+func checkLimit(ctx context.Context, limiter ratelimit.Limiter) error {
+	done := make(chan struct{})
+	go func() {
+		limiter.Take()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,1566 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	udp = "udp"
+	tcp = "tcp"
+
+	get  = "get"
+	post = "post"
+)
+
+func Test_do_classic_dns(t *testing.T) {
+	type args struct {
+		protocol string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			"benchmark - DNS over UDP",
+			args{
+				protocol: udp,
+			},
+		},
+		{
+			"benchmark - DNS over TCP",
+			args{
+				protocol: tcp,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(tt.args.protocol, func(w dns.ResponseWriter, r *dns.Msg) {
+				ret := new(dns.Msg)
+				ret.SetReply(r)
+				ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+				// wait some time to actually have some observable duration
+				time.Sleep(time.Millisecond * 500)
+
+				w.WriteMsg(ret)
+			})
+			defer s.Close()
+
+			bench := createBenchmark(s.Addr, tt.args.protocol == tcp, 1)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			assert.NoError(t, err, "expected no error from benchmark run")
+			assertResult(t, rs)
+		})
+	}
+}
+
+func Test_do_doh_post(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bd, err := io.ReadAll(r.Body)
+		if err != nil {
+			panic(err)
+		}
+
+		msg := dns.Msg{}
+		err = msg.Unpack(bd)
+		if err != nil {
+			panic(err)
+		}
+
+		msg.Answer = append(msg.Answer, A("example.org. IN A 127.0.0.1"))
+
+		pack, err := msg.Pack()
+		if err != nil {
+			panic(err)
+		}
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		_, err = w.Write(pack)
+		if err != nil {
+			panic(err)
+		}
+	}))
+	defer ts.Close()
+
+	bench := createBenchmark(ts.URL, true, 1)
+	bench.DohMethod = post
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	assertResult(t, rs)
+	for _, r := range rs {
+		assert.Equal(t, map[string]int64{"HTTP/1.1": 2}, r.DoHProtocols, "Run(ctx) DoH protocols")
+	}
+}
+
+func Test_do_doh_get(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		dnsQryParam := query.Get("dns")
+		bd, err := base64.RawURLEncoding.DecodeString(dnsQryParam)
+		if err != nil {
+			panic(err)
+		}
+
+		msg := dns.Msg{}
+		err = msg.Unpack(bd)
+		if err != nil {
+			panic(err)
+		}
+
+		msg.Answer = append(msg.Answer, A("example.org. IN A 127.0.0.1"))
+
+		pack, err := msg.Pack()
+		if err != nil {
+			panic(err)
+		}
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		_, err = w.Write(pack)
+		if err != nil {
+			panic(err)
+		}
+	}))
+	defer ts.Close()
+
+	bench := createBenchmark(ts.URL, true, 1)
+	bench.DohMethod = get
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	assertResult(t, rs)
+}
+
+func Test_do_doq(t *testing.T) {
+	s := NewDoQServer(func(r *dns.Msg) *dns.Msg {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		return ret
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.DOQ = true
+	bench.Insecure = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	assertResult(t, rs)
+}
+
+func Test_do_probability(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	assert.Len(t, rs, 2, "Run(ctx) rstats")
+	rs0 := rs[0]
+	rs1 := rs[1]
+	assert.Equal(t, int64(0), rs0.Counters.Total, "Run(ctx) total counter")
+	assert.Equal(t, int64(0), rs1.Counters.Total, "Run(ctx) total counter")
+}
+
+func Test_download_external_datasource_using_http(t *testing.T) {
+	s := NewServer("udp", func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`example.org`))
+		if err != nil {
+			panic(err)
+		}
+	}))
+	defer ts.Close()
+
+	bench := Benchmark{
+		Queries:        []string{ts.URL},
+		Types:          []string{"A", "AAAA"},
+		Server:         s.Addr,
+		TCP:            false,
+		Concurrency:    2,
+		Count:          1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	assertResult(t, rs)
+}
+
+func Test_download_external_datasource_with_query_types(t *testing.T) {
+	s := NewServer("udp", func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte("example.org\n\nexample.com mx\n"))
+		if err != nil {
+			panic(err)
+		}
+	}))
+	defer ts.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = []string{ts.URL, "example.net AAAA"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2, "Run(ctx) rstats")
+	for _, st := range rs {
+		assert.Equal(t, int64(4), st.Counters.Total, "Run(ctx) total counter")
+		assert.Equal(t, map[string]int64{"A": 1, "AAAA": 2, "MX": 1}, st.Qtypes)
+	}
+}
+
+func Test_do_classic_dns_weighted_queries(t *testing.T) {
+	var mu sync.Mutex
+	names := make(map[string]int)
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		names[r.Question[0].Name]++
+		mu.Unlock()
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Concurrency = 1
+	bench.Queries = []string{"example.org,A,9", "example.com,AAAA,1"}
+	bench.Count = 200
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 1)
+	assert.Equal(t, int64(400), rs[0].Counters.Total, "Run(ctx) total counter")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.InDelta(t, 360, names["example.org."], 40)
+	assert.InDelta(t, 40, names["example.com."], 40)
+	assert.Equal(t, int64(names["example.org."]), rs[0].Qtypes["A"])
+}
+
+func Test_do_classic_dns_weighted_query_types(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Concurrency = 1
+	bench.Queries = []string{"example.org", "example.com MX"}
+	bench.Types = []string{"A:7,AAAA:2", "HTTPS:1"}
+	bench.Count = 500
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 1)
+	// the names are not duplicated for each type, queries with explicit type keep their type
+	assert.Equal(t, int64(1000), rs[0].Counters.Total, "Run(ctx) total counter")
+	assert.Equal(t, int64(500), rs[0].Qtypes["MX"])
+	assert.InDelta(t, 350, rs[0].Qtypes["A"], 50)
+	assert.InDelta(t, 100, rs[0].Qtypes["AAAA"], 40)
+	assert.InDelta(t, 50, rs[0].Qtypes["HTTPS"], 30)
+	assert.Equal(t, int64(500), rs[0].Qtypes["A"]+rs[0].Qtypes["AAAA"]+rs[0].Qtypes["HTTPS"])
+}
+
+func Test_do_classic_dns_timeouts(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Qtype == dns.TypeAAAA {
+			// AAAA queries time out
+			return
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.RequestTimeout = 100 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2, "Run(ctx) rstats")
+	for _, r := range rs {
+		assert.Equal(t, int64(1), r.Counters.IOError, "Run(ctx) error counter")
+		assert.Equal(t, int64(1), r.Counters.Timeouts, "Run(ctx) timeouts counter")
+		assert.Equal(t, int64(1), r.ErrorCategories[errCategoryReadTimeout]+r.ErrorCategories[errCategoryRequestTimeout], "Run(ctx) timeout error categories")
+	}
+}
+
+func Test_do_classic_dns_connection_refused_not_timeout(t *testing.T) {
+	s := NewServer(tcp, func(w dns.ResponseWriter, r *dns.Msg) {})
+	// close right away to get refused connections
+	s.Close()
+
+	bench := createBenchmark(s.Addr, true, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2, "Run(ctx) rstats")
+	for _, r := range rs {
+		assert.Equal(t, int64(2), r.Counters.IOError, "Run(ctx) error counter")
+		assert.Zero(t, r.Counters.Timeouts, "Run(ctx) timeouts counter")
+		assert.Equal(t, map[string]int64{errCategoryDial: 2}, r.ErrorCategories, "Run(ctx) error categories")
+	}
+}
+
+func Test_zipf_and_weighted_queries_specified_at_once(t *testing.T) {
+	bench := createBenchmark("127.0.0.1", false, 1)
+	bench.Queries = []string{"example.org,A,9"}
+	bench.Zipf = 1
+
+	_, err := bench.Run(context.Background())
+
+	assert.Error(t, err)
+}
+
+func Test_invalid_query_type_in_queries(t *testing.T) {
+	bench := createBenchmark("127.0.0.1", false, 1)
+	bench.Queries = []string{"example.org FOO"}
+
+	_, err := bench.Run(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown query type 'FOO'")
+}
+
+func Test_download_external_datasource_using_http_not_available(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	}))
+	// close right away to get dead port
+	ts.Close()
+
+	bench := Benchmark{
+		Queries:        []string{ts.URL},
+		Types:          []string{"A", "AAAA"},
+		Server:         "8.8.8.8",
+		TCP:            false,
+		Concurrency:    2,
+		Count:          1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := bench.Run(ctx)
+
+	assert.Error(t, err, "expected error from benchmark run")
+}
+
+func Test_download_external_datasource_using_http_wrong_response(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	bench := Benchmark{
+		Queries:        []string{ts.URL},
+		Types:          []string{"A", "AAAA"},
+		Server:         "8.8.8.8",
+		TCP:            false,
+		Concurrency:    2,
+		Count:          1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := bench.Run(ctx)
+
+	assert.Error(t, err, "expected error from benchmark run")
+}
+
+func Test_do_classic_dns_with_duration(t *testing.T) {
+	s := NewServer("udp", func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		Concurrency:    1,
+		Duration:       2 * time.Second,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	assert.GreaterOrEqual(t, rs[0].Counters.Total, int64(1), "there should be atleast one execution")
+}
+
+func Test_do_classic_dns_with_duration_in_flight_queries(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// respond after the benchmark duration elapses
+		time.Sleep(2 * time.Second)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.Duration = 500 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	for _, r := range rs {
+		assert.Zero(t, r.Counters.Total, "Run(ctx) total counter")
+		assert.Zero(t, r.Counters.IOError, "Run(ctx) error counter")
+		assert.Empty(t, r.Errors, "Run(ctx) errors")
+	}
+}
+
+func Test_duration_and_count_specified_at_once(t *testing.T) {
+	bench := Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         "8.8.8.8",
+		TCP:            false,
+		Count:          1,
+		Duration:       time.Second,
+		Concurrency:    1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := bench.Run(ctx)
+
+	assert.Error(t, err, "expected error from benchmark run")
+}
+
+func Test_do_classic_dns_with_total(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.Total = 5
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	var total int64
+	for _, r := range rs {
+		total += r.Counters.Total
+	}
+	assert.Equal(t, int64(5), total, "Run(ctx) total counter")
+}
+
+func Test_total_and_count_specified_at_once(t *testing.T) {
+	bench := createBenchmark("8.8.8.8", false, 1)
+	bench.Total = 5
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := bench.Run(ctx)
+
+	assert.Error(t, err, "expected error from benchmark run")
+}
+
+func Test_do_classic_dns_max_runtime(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// respond after the max runtime elapses
+		time.Sleep(2 * time.Second)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 3
+	bench.MaxRuntime = 500 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	assert.True(t, bench.maxRuntimeExceeded)
+	assert.False(t, bench.interrupted)
+	for _, r := range rs {
+		assert.Zero(t, r.Counters.Total, "Run(ctx) total counter")
+		assert.Equal(t, int64(1), r.Counters.Unanswered, "Run(ctx) unanswered counter")
+		// 3 repetitions of 2 query types, the first query is unanswered
+		assert.Equal(t, int64(5), r.Counters.Unsent, "Run(ctx) unsent counter")
+	}
+}
+
+func Test_do_classic_dns_max_runtime_with_total(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		time.Sleep(2 * time.Second)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.Total = 10
+	bench.MaxRuntime = 500 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	merged := Merge(rs)
+	assert.Equal(t, int64(2), merged.Counters.Unanswered, "Run(ctx) unanswered counter")
+	assert.Equal(t, int64(8), merged.Counters.Unsent, "Run(ctx) unsent counter")
+}
+
+func Test_do_classic_dns_default_count(t *testing.T) {
+	s := NewServer("udp", func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		TCP:            false,
+		Concurrency:    1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	assert.Len(t, rs, 1, "Run(ctx) rstats")
+	assert.Equal(t, int64(1), rs[0].Counters.Total)
+	assert.Equal(t, int64(1), rs[0].Counters.Success)
+}
+
+func Test_do_classic_dns_pcap(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	pcap := writePcap(t,
+		udpPacket(t, 53, packQuery("example.org.", dns.TypeA)),
+		udpPacket(t, 53, packResponse("example.org.", dns.TypeA)),
+		udpPacket(t, 53, packQuery("example.org.", dns.TypeAAAA)),
+	)
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = nil
+	bench.Types = nil
+	bench.Pcap = pcap
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	assertResult(t, rs)
+}
+
+func Test_do_classic_dns_pcap_respect_timing(t *testing.T) {
+	var mu sync.Mutex
+	var received []*dns.Msg
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		received = append(received, r)
+		mu.Unlock()
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	pcap := writePcap(t,
+		udpPacket(t, 53, packQuery("example.org.", dns.TypeA)),
+		udpPacket(t, 53, packEdnsQuery("example.com.", dns.TypeAAAA)),
+	)
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = nil
+	bench.Types = nil
+	bench.Pcap = pcap
+	bench.Count = 2
+	bench.RespectTiming = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	res := Merge(rs)
+	// the capture is replayed twice by all workers together
+	assert.Equal(t, int64(4), res.Counters.Total, "Run(ctx) total counter")
+	assert.Equal(t, int64(4), res.Counters.Success, "Run(ctx) success counter")
+	assert.Equal(t, map[string]int64{"A": 2, "AAAA": 2}, res.Qtypes)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, r := range received {
+		if r.Question[0].Qtype == dns.TypeAAAA {
+			opt := r.IsEdns0()
+			require.NotNil(t, opt, "expected captured EDNS0 record to be replayed")
+			assert.Equal(t, uint16(1232), opt.UDPSize())
+			assert.True(t, opt.Do())
+		} else {
+			assert.Nil(t, r.IsEdns0())
+		}
+	}
+}
+
+func Test_do_classic_dns_dnstap(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	dnstap := writeDnstap(t,
+		dnstapMessage(dnstapClientQuery, time.Now(), packQuery("example.org.", dns.TypeA)),
+		dnstapMessage(dnstapClientQuery, time.Now(), packQuery("example.org.", dns.TypeAAAA)),
+	)
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = nil
+	bench.Types = nil
+	bench.Dnstap = dnstap
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2)
+	for _, r := range rs {
+		assert.Equal(t, int64(2), r.Counters.Total, "Run(ctx) total counter")
+		assert.Equal(t, map[string]int64{"A": 1, "AAAA": 1}, r.Qtypes)
+	}
+}
+
+func Test_respect_timing_without_pcap(t *testing.T) {
+	bench := createBenchmark("127.0.0.1", false, 1)
+	bench.RespectTiming = true
+
+	_, err := bench.Run(context.Background())
+
+	assert.Error(t, err)
+}
+
+func Test_do_classic_dns_diff(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	diffServer := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeA {
+			ret.Answer = append(ret.Answer, A("example.org. 30 IN A 127.0.0.1"))
+		} else {
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.2"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer diffServer.Close()
+
+	diffLog := filepath.Join(t.TempDir(), "diff.log")
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.DiffServer = diffServer.Addr
+	bench.DiffLog = diffLog
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	assert.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2, "Run(ctx) rstats")
+	for _, r := range rs {
+		assert.Equal(t, int64(1), r.Counters.Agreements, "Run(ctx) agreements counter")
+		assert.Equal(t, int64(1), r.Counters.Disagreements, "Run(ctx) disagreements counter")
+		if assert.NotNil(t, r.Diff, "Run(ctx) diff rstats") {
+			assert.Equal(t, int64(2), r.Diff.Counters.Total, "Run(ctx) diff total counter")
+			assert.Equal(t, int64(2), r.Diff.Counters.Success, "Run(ctx) diff success counter")
+		}
+	}
+
+	logged, err := os.ReadFile(diffLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(logged), ";; disagreement for example.org. AAAA")
+}
+
+func Test_diff_different_protocols(t *testing.T) {
+	bench := createBenchmark("8.8.8.8", false, 1)
+	bench.DiffServer = "https://1.1.1.1/dns-query"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := bench.Run(ctx)
+
+	assert.Error(t, err, "expected error from benchmark run")
+}
+
+func Test_do_classic_dns_0x20(t *testing.T) {
+	tests := []struct {
+		name             string
+		lowercase        bool
+		wantCaseMismatch int64
+	}{
+		{
+			name:             "server echoing case",
+			wantCaseMismatch: 0,
+		},
+		{
+			name:             "server not echoing case",
+			lowercase:        true,
+			wantCaseMismatch: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+				ret := new(dns.Msg)
+				ret.SetReply(r)
+				if tt.lowercase {
+					ret.Question[0].Name = strings.ToLower(ret.Question[0].Name)
+				}
+				w.WriteMsg(ret)
+			})
+			defer s.Close()
+
+			bench := createBenchmark(s.Addr, false, 1)
+			bench.Queries = []string{"averyveryverylongsubdomain.example.org"}
+			bench.DNS0x20 = true
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			assert.NoError(t, err, "expected no error from benchmark run")
+			for _, r := range rs {
+				assert.Equal(t, int64(2), r.Counters.Success, "Run(ctx) success counter")
+				assert.Equal(t, tt.wantCaseMismatch, r.Counters.CaseMismatch, "Run(ctx) case mismatch counter")
+			}
+		})
+	}
+}
+
+func Test_do_classic_dns_template(t *testing.T) {
+	var mu sync.Mutex
+	names := make(map[string]int)
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		names[r.Question[0].Name]++
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = []string{"{rand:10}.example.org", "q{seq}.example.org"}
+	bench.Count = 5
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, names, 40, "expected unique query name for each request")
+	for name := range names {
+		assert.Regexp(t, `^([a-z0-9]{10}|q([1-9]|1[0-9]|20))\.example\.org\.$`, name)
+	}
+}
+
+func Test_do_classic_dns_warmup_queries(t *testing.T) {
+	var mu sync.Mutex
+	received := 0
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		received++
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.WarmupQueries = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	assertResult(t, rs)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 10, received, "expected warm-up queries to be sent in addition to benchmark queries")
+	assert.NotZero(t, bench.warmupDuration)
+}
+
+func Test_do_classic_dns_warmup_with_duration(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+
+		time.Sleep(time.Millisecond * 100)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.Warmup = time.Second
+	bench.Duration = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	start := time.Now()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	assert.GreaterOrEqual(t, time.Since(start), 2*time.Second, "expected the duration to be measured after the warm-up")
+	for _, st := range rs {
+		assert.NotZero(t, st.Counters.Total)
+		assert.GreaterOrEqual(t, st.Timings[0].Start.Sub(start), time.Second, "expected no datapoints from the warm-up")
+	}
+}
+
+func Test_warmup_and_warmup_queries_specified_at_once(t *testing.T) {
+	bench := createBenchmark("127.0.0.1", false, 1)
+	bench.Warmup = time.Second
+	bench.WarmupQueries = 1
+
+	_, err := bench.Run(context.Background())
+
+	assert.Error(t, err)
+}
+
+func Test_do_classic_dns_rate_ramp(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.RateRamp = "10:1s,20:1s"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	start := time.Now()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	assert.GreaterOrEqual(t, time.Since(start), 2*time.Second, "expected the benchmark to run until the ramp ends")
+	steps := rampStepStats(bench.rateSteps, Merge(rs).Timings, 1)
+	require.Len(t, steps, 2)
+	assert.InDelta(t, 10, steps[0].Responses, 5)
+	assert.InDelta(t, 20, steps[1].Responses, 5)
+}
+
+func Test_rate_ramp_and_rate_limit_specified_at_once(t *testing.T) {
+	bench := createBenchmark("127.0.0.1", false, 1)
+	bench.Count = 0
+	bench.Rate = 10
+	bench.RateRamp = "10:1s"
+
+	_, err := bench.Run(context.Background())
+
+	assert.Error(t, err)
+}
+
+func Test_do_classic_dns_open_loop(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		time.Sleep(20 * time.Millisecond)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Concurrency = 1
+	bench.Types = []string{"A"}
+	bench.Count = 10
+	bench.Rate = 100
+	bench.OpenLoop = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	res := Merge(rs)
+	assert.Equal(t, int64(10), res.Counters.Total, "Run(ctx) total counter")
+	assert.Equal(t, int64(10), res.Counters.Success, "Run(ctx) success counter")
+	// queries are scheduled every 10ms, but the server responds in 20ms, so the queries wait for the worker
+	// and their latencies measured from the intended send times grow
+	assert.Greater(t, time.Duration(res.Hist.Max()), 80*time.Millisecond, "expected latencies to include time behind schedule")
+}
+
+func Test_open_loop_without_rate_limit(t *testing.T) {
+	bench := createBenchmark("127.0.0.1", false, 1)
+	bench.OpenLoop = true
+
+	_, err := bench.Run(context.Background())
+
+	assert.Error(t, err)
+}
+
+func Test_randomizeCase(t *testing.T) {
+	// nolint:gosec
+	rando := rand.New(rand.NewSource(1))
+	name := "averyveryverylongsubdomain.example-1.org."
+
+	randomized := randomizeCase(rando, name)
+
+	assert.NotEqual(t, name, randomized)
+	assert.True(t, strings.EqualFold(name, randomized))
+}
+
+func Test_no_queries_provided(t *testing.T) {
+	bench := createBenchmark("8.8.8.8", false, 1)
+	bench.Queries = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := bench.Run(ctx)
+
+	assert.Error(t, err, "expected error from benchmark run")
+}
+
+func assertResult(t *testing.T, rs []*ResultStats) {
+	if assert.Len(t, rs, 2, "Run(ctx) rstats") {
+		rs0 := rs[0]
+		rs1 := rs[1]
+		assertResultStats(t, rs0)
+		assertResultStats(t, rs1)
+		assertTimings(t, rs0)
+		assertTimings(t, rs1)
+	}
+}
+
+func assertResultStats(t *testing.T, rs *ResultStats) {
+	assert.NotNil(t, rs.Hist, "Run(ctx) rstats histogram")
+
+	if assert.NotNil(t, rs.Codes, "Run(ctx) rstats codes") {
+		assert.Equal(t, int64(2), rs.Codes[0], "Run(ctx) rstats codes NOERROR, state:"+fmt.Sprint(rs.Codes))
+	}
+
+	if assert.NotNil(t, rs.Qtypes, "Run(ctx) rstats qtypes") {
+		assert.Equal(t, int64(1), rs.Qtypes[dns.TypeToString[dns.TypeA]], "Run(ctx) rstats qtypes A, state:"+fmt.Sprint(rs.Codes))
+		assert.Equal(t, int64(1), rs.Qtypes[dns.TypeToString[dns.TypeAAAA]], "Run(ctx) rstats qtypes AAAA, state:"+fmt.Sprint(rs.Codes))
+	}
+
+	assert.Equal(t, int64(2), rs.Counters.Total, "Run(ctx) total counter")
+	assert.Zero(t, rs.Counters.IOError, "error counter")
+	assert.Equal(t, int64(2), rs.Counters.Success, "Run(ctx) success counter")
+	assert.Zero(t, rs.Counters.IDmismatch, "Run(ctx) mismatch counter")
+	assert.Zero(t, rs.Counters.Truncated, "Run(ctx) truncated counter")
+}
+
+func assertTimings(t *testing.T, rs *ResultStats) {
+	if assert.Len(t, rs.Timings, 2, "Run(ctx) rstats timings") {
+		t0 := rs.Timings[0]
+		t1 := rs.Timings[1]
+		assert.NotZero(t, t0.Duration, "Run(ctx) rstats timings duration")
+		assert.NotZero(t, t0.Start, "Run(ctx) rstats timings start")
+		assert.NotZero(t, t1.Duration, "Run(ctx) rstats timings duration")
+		assert.NotZero(t, t1.Start, "Run(ctx) rstats timings start")
+	}
+}
+
+func createBenchmark(server string, tcp bool, prob float64) Benchmark {
+	return Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A", "AAAA"},
+		Server:         server,
+		TCP:            tcp,
+		Concurrency:    2,
+		Count:          1,
+		Probability:    prob,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+}
+
+// A returns an A record from rr. It panics on errors.
+func A(rr string) *dns.A { r, _ := dns.NewRR(rr); return r.(*dns.A) }
+
+func TestBenchmark_normalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		benchmark  Benchmark
+		wantServer string
+		wantErr    bool
+	}{
+		{
+			name:       "server - IPv4",
+			benchmark:  Benchmark{Server: "8.8.8.8"},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:       "server - IPv4 with port",
+			benchmark:  Benchmark{Server: "8.8.8.8:53"},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:       "server - IPv6",
+			benchmark:  Benchmark{Server: "fddd:dddd::"},
+			wantServer: "[fddd:dddd::]:53",
+		},
+		{
+			name:       "server - IPv6",
+			benchmark:  Benchmark{Server: "fddd:dddd::"},
+			wantServer: "[fddd:dddd::]:53",
+		},
+		{
+			name:       "server - IPv6 with port",
+			benchmark:  Benchmark{Server: "fddd:dddd::"},
+			wantServer: "[fddd:dddd::]:53",
+		},
+		{
+			name:       "server - HTTPS url",
+			benchmark:  Benchmark{Server: "https://1.1.1.1/dns-query"},
+			wantServer: "https://1.1.1.1/dns-query",
+		},
+		{
+			name:       "server - HTTP url",
+			benchmark:  Benchmark{Server: "http://127.0.0.1/dns-query"},
+			wantServer: "http://127.0.0.1/dns-query",
+		},
+		{
+			name:       "server - QUIC url",
+			benchmark:  Benchmark{Server: "quic://dns.adguard-dns.com"},
+			wantServer: "dns.adguard-dns.com:853",
+		},
+		{
+			name:       "server - QUIC url with port",
+			benchmark:  Benchmark{Server: "quic://localhost:853"},
+			wantServer: "localhost:853",
+		},
+		{
+			name:       "server - DoQ",
+			benchmark:  Benchmark{Server: "dns.adguard-dns.com", DOQ: true},
+			wantServer: "dns.adguard-dns.com:853",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.benchmark.normalize()
+
+			require.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantServer, tt.benchmark.Server)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := New(s.Addr, "example.org")
+	bench.Count = 2
+	bench.Silent = true
+
+	rs, err := bench.Run(context.Background())
+
+	require.NoError(t, err)
+	merged := Merge(rs)
+	assert.Equal(t, int64(2), merged.Counters.Total)
+	assert.Equal(t, int64(2), merged.Codes[dns.RcodeSuccess])
+	assert.False(t, bench.interrupted)
+}
+
+func TestBenchmark_Run_progressFunc(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		time.Sleep(50 * time.Millisecond)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 5
+	bench.Silent = true
+	bench.Progress = 20 * time.Millisecond
+	var progress []ProgressStats
+	bench.ProgressFunc = func(p ProgressStats) {
+		progress = append(progress, p)
+	}
+
+	_, err := bench.Run(context.Background())
+
+	require.NoError(t, err)
+	require.Greater(t, len(progress), 1)
+	last := progress[len(progress)-1]
+	assert.Equal(t, int64(20), last.Requests)
+	assert.Equal(t, int64(0), last.Errors)
+	assert.Equal(t, int64(0), last.InFlight)
+	assert.Equal(t, map[int]int64{dns.RcodeSuccess: 20}, last.Rcodes)
+}
+
+func TestBenchmark_Run_interrupted(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		time.Sleep(10 * time.Millisecond)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.Duration = time.Minute
+	bench.Silent = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err)
+	assert.True(t, bench.interrupted)
+	assert.Positive(t, Merge(rs).Counters.Total)
+}
+
+func TestBenchmark_Run_noDatapoints(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 10)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.NoDatapoints = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2, "Run(ctx) rstats")
+	for _, r := range rs {
+		assertResultStats(t, r)
+		assert.Empty(t, r.Timings, "Run(ctx) rstats timings")
+		assert.Equal(t, int64(2), r.Hist.TotalCount(), "Run(ctx) rstats histogram count")
+	}
+}
+
+func TestBenchmark_normalize_noDatapoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		bench Benchmark
+	}{
+		{
+			name:  "plot",
+			bench: Benchmark{Server: "127.0.0.1", NoDatapoints: true, PlotDir: "/tmp"},
+		},
+		{
+			name:  "series",
+			bench: Benchmark{Server: "127.0.0.1", NoDatapoints: true, SeriesInterval: time.Second},
+		},
+		{
+			name:  "rate ramp",
+			bench: Benchmark{Server: "127.0.0.1", NoDatapoints: true, RateRamp: "100:1s"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bench.normalize()
+
+			assert.EqualError(t, err, "--no-datapoints cannot be combined with --plot, --series-interval or --rate-ramp options")
+		})
+	}
+}
+
+func TestBenchmark_Run_datapointSampleRate(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 500
+	bench.DatapointSampleRate = 0.1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	merged := Merge(rs)
+	assert.Equal(t, int64(2000), merged.Counters.Success, "Run(ctx) success counter")
+	assert.Equal(t, int64(2000), merged.Hist.TotalCount(), "Run(ctx) histogram count")
+	assert.InDelta(t, 200, len(merged.Timings), 80, "Run(ctx) sampled timings")
+}
+
+func TestBenchmark_Run_maxDatapoints(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 1000
+	bench.MaxDatapoints = 100
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	for _, st := range rs {
+		assert.LessOrEqual(t, len(st.Timings), 50, "each worker stores its share of the datapoints")
+	}
+	merged := Merge(rs)
+	assert.Equal(t, int64(4000), merged.Counters.Success, "Run(ctx) success counter")
+	assert.Equal(t, int64(4000), merged.Hist.TotalCount(), "Run(ctx) histogram count")
+	assert.LessOrEqual(t, len(merged.Timings), 100)
+	assert.InDelta(t, 4000, float64(len(merged.Timings))*merged.datapointWeight(), 1600, "the datapoints are scaled back to the number of the queries")
+}
+
+func TestResultStats_thin(t *testing.T) {
+	rs := &ResultStats{sampleRate: 1, maxDatapoints: 1000, sampler: rand.New(rand.NewSource(1))}
+	start := time.Now()
+	for i := 0; i < 1000000; i++ {
+		if !rs.sampled() {
+			continue
+		}
+		if i%10 == 0 {
+			rs.addErrorTime(start.Add(time.Duration(i)))
+		} else {
+			rs.addDatapoint(Datapoint{Start: start.Add(time.Duration(i))})
+		}
+	}
+
+	assert.LessOrEqual(t, len(rs.Timings)+len(rs.ErrorTimes), 1000)
+	assert.LessOrEqual(t, cap(rs.Timings)+cap(rs.ErrorTimes), 4000, "the memory does not grow with the number of the queries")
+	assert.InDelta(t, 900000, float64(len(rs.Timings))*rs.datapointWeight(), 90000)
+	assert.InDelta(t, 100000, float64(len(rs.ErrorTimes))*rs.datapointWeight(), 30000)
+}
+
+func TestMerge_datapointWeight(t *testing.T) {
+	start := time.Now()
+	var timings []Datapoint
+	for i := 0; i < 8; i++ {
+		timings = append(timings, Datapoint{Start: start.Add(time.Duration(i))})
+	}
+	rs := []*ResultStats{
+		{Counters: &Counters{}, Timings: timings},
+		{Counters: &Counters{}, Timings: timings[:2], DatapointWeight: 4},
+	}
+
+	merged := Merge(rs)
+
+	assert.Equal(t, 4.0, merged.DatapointWeight)
+	assert.Len(t, merged.Timings, 4, "the datapoints of the results with lower weight are thinned to the weight of the merged results")
+}
+
+func TestBenchmark_normalize_datapointSampleRate(t *testing.T) {
+	bench := Benchmark{Server: "127.0.0.1", DatapointSampleRate: 1.5}
+
+	err := bench.normalize()
+
+	assert.EqualError(t, err, "--datapoint-sample-rate has to be between 0 and 1")
+}
+
+func TestBenchmark_normalize_maxDatapoints(t *testing.T) {
+	bench := Benchmark{Server: "127.0.0.1", MaxDatapoints: -1}
+
+	err := bench.normalize()
+
+	assert.EqualError(t, err, "--max-datapoints cannot be negative")
+}
+
+func TestBenchmark_Run_nxdomainRatio(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Name != "example.org." {
+			ret.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 50
+	bench.NXDomainRatio = 0.5
+	bench.Seed = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(200), merged.Counters.Total)
+	assert.Greater(t, merged.Counters.NXDomainQueries, int64(50))
+	assert.Less(t, merged.Counters.NXDomainQueries, int64(150))
+	assert.Equal(t, merged.Counters.NXDomainQueries, merged.Codes[dns.RcodeNameError])
+	assert.Equal(t, merged.Counters.Total-merged.Counters.NXDomainQueries, merged.Codes[dns.RcodeSuccess])
+	assert.Equal(t, merged.Codes[dns.RcodeNameError], merged.RcodeHists[dns.RcodeNameError].TotalCount(), "latency of negative answers is recorded separately")
+}
+
+func TestBenchmark_Run_classAndOpcode(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*dns.Msg
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		requests = append(requests, r)
+		mu.Unlock()
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Class = "ch"
+	bench.Opcode = "NOTIFY"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.Total)
+	assert.Equal(t, int64(4), merged.Codes[dns.RcodeSuccess])
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 4)
+	for _, r := range requests {
+		assert.Equal(t, dns.OpcodeNotify, r.Opcode)
+		assert.Equal(t, uint16(dns.ClassCHAOS), r.Question[0].Qclass)
+		assert.True(t, r.Authoritative, "NOTIFY is sent with AA flag")
+	}
+}
+
+func TestBenchmark_normalize_classAndOpcode(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", Class: "XX"}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", Opcode: "IQUERY"}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", Opcode: "UPDATE", Transfer: "axfr"}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", Class: "HS", Opcode: "update"}
+	require.NoError(t, b.normalize())
+	assert.Equal(t, uint16(dns.ClassHESIOD), b.qclass)
+	assert.Equal(t, dns.OpcodeUpdate, b.opcode)
+}
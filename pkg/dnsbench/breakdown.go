@@ -0,0 +1,170 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/olekukonko/tablewriter"
+)
+
+// recordKeyed records the value to the histogram of the key, missing histogram is created with the same parameters as the template.
+func recordKeyed[K comparable](hists map[K]*hdrhistogram.Histogram, key K, template *hdrhistogram.Histogram, v int64) {
+	h, ok := hists[key]
+	if !ok {
+		h = hdrhistogram.New(template.LowestTrackableValue(), template.HighestTrackableValue(), int(template.SignificantFigures()))
+		hists[key] = h
+	}
+	h.RecordValue(v)
+}
+
+// mergeKeyed merges the histograms of src to the histograms of dst with the same keys, dst is created if it is nil.
+func mergeKeyed[K comparable](dst, src map[K]*hdrhistogram.Histogram) map[K]*hdrhistogram.Histogram {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[K]*hdrhistogram.Histogram)
+	}
+	for k, h := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = hdrhistogram.New(h.LowestTrackableValue(), h.HighestTrackableValue(), int(h.SignificantFigures()))
+		}
+		dst[k].Merge(h)
+	}
+	return dst
+}
+
+func exportKeyed[K comparable](hists map[K]*hdrhistogram.Histogram) map[K]*hdrhistogram.Snapshot {
+	if hists == nil {
+		return nil
+	}
+	res := make(map[K]*hdrhistogram.Snapshot, len(hists))
+	for k, h := range hists {
+		res[k] = h.Export()
+	}
+	return res
+}
+
+func importKeyed[K comparable](snapshots map[K]*hdrhistogram.Snapshot) map[K]*hdrhistogram.Histogram {
+	if snapshots == nil {
+		return nil
+	}
+	res := make(map[K]*hdrhistogram.Histogram, len(snapshots))
+	for k, s := range snapshots {
+		res[k] = hdrhistogram.Import(s)
+	}
+	return res
+}
+
+// rcodeHistsByName returns the histograms of response codes keyed by the names of the response codes.
+func rcodeHistsByName(hists map[int]*hdrhistogram.Histogram) map[string]*hdrhistogram.Histogram {
+	res := make(map[string]*hdrhistogram.Histogram, len(hists))
+	for k, h := range hists {
+		res[dns.RcodeToString[k]] = h
+	}
+	return res
+}
+
+// responseFlags returns the flags set in the header of the response separated by spaces, for example "RA AD", or "none" if no flag is set.
+func responseFlags(resp *dns.Msg) string {
+	var flags []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"AA", resp.Authoritative}, {"TC", resp.Truncated}, {"RA", resp.RecursionAvailable}, {"AD", resp.AuthenticatedData}, {"CD", resp.CheckingDisabled},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	if len(flags) == 0 {
+		return "none"
+	}
+	return strings.Join(flags, " ")
+}
+
+// printBreakdown prints table of latency percentiles of the histograms sorted by their keys, the table is printed only when there are at least two keys,
+// since single key breakdown is the same as the overall timings.
+func printBreakdown(w io.Writer, title, keyHeader string, hists map[string]*hdrhistogram.Histogram) {
+	if len(hists) < 2 {
+		return
+	}
+	keys := make([]string, 0, len(hists))
+	for k := range hists {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := make([][]string, 0, len(keys))
+	for _, k := range keys {
+		h := hists[k]
+		lines = append(lines, []string{
+			k,
+			strconv.FormatInt(h.TotalCount(), 10),
+			roundDuration(time.Duration(h.ValueAtQuantile(50))).String(),
+			roundDuration(time.Duration(h.ValueAtQuantile(95))).String(),
+			roundDuration(time.Duration(h.ValueAtQuantile(99))).String(),
+			roundDuration(time.Duration(h.Max())).String(),
+		})
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, title)
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{keyHeader, "Responses", "p50", "p95", "p99", "Max"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+}
+
+// printIdentities prints latencies by the identities of the responding servers, when all the responses were returned by a single server,
+// only its identity is printed.
+func printIdentities(w io.Writer, hists map[string]*hdrhistogram.Histogram) {
+	if len(hists) == 1 {
+		for k := range hists {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "All responses were returned by server", highlightStr(k))
+		}
+		return
+	}
+	printBreakdown(w, "DNS timings by server identity:", "Server", hists)
+}
+
+// cnameDepthHistsByName keys the histograms by the CNAME chain depth formatted as a string.
+func cnameDepthHistsByName(hists map[int]*hdrhistogram.Histogram) map[string]*hdrhistogram.Histogram {
+	res := make(map[string]*hdrhistogram.Histogram, len(hists))
+	for k, h := range hists {
+		res[strconv.Itoa(k)] = h
+	}
+	return res
+}
+
+func printCNAMEDepths(w io.Writer, hists map[int]*hdrhistogram.Histogram) {
+	if len(hists) == 1 {
+		for k := range hists {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "All responses followed", highlightStr(k), "CNAME records")
+		}
+		return
+	}
+	printBreakdown(w, "DNS timings by CNAME chain depth:", "Depth", cnameDepthHistsByName(hists))
+}
+
+// latencyStatsByKey returns latency statistics of the histograms, nil is returned if there are no histograms.
+func latencyStatsByKey(hists map[string]*hdrhistogram.Histogram) map[string]latencyStats {
+	if len(hists) == 0 {
+		return nil
+	}
+	res := make(map[string]latencyStats, len(hists))
+	for k, h := range hists {
+		res[k] = newLatencyStats(h)
+	}
+	return res
+}
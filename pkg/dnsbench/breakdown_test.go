@@ -0,0 +1,117 @@
+package dnsbench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultStats_record_breakdown(t *testing.T) {
+	b := Benchmark{HistMin: time.Microsecond, HistMax: time.Second, HistPre: 3, Rcodes: true}
+	st := b.newResultStats(0)
+
+	a, https := query("example.org.", dns.TypeA), query("example.org.", dns.TypeHTTPS)
+	st.record(a, reply(a, dns.RcodeSuccess), time.Now(), 10*time.Millisecond)
+	st.record(a, reply(a, dns.RcodeNameError), time.Now(), 20*time.Millisecond)
+	st.record(https, reply(https, dns.RcodeSuccess), time.Now(), 100*time.Millisecond)
+
+	require.Len(t, st.QtypeHists, 2)
+	assert.Equal(t, int64(2), st.QtypeHists["A"].TotalCount())
+	assert.Equal(t, int64(1), st.QtypeHists["HTTPS"].TotalCount())
+	assert.InDelta(t, 100*time.Millisecond, st.QtypeHists["HTTPS"].Max(), float64(time.Millisecond))
+
+	require.Len(t, st.RcodeHists, 2)
+	assert.Equal(t, int64(2), st.RcodeHists[dns.RcodeSuccess].TotalCount())
+	assert.Equal(t, int64(1), st.RcodeHists[dns.RcodeNameError].TotalCount())
+}
+
+func TestMerge_breakdown(t *testing.T) {
+	h1 := hdrhistogram.New(0, 1000, 1)
+	h1.RecordValue(5)
+	h2 := hdrhistogram.New(0, 1000, 1)
+	h2.RecordValue(10)
+	h3 := hdrhistogram.New(0, 1000, 1)
+	h3.RecordValue(20)
+
+	merged := Merge([]*ResultStats{
+		{QtypeHists: map[string]*hdrhistogram.Histogram{"A": h1}, RcodeHists: map[int]*hdrhistogram.Histogram{dns.RcodeSuccess: h1}},
+		{QtypeHists: map[string]*hdrhistogram.Histogram{"A": h2, "AAAA": h3}},
+	})
+
+	require.Len(t, merged.QtypeHists, 2)
+	assert.Equal(t, int64(2), merged.QtypeHists["A"].TotalCount())
+	assert.Equal(t, int64(1), merged.QtypeHists["AAAA"].TotalCount())
+	require.Len(t, merged.RcodeHists, 1)
+	assert.Equal(t, int64(1), merged.RcodeHists[dns.RcodeSuccess].TotalCount())
+	// merging must not modify the merged stats
+	assert.Equal(t, int64(1), h1.TotalCount())
+}
+
+func Test_printBreakdown(t *testing.T) {
+	a := hdrhistogram.New(0, int64(time.Second), 3)
+	a.RecordValue(int64(10 * time.Millisecond))
+	https := hdrhistogram.New(0, int64(time.Second), 3)
+	https.RecordValue(int64(100 * time.Millisecond))
+
+	buf := bytes.Buffer{}
+	printBreakdown(&buf, "DNS timings by question type:", "Type", map[string]*hdrhistogram.Histogram{"HTTPS": https, "A": a})
+
+	out := buf.String()
+	assert.Contains(t, out, "DNS timings by question type:")
+	assert.Contains(t, out, "TYPE")
+	assert.Contains(t, out, "P99")
+	assert.Less(t, bytes.Index(buf.Bytes(), []byte(" A ")), bytes.Index(buf.Bytes(), []byte("HTTPS")), "expected rows sorted by key")
+	assert.Contains(t, out, "10ms")
+
+	buf.Reset()
+	printBreakdown(&buf, "DNS timings by question type:", "Type", map[string]*hdrhistogram.Histogram{"A": a})
+	assert.Empty(t, buf.String(), "single key breakdown should not be printed")
+}
+
+func query(name string, qtype uint16) *dns.Msg {
+	m := dns.Msg{}
+	m.SetQuestion(name, qtype)
+	return &m
+}
+
+func reply(req *dns.Msg, rcode int) *dns.Msg {
+	m := dns.Msg{}
+	m.SetRcode(req, rcode)
+	return &m
+}
+
+func Test_responseFlags(t *testing.T) {
+	m := new(dns.Msg)
+	assert.Equal(t, "none", responseFlags(m))
+
+	m.RecursionAvailable = true
+	m.AuthenticatedData = true
+	assert.Equal(t, "RA AD", responseFlags(m))
+
+	m.Authoritative, m.Truncated, m.CheckingDisabled = true, true, true
+	assert.Equal(t, "AA TC RA AD CD", responseFlags(m))
+}
+
+func TestResultStats_record_flags(t *testing.T) {
+	b := Benchmark{HistMin: time.Microsecond, HistMax: time.Second, HistPre: 3}
+	st := b.newResultStats(0)
+
+	a := query("example.org.", dns.TypeA)
+	validated := reply(a, dns.RcodeSuccess)
+	validated.RecursionAvailable, validated.AuthenticatedData = true, true
+	insecure := reply(a, dns.RcodeSuccess)
+	insecure.RecursionAvailable = true
+	st.record(a, validated, time.Now(), 50*time.Millisecond)
+	st.record(a, validated, time.Now(), 60*time.Millisecond)
+	st.record(a, insecure, time.Now(), 10*time.Millisecond)
+
+	require.Len(t, st.FlagHists, 2)
+	assert.Equal(t, int64(2), st.FlagHists["RA AD"].TotalCount())
+	assert.Equal(t, int64(1), st.FlagHists["RA"].TotalCount())
+	assert.InDelta(t, 60*time.Millisecond, st.FlagHists["RA AD"].Max(), float64(time.Millisecond))
+}
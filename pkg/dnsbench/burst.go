@@ -0,0 +1,158 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// burstQuarters is the number of parts of the burst, by which the latencies of the queries are broken down.
+const burstQuarters = 4
+
+// BurstStats are the results of the queries sent in a single burst, see Benchmark.Burst.
+type BurstStats struct {
+	Queries int64
+	// Dropped counts the queries of the burst failed due to I/O errors or timeouts.
+	Dropped int64
+	// Drain is the time from the start of the burst until the last response of the burst was received.
+	Drain time.Duration
+}
+
+func (s *BurstStats) add(o *BurstStats) {
+	s.Queries += o.Queries
+	s.Dropped += o.Dropped
+	if o.Drain > s.Drain {
+		s.Drain = o.Drain
+	}
+}
+
+// burstTag identifies the burst, in which the query is sent, and the quarter of the burst the query belongs to.
+type burstTag struct {
+	index   int64
+	quarter int
+	start   time.Time
+}
+
+// burstLimiter releases Benchmark.Burst queries at the start of each Benchmark.BurstInterval, the queries of the burst are sent back-to-back
+// by the workers as soon as they are free and the following queries wait for the next burst. The first burst starts with the first query.
+// The limiter is safe for concurrent use by benchmark workers.
+type burstLimiter struct {
+	size     int
+	interval time.Duration
+
+	mu    sync.Mutex
+	start time.Time
+	index int64
+	taken int
+}
+
+func newBurstLimiter(size int, interval time.Duration) *burstLimiter {
+	return &burstLimiter{size: size, interval: interval}
+}
+
+// wait blocks until the query can be sent in the current or one of the following bursts, or until the context is done.
+func (l *burstLimiter) wait(ctx context.Context) (burstTag, error) {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if l.start.IsZero() {
+			l.start = now
+		}
+		if index := int64(now.Sub(l.start) / l.interval); index > l.index {
+			l.index, l.taken = index, 0
+		}
+		burstStart := l.start.Add(time.Duration(l.index) * l.interval)
+		if l.taken < l.size {
+			tag := burstTag{index: l.index, quarter: l.taken * burstQuarters / l.size, start: burstStart}
+			l.taken++
+			l.mu.Unlock()
+			return tag, nil
+		}
+		l.mu.Unlock()
+		if err := waitUntil(ctx, burstStart.Add(l.interval)); err != nil {
+			return burstTag{}, err
+		}
+	}
+}
+
+// recordBurst records the query of the burst the last query was sent in, err is the error of the query.
+func (rs *ResultStats) recordBurst(err error) {
+	if rs.Bursts == nil {
+		return
+	}
+	s, ok := rs.Bursts[rs.burst.index]
+	if !ok {
+		s = &BurstStats{}
+		rs.Bursts[rs.burst.index] = s
+	}
+	s.Queries++
+	if err != nil {
+		s.Dropped++
+		return
+	}
+	if d := time.Since(rs.burst.start); d > s.Drain {
+		s.Drain = d
+	}
+}
+
+// burstSummary summarizes the drain times and dropped queries of the bursts.
+type burstSummary struct {
+	bursts      int
+	drainP50    time.Duration
+	drainP99    time.Duration
+	drainMax    time.Duration
+	droppedMean float64
+	droppedMax  int64
+}
+
+func summarizeBursts(bursts map[int64]*BurstStats) burstSummary {
+	s := burstSummary{bursts: len(bursts)}
+	if len(bursts) == 0 {
+		return s
+	}
+	drains := make([]time.Duration, 0, len(bursts))
+	var dropped int64
+	for _, b := range bursts {
+		drains = append(drains, b.Drain)
+		dropped += b.Dropped
+		if b.Dropped > s.droppedMax {
+			s.droppedMax = b.Dropped
+		}
+	}
+	sort.Slice(drains, func(i, j int) bool { return drains[i] < drains[j] })
+	quantile := func(q float64) time.Duration {
+		return drains[int(math.Ceil(q*float64(len(drains))))-1]
+	}
+	s.drainP50, s.drainP99, s.drainMax = quantile(0.5), quantile(0.99), drains[len(drains)-1]
+	s.droppedMean = float64(dropped) / float64(len(bursts))
+	return s
+}
+
+// burstHistsByName returns the histograms of the quarters of the burst keyed by the range of positions within the burst in percent.
+func burstHistsByName(hists map[int]*hdrhistogram.Histogram) map[string]*hdrhistogram.Histogram {
+	res := make(map[string]*hdrhistogram.Histogram, len(hists))
+	for k, h := range hists {
+		res[fmt.Sprintf("%d-%d%%", k*100/burstQuarters, (k+1)*100/burstQuarters)] = h
+	}
+	return res
+}
+
+func printBursts(w io.Writer, size int, interval time.Duration, bursts map[int64]*BurstStats, hists map[int]*hdrhistogram.Histogram) {
+	if bursts == nil {
+		return
+	}
+	s := summarizeBursts(bursts)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Bursts of", highlightStr(size), "queries every", highlightStr(interval)+":")
+	fmt.Fprintf(w, "\tBursts:\t\t\t%s\n", highlightStr(s.bursts))
+	fmt.Fprintf(w, "\tDrain time:\t\tp50 %s, p99 %s, max %s\n", highlightStr(roundDuration(s.drainP50)), highlightStr(roundDuration(s.drainP99)),
+		highlightStr(roundDuration(s.drainMax)))
+	fmt.Fprintf(w, "\tDropped per burst:\tmean %s, max %s\n", highlightStr(fmt.Sprintf("%0.2f", s.droppedMean)), highlightStr(s.droppedMax))
+	printBreakdown(w, "DNS timings by position in burst:", "Position", burstHistsByName(hists))
+}
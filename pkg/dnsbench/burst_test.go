@@ -0,0 +1,101 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_burstLimiter_wait(t *testing.T) {
+	l := newBurstLimiter(3, 200*time.Millisecond)
+	ctx := context.Background()
+
+	var quarters []int
+	for i := 0; i < 3; i++ {
+		tag, err := l.wait(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, tag.index)
+		quarters = append(quarters, tag.quarter)
+	}
+	assert.Equal(t, []int{0, 1, 2}, quarters)
+
+	start := time.Now()
+	tag, err := l.wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), tag.index, "the query waits for the next burst")
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	assert.Zero(t, tag.quarter)
+
+	l.wait(ctx)
+	l.wait(ctx)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = l.wait(cancelled)
+	assert.Error(t, err)
+}
+
+func Test_summarizeBursts(t *testing.T) {
+	s := summarizeBursts(map[int64]*BurstStats{
+		0: {Queries: 10, Drain: 30 * time.Millisecond},
+		1: {Queries: 10, Dropped: 3, Drain: 10 * time.Millisecond},
+		2: {Queries: 10, Dropped: 1, Drain: 20 * time.Millisecond},
+	})
+	assert.Equal(t, burstSummary{
+		bursts:      3,
+		drainP50:    20 * time.Millisecond,
+		drainP99:    30 * time.Millisecond,
+		drainMax:    30 * time.Millisecond,
+		droppedMean: 4.0 / 3,
+		droppedMax:  3,
+	}, s)
+	assert.Equal(t, burstSummary{}, summarizeBursts(nil))
+}
+
+func TestBenchmark_Run_burst(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Concurrency = 4
+	bench.Count = 0
+	bench.Duration = time.Second
+	bench.Burst = 8
+	bench.BurstInterval = 300 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.GreaterOrEqual(t, len(merged.Bursts), 3)
+	var queries int64
+	for _, b := range merged.Bursts {
+		assert.LessOrEqual(t, b.Queries, int64(8))
+		assert.Zero(t, b.Dropped)
+		assert.Less(t, b.Drain, 300*time.Millisecond, "the burst is drained before the next one")
+		queries += b.Queries
+	}
+	assert.Equal(t, merged.Counters.Total, queries)
+	assert.Len(t, merged.BurstHists, burstQuarters)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "Bursts of")
+	assert.Contains(t, buf.String(), "DNS timings by position in burst:")
+}
+
+func TestBenchmark_normalize_burst(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", Burst: 10}
+	assert.Error(t, b.normalize(), "burst interval is required")
+
+	b = Benchmark{Server: "8.8.8.8", Burst: 10, BurstInterval: time.Second, Rate: 100}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", Burst: 10, BurstInterval: time.Second}
+	assert.NoError(t, b.normalize())
+}
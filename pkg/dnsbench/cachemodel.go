@@ -0,0 +1,147 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+// keys of ResultStats.CacheHists.
+const (
+	firstSeenQuestion = "first-seen"
+	repeatedQuestion  = "repeated"
+)
+
+// questionKey identifies the question regardless of the case of the name, as the caches of the resolvers do.
+type questionKey struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+// questionSet remembers the questions issued by all the workers of the benchmark, so that the first query of each question can be told
+// apart from the repeated ones, see Benchmark.CacheModel. It is safe for concurrent use.
+type questionSet struct {
+	mu   sync.Mutex
+	seen map[questionKey]struct{}
+}
+
+func newQuestionSet() *questionSet {
+	return &questionSet{seen: make(map[questionKey]struct{})}
+}
+
+// firstSeen remembers the question and returns whether it was issued for the first time, false is returned for nil set.
+func (s *questionSet) firstSeen(q dns.Question) bool {
+	if s == nil {
+		return false
+	}
+	k := questionKey{name: strings.ToLower(q.Name), qtype: q.Qtype, qclass: q.Qclass}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[k]; ok {
+		return false
+	}
+	s.seen[k] = struct{}{}
+	return true
+}
+
+// recordCacheModel records the latency of the response to the histogram of the first-seen or the repeated questions.
+func (rs *ResultStats) recordCacheModel(first bool, timing time.Duration) {
+	if rs.CacheHists == nil {
+		return
+	}
+	key := repeatedQuestion
+	if first {
+		key = firstSeenQuestion
+	}
+	recordKeyed(rs.CacheHists, key, rs.Hist, timing.Nanoseconds())
+}
+
+// cacheModel is the summary of the latencies of the first-seen and the repeated questions, the first query of each question is presumably
+// resolved by the resolver upstream, while the repeated queries are presumably answered from its cache.
+type cacheModel struct {
+	queries   int64
+	unique    int64
+	firstSeen *hdrhistogram.Histogram
+	repeated  *hdrhistogram.Histogram
+}
+
+func newCacheModel(hists map[string]*hdrhistogram.Histogram, c *Counters) cacheModel {
+	return cacheModel{queries: c.Total, unique: c.UniqueQuestions, firstSeen: hists[firstSeenQuestion], repeated: hists[repeatedQuestion]}
+}
+
+// repeatRate returns the fraction of the queries asking the questions already asked before.
+func (m cacheModel) repeatRate() float64 {
+	if m.queries == 0 {
+		return 0
+	}
+	return float64(m.queries-m.unique) / float64(m.queries)
+}
+
+// missPenalty returns the difference of the median latencies of the first-seen and the repeated questions, that is the inferred time
+// the resolver spends resolving the question upstream, zero is returned when there are no responses to either of them.
+func (m cacheModel) missPenalty() time.Duration {
+	if m.firstSeen == nil || m.repeated == nil {
+		return 0
+	}
+	return median(m.firstSeen) - median(m.repeated)
+}
+
+func median(h *hdrhistogram.Histogram) time.Duration {
+	if h == nil {
+		return 0
+	}
+	return time.Duration(h.ValueAtQuantile(50))
+}
+
+func printCacheModel(w io.Writer, hists map[string]*hdrhistogram.Histogram, c *Counters) {
+	if hists == nil {
+		return
+	}
+	m := newCacheModel(hists, c)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Cache model:")
+	fmt.Fprintf(w, "Unique questions:\t%s of %s queries (%0.2f%% repeated)\n", highlightStr(m.unique), highlightStr(m.queries), m.repeatRate()*100)
+	if m.firstSeen != nil {
+		fmt.Fprintf(w, "Inferred cache miss p50:\t%s (first-seen questions)\n", highlightStr(roundDuration(median(m.firstSeen))))
+	}
+	if m.repeated != nil {
+		fmt.Fprintf(w, "Inferred cache hit p50:\t%s (repeated questions)\n", highlightStr(roundDuration(median(m.repeated))))
+	}
+	if m.firstSeen != nil && m.repeated != nil {
+		fmt.Fprintf(w, "Inferred miss penalty:\t%s\n", highlightStr(roundDuration(m.missPenalty())))
+	}
+	printBreakdown(w, "DNS timings of first-seen and repeated questions:", "Question", hists)
+}
+
+type jsonCacheModel struct {
+	UniqueQuestions  int64         `json:"uniqueQuestions"`
+	RepeatRate       float64       `json:"repeatRate"`
+	MissPenaltyMs    float64       `json:"missPenaltyMs"`
+	FirstSeenLatency *latencyStats `json:"firstSeenLatencyStats,omitempty"`
+	RepeatedLatency  *latencyStats `json:"repeatedLatencyStats,omitempty"`
+}
+
+func newJSONCacheModel(hists map[string]*hdrhistogram.Histogram, c *Counters) *jsonCacheModel {
+	m := newCacheModel(hists, c)
+	res := &jsonCacheModel{
+		UniqueQuestions: m.unique,
+		RepeatRate:      math.Round(m.repeatRate()*10000) / 10000,
+		MissPenaltyMs:   durationMs(m.missPenalty()),
+	}
+	if m.firstSeen != nil {
+		s := newLatencyStats(m.firstSeen)
+		res.FirstSeenLatency = &s
+	}
+	if m.repeated != nil {
+		s := newLatencyStats(m.repeated)
+		res.RepeatedLatency = &s
+	}
+	return res
+}
@@ -0,0 +1,112 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cachingHandler answers the first query of each question slowly, as if it was resolved upstream, and the repeated queries quickly.
+func cachingHandler() dns.HandlerFunc {
+	var mu sync.Mutex
+	cached := make(map[string]bool)
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		k := strings.ToLower(r.Question[0].Name) + dns.TypeToString[r.Question[0].Qtype]
+		mu.Lock()
+		hit := cached[k]
+		cached[k] = true
+		mu.Unlock()
+		if !hit {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	}
+}
+
+func TestBenchmark_Run_cacheModel(t *testing.T) {
+	s := NewServer(udp, cachingHandler())
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	// single worker, so that the first-seen questions are the first ones received by the server
+	bench.Concurrency = 1
+	bench.Count = 3
+	bench.CacheModel = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(6), merged.Counters.Total)
+	assert.Equal(t, int64(2), merged.Counters.UniqueQuestions)
+	require.Len(t, merged.CacheHists, 2)
+	assert.Equal(t, int64(2), merged.CacheHists[firstSeenQuestion].TotalCount())
+	assert.Equal(t, int64(4), merged.CacheHists[repeatedQuestion].TotalCount())
+
+	m := newCacheModel(merged.CacheHists, merged.Counters)
+	assert.InDelta(t, 4.0/6, m.repeatRate(), 0.0001)
+	assert.Greater(t, m.missPenalty(), 50*time.Millisecond)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	out := buf.String()
+	assert.Contains(t, out, "Unique questions:\t2 of 6 queries (66.67% repeated)")
+	assert.Contains(t, out, "DNS timings of first-seen and repeated questions:")
+
+	bench.JSON = true
+	buf.Reset()
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	var res struct {
+		CacheModel jsonCacheModel `json:"cacheModel"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	assert.Equal(t, int64(2), res.CacheModel.UniqueQuestions)
+	assert.InDelta(t, 0.6667, res.CacheModel.RepeatRate, 0.0001)
+	assert.Greater(t, res.CacheModel.MissPenaltyMs, 50.0)
+	require.NotNil(t, res.CacheModel.FirstSeenLatency)
+	require.NotNil(t, res.CacheModel.RepeatedLatency)
+}
+
+func TestBenchmark_Run_cacheModel_warmup(t *testing.T) {
+	s := NewServer(udp, cachingHandler())
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.CacheModel = true
+	bench.WarmupQueries = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Zero(t, merged.Counters.UniqueQuestions, "questions of the warm-up are not first-seen")
+	require.Len(t, merged.CacheHists, 1)
+	assert.Equal(t, int64(4), merged.CacheHists[repeatedQuestion].TotalCount())
+}
+
+func TestQuestionSet_firstSeen(t *testing.T) {
+	s := newQuestionSet()
+
+	assert.True(t, s.firstSeen(dns.Question{Name: "example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}))
+	assert.False(t, s.firstSeen(dns.Question{Name: "EXAMPLE.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}), "names differing in case are the same question")
+	assert.True(t, s.firstSeen(dns.Question{Name: "example.org.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}))
+	assert.True(t, s.firstSeen(dns.Question{Name: "example.org.", Qtype: dns.TypeA, Qclass: dns.ClassCHAOS}))
+
+	var nilSet *questionSet
+	assert.False(t, nilSet.firstSeen(dns.Question{Name: "example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}))
+}
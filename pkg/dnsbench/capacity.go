@@ -0,0 +1,228 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// defaultCapacityPrecision is the default relative precision of the capacity search.
+	defaultCapacityPrecision = 0.05
+	// minAchievedRate is the minimal fraction of the offered rate, which has to be achieved for the rate to be considered sustainable.
+	minAchievedRate = 0.9
+)
+
+// CapacitySearch configures the search of the highest sustainable rate, see Benchmark.FindMaxQPS.
+type CapacitySearch struct {
+	// TargetP99 is the highest acceptable 99th percentile of the latencies.
+	TargetP99 time.Duration
+	// MaxErrorRate is the highest acceptable fraction of the queries failed due to I/O errors, for example 0.01.
+	MaxErrorRate float64
+	// StartRate is the rate of the first trial, the rate is doubled until a trial fails and then the highest sustainable rate is searched by bisection.
+	StartRate int
+	// MaxRate is the highest rate tried, 0 means no limit.
+	MaxRate int
+	// TrialDuration is the duration of the benchmark of each tried rate.
+	TrialDuration time.Duration
+	// Precision is the relative precision of the found rate, the search ends when the lowest unsustainable rate is within the precision
+	// from the highest sustainable rate, 0.05 is used if not set.
+	Precision float64
+}
+
+// CapacityTrial is the result of the benchmark of a single rate tried by the capacity search.
+type CapacityTrial struct {
+	Rate             int
+	QueriesPerSecond float64
+	P99              time.Duration
+	ErrorRate        float64
+	// Passed is set when the latencies and errors were within the bounds and at least 90% of the offered rate was achieved.
+	Passed bool
+}
+
+// CapacityResult is the result of the capacity search.
+type CapacityResult struct {
+	Search CapacitySearch
+	// MaxQPS is the highest sustainable rate found, 0 if none of the tried rates was sustainable.
+	MaxQPS int
+	Trials []CapacityTrial
+}
+
+type jsonCapacityTrial struct {
+	Rate             int     `json:"rate"`
+	QueriesPerSecond float64 `json:"queriesPerSecond"`
+	P99Ms            float64 `json:"p99Ms"`
+	ErrorRate        float64 `json:"errorRate"`
+	Passed           bool    `json:"passed"`
+}
+
+type jsonCapacityResult struct {
+	MaxQueriesPerSecond int                 `json:"maxQueriesPerSecond"`
+	TargetP99Ms         float64             `json:"targetP99Ms"`
+	MaxErrorRate        float64             `json:"maxErrorRate"`
+	Trials              []jsonCapacityTrial `json:"trials"`
+}
+
+func (s CapacitySearch) validate(b *Benchmark) error {
+	if s.TargetP99 <= 0 {
+		return errors.New("--target-p99 has to be positive")
+	}
+	if s.MaxErrorRate < 0 || s.MaxErrorRate > 1 {
+		return errors.New("--max-error-rate has to be between 0 and 1")
+	}
+	if s.StartRate <= 0 {
+		return errors.New("--search-start-rate has to be positive")
+	}
+	if s.MaxRate > 0 && s.MaxRate < s.StartRate {
+		return errors.New("--search-max-rate has to be greater than --search-start-rate")
+	}
+	if s.TrialDuration <= 0 {
+		return errors.New("duration of the trials of the capacity search has to be positive")
+	}
+	if b.Rate > 0 || b.RateRamp != "" || b.Count > 0 || b.Total > 0 || b.RespectTiming {
+		return errors.New("--find-max-qps cannot be combined with --rate-limit, --rate-ramp, --number, --total or --respect-timing options")
+	}
+	return nil
+}
+
+// FindMaxQPS searches the highest rate, which the server sustains with the latencies and errors within the bounds of the search.
+// Each tried rate is benchmarked for the trial duration, starting with the start rate the rate is doubled until a trial fails
+// and then the interval between the highest passed and the lowest failed rate is bisected. If the search is cancelled,
+// the result of the trials executed so far is returned.
+func (b *Benchmark) FindMaxQPS(ctx context.Context, s CapacitySearch) (CapacityResult, error) {
+	if err := s.validate(b); err != nil {
+		return CapacityResult{}, err
+	}
+	if s.Precision <= 0 {
+		s.Precision = defaultCapacityPrecision
+	}
+
+	res := CapacityResult{Search: s}
+	// passed is the highest sustainable rate and failed is the lowest unsustainable rate found so far
+	var passed, failed int
+	rate := s.StartRate
+	for {
+		trial, err := b.capacityTrial(ctx, s, rate)
+		if err != nil {
+			return CapacityResult{}, err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		res.Trials = append(res.Trials, trial)
+		if !b.Silent && !b.JSON {
+			fmt.Printf("Trial of %s QPS: %s QPS, p99 %s, error rate %s, ", highlightStr(rate), highlightStr(fmt.Sprintf("%0.1f", trial.QueriesPerSecond)),
+				highlightStr(roundDuration(trial.P99)), highlightStr(fmt.Sprintf("%0.2f%%", trial.ErrorRate*100)))
+			if trial.Passed {
+				successPrint(os.Stdout, "sustained\n")
+			} else {
+				errPrint(os.Stdout, "not sustained\n")
+			}
+		}
+
+		if trial.Passed {
+			passed = rate
+		} else {
+			failed = rate
+		}
+
+		switch {
+		case failed == 0 && s.MaxRate > 0 && passed >= s.MaxRate:
+			// the highest allowed rate is sustainable
+		case failed == 0:
+			rate = passed * 2
+			if s.MaxRate > 0 && rate > s.MaxRate {
+				rate = s.MaxRate
+			}
+		case passed == 0:
+			rate = failed / 2
+		default:
+			rate = (passed + failed) / 2
+		}
+		if rate < 1 || rate == passed || rate == failed || (passed > 0 && failed > 0 && float64(failed-passed) <= s.Precision*float64(passed)) {
+			break
+		}
+	}
+	res.MaxQPS = passed
+	return res, nil
+}
+
+// capacityTrial benchmarks the rate for the duration of the trial.
+func (b *Benchmark) capacityTrial(ctx context.Context, s CapacitySearch, rate int) (CapacityTrial, error) {
+	bench := *b
+	bench.Rate = rate
+	bench.Duration = s.TrialDuration
+	bench.Silent = true
+
+	start := time.Now()
+	stats, err := bench.Run(ctx)
+	if err != nil {
+		return CapacityTrial{}, err
+	}
+	d := time.Since(start) - bench.warmupDuration
+
+	merged := Merge(stats)
+	trial := CapacityTrial{Rate: rate, QueriesPerSecond: math.Round(float64(merged.Counters.Total)/d.Seconds()*100) / 100}
+	if merged.Hist != nil && merged.Hist.TotalCount() > 0 {
+		trial.P99 = time.Duration(merged.Hist.ValueAtQuantile(99))
+	}
+	if merged.Counters.Total > 0 {
+		trial.ErrorRate = float64(merged.Counters.IOError) / float64(merged.Counters.Total)
+	}
+	trial.Passed = merged.Counters.Total > 0 && trial.P99 <= s.TargetP99 && trial.ErrorRate <= s.MaxErrorRate &&
+		trial.QueriesPerSecond >= minAchievedRate*float64(rate)
+	return trial, nil
+}
+
+// PrintCapacity prints the trials of the capacity search followed by the highest sustainable rate found.
+func (b *Benchmark) PrintCapacity(w io.Writer, res CapacityResult) error {
+	if b.Silent {
+		return nil
+	}
+
+	if b.JSON {
+		j := jsonCapacityResult{
+			MaxQueriesPerSecond: res.MaxQPS,
+			TargetP99Ms:         float64(res.Search.TargetP99) / float64(time.Millisecond),
+			MaxErrorRate:        res.Search.MaxErrorRate,
+			Trials:              make([]jsonCapacityTrial, 0, len(res.Trials)),
+		}
+		for _, t := range res.Trials {
+			j.Trials = append(j.Trials, jsonCapacityTrial{
+				Rate:             t.Rate,
+				QueriesPerSecond: t.QueriesPerSecond,
+				P99Ms:            float64(t.P99) / float64(time.Millisecond),
+				ErrorRate:        math.Round(t.ErrorRate*10000) / 10000,
+				Passed:           t.Passed,
+			})
+		}
+		return json.NewEncoder(w).Encode(j)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Capacity search trials:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Rate", "QPS", "p99", "Error rate", "Sustained"})
+	table.SetBorder(false)
+	for _, t := range res.Trials {
+		table.Append([]string{fmt.Sprint(t.Rate), fmt.Sprintf("%0.1f", t.QueriesPerSecond), roundDuration(t.P99).String(),
+			fmt.Sprintf("%0.2f%%", t.ErrorRate*100), fmt.Sprint(t.Passed)})
+	}
+	table.Render()
+
+	fmt.Fprintln(w)
+	bounds := fmt.Sprintf("p99 <= %s, error rate <= %0.2f%%", res.Search.TargetP99, res.Search.MaxErrorRate*100)
+	if res.MaxQPS == 0 {
+		fmt.Fprintf(w, "None of the tried rates was sustainable with %s\n", bounds)
+		return nil
+	}
+	fmt.Fprintf(w, "Maximum sustainable throughput: %s QPS (%s)\n", highlightStr(res.MaxQPS), bounds)
+	return nil
+}
@@ -0,0 +1,166 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_FindMaxQPS(t *testing.T) {
+	// server answering at most 25 queries per 100ms, the other queries time out
+	var mu sync.Mutex
+	var window time.Time
+	var answered int
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		if now := time.Now(); now.Sub(window) > 100*time.Millisecond {
+			window, answered = now, 0
+		}
+		answered++
+		overloaded := answered > 25
+		mu.Unlock()
+		if overloaded {
+			return
+		}
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.ReadTimeout = 50 * time.Millisecond
+	bench.Silent = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	res, err := bench.FindMaxQPS(ctx, CapacitySearch{
+		TargetP99:     time.Second,
+		MaxErrorRate:  0.05,
+		StartRate:     50,
+		TrialDuration: 500 * time.Millisecond,
+		Precision:     0.2,
+	})
+
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Trials)
+	assert.True(t, res.Trials[0].Passed, "expected the start rate to be sustainable")
+	var failed bool
+	for _, tr := range res.Trials {
+		failed = failed || !tr.Passed
+	}
+	assert.True(t, failed, "expected some of the rates to overload the server")
+	assert.GreaterOrEqual(t, res.MaxQPS, 100)
+	assert.LessOrEqual(t, res.MaxQPS, 300)
+}
+
+func TestBenchmark_FindMaxQPS_maxRate(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.Silent = true
+
+	res, err := bench.FindMaxQPS(context.Background(), CapacitySearch{
+		TargetP99:     time.Second,
+		StartRate:     50,
+		MaxRate:       150,
+		TrialDuration: 300 * time.Millisecond,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 150, res.MaxQPS)
+	rates := make([]int, 0, len(res.Trials))
+	for _, tr := range res.Trials {
+		rates = append(rates, tr.Rate)
+	}
+	assert.Equal(t, []int{50, 100, 150}, rates)
+}
+
+func TestBenchmark_FindMaxQPS_invalid(t *testing.T) {
+	valid := CapacitySearch{TargetP99: time.Second, StartRate: 100, TrialDuration: time.Second}
+	tests := []struct {
+		name    string
+		bench   Benchmark
+		search  func(CapacitySearch) CapacitySearch
+		wantErr string
+	}{
+		{
+			name:    "target p99",
+			search:  func(s CapacitySearch) CapacitySearch { s.TargetP99 = 0; return s },
+			wantErr: "--target-p99 has to be positive",
+		},
+		{
+			name:    "max rate",
+			search:  func(s CapacitySearch) CapacitySearch { s.MaxRate = 50; return s },
+			wantErr: "--search-max-rate has to be greater than --search-start-rate",
+		},
+		{
+			name:    "rate limit",
+			bench:   Benchmark{Rate: 100},
+			search:  func(s CapacitySearch) CapacitySearch { return s },
+			wantErr: "--find-max-qps cannot be combined with --rate-limit, --rate-ramp, --number, --total or --respect-timing options",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.bench.FindMaxQPS(context.Background(), tt.search(valid))
+
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestBenchmark_PrintCapacity(t *testing.T) {
+	res := CapacityResult{
+		Search: CapacitySearch{TargetP99: 20 * time.Millisecond, MaxErrorRate: 0.01},
+		MaxQPS: 200,
+		Trials: []CapacityTrial{
+			{Rate: 100, QueriesPerSecond: 99, P99: 5 * time.Millisecond, Passed: true},
+			{Rate: 200, QueriesPerSecond: 198, P99: 15 * time.Millisecond, Passed: true},
+			{Rate: 400, QueriesPerSecond: 250, P99: 80 * time.Millisecond, ErrorRate: 0.2},
+		},
+	}
+
+	t.Run("std", func(t *testing.T) {
+		b := Benchmark{}
+		buf := bytes.Buffer{}
+
+		require.NoError(t, b.PrintCapacity(&buf, res))
+
+		assert.Contains(t, buf.String(), "Capacity search trials:")
+		assert.Contains(t, buf.String(), "Maximum sustainable throughput: 200 QPS (p99 <= 20ms, error rate <= 1.00%)")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		b := Benchmark{JSON: true}
+		buf := bytes.Buffer{}
+
+		require.NoError(t, b.PrintCapacity(&buf, res))
+
+		var j jsonCapacityResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &j))
+		assert.Equal(t, 200, j.MaxQueriesPerSecond)
+		assert.Equal(t, float64(20), j.TargetP99Ms)
+		require.Len(t, j.Trials, 3)
+		assert.False(t, j.Trials[2].Passed)
+		assert.Equal(t, float64(80), j.Trials[2].P99Ms)
+	})
+}
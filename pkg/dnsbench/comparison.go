@@ -0,0 +1,162 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/olekukonko/tablewriter"
+)
+
+// ServerResult represents benchmark results of a single server, when the benchmark is executed against multiple servers.
+type ServerResult struct {
+	Server   string
+	Stats    []*ResultStats
+	Duration time.Duration
+}
+
+type jsonServerResult struct {
+	Server string `json:"server"`
+	jsonSummary
+}
+
+// jsonSummary is a summary of benchmark results shared by comparisons of servers and phases.
+type jsonSummary struct {
+	TotalRequests    int64        `json:"totalRequests"`
+	TotalErrors      int64        `json:"totalErrors"`
+	ErrorRate        float64      `json:"errorRate"`
+	QueriesPerSecond float64      `json:"queriesPerSecond"`
+	LatencyStats     latencyStats `json:"latencyStats"`
+}
+
+// summarize returns summary of the results and the cells of the summary table: requests, QPS, p50, p95, p99, errors and error rate.
+func summarize(stats []*ResultStats, d time.Duration) (jsonSummary, []string) {
+	merged := Merge(stats)
+	res := jsonSummary{
+		TotalRequests:    merged.Counters.Total,
+		TotalErrors:      merged.Counters.IOError,
+		QueriesPerSecond: math.Round(float64(merged.Counters.Total)/d.Seconds()*100) / 100,
+	}
+	if merged.Counters.Total > 0 {
+		res.ErrorRate = math.Round(float64(merged.Counters.IOError)/float64(merged.Counters.Total)*10000) / 10000
+	}
+	var p50, p95, p99 time.Duration
+	if merged.Hist != nil {
+		res.LatencyStats = newLatencyStats(merged.Hist)
+		p50 = time.Duration(merged.Hist.ValueAtQuantile(50))
+		p95 = time.Duration(merged.Hist.ValueAtQuantile(95))
+		p99 = time.Duration(merged.Hist.ValueAtQuantile(99))
+	}
+	return res, []string{
+		strconv.FormatInt(res.TotalRequests, 10),
+		fmt.Sprintf("%0.1f", res.QueriesPerSecond),
+		roundDuration(p50).String(),
+		roundDuration(p95).String(),
+		roundDuration(p99).String(),
+		strconv.FormatInt(res.TotalErrors, 10),
+		fmt.Sprintf("%0.2f%%", res.ErrorRate*100),
+	}
+}
+
+// RunServers executes the benchmark sequentially against each of the provided servers, the Server field of the benchmark is ignored.
+// If the benchmark is cancelled, the results of the servers benchmarked so far are returned.
+func (b *Benchmark) RunServers(ctx context.Context, servers []string) ([]ServerResult, error) {
+	var results []ServerResult
+	for _, server := range servers {
+		bench := *b
+		bench.Server = server
+
+		start := time.Now()
+		stats, err := bench.Run(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to benchmark server '%s': %w", server, err)
+		}
+		results = append(results, ServerResult{Server: bench.Server, Stats: stats, Duration: time.Since(start) - bench.warmupDuration})
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return results, nil
+}
+
+// PrintComparison prints side-by-side comparison of the benchmarked servers. The CSV export and the plots are written for each server,
+// the CSV file name is suffixed by the server and the plots are saved into a subdirectory of the plot directory named by the server.
+func (b *Benchmark) PrintComparison(w io.Writer, results []ServerResult) error {
+	for _, r := range results {
+		if err := b.exportServer(r); err != nil {
+			return err
+		}
+	}
+
+	if b.Silent {
+		return nil
+	}
+
+	var jsonResults []jsonServerResult
+	lines := make([][]string, 0, len(results))
+	for _, r := range results {
+		summary, row := summarize(r.Stats, r.Duration)
+		jsonResults = append(jsonResults, jsonServerResult{Server: r.Server, jsonSummary: summary})
+		lines = append(lines, append([]string{r.Server}, row...))
+	}
+
+	if b.JSON {
+		return json.NewEncoder(w).Encode(jsonResults)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Comparison of", highlightStr(len(results)), "servers:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Server", "Requests", "QPS", "p50", "p95", "p99", "Errors", "Error rate"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+	return nil
+}
+
+// exportServer writes the CSV export and the plots of the results of the single server.
+func (b *Benchmark) exportServer(r ServerResult) error {
+	if b.Csv == "" && b.PlotDir == "" {
+		return nil
+	}
+	bench := *b
+	bench.Server = r.Server
+	merged := Merge(r.Stats)
+	if merged.Hist == nil {
+		merged.Hist = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+	}
+	suffix := serverFileSuffix(r.Server)
+
+	if b.PlotDir != "" {
+		dir := filepath.Join(b.PlotDir, suffix)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create plot directory of server '%s' due to '%v'", r.Server, err)
+		}
+		bench.plot(dir, merged)
+	}
+
+	if b.Csv != "" {
+		ext := filepath.Ext(b.Csv)
+		return bench.exportCsv(strings.TrimSuffix(b.Csv, ext)+"-"+suffix+ext, merged.Hist)
+	}
+	return nil
+}
+
+// serverFileSuffix returns the server address usable in file names, the characters other than letters, digits, dots and dashes are replaced by underscores.
+func serverFileSuffix(server string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, server)
+}
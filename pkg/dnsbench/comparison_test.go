@@ -0,0 +1,118 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_RunServers(t *testing.T) {
+	handler := func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		w.WriteMsg(ret)
+	}
+	s1 := NewServer(udp, handler)
+	defer s1.Close()
+	s2 := NewServer(udp, handler)
+	defer s2.Close()
+
+	bench := createBenchmark("", false, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	results, err := bench.RunServers(ctx, []string{s1.Addr, s2.Addr})
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, results, 2)
+	assert.Equal(t, s1.Addr, results[0].Server)
+	assert.Equal(t, s2.Addr, results[1].Server)
+	for _, r := range results {
+		assertResult(t, r.Stats)
+		assert.NotZero(t, r.Duration)
+	}
+}
+
+func TestBenchmark_PrintComparison_outputs(t *testing.T) {
+	dir := t.TempDir()
+	b := Benchmark{
+		Csv:        filepath.Join(dir, "out.csv"),
+		PlotDir:    dir,
+		PlotFormat: "png",
+		Silent:     true,
+		HistMin:    0,
+		HistMax:    time.Second,
+		HistPre:    1,
+	}
+
+	results := comparisonTestData()
+	start := time.Now()
+	for _, r := range results {
+		r.Stats[0].Timings = []Datapoint{{Duration: 10, Start: start}, {Duration: 20, Start: start.Add(time.Second)}}
+	}
+
+	err := b.PrintComparison(&bytes.Buffer{}, results)
+
+	require.NoError(t, err)
+	for _, server := range []string{"127.0.0.1_53", "127.0.0.2_53"} {
+		assert.FileExists(t, filepath.Join(dir, "out-"+server+".csv"), "CSV export of each server")
+		graphs, err := filepath.Glob(filepath.Join(dir, server, "graphs-*", "latency-histogram.png"))
+		require.NoError(t, err)
+		assert.Len(t, graphs, 1, "plots of each server")
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "out.csv"))
+}
+
+func TestBenchmark_PrintComparison(t *testing.T) {
+	b := Benchmark{}
+	var buf bytes.Buffer
+
+	err := b.PrintComparison(&buf, comparisonTestData())
+
+	require.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "Comparison of 2 servers:")
+	assert.Regexp(t, `127\.0\.0\.1:53 \|\s+2 \| 2\.0 \| 10\.\d+ms \| 10\.\d+ms \| 10\.\d+ms \|\s+1 \| 50\.00%`, out)
+	assert.Regexp(t, `127\.0\.0\.2:53 \|\s+1 \| 1\.0 \| 20\.\d+ms \| 20\.\d+ms \| 20\.\d+ms \|\s+0 \| 0\.00%`, out)
+}
+
+func ExampleBenchmark_PrintComparison_json() {
+	b := Benchmark{JSON: true}
+
+	b.PrintComparison(os.Stdout, comparisonTestData())
+
+	// Output: [{"server":"127.0.0.1:53","totalRequests":2,"totalErrors":1,"errorRate":0.5,"queriesPerSecond":2,"latencyStats":{"minMs":9,"meanMs":10,"stdMs":0,"maxMs":10,"p99Ms":10,"p95Ms":10,"p90Ms":10,"p75Ms":10,"p50Ms":10}},{"server":"127.0.0.2:53","totalRequests":1,"totalErrors":0,"errorRate":0,"queriesPerSecond":1,"latencyStats":{"minMs":19,"meanMs":20,"stdMs":0,"maxMs":20,"p99Ms":20,"p95Ms":20,"p90Ms":20,"p75Ms":20,"p50Ms":20}}]
+}
+
+func comparisonTestData() []ServerResult {
+	h1 := hdrhistogram.New(0, int64(time.Second), 1)
+	h1.RecordValue(int64(10 * time.Millisecond))
+	h2 := hdrhistogram.New(0, int64(time.Second), 1)
+	h2.RecordValue(int64(20 * time.Millisecond))
+
+	return []ServerResult{
+		{
+			Server:   "127.0.0.1:53",
+			Stats:    []*ResultStats{{Hist: h1, Counters: &Counters{Total: 2, IOError: 1, Success: 1}}},
+			Duration: time.Second,
+		},
+		{
+			Server:   "127.0.0.2:53",
+			Stats:    []*ResultStats{{Hist: h2, Counters: &Counters{Total: 1, Success: 1}}},
+			Duration: time.Second,
+		},
+	}
+}
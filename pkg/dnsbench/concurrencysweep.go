@@ -0,0 +1,276 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// sweepEllipsis in the list of the concurrency levels continues doubling the previous level up to the next level.
+const sweepEllipsis = "..."
+
+// ConcurrencyLevel is the result of the benchmark of a single concurrency level of the concurrency sweep.
+type ConcurrencyLevel struct {
+	Concurrency      uint32
+	Requests         int64
+	QueriesPerSecond float64
+	P50              time.Duration
+	P95              time.Duration
+	P99              time.Duration
+	ErrorRate        float64
+}
+
+type jsonConcurrencyLevel struct {
+	Concurrency      uint32  `json:"concurrency"`
+	Requests         int64   `json:"requests"`
+	QueriesPerSecond float64 `json:"queriesPerSecond"`
+	P50Ms            float64 `json:"p50Ms"`
+	P95Ms            float64 `json:"p95Ms"`
+	P99Ms            float64 `json:"p99Ms"`
+	ErrorRate        float64 `json:"errorRate"`
+}
+
+type jsonConcurrencySweep struct {
+	Levels []jsonConcurrencyLevel `json:"levels"`
+}
+
+// ParseConcurrencySweep parses comma separated increasing concurrency levels, for example 1,2,4,8. The levels can be abbreviated
+// using ellipsis, which continues doubling the previous level up to the next level, for example 1,2,4,...,256.
+func ParseConcurrencySweep(sweep string) ([]uint32, error) {
+	var levels []uint32
+	var ellipsis bool
+	for _, s := range strings.Split(sweep, ",") {
+		s = strings.TrimSpace(s)
+		if s == sweepEllipsis {
+			if len(levels) == 0 || ellipsis {
+				return nil, fmt.Errorf("invalid concurrency sweep '%s', ellipsis has to follow a concurrency level", sweep)
+			}
+			ellipsis = true
+			continue
+		}
+		l, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || l == 0 {
+			return nil, fmt.Errorf("invalid concurrency level '%s'", s)
+		}
+		level := uint32(l)
+		if len(levels) > 0 && level <= levels[len(levels)-1] {
+			return nil, fmt.Errorf("concurrency levels have to be increasing, %d follows %d", level, levels[len(levels)-1])
+		}
+		if ellipsis {
+			for next := uint64(levels[len(levels)-1]) * 2; next < uint64(level); next *= 2 {
+				levels = append(levels, uint32(next))
+			}
+			ellipsis = false
+		}
+		levels = append(levels, level)
+	}
+	if ellipsis {
+		return nil, fmt.Errorf("invalid concurrency sweep '%s', ellipsis has to be followed by the last concurrency level", sweep)
+	}
+	return levels, nil
+}
+
+func (b *Benchmark) validateConcurrencySweep(levels []uint32) error {
+	if len(levels) == 0 {
+		return errors.New("--concurrency-sweep requires at least one concurrency level")
+	}
+	if b.AutoConcurrency || b.Batch > 1 {
+		return errors.New("--concurrency-sweep cannot be combined with --auto-concurrency or --batch options")
+	}
+	return nil
+}
+
+// SweepConcurrency executes the benchmark with each of the concurrency levels one after another, so that the throughput and latencies
+// of the server can be plotted against each other. If the sweep is cancelled, the results of the levels executed so far are returned.
+func (b *Benchmark) SweepConcurrency(ctx context.Context, levels []uint32) ([]ConcurrencyLevel, error) {
+	if err := b.validateConcurrencySweep(levels); err != nil {
+		return nil, err
+	}
+
+	var res []ConcurrencyLevel
+	for _, c := range levels {
+		level, err := b.concurrencyLevel(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		res = append(res, level)
+		if !b.Silent && !b.JSON {
+			fmt.Printf("Concurrency %s: %s QPS, p50 %s, p99 %s, error rate %s\n", highlightStr(c), highlightStr(fmt.Sprintf("%0.1f", level.QueriesPerSecond)),
+				highlightStr(roundDuration(level.P50)), highlightStr(roundDuration(level.P99)), highlightStr(fmt.Sprintf("%0.2f%%", level.ErrorRate*100)))
+		}
+	}
+	return res, nil
+}
+
+// concurrencyLevel benchmarks the workload with the concurrency.
+func (b *Benchmark) concurrencyLevel(ctx context.Context, concurrency uint32) (ConcurrencyLevel, error) {
+	bench := *b
+	bench.Concurrency = concurrency
+	bench.Silent = true
+
+	start := time.Now()
+	stats, err := bench.Run(ctx)
+	if err != nil {
+		return ConcurrencyLevel{}, fmt.Errorf("failed to benchmark concurrency %d: %w", concurrency, err)
+	}
+	d := time.Since(start) - bench.warmupDuration
+
+	merged := Merge(stats)
+	level := ConcurrencyLevel{
+		Concurrency:      concurrency,
+		Requests:         merged.Counters.Total,
+		QueriesPerSecond: math.Round(float64(merged.Counters.Total)/d.Seconds()*100) / 100,
+	}
+	if merged.Hist != nil && merged.Hist.TotalCount() > 0 {
+		level.P50 = time.Duration(merged.Hist.ValueAtQuantile(50))
+		level.P95 = time.Duration(merged.Hist.ValueAtQuantile(95))
+		level.P99 = time.Duration(merged.Hist.ValueAtQuantile(99))
+	}
+	if merged.Counters.Total > 0 {
+		level.ErrorRate = float64(merged.Counters.IOError) / float64(merged.Counters.Total)
+	}
+	return level, nil
+}
+
+// PrintConcurrencySweep prints the throughput and latencies of the concurrency levels, the throughput-latency curve is plotted
+// when the plots are enabled, see Benchmark.PlotDir.
+func (b *Benchmark) PrintConcurrencySweep(w io.Writer, levels []ConcurrencyLevel) error {
+	if !b.Silent {
+		if err := b.printConcurrencySweep(w, levels); err != nil {
+			return err
+		}
+	}
+	if len(b.PlotDir) != 0 {
+		return b.plotConcurrencySweep(levels)
+	}
+	return nil
+}
+
+func (b *Benchmark) printConcurrencySweep(w io.Writer, levels []ConcurrencyLevel) error {
+	if b.JSON {
+		j := jsonConcurrencySweep{Levels: make([]jsonConcurrencyLevel, 0, len(levels))}
+		for _, l := range levels {
+			j.Levels = append(j.Levels, jsonConcurrencyLevel{
+				Concurrency:      l.Concurrency,
+				Requests:         l.Requests,
+				QueriesPerSecond: l.QueriesPerSecond,
+				P50Ms:            float64(l.P50) / float64(time.Millisecond),
+				P95Ms:            float64(l.P95) / float64(time.Millisecond),
+				P99Ms:            float64(l.P99) / float64(time.Millisecond),
+				ErrorRate:        math.Round(l.ErrorRate*10000) / 10000,
+			})
+		}
+		return json.NewEncoder(w).Encode(j)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Throughput and latency by concurrency:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Concurrency", "Requests", "QPS", "p50", "p95", "p99", "Error rate"})
+	table.SetBorder(false)
+	for _, l := range levels {
+		table.Append([]string{fmt.Sprint(l.Concurrency), fmt.Sprint(l.Requests), fmt.Sprintf("%0.1f", l.QueriesPerSecond),
+			roundDuration(l.P50).String(), roundDuration(l.P95).String(), roundDuration(l.P99).String(), fmt.Sprintf("%0.2f%%", l.ErrorRate*100)})
+	}
+	table.Render()
+
+	if best := peakThroughput(levels); best != nil {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Peak throughput: %s QPS at concurrency %s (p99 %s)\n", highlightStr(fmt.Sprintf("%0.1f", best.QueriesPerSecond)),
+			highlightStr(best.Concurrency), highlightStr(roundDuration(best.P99)))
+	}
+	return nil
+}
+
+// plotConcurrencySweep saves the throughput-latency curve into a new directory of the plots.
+func (b *Benchmark) plotConcurrencySweep(levels []ConcurrencyLevel) error {
+	dir := fmt.Sprintf("%s/graphs-%s", b.PlotDir, time.Now().Format(time.RFC3339))
+	if err := os.Mkdir(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for plots due to '%v'", err)
+	}
+	file := b.fileName(dir, "concurrency-sweep")
+	var err error
+	if b.PlotFormat == htmlFormat {
+		err = plotHTMLConcurrencySweep(file, b.Server, levels)
+	} else {
+		err = plotLineConcurrencySweep(file, levels)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save plot '%s' due to '%v'", file, err)
+	}
+	return nil
+}
+
+// peakThroughput returns the concurrency level with the highest throughput, nil is returned when there are no levels.
+func peakThroughput(levels []ConcurrencyLevel) *ConcurrencyLevel {
+	var best *ConcurrencyLevel
+	for i := range levels {
+		if best == nil || levels[i].QueriesPerSecond > best.QueriesPerSecond {
+			best = &levels[i]
+		}
+	}
+	return best
+}
+
+// plotLineConcurrencySweep plots the latency percentiles against the throughput of the concurrency levels, the points of p99 are labeled
+// by the concurrency.
+func plotLineConcurrencySweep(file string, levels []ConcurrencyLevel) error {
+	percentiles := []struct {
+		name  string
+		value func(l ConcurrencyLevel) time.Duration
+		color color.Color
+	}{
+		{"p50", func(l ConcurrencyLevel) time.Duration { return l.P50 }, plotutil.Color(0)},
+		{"p95", func(l ConcurrencyLevel) time.Duration { return l.P95 }, plotutil.Color(2)},
+		{"p99", func(l ConcurrencyLevel) time.Duration { return l.P99 }, color.RGBA{R: 255, A: 255}},
+	}
+
+	p := plot.New()
+	p.Title.Text = "Latency by throughput"
+	p.X.Label.Text = "Throughput (QPS)"
+	p.X.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.Y.Label.Text = "Latency (ms)"
+	p.Y.Tick.Marker = hplot.Ticks{N: 10, Format: "%.1f"}
+
+	var p99 plotter.XYs
+	for _, pc := range percentiles {
+		values := make(plotter.XYs, 0, len(levels))
+		for _, l := range levels {
+			values = append(values, plotter.XY{X: l.QueriesPerSecond, Y: float64(pc.value(l)) / float64(time.Millisecond)})
+		}
+		plotLine(p, values, pc.color, pc.name)
+		// p99 is the last of the percentiles
+		p99 = values
+	}
+
+	labels := make([]string, 0, len(levels))
+	for _, l := range levels {
+		labels = append(labels, "c="+strconv.FormatUint(uint64(l.Concurrency), 10))
+	}
+	l, err := plotter.NewLabels(plotter.XYLabels{XYs: p99, Labels: labels})
+	if err != nil {
+		return err
+	}
+	p.Add(l)
+	p.Legend.Top = true
+
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
@@ -0,0 +1,121 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConcurrencySweep(t *testing.T) {
+	tests := []struct {
+		sweep   string
+		want    []uint32
+		wantErr bool
+	}{
+		{sweep: "1,2,4,8", want: []uint32{1, 2, 4, 8}},
+		{sweep: "1, 2, 4, ..., 64", want: []uint32{1, 2, 4, 8, 16, 32, 64}},
+		{sweep: "10,...,100", want: []uint32{10, 20, 40, 80, 100}},
+		{sweep: "1,...,2", want: []uint32{1, 2}},
+		{sweep: "1,0", wantErr: true},
+		{sweep: "4,2", wantErr: true},
+		{sweep: "...,8", wantErr: true},
+		{sweep: "1,...", wantErr: true},
+		{sweep: "1,...,...,8", wantErr: true},
+		{sweep: "1,abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sweep, func(t *testing.T) {
+			got, err := ParseConcurrencySweep(tt.sweep)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBenchmark_SweepConcurrency(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Silent = true
+
+	res, err := bench.SweepConcurrency(context.Background(), []uint32{1, 2, 4})
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	for _, l := range res {
+		// each worker sends both A and AAAA queries
+		assert.Equal(t, int64(l.Concurrency)*2, l.Requests)
+		assert.Positive(t, l.QueriesPerSecond)
+		assert.Positive(t, l.P99)
+		assert.Zero(t, l.ErrorRate)
+	}
+	assert.Equal(t, uint32(2), bench.Concurrency, "concurrency of the benchmark is not changed by the sweep")
+}
+
+func TestBenchmark_SweepConcurrency_invalid(t *testing.T) {
+	b := Benchmark{AutoConcurrency: true}
+	_, err := b.SweepConcurrency(context.Background(), []uint32{1, 2})
+	assert.EqualError(t, err, "--concurrency-sweep cannot be combined with --auto-concurrency or --batch options")
+
+	_, err = (&Benchmark{}).SweepConcurrency(context.Background(), nil)
+	assert.Error(t, err)
+}
+
+func TestBenchmark_PrintConcurrencySweep(t *testing.T) {
+	levels := []ConcurrencyLevel{
+		{Concurrency: 1, Requests: 100, QueriesPerSecond: 1000, P50: time.Millisecond, P95: 2 * time.Millisecond, P99: 3 * time.Millisecond},
+		{Concurrency: 2, Requests: 200, QueriesPerSecond: 1900, P50: time.Millisecond, P95: 3 * time.Millisecond, P99: 5 * time.Millisecond},
+		{Concurrency: 4, Requests: 400, QueriesPerSecond: 1800, P50: 2 * time.Millisecond, P95: 9 * time.Millisecond, P99: 20 * time.Millisecond, ErrorRate: 0.05},
+	}
+
+	t.Run("std", func(t *testing.T) {
+		b := Benchmark{}
+		buf := bytes.Buffer{}
+
+		require.NoError(t, b.PrintConcurrencySweep(&buf, levels))
+
+		assert.Contains(t, buf.String(), "Throughput and latency by concurrency:")
+		assert.Contains(t, buf.String(), "Peak throughput: 1900.0 QPS at concurrency 2 (p99 5ms)")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		b := Benchmark{JSON: true}
+		buf := bytes.Buffer{}
+
+		require.NoError(t, b.PrintConcurrencySweep(&buf, levels))
+
+		var j jsonConcurrencySweep
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &j))
+		require.Len(t, j.Levels, 3)
+		assert.Equal(t, uint32(4), j.Levels[2].Concurrency)
+		assert.Equal(t, float64(20), j.Levels[2].P99Ms)
+		assert.Equal(t, 0.05, j.Levels[2].ErrorRate)
+	})
+
+	for _, format := range []string{"png", htmlFormat} {
+		t.Run("plot "+format, func(t *testing.T) {
+			dir := t.TempDir()
+			b := Benchmark{Silent: true, PlotDir: dir, PlotFormat: format}
+
+			require.NoError(t, b.PrintConcurrencySweep(&bytes.Buffer{}, levels))
+
+			files, err := filepath.Glob(filepath.Join(dir, "graphs-*", "concurrency-sweep."+format))
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			info, err := os.Stat(files[0])
+			require.NoError(t, err)
+			assert.Positive(t, info.Size())
+		})
+	}
+}
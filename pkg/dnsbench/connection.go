@@ -0,0 +1,177 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/quic-go/quic-go"
+)
+
+// ConnectionStats are statistics of TCP, TLS and QUIC connections established by the benchmark workers. The connection setup time
+// is not included in the latencies of the queries, which caused the connections to be established.
+type ConnectionStats struct {
+	Setup *hdrhistogram.Histogram
+	// ProxySetup holds durations of establishing the tunnels through the proxy, which are part of the connection setup, it is set only when the proxy is used.
+	ProxySetup *hdrhistogram.Histogram
+	Count      int64
+	// Workers is a number of benchmark workers, which established the connections.
+	Workers int64
+}
+
+func (c *ConnectionStats) add(o *ConnectionStats) {
+	if o.Setup != nil {
+		if c.Setup == nil {
+			c.Setup = hdrhistogram.New(o.Setup.LowestTrackableValue(), o.Setup.HighestTrackableValue(), int(o.Setup.SignificantFigures()))
+		}
+		c.Setup.Merge(o.Setup)
+	}
+	if o.ProxySetup != nil {
+		if c.ProxySetup == nil {
+			c.ProxySetup = hdrhistogram.New(o.ProxySetup.LowestTrackableValue(), o.ProxySetup.HighestTrackableValue(), int(o.ProxySetup.SignificantFigures()))
+		}
+		c.ProxySetup.Merge(o.ProxySetup)
+	}
+	c.Count += o.Count
+	c.Workers += o.Workers
+}
+
+type connTimerKey struct{}
+
+// connection is an established connection observed by connTimer.
+type connection struct {
+	remote net.Addr
+	start  time.Time
+	setup  time.Duration
+	proxy  time.Duration
+	family string
+}
+
+// connTimer collects connections established while sending a single query. Connections of shared DoH transport
+// might be established by other goroutines, so the timer is safe for concurrent use.
+type connTimer struct {
+	mu    sync.Mutex
+	conns []connection
+}
+
+func withConnTimer(ctx context.Context) (context.Context, *connTimer) {
+	t := &connTimer{}
+	return context.WithValue(ctx, connTimerKey{}, t), t
+}
+
+// observeConnection records setup duration and address family of the connection to the timer carried by the context, if any.
+func observeConnection(ctx context.Context, remote net.Addr, d time.Duration) {
+	observeProxiedConnection(ctx, remote, d, 0)
+}
+
+// observeProxiedConnection records the connection established through the proxy together with the duration of establishing the tunnel.
+func observeProxiedConnection(ctx context.Context, remote net.Addr, d, proxy time.Duration) {
+	if t, ok := ctx.Value(connTimerKey{}).(*connTimer); ok {
+		t.mu.Lock()
+		t.conns = append(t.conns, connection{remote: remote, start: time.Now().Add(-d), setup: d, proxy: proxy, family: addressFamily(remote)})
+		t.mu.Unlock()
+	}
+}
+
+func addressFamily(addr net.Addr) string {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	}
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return "IPv4"
+	default:
+		return "IPv6"
+	}
+}
+
+func (t *connTimer) take() []connection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.conns
+	t.conns = nil
+	return c
+}
+
+// recordConnections records address families and setup durations of the connections and returns the total setup duration,
+// which should be excluded from the latency of the query. Setup durations are not recorded if the connections are not tracked.
+func (rs *ResultStats) recordConnections(conns []connection) time.Duration {
+	var total time.Duration
+	for _, c := range conns {
+		if c.family != "" && rs.Families != nil {
+			rs.Families[c.family]++
+		}
+		if rs.Connections != nil {
+			rs.Connections.Count++
+			rs.Connections.Setup.RecordValue(c.setup.Nanoseconds())
+			if c.proxy > 0 && rs.Connections.ProxySetup != nil {
+				rs.Connections.ProxySetup.RecordValue(c.proxy.Nanoseconds())
+			}
+			total += c.setup
+		}
+	}
+	return total
+}
+
+func (b *Benchmark) dialTCP(ctx context.Context, network, addr string) (net.Conn, error) {
+	start := time.Now()
+	if b.proxy != nil {
+		conn, err := b.dialProxy(ctx, addr)
+		if err == nil {
+			observeProxiedConnection(ctx, conn.RemoteAddr(), time.Since(start), time.Since(start))
+		}
+		return conn, err
+	}
+	network = b.network(network)
+	conn, err := b.dialer(network).DialContext(ctx, network, addr)
+	if err == nil {
+		observeConnection(ctx, conn.RemoteAddr(), time.Since(start))
+	}
+	return conn, err
+}
+
+func (b *Benchmark) dialTLS(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+	start := time.Now()
+	if b.proxy != nil {
+		conn, proxySetup, err := b.dialProxyTLS(ctx, addr, cfg)
+		if err == nil {
+			observeProxiedConnection(ctx, conn.RemoteAddr(), time.Since(start), proxySetup)
+		}
+		return conn, err
+	}
+	network = b.network(network)
+	dialer := tls.Dialer{NetDialer: b.dialer(network), Config: cfg}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err == nil {
+		observeConnection(ctx, conn.RemoteAddr(), time.Since(start))
+	}
+	return conn, err
+}
+
+// dialQUIC dials QUIC connection and waits for the handshake to complete, so that the handshake is included in the connection setup.
+func (b *Benchmark) dialQUIC(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	start := time.Now()
+	udpAddr, err := net.ResolveUDPAddr(b.network("udp"), addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := quic.DialAddrEarly(ctx, udpAddr.String(), tlsCfg, cfg)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-conn.HandshakeComplete():
+		observeConnection(ctx, conn.RemoteAddr(), time.Since(start))
+	case <-ctx.Done():
+	}
+	return conn, nil
+}
@@ -0,0 +1,134 @@
+package dnsbench
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_do_classic_dns_tcp_connections(t *testing.T) {
+	tests := []struct {
+		name       string
+		qperConn   int64
+		wantCounts int64
+	}{
+		{name: "reused connection", qperConn: 0, wantCounts: 1},
+		{name: "connection per query", qperConn: 1, wantCounts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(tcp, func(w dns.ResponseWriter, r *dns.Msg) {
+				ret := new(dns.Msg)
+				ret.SetReply(r)
+				w.WriteMsg(ret)
+			})
+			defer s.Close()
+
+			bench := createBenchmark(s.Addr, true, 1)
+			bench.QperConn = tt.qperConn
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 2, "Run(ctx) rstats")
+			for _, r := range rs {
+				assert.Equal(t, int64(2), r.Counters.Success, "Run(ctx) success counter")
+				if assert.NotNil(t, r.Connections, "Run(ctx) connections") {
+					assert.Equal(t, tt.wantCounts, r.Connections.Count, "Run(ctx) connections counter")
+					assert.Equal(t, tt.wantCounts, r.Connections.Setup.TotalCount(), "Run(ctx) connection setup datapoints")
+				}
+			}
+
+			merged := Merge(rs)
+			assert.Equal(t, 2*tt.wantCounts, merged.Connections.Count, "Merge(rs) connections counter")
+			assert.Equal(t, int64(2), merged.Connections.Workers, "Merge(rs) connections workers")
+		})
+	}
+}
+
+func Test_do_classic_dns_udp_connections(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	for _, r := range rs {
+		assert.Nil(t, r.Connections, "Run(ctx) connections")
+	}
+}
+
+func Test_do_doh_connections(t *testing.T) {
+	tests := []struct {
+		name       string
+		fresh      bool
+		wantCounts int64
+	}{
+		{name: "shared transport", wantCounts: 1},
+		{name: "fresh connection per query", fresh: true, wantCounts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bd, err := io.ReadAll(r.Body)
+				if err != nil {
+					panic(err)
+				}
+				msg := dns.Msg{}
+				if err := msg.Unpack(bd); err != nil {
+					panic(err)
+				}
+				pack, err := msg.Pack()
+				if err != nil {
+					panic(err)
+				}
+				w.Write(pack)
+			}))
+			defer ts.Close()
+
+			bench := createBenchmark(ts.URL, true, 1)
+			bench.DohMethod = post
+			bench.Concurrency = 1
+			bench.FreshConnection = tt.fresh
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 1, "Run(ctx) rstats")
+			assert.Equal(t, int64(2), rs[0].Counters.Success, "Run(ctx) success counter")
+			if assert.NotNil(t, rs[0].Connections, "Run(ctx) connections") {
+				assert.Equal(t, tt.wantCounts, rs[0].Connections.Count, "Run(ctx) connections counter")
+			}
+		})
+	}
+}
+
+func TestBenchmark_normalize_fresh_connection(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", FreshConnection: true}
+	require.NoError(t, b.normalize())
+	assert.Equal(t, int64(1), b.QperConn)
+
+	b = Benchmark{Server: "8.8.8.8", FreshConnection: true, QperConn: 10}
+	assert.Error(t, b.normalize())
+}
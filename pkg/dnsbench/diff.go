@@ -0,0 +1,94 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// diffLogger logs disagreeing answers of compared servers, it is safe for concurrent use by benchmark workers.
+type diffLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *diffLogger) log(server, diffServer string, resp, diffResp *dns.Msg) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	q := resp.Question[0]
+	fmt.Fprintf(l.w, ";; disagreement for %s %s\n", q.Name, dns.TypeToString[q.Qtype])
+	writeAnswer(l.w, server, resp)
+	writeAnswer(l.w, diffServer, diffResp)
+	fmt.Fprintln(l.w)
+}
+
+func writeAnswer(w io.Writer, server string, resp *dns.Msg) {
+	fmt.Fprintf(w, ";; %s answered %s\n", server, dns.RcodeToString[resp.Rcode])
+	for _, rr := range resp.Answer {
+		fmt.Fprintln(w, rr.String())
+	}
+}
+
+// compare sends the request also to the DiffServer and compares its answer with the response from the Server.
+func (b *Benchmark) compare(ctx context.Context, query queryFunc, req, resp *dns.Msg, st *ResultStats, diffLog *diffLogger) {
+	st.Diff.Counters.Total++
+	st.Diff.step = st.step
+
+	start := time.Now()
+
+	reqTimeoutCtx, cancel := context.WithTimeout(ctx, b.RequestTimeout)
+	defer cancel()
+	diffResp, err := query(reqTimeoutCtx, b.DiffServer, req)
+	if err != nil {
+		if ended(ctx) {
+			st.Diff.Counters.Total--
+			return
+		}
+		st.Diff.recordError(err)
+		return
+	}
+	st.Diff.record(req, diffResp, start, time.Since(start))
+
+	if sameAnswers(resp, diffResp) {
+		st.Counters.Agreements++
+		return
+	}
+	st.Counters.Disagreements++
+	diffLog.log(b.Server, b.DiffServer, resp, diffResp)
+}
+
+// sameAnswers checks whether the responses have the same response code and answer sections, ordering of records and TTLs are ignored.
+func sameAnswers(a, b *dns.Msg) bool {
+	if a.Rcode != b.Rcode || len(a.Answer) != len(b.Answer) {
+		return false
+	}
+	aRecords := normalizedAnswer(a)
+	bRecords := normalizedAnswer(b)
+	for i := range aRecords {
+		if aRecords[i] != bRecords[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizedAnswer(m *dns.Msg) []string {
+	records := make([]string, 0, len(m.Answer))
+	for _, rr := range m.Answer {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = 0
+		records = append(records, strings.ToLower(rr.String()))
+	}
+	sort.Strings(records)
+	return records
+}
@@ -0,0 +1,63 @@
+package dnsbench
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sameAnswers(t *testing.T) {
+	tests := []struct {
+		name string
+		a    *dns.Msg
+		b    *dns.Msg
+		want bool
+	}{
+		{
+			name: "same answers",
+			a:    answer(dns.RcodeSuccess, "example.org. 60 IN A 127.0.0.1"),
+			b:    answer(dns.RcodeSuccess, "example.org. 60 IN A 127.0.0.1"),
+			want: true,
+		},
+		{
+			name: "different TTLs and ordering",
+			a:    answer(dns.RcodeSuccess, "example.org. 60 IN A 127.0.0.1", "example.org. 60 IN A 127.0.0.2"),
+			b:    answer(dns.RcodeSuccess, "EXAMPLE.org. 30 IN A 127.0.0.2", "example.org. 10 IN A 127.0.0.1"),
+			want: true,
+		},
+		{
+			name: "different records",
+			a:    answer(dns.RcodeSuccess, "example.org. 60 IN A 127.0.0.1"),
+			b:    answer(dns.RcodeSuccess, "example.org. 60 IN A 127.0.0.2"),
+			want: false,
+		},
+		{
+			name: "different number of records",
+			a:    answer(dns.RcodeSuccess, "example.org. 60 IN A 127.0.0.1"),
+			b:    answer(dns.RcodeSuccess, "example.org. 60 IN A 127.0.0.1", "example.org. 60 IN A 127.0.0.2"),
+			want: false,
+		},
+		{
+			name: "different rcodes",
+			a:    answer(dns.RcodeSuccess),
+			b:    answer(dns.RcodeNameError),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sameAnswers(tt.a, tt.b))
+		})
+	}
+}
+
+func answer(rcode int, records ...string) *dns.Msg {
+	m := dns.Msg{}
+	m.Rcode = rcode
+	for _, r := range records {
+		rr, _ := dns.NewRR(r)
+		m.Answer = append(m.Answer, rr)
+	}
+	return &m
+}
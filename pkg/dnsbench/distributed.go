@@ -0,0 +1,440 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const workerPath = "/benchmark"
+
+// remoteStats is a serializable representation of ResultStats exchanged between the coordinator and the workers.
+type remoteStats struct {
+	Codes           map[int]int64                     `json:"codes,omitempty"`
+	Qtypes          map[string]int64                  `json:"qtypes,omitempty"`
+	TTLs            map[string]*TTLStats              `json:"ttls,omitempty"`
+	Sizes           map[string]*remoteSizes           `json:"sizes,omitempty"`
+	QtypeSizes      map[string]*remoteSizes           `json:"qtypeSizes,omitempty"`
+	Hist            *hdrhistogram.Snapshot            `json:"hist,omitempty"`
+	QtypeHists      map[string]*hdrhistogram.Snapshot `json:"qtypeHists,omitempty"`
+	FlagHists       map[string]*hdrhistogram.Snapshot `json:"flagHists,omitempty"`
+	CNAMEDepthHists map[int]*hdrhistogram.Snapshot    `json:"cnameDepthHists,omitempty"`
+	FailoverHists   map[string]*hdrhistogram.Snapshot `json:"failoverHists,omitempty"`
+	BurstHists      map[int]*hdrhistogram.Snapshot    `json:"burstHists,omitempty"`
+	RcodeHists      map[int]*hdrhistogram.Snapshot    `json:"rcodeHists,omitempty"`
+	DoHMethodHists  map[string]*hdrhistogram.Snapshot `json:"dohMethodHists,omitempty"`
+	IdentityHists   map[string]*hdrhistogram.Snapshot `json:"identityHists,omitempty"`
+	ResponderHists  map[string]*hdrhistogram.Snapshot `json:"responderHists,omitempty"`
+	CacheHists      map[string]*hdrhistogram.Snapshot `json:"cacheHists,omitempty"`
+	FirstByteHists  map[string]*hdrhistogram.Snapshot `json:"firstByteHists,omitempty"`
+	EDNSSizeHists   map[int]*hdrhistogram.Snapshot    `json:"ednsSizeHists,omitempty"`
+	IntervalHists   map[int64]*hdrhistogram.Snapshot  `json:"intervalHists,omitempty"`
+	Timings         []Datapoint                       `json:"timings,omitempty"`
+	Counters        *Counters                         `json:"counters,omitempty"`
+	Errors          []string                          `json:"errors,omitempty"`
+	ErrorTimes      []time.Time                       `json:"errorTimes,omitempty"`
+	DatapointWeight float64                           `json:"datapointWeight,omitempty"`
+	DoHProtocols    map[string]int64                  `json:"dohProtocols,omitempty"`
+	DoH             *DoHStats                         `json:"doh,omitempty"`
+	Attempts        map[int]int64                     `json:"attempts,omitempty"`
+	Families        map[string]int64                  `json:"families,omitempty"`
+	Keepalives      map[string]int64                  `json:"keepaliveTimeouts,omitempty"`
+	Unanswered      map[string]int64                  `json:"failoverUnanswered,omitempty"`
+	Bursts          map[int64]*BurstStats             `json:"bursts,omitempty"`
+	RRL             map[int64]*RRLStats               `json:"rrl,omitempty"`
+	EDNSSizes       map[int]*EDNSSizeStats            `json:"ednsSizes,omitempty"`
+	Malformed       map[string]map[string]int64       `json:"malformed,omitempty"`
+	ErrorCategories map[string]int64                  `json:"errorCategories,omitempty"`
+	HookErrors      map[string]int64                  `json:"hookErrors,omitempty"`
+	Connections     *remoteConnections                `json:"connections,omitempty"`
+	Transfer        *remoteTransfer                   `json:"transfer,omitempty"`
+	Dual            *remoteDual                       `json:"dual,omitempty"`
+	Search          *remoteSearch                     `json:"search,omitempty"`
+	RTT             *remoteRTT                        `json:"rtt,omitempty"`
+	Diff            *remoteStats                      `json:"diff,omitempty"`
+}
+
+type remoteConnections struct {
+	Setup      *hdrhistogram.Snapshot `json:"setup,omitempty"`
+	ProxySetup *hdrhistogram.Snapshot `json:"proxySetup,omitempty"`
+	Count      int64                  `json:"count"`
+	Workers    int64                  `json:"workers"`
+}
+
+type remoteTransfer struct {
+	FirstRecord *hdrhistogram.Snapshot `json:"firstRecord,omitempty"`
+	Records     int64                  `json:"records"`
+	Bytes       int64                  `json:"bytes"`
+}
+
+type remoteDual struct {
+	FamilyHists  map[string]*hdrhistogram.Snapshot `json:"familyHists,omitempty"`
+	FamilyErrors map[string]int64                  `json:"familyErrors,omitempty"`
+	FirstUsable  *hdrhistogram.Snapshot            `json:"firstUsable,omitempty"`
+	Unusable     int64                             `json:"unusable"`
+}
+
+type remoteSearch struct {
+	LookupHist *hdrhistogram.Snapshot `json:"lookupHist,omitempty"`
+	AnswerHist *hdrhistogram.Snapshot `json:"answerHist,omitempty"`
+	Lookups    int64                  `json:"lookups"`
+	Queries    int64                  `json:"queries"`
+	Misses     int64                  `json:"misses"`
+}
+
+type remoteRTT struct {
+	Hist   *hdrhistogram.Snapshot `json:"hist,omitempty"`
+	Method string                 `json:"method"`
+	Target string                 `json:"target"`
+	Failed int64                  `json:"failed"`
+}
+
+type workerResponse struct {
+	Stats          []*remoteStats `json:"stats,omitempty"`
+	WarmupDuration time.Duration  `json:"warmupDuration,omitempty"`
+	Error          string         `json:"error,omitempty"`
+}
+
+func toRemoteStats(st *ResultStats) *remoteStats {
+	if st == nil {
+		return nil
+	}
+	rs := remoteStats{
+		Codes:           st.Codes,
+		Qtypes:          st.Qtypes,
+		TTLs:            st.TTLs,
+		Sizes:           exportSizes(st.Sizes),
+		QtypeSizes:      exportSizes(st.QtypeSizes),
+		QtypeHists:      exportKeyed(st.QtypeHists),
+		FlagHists:       exportKeyed(st.FlagHists),
+		CNAMEDepthHists: exportKeyed(st.CNAMEDepthHists),
+		FailoverHists:   exportKeyed(st.FailoverHists),
+		BurstHists:      exportKeyed(st.BurstHists),
+		RcodeHists:      exportKeyed(st.RcodeHists),
+		DoHMethodHists:  exportKeyed(st.DoHMethodHists),
+		IdentityHists:   exportKeyed(st.IdentityHists),
+		ResponderHists:  exportKeyed(st.ResponderHists),
+		CacheHists:      exportKeyed(st.CacheHists),
+		FirstByteHists:  exportKeyed(st.FirstByteHists),
+		EDNSSizeHists:   exportKeyed(st.EDNSSizeHists),
+		IntervalHists:   exportKeyed(st.IntervalHists),
+		Timings:         st.Timings,
+		ErrorTimes:      st.ErrorTimes,
+		DatapointWeight: st.DatapointWeight,
+		Counters:        st.Counters,
+		DoHProtocols:    st.DoHProtocols,
+		DoH:             st.DoH,
+		Attempts:        st.Attempts,
+		Families:        st.Families,
+		Keepalives:      st.KeepaliveTimeouts,
+		Unanswered:      st.FailoverUnanswered,
+		Bursts:          st.Bursts,
+		RRL:             st.RRL,
+		EDNSSizes:       st.EDNSSizes,
+		Malformed:       st.Malformed,
+		ErrorCategories: st.ErrorCategories,
+		HookErrors:      st.HookErrors,
+		Diff:            toRemoteStats(st.Diff),
+	}
+	if st.Hist != nil {
+		rs.Hist = st.Hist.Export()
+	}
+	if st.Connections != nil {
+		rs.Connections = &remoteConnections{Count: st.Connections.Count, Workers: st.Connections.Workers}
+		if st.Connections.Setup != nil {
+			rs.Connections.Setup = st.Connections.Setup.Export()
+		}
+		if st.Connections.ProxySetup != nil {
+			rs.Connections.ProxySetup = st.Connections.ProxySetup.Export()
+		}
+	}
+	if st.Transfer != nil {
+		rs.Transfer = &remoteTransfer{Records: st.Transfer.Records, Bytes: st.Transfer.Bytes}
+		if st.Transfer.FirstRecord != nil {
+			rs.Transfer.FirstRecord = st.Transfer.FirstRecord.Export()
+		}
+	}
+	if st.Dual != nil {
+		rs.Dual = &remoteDual{FamilyHists: exportKeyed(st.Dual.FamilyHists), FamilyErrors: st.Dual.FamilyErrors, Unusable: st.Dual.Unusable}
+		if st.Dual.FirstUsable != nil {
+			rs.Dual.FirstUsable = st.Dual.FirstUsable.Export()
+		}
+	}
+	if st.Search != nil {
+		rs.Search = &remoteSearch{Lookups: st.Search.Lookups, Queries: st.Search.Queries, Misses: st.Search.Misses}
+		if st.Search.LookupHist != nil {
+			rs.Search.LookupHist = st.Search.LookupHist.Export()
+		}
+		if st.Search.AnswerHist != nil {
+			rs.Search.AnswerHist = st.Search.AnswerHist.Export()
+		}
+	}
+	if st.RTT != nil {
+		rs.RTT = &remoteRTT{Method: st.RTT.Method, Target: st.RTT.Target, Failed: st.RTT.Failed}
+		if st.RTT.Hist != nil {
+			rs.RTT.Hist = st.RTT.Hist.Export()
+		}
+	}
+	for _, err := range st.Errors {
+		rs.Errors = append(rs.Errors, err.Error())
+	}
+	return &rs
+}
+
+func fromRemoteStats(rs *remoteStats) *ResultStats {
+	if rs == nil {
+		return nil
+	}
+	st := ResultStats{
+		Codes:              rs.Codes,
+		Qtypes:             rs.Qtypes,
+		TTLs:               rs.TTLs,
+		Sizes:              importSizes(rs.Sizes),
+		QtypeSizes:         importSizes(rs.QtypeSizes),
+		QtypeHists:         importKeyed(rs.QtypeHists),
+		FlagHists:          importKeyed(rs.FlagHists),
+		CNAMEDepthHists:    importKeyed(rs.CNAMEDepthHists),
+		FailoverHists:      importKeyed(rs.FailoverHists),
+		BurstHists:         importKeyed(rs.BurstHists),
+		RcodeHists:         importKeyed(rs.RcodeHists),
+		DoHMethodHists:     importKeyed(rs.DoHMethodHists),
+		IdentityHists:      importKeyed(rs.IdentityHists),
+		ResponderHists:     importKeyed(rs.ResponderHists),
+		CacheHists:         importKeyed(rs.CacheHists),
+		FirstByteHists:     importKeyed(rs.FirstByteHists),
+		EDNSSizeHists:      importKeyed(rs.EDNSSizeHists),
+		IntervalHists:      importKeyed(rs.IntervalHists),
+		Timings:            rs.Timings,
+		ErrorTimes:         rs.ErrorTimes,
+		DatapointWeight:    rs.DatapointWeight,
+		Counters:           rs.Counters,
+		DoHProtocols:       rs.DoHProtocols,
+		DoH:                rs.DoH,
+		Attempts:           rs.Attempts,
+		Families:           rs.Families,
+		KeepaliveTimeouts:  rs.Keepalives,
+		FailoverUnanswered: rs.Unanswered,
+		Bursts:             rs.Bursts,
+		RRL:                rs.RRL,
+		EDNSSizes:          rs.EDNSSizes,
+		Malformed:          rs.Malformed,
+		ErrorCategories:    rs.ErrorCategories,
+		HookErrors:         rs.HookErrors,
+		Diff:               fromRemoteStats(rs.Diff),
+	}
+	if st.Counters == nil {
+		st.Counters = &Counters{}
+	}
+	if rs.Hist != nil {
+		st.Hist = hdrhistogram.Import(rs.Hist)
+	}
+	if rs.Connections != nil {
+		st.Connections = &ConnectionStats{Count: rs.Connections.Count, Workers: rs.Connections.Workers}
+		if rs.Connections.Setup != nil {
+			st.Connections.Setup = hdrhistogram.Import(rs.Connections.Setup)
+		}
+		if rs.Connections.ProxySetup != nil {
+			st.Connections.ProxySetup = hdrhistogram.Import(rs.Connections.ProxySetup)
+		}
+	}
+	if rs.Transfer != nil {
+		st.Transfer = &TransferStats{Records: rs.Transfer.Records, Bytes: rs.Transfer.Bytes}
+		if rs.Transfer.FirstRecord != nil {
+			st.Transfer.FirstRecord = hdrhistogram.Import(rs.Transfer.FirstRecord)
+		}
+	}
+	if rs.Dual != nil {
+		st.Dual = &DualStats{FamilyHists: importKeyed(rs.Dual.FamilyHists), FamilyErrors: rs.Dual.FamilyErrors, Unusable: rs.Dual.Unusable}
+		if rs.Dual.FirstUsable != nil {
+			st.Dual.FirstUsable = hdrhistogram.Import(rs.Dual.FirstUsable)
+		}
+	}
+	if rs.Search != nil {
+		st.Search = &SearchStats{Lookups: rs.Search.Lookups, Queries: rs.Search.Queries, Misses: rs.Search.Misses}
+		if rs.Search.LookupHist != nil {
+			st.Search.LookupHist = hdrhistogram.Import(rs.Search.LookupHist)
+		}
+		if rs.Search.AnswerHist != nil {
+			st.Search.AnswerHist = hdrhistogram.Import(rs.Search.AnswerHist)
+		}
+	}
+	if rs.RTT != nil {
+		st.RTT = &RTTStats{Method: rs.RTT.Method, Target: rs.RTT.Target, Failed: rs.RTT.Failed}
+		if rs.RTT.Hist != nil {
+			st.RTT.Hist = hdrhistogram.Import(rs.RTT.Hist)
+		}
+	}
+	for _, err := range rs.Errors {
+		st.Errors = append(st.Errors, errors.New(err))
+	}
+	return &st
+}
+
+// RunWorker starts a benchmark worker listening on the provided address, the worker executes benchmarks received from the coordinator
+// and responds with the benchmark results. The worker runs until the context is cancelled.
+func RunWorker(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start worker listener due to '%v'", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(workerPath, handleBenchmark)
+	server := http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	fmt.Printf("Worker listening on %s\n", highlightStr(listener.Addr().String()))
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func handleBenchmark(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var b Benchmark
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(workerResponse{Error: fmt.Sprintf("failed to decode benchmark due to '%v'", err)})
+		return
+	}
+
+	b.clearLocalOutputs()
+	if err := b.checkUntrustedInputs(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(workerResponse{Error: err.Error()})
+		return
+	}
+	stats, err := b.Run(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(workerResponse{Error: err.Error()})
+		return
+	}
+
+	resp := workerResponse{WarmupDuration: b.warmupDuration}
+	for _, st := range stats {
+		resp.Stats = append(resp.Stats, toRemoteStats(st))
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// workerOutput replaces the paths of the outputs written by the coordinator, which enable collecting of the results exported to them.
+const workerOutput = "(coordinator)"
+
+// clearLocalOutputs clears the paths of the files written by the benchmark received from the coordinator, so that the coordinator
+// cannot create or overwrite files on the host of the worker. The outputs exported from the merged results are written only by the coordinator,
+// HistLog and PlotDir are replaced by a marker, because the worker still has to collect the interval histograms and the error times for them.
+func (b *Benchmark) clearLocalOutputs() {
+	b.FailureLog, b.DiffLog = "", ""
+	b.StatsFile, b.StatsInterval = "", 0
+	b.Csv, b.ReportCsv, b.Store, b.SaveRaw, b.HistExport, b.SeriesCsv = "", "", "", "", "", ""
+	if b.HistLog != "" {
+		b.HistLog = workerOutput
+	}
+	if b.PlotDir != "" {
+		b.PlotDir = workerOutput
+	}
+}
+
+// RunDistributed executes the benchmark on all provided workers in parallel and returns results of all benchmark goroutines of all workers.
+// Each worker executes the whole benchmark, so for example rate limits and the total number of queries are applied by each worker separately.
+// The queries are downloaded by the coordinator, the workers reject the benchmarks reading files or opening endpoints on their hosts.
+func (b *Benchmark) RunDistributed(ctx context.Context, workers []string) ([]*ResultStats, error) {
+	names, err := b.prepareNames()
+	if err != nil {
+		return nil, err
+	}
+	remote := *b
+	remote.Queries = names
+	body, err := json.Marshal(&remote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode benchmark due to '%v'", err)
+	}
+
+	if !b.Silent && !b.JSON {
+		fmt.Printf("Benchmarking %s using %s workers\n", highlightStr(b.Server), highlightStr(len(workers)))
+	}
+
+	results := make([]workerResponse, len(workers))
+	errs := make([]error, len(workers))
+
+	var wg sync.WaitGroup
+	for i, worker := range workers {
+		wg.Add(1)
+		go func(i int, worker string) {
+			defer wg.Done()
+			results[i], errs[i] = runOnWorker(ctx, worker, body)
+		}(i, worker)
+	}
+	wg.Wait()
+
+	var stats []*ResultStats
+	b.warmupDuration = 0
+	for i := range workers {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("worker '%s' failed: %w", workers[i], errs[i])
+		}
+		for _, rs := range results[i].Stats {
+			stats = append(stats, fromRemoteStats(rs))
+		}
+		// workers execute the benchmark in parallel, so the longest warm-up is excluded from the benchmark duration
+		if results[i].WarmupDuration > b.warmupDuration {
+			b.warmupDuration = results[i].WarmupDuration
+		}
+	}
+	return stats, nil
+}
+
+func runOnWorker(ctx context.Context, worker string, body []byte) (workerResponse, error) {
+	url := worker
+	if ok, _ := isHTTPUrl(worker); !ok {
+		url = "http://" + worker
+	}
+	url = strings.TrimSuffix(url, "/") + workerPath
+
+	var wr workerResponse
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return wr, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// benchmark can run for a long time, so the request is limited only by the context
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return wr, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return wr, err
+	}
+
+	if err := json.Unmarshal(data, &wr); err != nil {
+		return wr, fmt.Errorf("unexpected response with status '%s'", resp.Status)
+	}
+	if wr.Error != "" {
+		return wr, errors.New(wr.Error)
+	}
+	return wr, nil
+}
@@ -0,0 +1,173 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_RunDistributed(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	w1 := httptest.NewServer(http.HandlerFunc(handleBenchmark))
+	defer w1.Close()
+	w2 := httptest.NewServer(http.HandlerFunc(handleBenchmark))
+	defer w2.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.RunDistributed(ctx, []string{w1.URL, w2.Listener.Addr().String()})
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 4, "expected results from each goroutine of both workers")
+	for _, st := range rs {
+		assert.Equal(t, int64(2), st.Counters.Total)
+		assert.Equal(t, int64(2), st.Counters.Success)
+		assert.Equal(t, int64(2), st.Hist.TotalCount())
+		assert.Len(t, st.Timings, 2)
+		assert.Equal(t, map[int]int64{dns.RcodeSuccess: 2}, st.Codes)
+	}
+}
+
+func TestBenchmark_RunDistributed_localOutputs(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Rcode = dns.RcodeServerFailure
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	w := httptest.NewServer(http.HandlerFunc(handleBenchmark))
+	defer w.Close()
+
+	dir := t.TempDir()
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.FailureLog = filepath.Join(dir, "failures.log")
+	bench.DiffLog = filepath.Join(dir, "diff.log")
+	bench.StatsFile = filepath.Join(dir, "stats.jsonl")
+	bench.StatsInterval = time.Second
+	bench.HistLog = filepath.Join(dir, "latency.hlog")
+	bench.HistLogInterval = time.Second
+
+	rs, err := bench.RunDistributed(context.Background(), []string{w.URL})
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "worker does not write the files requested by the coordinator")
+	assert.NotEmpty(t, Merge(rs).IntervalHists, "interval histograms are still collected for the histogram log of the coordinator")
+}
+
+func TestBenchmark_RunDistributed_untrustedInputs(t *testing.T) {
+	w := httptest.NewServer(http.HandlerFunc(handleBenchmark))
+	defer w.Close()
+
+	tests := []struct {
+		name   string
+		modify func(b *Benchmark)
+	}{
+		{name: "zone file", modify: func(b *Benchmark) { b.ZoneFile = "/etc/shadow" }},
+		{name: "tls cert", modify: func(b *Benchmark) { b.TLSCert, b.TLSKey = "/etc/ssl/cert.pem", "/etc/ssl/key.pem" }},
+		{name: "prometheus", modify: func(b *Benchmark) { b.Prometheus = ":9090" }},
+		{name: "push", modify: func(b *Benchmark) { b.Push = []string{"http://127.0.0.1:9091"} }},
+		{name: "otel", modify: func(b *Benchmark) { b.OtelEndpoint = "127.0.0.1:4318" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bench := createBenchmark("127.0.0.1", false, 1)
+			tt.modify(&bench)
+
+			_, err := bench.RunDistributed(context.Background(), []string{w.URL})
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "is not supported for remote clients")
+		})
+	}
+}
+
+func TestBenchmark_RunDistributed_downloadedQueries(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+	names := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("example.org\nexample.com\n"))
+	}))
+	defer names.Close()
+
+	received := make(chan []string, 1)
+	w := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var b Benchmark
+		require.NoError(t, json.Unmarshal(body, &b))
+		received <- b.Queries
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handleBenchmark(w, r)
+	}))
+	defer w.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = []string{names.URL}
+
+	rs, err := bench.RunDistributed(context.Background(), []string{w.URL})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.org", "example.com"}, <-received, "the queries are downloaded by the coordinator")
+	assert.Equal(t, []string{names.URL}, bench.Queries)
+	assert.Equal(t, int64(8), Merge(rs).Counters.Total, "both downloaded names are queried by each goroutine")
+}
+
+func TestBenchmark_RunDistributed_worker_error(t *testing.T) {
+	w := httptest.NewServer(http.HandlerFunc(handleBenchmark))
+	defer w.Close()
+
+	bench := createBenchmark("127.0.0.1", false, 1)
+	bench.Queries = nil
+
+	_, err := bench.RunDistributed(context.Background(), []string{w.URL})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), w.URL)
+}
+
+func Test_remoteStats_searchAndRTT(t *testing.T) {
+	template := hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Second.Nanoseconds(), 3)
+	st := &ResultStats{Counters: &Counters{}, Search: newSearchStats(template)}
+	st.Search.Lookups, st.Search.Queries, st.Search.Misses = 2, 5, 3
+	require.NoError(t, st.Search.LookupHist.RecordValue(time.Millisecond.Nanoseconds()))
+	st.RTT = &RTTStats{Method: "tcp", Target: "127.0.0.1:53", Failed: 1, Hist: hdrhistogram.Import(template.Export())}
+	require.NoError(t, st.RTT.Hist.RecordValue(time.Millisecond.Nanoseconds()))
+
+	got := fromRemoteStats(toRemoteStats(st))
+	require.NotNil(t, got.Search)
+	assert.Equal(t, int64(5), got.Search.Queries)
+	assert.Equal(t, int64(1), got.Search.LookupHist.TotalCount())
+	require.NotNil(t, got.RTT)
+	assert.Equal(t, "127.0.0.1:53", got.RTT.Target)
+	assert.Equal(t, int64(1), got.RTT.Failed)
+	assert.Equal(t, int64(1), got.RTT.Hist.TotalCount())
+}
@@ -0,0 +1,192 @@
+package dnsbench
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/ratelimit"
+)
+
+const (
+	constantDistribution = "constant"
+	uniformDistribution  = "uniform"
+	poissonDistribution  = "poisson"
+
+	// stochasticSlack is a number of mean intervals, which the stochastic limiters and pacers are allowed to fall behind the schedule before they are reset,
+	// this mirrors the default slack of go.uber.org/ratelimit limiters.
+	stochasticSlack = 10
+
+	// minShardRate is the minimal rate of a single shard of the global rate limit, lower rates are not sharded,
+	// since the contention of the workers on a single limiter becomes noticeable only at high rates.
+	minShardRate = 1000
+)
+
+// newLimiter returns rate limiter producing arrivals according to the rate distribution of the benchmark, random arrivals are drawn using the seed.
+func (b *Benchmark) newLimiter(rate int, seed int64) ratelimit.Limiter {
+	switch b.RateDistribution {
+	case uniformDistribution, poissonDistribution:
+		// nolint:gosec
+		return &stochasticLimiter{
+			rando:        rand.New(rand.NewSource(seed)),
+			interval:     time.Second / time.Duration(rate),
+			distribution: b.RateDistribution,
+		}
+	default:
+		return ratelimit.New(rate)
+	}
+}
+
+// globalLimiter splits the global rate limit into shards, so that the workers do not contend on a single shared limiter at high rates,
+// each worker takes the earliest arrival of any shard, so that the shards of the stalled workers are taken by the others and the global rate holds.
+// There are at most as many shards as concurrent workers and usable CPUs. Constant arrivals of the shards are shifted against each other,
+// so that they interleave evenly instead of arriving at once.
+func (b *Benchmark) globalLimiter(rate int) ratelimit.Limiter {
+	shards := rate / minShardRate
+	if cpus := runtime.GOMAXPROCS(0); shards > cpus {
+		shards = cpus
+	}
+	if shards > int(b.Concurrency) {
+		shards = int(b.Concurrency)
+	}
+	// workers activated by AutoConcurrency would use only some of the shards
+	if shards <= 1 || b.AutoConcurrency {
+		return b.newLimiter(rate, b.randSeed(globalLimitStream, 0))
+	}
+
+	limiters := make([]shard, shards)
+	epoch := new(atomic.Int64)
+	for i := range limiters {
+		shardRate := rate / shards
+		if i < rate%shards {
+			shardRate++
+		}
+		if b.RateDistribution == uniformDistribution || b.RateDistribution == poissonDistribution {
+			limiters[i] = b.newLimiter(shardRate, b.randSeed(globalLimitStream, int64(i))).(*stochasticLimiter)
+			continue
+		}
+		limiters[i] = &pacer{interval: time.Second / time.Duration(shardRate), offset: time.Duration(i) * time.Second / time.Duration(rate), epoch: epoch}
+	}
+	return &shardedLimiter{shards: limiters}
+}
+
+// shard is a shard of the global rate limit.
+type shard interface {
+	ratelimit.Limiter
+	// nextArrival returns the time of the next arrival of the shard in unix nanoseconds, 0 when no arrival was taken yet.
+	nextArrival() int64
+}
+
+// shardedLimiter takes the earliest arrival of its shards, the shards are not locked while the earliest one is searched, so concurrent
+// Take calls can pick the same shard, the later one then waits for the following arrival of the shard. The limiter is safe for concurrent use.
+type shardedLimiter struct {
+	shards []shard
+}
+
+// Take blocks until the next arrival of the shard with the earliest one.
+func (l *shardedLimiter) Take() time.Time {
+	earliest := l.shards[0]
+	at := earliest.nextArrival()
+	for _, s := range l.shards[1:] {
+		if next := s.nextArrival(); next < at {
+			earliest, at = s, next
+		}
+	}
+	return earliest.Take()
+}
+
+// pacer is a lock-free rate limiter with constant intervals between the arrivals, the first arrival is delayed by the offset
+// from the epoch shared by the shards of the rate limit, the epoch is set by the first arrival of any of them.
+// The limiter is safe for concurrent use.
+type pacer struct {
+	interval time.Duration
+	offset   time.Duration
+	epoch    *atomic.Int64
+	// next is the time of the next arrival in unix nanoseconds.
+	next atomic.Int64
+}
+
+// Take blocks until the next scheduled arrival.
+func (p *pacer) Take() time.Time {
+	for {
+		now := time.Now().UnixNano()
+		next := p.next.Load()
+		at := next
+		if next == 0 {
+			p.epoch.CompareAndSwap(0, now)
+			at = p.epoch.Load() + int64(p.offset)
+		} else if now-next > stochasticSlack*int64(p.interval) {
+			at = now
+		}
+		if !p.next.CompareAndSwap(next, at+int64(p.interval)) {
+			continue
+		}
+
+		t := time.Unix(0, at)
+		if d := time.Until(t); d > 0 {
+			time.Sleep(d)
+		}
+		return t
+	}
+}
+
+func (p *pacer) nextArrival() int64 {
+	if next := p.next.Load(); next != 0 {
+		return next
+	}
+	if epoch := p.epoch.Load(); epoch != 0 {
+		return epoch + int64(p.offset)
+	}
+	return 0
+}
+
+// stochasticLimiter is a rate limiter with random intervals between the arrivals, the mean interval matches the rate.
+// Poisson distribution has exponentially distributed intervals, which is the standard model of arrivals of independent clients.
+// Uniform distribution has intervals uniformly distributed between zero and double of the mean interval. The limiter is safe for concurrent use.
+type stochasticLimiter struct {
+	mu           sync.Mutex
+	rando        *rand.Rand
+	interval     time.Duration
+	distribution string
+	next         time.Time
+}
+
+// Take blocks until the next scheduled arrival.
+func (l *stochasticLimiter) Take() time.Time {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.IsZero() || now.Sub(l.next) > stochasticSlack*l.interval {
+		l.next = now
+	}
+	l.next = l.next.Add(nextGap(l.rando, l.interval, l.distribution))
+	next := l.next
+	l.mu.Unlock()
+
+	if d := time.Until(next); d > 0 {
+		time.Sleep(d)
+	}
+	return next
+}
+
+func (l *stochasticLimiter) nextArrival() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.next.IsZero() {
+		return 0
+	}
+	return l.next.UnixNano()
+}
+
+// nextGap returns random interval to the next arrival drawn from the distribution with the given mean interval.
+func nextGap(rando *rand.Rand, interval time.Duration, distribution string) time.Duration {
+	switch distribution {
+	case poissonDistribution:
+		return time.Duration(rando.ExpFloat64() * float64(interval))
+	case uniformDistribution:
+		return time.Duration(rando.Float64() * 2 * float64(interval))
+	default:
+		return interval
+	}
+}
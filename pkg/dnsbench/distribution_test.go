@@ -0,0 +1,141 @@
+package dnsbench
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_newLimiter(t *testing.T) {
+	tests := []struct {
+		distribution string
+		stochastic   bool
+	}{
+		{distribution: "", stochastic: false},
+		{distribution: constantDistribution, stochastic: false},
+		{distribution: uniformDistribution, stochastic: true},
+		{distribution: poissonDistribution, stochastic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.distribution, func(t *testing.T) {
+			b := Benchmark{RateDistribution: tt.distribution}
+			_, ok := b.newLimiter(100, 1).(*stochasticLimiter)
+			assert.Equal(t, tt.stochastic, ok)
+		})
+	}
+}
+
+func TestStochasticLimiter_Take(t *testing.T) {
+	for _, distribution := range []string{uniformDistribution, poissonDistribution} {
+		t.Run(distribution, func(t *testing.T) {
+			b := Benchmark{RateDistribution: distribution}
+			limiter := b.newLimiter(1000, 1)
+
+			start := time.Now()
+			var prev time.Time
+			var gaps []time.Duration
+			for i := 0; i < 500; i++ {
+				next := limiter.Take()
+				if !prev.IsZero() {
+					gaps = append(gaps, next.Sub(prev))
+				}
+				prev = next
+			}
+			elapsed := time.Since(start)
+
+			// 500 arrivals at 1000 QPS take 500ms on average
+			assert.Greater(t, elapsed, 250*time.Millisecond)
+			assert.Less(t, elapsed, 2*time.Second)
+
+			distinct := make(map[time.Duration]struct{})
+			for _, g := range gaps {
+				require.GreaterOrEqual(t, g, time.Duration(0))
+				distinct[g] = struct{}{}
+			}
+			assert.Greater(t, len(distinct), 1, "intervals should be random")
+		})
+	}
+}
+
+func TestBenchmark_globalLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate        int
+		concurrency uint32
+		wantShards  int
+	}{
+		{name: "low rate", rate: 1500, concurrency: 100, wantShards: 1},
+		{name: "single worker", rate: 100000, concurrency: 1, wantShards: 1},
+		{name: "sharded", rate: 100000, concurrency: 2, wantShards: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Benchmark{Concurrency: tt.concurrency}
+			limiter := b.globalLimiter(tt.rate)
+			if runtime.GOMAXPROCS(0) < tt.wantShards {
+				t.Skip("not enough CPUs to shard the rate limit")
+			}
+			shards := 1
+			if sharded, ok := limiter.(*shardedLimiter); ok {
+				shards = len(sharded.shards)
+			}
+			assert.Equal(t, tt.wantShards, shards)
+		})
+	}
+}
+
+func TestBenchmark_globalLimiter_stalledWorker(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	b := Benchmark{Concurrency: 4}
+	limiter := b.globalLimiter(4000)
+	require.IsType(t, &shardedLimiter{}, limiter)
+
+	var taken atomic.Int64
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(500 * time.Millisecond)
+	// the fourth worker takes a single arrival and stalls, for example on a slow server
+	limiter.Take()
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for limiter.Take().Before(deadline) {
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 500ms at 4000 QPS, the arrivals of the stalled worker are taken by the others
+	assert.InDelta(t, 2000, taken.Load(), 300)
+}
+
+func TestPacer_Take(t *testing.T) {
+	// two shards of 1000 QPS rate limit
+	epoch := new(atomic.Int64)
+	shards := []*pacer{
+		{interval: 2 * time.Millisecond, epoch: epoch},
+		{interval: 2 * time.Millisecond, offset: time.Millisecond, epoch: epoch},
+	}
+
+	start := time.Now()
+	var arrivals []time.Time
+	for i := 0; i < 50; i++ {
+		for _, p := range shards {
+			arrivals = append(arrivals, p.Take())
+		}
+	}
+	elapsed := time.Since(start)
+
+	// 100 arrivals at 1000 QPS take 100ms
+	assert.Greater(t, elapsed, 90*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+	for i := 1; i < len(arrivals); i++ {
+		assert.Equal(t, time.Millisecond, arrivals[i].Sub(arrivals[i-1]), "arrivals of shards should interleave")
+	}
+}
@@ -0,0 +1,299 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const (
+	dnscryptStampPrefix = "sdns://"
+	dnscryptStampProto  = 0x01
+	dnscryptDefaultPort = "443"
+
+	dnscryptCertMagic     = "DNSC"
+	dnscryptCertLen       = 124
+	dnscryptResolverMagic = "r6fnvWj8"
+	// dnscryptXSalsa20Poly1305 is the mandatory encryption system of DNSCrypt, X25519-XChacha20Poly1305 is not supported.
+	dnscryptXSalsa20Poly1305 = 0x0001
+
+	dnscryptNonceLen    = 24
+	dnscryptMinQueryLen = 256
+	dnscryptPadBlock    = 64
+)
+
+// dnscryptStamp is a DNSCrypt server stamp, see https://dnscrypt.info/stamps-specifications.
+type dnscryptStamp struct {
+	addr         string
+	providerKey  ed25519.PublicKey
+	providerName string
+}
+
+// dnscryptCert is a certificate of DNSCrypt resolver, see https://dnscrypt.info/protocol.
+type dnscryptCert struct {
+	resolverKey [32]byte
+	clientMagic [8]byte
+	serial      uint32
+}
+
+// parseDNSCryptStamp parses DNSCrypt stamp in sdns://... format.
+func parseDNSCryptStamp(s string) (dnscryptStamp, error) {
+	if !strings.HasPrefix(s, dnscryptStampPrefix) {
+		return dnscryptStamp{}, fmt.Errorf("invalid DNSCrypt stamp '%s', expected %s prefix", s, dnscryptStampPrefix)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, dnscryptStampPrefix))
+	if err != nil {
+		return dnscryptStamp{}, fmt.Errorf("invalid DNSCrypt stamp '%s': %v", s, err)
+	}
+	// protocol identifier followed by 8 bytes of properties
+	if len(data) < 9 || data[0] != dnscryptStampProto {
+		return dnscryptStamp{}, fmt.Errorf("invalid DNSCrypt stamp '%s', only DNSCrypt stamps are supported", s)
+	}
+	data = data[9:]
+
+	var fields [3][]byte
+	for i := range fields {
+		if len(data) == 0 || len(data) < 1+int(data[0]) {
+			return dnscryptStamp{}, fmt.Errorf("invalid DNSCrypt stamp '%s', stamp is truncated", s)
+		}
+		fields[i] = data[1 : 1+int(data[0])]
+		data = data[1+int(data[0]):]
+	}
+
+	addr := string(fields[0])
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), dnscryptDefaultPort)
+	}
+	if len(fields[1]) != ed25519.PublicKeySize {
+		return dnscryptStamp{}, fmt.Errorf("invalid DNSCrypt stamp '%s', provider public key has to be %d bytes long", s, ed25519.PublicKeySize)
+	}
+	if len(fields[2]) == 0 {
+		return dnscryptStamp{}, fmt.Errorf("invalid DNSCrypt stamp '%s', provider name is missing", s)
+	}
+	return dnscryptStamp{addr: addr, providerKey: fields[1], providerName: dns.Fqdn(string(fields[2]))}, nil
+}
+
+// parseDNSCryptCert verifies the signature and validity of the resolver certificate and parses it.
+func parseDNSCryptCert(data []byte, providerKey ed25519.PublicKey, now time.Time) (dnscryptCert, error) {
+	if len(data) < dnscryptCertLen || string(data[:4]) != dnscryptCertMagic {
+		return dnscryptCert{}, errors.New("invalid DNSCrypt certificate")
+	}
+	if v := binary.BigEndian.Uint16(data[4:6]); v != dnscryptXSalsa20Poly1305 {
+		return dnscryptCert{}, fmt.Errorf("unsupported DNSCrypt encryption system %d", v)
+	}
+	if !ed25519.Verify(providerKey, data[72:], data[8:72]) {
+		return dnscryptCert{}, errors.New("invalid signature of DNSCrypt certificate")
+	}
+	start := time.Unix(int64(binary.BigEndian.Uint32(data[116:120])), 0)
+	end := time.Unix(int64(binary.BigEndian.Uint32(data[120:124])), 0)
+	if now.Before(start) || now.After(end) {
+		return dnscryptCert{}, errors.New("DNSCrypt certificate is not valid at this time")
+	}
+
+	var cert dnscryptCert
+	copy(cert.resolverKey[:], data[72:104])
+	copy(cert.clientMagic[:], data[104:112])
+	cert.serial = binary.BigEndian.Uint32(data[112:116])
+	return cert, nil
+}
+
+// fetchDNSCryptCert queries the certificates of the resolver and returns the valid one with the highest serial.
+func (b *Benchmark) fetchDNSCryptCert(ctx context.Context) (*dnscryptCert, error) {
+	dnsClient := b.getDNSClient()
+	dnsClient.Dialer = b.dialer(dnsClient.Net)
+
+	m := new(dns.Msg)
+	m.SetQuestion(b.dnscrypt.providerName, dns.TypeTXT)
+	r, _, err := dnsClient.ExchangeContext(ctx, m, b.Server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch DNSCrypt certificate of '%s' due to '%v'", b.dnscrypt.providerName, err)
+	}
+
+	var best *dnscryptCert
+	err = fmt.Errorf("no DNSCrypt certificate of '%s' returned", b.dnscrypt.providerName)
+	for _, rr := range r.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		cert, certErr := parseDNSCryptCert(txtBytes(strings.Join(txt.Txt, "")), b.dnscrypt.providerKey, time.Now())
+		if certErr != nil {
+			err = certErr
+			continue
+		}
+		if best == nil || cert.serial > best.serial {
+			best = &cert
+		}
+	}
+	if best == nil {
+		return nil, err
+	}
+	return best, nil
+}
+
+// txtBytes reverts escaping of non-printable characters done while unpacking TXT records.
+func txtBytes(s string) []byte {
+	res := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			res = append(res, s[i])
+			continue
+		}
+		i++
+		if i+2 < len(s) && isDigit(s[i]) && isDigit(s[i+1]) && isDigit(s[i+2]) {
+			v, _ := strconv.Atoi(s[i : i+3])
+			res = append(res, byte(v))
+			i += 2
+			continue
+		}
+		res = append(res, s[i])
+	}
+	return res
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// getDNSCryptClient returns query function encrypting the queries for the resolver using the certificate, each worker has its own key pair and connection.
+func (b *Benchmark) getDNSCryptClient(cert *dnscryptCert) queryFunc {
+	pk, sk, keyErr := box.GenerateKey(crand.Reader)
+	var shared [32]byte
+	if keyErr == nil {
+		box.Precompute(&shared, &cert.resolverKey, sk)
+	}
+
+	dnsClient := b.getDNSClient()
+	var co *dns.Conn
+	var n int64
+	return func(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		if co != nil && b.QperConn > 0 && n%b.QperConn == 0 {
+			co.Close()
+			co = nil
+		}
+		n++
+
+		if co == nil {
+			dialStart := time.Now()
+			dnsClient.Dialer = b.dialer(dnsClient.Net)
+			var err error
+			co, err = dnsClient.Dial(b.Server)
+			if err != nil {
+				return nil, err
+			}
+			observeConnection(ctx, co.RemoteAddr(), time.Since(dialStart))
+		}
+
+		r, err := b.dnscryptExchange(ctx, co, cert, pk, &shared, msg)
+		if err != nil {
+			co.Close()
+			co = nil
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+func (b *Benchmark) dnscryptExchange(ctx context.Context, co *dns.Conn, cert *dnscryptCert, pk, shared *[32]byte, msg *dns.Msg) (*dns.Msg, error) {
+	packBuf := packBuffers.Get().(*[]byte)
+	defer packBuffers.Put(packBuf)
+	packed, err := msg.PackBuffer(*packBuf)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [dnscryptNonceLen]byte
+	if _, err := crand.Read(nonce[:dnscryptNonceLen/2]); err != nil {
+		return nil, err
+	}
+
+	query := make([]byte, 0, len(cert.clientMagic)+len(pk)+dnscryptNonceLen/2+box.Overhead+len(packed)+dnscryptMinQueryLen)
+	query = append(query, cert.clientMagic[:]...)
+	query = append(query, pk[:]...)
+	query = append(query, nonce[:dnscryptNonceLen/2]...)
+	query = box.SealAfterPrecomputation(query, dnscryptPad(packed), &nonce, shared)
+
+	co.SetWriteDeadline(deadline(ctx, b.WriteTimeout))
+	if _, err := co.Write(query); err != nil {
+		return nil, err
+	}
+	co.SetReadDeadline(deadline(ctx, b.ReadTimeout))
+	buf := make([]byte, dns.MaxMsgSize)
+	l, err := co.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	resp := buf[:l]
+
+	if len(resp) < len(dnscryptResolverMagic)+dnscryptNonceLen+box.Overhead || string(resp[:len(dnscryptResolverMagic)]) != dnscryptResolverMagic {
+		return nil, errors.New("invalid DNSCrypt response")
+	}
+	resp = resp[len(dnscryptResolverMagic):]
+	if !bytes.Equal(resp[:dnscryptNonceLen/2], nonce[:dnscryptNonceLen/2]) {
+		return nil, errors.New("unexpected nonce of DNSCrypt response")
+	}
+	copy(nonce[:], resp[:dnscryptNonceLen])
+	plain, ok := box.OpenAfterPrecomputation(nil, resp[dnscryptNonceLen:], &nonce, shared)
+	if !ok {
+		return nil, errors.New("failed to decrypt DNSCrypt response")
+	}
+	plain, err = dnscryptUnpad(plain)
+	if err != nil {
+		return nil, err
+	}
+
+	r := new(dns.Msg)
+	if err := r.Unpack(plain); err != nil {
+		return nil, err
+	}
+	if r.Id != msg.Id {
+		return nil, dns.ErrId
+	}
+	return r, nil
+}
+
+// dnscryptPad pads the query using ISO/IEC 7816-4 padding to the multiple of 64 bytes, at least to the minimal query length.
+func dnscryptPad(packed []byte) []byte {
+	l := (len(packed) + 1 + dnscryptPadBlock - 1) / dnscryptPadBlock * dnscryptPadBlock
+	if l < dnscryptMinQueryLen {
+		l = dnscryptMinQueryLen
+	}
+	padded := make([]byte, l)
+	copy(padded, packed)
+	padded[len(packed)] = 0x80
+	return padded
+}
+
+func dnscryptUnpad(padded []byte) ([]byte, error) {
+	i := len(padded) - 1
+	for i >= 0 && padded[i] == 0 {
+		i--
+	}
+	if i < 0 || padded[i] != 0x80 {
+		return nil, errors.New("invalid padding of DNSCrypt response")
+	}
+	return padded[:i], nil
+}
+
+// deadline returns the deadline after the timeout, the deadline of the context is used when it is sooner.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	d := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(d) {
+		return ctxDeadline
+	}
+	return d
+}
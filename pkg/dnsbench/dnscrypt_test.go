@@ -0,0 +1,251 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/ed25519"
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const testProviderName = "2.dnscrypt-cert.example.org."
+
+var testClientMagic = [8]byte{'t', 'e', 's', 't', 'm', 'a', 'g', 'c'}
+
+// dnscryptServer is a DNSCrypt resolver answering certificate queries in plain DNS and encrypted A queries.
+type dnscryptServer struct {
+	conn        net.PacketConn
+	providerKey ed25519.PublicKey
+	cert        []byte
+	sk          *[32]byte
+}
+
+func newDNSCryptServer(t *testing.T) *dnscryptServer {
+	providerPk, providerSk, err := ed25519.GenerateKey(crand.Reader)
+	require.NoError(t, err)
+	pk, sk, err := box.GenerateKey(crand.Reader)
+	require.NoError(t, err)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &dnscryptServer{conn: conn, providerKey: providerPk, cert: testCert(providerSk, pk, 1), sk: sk}
+	go s.serve()
+	return s
+}
+
+func testCert(providerSk ed25519.PrivateKey, pk *[32]byte, serial uint32) []byte {
+	signed := make([]byte, 0, 52)
+	signed = append(signed, pk[:]...)
+	signed = append(signed, testClientMagic[:]...)
+	signed = binary.BigEndian.AppendUint32(signed, serial)
+	signed = binary.BigEndian.AppendUint32(signed, uint32(time.Now().Add(-time.Hour).Unix()))
+	signed = binary.BigEndian.AppendUint32(signed, uint32(time.Now().Add(time.Hour).Unix()))
+
+	cert := []byte{'D', 'N', 'S', 'C', 0, 1, 0, 0}
+	cert = append(cert, ed25519.Sign(providerSk, signed)...)
+	return append(cert, signed...)
+}
+
+func (s *dnscryptServer) serve() {
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		l, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.handle(buf[:l]); resp != nil {
+			s.conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *dnscryptServer) handle(data []byte) []byte {
+	if len(data) < 52 || string(data[:8]) != string(testClientMagic[:]) {
+		m := new(dns.Msg)
+		if err := m.Unpack(data); err != nil {
+			return nil
+		}
+		r := new(dns.Msg)
+		r.SetReply(m)
+		r.Answer = append(r.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: testProviderName, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+			Txt: []string{escapeTxt(s.cert)},
+		})
+		packed, _ := r.Pack()
+		return packed
+	}
+
+	var clientPk [32]byte
+	copy(clientPk[:], data[8:40])
+	var shared [32]byte
+	box.Precompute(&shared, &clientPk, s.sk)
+	var nonce [24]byte
+	copy(nonce[:], data[40:52])
+	plain, ok := box.OpenAfterPrecomputation(nil, data[52:], &nonce, &shared)
+	if !ok {
+		return nil
+	}
+	plain, err := dnscryptUnpad(plain)
+	if err != nil {
+		return nil
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(plain); err != nil {
+		return nil
+	}
+	r := new(dns.Msg)
+	r.SetReply(m)
+	r.Answer = append(r.Answer, A(m.Question[0].Name+" IN A 127.0.0.1"))
+	packed, _ := r.Pack()
+
+	// wait some time to actually have some observable duration
+	time.Sleep(10 * time.Millisecond)
+
+	crand.Read(nonce[12:])
+	resp := append([]byte(dnscryptResolverMagic), nonce[:]...)
+	return box.SealAfterPrecomputation(resp, dnscryptPad(packed), &nonce, &shared)
+}
+
+func (s *dnscryptServer) stamp() string {
+	data := []byte{dnscryptStampProto, 0, 0, 0, 0, 0, 0, 0, 0}
+	for _, f := range [][]byte{[]byte(s.conn.LocalAddr().String()), s.providerKey, []byte(strings.TrimSuffix(testProviderName, "."))} {
+		data = append(data, byte(len(f)))
+		data = append(data, f...)
+	}
+	return dnscryptStampPrefix + base64.RawURLEncoding.EncodeToString(data)
+}
+
+func escapeTxt(data []byte) string {
+	var sb strings.Builder
+	for _, c := range data {
+		fmt.Fprintf(&sb, "\\%03d", c)
+	}
+	return sb.String()
+}
+
+func TestBenchmark_Run_dnscrypt(t *testing.T) {
+	s := newDNSCryptServer(t)
+	defer s.conn.Close()
+
+	bench := createBenchmark("", false, 1)
+	bench.DNSCrypt = s.stamp()
+
+	rs, err := bench.Run(context.Background())
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	assertResult(t, rs)
+	assert.Equal(t, s.conn.LocalAddr().String(), bench.Server)
+}
+
+func TestBenchmark_Run_dnscrypt_invalidCert(t *testing.T) {
+	s := newDNSCryptServer(t)
+	defer s.conn.Close()
+	otherPk, _, err := ed25519.GenerateKey(crand.Reader)
+	require.NoError(t, err)
+	s.providerKey = otherPk
+
+	bench := createBenchmark("", false, 1)
+	bench.DNSCrypt = s.stamp()
+
+	_, err = bench.Run(context.Background())
+
+	assert.EqualError(t, err, "invalid signature of DNSCrypt certificate")
+}
+
+func Test_parseDNSCryptStamp(t *testing.T) {
+	key := make([]byte, ed25519.PublicKeySize)
+	stamp := func(addr string, key []byte, name string) string {
+		data := []byte{dnscryptStampProto, 1, 0, 0, 0, 0, 0, 0, 0}
+		for _, f := range [][]byte{[]byte(addr), key, []byte(name)} {
+			data = append(data, byte(len(f)))
+			data = append(data, f...)
+		}
+		return dnscryptStampPrefix + base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	tests := []struct {
+		name    string
+		stamp   string
+		want    dnscryptStamp
+		wantErr bool
+	}{
+		{
+			name:  "address with port",
+			stamp: stamp("127.0.0.1:5443", key, "2.dnscrypt-cert.example.org"),
+			want:  dnscryptStamp{addr: "127.0.0.1:5443", providerKey: key, providerName: "2.dnscrypt-cert.example.org."},
+		},
+		{
+			name:  "default port",
+			stamp: stamp("127.0.0.1", key, "2.dnscrypt-cert.example.org"),
+			want:  dnscryptStamp{addr: "127.0.0.1:443", providerKey: key, providerName: "2.dnscrypt-cert.example.org."},
+		},
+		{
+			name:  "IPv6 address",
+			stamp: stamp("[::1]", key, "2.dnscrypt-cert.example.org"),
+			want:  dnscryptStamp{addr: "[::1]:443", providerKey: key, providerName: "2.dnscrypt-cert.example.org."},
+		},
+		{
+			name:    "missing prefix",
+			stamp:   "AQAAAAAAAAAA",
+			wantErr: true,
+		},
+		{
+			name:    "DoH stamp",
+			stamp:   dnscryptStampPrefix + base64.RawURLEncoding.EncodeToString([]byte{0x02, 0, 0, 0, 0, 0, 0, 0, 0}),
+			wantErr: true,
+		},
+		{
+			name:    "invalid key",
+			stamp:   stamp("127.0.0.1", key[:16], "2.dnscrypt-cert.example.org"),
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			stamp:   stamp("127.0.0.1", key, "2.dnscrypt-cert.example.org")[:40],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDNSCryptStamp(tt.stamp)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_dnscryptPad(t *testing.T) {
+	for _, l := range []int{0, 12, 255, 256, 300} {
+		packed := make([]byte, l)
+		for i := range packed {
+			packed[i] = 0x80
+		}
+
+		padded := dnscryptPad(packed)
+
+		assert.Zero(t, len(padded)%dnscryptPadBlock)
+		assert.GreaterOrEqual(t, len(padded), dnscryptMinQueryLen)
+		unpadded, err := dnscryptUnpad(padded)
+		require.NoError(t, err)
+		assert.Equal(t, packed, unpadded)
+	}
+}
+
+func Test_txtBytes(t *testing.T) {
+	assert.Equal(t, []byte{'D', 0, 200, '"', '\\'}, txtBytes(`D\000\200\"\\`))
+}
@@ -0,0 +1,246 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// rootAnchors are DS records of the root zone KSKs, used as trust anchors for validating the chain of trust.
+var rootAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// dnssecValidator validates signatures of DNS responses and the chain of trust of the signing keys up to the trust anchors.
+// Validated zone keys are cached, the validator is safe for concurrent use by benchmark workers.
+type dnssecValidator struct {
+	anchors []*dns.DS
+	timeout time.Duration
+	// zeroID is set for DoQ, which requires zero message IDs
+	zeroID bool
+
+	mu   sync.Mutex
+	keys map[string][]*dns.DNSKEY
+}
+
+func newDNSSECValidator(timeout time.Duration, zeroID bool) *dnssecValidator {
+	v := dnssecValidator{timeout: timeout, zeroID: zeroID, keys: make(map[string][]*dns.DNSKEY)}
+	for _, a := range rootAnchors {
+		rr, err := dns.NewRR(a)
+		if err != nil {
+			panic(err)
+		}
+		v.anchors = append(v.anchors, rr.(*dns.DS))
+	}
+	return &v
+}
+
+// validate checks that all records in answer and authority sections of the response are signed and their signatures are valid.
+// Note that only signatures are validated, proofs of non-existence are not checked.
+func (v *dnssecValidator) validate(ctx context.Context, query queryFunc, server string, resp *dns.Msg) error {
+	rrsets := append(groupRRsets(resp.Answer), groupRRsets(resp.Ns)...)
+	if len(rrsets) == 0 {
+		return errors.New("response contains no records")
+	}
+	for _, rrset := range rrsets {
+		if err := v.validateRRset(ctx, query, server, rrset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rrset is a set of records with the same name and type together with the signatures covering the records.
+type rrset struct {
+	records []dns.RR
+	sigs    []*dns.RRSIG
+}
+
+func groupRRsets(records []dns.RR) []*rrset {
+	var res []*rrset
+	sets := make(map[string]*rrset)
+	key := func(name string, t uint16) string {
+		return strings.ToLower(name) + "/" + dns.TypeToString[t]
+	}
+	get := func(k string) *rrset {
+		set, ok := sets[k]
+		if !ok {
+			set = &rrset{}
+			sets[k] = set
+			res = append(res, set)
+		}
+		return set
+	}
+	for _, rr := range records {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			set := get(key(sig.Hdr.Name, sig.TypeCovered))
+			set.sigs = append(set.sigs, sig)
+			continue
+		}
+		set := get(key(rr.Header().Name, rr.Header().Rrtype))
+		set.records = append(set.records, rr)
+	}
+	return res
+}
+
+func (v *dnssecValidator) validateRRset(ctx context.Context, query queryFunc, server string, set *rrset) error {
+	if len(set.records) == 0 {
+		return nil
+	}
+	name := set.records[0].Header().Name
+	qtype := dns.TypeToString[set.records[0].Header().Rrtype]
+	if len(set.sigs) == 0 {
+		return fmt.Errorf("%s %s is not signed", name, qtype)
+	}
+
+	var err error
+	for _, sig := range set.sigs {
+		var keys []*dns.DNSKEY
+		keys, err = v.zoneKeys(ctx, query, server, sig.SignerName)
+		if err != nil {
+			continue
+		}
+		if err = verify(sig, keys, set.records); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s signature is not valid: %w", name, qtype, err)
+}
+
+// zoneKeys returns validated DNSKEYs of the zone, the keys are validated using DS records of the parent zone or the trust anchors.
+func (v *dnssecValidator) zoneKeys(ctx context.Context, query queryFunc, server, zone string) ([]*dns.DNSKEY, error) {
+	zone = strings.ToLower(dns.Fqdn(zone))
+
+	v.mu.Lock()
+	keys, ok := v.keys[zone]
+	v.mu.Unlock()
+	if ok {
+		return keys, nil
+	}
+
+	keyResp, err := v.lookup(ctx, query, server, zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	var keySet *rrset
+	for _, set := range groupRRsets(keyResp.Answer) {
+		if len(set.records) > 0 && set.records[0].Header().Rrtype == dns.TypeDNSKEY {
+			keySet = set
+		}
+	}
+	if keySet == nil {
+		return nil, fmt.Errorf("no DNSKEY found for zone %s", zone)
+	}
+	for _, rr := range keySet.records {
+		keys = append(keys, rr.(*dns.DNSKEY))
+	}
+
+	dss, err := v.zoneDS(ctx, query, server, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	// the DNSKEY set has to be signed by a key matching any of DS records
+	var trusted []*dns.DNSKEY
+	for _, k := range keys {
+		for _, ds := range dss {
+			if kds := k.ToDS(ds.DigestType); kds != nil && ds.KeyTag == kds.KeyTag && strings.EqualFold(ds.Digest, kds.Digest) {
+				trusted = append(trusted, k)
+			}
+		}
+	}
+	if len(trusted) == 0 {
+		return nil, fmt.Errorf("no DNSKEY of zone %s matches DS records", zone)
+	}
+	err = errors.New("DNSKEY set is not signed")
+	for _, sig := range keySet.sigs {
+		if err = verify(sig, trusted, keySet.records); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DNSKEY set of zone %s is not valid: %w", zone, err)
+	}
+
+	v.mu.Lock()
+	v.keys[zone] = keys
+	v.mu.Unlock()
+	return keys, nil
+}
+
+// zoneDS returns validated DS records of the zone.
+func (v *dnssecValidator) zoneDS(ctx context.Context, query queryFunc, server, zone string) ([]*dns.DS, error) {
+	var anchors []*dns.DS
+	for _, a := range v.anchors {
+		if strings.EqualFold(a.Hdr.Name, zone) {
+			anchors = append(anchors, a)
+		}
+	}
+	if len(anchors) > 0 {
+		return anchors, nil
+	}
+	if zone == "." {
+		return nil, errors.New("no trust anchor for root zone")
+	}
+
+	dsResp, err := v.lookup(ctx, query, server, zone, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+	var dss []*dns.DS
+	for _, set := range groupRRsets(dsResp.Answer) {
+		if len(set.records) == 0 || set.records[0].Header().Rrtype != dns.TypeDS {
+			continue
+		}
+		if err := v.validateRRset(ctx, query, server, set); err != nil {
+			return nil, err
+		}
+		for _, rr := range set.records {
+			dss = append(dss, rr.(*dns.DS))
+		}
+	}
+	if len(dss) == 0 {
+		return nil, fmt.Errorf("no DS found for zone %s", zone)
+	}
+	return dss, nil
+}
+
+func (v *dnssecValidator) lookup(ctx context.Context, query queryFunc, server, zone string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(zone, qtype)
+	m.SetEdns0(4096, true)
+	m.RecursionDesired = true
+	if v.zeroID {
+		m.Id = 0
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+	resp, err := query(reqCtx, server, m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s %s: %w", zone, dns.TypeToString[qtype], err)
+	}
+	return resp, nil
+}
+
+func verify(sig *dns.RRSIG, keys []*dns.DNSKEY, records []dns.RR) error {
+	if !sig.ValidityPeriod(time.Now()) {
+		return errors.New("signature expired")
+	}
+	err := errors.New("no DNSKEY matches the signature")
+	for _, k := range keys {
+		if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm {
+			continue
+		}
+		if err = sig.Verify(k, records); err == nil {
+			return nil
+		}
+	}
+	return err
+}
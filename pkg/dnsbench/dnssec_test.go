@@ -0,0 +1,103 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signedZone struct {
+	key     *dns.DNSKEY
+	keySig  *dns.RRSIG
+	records []dns.RR
+	sig     *dns.RRSIG
+}
+
+func newSignedZone(t *testing.T) signedZone {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	require.NoError(t, err)
+
+	sign := func(records []dns.RR) *dns.RRSIG {
+		sig := &dns.RRSIG{
+			Hdr:        dns.RR_Header{Name: records[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+			Algorithm:  key.Algorithm,
+			SignerName: key.Hdr.Name,
+			KeyTag:     key.KeyTag(),
+			Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+			Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+		}
+		require.NoError(t, sig.Sign(priv.(crypto.Signer), records))
+		return sig
+	}
+
+	records := []dns.RR{A("example.org. 3600 IN A 127.0.0.1")}
+	return signedZone{key: key, keySig: sign([]dns.RR{key}), records: records, sig: sign(records)}
+}
+
+func (z signedZone) query(_ context.Context, _ string, m *dns.Msg) (*dns.Msg, error) {
+	resp := new(dns.Msg)
+	resp.SetReply(m)
+	if m.Question[0].Qtype == dns.TypeDNSKEY {
+		resp.Answer = []dns.RR{z.key, z.keySig}
+	}
+	return resp, nil
+}
+
+func Test_dnssecValidator_validate(t *testing.T) {
+	zone := newSignedZone(t)
+
+	v := newDNSSECValidator(time.Second, false)
+	v.anchors = []*dns.DS{zone.key.ToDS(dns.SHA256)}
+
+	resp := new(dns.Msg)
+	resp.Answer = append(zone.records, zone.sig)
+	assert.NoError(t, v.validate(context.Background(), zone.query, "", resp))
+
+	tampered := new(dns.Msg)
+	tampered.Answer = []dns.RR{A("example.org. 3600 IN A 127.0.0.2"), zone.sig}
+	assert.Error(t, v.validate(context.Background(), zone.query, "", tampered))
+
+	unsigned := new(dns.Msg)
+	unsigned.Answer = zone.records
+	assert.Error(t, v.validate(context.Background(), zone.query, "", unsigned))
+
+	untrusted := newDNSSECValidator(time.Second, false)
+	assert.Error(t, untrusted.validate(context.Background(), zone.query, "", resp), "expected failure for keys not matching trust anchors")
+}
+
+func Test_do_classic_dns_dnssec(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if opt := r.IsEdns0(); opt != nil && opt.Do() {
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.DNSSEC = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	for _, st := range rs {
+		assert.Equal(t, int64(2), st.Counters.Total)
+		assert.Zero(t, st.Counters.ValidationOK)
+		assert.Equal(t, int64(2), st.Counters.ValidationFailed, "expected unsigned responses to fail validation")
+	}
+}
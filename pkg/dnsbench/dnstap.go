@@ -0,0 +1,254 @@
+package dnsbench
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Frame Streams control frame types, see https://farsightsec.github.io/fstrm/.
+const (
+	fstrmControlAccept = 1
+	fstrmControlStart  = 2
+	fstrmControlStop   = 3
+	fstrmControlReady  = 4
+	fstrmControlFinish = 5
+
+	fstrmFieldContentType = 1
+
+	// fstrmMaxFrameSize limits size of the frames, so that corrupted stream cannot exhaust memory.
+	fstrmMaxFrameSize = 1 << 20
+)
+
+var dnstapContentType = []byte("protobuf:dnstap.Dnstap")
+
+// dnstap protobuf field numbers and message types, see https://github.com/dnstap/dnstap.pb/blob/master/dnstap.proto.
+const (
+	dnstapFieldMessage = 14
+
+	dnstapMessageFieldType          = 1
+	dnstapMessageFieldQueryTimeSec  = 8
+	dnstapMessageFieldQueryTimeNsec = 9
+	dnstapMessageFieldQueryMessage  = 10
+
+	dnstapAuthQuery   = 1
+	dnstapClientQuery = 5
+)
+
+// IsDnstapSource returns true if the source is a dnstap socket (unix:/path/to/socket or tcp:host:port) or a dnstap file.
+// Dnstap files start with Frame Streams escape sequence of four zero bytes unlike pcap files.
+func IsDnstapSource(source string) bool {
+	if strings.HasPrefix(source, "unix:") || strings.HasPrefix(source, "tcp:") {
+		return true
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var escape [4]byte
+	if _, err := io.ReadFull(f, escape[:]); err != nil {
+		return false
+	}
+	return binary.BigEndian.Uint32(escape[:]) == 0
+}
+
+// readDnstapQueries reads client and authoritative DNS queries logged in dnstap format and returns them in the order in which they were logged.
+// The source is either a dnstap file or a socket in format unix:/path/to/socket or tcp:host:port, on which dnstap connection of DNS server
+// is accepted and the queries are read until the server finishes the stream.
+func readDnstapQueries(source string) ([]capturedQuery, error) {
+	network, address, socket := strings.Cut(source, ":")
+	if socket && (network == "unix" || network == "tcp") {
+		queries, err := acceptDnstap(network, address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dnstap socket '%s' with error '%v'", source, err)
+		}
+		return queries, nil
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dnstap file '%s' with error '%v'", source, err)
+	}
+	defer f.Close()
+
+	queries, err := parseDnstap(f, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dnstap file '%s' with error '%v'", source, err)
+	}
+	return queries, nil
+}
+
+func acceptDnstap(network, address string) ([]capturedQuery, error) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return parseDnstap(conn, conn)
+}
+
+// parseDnstap reads dnstap messages from Frame Streams, when w is set, the stream is bidirectional and the handshake is answered using w.
+func parseDnstap(r io.Reader, w io.Writer) ([]capturedQuery, error) {
+	br := bufio.NewReader(r)
+	var queries []capturedQuery
+	var first time.Time
+	for {
+		frame, control, err := readFrame(br)
+		if errors.Is(err, io.EOF) {
+			return queries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case control == fstrmControlReady && w != nil:
+			if err := writeControlFrame(w, fstrmControlAccept); err != nil {
+				return nil, err
+			}
+		case control == fstrmControlStop:
+			if w != nil {
+				if err := writeControlFrame(w, fstrmControlFinish); err != nil {
+					return nil, err
+				}
+			}
+			return queries, nil
+		case control == 0:
+			msg, ts, ok := parseDnstapQuery(frame)
+			if !ok {
+				continue
+			}
+			if first.IsZero() {
+				first = ts
+			}
+			queries = append(queries, newCapturedQueries(msg, ts.Sub(first))...)
+		}
+	}
+}
+
+// readFrame reads single frame of Frame Streams, for control frames the type of the control frame is returned.
+func readFrame(r io.Reader) ([]byte, uint32, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, 0, err
+	}
+	control := length == 0
+	if control {
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, 0, unexpectedEOF(err)
+		}
+	}
+	if length > fstrmMaxFrameSize || (control && length < 4) {
+		return nil, 0, fmt.Errorf("invalid frame length %d", length)
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+	if control {
+		return frame, binary.BigEndian.Uint32(frame), nil
+	}
+	return frame, 0, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func writeControlFrame(w io.Writer, control uint32) error {
+	frame := binary.BigEndian.AppendUint32(nil, control)
+	if control == fstrmControlAccept {
+		frame = binary.BigEndian.AppendUint32(frame, fstrmFieldContentType)
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(dnstapContentType)))
+		frame = append(frame, dnstapContentType...)
+	}
+	buf := binary.BigEndian.AppendUint32(nil, 0)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(frame)))
+	_, err := w.Write(append(buf, frame...))
+	return err
+}
+
+// parseDnstapQuery returns DNS query and its time logged in dnstap message, false is returned if the message does not contain
+// client or authoritative query.
+func parseDnstapQuery(b []byte) (*dns.Msg, time.Time, bool) {
+	message, ok := protoBytesField(b, dnstapFieldMessage)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	var msgType, sec uint64
+	var nsec uint32
+	var payload []byte
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return nil, time.Time{}, false
+		}
+		message = message[n:]
+		switch {
+		case num == dnstapMessageFieldType && typ == protowire.VarintType:
+			msgType, n = protowire.ConsumeVarint(message)
+		case num == dnstapMessageFieldQueryTimeSec && typ == protowire.VarintType:
+			sec, n = protowire.ConsumeVarint(message)
+		case num == dnstapMessageFieldQueryTimeNsec && typ == protowire.Fixed32Type:
+			nsec, n = protowire.ConsumeFixed32(message)
+		case num == dnstapMessageFieldQueryMessage && typ == protowire.BytesType:
+			payload, n = protowire.ConsumeBytes(message)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, message)
+		}
+		if n < 0 {
+			return nil, time.Time{}, false
+		}
+		message = message[n:]
+	}
+
+	if msgType != dnstapClientQuery && msgType != dnstapAuthQuery {
+		return nil, time.Time{}, false
+	}
+	msg := dns.Msg{}
+	if err := msg.Unpack(payload); err != nil || !isQuery(&msg) {
+		return nil, time.Time{}, false
+	}
+	return &msg, time.Unix(int64(sec), int64(nsec)), true
+}
+
+// protoBytesField returns value of the length delimited field of protobuf message.
+func protoBytesField(b []byte, field protowire.Number) ([]byte, bool) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, false
+		}
+		b = b[n:]
+		if num == field && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			return v, n >= 0
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return nil, false
+		}
+		b = b[n:]
+	}
+	return nil, false
+}
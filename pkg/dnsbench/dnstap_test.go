@@ -0,0 +1,164 @@
+package dnsbench
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func Test_readDnstapQueries(t *testing.T) {
+	start := time.Unix(1700000000, 500)
+	file := writeDnstap(t,
+		dnstapMessage(dnstapClientQuery, start, packQuery("example.org.", dns.TypeA)),
+		// resolver queries and client responses are skipped
+		dnstapMessage(3, start.Add(time.Millisecond), packQuery("example.net.", dns.TypeA)),
+		dnstapMessage(6, start.Add(time.Millisecond), packResponse("example.org.", dns.TypeA)),
+		dnstapMessage(dnstapClientQuery, start.Add(20*time.Millisecond), []byte("not a DNS message")),
+		dnstapMessage(dnstapAuthQuery, start.Add(30*time.Millisecond), packEdnsQuery("example.com.", dns.TypeAAAA)),
+	)
+
+	queries, err := readDnstapQueries(file)
+
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.Equal(t, dns.Question{Name: "example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}, queries[0].question)
+	assert.Nil(t, queries[0].opt)
+	assert.Zero(t, queries[0].offset)
+
+	assert.Equal(t, dns.Question{Name: "example.com.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}, queries[1].question)
+	require.NotNil(t, queries[1].opt)
+	assert.True(t, queries[1].opt.Do())
+	assert.Equal(t, 30*time.Millisecond, queries[1].offset)
+}
+
+func Test_readDnstapQueries_missing_file(t *testing.T) {
+	_, err := readDnstapQueries(filepath.Join(t.TempDir(), "missing.dnstap"))
+
+	assert.Error(t, err)
+}
+
+func Test_readDnstapQueries_truncated_file(t *testing.T) {
+	file := writeDnstap(t, dnstapMessage(dnstapClientQuery, time.Now(), packQuery("example.org.", dns.TypeA)))
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	// cut the stop frame and part of the data frame
+	require.NoError(t, os.WriteFile(file, data[:len(data)-16], 0o600))
+
+	_, err = readDnstapQueries(file)
+
+	assert.Error(t, err)
+}
+
+func Test_readDnstapQueries_socket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "dnstap.sock")
+
+	type result struct {
+		queries []capturedQuery
+		err     error
+	}
+	done := make(chan result)
+	go func() {
+		queries, err := readDnstapQueries("unix:" + socket)
+		done <- result{queries: queries, err: err}
+	}()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		var err error
+		conn, err = net.Dial("unix", socket)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer conn.Close()
+
+	// bidirectional Frame Streams handshake of the DNS server logging the queries
+	_, err := conn.Write(controlFrame(fstrmControlReady))
+	require.NoError(t, err)
+	_, control, err := readFrame(conn)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(fstrmControlAccept), control)
+
+	_, err = conn.Write(controlFrame(fstrmControlStart))
+	require.NoError(t, err)
+	_, err = conn.Write(dataFrame(dnstapMessage(dnstapClientQuery, time.Now(), packQuery("example.org.", dns.TypeA))))
+	require.NoError(t, err)
+	_, err = conn.Write(controlFrame(fstrmControlStop))
+	require.NoError(t, err)
+
+	_, control, err = readFrame(conn)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(fstrmControlFinish), control)
+
+	res := <-done
+	require.NoError(t, res.err)
+	require.Len(t, res.queries, 1)
+	assert.Equal(t, "example.org.", res.queries[0].question.Name)
+}
+
+func Test_isDnstapSource(t *testing.T) {
+	assert.True(t, IsDnstapSource(writeDnstap(t)))
+	assert.False(t, IsDnstapSource(writePcap(t, udpPacket(t, 53, packQuery("example.org.", dns.TypeA)))))
+	assert.True(t, IsDnstapSource("unix:/var/run/dnstap.sock"))
+	assert.True(t, IsDnstapSource("tcp:127.0.0.1:6000"))
+	assert.False(t, IsDnstapSource(filepath.Join(t.TempDir(), "missing")))
+}
+
+func dnstapMessage(msgType uint64, ts time.Time, query []byte) []byte {
+	var msg []byte
+	msg = protowire.AppendTag(msg, dnstapMessageFieldType, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, msgType)
+	// socket family is not used and has to be skipped
+	msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, 1)
+	msg = protowire.AppendTag(msg, dnstapMessageFieldQueryTimeSec, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(ts.Unix()))
+	msg = protowire.AppendTag(msg, dnstapMessageFieldQueryTimeNsec, protowire.Fixed32Type)
+	msg = protowire.AppendFixed32(msg, uint32(ts.Nanosecond()))
+	msg = protowire.AppendTag(msg, dnstapMessageFieldQueryMessage, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, query)
+
+	var frame []byte
+	// identity of the DNS server is not used and has to be skipped
+	frame = protowire.AppendTag(frame, 1, protowire.BytesType)
+	frame = protowire.AppendBytes(frame, []byte("resolver"))
+	frame = protowire.AppendTag(frame, dnstapFieldMessage, protowire.BytesType)
+	frame = protowire.AppendBytes(frame, msg)
+	frame = protowire.AppendTag(frame, 15, protowire.VarintType)
+	frame = protowire.AppendVarint(frame, 1)
+	return frame
+}
+
+func controlFrame(control uint32) []byte {
+	frame := binary.BigEndian.AppendUint32(nil, control)
+	if control == fstrmControlStart || control == fstrmControlReady {
+		frame = binary.BigEndian.AppendUint32(frame, fstrmFieldContentType)
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(dnstapContentType)))
+		frame = append(frame, dnstapContentType...)
+	}
+	buf := binary.BigEndian.AppendUint32(nil, 0)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(frame)))
+	return append(buf, frame...)
+}
+
+func dataFrame(payload []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(payload))), payload...)
+}
+
+func writeDnstap(t *testing.T, messages ...[]byte) string {
+	data := controlFrame(fstrmControlStart)
+	for _, m := range messages {
+		data = append(data, dataFrame(m)...)
+	}
+	data = append(data, controlFrame(fstrmControlStop)...)
+
+	file := filepath.Join(t.TempDir(), "queries.dnstap")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+	return file
+}
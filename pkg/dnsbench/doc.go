@@ -0,0 +1,30 @@
+// Package dnsbench implements DNS benchmarks executed by the dnspyre command, so the benchmarks can be embedded into other programs and test harnesses.
+//
+// Benchmark created by New has the same defaults as the dnspyre command, the results of parallel benchmark workers returned by Benchmark.Run
+// can be merged using Merge and reported using Benchmark.PrintReport:
+//
+//	bench := dnsbench.New("127.0.0.1:53", "example.org")
+//	bench.Concurrency = 10
+//	bench.Duration = 30 * time.Second
+//	bench.Silent = true
+//	bench.ProgressFunc = func(p dnsbench.ProgressStats) {
+//		log.Printf("%d requests, %.1f QPS", p.Requests, p.QueriesPerSecond)
+//	}
+//
+//	results, err := bench.Run(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	merged := dnsbench.Merge(results)
+//	p99 := time.Duration(merged.Hist.ValueAtQuantile(99))
+//
+// Queries can be modified and responses validated by custom code using Benchmark.RequestHook and Benchmark.ResponseHook,
+// responses rejected by the response hook are counted in ResultStats.HookErrors by the returned error:
+//
+//	bench.ResponseHook = func(q, r *dns.Msg) error {
+//		if len(r.Answer) == 0 {
+//			return errors.New("no answers")
+//		}
+//		return nil
+//	}
+package dnsbench
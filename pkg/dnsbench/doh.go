@@ -0,0 +1,199 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DoHStats holds statistics of the HTTP layer of DoH responses.
+type DoHStats struct {
+	// Statuses counts the responses by HTTP status code.
+	Statuses map[int]int64 `json:"statuses,omitempty"`
+	// Servers counts the responses by the value of Server header, which usually identifies the server software or the CDN.
+	Servers map[string]int64 `json:"servers,omitempty"`
+	// Via counts the responses by the value of Via header, which usually identifies the proxies or the CDN PoP, which forwarded the response.
+	Via map[string]int64 `json:"via,omitempty"`
+	// ReusedConnections and NewConnections count the requests sent over reused and newly established connections.
+	// Connections of HTTP/3 are not traced, so they are not counted.
+	ReusedConnections int64 `json:"reusedConnections,omitempty"`
+	NewConnections    int64 `json:"newConnections,omitempty"`
+	// Methods holds the statistics of the responses by HTTP method of the requests.
+	Methods map[string]DoHMethodStats `json:"methods,omitempty"`
+}
+
+// DoHMethodStats holds cacheability statistics of DoH responses to the requests sent via single HTTP method.
+type DoHMethodStats struct {
+	Responses int64 `json:"responses"`
+	// Cacheable counts the responses allowed to be cached by HTTP caches, that is responses with positive max-age in Cache-Control header
+	// and without no-store or no-cache directives.
+	Cacheable int64 `json:"cacheable"`
+	// Cached counts the responses with Age header, which is added by HTTP caches to responses served from the cache.
+	Cached int64 `json:"cached"`
+}
+
+func newDoHStats() *DoHStats {
+	return &DoHStats{Statuses: make(map[int]int64), Servers: make(map[string]int64), Via: make(map[string]int64), Methods: make(map[string]DoHMethodStats)}
+}
+
+func (d *DoHStats) add(o *DoHStats) {
+	for k, v := range o.Statuses {
+		d.Statuses[k] += v
+	}
+	for k, v := range o.Servers {
+		d.Servers[k] += v
+	}
+	for k, v := range o.Via {
+		d.Via[k] += v
+	}
+	d.ReusedConnections += o.ReusedConnections
+	d.NewConnections += o.NewConnections
+	for k, v := range o.Methods {
+		m := d.Methods[k]
+		m.Responses += v.Responses
+		m.Cacheable += v.Cacheable
+		m.Cached += v.Cached
+		d.Methods[k] = m
+	}
+}
+
+// isCacheable decides whether the response is allowed to be cached by HTTP caches based on its Cache-Control header.
+func isCacheable(h http.Header) bool {
+	var maxAge bool
+	for _, d := range strings.Split(strings.ToLower(h.Get("Cache-Control")), ",") {
+		d = strings.TrimSpace(d)
+		switch {
+		case d == "no-store" || d == "no-cache":
+			return false
+		case strings.HasPrefix(d, "max-age="):
+			age, err := strconv.Atoi(strings.TrimPrefix(d, "max-age="))
+			maxAge = err == nil && age > 0
+		}
+	}
+	return maxAge
+}
+
+// protocolRecorder is recording HTTP protocols, status codes, Server and Via headers of responses returned by the inner round tripper
+// and whether the requests reused connections, the times of the first and the last byte of the responses are recorded as well, see Benchmark.FirstByte.
+type protocolRecorder struct {
+	inner http.RoundTripper
+	st    *ResultStats
+}
+
+func (p *protocolRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	doh := p.st.DoH
+	if doh != nil {
+		trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				doh.ReusedConnections++
+			} else {
+				doh.NewConnections++
+			}
+		}}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	}
+	timed := p.st.FirstByteHists != nil
+	if timed {
+		p.st.firstByte = readTimer{}
+		timer := &p.st.firstByte
+		trace := &httptrace.ClientTrace{WroteRequest: func(httptrace.WroteRequestInfo) { timer.written() }, GotFirstResponseByte: timer.read}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	}
+	if qt := queryTraceFrom(req.Context()); qt != nil {
+		trace := &httptrace.ClientTrace{WroteRequest: func(httptrace.WroteRequestInfo) { qt.written() }}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	}
+
+	resp, err := p.inner.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	p.st.DoHProtocols[resp.Proto]++
+	if timed {
+		resp.Body = &firstByteBody{ReadCloser: resp.Body, timer: &p.st.firstByte}
+	}
+	if doh != nil {
+		doh.Statuses[resp.StatusCode]++
+		if server := resp.Header.Get("Server"); server != "" {
+			doh.Servers[server]++
+		}
+		if via := resp.Header.Get("Via"); via != "" {
+			doh.Via[via]++
+		}
+		m := doh.Methods[req.Method]
+		m.Responses++
+		if isCacheable(resp.Header) {
+			m.Cacheable++
+		}
+		if resp.Header.Get("Age") != "" {
+			m.Cached++
+		}
+		doh.Methods[req.Method] = m
+	}
+	return resp, err
+}
+
+// parseDoHHeaders parses the headers in format "Name: value" and the User-Agent header, nil is returned when there are no headers.
+func parseDoHHeaders(headers []string, userAgent string) (http.Header, error) {
+	if len(headers) == 0 && userAgent == "" {
+		return nil, nil
+	}
+	h := make(http.Header)
+	for _, s := range headers {
+		name, value, ok := strings.Cut(s, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header '%s', expected format 'Name: value'", s)
+		}
+		h.Add(name, strings.TrimSpace(value))
+	}
+	if userAgent != "" {
+		h.Set("User-Agent", userAgent)
+	}
+	return h, nil
+}
+
+// headerSetter adds the headers to the requests sent by the inner round tripper, Host header overrides the host of the requests.
+type headerSetter struct {
+	inner  http.RoundTripper
+	header http.Header
+}
+
+func (s *headerSetter) RoundTrip(req *http.Request) (*http.Response, error) {
+	// round trippers must not modify the requests
+	req = req.Clone(req.Context())
+	for k, v := range s.header {
+		if k == "Host" {
+			req.Host = v[0]
+			continue
+		}
+		req.Header[k] = v
+	}
+	return s.inner.RoundTrip(req)
+}
+
+// alternateDoHMethods returns query function alternating GET and POST requests, latencies of the responses are recorded by the method
+// to the provided stats, so that both methods can be compared under the same network conditions.
+func alternateDoHMethods(get, post queryFunc, st *ResultStats) queryFunc {
+	var n int64
+	return func(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+		method, query := http.MethodGet, get
+		if n%2 == 1 {
+			method, query = http.MethodPost, post
+		}
+		n++
+
+		start := time.Now()
+		resp, err := query(ctx, server, msg)
+		if err == nil && st.DoHMethodHists != nil {
+			recordKeyed(st.DoHMethodHists, method, st.Hist, time.Since(start).Nanoseconds())
+		}
+		return resp, err
+	}
+}
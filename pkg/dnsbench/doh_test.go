@@ -0,0 +1,226 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_do_doh_http_stats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bd, err := io.ReadAll(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		msg := dns.Msg{}
+		if err := msg.Unpack(bd); err != nil {
+			panic(err)
+		}
+
+		w.Header().Set("Server", "test-doh")
+		w.Header().Set("Via", "1.1 pop-ams")
+		if msg.Question[0].Qtype == dns.TypeAAAA {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		msg.Response = true
+		pack, err := msg.Pack()
+		if err != nil {
+			panic(err)
+		}
+		w.Write(pack)
+	}))
+	defer ts.Close()
+
+	bench := createBenchmark(ts.URL, true, 1)
+	bench.DohMethod = post
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.NotNil(t, merged.DoH)
+	assert.Equal(t, map[int]int64{http.StatusOK: 2, http.StatusServiceUnavailable: 2}, merged.DoH.Statuses)
+	assert.Equal(t, map[string]int64{"test-doh": 4}, merged.DoH.Servers)
+	assert.Equal(t, map[string]int64{"1.1 pop-ams": 4}, merged.DoH.Via)
+	assert.Equal(t, int64(4), merged.DoH.ReusedConnections+merged.DoH.NewConnections)
+	assert.Positive(t, merged.DoH.ReusedConnections, "idle connections are reused")
+	assert.Equal(t, int64(2), merged.Counters.IOError)
+}
+
+func Test_do_doh_both_methods(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var bd []byte
+		var err error
+		if r.Method == http.MethodGet {
+			bd, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+			// responses to GET requests are cacheable and served from the cache
+			w.Header().Set("Cache-Control", "max-age=300")
+			w.Header().Set("Age", "10")
+		} else {
+			bd, err = io.ReadAll(r.Body)
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		if err != nil {
+			panic(err)
+		}
+		msg := dns.Msg{}
+		if err := msg.Unpack(bd); err != nil {
+			panic(err)
+		}
+		msg.Response = true
+		pack, err := msg.Pack()
+		if err != nil {
+			panic(err)
+		}
+		w.Write(pack)
+	}))
+	defer ts.Close()
+
+	bench := createBenchmark(ts.URL, true, 1)
+	bench.DohMethod = "both"
+	bench.Count = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.NotNil(t, merged.DoH)
+	assert.Equal(t, map[string]DoHMethodStats{
+		http.MethodGet:  {Responses: 4, Cacheable: 4, Cached: 4},
+		http.MethodPost: {Responses: 4},
+	}, merged.DoH.Methods)
+	require.Len(t, merged.DoHMethodHists, 2)
+	assert.Equal(t, int64(4), merged.DoHMethodHists[http.MethodGet].TotalCount())
+	assert.Equal(t, int64(4), merged.DoHMethodHists[http.MethodPost].TotalCount())
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "DoH timings by HTTP method:")
+	assert.Contains(t, buf.String(), "DoH responses by HTTP method:")
+}
+
+func Test_isCacheable(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         bool
+	}{
+		{cacheControl: "max-age=300", want: true},
+		{cacheControl: "public, Max-Age=60", want: true},
+		{cacheControl: "max-age=0"},
+		{cacheControl: "max-age=300, no-store"},
+		{cacheControl: "no-cache"},
+		{cacheControl: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			h := http.Header{}
+			h.Set("Cache-Control", tt.cacheControl)
+			assert.Equal(t, tt.want, isCacheable(h))
+		})
+	}
+}
+
+func TestBenchmark_PrintReport_doh(t *testing.T) {
+	b, rs := testData()
+	rs.DoH = &DoHStats{
+		Statuses:          map[int]int64{http.StatusOK: 3, http.StatusBadGateway: 1},
+		Servers:           map[string]int64{"cloudflare": 4},
+		Via:               map[string]int64{"1.1 varnish": 4},
+		ReusedConnections: 3,
+		NewConnections:    1,
+	}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.Contains(t, buf.String(), "DoH HTTP status codes:\n\t200:\t3\n\t502:\t1\n")
+	assert.Contains(t, buf.String(), "DoH Server headers:\n\tcloudflare:\t4\n")
+	assert.Contains(t, buf.String(), "DoH Via headers:\n\t1.1 varnish:\t4\n")
+	assert.Contains(t, buf.String(), "DoH requests by connection:\n\treused:\t3\n\tnew:\t1\n")
+
+	b.JSON = true
+	buf.Reset()
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.Contains(t, buf.String(), `"doh":{"statuses":{"200":3,"502":1},"servers":{"cloudflare":4},"via":{"1.1 varnish":4},"reusedConnections":3,"newConnections":1}`)
+}
+
+func dohReplyHandler(w http.ResponseWriter, r *http.Request) {
+	bd, err := io.ReadAll(r.Body)
+	if err != nil {
+		panic(err)
+	}
+	msg := dns.Msg{}
+	if err := msg.Unpack(bd); err != nil {
+		panic(err)
+	}
+	msg.Response = true
+	pack, err := msg.Pack()
+	if err != nil {
+		panic(err)
+	}
+	w.Write(pack)
+}
+
+func Test_do_doh_connection_pool(t *testing.T) {
+	tests := []struct {
+		protocol string
+		want     func(t *testing.T, conns int64)
+	}{
+		{protocol: "1.1", want: func(t *testing.T, conns int64) { assert.LessOrEqual(t, conns, int64(3)) }},
+		{protocol: "2", want: func(t *testing.T, conns int64) { assert.Equal(t, int64(3), conns) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			var mu sync.Mutex
+			conns := make(map[string]bool)
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				conns[r.RemoteAddr] = true
+				mu.Unlock()
+				dohReplyHandler(w, r)
+			}))
+			ts.EnableHTTP2 = tt.protocol == "2"
+			ts.StartTLS()
+			defer ts.Close()
+
+			bench := createBenchmark(ts.URL, true, 1)
+			bench.Concurrency = 10
+			bench.Count = 5
+			bench.Insecure = true
+			bench.DohMethod = post
+			bench.DohProtocol = tt.protocol
+			bench.DoHConnections = 3
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+			require.NoError(t, err, "expected no error from benchmark run")
+
+			merged := Merge(rs)
+			assert.Equal(t, int64(100), merged.Counters.Success)
+			require.NotNil(t, merged.Connections)
+			tt.want(t, merged.Connections.Count)
+			mu.Lock()
+			assert.Equal(t, int64(len(conns)), merged.Connections.Count, "opened connections are reported")
+			mu.Unlock()
+
+			buf := bytes.Buffer{}
+			require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+			assert.Contains(t, buf.String(), "DoH connection pool:\t\t3 connections")
+		})
+	}
+}
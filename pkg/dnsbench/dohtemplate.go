@@ -0,0 +1,128 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// dohTemplate is RFC 8484 URI template of DoH server, for example https://dns.example.com/resolve{?dns}. Only the dns variable
+// is supported, expanded by simple ({dns}), form-style query ({?dns}) or query continuation ({&dns}) expression of RFC 6570.
+type dohTemplate struct {
+	prefix string
+	op     string
+	suffix string
+}
+
+// parseDoHTemplate parses the URI template of DoH server, nil is returned when the server is not a template.
+func parseDoHTemplate(server string) (*dohTemplate, error) {
+	start := strings.Index(server, "{")
+	if start < 0 {
+		if strings.Contains(server, "}") {
+			return nil, fmt.Errorf("invalid DoH URI template '%s', unmatched '}'", server)
+		}
+		return nil, nil
+	}
+	end := strings.Index(server[start:], "}")
+	if end < 0 {
+		return nil, fmt.Errorf("invalid DoH URI template '%s', unterminated expression", server)
+	}
+	end += start
+	if strings.ContainsAny(server[end+1:], "{}") {
+		return nil, fmt.Errorf("invalid DoH URI template '%s', only single expression with dns variable is supported", server)
+	}
+
+	expr := server[start+1 : end]
+	t := &dohTemplate{prefix: server[:start], suffix: server[end+1:]}
+	if strings.HasPrefix(expr, "?") || strings.HasPrefix(expr, "&") {
+		t.op, expr = expr[:1], expr[1:]
+	}
+	if expr != "dns" {
+		return nil, fmt.Errorf("invalid DoH URI template '%s', expression '{%s}' has to expand the dns variable", server, server[start+1:end])
+	}
+	return t, nil
+}
+
+// expand expands the template with the dns variable, the variable is undefined and its expression is removed when the value is empty.
+func (t *dohTemplate) expand(value string) string {
+	if value == "" {
+		return t.prefix + t.suffix
+	}
+	switch t.op {
+	case "?", "&":
+		return t.prefix + t.op + "dns=" + value + t.suffix
+	default:
+		return t.prefix + value + t.suffix
+	}
+}
+
+// parseDoHTemplates parses URI templates of the DoH servers, the templates are keyed by the servers.
+func parseDoHTemplates(servers ...string) (map[string]*dohTemplate, error) {
+	var templates map[string]*dohTemplate
+	for _, s := range servers {
+		if doh, _ := isHTTPUrl(s); !doh {
+			continue
+		}
+		t, err := parseDoHTemplate(s)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			continue
+		}
+		if templates == nil {
+			templates = make(map[string]*dohTemplate)
+		}
+		templates[s] = t
+	}
+	return templates, nil
+}
+
+// templateDoHQuery returns DoH query function sending the queries to the URLs expanded from the URI templates of the servers, the servers
+// without templates are queried by the inner query function. POST requests are sent by the inner function to the template expanded
+// without the dns variable, GET requests are sent by the client with the query in the dns variable.
+func templateDoHQuery(c *http.Client, templates map[string]*dohTemplate, method string, inner queryFunc) queryFunc {
+	return func(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+		t, ok := templates[server]
+		if !ok {
+			return inner(ctx, server, msg)
+		}
+		if method == http.MethodPost {
+			return inner(ctx, t.expand(""), msg)
+		}
+
+		pack, err := msg.Pack()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.expand(base64.RawURLEncoding.EncodeToString(pack)), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/dns-message")
+		resp, err := c.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			// the same error as returned by the DoH client for the servers without templates
+			return nil, errors.New("unexpected HTTP status")
+		}
+		var body bytes.Buffer
+		if _, err := body.ReadFrom(resp.Body); err != nil {
+			return nil, err
+		}
+		var res dns.Msg
+		if err := res.Unpack(body.Bytes()); err != nil {
+			return nil, err
+		}
+		return &res, nil
+	}
+}
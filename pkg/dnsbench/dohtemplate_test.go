@@ -0,0 +1,146 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseDoHTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		noDNS    string
+		withDNS  string
+		wantErr  bool
+	}{
+		{template: "https://dns.example.com/resolve{?dns}", noDNS: "https://dns.example.com/resolve", withDNS: "https://dns.example.com/resolve?dns=AAAB"},
+		{template: "https://dns.example.com/q?tenant=a{&dns}", noDNS: "https://dns.example.com/q?tenant=a", withDNS: "https://dns.example.com/q?tenant=a&dns=AAAB"},
+		{template: "https://dns.example.com/{dns}/query", noDNS: "https://dns.example.com//query", withDNS: "https://dns.example.com/AAAB/query"},
+		{template: "https://dns.example.com/dns-query"},
+		{template: "https://dns.example.com/resolve{?name}", wantErr: true},
+		{template: "https://dns.example.com/resolve{?dns", wantErr: true},
+		{template: "https://dns.example.com/resolve}", wantErr: true},
+		{template: "https://dns.example.com/{dns}{?dns}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := parseDoHTemplate(tt.template)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.noDNS == "" {
+				assert.Nil(t, tmpl, "server without expression is not a template")
+				return
+			}
+			assert.Equal(t, tt.noDNS, tmpl.expand(""))
+			assert.Equal(t, tt.withDNS, tmpl.expand("AAAB"))
+		})
+	}
+}
+
+func Test_parseDoHHeaders(t *testing.T) {
+	h, err := parseDoHHeaders([]string{"Authorization: Bearer token", "x-tenant:a", "X-Tenant: b"}, "dnspyre-test")
+	require.NoError(t, err)
+	assert.Equal(t, http.Header{"Authorization": {"Bearer token"}, "X-Tenant": {"a", "b"}, "User-Agent": {"dnspyre-test"}}, h)
+
+	h, err = parseDoHHeaders(nil, "")
+	require.NoError(t, err)
+	assert.Nil(t, h)
+
+	for _, header := range []string{"Authorization", ": value", "Bad Name: value"} {
+		_, err := parseDoHHeaders([]string{header}, "")
+		assert.Error(t, err, header)
+	}
+}
+
+func TestBenchmark_Run_dohTemplate(t *testing.T) {
+	for _, method := range []string{get, post} {
+		t.Run(method, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests []*http.Request
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var query []byte
+				var err error
+				if r.Method == http.MethodGet {
+					query, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+				} else {
+					query, err = io.ReadAll(r.Body)
+				}
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				mu.Lock()
+				requests = append(requests, r)
+				mu.Unlock()
+
+				var m dns.Msg
+				if err := m.Unpack(query); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				ret := new(dns.Msg)
+				ret.SetReply(&m)
+				ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+				pack, err := ret.Pack()
+				if err != nil {
+					panic(err)
+				}
+				w.Header().Set("Content-Type", "application/dns-message")
+				w.Write(pack)
+			}))
+			defer ts.Close()
+
+			bench := createBenchmark(ts.URL+"/custom/resolve?tenant=a{&dns}", true, 1)
+			bench.DohMethod = method
+			bench.DoHHeaders = []string{"Authorization: Bearer secret", "Host: dns.example.com"}
+			bench.UserAgent = "dnspyre-test"
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+			require.NoError(t, err, "expected no error from benchmark run")
+
+			merged := Merge(rs)
+			assert.Equal(t, int64(4), merged.Counters.Success)
+			mu.Lock()
+			defer mu.Unlock()
+			require.Len(t, requests, 4)
+			for _, r := range requests {
+				assert.Equal(t, "/custom/resolve", r.URL.Path)
+				assert.Equal(t, "a", r.URL.Query().Get("tenant"))
+				assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+				assert.Equal(t, "dnspyre-test", r.UserAgent())
+				assert.Equal(t, "dns.example.com", r.Host)
+			}
+		})
+	}
+}
+
+func TestBenchmark_normalize_dohHeaders(t *testing.T) {
+	tests := []struct {
+		name  string
+		bench Benchmark
+	}{
+		{name: "not doh", bench: Benchmark{Server: "8.8.8.8", DoHHeaders: []string{"Authorization: Bearer secret"}}},
+		{name: "user agent not doh", bench: Benchmark{Server: "8.8.8.8", UserAgent: "dnspyre"}},
+		{name: "invalid header", bench: Benchmark{Server: "https://8.8.8.8/dns-query", DoHHeaders: []string{"Authorization"}}},
+		{name: "invalid template", bench: Benchmark{Server: "https://8.8.8.8/resolve{?name}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.bench.normalize())
+		})
+	}
+}
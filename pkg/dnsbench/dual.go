@@ -0,0 +1,149 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+// DualStats holds statistics of A and AAAA queries sent concurrently for each name, see Benchmark.DualAAAA.
+type DualStats struct {
+	// FamilyHists holds latency histograms of the A and AAAA queries.
+	FamilyHists map[string]*hdrhistogram.Histogram
+	// FamilyErrors counts failed A and AAAA queries.
+	FamilyErrors map[string]int64
+	// FirstUsable is a histogram of times to the first usable answer, the answer containing addresses of the queried family.
+	FirstUsable *hdrhistogram.Histogram
+	// Unusable counts names, for which neither of the queries returned addresses.
+	Unusable int64
+}
+
+func newDualStats(template *hdrhistogram.Histogram) *DualStats {
+	return &DualStats{
+		FamilyHists:  make(map[string]*hdrhistogram.Histogram),
+		FamilyErrors: make(map[string]int64),
+		FirstUsable:  hdrhistogram.New(template.LowestTrackableValue(), template.HighestTrackableValue(), int(template.SignificantFigures())),
+	}
+}
+
+func (d *DualStats) add(o *DualStats) {
+	d.FamilyHists = mergeKeyed(d.FamilyHists, o.FamilyHists)
+	if o.FamilyErrors != nil {
+		if d.FamilyErrors == nil {
+			d.FamilyErrors = make(map[string]int64)
+		}
+		for k, v := range o.FamilyErrors {
+			d.FamilyErrors[k] += v
+		}
+	}
+	if o.FirstUsable != nil {
+		if d.FirstUsable == nil {
+			d.FirstUsable = hdrhistogram.New(o.FirstUsable.LowestTrackableValue(), o.FirstUsable.HighestTrackableValue(), int(o.FirstUsable.SignificantFigures()))
+		}
+		d.FirstUsable.Merge(o.FirstUsable)
+	}
+	d.Unusable += o.Unusable
+}
+
+// dualAnswer is a result of one of the dual queries.
+type dualAnswer struct {
+	resp     *dns.Msg
+	err      error
+	duration time.Duration
+}
+
+// usable checks whether the answer contains records of the queried type.
+func (a dualAnswer) usable(qtype uint16) bool {
+	if a.err != nil || a.resp == nil || a.resp.Rcode != dns.RcodeSuccess {
+		return false
+	}
+	for _, rr := range a.resp.Answer {
+		if rr.Header().Rrtype == qtype {
+			return true
+		}
+	}
+	return false
+}
+
+// withDualAAAA returns query function, which sends AAAA query using aaaaQuery concurrently with each A query, the same way getaddrinfo does.
+// The A query is finished once both answers are received, so its latency is the time the application waits for the resolution. aaaaQuery
+// records its counters to aaaaStats, which are added to st after each query, so that the queries do not share the stats concurrently.
+// The answers are kept in st until they are recorded by the worker, see ResultStats.recordDual.
+func withDualAAAA(aQuery, aaaaQuery queryFunc, st, aaaaStats *ResultStats) queryFunc {
+	return func(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+		if msg.Question[0].Qtype != dns.TypeA {
+			return aQuery(ctx, server, msg)
+		}
+		aaaa := msg.Copy()
+		aaaa.Id = msg.Id + 1
+		aaaa.Question[0].Qtype = dns.TypeAAAA
+
+		start := time.Now()
+		done := make(chan dualAnswer, 1)
+		go func() {
+			r, err := aaaaQuery(ctx, server, aaaa)
+			done <- dualAnswer{resp: r, err: err, duration: time.Since(start)}
+		}()
+		r, err := aQuery(ctx, server, msg)
+		v4 := dualAnswer{resp: r, err: err, duration: time.Since(start)}
+		v6 := <-done
+
+		st.Counters.add(aaaaStats.Counters)
+		*aaaaStats.Counters = Counters{}
+		st.dualAnswers = []dualAnswer{v4, v6}
+		return r, err
+	}
+}
+
+// recordDual records latencies of the last A and AAAA answers and the time to the first usable answer.
+func (rs *ResultStats) recordDual() {
+	if rs.dualAnswers == nil {
+		return
+	}
+	v4, v6 := rs.dualAnswers[0], rs.dualAnswers[1]
+	rs.dualAnswers = nil
+	first := time.Duration(-1)
+	for _, a := range []struct {
+		family string
+		qtype  uint16
+		dualAnswer
+	}{{"A", dns.TypeA, v4}, {"AAAA", dns.TypeAAAA, v6}} {
+		if a.err != nil {
+			rs.Dual.FamilyErrors[a.family]++
+			continue
+		}
+		recordKeyed(rs.Dual.FamilyHists, a.family, rs.Hist, a.duration.Nanoseconds())
+		if a.usable(a.qtype) && (first < 0 || a.duration < first) {
+			first = a.duration
+		}
+	}
+	if first < 0 {
+		rs.Dual.Unusable++
+		return
+	}
+	rs.Dual.FirstUsable.RecordValue(first.Nanoseconds())
+}
+
+func printDual(w io.Writer, d *DualStats) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Dual A and AAAA queries:")
+	for _, family := range []string{"A", "AAAA"} {
+		h, ok := d.FamilyHists[family]
+		if !ok {
+			fmt.Fprintf(w, "\t%s:\t%s errors\n", family, highlightStr(d.FamilyErrors[family]))
+			continue
+		}
+		fmt.Fprintf(w, "\t%s:\t%s answers, %s errors, p50 %s, p95 %s, p99 %s\n", family, highlightStr(h.TotalCount()), highlightStr(d.FamilyErrors[family]),
+			highlightStr(roundDuration(time.Duration(h.ValueAtQuantile(50)))), highlightStr(roundDuration(time.Duration(h.ValueAtQuantile(95)))),
+			highlightStr(roundDuration(time.Duration(h.ValueAtQuantile(99)))))
+	}
+	fmt.Fprintf(w, "\tNames without usable answer:\t%s\n", highlightStr(d.Unusable))
+	if d.FirstUsable != nil && d.FirstUsable.TotalCount() > 0 {
+		fmt.Fprintln(w, "Time to first usable answer,", highlightStr(d.FirstUsable.TotalCount()), "datapoints")
+		printTimings(w, d.FirstUsable)
+	}
+}
@@ -0,0 +1,113 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_withDualAAAA(t *testing.T) {
+	hist := hdrhistogram.New(1, time.Minute.Nanoseconds(), 3)
+	st := &ResultStats{Hist: hist, Counters: &Counters{}, Dual: newDualStats(hist)}
+	aaaaStats := &ResultStats{Counters: &Counters{}}
+	reply := func(delay time.Duration, rr string) queryFunc {
+		return func(_ context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+			time.Sleep(delay)
+			r := new(dns.Msg).SetReply(msg)
+			if rr != "" {
+				r.Answer = append(r.Answer, mustRR(t, rr))
+			}
+			return r, nil
+		}
+	}
+	aaaaQuery := func(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+		aaaaStats.Counters.ServerCloses++
+		assert.Equal(t, dns.TypeAAAA, msg.Question[0].Qtype)
+		return reply(100*time.Millisecond, "example.org. 60 IN AAAA ::1")(ctx, server, msg)
+	}
+
+	query := withDualAAAA(reply(100*time.Millisecond, ""), aaaaQuery, st, aaaaStats)
+	start := time.Now()
+	r, err := query(context.Background(), "127.0.0.1:53", new(dns.Msg).SetQuestion("example.org.", dns.TypeA))
+	require.NoError(t, err)
+	assert.Equal(t, dns.TypeA, r.Question[0].Qtype, "response of the A query is returned")
+	assert.Less(t, time.Since(start), 190*time.Millisecond, "the queries are sent concurrently")
+	assert.Equal(t, int64(1), st.Counters.ServerCloses, "counters of AAAA queries are added")
+	assert.Zero(t, aaaaStats.Counters.ServerCloses)
+
+	st.recordDual()
+	assert.Equal(t, int64(1), st.Dual.FamilyHists["A"].TotalCount())
+	assert.Equal(t, int64(1), st.Dual.FamilyHists["AAAA"].TotalCount())
+	assert.Equal(t, int64(1), st.Dual.FirstUsable.TotalCount(), "only AAAA answer is usable")
+	assert.Zero(t, st.Dual.Unusable)
+
+	_, err = query(context.Background(), "127.0.0.1:53", new(dns.Msg).SetQuestion("example.org.", dns.TypeMX))
+	require.NoError(t, err)
+	assert.Nil(t, st.dualAnswers, "other query types are sent alone")
+}
+
+func TestResultStats_recordDual_unusable(t *testing.T) {
+	hist := hdrhistogram.New(1, time.Minute.Nanoseconds(), 3)
+	st := &ResultStats{Hist: hist, Dual: newDualStats(hist)}
+	st.dualAnswers = []dualAnswer{
+		{err: errors.New("timeout")},
+		{resp: &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure}}, duration: time.Millisecond},
+	}
+	st.recordDual()
+	assert.Equal(t, map[string]int64{"A": 1}, st.Dual.FamilyErrors)
+	assert.Equal(t, int64(1), st.Dual.Unusable)
+	assert.Zero(t, st.Dual.FirstUsable.TotalCount())
+}
+
+func TestBenchmark_Run_dualAAAA(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeA {
+			ret.Answer = append(ret.Answer, mustRR(t, r.Question[0].Name+" 60 IN A 127.0.0.1"))
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Types = []string{"A"}
+	bench.DualAAAA = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(2), merged.Counters.Total)
+	require.NotNil(t, merged.Dual)
+	assert.Equal(t, int64(2), merged.Dual.FamilyHists["A"].TotalCount())
+	assert.Equal(t, int64(2), merged.Dual.FamilyHists["AAAA"].TotalCount())
+	assert.Equal(t, int64(2), merged.Dual.FirstUsable.TotalCount())
+	assert.Less(t, merged.Dual.FirstUsable.Max(), merged.Hist.Min(), "A answer is usable before AAAA answer is received")
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "Time to first usable answer")
+}
+
+func TestBenchmark_normalize_dualAAAA(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", DualAAAA: true}
+	require.NoError(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", DualAAAA: true, Types: []string{"A", "AAAA"}}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "https://1.1.1.1/dns-query", DualAAAA: true}
+	assert.Error(t, b.normalize())
+}
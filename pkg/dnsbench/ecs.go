@@ -0,0 +1,76 @@
+package dnsbench
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ecsSubnet is a client subnet attached to the queries using EDNS0 Client Subnet option, see https://datatracker.ietf.org/doc/html/rfc7871.
+type ecsSubnet struct {
+	// subnet is nil for randomly generated subnets.
+	subnet *net.IPNet
+	family uint16
+	bits   int
+}
+
+// parseECS parses the client subnet in CIDR notation, random/N and random6/N generates random IPv4 and IPv6 subnets with the prefix length N.
+func parseECS(s string) (ecsSubnet, error) {
+	if prefix, bits, ok := strings.Cut(s, "/"); ok && (prefix == "random" || prefix == "random6") {
+		family, max := uint16(1), 32
+		if prefix == "random6" {
+			family, max = 2, 128
+		}
+		n, err := strconv.Atoi(bits)
+		if err != nil || n < 0 || n > max {
+			return ecsSubnet{}, fmt.Errorf("invalid prefix length of client subnet '%s'", s)
+		}
+		return ecsSubnet{family: family, bits: n}, nil
+	}
+
+	_, subnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return ecsSubnet{}, fmt.Errorf("invalid client subnet '%s', expected subnet in CIDR notation", s)
+	}
+	ones, _ := subnet.Mask.Size()
+	family := uint16(2)
+	if subnet.IP.To4() != nil {
+		family = 1
+	}
+	return ecsSubnet{subnet: subnet, family: family, bits: ones}, nil
+}
+
+func (e ecsSubnet) option(rando *rand.Rand) *dns.EDNS0_SUBNET {
+	o := dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        e.family,
+		SourceNetmask: uint8(e.bits),
+	}
+	if e.subnet != nil {
+		o.Address = e.subnet.IP
+		return &o
+	}
+
+	size, total := net.IPv4len, 32
+	if e.family == 2 {
+		size, total = net.IPv6len, 128
+	}
+	ip := make(net.IP, size)
+	rando.Read(ip)
+	o.Address = ip.Mask(net.CIDRMask(e.bits, total))
+	return &o
+}
+
+// addECS attaches EDNS0 Client Subnet option with one of the subnets chosen randomly.
+func addECS(m *dns.Msg, rando *rand.Rand, subnets []ecsSubnet) {
+	o := m.IsEdns0()
+	if o == nil {
+		m.SetEdns0(4096, false)
+		o = m.IsEdns0()
+	}
+	o.Option = append(o.Option, subnets[rando.Intn(len(subnets))].option(rando))
+}
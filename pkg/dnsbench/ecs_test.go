@@ -0,0 +1,77 @@
+package dnsbench
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseECS(t *testing.T) {
+	rando := rand.New(rand.NewSource(0))
+
+	e, err := parseECS("192.0.2.1/24")
+	require.NoError(t, err)
+	o := e.option(rando)
+	assert.Equal(t, uint16(1), o.Family)
+	assert.Equal(t, uint8(24), o.SourceNetmask)
+	assert.True(t, net.ParseIP("192.0.2.0").Equal(o.Address))
+
+	e, err = parseECS("2001:db8::/56")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(2), e.option(rando).Family)
+
+	e, err = parseECS("random/16")
+	require.NoError(t, err)
+	o = e.option(rando)
+	assert.Equal(t, uint16(1), o.Family)
+	assert.Equal(t, uint8(16), o.SourceNetmask)
+	assert.Equal(t, byte(0), o.Address.To4()[2], "expected random address masked by prefix length")
+
+	e, err = parseECS("random6/48")
+	require.NoError(t, err)
+	assert.Len(t, e.option(rando).Address, net.IPv6len)
+
+	for _, s := range []string{"192.0.2.0", "random/33", "random6/129", "abc"} {
+		_, err := parseECS(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_do_classic_dns_ecs(t *testing.T) {
+	var mu sync.Mutex
+	subnets := make(map[string]int)
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		if opt := r.IsEdns0(); opt != nil {
+			for _, o := range opt.Option {
+				if ecs, ok := o.(*dns.EDNS0_SUBNET); ok {
+					mu.Lock()
+					subnets[ecs.String()]++
+					mu.Unlock()
+				}
+			}
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.ECS = []string{"192.0.2.0/24"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{"192.0.2.0/24/0": 4}, subnets)
+}
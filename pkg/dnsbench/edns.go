@@ -0,0 +1,93 @@
+package dnsbench
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	clientCookieLen    = 8
+	minServerCookieLen = 8
+	maxServerCookieLen = 32
+)
+
+// parseEdnsOpt parses EDNS0 option in format code[:value], value is hexadecimal payload of the option.
+func parseEdnsOpt(s string) (*dns.EDNS0_LOCAL, error) {
+	c, v, _ := strings.Cut(s, ":")
+	code, err := strconv.ParseUint(c, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EDNS option '%s', expected format code[:value] with numeric code", s)
+	}
+	data, err := hex.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EDNS option '%s', value has to be a hexadecimal string", s)
+	}
+	return &dns.EDNS0_LOCAL{Code: uint16(code), Data: data}, nil
+}
+
+func addEdnsOpts(m *dns.Msg, opts []*dns.EDNS0_LOCAL) {
+	o := m.IsEdns0()
+	if o == nil {
+		m.SetEdns0(4096, true)
+		o = m.IsEdns0()
+	}
+	for _, opt := range opts {
+		o.Option = append(o.Option, opt)
+	}
+}
+
+// cookieJar holds DNS Cookies of a single benchmark worker, see https://datatracker.ietf.org/doc/html/rfc7873.
+// The client cookie is generated randomly for the worker and the last server cookie returned by the server is echoed in the following queries.
+type cookieJar struct {
+	client [clientCookieLen]byte
+	server []byte
+}
+
+func newCookieJar(rando *rand.Rand) *cookieJar {
+	var j cookieJar
+	rando.Read(j.client[:])
+	return &j
+}
+
+// add attaches COOKIE option with the client cookie and the server cookie, if any, to the query.
+func (j *cookieJar) add(m *dns.Msg) {
+	o := m.IsEdns0()
+	if o == nil {
+		m.SetEdns0(4096, false)
+		o = m.IsEdns0()
+	}
+	o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: hex.EncodeToString(j.client[:]) + hex.EncodeToString(j.server)})
+}
+
+// update stores the server cookie of the response, responses without cookie or echoing different client cookie are counted.
+func (j *cookieJar) update(resp *dns.Msg, c *Counters) {
+	var cookie *dns.EDNS0_COOKIE
+	if o := resp.IsEdns0(); o != nil {
+		for _, opt := range o.Option {
+			if co, ok := opt.(*dns.EDNS0_COOKIE); ok {
+				cookie = co
+				break
+			}
+		}
+	}
+	if cookie == nil {
+		c.CookieMissing++
+		return
+	}
+
+	data, err := hex.DecodeString(cookie.Cookie)
+	if err != nil || len(data) < clientCookieLen || !bytes.Equal(data[:clientCookieLen], j.client[:]) {
+		c.CookieMismatch++
+		return
+	}
+	if server := data[clientCookieLen:]; len(server) >= minServerCookieLen && len(server) <= maxServerCookieLen {
+		j.server = append(j.server[:0], server...)
+		c.ServerCookies++
+	}
+}
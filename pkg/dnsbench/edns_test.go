@@ -0,0 +1,136 @@
+package dnsbench
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseEdnsOpt(t *testing.T) {
+	tests := []struct {
+		opt     string
+		want    *dns.EDNS0_LOCAL
+		wantErr string
+	}{
+		{opt: "65518:fddd", want: &dns.EDNS0_LOCAL{Code: 65518, Data: []byte{0xfd, 0xdd}}},
+		{opt: "65001", want: &dns.EDNS0_LOCAL{Code: 65001, Data: []byte{}}},
+		{opt: "abc:fddd", wantErr: "invalid EDNS option 'abc:fddd', expected format code[:value] with numeric code"},
+		{opt: "70000:fddd", wantErr: "invalid EDNS option '70000:fddd', expected format code[:value] with numeric code"},
+		{opt: "65518:xyz", wantErr: "invalid EDNS option '65518:xyz', value has to be a hexadecimal string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.opt, func(t *testing.T) {
+			got, err := parseEdnsOpt(tt.opt)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBenchmark_Run_ednsOpts(t *testing.T) {
+	var mu sync.Mutex
+	codes := make(map[uint16]int)
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		if o := r.IsEdns0(); o != nil {
+			mu.Lock()
+			for _, opt := range o.Option {
+				codes[opt.Option()]++
+			}
+			mu.Unlock()
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 10)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.EdnsOpt = []string{"65518:fddddddd", "65519:01"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	assertResult(t, rs)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[uint16]int{65518: 4, 65519: 4}, codes)
+}
+
+func TestBenchmark_Run_cookies(t *testing.T) {
+	serverCookie := "0102030405060708090a0b0c0d0e0f10"
+	var mu sync.Mutex
+	var echoed int
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		ret.SetEdns0(4096, false)
+		if o := r.IsEdns0(); o != nil {
+			for _, opt := range o.Option {
+				if c, ok := opt.(*dns.EDNS0_COOKIE); ok {
+					if len(c.Cookie) > 16 && c.Cookie[16:] == serverCookie {
+						mu.Lock()
+						echoed++
+						mu.Unlock()
+					}
+					ret.IsEdns0().Option = append(ret.IsEdns0().Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: c.Cookie[:16] + serverCookie})
+				}
+			}
+		}
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 10)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Cookies = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	assertResult(t, rs)
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.ServerCookies, "Run(ctx) server cookies")
+	assert.Zero(t, merged.Counters.CookieMissing, "Run(ctx) missing cookies")
+	assert.Zero(t, merged.Counters.CookieMismatch, "Run(ctx) cookie mismatches")
+	// the second query of each worker echoes the server cookie returned for the first one
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, echoed)
+}
+
+func Test_cookieJar_update(t *testing.T) {
+	j := &cookieJar{client: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+	c := &Counters{}
+
+	j.update(new(dns.Msg), c)
+	assert.Equal(t, int64(1), c.CookieMissing)
+
+	resp := new(dns.Msg)
+	resp.SetEdns0(4096, false)
+	resp.IsEdns0().Option = append(resp.IsEdns0().Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "ffffffffffffffff0102030405060708"})
+	j.update(resp, c)
+	assert.Equal(t, int64(1), c.CookieMismatch)
+	assert.Empty(t, j.server)
+}
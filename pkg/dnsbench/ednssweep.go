@@ -0,0 +1,190 @@
+package dnsbench
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/olekukonko/tablewriter"
+)
+
+// EDNSSizeStats counts the outcomes of the queries advertising a single EDNS buffer size, see Benchmark.EDNSSweep.
+type EDNSSizeStats struct {
+	// Queries counts the queries sent with the buffer size.
+	Queries int64
+	// Truncated counts the truncated responses, including the ones retried over TCP, see Benchmark.RetryTruncated.
+	Truncated int64
+	// Timeouts counts the queries, which timed out, large responses are often lost due to fragmentation.
+	Timeouts int64
+	// Errors counts the queries failed due to other errors than timeouts.
+	Errors int64
+	// ResponseBytes sums the sizes of the responses.
+	ResponseBytes int64
+}
+
+func (s *EDNSSizeStats) add(o *EDNSSizeStats) {
+	s.Queries += o.Queries
+	s.Truncated += o.Truncated
+	s.Timeouts += o.Timeouts
+	s.Errors += o.Errors
+	s.ResponseBytes += o.ResponseBytes
+}
+
+// parseEDNSSweep parses comma separated EDNS buffer sizes, at least two distinct sizes not lower than the minimal DNS message size are required.
+func parseEDNSSweep(sweep string) ([]uint16, error) {
+	var sizes []uint16
+	seen := make(map[uint16]bool)
+	for _, s := range strings.Split(sweep, ",") {
+		size, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+		if err != nil || size < dns.MinMsgSize {
+			return nil, fmt.Errorf("invalid EDNS buffer size '%s', expected number between %d and %d", s, dns.MinMsgSize, math.MaxUint16)
+		}
+		if seen[uint16(size)] {
+			return nil, fmt.Errorf("duplicate EDNS buffer size '%s'", s)
+		}
+		seen[uint16(size)] = true
+		sizes = append(sizes, uint16(size))
+	}
+	if len(sizes) < 2 {
+		return nil, errors.New("--edns-sweep requires at least two EDNS buffer sizes")
+	}
+	return sizes, nil
+}
+
+// ednsSweep rotates the EDNS buffer sizes advertised by the queries of a worker, each worker starts with a different size,
+// so that all sizes are sent at the same time.
+type ednsSweep struct {
+	sizes []uint16
+	next  int
+}
+
+func newEDNSSweep(sizes []uint16, worker uint32) *ednsSweep {
+	return &ednsSweep{sizes: sizes, next: int(worker) % len(sizes)}
+}
+
+func (s *ednsSweep) size() uint16 {
+	size := s.sizes[s.next]
+	s.next = (s.next + 1) % len(s.sizes)
+	return size
+}
+
+// setUDPSize advertises the EDNS buffer size by the message, OPT record is added when the message has none.
+func setUDPSize(m *dns.Msg, size uint16) {
+	if o := m.IsEdns0(); o != nil {
+		o.SetUDPSize(size)
+	} else {
+		m.SetEdns0(size, false)
+	}
+}
+
+// recordEDNSSize records the outcome of the query advertising the buffer size of rs.ednsSize, err is the error of the query.
+func (rs *ResultStats) recordEDNSSize(resp *dns.Msg, err error) {
+	if rs.EDNSSizes == nil {
+		return
+	}
+	s, ok := rs.EDNSSizes[rs.ednsSize]
+	if !ok {
+		s = &EDNSSizeStats{}
+		rs.EDNSSizes[rs.ednsSize] = s
+	}
+	s.Queries++
+	switch {
+	case err != nil && isTimeout(err):
+		s.Timeouts++
+	case err != nil:
+		s.Errors++
+	default:
+		if resp.Truncated || rs.tcpRetried {
+			s.Truncated++
+		}
+		s.ResponseBytes += int64(resp.Len())
+	}
+}
+
+func sortedEDNSSizes(sizes map[int]*EDNSSizeStats) []int {
+	keys := make([]int, 0, len(sizes))
+	for k := range sizes {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func (s *EDNSSizeStats) avgResponseBytes() int64 {
+	if answered := s.Queries - s.Timeouts - s.Errors; answered > 0 {
+		return s.ResponseBytes / answered
+	}
+	return 0
+}
+
+func printEDNSSizes(w io.Writer, sizes map[int]*EDNSSizeStats, hists map[int]*hdrhistogram.Histogram) {
+	if sizes == nil {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Truncation and latency by EDNS buffer size:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Size", "Queries", "Truncated", "Timeouts", "Errors", "Avg response", "p50", "p95", "p99"})
+	table.SetBorder(false)
+	for _, k := range sortedEDNSSizes(sizes) {
+		s := sizes[k]
+		p50, p95, p99 := "-", "-", "-"
+		if h := hists[k]; h != nil && h.TotalCount() > 0 {
+			p50 = time.Duration(h.ValueAtQuantile(50)).String()
+			p95 = time.Duration(h.ValueAtQuantile(95)).String()
+			p99 = time.Duration(h.ValueAtQuantile(99)).String()
+		}
+		table.Append([]string{
+			strconv.Itoa(k),
+			strconv.FormatInt(s.Queries, 10),
+			fmt.Sprintf("%d (%0.1f%%)", s.Truncated, rate(s.Truncated, s.Queries)*100),
+			fmt.Sprintf("%d (%0.1f%%)", s.Timeouts, rate(s.Timeouts, s.Queries)*100),
+			strconv.FormatInt(s.Errors, 10),
+			fmt.Sprintf("%d B", s.avgResponseBytes()),
+			p50, p95, p99,
+		})
+	}
+	table.Render()
+}
+
+type jsonEDNSSize struct {
+	Size             int           `json:"size"`
+	Queries          int64         `json:"queries"`
+	Truncated        int64         `json:"truncated"`
+	TruncatedRate    float64       `json:"truncatedRate"`
+	Timeouts         int64         `json:"timeouts"`
+	TimeoutRate      float64       `json:"timeoutRate"`
+	Errors           int64         `json:"errors"`
+	AvgResponseBytes int64         `json:"avgResponseBytes"`
+	Latency          *latencyStats `json:"latencyStats,omitempty"`
+}
+
+func newJSONEDNSSizes(sizes map[int]*EDNSSizeStats, hists map[int]*hdrhistogram.Histogram) []jsonEDNSSize {
+	res := make([]jsonEDNSSize, 0, len(sizes))
+	for _, k := range sortedEDNSSizes(sizes) {
+		s := sizes[k]
+		js := jsonEDNSSize{
+			Size:             k,
+			Queries:          s.Queries,
+			Truncated:        s.Truncated,
+			TruncatedRate:    math.Round(rate(s.Truncated, s.Queries)*10000) / 10000,
+			Timeouts:         s.Timeouts,
+			TimeoutRate:      math.Round(rate(s.Timeouts, s.Queries)*10000) / 10000,
+			Errors:           s.Errors,
+			AvgResponseBytes: s.avgResponseBytes(),
+		}
+		if h := hists[k]; h != nil && h.TotalCount() > 0 {
+			l := newLatencyStats(h)
+			js.Latency = &l
+		}
+		res = append(res, js)
+	}
+	return res
+}
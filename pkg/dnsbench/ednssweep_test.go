@@ -0,0 +1,131 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// truncatingHandler truncates the responses to the queries advertising smaller buffer than 1232 bytes.
+func truncatingHandler(w dns.ResponseWriter, r *dns.Msg) {
+	ret := new(dns.Msg)
+	ret.SetReply(r)
+	if opt := r.IsEdns0(); opt == nil || opt.UDPSize() < 1232 {
+		ret.Truncated = true
+	} else {
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		ret.SetEdns0(opt.UDPSize(), false)
+	}
+	w.WriteMsg(ret)
+}
+
+func Test_parseEDNSSweep(t *testing.T) {
+	sizes, err := parseEDNSSweep("512, 1232,4096")
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{512, 1232, 4096}, sizes)
+
+	for _, sweep := range []string{"1232", "511,1232", "512,70000", "512,abc", "1232,1232"} {
+		_, err := parseEDNSSweep(sweep)
+		assert.Error(t, err, sweep)
+	}
+}
+
+func Test_ednsSweep(t *testing.T) {
+	s := newEDNSSweep([]uint16{512, 1232, 4096}, 4)
+	var got []uint16
+	for i := 0; i < 4; i++ {
+		got = append(got, s.size())
+	}
+	assert.Equal(t, []uint16{1232, 4096, 512, 1232}, got)
+}
+
+func TestBenchmark_Run_ednsSweep(t *testing.T) {
+	s := NewServer(udp, truncatingHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.EDNSSweep = "512,4096"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.Len(t, merged.EDNSSizes, 2)
+	assert.Equal(t, int64(2), merged.EDNSSizes[512].Queries)
+	assert.Equal(t, int64(2), merged.EDNSSizes[512].Truncated)
+	assert.Equal(t, int64(2), merged.EDNSSizes[4096].Queries)
+	assert.Zero(t, merged.EDNSSizes[4096].Truncated)
+	assert.Greater(t, merged.EDNSSizes[4096].avgResponseBytes(), merged.EDNSSizes[512].avgResponseBytes())
+	assert.Equal(t, int64(2), merged.EDNSSizeHists[512].TotalCount())
+	assert.Equal(t, int64(2), merged.EDNSSizeHists[4096].TotalCount())
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "Truncation and latency by EDNS buffer size:")
+
+	bench.JSON = true
+	buf.Reset()
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	var res struct {
+		EDNSSizes []jsonEDNSSize `json:"ednsSizes"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	require.Len(t, res.EDNSSizes, 2)
+	assert.Equal(t, 512, res.EDNSSizes[0].Size)
+	assert.Equal(t, 1.0, res.EDNSSizes[0].TruncatedRate)
+	assert.NotNil(t, res.EDNSSizes[1].Latency)
+}
+
+func TestBenchmark_Run_udpSize(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []uint16
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		if opt := r.IsEdns0(); opt != nil {
+			sizes = append(sizes, opt.UDPSize())
+		}
+		mu.Unlock()
+		truncatingHandler(w, r)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.UDPSize = 1232
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	assert.Zero(t, Merge(rs).Counters.Truncated)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []uint16{1232, 1232, 1232, 1232}, sizes)
+}
+
+func TestBenchmark_normalize_ednsSweep(t *testing.T) {
+	tests := []struct {
+		name  string
+		bench Benchmark
+	}{
+		{name: "invalid sizes", bench: Benchmark{Server: "8.8.8.8", EDNSSweep: "512"}},
+		{name: "edns0", bench: Benchmark{Server: "8.8.8.8", EDNSSweep: "512,1232", UDPSize: 4096}},
+		{name: "tcp", bench: Benchmark{Server: "8.8.8.8", EDNSSweep: "512,1232", TCP: true}},
+		{name: "doh", bench: Benchmark{Server: "https://8.8.8.8/dns-query", EDNSSweep: "512,1232"}},
+		{name: "diff", bench: Benchmark{Server: "8.8.8.8", EDNSSweep: "512,1232", DiffServer: "1.1.1.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.bench.normalize())
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// categories of the errors of failed queries, see ResultStats.ErrorCategories.
+const (
+	errCategoryDial           = "dial"
+	errCategoryWrite          = "write"
+	errCategoryReadTimeout    = "read timeout"
+	errCategoryRequestTimeout = "request timeout"
+	errCategoryReset          = "connection reset"
+	errCategoryRefused        = "connection refused"
+	errCategoryTLS            = "tls"
+	errCategoryHTTPStatus     = "http status"
+	errCategoryOther          = "other"
+)
+
+// recordError records the query failed due to I/O error or timeout.
+func (rs *ResultStats) recordError(err error) {
+	rs.Counters.IOError++
+	if isTimeout(err) {
+		rs.Counters.Timeouts++
+	}
+	if rs.ErrorCategories != nil {
+		rs.ErrorCategories[errorCategory(err)]++
+	}
+	rs.Errors = append(rs.Errors, err)
+}
+
+// errorCategory returns the category of the error of the failed query, errors of establishing connections are categorized as dial errors
+// regardless of their cause, except for TLS handshake failures.
+func errorCategory(err error) string {
+	var opErr *net.OpError
+	op := ""
+	if errors.As(err, &opErr) {
+		op = opErr.Op
+	}
+	switch {
+	case isTLSError(err):
+		return errCategoryTLS
+	case isHTTPStatusError(err):
+		return errCategoryHTTPStatus
+	case op == "dial" || op == "proxyconnect":
+		return errCategoryDial
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return errCategoryReset
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errCategoryRefused
+	case op == "write":
+		return errCategoryWrite
+	case isTimeout(err) && op == "read":
+		return errCategoryReadTimeout
+	case isTimeout(err):
+		return errCategoryRequestTimeout
+	default:
+		return errCategoryOther
+	}
+}
+
+// isTimeout checks whether the query failed due to exceeded request timeout or read or write deadline of the connection.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func isTLSError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &verifyErr) || errors.As(err, &recordErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return true
+	}
+	// TLS alerts sent by the server are not exported
+	return strings.Contains(err.Error(), "tls: ")
+}
+
+// isHTTPStatusError checks whether DoH server responded with other HTTP status than 200, the DoH client returns only opaque error in such case.
+func isHTTPStatusError(err error) bool {
+	return strings.Contains(err.Error(), "unexpected HTTP status")
+}
@@ -0,0 +1,89 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_errorCategory(t *testing.T) {
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+	opErr := func(op string, err error) error {
+		return &net.OpError{Op: op, Net: "udp", Addr: addr, Err: err}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "dial", err: opErr("dial", syscall.ECONNREFUSED), want: errCategoryDial},
+		{name: "dial timeout", err: opErr("dial", os.ErrDeadlineExceeded), want: errCategoryDial},
+		{name: "dial through proxy", err: &url.Error{Op: "Post", URL: "https://example.org", Err: opErr("proxyconnect", syscall.ECONNREFUSED)}, want: errCategoryDial},
+		{name: "write", err: opErr("write", syscall.ENOBUFS), want: errCategoryWrite},
+		{name: "read timeout", err: timeoutError(addr, addr), want: errCategoryReadTimeout},
+		{name: "request timeout", err: context.DeadlineExceeded, want: errCategoryRequestTimeout},
+		{name: "reset", err: opErr("read", &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}), want: errCategoryReset},
+		{name: "closed connection", err: io.EOF, want: errCategoryReset},
+		{name: "refused", err: opErr("read", &os.SyscallError{Syscall: "recvfrom", Err: syscall.ECONNREFUSED}), want: errCategoryRefused},
+		{name: "tls certificate", err: &url.Error{Op: "Post", URL: "https://example.org", Err: x509.UnknownAuthorityError{}}, want: errCategoryTLS},
+		{name: "tls alert", err: opErr("remote error", errors.New("tls: handshake failure")), want: errCategoryTLS},
+		{name: "http status", err: errors.New("unexpected HTTP status"), want: errCategoryHTTPStatus},
+		{name: "other", err: errors.New("dns: bad rdata"), want: errCategoryOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, errorCategory(tt.err))
+		})
+	}
+}
+
+func Test_do_doh_error_categories(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	bench := createBenchmark(ts.URL, true, 1)
+	bench.DohMethod = post
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2)
+	for _, r := range rs {
+		assert.Equal(t, int64(2), r.Counters.IOError, "Run(ctx) error counter")
+		assert.Equal(t, map[string]int64{errCategoryHTTPStatus: 2}, r.ErrorCategories, "Run(ctx) error categories")
+	}
+
+	merged := Merge(rs)
+	assert.Equal(t, map[string]int64{errCategoryHTTPStatus: 4}, merged.ErrorCategories, "merged error categories")
+}
+
+func TestBenchmark_PrintReport_errorCategories(t *testing.T) {
+	b, rs := testData()
+	rs.ErrorCategories = map[string]int64{errCategoryDial: 1, errCategoryReadTimeout: 2}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.Contains(t, buf.String(), "Errors by category:\n\tread timeout:\t2\n\tdial:\t1\n")
+
+	b.JSON = true
+	buf.Reset()
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.Contains(t, buf.String(), `"errorCategories":{"dial":1,"read timeout":2}`)
+}
@@ -0,0 +1,121 @@
+package dnsbench
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// expectations are checks of the responses, responses not matching the expectations are counted separately for each check.
+type expectations struct {
+	// rcode is -1 when the response code is not checked.
+	rcode int
+	// countOp is empty when the number of answers is not checked.
+	countOp string
+	count   int
+	ips     []*net.IPNet
+}
+
+// parseExpectations parses expected response code like NXDOMAIN, expected answer count like >=1 and expected IPs in CIDR notation,
+// nil is returned if nothing is expected.
+func parseExpectations(rcode, answerCount string, ips []string) (*expectations, error) {
+	if rcode == "" && answerCount == "" && len(ips) == 0 {
+		return nil, nil
+	}
+	e := expectations{rcode: -1}
+
+	if rcode != "" {
+		code, ok := dns.StringToRcode[strings.ToUpper(rcode)]
+		if !ok {
+			return nil, fmt.Errorf("unknown expected response code '%s'", rcode)
+		}
+		e.rcode = code
+	}
+
+	if answerCount != "" {
+		e.countOp, e.count = "==", -1
+		value := strings.ReplaceAll(answerCount, " ", "")
+		for _, op := range []string{"<=", ">=", "==", "<", ">", "="} {
+			if v, ok := strings.CutPrefix(value, op); ok {
+				e.countOp, value = op, v
+				break
+			}
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid expected answer count '%s', expected for example >=1", answerCount)
+		}
+		e.count = count
+	}
+
+	for _, ip := range ips {
+		if !strings.Contains(ip, "/") {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+				ip += "/32"
+			} else {
+				ip += "/128"
+			}
+		}
+		_, subnet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected IP '%s', expected IP address or subnet in CIDR notation", ip)
+		}
+		e.ips = append(e.ips, subnet)
+	}
+	return &e, nil
+}
+
+// evaluateResponse checks the response against the expectations and counts mismatches of each check.
+func (e *expectations) evaluateResponse(resp *dns.Msg, c *Counters) {
+	if e == nil {
+		return
+	}
+	if e.rcode >= 0 && resp.Rcode != e.rcode {
+		c.RcodeMismatch++
+	}
+	if e.countOp != "" && !e.countMatches(len(resp.Answer)) {
+		c.AnswerCountMismatch++
+	}
+	if len(e.ips) > 0 && !e.ipMatches(resp.Answer) {
+		c.IPMismatch++
+	}
+}
+
+func (e *expectations) countMatches(n int) bool {
+	switch e.countOp {
+	case "<":
+		return n < e.count
+	case "<=":
+		return n <= e.count
+	case ">":
+		return n > e.count
+	case ">=":
+		return n >= e.count
+	default:
+		return n == e.count
+	}
+}
+
+// ipMatches returns true if any A or AAAA record of the answer is within any of the expected subnets.
+func (e *expectations) ipMatches(answer []dns.RR) bool {
+	for _, rr := range answer {
+		var ip net.IP
+		switch v := rr.(type) {
+		case *dns.A:
+			ip = v.A
+		case *dns.AAAA:
+			ip = v.AAAA
+		default:
+			continue
+		}
+		for _, subnet := range e.ips {
+			if subnet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package dnsbench
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseExpectations(t *testing.T) {
+	e, err := parseExpectations("nxdomain", ">= 1", []string{"1.2.3.4", "10.0.0.0/8", "fd00::1"})
+
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeNameError, e.rcode)
+	assert.Equal(t, ">=", e.countOp)
+	assert.Equal(t, 1, e.count)
+	require.Len(t, e.ips, 3)
+	assert.Equal(t, "1.2.3.4/32", e.ips[0].String())
+	assert.Equal(t, "10.0.0.0/8", e.ips[1].String())
+	assert.Equal(t, "fd00::1/128", e.ips[2].String())
+
+	e, err = parseExpectations("", "", nil)
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	for _, tt := range []struct{ rcode, count, ip string }{{rcode: "FOO"}, {count: ">=x"}, {count: "-1"}, {ip: "1.2.3"}} {
+		var ips []string
+		if tt.ip != "" {
+			ips = []string{tt.ip}
+		}
+		_, err := parseExpectations(tt.rcode, tt.count, ips)
+		assert.Error(t, err, tt)
+	}
+}
+
+func Test_expectations_evaluateResponse(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeA}, A: net.ParseIP("127.0.0.1")}}
+
+	tests := []struct {
+		name        string
+		rcode       string
+		answerCount string
+		ips         []string
+		want        Counters
+	}{
+		{name: "matching", rcode: "NOERROR", answerCount: "1", ips: []string{"127.0.0.0/8"}},
+		{name: "rcode mismatch", rcode: "NXDOMAIN", want: Counters{RcodeMismatch: 1}},
+		{name: "answer count mismatch", answerCount: ">1", want: Counters{AnswerCountMismatch: 1}},
+		{name: "IP mismatch", ips: []string{"10.0.0.0/8", "::1"}, want: Counters{IPMismatch: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := parseExpectations(tt.rcode, tt.answerCount, tt.ips)
+			require.NoError(t, err)
+
+			c := Counters{}
+			e.evaluateResponse(resp, &c)
+
+			assert.Equal(t, tt.want, c)
+		})
+	}
+}
+
+func Test_do_classic_dns_expectations(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeA {
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.ExpectRcode = "NOERROR"
+	bench.ExpectAnswerCount = ">=1"
+	bench.ExpectIP = []string{"127.0.0.1/32"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	for _, r := range rs {
+		assert.Equal(t, int64(2), r.Counters.Success, "Run(ctx) success counter")
+		assert.Equal(t, int64(0), r.Counters.RcodeMismatch, "Run(ctx) rcode mismatch counter")
+		assert.Equal(t, int64(1), r.Counters.AnswerCountMismatch, "Run(ctx) answer count mismatch counter")
+		assert.Equal(t, int64(1), r.Counters.IPMismatch, "Run(ctx) IP mismatch counter")
+	}
+}
@@ -0,0 +1,67 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/olekukonko/tablewriter"
+)
+
+// withFailover returns query function, which sends the query to the servers in order, the same way stub resolvers configured with multiple
+// nameservers do. When the server does not answer within the timeout, the query is sent to the next server, the latency of such query
+// therefore includes the timeouts of the servers which did not answer.
+func withFailover(queries []queryFunc, servers []string, timeout time.Duration, st *ResultStats) queryFunc {
+	return func(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+		st.answeredBy = ""
+		var r *dns.Msg
+		var err error
+		for i, query := range queries {
+			if i > 0 {
+				st.Counters.Failovers++
+			}
+			attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+			r, err = query(attemptCtx, servers[i], msg)
+			cancel()
+			if err == nil || (r != nil && isTsigError(err)) {
+				st.answeredBy = servers[i]
+				return r, err
+			}
+			if ctx.Err() != nil {
+				return r, err
+			}
+			st.FailoverUnanswered[servers[i]]++
+		}
+		return r, err
+	}
+}
+
+func printFailover(w io.Writer, servers []string, hists map[string]*hdrhistogram.Histogram, unanswered map[string]int64) {
+	if hists == nil {
+		return
+	}
+	lines := make([][]string, 0, len(servers))
+	for _, s := range servers {
+		line := []string{s, "0", strconv.FormatInt(unanswered[s], 10), "-", "-", "-", "-"}
+		if h, ok := hists[s]; ok {
+			line[1] = strconv.FormatInt(h.TotalCount(), 10)
+			line[3] = roundDuration(time.Duration(h.ValueAtQuantile(50))).String()
+			line[4] = roundDuration(time.Duration(h.ValueAtQuantile(95))).String()
+			line[5] = roundDuration(time.Duration(h.ValueAtQuantile(99))).String()
+			line[6] = roundDuration(time.Duration(h.Max())).String()
+		}
+		lines = append(lines, line)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "DNS timings by answering server:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Server", "Answered", "Unanswered", "p50", "p95", "p99", "Max"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+}
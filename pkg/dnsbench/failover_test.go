@@ -0,0 +1,97 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_withFailover(t *testing.T) {
+	st := &ResultStats{Counters: &Counters{}, FailoverUnanswered: make(map[string]int64)}
+	unanswered := func(ctx context.Context, _ string, _ *dns.Msg) (*dns.Msg, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	answered := func(_ context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+		return new(dns.Msg).SetReply(msg), nil
+	}
+
+	query := withFailover([]queryFunc{unanswered, answered}, []string{"ns1:53", "ns2:53"}, 10*time.Millisecond, st)
+	r, err := query(context.Background(), "ns1:53", new(dns.Msg))
+	require.NoError(t, err)
+	assert.NotNil(t, r)
+	assert.Equal(t, "ns2:53", st.answeredBy)
+	assert.Equal(t, int64(1), st.Counters.Failovers)
+	assert.Equal(t, map[string]int64{"ns1:53": 1}, st.FailoverUnanswered)
+
+	query = withFailover([]queryFunc{unanswered, unanswered}, []string{"ns1:53", "ns2:53"}, 10*time.Millisecond, st)
+	_, err = query(context.Background(), "ns1:53", new(dns.Msg))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Empty(t, st.answeredBy)
+	assert.Equal(t, map[string]int64{"ns1:53": 2, "ns2:53": 1}, st.FailoverUnanswered)
+}
+
+func TestBenchmark_Run_failover(t *testing.T) {
+	// the first server never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.FailoverServers = []string{conn.LocalAddr().String(), s.Addr}
+	bench.FailoverTimeout = 100 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.Success)
+	assert.Equal(t, int64(4), merged.Counters.Failovers)
+	assert.Equal(t, map[string]int64{conn.LocalAddr().String(): 4}, merged.FailoverUnanswered)
+	require.Contains(t, merged.FailoverHists, s.Addr)
+	assert.Equal(t, int64(4), merged.FailoverHists[s.Addr].TotalCount())
+	assert.GreaterOrEqual(t, merged.Hist.Max(), (90 * time.Millisecond).Nanoseconds(), "the latency includes the failover timeout")
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "DNS timings by answering server:")
+}
+
+func Test_printFailover(t *testing.T) {
+	h := hdrhistogram.New(1, time.Second.Nanoseconds(), 3)
+	h.RecordValue(time.Millisecond.Nanoseconds())
+
+	buf := bytes.Buffer{}
+	printFailover(&buf, []string{"ns1:53", "ns2:53"}, map[string]*hdrhistogram.Histogram{"ns2:53": h}, map[string]int64{"ns1:53": 1})
+	assert.Regexp(t, `ns1:53\s+\|\s+0\s+\|\s+1\s+\|\s+-`, buf.String())
+	assert.Regexp(t, `ns2:53\s+\|\s+1\s+\|\s+0\s+\|\s+1ms`, buf.String())
+}
+
+func TestBenchmark_normalize_failover(t *testing.T) {
+	b := Benchmark{FailoverServers: []string{"127.0.0.1", "127.0.0.2"}, FailoverTimeout: time.Second}
+	require.NoError(t, b.normalize())
+	assert.Equal(t, "127.0.0.1:53", b.Server)
+	assert.Equal(t, []string{"127.0.0.1:53", "127.0.0.2:53"}, b.FailoverServers)
+
+	b = Benchmark{FailoverServers: []string{"127.0.0.1"}, FailoverTimeout: time.Second}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{FailoverServers: []string{"127.0.0.1", "127.0.0.2"}}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{FailoverServers: []string{"127.0.0.1", "https://1.1.1.1/dns-query"}, FailoverTimeout: time.Second}
+	assert.Error(t, b.normalize())
+}
@@ -0,0 +1,86 @@
+package dnsbench
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// failureLogger logs queries and responses of failed checks in JSON lines format, it is safe for concurrent use by benchmark workers.
+type failureLogger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// failureRecord is a single line of the failure log, the query and the response are logged in wire format encoded as hexadecimal string.
+type failureRecord struct {
+	Time     time.Time `json:"time"`
+	Server   string    `json:"server"`
+	Reasons  []string  `json:"reasons"`
+	Question string    `json:"question"`
+	Rcode    string    `json:"rcode"`
+	Query    string    `json:"query"`
+	Response string    `json:"response"`
+}
+
+func newFailureLogger(w io.Writer) *failureLogger {
+	return &failureLogger{enc: json.NewEncoder(w)}
+}
+
+func (l *failureLogger) log(server string, reasons []string, req, resp *dns.Msg) {
+	if l == nil || len(reasons) == 0 {
+		return
+	}
+	rec := failureRecord{Time: time.Now(), Server: server, Reasons: reasons, Question: questionString(req), Rcode: dns.RcodeToString[resp.Rcode]}
+	if packed, err := req.Pack(); err == nil {
+		rec.Query = hex.EncodeToString(packed)
+	}
+	if packed, err := resp.Pack(); err == nil {
+		rec.Response = hex.EncodeToString(packed)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(rec)
+}
+
+// failureReasons returns the checks failed by the response, which are found by comparing the counters before and after the response was evaluated.
+// Without expected response code, responses with other codes than NOERROR and NXDOMAIN are considered failed.
+func (b *Benchmark) failureReasons(before, after *Counters, resp *dns.Msg) []string {
+	var reasons []string
+	if after.IDmismatch > before.IDmismatch {
+		reasons = append(reasons, "id mismatch")
+	}
+	if after.RcodeMismatch > before.RcodeMismatch {
+		reasons = append(reasons, "rcode mismatch")
+	}
+	if (b.expect == nil || b.expect.rcode < 0) && resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		reasons = append(reasons, "unexpected rcode")
+	}
+	if after.AnswerCountMismatch > before.AnswerCountMismatch {
+		reasons = append(reasons, "answer count mismatch")
+	}
+	if after.IPMismatch > before.IPMismatch {
+		reasons = append(reasons, "ip mismatch")
+	}
+	if after.CaseMismatch > before.CaseMismatch {
+		reasons = append(reasons, "case mismatch")
+	}
+	if after.CookieMismatch > before.CookieMismatch {
+		reasons = append(reasons, "cookie mismatch")
+	}
+	if after.ValidationFailed > before.ValidationFailed {
+		reasons = append(reasons, "dnssec validation failed")
+	}
+	if after.TSIGError > before.TSIGError {
+		reasons = append(reasons, "tsig error")
+	}
+	if after.HookRejected > before.HookRejected {
+		reasons = append(reasons, "rejected by response hook")
+	}
+	return reasons
+}
@@ -0,0 +1,81 @@
+package dnsbench
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_Run_failureLog(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeAAAA {
+			ret.Rcode = dns.RcodeServerFailure
+		} else {
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	file := filepath.Join(t.TempDir(), "failures.jsonl")
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.FailureLog = file
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []failureRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec failureRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		records = append(records, rec)
+	}
+	require.Len(t, records, 2)
+	for _, rec := range records {
+		assert.Equal(t, []string{"unexpected rcode"}, rec.Reasons)
+		assert.Equal(t, "example.org. AAAA", rec.Question)
+		assert.Equal(t, "SERVFAIL", rec.Rcode)
+		assert.Equal(t, s.Addr, rec.Server)
+
+		packed, err := hex.DecodeString(rec.Response)
+		require.NoError(t, err)
+		resp := new(dns.Msg)
+		require.NoError(t, resp.Unpack(packed))
+		assert.Equal(t, dns.RcodeServerFailure, resp.Rcode)
+
+		packed, err = hex.DecodeString(rec.Query)
+		require.NoError(t, err)
+		req := new(dns.Msg)
+		require.NoError(t, req.Unpack(packed))
+		assert.Equal(t, resp.Id, req.Id)
+	}
+}
+
+func TestBenchmark_failureReasons(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.Rcode = dns.RcodeNameError
+
+	b := Benchmark{}
+	assert.Empty(t, b.failureReasons(&Counters{}, &Counters{}, resp), "NXDOMAIN is not a failure without expected rcode")
+
+	b.expect = &expectations{rcode: dns.RcodeSuccess}
+	assert.Equal(t, []string{"rcode mismatch", "case mismatch"}, b.failureReasons(&Counters{}, &Counters{RcodeMismatch: 1, CaseMismatch: 1}, resp))
+}
@@ -0,0 +1,23 @@
+package dnsbench
+
+import (
+	"strings"
+)
+
+// network returns the network restricted to the address family selected by Benchmark.IPv4 or Benchmark.IPv6,
+// for example udp is changed to udp4 and tcp-tls to tcp6-tls.
+func (b *Benchmark) network(network string) string {
+	var family string
+	switch {
+	case b.IPv4:
+		family = "4"
+	case b.IPv6:
+		family = "6"
+	default:
+		return network
+	}
+	if base, ok := strings.CutSuffix(network, "-tls"); ok {
+		return strings.TrimRight(base, "46") + family + "-tls"
+	}
+	return strings.TrimRight(network, "46") + family
+}
@@ -0,0 +1,59 @@
+package dnsbench
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_network(t *testing.T) {
+	tests := []struct {
+		network string
+		ipv4    bool
+		ipv6    bool
+		want    string
+	}{
+		{network: "udp", want: "udp"},
+		{network: "udp", ipv4: true, want: "udp4"},
+		{network: "tcp", ipv6: true, want: "tcp6"},
+		{network: "tcp-tls", ipv4: true, want: "tcp4-tls"},
+		{network: "tcp6", ipv4: true, want: "tcp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			b := Benchmark{IPv4: tt.ipv4, IPv6: tt.ipv6}
+			assert.Equal(t, tt.want, b.network(tt.network))
+		})
+	}
+}
+
+func Test_do_classic_dns_ipv4(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	_, port, err := net.SplitHostPort(s.Addr)
+	require.NoError(t, err)
+
+	bench := createBenchmark(net.JoinHostPort("localhost", port), false, 1)
+	bench.IPv4 = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	for _, r := range rs {
+		assert.Equal(t, int64(2), r.Counters.Success, "Run(ctx) success counter")
+		assert.Equal(t, map[string]int64{"IPv4": 1}, r.Families, "Run(ctx) address families")
+	}
+}
@@ -0,0 +1,59 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// errInjectedDrop is returned by the queries dropped by the client, see Benchmark.DropRate.
+var errInjectedDrop = errors.New("query dropped by injected packet loss")
+
+// parseInjectDelay parses the delay in format delay[±jitter], for example 5ms±2ms, +- can be used instead of ±.
+func parseInjectDelay(s string) (time.Duration, time.Duration, error) {
+	delay, jitter, ok := strings.Cut(s, "±")
+	if !ok {
+		delay, jitter, ok = strings.Cut(s, "+-")
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(delay))
+	if err != nil || d < 0 {
+		return 0, 0, fmt.Errorf("invalid delay '%s' of --inject-delay, expected format delay[±jitter]", delay)
+	}
+	if !ok {
+		return d, 0, nil
+	}
+	j, err := time.ParseDuration(strings.TrimSpace(jitter))
+	if err != nil || j < 0 || j > d {
+		return 0, 0, fmt.Errorf("invalid jitter '%s' of --inject-delay, the jitter has to be between zero and the delay", jitter)
+	}
+	return d, j, nil
+}
+
+// withFaults returns query function, which simulates degraded network between the client and the server. The queries are delayed
+// by the injected delay with uniformly random jitter before they are sent and the dropped queries are not sent at all, they fail
+// once the request timeout elapses like the queries lost in the network.
+func (b *Benchmark) withFaults(query queryFunc, rando *rand.Rand, st *ResultStats) queryFunc {
+	return func(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+		if b.injectDelay > 0 {
+			d := b.injectDelay
+			if b.injectJitter > 0 {
+				d += time.Duration(rando.Int63n(int64(2*b.injectJitter)+1)) - b.injectJitter
+			}
+			st.Counters.InjectedDelays++
+			if err := waitUntil(ctx, time.Now().Add(d)); err != nil {
+				return nil, err
+			}
+		}
+		if b.DropRate > 0 && rando.Float64() < b.DropRate {
+			st.Counters.InjectedDrops++
+			<-ctx.Done()
+			return nil, errInjectedDrop
+		}
+		return query(ctx, server, msg)
+	}
+}
@@ -0,0 +1,85 @@
+package dnsbench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseInjectDelay(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantDelay  time.Duration
+		wantJitter time.Duration
+		wantErr    bool
+	}{
+		{in: "5ms", wantDelay: 5 * time.Millisecond},
+		{in: "5ms±2ms", wantDelay: 5 * time.Millisecond, wantJitter: 2 * time.Millisecond},
+		{in: "5ms+-2ms", wantDelay: 5 * time.Millisecond, wantJitter: 2 * time.Millisecond},
+		{in: "5ms ± 5ms", wantDelay: 5 * time.Millisecond, wantJitter: 5 * time.Millisecond},
+		{in: "5ms±6ms", wantErr: true},
+		{in: "-5ms", wantErr: true},
+		{in: "5", wantErr: true},
+		{in: "5ms±x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			delay, jitter, err := parseInjectDelay(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDelay, delay)
+			assert.Equal(t, tt.wantJitter, jitter)
+		})
+	}
+}
+
+func TestBenchmark_Run_injectedFaults(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.InjectDelay = "20ms±5ms"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.InjectedDelays)
+	assert.GreaterOrEqual(t, merged.Hist.Min(), (15 * time.Millisecond).Nanoseconds(), "the delay is included in the latency")
+
+	bench = createBenchmark(s.Addr, false, 1)
+	bench.DropRate = 1
+	bench.RequestTimeout = 50 * time.Millisecond
+	bench.Retries = 1
+	bench.RetryBackoff = time.Millisecond
+
+	rs, err = bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged = Merge(rs)
+	assert.Equal(t, int64(8), merged.Counters.InjectedDrops, "the retries are dropped as well")
+	assert.Equal(t, int64(4), merged.Counters.InjectedFailures)
+	assert.Zero(t, merged.Counters.IOError, "injected failures are not real errors")
+	assert.Zero(t, merged.Counters.Timeouts)
+}
+
+func TestBenchmark_normalize_injectedFaults(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", DropRate: 1.5}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", InjectDelay: "1s±2s"}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", DropRate: 0.01, InjectDelay: "5ms±2ms"}
+	require.NoError(t, b.normalize())
+	assert.Equal(t, 5*time.Millisecond, b.injectDelay)
+	assert.Equal(t, 2*time.Millisecond, b.injectJitter)
+}
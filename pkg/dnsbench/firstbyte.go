@@ -0,0 +1,82 @@
+package dnsbench
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// keys of ResultStats.FirstByteHists.
+const (
+	firstByteRead   = "first byte"
+	fullMessageRead = "full message"
+	streamingRead   = "streaming"
+)
+
+// readTimer records the times the first and the last byte of the response were read after the query was written.
+type readTimer struct {
+	wrote time.Time
+	first time.Time
+	last  time.Time
+}
+
+// written records the time the query was written, the reads of the previous response are forgotten.
+func (t *readTimer) written() {
+	*t = readTimer{wrote: time.Now()}
+}
+
+// read records the time a part of the response was read.
+func (t *readTimer) read() {
+	now := time.Now()
+	if t.first.IsZero() {
+		t.first = now
+	}
+	t.last = now
+}
+
+// firstByteConn records the times the first and the last byte of the responses read from the stream connection, see Benchmark.FirstByte.
+type firstByteConn struct {
+	net.Conn
+	timer readTimer
+}
+
+func (c *firstByteConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.timer.written()
+	return n, err
+}
+
+func (c *firstByteConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.timer.read()
+	}
+	return n, err
+}
+
+// firstByteBody records the times the parts of DoH response body are read, the first byte of the response is recorded
+// when the HTTP response headers arrive.
+type firstByteBody struct {
+	io.ReadCloser
+	timer *readTimer
+}
+
+func (b *firstByteBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.read()
+	}
+	return n, err
+}
+
+// recordFirstByte records the time until the first byte and until the full response was read, and the time streaming the response in between,
+// nothing is recorded when the reads of the last response were not timed.
+func (rs *ResultStats) recordFirstByte() {
+	t := rs.firstByte
+	if rs.FirstByteHists == nil || t.wrote.IsZero() || t.first.IsZero() {
+		return
+	}
+	recordKeyed(rs.FirstByteHists, firstByteRead, rs.Hist, t.first.Sub(t.wrote).Nanoseconds())
+	recordKeyed(rs.FirstByteHists, fullMessageRead, rs.Hist, t.last.Sub(t.wrote).Nanoseconds())
+	recordKeyed(rs.FirstByteHists, streamingRead, rs.Hist, t.last.Sub(t.first).Nanoseconds())
+}
@@ -0,0 +1,157 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamingDelay is the delay, after which the rest of the response is sent by the slowly streaming test servers.
+const streamingDelay = 50 * time.Millisecond
+
+// slowlyStreamedReply returns the packed reply to the query, the reply is large enough to be split into two writes.
+func slowlyStreamedReply(query []byte) []byte {
+	var m dns.Msg
+	if err := m.Unpack(query); err != nil {
+		panic(err)
+	}
+	ret := new(dns.Msg)
+	ret.SetReply(&m)
+	for i := 0; i < 20; i++ {
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+	}
+	pack, err := ret.Pack()
+	if err != nil {
+		panic(err)
+	}
+	return pack
+}
+
+func TestBenchmark_Run_firstByte_tcp(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				for {
+					var size uint16
+					if err := binary.Read(c, binary.BigEndian, &size); err != nil {
+						return
+					}
+					query := make([]byte, size)
+					if _, err := io.ReadFull(c, query); err != nil {
+						return
+					}
+					reply := slowlyStreamedReply(query)
+					framed := binary.BigEndian.AppendUint16(nil, uint16(len(reply)))
+					framed = append(framed, reply...)
+					c.Write(framed[:20])
+					time.Sleep(streamingDelay)
+					c.Write(framed[20:])
+				}
+			}()
+		}
+	}()
+
+	bench := createBenchmark(l.Addr().String(), true, 1)
+	bench.FirstByte = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.Len(t, merged.FirstByteHists, 3)
+	for _, k := range []string{firstByteRead, fullMessageRead, streamingRead} {
+		assert.Equal(t, int64(4), merged.FirstByteHists[k].TotalCount(), k)
+	}
+	assert.Less(t, merged.FirstByteHists[firstByteRead].ValueAtQuantile(50), streamingDelay.Nanoseconds())
+	assert.GreaterOrEqual(t, merged.FirstByteHists[streamingRead].ValueAtQuantile(50), (streamingDelay - time.Millisecond).Nanoseconds())
+	assert.Greater(t, merged.FirstByteHists[fullMessageRead].ValueAtQuantile(50), merged.FirstByteHists[firstByteRead].ValueAtQuantile(50))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "DNS timings of the first byte and the full response since the query was written:")
+
+	bench.JSON = true
+	buf.Reset()
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	var res struct {
+		LatencyByRead map[string]latencyStats `json:"latencyStatsByRead"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	assert.Len(t, res.LatencyByRead, 3)
+}
+
+func TestBenchmark_Run_firstByte_doh(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := io.ReadAll(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		reply := slowlyStreamedReply(query)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(reply[:20])
+		w.(http.Flusher).Flush()
+		time.Sleep(streamingDelay)
+		w.Write(reply[20:])
+	}))
+	defer ts.Close()
+
+	bench := createBenchmark(ts.URL, true, 1)
+	bench.DohMethod = post
+	bench.FirstByte = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.Len(t, merged.FirstByteHists, 3)
+	assert.Equal(t, int64(4), merged.FirstByteHists[streamingRead].TotalCount())
+	assert.Less(t, merged.FirstByteHists[firstByteRead].ValueAtQuantile(50), streamingDelay.Nanoseconds())
+	assert.GreaterOrEqual(t, merged.FirstByteHists[streamingRead].ValueAtQuantile(50), (streamingDelay - time.Millisecond).Nanoseconds())
+}
+
+func TestBenchmark_normalize_firstByte(t *testing.T) {
+	tests := []struct {
+		name    string
+		bench   Benchmark
+		wantErr bool
+	}{
+		{name: "udp", bench: Benchmark{Server: "8.8.8.8", FirstByte: true}, wantErr: true},
+		{name: "tcp", bench: Benchmark{Server: "8.8.8.8", TCP: true, FirstByte: true}},
+		{name: "dot", bench: Benchmark{Server: "8.8.8.8", DOT: true, FirstByte: true}},
+		{name: "doh", bench: Benchmark{Server: "https://8.8.8.8/dns-query", FirstByte: true}},
+		{name: "pipelined", bench: Benchmark{Server: "8.8.8.8", TCP: true, MaxInflight: 4, FirstByte: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bench.normalize()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
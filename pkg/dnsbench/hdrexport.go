@@ -0,0 +1,67 @@
+package dnsbench
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// writeHgrm writes percentile distribution of the histogram in milliseconds in the .hgrm format of HdrHistogram tooling.
+func writeHgrm(file string, hist *hdrhistogram.Histogram) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create file for histogram export due to '%v'", err)
+	}
+	defer f.Close()
+
+	if _, err := hist.PercentilesPrint(f, 5, float64(time.Millisecond)); err != nil {
+		return fmt.Errorf("failed to export histogram due to '%v'", err)
+	}
+	return nil
+}
+
+// writeHistLog writes the interval histograms keyed by the start of the interval in unix milliseconds in the histogram log format of HdrHistogram tooling,
+// timestamps of the intervals are relative to the start of the first interval.
+func writeHistLog(file string, hists map[int64]*hdrhistogram.Histogram, interval time.Duration) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create file for histogram log export due to '%v'", err)
+	}
+	defer f.Close()
+
+	starts := make([]int64, 0, len(hists))
+	for k := range hists {
+		starts = append(starts, k)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+
+	lw := hdrhistogram.NewHistogramLogWriter(f)
+	if err := lw.OutputLogFormatVersion(); err != nil {
+		return err
+	}
+	if len(starts) > 0 {
+		if err := lw.OutputStartTime(starts[0]); err != nil {
+			return err
+		}
+	}
+	if err := lw.OutputLegend(); err != nil {
+		return err
+	}
+	for _, start := range starts {
+		h := hists[start]
+		payload, err := h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+		if err != nil {
+			return fmt.Errorf("failed to encode histogram due to '%v'", err)
+		}
+		offset := float64(start-starts[0]) / 1000
+		if _, err := fmt.Fprintf(f, "%.3f,%.3f,%.3f,%s\n", offset, interval.Seconds(), float64(h.Max())/float64(time.Millisecond), payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
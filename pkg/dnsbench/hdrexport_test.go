@@ -0,0 +1,69 @@
+package dnsbench
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_writeHgrm(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "latency.hgrm")
+	hist := hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Second.Nanoseconds(), 3)
+	require.NoError(t, hist.RecordValue(10*time.Millisecond.Nanoseconds()))
+	require.NoError(t, hist.RecordValue(20*time.Millisecond.Nanoseconds()))
+
+	require.NoError(t, writeHgrm(file, hist))
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Value\tPercentile\tTotalCount\t1/(1-Percentile)")
+	assert.Contains(t, string(content), "#[Mean    =       14.998")
+	assert.Contains(t, string(content), "#[Max     =       20.005")
+	assert.Contains(t, string(content), "Total count    =            2]")
+}
+
+func Test_writeHistLog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "latency.hlog")
+	first := hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Second.Nanoseconds(), 3)
+	require.NoError(t, first.RecordValue(10*time.Millisecond.Nanoseconds()))
+	second := hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Second.Nanoseconds(), 3)
+	require.NoError(t, second.RecordValue(20*time.Millisecond.Nanoseconds()))
+	require.NoError(t, second.RecordValue(30*time.Millisecond.Nanoseconds()))
+
+	require.NoError(t, writeHistLog(file, map[int64]*hdrhistogram.Histogram{1700000002000: second, 1700000001000: first}, time.Second))
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "#[StartTime: 1700000001 (seconds since epoch)")
+	assert.Contains(t, string(content), "0.000,1.000,10.002,")
+	assert.Contains(t, string(content), "1.000,1.000,30.015,")
+
+	reader := hdrhistogram.NewHistogramLogReader(bytes.NewReader(content))
+	var counts []int64
+	for h, err := reader.NextIntervalHistogram(); h != nil && err == nil; h, err = reader.NextIntervalHistogram() {
+		counts = append(counts, h.TotalCount())
+	}
+	assert.Equal(t, []int64{1, 2}, counts)
+}
+
+func TestResultStats_record_intervals(t *testing.T) {
+	b := Benchmark{HistMin: time.Microsecond, HistMax: time.Second, HistPre: 3, HistLog: "latency.hlog", HistLogInterval: time.Second}
+	st := b.newResultStats(0)
+
+	start := time.UnixMilli(1700000000000)
+	a := query("example.org.", dns.TypeA)
+	st.record(a, reply(a, dns.RcodeSuccess), start, 10*time.Millisecond)
+	st.record(a, reply(a, dns.RcodeSuccess), start.Add(999*time.Millisecond), 10*time.Millisecond)
+	st.record(a, reply(a, dns.RcodeSuccess), start.Add(time.Second), 10*time.Millisecond)
+
+	require.Len(t, st.IntervalHists, 2)
+	assert.Equal(t, int64(2), st.IntervalHists[1700000000000].TotalCount())
+	assert.Equal(t, int64(1), st.IntervalHists[1700000001000].TotalCount())
+}
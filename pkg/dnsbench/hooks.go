@@ -0,0 +1,92 @@
+package dnsbench
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// names of the symbols looked up in the hook plugins, see Benchmark.HookPlugin.
+const (
+	pluginRequestHook  = "RequestHook"
+	pluginResponseHook = "ResponseHook"
+)
+
+// maxHookErrors is the maximum number of distinct errors of the response hook counted in ResultStats.HookErrors,
+// the rest of the errors is counted together, so that errors containing details of each response do not grow the results without bounds.
+const maxHookErrors = 100
+
+// otherHookErrors is the key of ResultStats.HookErrors counting the errors above maxHookErrors.
+const otherHookErrors = "other errors"
+
+// hooks are the request and response hooks of the benchmark registered directly or loaded from the hook plugin.
+type hooks struct {
+	request  func(m *dns.Msg)
+	response func(q, r *dns.Msg) error
+}
+
+// loadHooks combines the hooks registered directly with the hooks exported by the hook plugin, the same hook cannot be provided both ways.
+func (b *Benchmark) loadHooks() error {
+	b.hooks = hooks{request: b.RequestHook, response: b.ResponseHook}
+	if b.HookPlugin == "" {
+		return nil
+	}
+	h, err := openHookPlugin(b.HookPlugin)
+	if err != nil {
+		return err
+	}
+	if h.request == nil && h.response == nil {
+		return fmt.Errorf("hook plugin '%s' exports neither %s nor %s", b.HookPlugin, pluginRequestHook, pluginResponseHook)
+	}
+	if (h.request != nil && b.RequestHook != nil) || (h.response != nil && b.ResponseHook != nil) {
+		return errors.New("hooks of --hook-plugin cannot be combined with the hooks registered by Benchmark.RequestHook and Benchmark.ResponseHook")
+	}
+	if h.request != nil {
+		b.hooks.request = h.request
+	}
+	if h.response != nil {
+		b.hooks.response = h.response
+	}
+	return nil
+}
+
+// lookupHooks resolves the hooks from the symbols found by lookup, the hooks can be exported either as functions or as variables
+// of the function types. Missing symbols are not an error.
+func lookupHooks(lookup func(name string) (any, error)) (hooks, error) {
+	var h hooks
+	if sym, err := lookup(pluginRequestHook); err == nil {
+		switch f := sym.(type) {
+		case func(*dns.Msg):
+			h.request = f
+		case *func(*dns.Msg):
+			h.request = *f
+		default:
+			return hooks{}, fmt.Errorf("symbol %s of hook plugin has type %T, expected func(*dns.Msg)", pluginRequestHook, sym)
+		}
+	}
+	if sym, err := lookup(pluginResponseHook); err == nil {
+		switch f := sym.(type) {
+		case func(*dns.Msg, *dns.Msg) error:
+			h.response = f
+		case *func(*dns.Msg, *dns.Msg) error:
+			h.response = *f
+		default:
+			return hooks{}, fmt.Errorf("symbol %s of hook plugin has type %T, expected func(q, r *dns.Msg) error", pluginResponseHook, sym)
+		}
+	}
+	return h, nil
+}
+
+// recordHookError counts the response rejected by the response hook with the error.
+func (rs *ResultStats) recordHookError(err error) {
+	rs.Counters.HookRejected++
+	if rs.HookErrors == nil {
+		return
+	}
+	msg := err.Error()
+	if _, ok := rs.HookErrors[msg]; !ok && len(rs.HookErrors) >= maxHookErrors {
+		msg = otherHookErrors
+	}
+	rs.HookErrors[msg]++
+}
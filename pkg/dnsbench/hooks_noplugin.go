@@ -0,0 +1,13 @@
+//go:build !((linux || darwin || freebsd) && cgo)
+
+package dnsbench
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// openHookPlugin fails, Go plugins are supported only on Linux, macOS and FreeBSD with cgo enabled.
+func openHookPlugin(path string) (hooks, error) {
+	return hooks{}, fmt.Errorf("failed to open hook plugin '%s', Go plugins are not supported on %s or without cgo, dnspyre has to be built from source with cgo enabled", path, runtime.GOOS)
+}
@@ -0,0 +1,19 @@
+//go:build (linux || darwin || freebsd) && cgo
+
+package dnsbench
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// openHookPlugin loads the hooks exported by the Go plugin, see Benchmark.HookPlugin.
+func openHookPlugin(path string) (hooks, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return hooks{}, fmt.Errorf("failed to open hook plugin '%s' due to '%v'", path, err)
+	}
+	return lookupHooks(func(name string) (any, error) {
+		return p.Lookup(name)
+	})
+}
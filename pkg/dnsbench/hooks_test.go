@@ -0,0 +1,119 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_Run_hooks(t *testing.T) {
+	var mu sync.Mutex
+	var options []string
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		if opt := r.IsEdns0(); opt != nil {
+			for _, o := range opt.Option {
+				options = append(options, o.String())
+			}
+		}
+		mu.Unlock()
+		replyHandler(w, r)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.RequestHook = func(m *dns.Msg) {
+		m.SetEdns0(1232, false)
+		opt := m.IsEdns0()
+		opt.Option = append(opt.Option, &dns.EDNS0_LOCAL{Code: 65001, Data: []byte{0xab}})
+	}
+	bench.ResponseHook = func(q, r *dns.Msg) error {
+		if q.Question[0].Qtype == dns.TypeAAAA {
+			return fmt.Errorf("%s answered", dns.TypeToString[r.Question[0].Qtype])
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	mu.Lock()
+	assert.Equal(t, []string{"65001:0xab", "65001:0xab", "65001:0xab", "65001:0xab"}, options)
+	mu.Unlock()
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(2), merged.Counters.HookRejected)
+	assert.Equal(t, map[string]int64{"AAAA answered": 2}, merged.HookErrors)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "Rejected by hook:\t2")
+	assert.Contains(t, buf.String(), "\tAAAA answered:\t2")
+}
+
+func TestResultStats_recordHookError(t *testing.T) {
+	rs := ResultStats{Counters: &Counters{}, HookErrors: make(map[string]int64)}
+	for i := 0; i < maxHookErrors+10; i++ {
+		rs.recordHookError(fmt.Errorf("error %d", i))
+	}
+	rs.recordHookError(errors.New("error 0"))
+
+	assert.Equal(t, int64(maxHookErrors+11), rs.Counters.HookRejected)
+	assert.Len(t, rs.HookErrors, maxHookErrors+1)
+	assert.Equal(t, int64(2), rs.HookErrors["error 0"])
+	assert.Equal(t, int64(10), rs.HookErrors[otherHookErrors])
+}
+
+func Test_lookupHooks(t *testing.T) {
+	var calls []string
+	request := func(*dns.Msg) { calls = append(calls, "request") }
+	response := func(_, _ *dns.Msg) error { calls = append(calls, "response"); return nil }
+	symbols := func(syms map[string]any) func(string) (any, error) {
+		return func(name string) (any, error) {
+			if s, ok := syms[name]; ok {
+				return s, nil
+			}
+			return nil, errors.New("symbol not found")
+		}
+	}
+
+	h, err := lookupHooks(symbols(map[string]any{pluginRequestHook: request, pluginResponseHook: &response}))
+	require.NoError(t, err)
+	h.request(nil)
+	require.NoError(t, h.response(nil, nil))
+	assert.Equal(t, []string{"request", "response"}, calls)
+
+	h, err = lookupHooks(symbols(map[string]any{pluginResponseHook: response}))
+	require.NoError(t, err)
+	assert.Nil(t, h.request)
+	assert.NotNil(t, h.response)
+
+	_, err = lookupHooks(symbols(map[string]any{pluginRequestHook: func(*dns.Msg) error { return nil }}))
+	assert.Error(t, err)
+}
+
+func TestBenchmark_normalize_hookPlugin(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", HookPlugin: "missing.so"}
+	assert.ErrorContains(t, b.normalize(), "hook plugin 'missing.so'")
+}
+
+func TestBenchmark_hookPlugin_notSentToWorkers(t *testing.T) {
+	data, err := json.Marshal(Benchmark{Server: "8.8.8.8", HookPlugin: "hooks.so"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hooks.so")
+
+	var b Benchmark
+	require.NoError(t, json.Unmarshal([]byte(`{"Server": "8.8.8.8", "HookPlugin": "hooks.so"}`), &b))
+	assert.Empty(t, b.HookPlugin, "plugin path received from the coordinator is ignored")
+}
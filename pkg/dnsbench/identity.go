@@ -0,0 +1,104 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/miekg/dns"
+)
+
+// nsidOption requests the name server identifier, see https://datatracker.ietf.org/doc/html/rfc5001.
+var nsidOption = &dns.EDNS0_LOCAL{Code: dns.EDNS0NSID}
+
+// responseNSID returns the name server identifier of the response, printable identifiers are returned as they are,
+// other identifiers are returned as hexadecimal string.
+func responseNSID(resp *dns.Msg) string {
+	o := resp.IsEdns0()
+	if o == nil {
+		return ""
+	}
+	for _, opt := range o.Option {
+		nsid, ok := opt.(*dns.EDNS0_NSID)
+		if !ok || nsid.Nsid == "" {
+			continue
+		}
+		data, err := hex.DecodeString(nsid.Nsid)
+		if err != nil {
+			return nsid.Nsid
+		}
+		if isPrintable(string(data)) {
+			return string(data)
+		}
+		return nsid.Nsid
+	}
+	return ""
+}
+
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// chaosProbe identifies the server answering the queries of a single benchmark worker using hostname.bind CH TXT queries,
+// the server is probed when the first identity is needed and then again after each interval.
+type chaosProbe struct {
+	interval time.Duration
+	// zeroID is set for DoQ, which requires zero message IDs
+	zeroID bool
+	last   time.Time
+	id     string
+}
+
+// identity returns the identity of the server returned by the last probe, the server is probed again when the interval elapsed.
+// Failed probes reset the identity, so that the following responses are not attributed to the previously identified server.
+func (p *chaosProbe) identity(ctx context.Context, query queryFunc, server string) string {
+	if p == nil {
+		return ""
+	}
+	if !p.last.IsZero() && time.Since(p.last) < p.interval {
+		return p.id
+	}
+	p.last = time.Now()
+	p.id = ""
+
+	m := new(dns.Msg)
+	m.SetQuestion("hostname.bind.", dns.TypeTXT)
+	m.Question[0].Qclass = dns.ClassCHAOS
+	if p.zeroID {
+		m.Id = 0
+	}
+	resp, err := query(ctx, server, m)
+	if err != nil || resp.Rcode != dns.RcodeSuccess {
+		return ""
+	}
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok && len(txt.Txt) > 0 {
+			p.id = strings.Join(txt.Txt, "")
+			break
+		}
+	}
+	return p.id
+}
+
+// recordIdentity records the latency of the response to the histogram of the identity of the responding server, NSID of the response
+// takes precedence over the identity found by the probes.
+func (rs *ResultStats) recordIdentity(resp *dns.Msg, probed string, timing time.Duration) {
+	if rs.IdentityHists == nil {
+		return
+	}
+	id := responseNSID(resp)
+	if id == "" {
+		id = probed
+	}
+	if id == "" {
+		id = "unknown"
+	}
+	recordKeyed(rs.IdentityHists, id, rs.Hist, timing.Nanoseconds())
+}
@@ -0,0 +1,117 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_responseNSID(t *testing.T) {
+	tests := []struct {
+		name string
+		nsid string
+		want string
+	}{
+		{name: "printable", nsid: hex.EncodeToString([]byte("ams1.pop")), want: "ams1.pop"},
+		{name: "binary", nsid: "00ff", want: "00ff"},
+		{name: "empty", nsid: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := new(dns.Msg)
+			m.SetEdns0(4096, false)
+			m.IsEdns0().Option = append(m.IsEdns0().Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: tt.nsid})
+			assert.Equal(t, tt.want, responseNSID(m))
+		})
+	}
+	assert.Empty(t, responseNSID(new(dns.Msg)))
+}
+
+// identityHandler answers A queries with NSID, if requested, and hostname.bind CH TXT probes, AAAA responses carry no NSID.
+func identityHandler(w dns.ResponseWriter, r *dns.Msg) {
+	ret := new(dns.Msg)
+	ret.SetReply(r)
+	q := r.Question[0]
+	if q.Qclass == dns.ClassCHAOS {
+		if q.Name != "hostname.bind." || q.Qtype != dns.TypeTXT {
+			ret.Rcode = dns.RcodeRefused
+		} else {
+			ret.Answer = append(ret.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS}, Txt: []string{"probed-instance"}})
+		}
+		w.WriteMsg(ret)
+		return
+	}
+	if o := r.IsEdns0(); o != nil && q.Qtype == dns.TypeA {
+		for _, opt := range o.Option {
+			if opt.Option() == dns.EDNS0NSID {
+				ret.SetEdns0(4096, false)
+				ret.IsEdns0().Option = append(ret.IsEdns0().Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: hex.EncodeToString([]byte("nsid-instance"))})
+			}
+		}
+	}
+	w.WriteMsg(ret)
+}
+
+func TestBenchmark_Run_nsid(t *testing.T) {
+	s := NewServer(udp, identityHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.NSID = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.Len(t, merged.IdentityHists, 2)
+	assert.Equal(t, int64(2), merged.IdentityHists["nsid-instance"].TotalCount())
+	assert.Equal(t, int64(2), merged.IdentityHists["unknown"].TotalCount())
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "DNS timings by server identity:")
+}
+
+func TestBenchmark_Run_chaos_probe(t *testing.T) {
+	s := NewServer(udp, identityHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.NSID = true
+	bench.ChaosProbeInterval = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.Len(t, merged.IdentityHists, 2)
+	assert.Equal(t, int64(2), merged.IdentityHists["nsid-instance"].TotalCount())
+	assert.Equal(t, int64(2), merged.IdentityHists["probed-instance"].TotalCount(), "responses without NSID are attributed to the probed identity")
+	assert.Equal(t, int64(4), merged.Counters.Total, "probes are not counted")
+}
+
+func TestBenchmark_PrintReport_single_identity(t *testing.T) {
+	b, rs := testData()
+	b.NSID = true
+	rs.IdentityHists = mergeKeyed(nil, map[string]*hdrhistogram.Histogram{"ams1": rs.Hist})
+
+	buf := bytes.Buffer{}
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.Contains(t, buf.String(), "All responses were returned by server ams1")
+}
+
+func TestBenchmark_normalize_chaosProbeInterval(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", ChaosProbeInterval: -time.Second}
+	assert.Error(t, b.normalize())
+}
@@ -0,0 +1,507 @@
+package dnsbench
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+type jsonReporter struct{}
+
+type latencyStats struct {
+	MinMs  int64 `json:"minMs"`
+	MeanMs int64 `json:"meanMs"`
+	StdMs  int64 `json:"stdMs"`
+	MaxMs  int64 `json:"maxMs"`
+	P99Ms  int64 `json:"p99Ms"`
+	P95Ms  int64 `json:"p95Ms"`
+	P90Ms  int64 `json:"p90Ms"`
+	P75Ms  int64 `json:"p75Ms"`
+	P50Ms  int64 `json:"p50Ms"`
+}
+
+type errorCount struct {
+	Error string `json:"error"`
+	Count int    `json:"count"`
+}
+
+type histogramPoint struct {
+	LatencyMs int64 `json:"latencyMs"`
+	Count     int64 `json:"count"`
+}
+
+type jsonResult struct {
+	Interrupted              bool                     `json:"interrupted,omitempty"`
+	MaxRuntimeExceeded       bool                     `json:"maxRuntimeExceeded,omitempty"`
+	TotalUnsent              int64                    `json:"totalUnsent,omitempty"`
+	TotalUnanswered          int64                    `json:"totalUnanswered,omitempty"`
+	TotalRequests            int64                    `json:"totalRequests"`
+	TotalSuccessCodes        int64                    `json:"totalSuccessCodes"`
+	TotalErrors              int64                    `json:"totalErrors"`
+	TotalTimeouts            int64                    `json:"totalTimeouts,omitempty"`
+	TopErrors                []errorCount             `json:"topErrors,omitempty"`
+	ErrorCategories          map[string]int64         `json:"errorCategories,omitempty"`
+	HookErrors               map[string]int64         `json:"hookErrors,omitempty"`
+	TotalIDmismatch          int64                    `json:"TotalIDmismatch"`
+	TotalTruncatedResponses  int64                    `json:"totalTruncatedResponses"`
+	TotalRetries             int64                    `json:"totalRetries,omitempty"`
+	TotalTCPRetries          int64                    `json:"totalTCPRetries,omitempty"`
+	TotalStubCacheHits       int64                    `json:"totalStubCacheHits,omitempty"`
+	StubCacheHitRate         float64                  `json:"stubCacheHitRate,omitempty"`
+	TotalCNAMEChases         int64                    `json:"totalCNAMEChases,omitempty"`
+	TotalNXDomainQueries     int64                    `json:"totalNXDomainQueries,omitempty"`
+	TotalServerCloses        int64                    `json:"totalServerCloses,omitempty"`
+	TotalIdleCloses          int64                    `json:"totalIdleCloses,omitempty"`
+	TotalFailovers           int64                    `json:"totalFailovers,omitempty"`
+	TotalUDPRetransmits      int64                    `json:"totalUDPRetransmits,omitempty"`
+	TotalLateResponses       int64                    `json:"totalLateResponses,omitempty"`
+	TotalDuplicateResponses  int64                    `json:"totalDuplicateResponses,omitempty"`
+	TotalExpiredResponses    int64                    `json:"totalExpiredResponses,omitempty"`
+	TotalInjectedDelays      int64                    `json:"totalInjectedDelays,omitempty"`
+	TotalInjectedDrops       int64                    `json:"totalInjectedDrops,omitempty"`
+	TotalInjectedFailures    int64                    `json:"totalInjectedFailures,omitempty"`
+	KeepaliveTimeouts        map[string]int64         `json:"keepaliveTimeouts,omitempty"`
+	TotalCaseMismatch        int64                    `json:"totalCaseMismatch,omitempty"`
+	TotalServerCookies       int64                    `json:"totalServerCookies,omitempty"`
+	TotalCookieMissing       int64                    `json:"totalCookieMissing,omitempty"`
+	TotalCookieMismatch      int64                    `json:"totalCookieMismatch,omitempty"`
+	TotalRcodeMismatch       int64                    `json:"totalRcodeMismatch,omitempty"`
+	TotalAnswerCountMismatch int64                    `json:"totalAnswerCountMismatch,omitempty"`
+	TotalIPMismatch          int64                    `json:"totalIPMismatch,omitempty"`
+	TotalTSIGErrors          int64                    `json:"totalTSIGErrors,omitempty"`
+	TotalValidationOK        int64                    `json:"totalValidationOK,omitempty"`
+	TotalValidationFailed    int64                    `json:"totalValidationFailed,omitempty"`
+	TotalHookRejected        int64                    `json:"totalHookRejected,omitempty"`
+	ResponseRcodes           map[string]int64         `json:"responseRcodes,omitempty"`
+	QuestionTypes            map[string]int64         `json:"questionTypes"`
+	DoHProtocols             map[string]int64         `json:"dohProtocols,omitempty"`
+	DoH                      *DoHStats                `json:"doh,omitempty"`
+	AttemptsPerQuery         map[int]int64            `json:"attemptsPerQuery,omitempty"`
+	AddressFamilies          map[string]int64         `json:"addressFamilies,omitempty"`
+	QueriesPerSecond         float64                  `json:"queriesPerSecond"`
+	BenchmarkDurationSeconds float64                  `json:"benchmarkDurationSeconds"`
+	LatencyStats             latencyStats             `json:"latencyStats"`
+	LatencyPercentiles       []jsonPercentile         `json:"latencyPercentiles,omitempty"`
+	LatencyDistribution      []histogramPoint         `json:"latencyDistribution,omitempty"`
+	LatencyByQuestionType    map[string]latencyStats  `json:"latencyStatsByQuestionType,omitempty"`
+	LatencyByResponseCode    map[string]latencyStats  `json:"latencyStatsByResponseCode,omitempty"`
+	LatencyByResponseFlags   map[string]latencyStats  `json:"latencyStatsByResponseFlags,omitempty"`
+	LatencyByCNAMEDepth      map[string]latencyStats  `json:"latencyStatsByCNAMEDepth,omitempty"`
+	LatencyByFailoverServer  map[string]latencyStats  `json:"latencyStatsByFailoverServer,omitempty"`
+	FailoverUnanswered       map[string]int64         `json:"failoverUnanswered,omitempty"`
+	AnswerTTLs               map[string]jsonTTLStats  `json:"answerTTLsByQuestionType,omitempty"`
+	ResponseSizes            map[string]jsonSizeStats `json:"responseSizesByTransport,omitempty"`
+	ResponseSizesByQtype     map[string]jsonSizeStats `json:"responseSizesByQuestionType,omitempty"`
+	Padding                  map[string]jsonPadStats  `json:"paddingByTransport,omitempty"`
+	LatencyByDoHMethod       map[string]latencyStats  `json:"latencyStatsByDoHMethod,omitempty"`
+	LatencyByServerIdentity  map[string]latencyStats  `json:"latencyStatsByServerIdentity,omitempty"`
+	LatencyByRead            map[string]latencyStats  `json:"latencyStatsByRead,omitempty"`
+	Diff                     *jsonDiff                `json:"diff,omitempty"`
+	RateRampSteps            []jsonRampStep           `json:"rateRampSteps,omitempty"`
+	ConcurrencySteps         []jsonConcurrencyStep    `json:"concurrencySteps,omitempty"`
+	Workers                  []jsonWorker             `json:"workers,omitempty"`
+	WorkerThroughputSkew     float64                  `json:"workerThroughputSkew,omitempty"`
+	Series                   []jsonSeriesBucket       `json:"series,omitempty"`
+	Connections              *jsonConnections         `json:"connections,omitempty"`
+	Transfer                 *jsonTransfer            `json:"transfer,omitempty"`
+	Dual                     *jsonDual                `json:"dual,omitempty"`
+	Search                   *jsonSearch              `json:"searchExpansion,omitempty"`
+	NetworkRTT               *jsonRTT                 `json:"networkRTT,omitempty"`
+	Bursts                   *jsonBursts              `json:"bursts,omitempty"`
+	Malformed                *jsonMalformed           `json:"malformed,omitempty"`
+	Responders               *jsonResponders          `json:"responders,omitempty"`
+	CacheModel               *jsonCacheModel          `json:"cacheModel,omitempty"`
+	RRL                      *jsonRRL                 `json:"rrl,omitempty"`
+	EDNSSizes                []jsonEDNSSize           `json:"ednsSizes,omitempty"`
+	ClientUsage              *jsonClientUsage         `json:"clientUsage,omitempty"`
+}
+
+type jsonConnections struct {
+	TotalConnections     int64         `json:"totalConnections"`
+	ConnectionsPerWorker float64       `json:"connectionsPerWorker"`
+	DoHPoolSize          int           `json:"dohPoolSize,omitempty"`
+	HandshakesPerSecond  float64       `json:"handshakesPerSecond"`
+	ConnectionSetupStats latencyStats  `json:"connectionSetupLatencyStats"`
+	ProxySetupStats      *latencyStats `json:"proxySetupLatencyStats,omitempty"`
+}
+
+type jsonTransfer struct {
+	TotalRecords     int64        `json:"totalRecords"`
+	TotalBytes       int64        `json:"totalBytes"`
+	FirstRecordStats latencyStats `json:"firstRecordLatencyStats"`
+}
+
+type jsonDual struct {
+	LatencyByFamily  map[string]latencyStats `json:"latencyStatsByFamily,omitempty"`
+	ErrorsByFamily   map[string]int64        `json:"errorsByFamily,omitempty"`
+	FirstUsableStats latencyStats            `json:"firstUsableLatencyStats"`
+	TotalUnusable    int64                   `json:"totalUnusable"`
+}
+
+type jsonSearch struct {
+	TotalLookups     int64        `json:"totalLookups"`
+	TotalQueries     int64        `json:"totalQueries"`
+	TotalMisses      int64        `json:"totalMisses"`
+	QueriesPerLookup float64      `json:"queriesPerLookup"`
+	MeanOverheadMs   float64      `json:"meanOverheadMs"`
+	LookupStats      latencyStats `json:"lookupLatencyStats"`
+	AnswerStats      latencyStats `json:"answerLatencyStats"`
+}
+
+type jsonResponders struct {
+	TotalUnexpectedSources int64                   `json:"totalUnexpectedSources"`
+	LatencyByAddress       map[string]latencyStats `json:"latencyStatsByAddress,omitempty"`
+}
+
+type jsonMalformed struct {
+	TotalQueries       int64                       `json:"totalQueries"`
+	OutcomesByStrategy map[string]map[string]int64 `json:"outcomesByStrategy"`
+}
+
+type jsonBursts struct {
+	Size              int                     `json:"size"`
+	IntervalSeconds   float64                 `json:"intervalSeconds"`
+	TotalBursts       int                     `json:"totalBursts"`
+	DrainP50Ms        float64                 `json:"drainP50Ms"`
+	DrainP99Ms        float64                 `json:"drainP99Ms"`
+	DrainMaxMs        float64                 `json:"drainMaxMs"`
+	DroppedMean       float64                 `json:"droppedPerBurstMean"`
+	DroppedMax        int64                   `json:"droppedPerBurstMax"`
+	LatencyByPosition map[string]latencyStats `json:"latencyStatsByPosition,omitempty"`
+}
+
+type jsonWorker struct {
+	Worker           int     `json:"worker"`
+	TotalRequests    int64   `json:"totalRequests"`
+	QueriesPerSecond float64 `json:"queriesPerSecond"`
+	TotalErrors      int64   `json:"totalErrors"`
+	Connections      int64   `json:"connections"`
+	P50Ms            int64   `json:"p50Ms"`
+	P99Ms            int64   `json:"p99Ms"`
+	MaxMs            int64   `json:"maxMs"`
+}
+
+type jsonConcurrencyStep struct {
+	OffsetSeconds    float64 `json:"offsetSeconds"`
+	Workers          uint32  `json:"workers"`
+	QueriesPerSecond float64 `json:"queriesPerSecond,omitempty"`
+}
+
+type jsonRampStep struct {
+	TargetQueriesPerSecond int     `json:"targetQueriesPerSecond"`
+	DurationSeconds        float64 `json:"durationSeconds"`
+	TotalResponses         int64   `json:"totalResponses"`
+	QueriesPerSecond       float64 `json:"queriesPerSecond"`
+	MeanMs                 float64 `json:"meanMs"`
+	P99Ms                  float64 `json:"p99Ms"`
+}
+
+type jsonSeriesBucket struct {
+	OffsetSeconds    float64 `json:"offsetSeconds"`
+	TotalResponses   int64   `json:"totalResponses"`
+	TotalErrors      int64   `json:"totalErrors"`
+	QueriesPerSecond float64 `json:"queriesPerSecond"`
+	ErrorRate        float64 `json:"errorRate"`
+	MeanMs           float64 `json:"meanMs"`
+	P99Ms            float64 `json:"p99Ms"`
+}
+
+type jsonDiff struct {
+	Server            string       `json:"server"`
+	Agreements        int64        `json:"agreements"`
+	Disagreements     int64        `json:"disagreements"`
+	TotalRequests     int64        `json:"totalRequests"`
+	TotalSuccessCodes int64        `json:"totalSuccessCodes"`
+	TotalErrors       int64        `json:"totalErrors"`
+	LatencyStats      latencyStats `json:"latencyStats"`
+}
+
+func (s *jsonReporter) print(w io.Writer, b *Benchmark, stats *ResultStats, topErrs orderedMap, t time.Duration) error {
+	timings := stats.Hist
+	codeTotals := stats.Codes
+	totalCounters := *stats.Counters
+	qtypeTotals := stats.Qtypes
+	diff := stats.Diff
+
+	sumerrs := int64(0)
+	for _, v := range topErrs.m {
+		sumerrs += int64(v)
+	}
+
+	var topErrors []errorCount
+	for _, err := range topErrs.order {
+		topErrors = append(topErrors, errorCount{Error: err, Count: topErrs.m[err]})
+	}
+
+	codeTotalsMapped := make(map[string]int64)
+	if b.Rcodes {
+		for k, v := range codeTotals {
+			codeTotalsMapped[dns.RcodeToString[k]] = v
+		}
+	}
+
+	var res []histogramPoint
+
+	if b.HistDisplay {
+		dist := b.distribution(timings)
+		for _, d := range dist {
+			res = append(res, histogramPoint{
+				LatencyMs: time.Duration(d.To/2 + d.From/2).Milliseconds(),
+				Count:     d.Count,
+			})
+		}
+
+		var dedupRes []histogramPoint
+		i := -1
+		for _, r := range res {
+			if i >= 0 && i < len(res) {
+				if dedupRes[i].LatencyMs == r.LatencyMs {
+					dedupRes[i].Count += r.Count
+				} else {
+					dedupRes = append(dedupRes, r)
+					i++
+				}
+			} else {
+				dedupRes = append(dedupRes, r)
+				i++
+			}
+		}
+	}
+
+	result := jsonResult{
+		Interrupted:              b.interrupted,
+		MaxRuntimeExceeded:       b.maxRuntimeExceeded,
+		TotalUnsent:              totalCounters.Unsent,
+		TotalUnanswered:          totalCounters.Unanswered,
+		TotalRequests:            totalCounters.Total,
+		TotalSuccessCodes:        totalCounters.Success,
+		TotalErrors:              sumerrs,
+		TotalTimeouts:            totalCounters.Timeouts,
+		TopErrors:                topErrors,
+		ErrorCategories:          stats.ErrorCategories,
+		HookErrors:               stats.HookErrors,
+		TotalIDmismatch:          totalCounters.IDmismatch,
+		TotalTruncatedResponses:  totalCounters.Truncated,
+		TotalRetries:             totalCounters.Retries,
+		TotalTCPRetries:          totalCounters.TCPRetries,
+		TotalStubCacheHits:       totalCounters.StubCacheHits,
+		StubCacheHitRate:         math.Round(stubCacheHitRate(totalCounters)*10000) / 10000,
+		TotalCNAMEChases:         totalCounters.CNAMEChases,
+		TotalNXDomainQueries:     totalCounters.NXDomainQueries,
+		TotalServerCloses:        totalCounters.ServerCloses,
+		TotalIdleCloses:          totalCounters.IdleCloses,
+		TotalFailovers:           totalCounters.Failovers,
+		TotalUDPRetransmits:      totalCounters.UDPRetransmits,
+		TotalLateResponses:       totalCounters.LateResponses,
+		TotalDuplicateResponses:  totalCounters.DuplicateResponses,
+		TotalExpiredResponses:    totalCounters.ExpiredResponses,
+		TotalInjectedDelays:      totalCounters.InjectedDelays,
+		TotalInjectedDrops:       totalCounters.InjectedDrops,
+		TotalInjectedFailures:    totalCounters.InjectedFailures,
+		KeepaliveTimeouts:        stats.KeepaliveTimeouts,
+		TotalCaseMismatch:        totalCounters.CaseMismatch,
+		TotalServerCookies:       totalCounters.ServerCookies,
+		TotalCookieMissing:       totalCounters.CookieMissing,
+		TotalCookieMismatch:      totalCounters.CookieMismatch,
+		TotalRcodeMismatch:       totalCounters.RcodeMismatch,
+		TotalAnswerCountMismatch: totalCounters.AnswerCountMismatch,
+		TotalIPMismatch:          totalCounters.IPMismatch,
+		TotalTSIGErrors:          totalCounters.TSIGError,
+		TotalValidationOK:        totalCounters.ValidationOK,
+		TotalValidationFailed:    totalCounters.ValidationFailed,
+		TotalHookRejected:        totalCounters.HookRejected,
+		QueriesPerSecond:         math.Round(float64(totalCounters.Total)/t.Seconds()*100) / 100,
+		BenchmarkDurationSeconds: roundDuration(t).Seconds(),
+		ResponseRcodes:           codeTotalsMapped,
+		QuestionTypes:            qtypeTotals,
+		DoHProtocols:             stats.DoHProtocols,
+		DoH:                      stats.DoH,
+		AttemptsPerQuery:         stats.Attempts,
+		AddressFamilies:          stats.Families,
+		LatencyStats:             newLatencyStats(timings),
+		LatencyDistribution:      res,
+		LatencyByQuestionType:    latencyStatsByKey(stats.QtypeHists),
+		LatencyByResponseCode:    latencyStatsByKey(rcodeHistsByName(stats.RcodeHists)),
+		LatencyByResponseFlags:   latencyStatsByKey(stats.FlagHists),
+		LatencyByCNAMEDepth:      latencyStatsByKey(cnameDepthHistsByName(stats.CNAMEDepthHists)),
+		LatencyByFailoverServer:  latencyStatsByKey(stats.FailoverHists),
+		FailoverUnanswered:       stats.FailoverUnanswered,
+		AnswerTTLs:               jsonTTLs(stats.TTLs),
+		ResponseSizes:            jsonSizes(stats.Sizes),
+		ResponseSizesByQtype:     jsonSizes(stats.QtypeSizes),
+		Padding:                  jsonPadding(stats.Sizes),
+		LatencyByDoHMethod:       latencyStatsByKey(stats.DoHMethodHists),
+		LatencyByServerIdentity:  latencyStatsByKey(stats.IdentityHists),
+		LatencyByRead:            latencyStatsByKey(stats.FirstByteHists),
+	}
+
+	if b.percentiles != nil {
+		result.LatencyPercentiles = newJSONPercentiles(timings, b.percentiles)
+	}
+
+	if diff != nil {
+		result.Diff = &jsonDiff{
+			Server:            b.DiffServer,
+			Agreements:        totalCounters.Agreements,
+			Disagreements:     totalCounters.Disagreements,
+			TotalRequests:     diff.Counters.Total,
+			TotalSuccessCodes: diff.Counters.Success,
+			TotalErrors:       diff.Counters.IOError,
+			LatencyStats:      newLatencyStats(diff.Hist),
+		}
+	}
+
+	if c := stats.Connections; c != nil {
+		result.Connections = &jsonConnections{
+			TotalConnections:    c.Count,
+			DoHPoolSize:         b.DoHConnections,
+			HandshakesPerSecond: math.Round(float64(c.Count)/t.Seconds()*100) / 100,
+		}
+		if c.Workers > 0 {
+			result.Connections.ConnectionsPerWorker = math.Round(float64(c.Count)/float64(c.Workers)*100) / 100
+		}
+		if c.Setup != nil {
+			result.Connections.ConnectionSetupStats = newLatencyStats(c.Setup)
+		}
+		if c.ProxySetup != nil {
+			proxySetup := newLatencyStats(c.ProxySetup)
+			result.Connections.ProxySetupStats = &proxySetup
+		}
+	}
+
+	if t := stats.Transfer; t != nil {
+		result.Transfer = &jsonTransfer{TotalRecords: t.Records, TotalBytes: t.Bytes}
+		if t.FirstRecord != nil {
+			result.Transfer.FirstRecordStats = newLatencyStats(t.FirstRecord)
+		}
+	}
+
+	if s := stats.Search; s != nil && s.Lookups > 0 {
+		result.Search = &jsonSearch{
+			TotalLookups:     s.Lookups,
+			TotalQueries:     s.Queries,
+			TotalMisses:      s.Misses,
+			QueriesPerLookup: math.Round(float64(s.Queries)/float64(s.Lookups)*100) / 100,
+			MeanOverheadMs:   durationMs(s.overhead()),
+		}
+		if s.LookupHist != nil && s.LookupHist.TotalCount() > 0 {
+			result.Search.LookupStats = newLatencyStats(s.LookupHist)
+			result.Search.AnswerStats = newLatencyStats(s.AnswerHist)
+		}
+	}
+	if stats.RTT != nil {
+		result.NetworkRTT = newJSONRTT(stats.RTT, stats.Hist, b.reportedPercentiles())
+	}
+	if d := stats.Dual; d != nil {
+		result.Dual = &jsonDual{LatencyByFamily: latencyStatsByKey(d.FamilyHists), ErrorsByFamily: d.FamilyErrors, TotalUnusable: d.Unusable}
+		if d.FirstUsable != nil {
+			result.Dual.FirstUsableStats = newLatencyStats(d.FirstUsable)
+		}
+	}
+
+	if stats.Bursts != nil {
+		s := summarizeBursts(stats.Bursts)
+		result.Bursts = &jsonBursts{
+			Size:              b.Burst,
+			IntervalSeconds:   b.BurstInterval.Seconds(),
+			TotalBursts:       s.bursts,
+			DrainP50Ms:        durationMs(s.drainP50),
+			DrainP99Ms:        durationMs(s.drainP99),
+			DrainMaxMs:        durationMs(s.drainMax),
+			DroppedMean:       math.Round(s.droppedMean*100) / 100,
+			DroppedMax:        s.droppedMax,
+			LatencyByPosition: latencyStatsByKey(burstHistsByName(stats.BurstHists)),
+		}
+	}
+
+	if stats.Malformed != nil {
+		result.Malformed = &jsonMalformed{TotalQueries: totalCounters.MalformedQueries, OutcomesByStrategy: stats.Malformed}
+	}
+
+	if stats.CacheHists != nil {
+		result.CacheModel = newJSONCacheModel(stats.CacheHists, &totalCounters)
+	}
+
+	if stats.RRL != nil {
+		result.RRL = newJSONRRL(b.RRLInterval, stats.RRL)
+	}
+
+	if stats.EDNSSizes != nil {
+		result.EDNSSizes = newJSONEDNSSizes(stats.EDNSSizes, stats.EDNSSizeHists)
+	}
+
+	if b.usage != nil && b.ClientUsage {
+		result.ClientUsage = newJSONClientUsage(b.usage)
+	}
+
+	if stats.ResponderHists != nil {
+		result.Responders = &jsonResponders{TotalUnexpectedSources: totalCounters.UnexpectedSources, LatencyByAddress: latencyStatsByKey(stats.ResponderHists)}
+	}
+
+	if steps, err := parseRateRamp(b.RateRamp); b.RateRamp != "" && err == nil {
+		for _, s := range rampStepStats(steps, stats.Timings, stats.datapointWeight()) {
+			result.RateRampSteps = append(result.RateRampSteps, jsonRampStep{
+				TargetQueriesPerSecond: s.Rate,
+				DurationSeconds:        s.Duration.Seconds(),
+				TotalResponses:         s.Responses,
+				QueriesPerSecond:       s.QueriesPerSecond,
+				MeanMs:                 s.MeanMs,
+				P99Ms:                  s.P99Ms,
+			})
+		}
+	}
+
+	for _, s := range b.concurrencySteps {
+		result.ConcurrencySteps = append(result.ConcurrencySteps, jsonConcurrencyStep{
+			OffsetSeconds:    s.offset.Seconds(),
+			Workers:          s.workers,
+			QueriesPerSecond: math.Round(s.qps*100) / 100,
+		})
+	}
+
+	for _, s := range stats.workers {
+		result.Workers = append(result.Workers, jsonWorker{
+			Worker:           s.worker,
+			TotalRequests:    s.queries,
+			QueriesPerSecond: math.Round(s.qps*100) / 100,
+			TotalErrors:      s.errors,
+			Connections:      s.connections,
+			P50Ms:            s.p50.Milliseconds(),
+			P99Ms:            s.p99.Milliseconds(),
+			MaxMs:            s.max.Milliseconds(),
+		})
+	}
+	result.WorkerThroughputSkew = math.Round(workerSkew(stats.workers)*100) / 100
+
+	for _, s := range timeSeries(stats.Timings, stats.ErrorTimes, b.SeriesInterval, stats.datapointWeight()) {
+		result.Series = append(result.Series, jsonSeriesBucket{
+			OffsetSeconds:    s.Offset.Seconds(),
+			TotalResponses:   s.Responses,
+			TotalErrors:      s.Errors,
+			QueriesPerSecond: s.QueriesPerSecond,
+			ErrorRate:        s.ErrorRate,
+			MeanMs:           s.MeanMs,
+			P99Ms:            s.P99Ms,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(result)
+}
+
+func newLatencyStats(timings *hdrhistogram.Histogram) latencyStats {
+	return latencyStats{
+		MinMs:  time.Duration(timings.Min()).Milliseconds(),
+		MeanMs: time.Duration(timings.Mean()).Milliseconds(),
+		StdMs:  time.Duration(timings.StdDev()).Milliseconds(),
+		MaxMs:  time.Duration(timings.Max()).Milliseconds(),
+		P99Ms:  time.Duration(timings.ValueAtQuantile(99)).Milliseconds(),
+		P95Ms:  time.Duration(timings.ValueAtQuantile(95)).Milliseconds(),
+		P90Ms:  time.Duration(timings.ValueAtQuantile(90)).Milliseconds(),
+		P75Ms:  time.Duration(timings.ValueAtQuantile(75)).Milliseconds(),
+		P50Ms:  time.Duration(timings.ValueAtQuantile(50)).Milliseconds(),
+	}
+}
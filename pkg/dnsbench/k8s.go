@@ -0,0 +1,92 @@
+package dnsbench
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// defaultClusterDomain is the default domain of Kubernetes clusters.
+	defaultClusterDomain = "cluster.local"
+	// defaultK8sNdots is the ndots option configured by kubelet in the pods.
+	defaultK8sNdots = 5
+	// defaultK8sService is the API server service, which exists in every cluster.
+	defaultK8sService = "kubernetes.default"
+)
+
+// K8sPreset configures the benchmark to generate the query pattern of pods resolving names through the cluster DNS like kube-dns or CoreDNS.
+// The names are looked up with the search domains of the pods, so the misses of the search domain expansion driven by ndots are sent as well,
+// the overhead of the expansion is reported in ResultStats.Search.
+type K8sPreset struct {
+	// ClusterDomain is the domain of the cluster, cluster.local by default.
+	ClusterDomain string
+	// Namespace is the namespace of the simulated pods, default by default.
+	Namespace string
+	// Ndots is the ndots option of the pods, 5 by default like kubelet configures it.
+	Ndots int
+	// Services are the resolved services in format service or service.namespace, services in the namespace of the pods are looked up
+	// by the short name, by the name with the namespace and by the full name of the service without trailing dot, services in other
+	// namespaces are looked up by the name with the namespace and by the full name. kubernetes.default is resolved when not set.
+	Services []string
+	// External are names outside of the cluster resolved by the pods, for example example.com.
+	External []string
+}
+
+// Apply sets the search domains, ndots and queries of the benchmark according to the preset, the search domain expansion is enabled.
+func (p K8sPreset) Apply(b *Benchmark) error {
+	domain := strings.Trim(p.ClusterDomain, ".")
+	if domain == "" {
+		domain = defaultClusterDomain
+	}
+	namespace := p.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	ndots := p.Ndots
+	if ndots == 0 {
+		ndots = defaultK8sNdots
+	}
+	if ndots < 0 {
+		return errors.New("ndots cannot be negative")
+	}
+	if _, ok := dns.IsDomainName(domain); !ok {
+		return fmt.Errorf("invalid cluster domain '%s'", p.ClusterDomain)
+	}
+	if _, ok := dns.IsDomainName(namespace); !ok || strings.Contains(namespace, ".") {
+		return fmt.Errorf("invalid namespace '%s'", p.Namespace)
+	}
+
+	services := p.Services
+	if len(services) == 0 {
+		services = []string{defaultK8sService}
+	}
+	var queries []string
+	for _, s := range services {
+		name, ns, _ := strings.Cut(strings.Trim(s, "."), ".")
+		if ns == "" {
+			ns = namespace
+		}
+		if _, ok := dns.IsDomainName(name + "." + ns); !ok || name == "" || strings.Contains(ns, ".") {
+			return fmt.Errorf("invalid service '%s', expected format service or service.namespace", s)
+		}
+		if ns == namespace {
+			queries = append(queries, name)
+		}
+		queries = append(queries, name+"."+ns, name+"."+ns+".svc."+domain)
+	}
+	for _, e := range p.External {
+		if _, ok := dns.IsDomainName(e); !ok {
+			return fmt.Errorf("invalid external name '%s'", e)
+		}
+		queries = append(queries, e)
+	}
+
+	b.SearchDomains = []string{namespace + ".svc." + domain, "svc." + domain, domain}
+	b.Ndots = ndots
+	b.SearchExpansion = true
+	b.Queries = queries
+	return nil
+}
@@ -0,0 +1,39 @@
+package dnsbench
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestK8sPreset_Apply(t *testing.T) {
+	var b Benchmark
+	require.NoError(t, K8sPreset{Namespace: "shop", Services: []string{"cart", "db.storage"}, External: []string{"example.com"}}.Apply(&b))
+	assert.Equal(t, []string{"shop.svc.cluster.local", "svc.cluster.local", "cluster.local"}, b.SearchDomains)
+	assert.Equal(t, 5, b.Ndots)
+	assert.True(t, b.SearchExpansion)
+	assert.Equal(t, []string{
+		"cart", "cart.shop", "cart.shop.svc.cluster.local",
+		"db.storage", "db.storage.svc.cluster.local",
+		"example.com",
+	}, b.Queries)
+
+	b = Benchmark{}
+	require.NoError(t, K8sPreset{ClusterDomain: "k8s.internal.", Ndots: 2}.Apply(&b))
+	assert.Equal(t, []string{"default.svc.k8s.internal", "svc.k8s.internal", "k8s.internal"}, b.SearchDomains)
+	assert.Equal(t, 2, b.Ndots)
+	assert.Equal(t, []string{"kubernetes", "kubernetes.default", "kubernetes.default.svc.k8s.internal"}, b.Queries)
+}
+
+func TestK8sPreset_Apply_invalid(t *testing.T) {
+	for _, p := range []K8sPreset{
+		{Namespace: "a.b"},
+		{Ndots: -1},
+		{Services: []string{"a.b.c"}},
+		{Services: []string{""}},
+		{External: []string{"exa mple..com"}},
+	} {
+		assert.Error(t, p.Apply(&Benchmark{}), "%+v", p)
+	}
+}
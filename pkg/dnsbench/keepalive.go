@@ -0,0 +1,82 @@
+package dnsbench
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// connKeepalive tracks idle time of persistent connection, the connection is closed by the client once it is idle longer than Benchmark.IdleTimeout
+// or than the idle timeout advertised by the server using edns-tcp-keepalive option (RFC 7828), whichever is lower.
+type connKeepalive struct {
+	lastUsed time.Time
+	// advertised is the idle timeout advertised by the server, it is valid only when signalled is set, zero timeout asks the client to close the connection.
+	advertised time.Duration
+	signalled  bool
+}
+
+// used records the use of the connection and the idle timeout advertised by the response, if any.
+func (k *connKeepalive) used(resp *dns.Msg) {
+	k.lastUsed = time.Now()
+	if d, ok := advertisedKeepalive(resp); ok {
+		k.advertised, k.signalled = d, true
+	}
+}
+
+// expired decides whether the connection should be closed before it is used again.
+func (k *connKeepalive) expired(idleTimeout time.Duration, now time.Time) bool {
+	timeout := idleTimeout
+	if k.signalled && (timeout == 0 || k.advertised < timeout) {
+		if k.advertised == 0 {
+			return true
+		}
+		timeout = k.advertised
+	}
+	return timeout > 0 && now.Sub(k.lastUsed) >= timeout
+}
+
+// advertisedKeepalive returns the idle timeout of edns-tcp-keepalive option of the response.
+func advertisedKeepalive(resp *dns.Msg) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	o := resp.IsEdns0()
+	if o == nil {
+		return 0, false
+	}
+	for _, opt := range o.Option {
+		if k, ok := opt.(*dns.EDNS0_TCP_KEEPALIVE); ok {
+			// the timeout is in units of 100 milliseconds
+			return time.Duration(k.Timeout) * 100 * time.Millisecond, true
+		}
+	}
+	return 0, false
+}
+
+// addTCPKeepalive adds edns-tcp-keepalive option without timeout to the query, so that the server advertises its idle timeout.
+func addTCPKeepalive(m *dns.Msg) {
+	o := m.IsEdns0()
+	if o == nil {
+		m.SetEdns0(4096, false)
+		o = m.IsEdns0()
+	}
+	o.Option = append(o.Option, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE})
+}
+
+// recordKeepalive counts the response by the idle timeout advertised by the server.
+func (rs *ResultStats) recordKeepalive(resp *dns.Msg) {
+	if rs.KeepaliveTimeouts == nil {
+		return
+	}
+	if d, ok := advertisedKeepalive(resp); ok {
+		rs.KeepaliveTimeouts[d.String()]++
+	}
+}
+
+// closedByServer checks whether the query failed, because the server closed the reused connection.
+func closedByServer(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
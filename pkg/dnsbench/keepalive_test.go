@@ -0,0 +1,132 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_connKeepalive_expired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		keepalive   connKeepalive
+		idleTimeout time.Duration
+		want        bool
+	}{
+		{name: "no timeout", keepalive: connKeepalive{lastUsed: now.Add(-time.Hour)}},
+		{name: "idle timeout", keepalive: connKeepalive{lastUsed: now.Add(-2 * time.Second)}, idleTimeout: time.Second, want: true},
+		{name: "not idle", keepalive: connKeepalive{lastUsed: now.Add(-500 * time.Millisecond)}, idleTimeout: time.Second},
+		{name: "advertised", keepalive: connKeepalive{lastUsed: now.Add(-500 * time.Millisecond), advertised: 200 * time.Millisecond, signalled: true}, idleTimeout: time.Second, want: true},
+		{name: "advertised higher", keepalive: connKeepalive{lastUsed: now.Add(-2 * time.Second), advertised: time.Minute, signalled: true}, idleTimeout: time.Second, want: true},
+		{name: "advertised zero", keepalive: connKeepalive{lastUsed: now, signalled: true}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.keepalive.expired(tt.idleTimeout, now))
+		})
+	}
+}
+
+func Test_advertisedKeepalive(t *testing.T) {
+	m := new(dns.Msg)
+	_, ok := advertisedKeepalive(m)
+	assert.False(t, ok)
+
+	addTCPKeepalive(m)
+	require.NotNil(t, m.IsEdns0())
+	_, ok = advertisedKeepalive(m)
+	assert.True(t, ok, "the option is added to the query")
+	assert.False(t, m.IsEdns0().Do(), "DNSSEC is not requested")
+
+	m.IsEdns0().Option[0].(*dns.EDNS0_TCP_KEEPALIVE).Timeout = 25
+	d, ok := advertisedKeepalive(m)
+	assert.True(t, ok)
+	assert.Equal(t, 2500*time.Millisecond, d)
+}
+
+func TestBenchmark_Run_ednsTCPKeepalive(t *testing.T) {
+	s := NewServer(tcp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if _, ok := advertisedKeepalive(r); ok {
+			ret.SetEdns0(4096, false)
+			ret.IsEdns0().Option = append(ret.IsEdns0().Option, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE, Timeout: 1})
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, true, 1)
+	bench.EDNSTCPKeepalive = true
+	bench.RateLimitWorker = 5
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, map[string]int64{"100ms": 4}, merged.KeepaliveTimeouts)
+	assert.Equal(t, int64(2), merged.Counters.IdleCloses, "connections idle for longer than advertised are closed")
+	assert.Equal(t, int64(4), merged.Connections.Count)
+}
+
+// closingServer answers a single query of each TCP connection and closes the connection afterwards.
+func closingServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length uint16
+				if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+					return
+				}
+				buf := make([]byte, length)
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					return
+				}
+				m := new(dns.Msg)
+				if err := m.Unpack(buf); err != nil {
+					return
+				}
+				resp := new(dns.Msg)
+				resp.SetReply(m)
+				pack, _ := resp.Pack()
+				binary.Write(conn, binary.BigEndian, uint16(len(pack)))
+				conn.Write(pack)
+			}()
+		}
+	}()
+	return l
+}
+
+func TestBenchmark_Run_serverCloses(t *testing.T) {
+	l := closingServer(t)
+	defer l.Close()
+
+	bench := createBenchmark(l.Addr().String(), true, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.Success)
+	assert.Zero(t, merged.Counters.IOError, "connections closed by the server are not errors")
+	assert.Equal(t, int64(2), merged.Counters.ServerCloses)
+}
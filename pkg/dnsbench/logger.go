@@ -0,0 +1,141 @@
+package dnsbench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+)
+
+var logLevelNames = map[logLevel]string{levelDebug: "debug", levelInfo: "info", levelWarn: "warn"}
+
+func parseLogLevel(s string) (logLevel, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return levelDebug, nil
+	case "info":
+		return levelInfo, nil
+	case "warn", "":
+		return levelWarn, nil
+	}
+	return 0, fmt.Errorf("invalid log level '%s', expected one of debug, info or warn", s)
+}
+
+// logger writes structured logs in logfmt format, the message is followed by key=value pairs describing the event,
+// for example the worker emitting the log. The logger is safe for concurrent use by benchmark workers, nil logger discards all the logs.
+type logger struct {
+	out   *logOutput
+	level logLevel
+	// kv are the key=value pairs added to all the logs of the logger
+	kv []any
+}
+
+// logOutput is shared by the logger and all the loggers derived from it.
+type logOutput struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func newLogger(w io.Writer, level logLevel) *logger {
+	return &logger{out: &logOutput{w: w}, level: level}
+}
+
+// with returns logger adding the key=value pairs to all the logs.
+func (l *logger) with(kv ...any) *logger {
+	if l == nil {
+		return nil
+	}
+	return &logger{out: l.out, level: l.level, kv: append(append([]any(nil), l.kv...), kv...)}
+}
+
+// enabled is used to avoid preparing the key=value pairs of logs, which would be discarded anyway.
+func (l *logger) enabled(level logLevel) bool {
+	return l != nil && level >= l.level
+}
+
+func (l *logger) debug(msg string, kv ...any) {
+	l.log(levelDebug, msg, kv)
+}
+
+func (l *logger) info(msg string, kv ...any) {
+	l.log(levelInfo, msg, kv)
+}
+
+func (l *logger) warn(msg string, kv ...any) {
+	l.log(levelWarn, msg, kv)
+}
+
+func (l *logger) log(level logLevel, msg string, kv []any) {
+	if !l.enabled(level) {
+		return
+	}
+	now := time.Now()
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	buf := &l.out.buf
+	buf.Reset()
+	buf.WriteString("time=")
+	buf.WriteString(now.Format(time.RFC3339Nano))
+	buf.WriteString(" level=")
+	buf.WriteString(logLevelNames[level])
+	buf.WriteString(" msg=")
+	buf.WriteString(logValue(msg))
+	writeLogPairs(buf, l.kv)
+	writeLogPairs(buf, kv)
+	buf.WriteByte('\n')
+	l.out.w.Write(buf.Bytes())
+}
+
+func writeLogPairs(buf *bytes.Buffer, kv []any) {
+	for i := 0; i < len(kv); i += 2 {
+		buf.WriteByte(' ')
+		buf.WriteString(fmt.Sprint(kv[i]))
+		buf.WriteByte('=')
+		if i+1 < len(kv) {
+			buf.WriteString(logValue(kv[i+1]))
+		} else {
+			buf.WriteString(`""`)
+		}
+	}
+}
+
+// logValue formats the value, values containing spaces, quotes or equal signs are quoted.
+func logValue(v any) string {
+	var s string
+	switch val := v.(type) {
+	case string:
+		s = val
+	case error:
+		s = val.Error()
+	default:
+		s = fmt.Sprint(val)
+	}
+	if s == "" || strings.ContainsAny(s, " \"=\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// questionString formats the question of the message for the logs, for example "example.org. A".
+func questionString(m *dns.Msg) string {
+	if len(m.Question) == 0 {
+		return ""
+	}
+	q := m.Question[0]
+	return q.Name + " " + dns.TypeToString[q.Qtype]
+}
@@ -0,0 +1,100 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseLogLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		want    logLevel
+		wantErr bool
+	}{
+		{level: "debug", want: levelDebug},
+		{level: "INFO", want: levelInfo},
+		{level: "warn", want: levelWarn},
+		{level: "", want: levelWarn},
+		{level: "trace", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			got, err := parseLogLevel(tt.level)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_logger(t *testing.T) {
+	var out bytes.Buffer
+	l := newLogger(&out, levelInfo).with("worker", 3)
+
+	l.debug("discarded")
+	l.info("query failed", "question", "example.org. A", "timeout", true, "err", errors.New("i/o timeout"), "empty", "")
+	l.warn("odd", "key")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `^time=\S+ level=info msg="query failed" worker=3 question="example.org. A" timeout=true err="i/o timeout" empty=""$`, lines[0])
+	assert.Regexp(t, `^time=\S+ level=warn msg=odd worker=3 key=""$`, lines[1])
+
+	// nil logger discards all the logs
+	var nilLogger *logger
+	assert.False(t, nilLogger.enabled(levelWarn))
+	nilLogger.with("worker", 1).warn("discarded")
+}
+
+func TestBenchmark_Run_logs(t *testing.T) {
+	s := NewServer(tcp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeAAAA {
+			// AAAA queries time out
+			return
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	var out bytes.Buffer
+	bench := createBenchmark(s.Addr, true, 1)
+	bench.Concurrency = 1
+	bench.RequestTimeout = 200 * time.Millisecond
+	bench.ReadTimeout = 200 * time.Millisecond
+	bench.Retries = 1
+	bench.RetryBackoff = time.Millisecond
+	bench.LogLevel = "debug"
+	bench.LogOutput = &out
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	logs := out.String()
+	assert.Contains(t, logs, `level=info msg="benchmark started" server=`+s.Addr+` concurrency=1 questions=2`)
+	assert.Contains(t, logs, `level=debug msg="worker started" worker=0`)
+	assert.Regexp(t, `level=debug msg="connection established" worker=0 remote=`+s.Addr+` family=IPv4 setup=\S+`, logs)
+	assert.Contains(t, logs, `level=debug msg="retrying query" worker=0 question="example.org. AAAA" attempt=1 backoff=1ms`)
+	assert.Regexp(t, `level=debug msg="query failed" worker=0 question="example.org. AAAA" attempts=2 category="(read|request) timeout"`, logs)
+	assert.Contains(t, logs, `level=debug msg="worker finished" worker=0 queries=2 errors=1`)
+	assert.Regexp(t, `level=info msg="benchmark finished" duration=\S+ interrupted=false`, logs)
+}
+
+func TestBenchmark_normalize_logLevel(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", LogLevel: "trace"}
+	assert.Error(t, b.normalize())
+}
@@ -0,0 +1,170 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/olekukonko/tablewriter"
+)
+
+// mutation strategies of malformed queries, see Benchmark.MalformedRate.
+const (
+	// malformedBadLength declares the length of the first label longer than the rest of the packet.
+	malformedBadLength = "bad-length"
+	// malformedInvalidLabel sets the reserved label type of the first label.
+	malformedInvalidLabel = "invalid-label"
+	// malformedReservedOpcode sets the reserved opcode 3 in the header.
+	malformedReservedOpcode = "reserved-opcode"
+	// malformedOversizedOPT adds EDNS option to OPT record, whose length exceeds the length of the record.
+	malformedOversizedOPT = "oversized-opt"
+)
+
+var malformedStrategies = []string{malformedBadLength, malformedInvalidLabel, malformedReservedOpcode, malformedOversizedOPT}
+
+// outcomes of malformed queries other than response codes.
+const (
+	malformedNoResponse      = "no response"
+	malformedClosed          = "closed"
+	malformedInvalidResponse = "invalid response"
+	malformedError           = "error"
+)
+
+// malformedEDNSOption is a code of the local EDNS option added by malformedOversizedOPT.
+const malformedEDNSOption = 65001
+
+// malformQuery returns the wire format of the query mutated according to the strategy.
+func malformQuery(m *dns.Msg, strategy string) ([]byte, error) {
+	if strategy == malformedOversizedOPT {
+		m = m.Copy()
+		o := m.IsEdns0()
+		if o == nil {
+			m.SetEdns0(dns.DefaultMsgSize, false)
+			o = m.IsEdns0()
+		}
+		// the OPT record has to be the last record, so that the length of the option is at a known offset
+		m.Extra = append(removeOPT(m.Extra), o)
+		o.Option = append(o.Option, &dns.EDNS0_LOCAL{Code: malformedEDNSOption, Data: []byte{0, 0, 0, 0}})
+	}
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	switch strategy {
+	case malformedBadLength:
+		buf[12] = 63
+		buf = buf[:14]
+	case malformedInvalidLabel:
+		buf[12] |= 0x80
+	case malformedReservedOpcode:
+		buf[2] = buf[2]&^0x78 | 3<<3
+	case malformedOversizedOPT:
+		buf[len(buf)-6], buf[len(buf)-5] = 0xff, 0xff
+	}
+	return buf, nil
+}
+
+func removeOPT(extra []dns.RR) []dns.RR {
+	res := make([]dns.RR, 0, len(extra))
+	for _, rr := range extra {
+		if _, ok := rr.(*dns.OPT); !ok {
+			res = append(res, rr)
+		}
+	}
+	return res
+}
+
+// sendMalformed sends the query mutated by randomly chosen strategy over a new connection to the server and records the outcome,
+// that is the response code of the response, or whether the server did not respond or closed the connection.
+func (b *Benchmark) sendMalformed(ctx context.Context, m *dns.Msg, rando *rand.Rand, st *ResultStats) {
+	strategy := malformedStrategies[rando.Intn(len(malformedStrategies))]
+	st.Counters.MalformedQueries++
+	record := func(outcome string) {
+		if st.Malformed[strategy] == nil {
+			st.Malformed[strategy] = make(map[string]int64)
+		}
+		st.Malformed[strategy][outcome]++
+	}
+
+	buf, err := malformQuery(m, strategy)
+	if err != nil {
+		record(malformedError)
+		return
+	}
+	client := b.getDNSClient()
+	client.Dialer = b.dialer(client.Net)
+	co, err := client.Dial(b.Server)
+	if err != nil {
+		record(malformedError)
+		return
+	}
+	defer co.Close()
+	deadline := time.Now().Add(b.RequestTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := co.SetDeadline(deadline); err != nil {
+		record(malformedError)
+		return
+	}
+	if _, err := co.Write(buf); err != nil {
+		record(malformedError)
+		return
+	}
+	resp := make([]byte, dns.MaxMsgSize)
+	n, err := co.Read(resp)
+	switch {
+	case err != nil && isTimeout(err):
+		record(malformedNoResponse)
+	case err != nil && closedByServer(err):
+		record(malformedClosed)
+	case err != nil:
+		record(malformedError)
+	case n < 4 || resp[2]&0x80 == 0:
+		record(malformedInvalidResponse)
+	default:
+		rcode := int(resp[3] & 0x0f)
+		name, ok := dns.RcodeToString[rcode]
+		if !ok {
+			name = strconv.Itoa(rcode)
+		}
+		record(name)
+	}
+}
+
+func printMalformed(w io.Writer, malformed map[string]map[string]int64) {
+	if len(malformed) == 0 {
+		return
+	}
+	strategies := make([]string, 0, len(malformed))
+	for k := range malformed {
+		strategies = append(strategies, k)
+	}
+	sort.Strings(strategies)
+
+	lines := make([][]string, 0, len(strategies))
+	for _, s := range strategies {
+		outcomes := make([]string, 0, len(malformed[s]))
+		var sent int64
+		for o, c := range malformed[s] {
+			outcomes = append(outcomes, fmt.Sprintf("%s: %d", o, c))
+			sent += c
+		}
+		sort.Strings(outcomes)
+		lines = append(lines, []string{s, strconv.FormatInt(sent, 10), strings.Join(outcomes, ", ")})
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Outcomes of malformed queries:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Strategy", "Sent", "Outcomes"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+}
@@ -0,0 +1,119 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_malformQuery(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	for _, strategy := range malformedStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			buf, err := malformQuery(m, strategy)
+			require.NoError(t, err)
+
+			res := new(dns.Msg)
+			err = res.Unpack(buf)
+			if strategy == malformedReservedOpcode {
+				require.NoError(t, err)
+				assert.Equal(t, 3, res.Opcode)
+				return
+			}
+			assert.Error(t, err, "malformed query is not unpacked")
+			assert.Equal(t, m.Id, res.Id, "header of the query is preserved")
+		})
+	}
+	assert.Nil(t, m.IsEdns0(), "the original query is not modified")
+}
+
+func TestBenchmark_Run_malformed(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.MalformedRate = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.MalformedQueries)
+	assert.Zero(t, merged.Counters.Total, "malformed queries are sent instead of the regular queries")
+
+	var outcomes int64
+	for strategy, v := range merged.Malformed {
+		assert.Contains(t, malformedStrategies, strategy)
+		for outcome, c := range v {
+			assert.NotEqual(t, malformedError, outcome)
+			outcomes += c
+		}
+	}
+	assert.Equal(t, int64(4), outcomes)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, bench.PrintReport(buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "Outcomes of malformed queries:")
+}
+
+func TestBenchmark_normalize_malformed(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", MalformedRate: 1.5}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", MalformedRate: 0.1, DOT: true}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "https://1.1.1.1/dns-query", MalformedRate: 0.1}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", MalformedRate: 0.1, TCP: true}
+	assert.NoError(t, b.normalize())
+}
+
+func TestBenchmark_Run_malformed_total(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.Total = 20
+	bench.MalformedRate = 0.5
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Positive(t, merged.Counters.MalformedQueries)
+	assert.Equal(t, int64(20), merged.Counters.Total+merged.Counters.MalformedQueries, "malformed queries are sent, so they are counted in --total")
+}
+
+func TestBenchmark_Run_malformed_only_total(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Count = 0
+	bench.Total = 10
+	bench.MalformedRate = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.NoError(t, ctx.Err(), "benchmark ends when --total malformed queries are sent")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(10), merged.Counters.MalformedQueries)
+	assert.Zero(t, merged.Counters.Total)
+}
@@ -0,0 +1,54 @@
+package dnsbench
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// openLoopSchedule schedules send times of queries up front from the target rate, independently of the responses of the server,
+// so that slow responses do not postpone the following queries (coordinated omission). Unlike the rate limiters, the schedule
+// never skips arrivals when the workers fall behind, the late queries are sent as soon as possible instead and their latency
+// includes the time spent waiting for a free worker. The schedule is safe for concurrent use.
+type openLoopSchedule struct {
+	mu           sync.Mutex
+	rando        *rand.Rand
+	interval     time.Duration
+	distribution string
+	next         time.Time
+}
+
+func (b *Benchmark) newOpenLoopSchedule(start time.Time, seed int64) *openLoopSchedule {
+	return &openLoopSchedule{
+		// nolint:gosec
+		rando:        rand.New(rand.NewSource(seed)),
+		interval:     time.Second / time.Duration(b.Rate),
+		distribution: b.RateDistribution,
+		next:         start,
+	}
+}
+
+// take returns intended send time of the next query.
+func (s *openLoopSchedule) take() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = s.next.Add(nextGap(s.rando, s.interval, s.distribution))
+	return s.next
+}
+
+// waitUntil blocks until the given time or until the context is done.
+func waitUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
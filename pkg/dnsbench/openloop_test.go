@@ -0,0 +1,46 @@
+package dnsbench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_openLoopSchedule_take(t *testing.T) {
+	start := time.Now()
+	b := Benchmark{Rate: 100}
+	s := b.newOpenLoopSchedule(start, start.UnixNano())
+
+	for i := 1; i <= 5; i++ {
+		assert.Equal(t, start.Add(time.Duration(i)*10*time.Millisecond), s.take())
+	}
+}
+
+func Test_openLoopSchedule_take_poisson(t *testing.T) {
+	start := time.Now()
+	b := Benchmark{Rate: 1000, RateDistribution: poissonDistribution}
+	s := b.newOpenLoopSchedule(start, start.UnixNano())
+
+	var last time.Time
+	for i := 0; i < 1000; i++ {
+		next := s.take()
+		assert.False(t, next.Before(last), "intended send times should not decrease")
+		last = next
+	}
+	// 1000 arrivals at 1000 QPS span one second on average
+	assert.InDelta(t, time.Second, last.Sub(start), float64(300*time.Millisecond))
+}
+
+func Test_waitUntil(t *testing.T) {
+	start := time.Now()
+	assert.NoError(t, waitUntil(context.Background(), start.Add(20*time.Millisecond)))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	assert.NoError(t, waitUntil(context.Background(), start), "time in the past should not block")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, waitUntil(ctx, time.Now().Add(time.Hour)))
+}
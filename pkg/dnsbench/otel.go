@@ -0,0 +1,358 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// defaultOtelSampleRate is the default fraction of the queries, whose spans are exported.
+	defaultOtelSampleRate = 0.01
+	// otelBatchSize is the number of spans, after which the spans are exported without waiting for the export interval.
+	otelBatchSize      = 512
+	otelExportInterval = 5 * time.Second
+	otelTimeout        = 10 * time.Second
+
+	otelSpanKindInternal = 1
+	otelSpanKindClient   = 3
+	otelStatusError      = 2
+)
+
+type queryTraceKey struct{}
+
+// queryTrace collects the timestamps of the phases of a single sampled query, it is carried by the context of the query.
+type queryTrace struct {
+	mu    sync.Mutex
+	wrote time.Time
+}
+
+func withQueryTrace(ctx context.Context, t *queryTrace) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, t)
+}
+
+func queryTraceFrom(ctx context.Context) *queryTrace {
+	t, _ := ctx.Value(queryTraceKey{}).(*queryTrace)
+	return t
+}
+
+// written records the time the query was written, when the query is retried, the last write is kept.
+func (t *queryTrace) written() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.wrote = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *queryTrace) writtenAt() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.wrote
+}
+
+// tracedConn records the writes of the queries to the trace of the query being sent over the connection.
+type tracedConn struct {
+	net.Conn
+	trace *queryTrace
+}
+
+func (c *tracedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.trace.written()
+	return n, err
+}
+
+// tracedUDPConn is tracedConn of UDP connections, which has to stay net.PacketConn, so that DNS messages are not prefixed by their length.
+type tracedUDPConn struct {
+	*net.UDPConn
+	trace *queryTrace
+}
+
+func (c *tracedUDPConn) Write(p []byte) (int, error) {
+	n, err := c.UDPConn.Write(p)
+	c.trace.written()
+	return n, err
+}
+
+// traceConn wraps the connection of the DNS client, so that the writes of the queries are traced, other packet connections are not traced.
+func traceConn(co *dns.Conn) {
+	switch c := co.Conn.(type) {
+	case *tracedConn, *tracedUDPConn:
+	case *net.UDPConn:
+		co.Conn = &tracedUDPConn{UDPConn: c}
+	case net.PacketConn:
+	default:
+		co.Conn = &tracedConn{Conn: c}
+	}
+}
+
+// setConnTrace sets the trace of the query, which is going to be sent over the connection.
+func setConnTrace(co *dns.Conn, t *queryTrace) {
+	switch c := co.Conn.(type) {
+	case *tracedConn:
+		c.trace = t
+	case *tracedUDPConn:
+		c.trace = t
+	}
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue,omitempty"`
+	// IntValue is int64 encoded as string, as required by OTLP JSON encoding
+	IntValue string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func stringAttr(k, v string) otlpAttribute {
+	return otlpAttribute{Key: k, Value: otlpValue{StringValue: v}}
+}
+
+func intAttr(k string, v int64) otlpAttribute {
+	return otlpAttribute{Key: k, Value: otlpValue{IntValue: strconv.FormatInt(v, 10)}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func randomID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// otelExporter exports spans of the sampled queries to OTLP collector using OTLP/HTTP with JSON encoding, the spans are exported in batches
+// in the background, so that the workers are not blocked by the export. The exporter is safe for concurrent use by benchmark workers.
+type otelExporter struct {
+	url        string
+	client     *http.Client
+	sampleRate float64
+	resource   otlpResource
+	log        *logger
+
+	mu    sync.Mutex
+	spans []otlpSpan
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// otelTracesURL returns the URL of the traces of the OTLP/HTTP endpoint, /v1/traces path is used when the endpoint has no path.
+func otelTracesURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid --otel-endpoint '%s', expected OTLP/HTTP endpoint like http://localhost:4318", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
+
+func (b *Benchmark) startOtelExporter() *otelExporter {
+	e := &otelExporter{
+		url:        b.otelURL,
+		client:     &http.Client{Timeout: otelTimeout},
+		sampleRate: b.OtelSampleRate,
+		resource: otlpResource{Attributes: []otlpAttribute{
+			stringAttr("service.name", "dnspyre"),
+			stringAttr("dnspyre.server", b.Server),
+		}},
+		log:   b.log,
+		flush: make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if e.sampleRate <= 0 {
+		e.sampleRate = defaultOtelSampleRate
+	}
+	go e.run()
+	return e
+}
+
+func (e *otelExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(otelExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flush:
+		case <-e.stop:
+			e.export()
+			return
+		}
+		e.export()
+	}
+}
+
+// close exports the remaining spans and stops the exporter.
+func (e *otelExporter) close() {
+	if e == nil {
+		return
+	}
+	close(e.stop)
+	<-e.done
+	e.client.CloseIdleConnections()
+}
+
+func (e *otelExporter) export() {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "dnspyre"}, Spans: spans}},
+	}}})
+	if err != nil {
+		e.log.warn("failed to export spans", "spans", len(spans), "err", err)
+		return
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		e.log.warn("failed to export spans", "spans", len(spans), "err", err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusBadRequest {
+		e.log.warn("failed to export spans", "spans", len(spans), "status", resp.StatusCode)
+	}
+}
+
+// sampled decides whether the spans of the query are exported, rnd is a random number from [0, 1).
+func (e *otelExporter) sampled(rnd float64) bool {
+	return e != nil && rnd < e.sampleRate
+}
+
+// record adds the spans of the sampled query, the root span covers the whole query including retries, it has child spans
+// of the connections established for the query, of sending the query and of receiving the response, which includes processing of the query by the server.
+// Queries over transports, whose writes are not traced, have only the spans of the connections.
+func (e *otelExporter) record(t *queryTrace, start, end time.Time, transport string, m, resp *dns.Msg, attempts int, conns []connection, err error) {
+	traceID, rootID := randomID(16), randomID(8)
+	q := m.Question[0]
+	root := otlpSpan{
+		TraceID: traceID, SpanID: rootID, Name: "dns.query", Kind: otelSpanKindClient,
+		StartTimeUnixNano: unixNano(start), EndTimeUnixNano: unixNano(end),
+		Attributes: []otlpAttribute{
+			stringAttr("dns.question.name", q.Name),
+			stringAttr("dns.question.type", dns.TypeToString[q.Qtype]),
+			stringAttr("network.transport", transport),
+			intAttr("dns.attempts", int64(attempts)),
+		},
+	}
+	if resp != nil {
+		root.Attributes = append(root.Attributes, stringAttr("dns.response.code", dns.RcodeToString[resp.Rcode]))
+	}
+	if err != nil {
+		root.Status = &otlpStatus{Code: otelStatusError, Message: err.Error()}
+		root.Attributes = append(root.Attributes, stringAttr("error.type", errorCategory(err)))
+	}
+	spans := []otlpSpan{root}
+
+	child := func(name string, from, to time.Time, attrs ...otlpAttribute) {
+		spans = append(spans, otlpSpan{
+			TraceID: traceID, SpanID: randomID(8), ParentSpanID: rootID, Name: name, Kind: otelSpanKindInternal,
+			StartTimeUnixNano: unixNano(from), EndTimeUnixNano: unixNano(to), Attributes: attrs,
+		})
+	}
+	sendStart := start
+	for _, c := range conns {
+		var attrs []otlpAttribute
+		if c.remote != nil {
+			attrs = append(attrs, stringAttr("network.peer.address", c.remote.String()))
+		}
+		if c.family != "" {
+			attrs = append(attrs, stringAttr("network.type", c.family))
+		}
+		if c.proxy > 0 {
+			attrs = append(attrs, intAttr("proxy.setup_ns", c.proxy.Nanoseconds()))
+		}
+		connEnd := c.start.Add(c.setup)
+		child("connect", c.start, connEnd, attrs...)
+		if connEnd.After(sendStart) {
+			sendStart = connEnd
+		}
+	}
+	if wrote := t.writtenAt(); !wrote.IsZero() {
+		if wrote.Before(sendStart) {
+			sendStart = wrote
+		}
+		child("send", sendStart, wrote)
+		child("receive", wrote, end)
+	}
+
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	full := len(e.spans) >= otelBatchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_otelTracesURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "http://localhost:4318", want: "http://localhost:4318/v1/traces"},
+		{endpoint: "https://otel.example.org/", want: "https://otel.example.org/v1/traces"},
+		{endpoint: "http://localhost:4318/custom/traces", want: "http://localhost:4318/custom/traces"},
+		{endpoint: "localhost:4318", wantErr: true},
+		{endpoint: "grpc://localhost:4317", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := otelTracesURL(tt.endpoint)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+type otlpCollector struct {
+	mu    sync.Mutex
+	spans []otlpSpan
+	res   []otlpAttribute
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var traces otlpTraces
+	if err := json.NewDecoder(r.Body).Decode(&traces); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range traces.ResourceSpans {
+		c.res = rs.Resource.Attributes
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+// traces returns the names of the spans of each trace, the root span first.
+func (c *otlpCollector) traces() map[string][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	traces := make(map[string][]string)
+	for _, s := range c.spans {
+		traces[s.TraceID] = append(traces[s.TraceID], s.Name)
+	}
+	for _, names := range traces {
+		sort.Slice(names, func(i, j int) bool {
+			return names[i] == "dns.query" || (names[j] != "dns.query" && names[i] < names[j])
+		})
+	}
+	return traces
+}
+
+func TestBenchmark_Run_otel(t *testing.T) {
+	tests := []struct {
+		name string
+		tcp  bool
+	}{
+		{name: "udp"},
+		{name: "tcp", tcp: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &otlpCollector{}
+			ts := httptest.NewServer(collector)
+			defer ts.Close()
+
+			network := udp
+			if tt.tcp {
+				network = tcp
+			}
+			s := NewServer(network, replyHandler)
+			defer s.Close()
+
+			bench := createBenchmark(s.Addr, tt.tcp, 1)
+			bench.OtelEndpoint = ts.URL
+			bench.OtelSampleRate = 1
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			_, err := bench.Run(ctx)
+			require.NoError(t, err, "expected no error from benchmark run")
+
+			traces := collector.traces()
+			require.Len(t, traces, 4, "all the queries are sampled")
+			var connected int
+			for _, names := range traces {
+				if len(names) == 4 {
+					assert.Equal(t, []string{"dns.query", "connect", "receive", "send"}, names)
+					connected++
+				} else {
+					assert.Equal(t, []string{"dns.query", "receive", "send"}, names)
+				}
+			}
+			assert.Equal(t, 2, connected, "only the first query of each worker establishes connection")
+			assert.Contains(t, collector.res, stringAttr("service.name", "dnspyre"))
+		})
+	}
+}
+
+func TestBenchmark_Run_otel_failure(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	var logs strings.Builder
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.OtelEndpoint = "http://127.0.0.1:1"
+	bench.OtelSampleRate = 1
+	bench.LogOutput = &logs
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "failed exports do not fail the benchmark")
+	assert.Equal(t, int64(4), Merge(rs).Counters.Total)
+	assert.Contains(t, logs.String(), `level=warn msg="failed to export spans" spans=14`)
+}
+
+func TestBenchmark_Run_otel_sampleRate(t *testing.T) {
+	bench := createBenchmark("127.0.0.1:53", false, 1)
+	bench.OtelEndpoint = "http://localhost:4318"
+	bench.OtelSampleRate = 1.5
+
+	_, err := bench.Run(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/miekg/dns"
+	"github.com/olekukonko/tablewriter"
+)
+
+// zeroPadding is the content of the padding options, the padding is made of zero bytes as recommended by RFC 7830.
+var zeroPadding = make([]byte, maxMessageSize)
+
+// padMsg attaches EDNS0 Padding option (RFC 7830) to the query, so that the size of the query in wire format is a multiple of the block size,
+// see https://datatracker.ietf.org/doc/html/rfc8467 for the recommended padding policies.
+func padMsg(m *dns.Msg, block int) {
+	o := m.IsEdns0()
+	if o == nil {
+		m.SetEdns0(4096, false)
+		o = m.IsEdns0()
+	}
+	pad := &dns.EDNS0_PADDING{}
+	o.Option = append(o.Option, pad)
+	if r := m.Len() % block; r != 0 {
+		pad.Padding = zeroPadding[:block-r]
+	}
+}
+
+// paddingSize returns the size of the padding option of the message in wire format, including the code and the length of the option.
+func paddingSize(m *dns.Msg) int64 {
+	o := m.IsEdns0()
+	if o == nil {
+		return 0
+	}
+	for _, opt := range o.Option {
+		if pad, ok := opt.(*dns.EDNS0_PADDING); ok {
+			return int64(4 + len(pad.Padding))
+		}
+	}
+	return 0
+}
+
+// printPadding prints the average sizes of the padded queries and responses by transport together with the sizes they would have without
+// the padding options, nothing is printed when none of the messages was padded.
+func printPadding(w io.Writer, sizes map[string]*SizeStats) {
+	keys := make([]string, 0, len(sizes))
+	for k, s := range sizes {
+		if s.QueryPadding > 0 || s.ResponsePadding > 0 {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "DNS message padding:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Transport", "Responses", "Query avg", "Unpadded query avg", "Response avg", "Unpadded response avg", "Overhead"})
+	table.SetBorder(false)
+	for _, k := range keys {
+		p := newPadStats(sizes[k])
+		table.Append([]string{
+			k,
+			strconv.FormatInt(p.Responses, 10),
+			fmt.Sprintf("%0.1f B", p.QueryAvgBytes),
+			fmt.Sprintf("%0.1f B", p.UnpaddedQueryAvgBytes),
+			fmt.Sprintf("%0.1f B", p.ResponseAvgBytes),
+			fmt.Sprintf("%0.1f B", p.UnpaddedResponseAvgBytes),
+			fmt.Sprintf("%0.2f%%", p.Overhead*100),
+		})
+	}
+	table.Render()
+}
+
+// jsonPadStats holds the average sizes of the padded and unpadded messages and the overhead, that is the ratio of the padding bytes
+// to the bytes of the messages without the padding.
+type jsonPadStats struct {
+	Responses                int64   `json:"responses"`
+	QueryAvgBytes            float64 `json:"queryAvgBytes"`
+	UnpaddedQueryAvgBytes    float64 `json:"unpaddedQueryAvgBytes"`
+	ResponseAvgBytes         float64 `json:"responseAvgBytes"`
+	UnpaddedResponseAvgBytes float64 `json:"unpaddedResponseAvgBytes"`
+	Overhead                 float64 `json:"overhead"`
+}
+
+func newPadStats(s *SizeStats) jsonPadStats {
+	p := jsonPadStats{Responses: s.Responses.TotalCount()}
+	if p.Responses == 0 {
+		return p
+	}
+	avg := func(bytes int64) float64 { return math.Round(float64(bytes)/float64(p.Responses)*10) / 10 }
+	p.QueryAvgBytes = avg(s.QueryBytes)
+	p.UnpaddedQueryAvgBytes = avg(s.QueryBytes - s.QueryPadding)
+	p.ResponseAvgBytes = avg(s.ResponseBytes)
+	p.UnpaddedResponseAvgBytes = avg(s.ResponseBytes - s.ResponsePadding)
+	if unpadded := s.QueryBytes + s.ResponseBytes - s.QueryPadding - s.ResponsePadding; unpadded > 0 {
+		p.Overhead = math.Round(float64(s.QueryPadding+s.ResponsePadding)/float64(unpadded)*10000) / 10000
+	}
+	return p
+}
+
+func jsonPadding(sizes map[string]*SizeStats) map[string]jsonPadStats {
+	var res map[string]jsonPadStats
+	for k, s := range sizes {
+		if s.QueryPadding == 0 && s.ResponsePadding == 0 {
+			continue
+		}
+		if res == nil {
+			res = make(map[string]jsonPadStats)
+		}
+		res[k] = newPadStats(s)
+	}
+	return res
+}
@@ -0,0 +1,128 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPadMsg(t *testing.T) {
+	tests := []struct {
+		name  string
+		block int
+		edns  bool
+	}{
+		{name: "recommended block", block: 468},
+		{name: "small block", block: 16},
+		{name: "existing EDNS", block: 128, edns: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+			if tt.edns {
+				m.SetEdns0(1232, true)
+			}
+
+			padMsg(m, tt.block)
+
+			packed, err := m.Pack()
+			require.NoError(t, err)
+			assert.Zero(t, len(packed)%tt.block, "size of the padded query is not a multiple of the block")
+			require.NotNil(t, m.IsEdns0())
+			if tt.edns {
+				assert.Equal(t, uint16(1232), m.IsEdns0().UDPSize())
+				assert.True(t, m.IsEdns0().Do())
+			}
+			assert.Positive(t, paddingSize(m))
+		})
+	}
+}
+
+func TestBenchmark_Run_padTo(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		sizes = append(sizes, r.Len())
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		// padding of the responses as recommended by RFC 8467
+		ret.SetEdns0(4096, false)
+		padMsg(ret, 468)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.PadTo = 128
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	mu.Lock()
+	sent := append([]int(nil), sizes...)
+	mu.Unlock()
+	require.Len(t, sent, 4)
+	for _, size := range sent {
+		assert.Equal(t, 128, size)
+	}
+	merged := Merge(rs)
+	udpSizes := merged.Sizes["udp"]
+	require.NotNil(t, udpSizes)
+	assert.Equal(t, int64(4*128), udpSizes.QueryBytes)
+	assert.Equal(t, int64(4*468), udpSizes.ResponseBytes)
+	assert.Positive(t, udpSizes.QueryPadding)
+	assert.Positive(t, udpSizes.ResponsePadding)
+
+	p := newPadStats(udpSizes)
+	assert.Equal(t, 128.0, p.QueryAvgBytes)
+	assert.Less(t, p.UnpaddedQueryAvgBytes, 128.0)
+	assert.Equal(t, 468.0, p.ResponseAvgBytes)
+	assert.Less(t, p.UnpaddedResponseAvgBytes, 468.0)
+	assert.Positive(t, p.Overhead)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "DNS message padding:")
+	assert.Regexp(t, `udp\s+\|\s+4 \| 128\.0 B`, buf.String())
+}
+
+func TestBenchmark_PrintReport_no_padding(t *testing.T) {
+	b, rs := testData()
+	rs.Sizes = map[string]*SizeStats{"udp": newSizeStats()}
+	rs.Sizes["udp"].Responses.RecordValue(100)
+	rs.Sizes["udp"].QueryBytes, rs.Sizes["udp"].ResponseBytes = 40, 100
+
+	buf := bytes.Buffer{}
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.NotContains(t, buf.String(), "DNS message padding:")
+	assert.Nil(t, jsonPadding(rs.Sizes))
+}
+
+func TestBenchmark_normalize_padTo(t *testing.T) {
+	tests := []struct {
+		name  string
+		bench Benchmark
+	}{
+		{name: "negative", bench: Benchmark{Server: "8.8.8.8", PadTo: -1}},
+		{name: "too large", bench: Benchmark{Server: "8.8.8.8", PadTo: maxMessageSize + 1}},
+		{name: "tsig", bench: Benchmark{Server: "8.8.8.8", PadTo: 468, TSIG: "key.:hmac-sha256:c2VjcmV0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.bench.normalize())
+		})
+	}
+}
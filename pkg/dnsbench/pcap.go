@@ -0,0 +1,126 @@
+package dnsbench
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/miekg/dns"
+)
+
+// pcapng files start with the section header block type.
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+type packetReader interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+}
+
+// capturedQuery is a question of DNS query captured in the pcap file or logged in dnstap.
+type capturedQuery struct {
+	question dns.Question
+	// opt is EDNS0 record of the captured query, nil if the query did not use EDNS0.
+	opt *dns.OPT
+	// offset is time of capturing the query relative to the first captured query.
+	offset time.Duration
+}
+
+// readPcapQueries parses DNS queries captured in the pcap (or pcapng) file and returns them in the order in which they were captured
+// together with their EDNS0 records and capture times. Non-DNS packets and DNS responses are skipped.
+func readPcapQueries(file string) ([]capturedQuery, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pcap file '%s' with error '%v'", file, err)
+	}
+	defer f.Close()
+
+	queries, err := parsePcap(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pcap file '%s' with error '%v'", file, err)
+	}
+	return queries, nil
+}
+
+func parsePcap(r io.Reader) ([]capturedQuery, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(pcapngMagic))
+	if err != nil {
+		return nil, err
+	}
+
+	var reader packetReader
+	if bytes.Equal(magic, pcapngMagic) {
+		reader, err = pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+	} else {
+		reader, err = pcapgo.NewReader(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var queries []capturedQuery
+	var first time.Time
+	source := gopacket.NewPacketSource(reader, reader.LinkType())
+	source.Lazy = true
+	source.NoCopy = true
+	for packet := range source.Packets() {
+		if msg := dnsQuery(packet); msg != nil {
+			ts := packet.Metadata().Timestamp
+			if first.IsZero() {
+				first = ts
+			}
+			queries = append(queries, newCapturedQueries(msg, ts.Sub(first))...)
+		}
+	}
+	return queries, nil
+}
+
+// newCapturedQueries returns captured queries for each question of the DNS query captured at the offset.
+func newCapturedQueries(msg *dns.Msg, offset time.Duration) []capturedQuery {
+	var opt *dns.OPT
+	if o := msg.IsEdns0(); o != nil {
+		opt = dns.Copy(o).(*dns.OPT)
+	}
+	queries := make([]capturedQuery, 0, len(msg.Question))
+	for _, q := range msg.Question {
+		queries = append(queries, capturedQuery{
+			question: dns.Question{Name: q.Name, Qtype: q.Qtype, Qclass: dns.ClassINET},
+			opt:      opt,
+			offset:   offset,
+		})
+	}
+	return queries
+}
+
+// dnsQuery returns DNS query carried by the packet, nil is returned if the packet does not contain DNS query.
+func dnsQuery(packet gopacket.Packet) *dns.Msg {
+	var payload []byte
+	if udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP); ok {
+		payload = udp.Payload
+	} else if tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP); ok {
+		// DNS messages sent over TCP are prefixed with two byte length field
+		if len(tcp.Payload) < 2 {
+			return nil
+		}
+		payload = tcp.Payload[2:]
+	} else {
+		return nil
+	}
+
+	msg := dns.Msg{}
+	if err := msg.Unpack(payload); err != nil || !isQuery(&msg) {
+		return nil
+	}
+	return &msg
+}
+
+// isQuery returns true if the message is a standard DNS query with a question.
+func isQuery(msg *dns.Msg) bool {
+	return !msg.Response && msg.Opcode == dns.OpcodeQuery && len(msg.Question) > 0
+}
@@ -0,0 +1,114 @@
+package dnsbench
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readPcapQueries(t *testing.T) {
+	file := writePcap(t,
+		udpPacket(t, 53, packQuery("example.org.", dns.TypeA)),
+		udpPacket(t, 53, packResponse("example.org.", dns.TypeA)),
+		udpPacket(t, 53, []byte("not a DNS message")),
+		udpPacket(t, 53, packEdnsQuery("example.com.", dns.TypeAAAA)),
+	)
+
+	queries, err := readPcapQueries(file)
+
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.Equal(t, dns.Question{Name: "example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}, queries[0].question)
+	assert.Nil(t, queries[0].opt)
+	assert.Zero(t, queries[0].offset)
+
+	assert.Equal(t, dns.Question{Name: "example.com.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}, queries[1].question)
+	require.NotNil(t, queries[1].opt)
+	assert.Equal(t, uint16(1232), queries[1].opt.UDPSize())
+	assert.True(t, queries[1].opt.Do())
+	require.Len(t, queries[1].opt.Option, 1)
+	assert.Equal(t, uint16(dns.EDNS0NSID), queries[1].opt.Option[0].Option())
+	assert.Equal(t, 3*pcapPacketInterval, queries[1].offset)
+}
+
+func Test_readPcapQueries_missing_file(t *testing.T) {
+	_, err := readPcapQueries(filepath.Join(t.TempDir(), "missing.pcap"))
+
+	assert.Error(t, err)
+}
+
+func packQuery(name string, qtype uint16) []byte {
+	m := dns.Msg{}
+	m.SetQuestion(name, qtype)
+	pack, _ := m.Pack()
+	return pack
+}
+
+func packEdnsQuery(name string, qtype uint16) []byte {
+	m := dns.Msg{}
+	m.SetQuestion(name, qtype)
+	m.SetEdns0(1232, true)
+	o := m.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	pack, _ := m.Pack()
+	return pack
+}
+
+func packResponse(name string, qtype uint16) []byte {
+	q := dns.Msg{}
+	q.SetQuestion(name, qtype)
+	m := dns.Msg{}
+	m.SetReply(&q)
+	pack, _ := m.Pack()
+	return pack
+}
+
+func udpPacket(t *testing.T, dstPort uint16, payload []byte) []byte {
+	eth := layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IPv4(127, 0, 0, 1),
+		DstIP:    net.IPv4(127, 0, 0, 2),
+	}
+	udp := layers.UDP{SrcPort: 12345, DstPort: layers.UDPPort(dstPort)}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(&ip))
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, &eth, &ip, &udp, gopacket.Payload(payload)))
+	return buf.Bytes()
+}
+
+// pcapPacketInterval is interval between the packets written by writePcap.
+const pcapPacketInterval = 10 * time.Millisecond
+
+func writePcap(t *testing.T, packets ...[]byte) string {
+	file := filepath.Join(t.TempDir(), "capture.pcap")
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w := pcapgo.NewWriter(f)
+	require.NoError(t, w.WriteFileHeader(65536, layers.LinkTypeEthernet))
+	start := time.Now()
+	for i, p := range packets {
+		ci := gopacket.CaptureInfo{Timestamp: start.Add(time.Duration(i) * pcapPacketInterval), CaptureLength: len(p), Length: len(p)}
+		require.NoError(t, w.WritePacket(ci, p))
+	}
+	return file
+}
@@ -0,0 +1,117 @@
+package dnsbench
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// defaultPercentiles are the latency percentiles reported when Benchmark.Percentiles is not set.
+var defaultPercentiles = []float64{99, 95, 90, 75, 50}
+
+// parsePercentiles parses comma separated latency percentiles, for example 50,90,99,99.9,99.99, the percentiles are returned
+// from the highest to the lowest like the default percentiles are reported.
+func parsePercentiles(s string) ([]float64, error) {
+	var percentiles []float64
+	seen := make(map[float64]bool)
+	for _, p := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || v <= 0 || v > 100 {
+			return nil, fmt.Errorf("invalid percentile '%s', expected number greater than 0 and at most 100", p)
+		}
+		if seen[v] {
+			return nil, fmt.Errorf("duplicate percentile '%s'", p)
+		}
+		seen[v] = true
+		percentiles = append(percentiles, v)
+	}
+	if len(percentiles) == 0 {
+		return nil, errors.New("--percentiles requires at least one percentile")
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(percentiles)))
+	return percentiles, nil
+}
+
+// reportedPercentiles returns the latency percentiles reported for the benchmark.
+func (b *Benchmark) reportedPercentiles() []float64 {
+	if b.percentiles != nil {
+		return b.percentiles
+	}
+	return defaultPercentiles
+}
+
+// percentileLabel returns the label of the percentile, for example p99.9.
+func percentileLabel(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// printPercentiles prints the latencies at the percentiles aligned with the other timings.
+func printPercentiles(w io.Writer, timings *hdrhistogram.Histogram, percentiles []float64) {
+	for _, p := range percentiles {
+		label := " " + percentileLabel(p) + ":"
+		tabs := "\t\t"
+		if len(label) >= 8 {
+			tabs = "\t"
+		}
+		fmt.Fprintln(w, "\t"+label+tabs, highlightStr(roundDuration(time.Duration(timings.ValueAtPercentile(p)))))
+	}
+}
+
+type jsonPercentile struct {
+	Percentile float64 `json:"percentile"`
+	LatencyMs  float64 `json:"latencyMs"`
+}
+
+// newJSONPercentiles returns the latencies at the percentiles for JSON report.
+func newJSONPercentiles(timings *hdrhistogram.Histogram, percentiles []float64) []jsonPercentile {
+	res := make([]jsonPercentile, 0, len(percentiles))
+	for _, p := range percentiles {
+		res = append(res, jsonPercentile{Percentile: p, LatencyMs: durationMs(time.Duration(timings.ValueAtPercentile(p)))})
+	}
+	return res
+}
+
+// distribution returns the distribution of the latencies of the histogram, when HistBuckets is set, the latencies are aggregated into
+// HistBuckets buckets of equal width between the lowest and the highest recorded latency.
+func (b *Benchmark) distribution(timings *hdrhistogram.Histogram) []hdrhistogram.Bar {
+	dist := timings.Distribution()
+	if b.HistBuckets <= 0 {
+		return dist
+	}
+	first, last := -1, -1
+	for i, d := range dist {
+		if d.Count == 0 {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+	if first < 0 {
+		return dist
+	}
+
+	lo, hi := dist[first].From, dist[last].To
+	n := int64(b.HistBuckets)
+	width := (hi - lo + n) / n
+	bars := make([]hdrhistogram.Bar, n)
+	for i := range bars {
+		bars[i].From = lo + int64(i)*width
+		bars[i].To = bars[i].From + width - 1
+	}
+	for _, d := range dist[first : last+1] {
+		i := (d.From/2 + d.To/2 - lo) / width
+		if i >= n {
+			i = n - 1
+		}
+		bars[i].Count += d.Count
+	}
+	return bars
+}
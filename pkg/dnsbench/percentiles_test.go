@@ -0,0 +1,71 @@
+package dnsbench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parsePercentiles(t *testing.T) {
+	percentiles, err := parsePercentiles("50, 90,99.99,99,99.9")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{99.99, 99.9, 99, 90, 50}, percentiles)
+
+	for _, s := range []string{"", "0", "100.1", "-5", "p99", "99,99"} {
+		_, err := parsePercentiles(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_printPercentiles(t *testing.T) {
+	h := hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Second.Nanoseconds(), 3)
+	for i := 1; i <= 10000; i++ {
+		require.NoError(t, h.RecordValue(int64(i)*time.Microsecond.Nanoseconds()))
+	}
+
+	var buf bytes.Buffer
+	printPercentiles(&buf, h, []float64{99.99, 50})
+	assert.Equal(t, "\t p99.99:\t 10ms\n\t p50:\t\t 5ms\n", buf.String())
+}
+
+func TestBenchmark_distribution(t *testing.T) {
+	h := hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Second.Nanoseconds(), 3)
+	for i := 1; i <= 100; i++ {
+		require.NoError(t, h.RecordValue(int64(i)*time.Millisecond.Nanoseconds()))
+	}
+
+	b := Benchmark{HistBuckets: 4}
+	bars := b.distribution(h)
+	require.Len(t, bars, 4)
+	var total int64
+	for i, bar := range bars {
+		assert.InDelta(t, 25, bar.Count, 1, "bucket %d", i)
+		total += bar.Count
+	}
+	assert.Equal(t, int64(100), total)
+	assert.LessOrEqual(t, bars[0].From, time.Millisecond.Nanoseconds())
+	assert.GreaterOrEqual(t, bars[3].To, 100*time.Millisecond.Nanoseconds())
+
+	b.HistBuckets = 0
+	assert.Equal(t, h.Distribution(), b.distribution(h))
+}
+
+func TestBenchmark_normalize_percentiles(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", Percentiles: "99.9,50"}
+	require.NoError(t, b.normalize())
+	assert.Equal(t, []float64{99.9, 50}, b.reportedPercentiles())
+
+	b = Benchmark{Server: "8.8.8.8"}
+	require.NoError(t, b.normalize())
+	assert.Equal(t, defaultPercentiles, b.reportedPercentiles())
+
+	b = Benchmark{Server: "8.8.8.8", Percentiles: "99,abc"}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", HistBuckets: -1}
+	assert.Error(t, b.normalize())
+}
@@ -0,0 +1,92 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Phase is a benchmark executed as a part of multi-phase scenario, each phase can use different rate, concurrency, queries and duration.
+type Phase struct {
+	Name      string
+	Benchmark *Benchmark
+}
+
+// PhaseResult represents benchmark results of a single phase of multi-phase scenario.
+type PhaseResult struct {
+	Name     string
+	Stats    []*ResultStats
+	Duration time.Duration
+}
+
+type jsonPhaseResult struct {
+	Phase string `json:"phase"`
+	jsonSummary
+}
+
+type jsonPhasesResult struct {
+	Phases  []jsonPhaseResult `json:"phases"`
+	Overall jsonSummary       `json:"overall"`
+}
+
+// RunPhases executes the benchmarks of the phases one after another.
+// If the benchmark is cancelled, the results of the phases executed so far are returned.
+func RunPhases(ctx context.Context, phases []Phase) ([]PhaseResult, error) {
+	var results []PhaseResult
+	for _, ph := range phases {
+		start := time.Now()
+		stats, err := ph.Benchmark.Run(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run phase '%s': %w", ph.Name, err)
+		}
+		results = append(results, PhaseResult{Name: ph.Name, Stats: stats, Duration: time.Since(start) - ph.Benchmark.warmupDuration})
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return results, nil
+}
+
+// PrintPhases prints summary table of the phases followed by the overall report of results of all the phases.
+// Files exported by the benchmark like CSV, plots or histograms are not written for the overall report.
+func (b *Benchmark) PrintPhases(w io.Writer, results []PhaseResult) error {
+	if b.Silent {
+		return nil
+	}
+
+	var all []*ResultStats
+	var total time.Duration
+	var jsonResults []jsonPhaseResult
+	lines := make([][]string, 0, len(results))
+	for _, r := range results {
+		summary, row := summarize(r.Stats, r.Duration)
+		jsonResults = append(jsonResults, jsonPhaseResult{Phase: r.Name, jsonSummary: summary})
+		lines = append(lines, append([]string{r.Name}, row...))
+		all = append(all, r.Stats...)
+		total += r.Duration
+	}
+
+	if b.JSON {
+		overall, _ := summarize(all, total)
+		return json.NewEncoder(w).Encode(jsonPhasesResult{Phases: jsonResults, Overall: overall})
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Summary of", highlightStr(len(results)), "phases:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Phase", "Requests", "QPS", "p50", "p95", "p99", "Errors", "Error rate"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Overall results:")
+	overall := *b
+	overall.PlotDir, overall.Csv, overall.HistExport, overall.HistLog, overall.SeriesCsv = "", "", "", "", ""
+	return overall.PrintReport(w, all, total)
+}
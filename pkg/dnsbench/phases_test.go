@@ -0,0 +1,104 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPhases(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	warmup := createBenchmark(s.Addr, false, 1)
+	steady := createBenchmark(s.Addr, false, 1)
+	steady.Concurrency = 4
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	results, err := RunPhases(ctx, []Phase{{Name: "warmup", Benchmark: &warmup}, {Name: "steady", Benchmark: &steady}})
+
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "warmup", results[0].Name)
+	assert.Equal(t, "steady", results[1].Name)
+	assert.Equal(t, int64(4), Merge(results[0].Stats).Counters.Total)
+	assert.Equal(t, int64(8), Merge(results[1].Stats).Counters.Total)
+}
+
+func TestRunPhases_cancelled(t *testing.T) {
+	first := createBenchmark("127.0.0.1:1", false, 1)
+	second := createBenchmark("127.0.0.1:1", false, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := RunPhases(ctx, []Phase{{Name: "1", Benchmark: &first}, {Name: "2", Benchmark: &second}})
+
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestBenchmark_PrintPhases(t *testing.T) {
+	b := Benchmark{HistMin: 0, HistMax: time.Second, HistPre: 1, Rcodes: true}
+	var buf bytes.Buffer
+
+	err := b.PrintPhases(&buf, phasesTestData())
+
+	require.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "Summary of 2 phases:")
+	assert.Regexp(t, `warmup \|\s+2 \| 2\.0 \| 10\.\d+ms \| 10\.\d+ms \| 10\.\d+ms \|\s+1 \| 50\.00%`, out)
+	assert.Regexp(t, `steady \|\s+1 \| 1\.0 \| 20\.\d+ms \| 20\.\d+ms \| 20\.\d+ms \|\s+0 \| 0\.00%`, out)
+	assert.Contains(t, out, "Overall results:")
+	assert.Contains(t, out, "Total requests:\t\t3")
+}
+
+func TestBenchmark_PrintPhases_json(t *testing.T) {
+	b := Benchmark{JSON: true}
+	var buf bytes.Buffer
+
+	err := b.PrintPhases(&buf, phasesTestData())
+
+	require.NoError(t, err)
+	var res jsonPhasesResult
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	require.Len(t, res.Phases, 2)
+	assert.Equal(t, "warmup", res.Phases[0].Phase)
+	assert.Equal(t, int64(2), res.Phases[0].TotalRequests)
+	assert.Equal(t, "steady", res.Phases[1].Phase)
+	assert.Equal(t, int64(3), res.Overall.TotalRequests)
+	assert.Equal(t, int64(1), res.Overall.TotalErrors)
+	assert.Equal(t, 1.5, res.Overall.QueriesPerSecond)
+}
+
+func phasesTestData() []PhaseResult {
+	h1 := hdrhistogram.New(0, int64(time.Second), 1)
+	h1.RecordValue(int64(10 * time.Millisecond))
+	h2 := hdrhistogram.New(0, int64(time.Second), 1)
+	h2.RecordValue(int64(20 * time.Millisecond))
+
+	return []PhaseResult{
+		{
+			Name:     "warmup",
+			Stats:    []*ResultStats{{Hist: h1, Counters: &Counters{Total: 2, IOError: 1, Success: 1}}},
+			Duration: time.Second,
+		},
+		{
+			Name:     "steady",
+			Stats:    []*ResultStats{{Hist: h2, Counters: &Counters{Total: 1, Success: 1}}},
+			Duration: time.Second,
+		},
+	}
+}
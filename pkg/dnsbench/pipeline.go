@@ -0,0 +1,225 @@
+package dnsbench
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// pipelinedClient keeps multiple queries in flight on a single TCP or DoT connection as described in RFC 7766,
+// the responses are matched to the queries by message ID, so they can arrive out of order. The client is safe for concurrent use.
+type pipelinedClient struct {
+	b      *Benchmark
+	server string
+	client *dns.Client
+
+	mu   sync.Mutex
+	conn *pipelinedConn
+}
+
+// pipelinedConn is a connection of pipelinedClient with the queries waiting for the responses.
+type pipelinedConn struct {
+	co *dns.Conn
+	// timeout is the longest time the connection waits for any response, before it fails together with the queries waiting for the responses.
+	timeout time.Duration
+
+	// wmu serializes the writes of the queries, so that the senders do not block the reading of the responses.
+	wmu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint16]chan *dns.Msg
+	// waiting is the time since which the connection waits for a response, zero when it is not waiting.
+	waiting time.Time
+	err     error
+}
+
+func (b *Benchmark) newPipelinedClient(server string) *pipelinedClient {
+	return &pipelinedClient{b: b, server: server, client: b.getDNSClient()}
+}
+
+func (p *pipelinedClient) query(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.send(msg, deadline(ctx, p.b.WriteTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(p.b.ReadTimeout)
+	defer timer.Stop()
+	select {
+	case r, ok := <-ch:
+		if !ok {
+			return nil, conn.failure()
+		}
+		return r, nil
+	case <-ctx.Done():
+		conn.forget(msg.Id, ch)
+		return nil, timeoutError(conn.co.LocalAddr(), conn.co.RemoteAddr())
+	case <-timer.C:
+		conn.forget(msg.Id, ch)
+		return nil, timeoutError(conn.co.LocalAddr(), conn.co.RemoteAddr())
+	}
+}
+
+// connection returns the current connection, new connection is dialed if there is none or the current one failed.
+func (p *pipelinedClient) connection(ctx context.Context) (*pipelinedConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil && p.conn.failure() == nil {
+		return p.conn, nil
+	}
+
+	var co *dns.Conn
+	var err error
+	if p.b.proxy != nil {
+		co, err = p.b.dialProxyDNS(ctx, p.server)
+	} else {
+		dialStart := time.Now()
+		p.client.Dialer = p.b.dialer(p.client.Net)
+		co, err = p.client.Dial(p.server)
+		if err == nil {
+			observeConnection(ctx, co.RemoteAddr(), time.Since(dialStart))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.conn = &pipelinedConn{co: co, timeout: p.b.RequestTimeout, pending: make(map[uint16]chan *dns.Msg)}
+	go p.conn.read()
+	return p.conn, nil
+}
+
+// timeoutError returns the same error as the one returned by the connection, when the read deadline is exceeded.
+func timeoutError(local, remote net.Addr) error {
+	return &net.OpError{Op: "read", Net: local.Network(), Source: local, Addr: remote, Err: os.ErrDeadlineExceeded}
+}
+
+func (p *pipelinedClient) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.co.Close()
+	}
+}
+
+// send writes the query to the connection and returns channel receiving the response, the channel is closed when the connection fails.
+// The message ID of the query is changed, if there is already a query in flight with the same ID.
+func (c *pipelinedConn) send(msg *dns.Msg, writeDeadline time.Time) (chan *dns.Msg, error) {
+	ch, err := c.register(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := packBuffers.Get().(*[]byte)
+	defer packBuffers.Put(buf)
+	packed, err := msg.PackBuffer(*buf)
+	if err != nil {
+		c.forget(msg.Id, ch)
+		return nil, err
+	}
+
+	c.wmu.Lock()
+	c.co.SetWriteDeadline(writeDeadline)
+	_, err = c.co.Write(packed)
+	c.wmu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		c.failLocked(err)
+		c.mu.Unlock()
+		return nil, err
+	}
+	return ch, nil
+}
+
+// register adds the query to the queries waiting for the responses, the connection starts waiting for a response, if it was not already.
+func (c *pipelinedConn) register(msg *dns.Msg) (chan *dns.Msg, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	for {
+		if _, ok := c.pending[msg.Id]; !ok {
+			break
+		}
+		msg.Id = dns.Id()
+	}
+	ch := make(chan *dns.Msg, 1)
+	c.pending[msg.Id] = ch
+	if c.waiting.IsZero() {
+		c.waitLocked(time.Now())
+	}
+	return ch, nil
+}
+
+// read reads the responses and passes them to the queries waiting for them, until the connection fails. The connection fails, when
+// no response arrives within the timeout while it is waiting for one, so that the server, which stopped answering, does not stall the queries forever
+// and the following queries dial a new connection.
+func (c *pipelinedConn) read() {
+	for {
+		r, err := c.co.ReadMsg()
+		if err != nil {
+			c.mu.Lock()
+			c.failLocked(err)
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Lock()
+		// responses to the queries, which already timed out, are dropped
+		if ch, ok := c.pending[r.Id]; ok {
+			delete(c.pending, r.Id)
+			ch <- r
+		}
+		if len(c.pending) > 0 {
+			c.waitLocked(time.Now())
+		} else {
+			c.waitLocked(time.Time{})
+		}
+		c.mu.Unlock()
+	}
+}
+
+// waitLocked sets the read deadline of the connection to the timeout from the time since which the connection waits for a response,
+// zero time disables the deadline of the idle connection.
+func (c *pipelinedConn) waitLocked(since time.Time) {
+	c.waiting = since
+	if since.IsZero() {
+		c.co.SetReadDeadline(time.Time{})
+		return
+	}
+	c.co.SetReadDeadline(since.Add(c.timeout))
+}
+
+// forget removes the query, which is no longer waiting for the response, the ID might be already reused by another query.
+func (c *pipelinedConn) forget(id uint16, ch chan *dns.Msg) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending[id] == ch {
+		delete(c.pending, id)
+	}
+}
+
+func (c *pipelinedConn) failure() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *pipelinedConn) failLocked(err error) {
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	c.co.Close()
+	for _, ch := range c.pending {
+		close(ch)
+	}
+	c.pending = nil
+}
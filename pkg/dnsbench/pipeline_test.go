@@ -0,0 +1,174 @@
+package dnsbench
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReorderingServer starts TCP DNS server, which reads pairs of queries and answers them in reverse order,
+// so the queries are answered only when they are pipelined.
+func newReorderingServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				co := &dns.Conn{Conn: conn}
+				defer co.Close()
+				for {
+					var pair []*dns.Msg
+					for len(pair) < 2 {
+						m, err := co.ReadMsg()
+						if err != nil {
+							return
+						}
+						pair = append(pair, m)
+					}
+					for i := len(pair) - 1; i >= 0; i-- {
+						r := new(dns.Msg)
+						r.SetReply(pair[i])
+						if err := co.WriteMsg(r); err != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func TestBenchmark_Run_maxInflight(t *testing.T) {
+	l := newReorderingServer(t)
+	defer l.Close()
+
+	bench := createBenchmark(l.Addr().String(), true, 1)
+	bench.Concurrency = 1
+	bench.MaxInflight = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2, "Run(ctx) rstats")
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.Total, "Run(ctx) total counter")
+	assert.Equal(t, int64(4), merged.Counters.Success, "Run(ctx) success counter")
+	assert.Zero(t, merged.Counters.IDmismatch, "Run(ctx) mismatch counter")
+	assert.Equal(t, int64(1), merged.Connections.Count, "Run(ctx) connections counter")
+	assert.Equal(t, int64(1), merged.Connections.Workers, "Run(ctx) connections workers")
+}
+
+func TestBenchmark_Run_maxInflight_timeout(t *testing.T) {
+	l := newReorderingServer(t)
+	defer l.Close()
+
+	bench := createBenchmark(l.Addr().String(), true, 1)
+	bench.Concurrency = 1
+	bench.Types = []string{"A"}
+	bench.ReadTimeout = 100 * time.Millisecond
+	bench.RequestTimeout = time.Second
+	bench.MaxInflight = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	merged := Merge(rs)
+	// the third query is never answered, because the server waits for the pair
+	assert.Equal(t, int64(3), merged.Counters.Total, "Run(ctx) total counter")
+	assert.Equal(t, int64(2), merged.Counters.Success, "Run(ctx) success counter")
+	assert.Equal(t, int64(1), merged.Counters.IOError, "Run(ctx) error counter")
+}
+
+func TestBenchmark_Run_maxInflight_stalledServer(t *testing.T) {
+	// the server answers only the first query of each connection and then stops answering without closing the connection
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				co := &dns.Conn{Conn: conn}
+				defer co.Close()
+				for answered := false; ; answered = true {
+					m, err := co.ReadMsg()
+					if err != nil {
+						return
+					}
+					if !answered {
+						r := new(dns.Msg)
+						r.SetReply(m)
+						co.WriteMsg(r)
+					}
+				}
+			}()
+		}
+	}()
+
+	bench := createBenchmark(l.Addr().String(), true, 1)
+	bench.Concurrency = 1
+	bench.Types = []string{"A"}
+	bench.Count = 5
+	bench.ReadTimeout = 50 * time.Millisecond
+	bench.RequestTimeout = 150 * time.Millisecond
+	bench.MaxInflight = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	merged := Merge(rs)
+	assert.Equal(t, int64(10), merged.Counters.Total, "Run(ctx) total counter")
+	assert.Greater(t, merged.Connections.Count, int64(1), "the stalled connection is redialed")
+	assert.Equal(t, merged.Connections.Count, merged.Counters.Success, "the first query of each connection is answered")
+}
+
+func TestBenchmark_normalize_maxInflight(t *testing.T) {
+	tests := []struct {
+		name    string
+		bench   Benchmark
+		wantErr string
+	}{
+		{
+			name:    "UDP",
+			bench:   Benchmark{Server: "127.0.0.1", MaxInflight: 2},
+			wantErr: "--max-inflight is supported only for plain DNS over TCP and DoT",
+		},
+		{
+			name:    "DoH",
+			bench:   Benchmark{Server: "https://127.0.0.1/dns-query", TCP: true, MaxInflight: 2},
+			wantErr: "--max-inflight is supported only for plain DNS over TCP and DoT",
+		},
+		{
+			name:    "query per connection",
+			bench:   Benchmark{Server: "127.0.0.1", TCP: true, QperConn: 10, MaxInflight: 2},
+			wantErr: "--max-inflight cannot be combined with --query-per-conn, --fresh-connection-per-query, --transfer or --tsig options",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bench.normalize()
+
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package dnsbench
+
+import (
+	"image/color"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/montanaflynn/stats"
+	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette/moreland"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// plotHistogramLatency plots distribution of the latencies, weight is the number of requests represented by each datapoint.
+func plotHistogramLatency(file string, times []Datapoint, weight float64) error {
+	values := make(plotter.XYs, 0, len(times))
+	for _, v := range times {
+		values = append(values, plotter.XY{X: v.Duration, Y: weight})
+	}
+	p := plot.New()
+	p.Title.Text = "Latencies distribution"
+
+	hist, err := plotter.NewHistogram(values, 16)
+	if err != nil {
+		panic(err)
+	}
+	p.X.Label.Text = "Latencies (ms)"
+	p.X.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.Y.Label.Text = "Number of requests"
+	p.Y.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	hist.FillColor = color.RGBA{R: 175, G: 238, B: 238}
+	p.Add(hist)
+
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
+
+func plotBoxPlotLatency(file, server string, times []Datapoint) error {
+	var values plotter.Values
+	for _, v := range times {
+		values = append(values, v.Duration)
+	}
+	p := plot.New()
+	p.Title.Text = "Latencies distribution"
+	p.Y.Label.Text = "Latencies (ms)"
+	p.Y.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.NominalX(server)
+
+	boxplot, err := plotter.NewBoxPlot(vg.Length(120), 0, values)
+	if err != nil {
+		panic(err)
+	}
+	boxplot.FillColor = color.RGBA{R: 127, G: 188, B: 165, A: 1}
+	p.Add(boxplot)
+
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
+
+func plotResponses(file string, rcodes map[int]int64) error {
+	sortedKeys := make([]int, 0)
+	for k := range rcodes {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Ints(sortedKeys)
+
+	colors := []color.Color{
+		color.RGBA{R: 122, G: 195, B: 106},
+		color.RGBA{R: 241, G: 90, B: 96},
+		color.RGBA{R: 90, G: 155, B: 212},
+		color.RGBA{R: 250, G: 167, B: 91},
+		color.RGBA{R: 158, G: 103, B: 171},
+		color.RGBA{R: 206, G: 112, B: 88},
+		color.RGBA{R: 215, G: 127, B: 180},
+	}
+	colors = append(colors, plotutil.DarkColors...)
+
+	p := plot.New()
+	p.Title.Text = "Response code distribution"
+	p.NominalX("Response codes")
+
+	width := vg.Points(40)
+
+	c := 0
+	off := -vg.Length(len(rcodes)/2) * width
+	for _, v := range sortedKeys {
+		bar, err := plotter.NewBarChart(plotter.Values{float64(rcodes[v])}, width)
+		if err != nil {
+			panic(err)
+		}
+		p.Legend.Add(dns.RcodeToString[v], bar)
+		bar.Color = colors[c%len(colors)]
+		bar.Offset = off
+		p.Add(bar)
+		c++
+		off += width
+	}
+
+	p.Y.Label.Text = "Number of requests"
+	p.Y.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.Legend.Top = true
+
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
+
+// plotLineThroughput plots the number of sent and answered requests per second, so it is visible whether the server kept up with the load,
+// weight is the number of requests represented by each datapoint.
+func plotLineThroughput(file string, times []Datapoint, errorTimes []time.Time, weight float64) error {
+	first := firstSecond(times, errorTimes)
+	answered := make(map[int64]int64)
+	sent := make(map[int64]int64)
+	for _, v := range times {
+		answered[v.Start.Unix()-first]++
+		sent[v.Start.Unix()-first]++
+	}
+	for _, v := range errorTimes {
+		sent[v.Unix()-first]++
+	}
+
+	p := plot.New()
+	p.Title.Text = "Throughput per second"
+	p.X.Label.Text = "Time of test (s)"
+	p.X.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.Y.Label.Text = "Number of requests (per sec)"
+	p.Y.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+
+	if len(errorTimes) != 0 {
+		plotLine(p, perSecond(sent, func(v int64, _ int64) float64 { return float64(v) * weight }), plotutil.Color(1), "sent")
+		plotLine(p, perSecond(answered, func(v int64, _ int64) float64 { return float64(v) * weight }), color.RGBA{R: 255, A: 255}, "answered")
+		p.Legend.Top = true
+	} else {
+		l, err := plotter.NewLine(perSecond(answered, func(v int64, _ int64) float64 { return float64(v) * weight }))
+		l.Color = color.RGBA{R: 255, A: 255}
+		if err != nil {
+			panic(err)
+		}
+		p.Add(l)
+	}
+
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
+
+type latencyMeasurements struct {
+	p99 float64
+	p95 float64
+	p90 float64
+	p50 float64
+}
+
+func plotLineLatencies(file string, times []Datapoint) error {
+	m := make(map[int64]latencyMeasurements)
+
+	timings := make([]float64, 0)
+	if len(times) != 0 {
+		first := times[0].Start.Unix()
+		last := int64(0)
+
+		for _, v := range times {
+			unix := v.Start.Unix() - first
+			if _, ok := m[unix]; !ok {
+				m[unix] = latencyMeasurements{}
+			}
+			if unix != last {
+				p99, err := stats.Percentile(timings, 99)
+				if err != nil {
+					panic(err)
+				}
+				p95, err := stats.Percentile(timings, 95)
+				if err != nil {
+					panic(err)
+				}
+				p90, err := stats.Percentile(timings, 90)
+				if err != nil {
+					panic(err)
+				}
+				p50, err := stats.Percentile(timings, 50)
+				if err != nil {
+					panic(err)
+				}
+				measure := m[unix]
+				measure.p99 = p99
+				measure.p95 = p95
+				measure.p90 = p90
+				measure.p50 = p50
+				m[unix] = measure
+				last = unix
+			}
+			timings = append(timings, v.Duration)
+		}
+	}
+
+	var p99values plotter.XYs
+	var p95values plotter.XYs
+	var p90values plotter.XYs
+	var p50values plotter.XYs
+
+	for k, v := range m {
+		p99values = append(p99values, plotter.XY{X: float64(k), Y: v.p99})
+		p95values = append(p95values, plotter.XY{X: float64(k), Y: v.p95})
+		p90values = append(p90values, plotter.XY{X: float64(k), Y: v.p90})
+		p50values = append(p50values, plotter.XY{X: float64(k), Y: v.p50})
+	}
+
+	less := func(xys plotter.XYs) func(i, j int) bool {
+		return func(i, j int) bool {
+			return xys[i].X < xys[j].X
+		}
+	}
+
+	sort.SliceStable(p99values, less(p99values))
+	sort.SliceStable(p95values, less(p95values))
+	sort.SliceStable(p90values, less(p90values))
+	sort.SliceStable(p50values, less(p50values))
+
+	p := plot.New()
+	p.Title.Text = "Response latencies"
+	p.X.Label.Text = "Time of test (s)"
+	p.X.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.Y.Label.Text = "Latency (ms)"
+	p.Y.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+
+	plotLine(p, p99values, plotutil.Color(0), "p99")
+	plotLine(p, p95values, plotutil.Color(1), "p95")
+	plotLine(p, p90values, plotutil.Color(2), "p90")
+	plotLine(p, p50values, plotutil.Color(3), "p50")
+
+	p.Legend.Top = true
+
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
+
+func plotLine(p *plot.Plot, values plotter.XYs, color color.Color, name string) {
+	l, err := plotter.NewLine(values)
+	l.Color = color
+	if err != nil {
+		panic(err)
+	}
+	p.Add(l)
+	p.Legend.Add(name, l)
+}
+
+// plotLineErrorRate plots the percentage of requests sent in each second, which failed due to I/O errors or timeouts.
+func plotLineErrorRate(file string, times []Datapoint, errorTimes []time.Time) error {
+	first := firstSecond(times, errorTimes)
+	sent := make(map[int64]int64)
+	for _, v := range times {
+		sent[v.Start.Unix()-first]++
+	}
+	errs := make(map[int64]int64)
+	for _, v := range errorTimes {
+		sent[v.Unix()-first]++
+		errs[v.Unix()-first]++
+	}
+
+	values := perSecond(sent, func(_ int64, k int64) float64 {
+		return float64(errs[k]) / float64(sent[k]) * 100
+	})
+
+	p := plot.New()
+	p.Title.Text = "Error rate per second"
+	p.X.Label.Text = "Time of test (s)"
+	p.X.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.Y.Label.Text = "Errors and timeouts (%)"
+	p.Y.Tick.Marker = hplot.Ticks{N: 10, Format: "%.1f"}
+	p.Y.Min = 0
+
+	l, err := plotter.NewLine(values)
+	if err != nil {
+		panic(err)
+	}
+	l.Color = color.RGBA{R: 241, G: 90, B: 96, A: 255}
+	p.Add(l)
+
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
+
+// plotLineResponses plots the number of responses per second for each response code, weight is the number of responses represented by each datapoint.
+func plotLineResponses(file string, times []Datapoint, weight float64) error {
+	first := firstSecond(times, nil)
+	seconds := make(map[int64]int64)
+	rcodes := make(map[int]map[int64]int64)
+	for _, v := range times {
+		unix := v.Start.Unix() - first
+		seconds[unix]++
+		if _, ok := rcodes[v.Rcode]; !ok {
+			rcodes[v.Rcode] = make(map[int64]int64)
+		}
+		rcodes[v.Rcode][unix]++
+	}
+
+	sortedKeys := make([]int, 0, len(rcodes))
+	for k := range rcodes {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Ints(sortedKeys)
+
+	p := plot.New()
+	p.Title.Text = "Response codes per second"
+	p.X.Label.Text = "Time of test (s)"
+	p.X.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.Y.Label.Text = "Number of responses (per sec)"
+	p.Y.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+
+	for i, rcode := range sortedKeys {
+		counts := rcodes[rcode]
+		// seconds without responses with the code are plotted as zero, so the lines do not interpolate over them
+		values := perSecond(seconds, func(_ int64, k int64) float64 { return float64(counts[k]) * weight })
+		plotLine(p, values, plotutil.Color(i), dns.RcodeToString[rcode])
+	}
+
+	p.Legend.Top = true
+
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
+
+// heatmapRows is the number of latency ranges of the latency heatmap.
+const heatmapRows = 20
+
+// latencyGrid counts the responses by the second of the test and latency range, it implements plotter.GridXYZ.
+type latencyGrid struct {
+	counts [][]float64
+	// step is the size of a latency range in milliseconds.
+	step float64
+}
+
+func newLatencyGrid(times []Datapoint) *latencyGrid {
+	first := firstSecond(times, nil)
+	var maxSecond int64
+	var maxLatency float64
+	for _, v := range times {
+		if s := v.Start.Unix() - first; s > maxSecond {
+			maxSecond = s
+		}
+		maxLatency = math.Max(maxLatency, v.Duration)
+	}
+
+	g := &latencyGrid{counts: make([][]float64, maxSecond+1), step: math.Max(1, math.Ceil((maxLatency+1)/heatmapRows))}
+	for i := range g.counts {
+		g.counts[i] = make([]float64, heatmapRows)
+	}
+	for _, v := range times {
+		g.counts[v.Start.Unix()-first][int(v.Duration/g.step)]++
+	}
+	return g
+}
+
+func (g *latencyGrid) Dims() (c, r int) {
+	return len(g.counts), heatmapRows
+}
+
+func (g *latencyGrid) Z(c, r int) float64 {
+	return g.counts[c][r]
+}
+
+func (g *latencyGrid) X(c int) float64 {
+	return float64(c)
+}
+
+func (g *latencyGrid) Y(r int) float64 {
+	return (float64(r) + 0.5) * g.step
+}
+
+// plotHeatmapLatency plots the number of responses in each second of the test by their latency.
+func plotHeatmapLatency(file string, times []Datapoint) error {
+	if len(times) == 0 {
+		return nil
+	}
+	g := newLatencyGrid(times)
+	h := plotter.NewHeatMap(g, moreland.ExtendedBlackBody().Palette(12))
+	h.Min = 0
+	if h.Max <= h.Min {
+		h.Max = h.Min + 1
+	}
+
+	p := plot.New()
+	p.Title.Text = "Latencies heatmap"
+	p.X.Label.Text = "Time of test (s)"
+	p.X.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.Y.Label.Text = "Latency (ms)"
+	p.Y.Tick.Marker = hplot.Ticks{N: 10, Format: "%.0f"}
+	p.Add(h)
+
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
+
+// firstSecond returns the unix second of the earliest datapoint or error.
+func firstSecond(times []Datapoint, errorTimes []time.Time) int64 {
+	var first int64
+	if len(times) != 0 {
+		first = times[0].Start.Unix()
+	}
+	if len(errorTimes) != 0 && (len(times) == 0 || errorTimes[0].Unix() < first) {
+		first = errorTimes[0].Unix()
+	}
+	return first
+}
+
+// perSecond converts counts by the second of the test to points sorted by time, with value computed by the provided function.
+func perSecond(m map[int64]int64, value func(v int64, k int64) float64) plotter.XYs {
+	values := make(plotter.XYs, 0, len(m))
+	for k, v := range m {
+		values = append(values, plotter.XY{X: float64(k), Y: value(v, k)})
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].X < values[j].X
+	})
+	return values
+}
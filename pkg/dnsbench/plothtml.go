@@ -0,0 +1,224 @@
+package dnsbench
+
+import (
+	_ "embed"
+	"html/template"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// htmlFormat is the format of the plots exported as a single self-contained HTML file with interactive charts, see Benchmark.PlotFormat.
+const htmlFormat = "html"
+
+// htmlScatterPoints is the maximum number of latencies of the individual responses plotted in the interactive chart, when there are more
+// datapoints, the latencies above p99 are all plotted and the rest is sampled evenly, so that the tail outliers are preserved.
+const htmlScatterPoints = 20000
+
+//go:embed plothtml.tmpl
+var htmlPlotTemplate string
+
+var htmlPlot = template.Must(template.New("plot").Parse(htmlPlotTemplate))
+
+// htmlChart is a chart of the HTML plot, the points of the series are pairs of the time of test in seconds and the value,
+// unless the chart has its own label of the x-axis.
+type htmlChart struct {
+	Title  string       `json:"title"`
+	XLabel string       `json:"xLabel,omitempty"`
+	YLabel string       `json:"yLabel"`
+	Series []htmlSeries `json:"series"`
+}
+
+type htmlSeries struct {
+	Name    string       `json:"name"`
+	Scatter bool         `json:"scatter,omitempty"`
+	Points  [][2]float64 `json:"points"`
+}
+
+// plotHTML exports the latency, throughput and response code charts of the benchmark into a single HTML file, the charts can be zoomed
+// by selecting the time range and are not dependent on any external resources. weight is the number of requests represented by each datapoint.
+func plotHTML(file, server string, times []Datapoint, errorTimes []time.Time, weight float64) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data := struct {
+		Server string
+		Charts []htmlChart
+	}{
+		Server: server,
+		Charts: []htmlChart{htmlLatencyChart(times), htmlThroughputChart(times, errorTimes, weight), htmlResponsesChart(times, weight)},
+	}
+	return htmlPlot.Execute(f, data)
+}
+
+// plotHTMLConcurrencySweep exports the chart of the latency percentiles by the throughput of the concurrency levels into a single HTML file.
+func plotHTMLConcurrencySweep(file, server string, levels []ConcurrencyLevel) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	chart := htmlChart{Title: "Latency by throughput", XLabel: "Throughput (QPS)", YLabel: "Latency (ms)"}
+	for _, p := range []struct {
+		name  string
+		value func(l ConcurrencyLevel) time.Duration
+	}{
+		{"p50", func(l ConcurrencyLevel) time.Duration { return l.P50 }},
+		{"p95", func(l ConcurrencyLevel) time.Duration { return l.P95 }},
+		{"p99", func(l ConcurrencyLevel) time.Duration { return l.P99 }},
+	} {
+		s := htmlSeries{Name: p.name, Points: make([][2]float64, 0, len(levels))}
+		for _, l := range levels {
+			s.Points = append(s.Points, [2]float64{l.QueriesPerSecond, float64(p.value(l)) / float64(time.Millisecond)})
+		}
+		chart.Series = append(chart.Series, s)
+	}
+
+	data := struct {
+		Server string
+		Charts []htmlChart
+	}{
+		Server: server,
+		Charts: []htmlChart{chart},
+	}
+	return htmlPlot.Execute(f, data)
+}
+
+func htmlLatencyChart(times []Datapoint) htmlChart {
+	chart := htmlChart{Title: "Response latencies", YLabel: "Latency (ms)"}
+	if len(times) == 0 {
+		return chart
+	}
+	first := times[0].Start
+	for _, v := range times {
+		if v.Start.Before(first) {
+			first = v.Start
+		}
+	}
+
+	latencies := make([]float64, 0, len(times))
+	bySecond := make(map[int64][]float64)
+	for _, v := range times {
+		latencies = append(latencies, v.Duration)
+		s := int64(v.Start.Sub(first) / time.Second)
+		bySecond[s] = append(bySecond[s], v.Duration)
+	}
+	sort.Float64s(latencies)
+	p99 := quantileSorted(latencies, 0.99)
+
+	// every n-th response below p99 is plotted, when there are too many datapoints
+	n := 1
+	if len(times) > htmlScatterPoints {
+		n = int(math.Ceil(float64(len(times)) / htmlScatterPoints))
+	}
+	scatter := make([][2]float64, 0, len(times)/n+1)
+	for i, v := range times {
+		if v.Duration > p99 || i%n == 0 {
+			scatter = append(scatter, [2]float64{round3(v.Start.Sub(first).Seconds()), round3(v.Duration)})
+		}
+	}
+
+	seconds := make([]int64, 0, len(bySecond))
+	for s := range bySecond {
+		seconds = append(seconds, s)
+	}
+	sort.Slice(seconds, func(i, j int) bool { return seconds[i] < seconds[j] })
+	p50s := make([][2]float64, 0, len(seconds))
+	p99s := make([][2]float64, 0, len(seconds))
+	for _, s := range seconds {
+		l := bySecond[s]
+		sort.Float64s(l)
+		p50s = append(p50s, [2]float64{float64(s), round3(quantileSorted(l, 0.5))})
+		p99s = append(p99s, [2]float64{float64(s), round3(quantileSorted(l, 0.99))})
+	}
+
+	chart.Series = []htmlSeries{
+		{Name: "responses", Scatter: true, Points: scatter},
+		{Name: "p50", Points: p50s},
+		{Name: "p99", Points: p99s},
+	}
+	return chart
+}
+
+func htmlThroughputChart(times []Datapoint, errorTimes []time.Time, weight float64) htmlChart {
+	first := firstSecond(times, errorTimes)
+	answered := make(map[int64]int64)
+	sent := make(map[int64]int64)
+	for _, v := range times {
+		answered[v.Start.Unix()-first]++
+		sent[v.Start.Unix()-first]++
+	}
+	for _, v := range errorTimes {
+		sent[v.Unix()-first]++
+	}
+	perSec := func(m map[int64]int64) [][2]float64 {
+		// seconds without responses are plotted as zero
+		return htmlPoints(sent, func(k int64) float64 { return float64(m[k]) * weight })
+	}
+	return htmlChart{
+		Title:  "Throughput per second",
+		YLabel: "Number of requests (per sec)",
+		Series: []htmlSeries{{Name: "sent", Points: perSec(sent)}, {Name: "answered", Points: perSec(answered)}},
+	}
+}
+
+func htmlResponsesChart(times []Datapoint, weight float64) htmlChart {
+	first := firstSecond(times, nil)
+	seconds := make(map[int64]int64)
+	rcodes := make(map[int]map[int64]int64)
+	for _, v := range times {
+		unix := v.Start.Unix() - first
+		seconds[unix]++
+		if _, ok := rcodes[v.Rcode]; !ok {
+			rcodes[v.Rcode] = make(map[int64]int64)
+		}
+		rcodes[v.Rcode][unix]++
+	}
+	sortedKeys := make([]int, 0, len(rcodes))
+	for k := range rcodes {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Ints(sortedKeys)
+
+	chart := htmlChart{Title: "Response codes per second", YLabel: "Number of responses (per sec)"}
+	for _, rcode := range sortedKeys {
+		counts := rcodes[rcode]
+		chart.Series = append(chart.Series, htmlSeries{
+			Name:   dns.RcodeToString[rcode],
+			Points: htmlPoints(seconds, func(k int64) float64 { return float64(counts[k]) * weight }),
+		})
+	}
+	return chart
+}
+
+// htmlPoints converts the seconds of the test to points sorted by time, with value computed by the provided function.
+func htmlPoints(seconds map[int64]int64, value func(k int64) float64) [][2]float64 {
+	points := make([][2]float64, 0, len(seconds))
+	for _, xy := range perSecond(seconds, func(_ int64, k int64) float64 { return value(k) }) {
+		points = append(points, [2]float64{xy.X, round3(xy.Y)})
+	}
+	return points
+}
+
+// quantileSorted returns the q quantile of the sorted values using the nearest rank.
+func quantileSorted(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,60 @@
+package dnsbench
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_plotHTML(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	times := []Datapoint{
+		{Duration: 5, Start: start},
+		{Duration: 7, Start: start.Add(500 * time.Millisecond)},
+		{Duration: 50, Start: start.Add(1500 * time.Millisecond), Rcode: dns.RcodeServerFailure},
+	}
+	file := filepath.Join(t.TempDir(), "graphs.html")
+
+	require.NoError(t, plotHTML(file, "<127.0.0.1>", times, []time.Time{start.Add(time.Second)}, 1))
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	html := string(b)
+	assert.Contains(t, html, "&lt;127.0.0.1&gt;", "the server is escaped")
+	assert.NotContains(t, html, "<script src", "the file does not depend on external resources")
+	for _, s := range []string{"Response latencies", "Throughput per second", "Response codes per second", "SERVFAIL", "[1.5,50]"} {
+		assert.Contains(t, html, s)
+	}
+}
+
+func Test_htmlLatencyChart(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	times := make([]Datapoint, 0, 3*htmlScatterPoints)
+	for i := 0; i < 3*htmlScatterPoints; i++ {
+		d := 1.0
+		if i%1000 == 1 {
+			d = 100
+		}
+		times = append(times, Datapoint{Duration: d, Start: start.Add(time.Duration(i) * time.Millisecond)})
+	}
+
+	chart := htmlLatencyChart(times)
+
+	require.Len(t, chart.Series, 3)
+	scatter := chart.Series[0].Points
+	assert.LessOrEqual(t, len(scatter), htmlScatterPoints+3*htmlScatterPoints/1000)
+	var outliers int
+	for _, p := range scatter {
+		if p[1] == 100 {
+			outliers++
+		}
+	}
+	assert.Equal(t, 3*htmlScatterPoints/1000, outliers, "all outliers above p99 are plotted")
+	assert.Equal(t, [2]float64{0, 1}, chart.Series[1].Points[0], "p50 of the first second")
+	assert.Len(t, chart.Series[2].Points, 3*htmlScatterPoints/1000)
+}
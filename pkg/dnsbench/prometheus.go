@@ -0,0 +1,94 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are live benchmark metrics exposed for Prometheus, the metrics are safe for concurrent use by benchmark workers.
+type metrics struct {
+	registry  *prometheus.Registry
+	requests  prometheus.Counter
+	errors    prometheus.Counter
+	responses *prometheus.CounterVec
+	latency   prometheus.Histogram
+}
+
+func newMetrics() *metrics {
+	m := metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "dnspyre_requests_total",
+			Help: "Number of DNS requests sent.",
+		}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "dnspyre_errors_total",
+			Help: "Number of DNS requests failed due to I/O errors.",
+		}),
+		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dnspyre_responses_total",
+			Help: "Number of DNS responses received by response code.",
+		}, []string{"rcode"}),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "dnspyre_latency_seconds",
+			Help:    "Latency of DNS requests.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16),
+		}),
+	}
+	m.registry.MustRegister(m.requests, m.errors, m.responses, m.latency)
+	return &m
+}
+
+func (m *metrics) observeRequest() {
+	if m == nil {
+		return
+	}
+	m.requests.Inc()
+}
+
+func (m *metrics) observeError() {
+	if m == nil {
+		return
+	}
+	m.errors.Inc()
+}
+
+func (m *metrics) observeResponse(resp *dns.Msg, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.responses.WithLabelValues(dns.RcodeToString[resp.Rcode]).Inc()
+	m.latency.Observe(latency.Seconds())
+}
+
+// startMetricsServer starts HTTP server exposing the metrics on /metrics path, the returned function shuts the server down.
+func startMetricsServer(addr string, m *metrics, log *logger) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start Prometheus metrics listener due to '%v'", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	server := http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.warn("Prometheus metrics server failed", "addr", addr, "err", err)
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
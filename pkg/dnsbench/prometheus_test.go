@@ -0,0 +1,57 @@
+package dnsbench
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_metrics(t *testing.T) {
+	m := newMetrics()
+	ts := httptest.NewServer(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	defer ts.Close()
+
+	resp := dns.Msg{}
+	resp.Rcode = dns.RcodeNameError
+	m.observeRequest()
+	m.observeResponse(&resp, 10*time.Millisecond)
+	m.observeRequest()
+	m.observeError()
+
+	r, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), "dnspyre_requests_total 2")
+	assert.Contains(t, string(body), "dnspyre_errors_total 1")
+	assert.Contains(t, string(body), `dnspyre_responses_total{rcode="NXDOMAIN"} 1`)
+	assert.Contains(t, string(body), "dnspyre_latency_seconds_count 1")
+}
+
+func Test_metrics_nil(t *testing.T) {
+	var m *metrics
+
+	assert.NotPanics(t, func() {
+		m.observeRequest()
+		m.observeError()
+		m.observeResponse(&dns.Msg{}, time.Second)
+	})
+}
+
+func Test_startMetricsServer(t *testing.T) {
+	stop, err := startMetricsServer("127.0.0.1:0", newMetrics(), nil)
+	require.NoError(t, err)
+	stop()
+
+	_, err = startMetricsServer("invalid:address:0", newMetrics(), nil)
+	assert.Error(t, err)
+}
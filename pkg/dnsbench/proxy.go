@@ -0,0 +1,142 @@
+package dnsbench
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// parseProxy parses URL of the proxy, supported proxies are SOCKS5 proxies and HTTP proxies supporting CONNECT method.
+func parseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy '%s': %v", s, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return nil, fmt.Errorf("unsupported proxy '%s', supported schemes are socks5, socks5h and http", s)
+	}
+	if u.Port() == "" {
+		return nil, fmt.Errorf("invalid proxy '%s', port is missing", s)
+	}
+	return u, nil
+}
+
+// environmentProxy returns proxy of the DoH server configured by HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables, if any.
+func environmentProxy(server string) *url.URL {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil
+	}
+	p, err := httpproxy.FromEnvironment().ProxyFunc()(u)
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// dialProxy establishes TCP tunnel to the address through the proxy, the connection to the proxy is dialed from the source address.
+func (b *Benchmark) dialProxy(ctx context.Context, addr string) (net.Conn, error) {
+	network := b.network("tcp")
+	if b.proxy.Scheme != "http" {
+		var auth *proxy.Auth
+		if u := b.proxy.User; u != nil {
+			password, _ := u.Password()
+			auth = &proxy.Auth{User: u.Username(), Password: password}
+		}
+		d, err := proxy.SOCKS5(network, b.proxy.Host, auth, b.dialer(network))
+		if err != nil {
+			return nil, err
+		}
+		return d.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	}
+
+	conn, err := b.dialer(network).DialContext(ctx, network, b.proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(deadline(ctx, b.ConnectTimeout))
+
+	req := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: addr}, Host: addr, Header: make(http.Header)}
+	if u := b.proxy.User; u != nil {
+		password, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused connection to '%s' with status '%s'", addr, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// dialProxyTLS establishes TLS connection to the address through the proxy.
+func (b *Benchmark) dialProxyTLS(ctx context.Context, addr string, cfg *tls.Config) (net.Conn, time.Duration, error) {
+	start := time.Now()
+	conn, err := b.dialProxy(ctx, addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	proxySetup := time.Since(start)
+
+	if cfg.ServerName == "" {
+		cfg = cfg.Clone()
+		cfg.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+	return tlsConn, proxySetup, nil
+}
+
+// dialProxyDNS establishes plain DNS over TCP or DoT connection to the server through the proxy.
+func (b *Benchmark) dialProxyDNS(ctx context.Context, server string) (*dns.Conn, error) {
+	var conn net.Conn
+	var err error
+	if b.DOT {
+		conn, err = b.dialTLS(ctx, "tcp", server, b.tlsConfig(""))
+	} else {
+		conn, err = b.dialTCP(ctx, "tcp", server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dns.Conn{Conn: conn}, nil
+}
+
+// bufferedConn is a connection, whose data were partially read to the buffer while establishing the tunnel.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
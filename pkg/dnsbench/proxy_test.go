@@ -0,0 +1,226 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSocks5Proxy starts SOCKS5 proxy without authentication supporting only CONNECT command with IPv4 addresses.
+func newSocks5Proxy(t *testing.T, tunnels *int64) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				// greeting with authentication methods
+				greeting := make([]byte, 2)
+				if _, err := io.ReadFull(conn, greeting); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, make([]byte, greeting[1])); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 0})
+				// version, command, reserved, address type, IPv4 address and port
+				req := make([]byte, 10)
+				if _, err := io.ReadFull(conn, req); err != nil || req[3] != 1 {
+					return
+				}
+				addr := net.JoinHostPort(net.IP(req[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(req[8:]))))
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+				atomic.AddInt64(tunnels, 1)
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				pipe(conn, target)
+			}()
+		}
+	}()
+	return l
+}
+
+// newHTTPProxy starts HTTP proxy supporting only CONNECT method.
+func newHTTPProxy(tunnels *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+		atomic.AddInt64(tunnels, 1)
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		pipe(conn, target)
+	}))
+}
+
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func TestBenchmark_Run_proxy(t *testing.T) {
+	var socksTunnels, httpTunnels int64
+	socks := newSocks5Proxy(t, &socksTunnels)
+	defer socks.Close()
+	httpProxy := newHTTPProxy(&httpTunnels)
+	defer httpProxy.Close()
+
+	tests := []struct {
+		name    string
+		proxy   string
+		tunnels *int64
+	}{
+		{name: "socks5", proxy: "socks5://" + socks.Addr().String(), tunnels: &socksTunnels},
+		{name: "http", proxy: httpProxy.URL, tunnels: &httpTunnels},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(tcp, func(w dns.ResponseWriter, r *dns.Msg) {
+				ret := new(dns.Msg)
+				ret.SetReply(r)
+				ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+				w.WriteMsg(ret)
+			})
+			defer s.Close()
+
+			bench := createBenchmark(s.Addr, true, 1)
+			bench.Proxy = tt.proxy
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 2, "Run(ctx) rstats")
+			for _, r := range rs {
+				assert.Equal(t, int64(2), r.Counters.Success, "Run(ctx) success counter")
+				if assert.NotNil(t, r.Connections, "Run(ctx) connections") {
+					assert.Equal(t, int64(1), r.Connections.Count, "Run(ctx) connections counter")
+					assert.Equal(t, int64(1), r.Connections.ProxySetup.TotalCount(), "Run(ctx) proxy setup datapoints")
+				}
+			}
+			assert.Equal(t, int64(2), atomic.LoadInt64(tt.tunnels))
+		})
+	}
+}
+
+func TestBenchmark_Run_proxy_doh(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bd, err := io.ReadAll(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		msg := dns.Msg{}
+		if err := msg.Unpack(bd); err != nil {
+			panic(err)
+		}
+		pack, err := msg.Pack()
+		if err != nil {
+			panic(err)
+		}
+		w.Write(pack)
+	}))
+	defer ts.Close()
+	var tunnels int64
+	httpProxy := newHTTPProxy(&tunnels)
+	defer httpProxy.Close()
+
+	bench := createBenchmark(ts.URL, true, 1)
+	bench.DohMethod = post
+	bench.Concurrency = 1
+	bench.Proxy = httpProxy.URL
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 1, "Run(ctx) rstats")
+	assert.Equal(t, int64(2), rs[0].Counters.Success, "Run(ctx) success counter")
+	assert.Equal(t, int64(1), rs[0].Connections.ProxySetup.TotalCount(), "Run(ctx) proxy setup datapoints")
+	assert.Equal(t, int64(1), atomic.LoadInt64(&tunnels))
+}
+
+func TestBenchmark_normalize_proxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		bench   Benchmark
+		wantErr string
+	}{
+		{
+			name:    "unsupported scheme",
+			bench:   Benchmark{Server: "127.0.0.1", TCP: true, Proxy: "ftp://127.0.0.1:21"},
+			wantErr: "unsupported proxy 'ftp://127.0.0.1:21', supported schemes are socks5, socks5h and http",
+		},
+		{
+			name:    "missing port",
+			bench:   Benchmark{Server: "127.0.0.1", TCP: true, Proxy: "socks5://127.0.0.1"},
+			wantErr: "invalid proxy 'socks5://127.0.0.1', port is missing",
+		},
+		{
+			name:    "UDP",
+			bench:   Benchmark{Server: "127.0.0.1", Proxy: "socks5://127.0.0.1:1080"},
+			wantErr: "--proxy is supported only for DoH over HTTP/1.1 and HTTP/2, DoT and plain DNS over TCP",
+		},
+		{
+			name:    "DoQ",
+			bench:   Benchmark{Server: "quic://127.0.0.1", Proxy: "socks5://127.0.0.1:1080"},
+			wantErr: "--proxy is supported only for DoH over HTTP/1.1 and HTTP/2, DoT and plain DNS over TCP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bench.normalize()
+
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_environmentProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://proxy.example.org:3128")
+	t.Setenv("NO_PROXY", "internal.example.org")
+
+	assert.Equal(t, "http://proxy.example.org:3128", environmentProxy("https://dns.example.org/dns-query").String())
+	assert.Nil(t, environmentProxy("https://internal.example.org/dns-query"))
+}
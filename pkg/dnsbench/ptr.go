@@ -0,0 +1,48 @@
+package dnsbench
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+// maxPTRAddresses is the highest number of addresses in the ranges of PTR queries, the questions are generated upfront and kept in memory.
+const maxPTRAddresses = 1 << 20
+
+// ptrQuestions returns PTR questions of all the addresses in the ranges in CIDR notation, the questions are in order of the addresses
+// unless they are shuffled.
+func ptrQuestions(cidrs []string, shuffle bool, seed int64) ([]dns.Question, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	total := 0
+	for _, c := range cidrs {
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range '%s' of --ptr-from-cidr option, expected CIDR notation like 10.0.0.0/16", c)
+		}
+		bits := p.Addr().BitLen() - p.Bits()
+		if bits >= 31 || total+1<<bits > maxPTRAddresses {
+			return nil, fmt.Errorf("ranges of --ptr-from-cidr option contain more than %d addresses", maxPTRAddresses)
+		}
+		total += 1 << bits
+		prefixes = append(prefixes, p.Masked())
+	}
+
+	questions := make([]dns.Question, 0, total)
+	for _, p := range prefixes {
+		for a := p.Addr(); a.IsValid() && p.Contains(a); a = a.Next() {
+			name, err := dns.ReverseAddr(a.String())
+			if err != nil {
+				return nil, err
+			}
+			questions = append(questions, dns.Question{Name: name, Qtype: dns.TypePTR, Qclass: dns.ClassINET})
+		}
+	}
+	if shuffle {
+		// nolint:gosec
+		rando := rand.New(rand.NewSource(seed))
+		rando.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	}
+	return questions, nil
+}
@@ -0,0 +1,113 @@
+package dnsbench
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ptrQuestions(t *testing.T) {
+	questions, err := ptrQuestions([]string{"10.0.0.1/30", "2001:db8::/127"}, false, 0)
+	require.NoError(t, err)
+
+	var names []string
+	for _, q := range questions {
+		assert.Equal(t, dns.TypePTR, q.Qtype)
+		assert.Equal(t, uint16(dns.ClassINET), q.Qclass)
+		names = append(names, q.Name)
+	}
+	assert.Equal(t, []string{
+		"0.0.0.10.in-addr.arpa.",
+		"1.0.0.10.in-addr.arpa.",
+		"2.0.0.10.in-addr.arpa.",
+		"3.0.0.10.in-addr.arpa.",
+		"0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	}, names)
+}
+
+func Test_ptrQuestions_shuffle(t *testing.T) {
+	ordered, err := ptrQuestions([]string{"10.0.0.0/16"}, false, 0)
+	require.NoError(t, err)
+	shuffled, err := ptrQuestions([]string{"10.0.0.0/16"}, true, 1)
+	require.NoError(t, err)
+
+	require.Equal(t, len(ordered), len(shuffled))
+	names := make(map[string]struct{})
+	moved := 0
+	for i, q := range shuffled {
+		names[q.Name] = struct{}{}
+		if q != ordered[i] {
+			moved++
+		}
+	}
+	assert.Equal(t, 65536, len(names), "each address is queried once")
+	assert.Positive(t, moved, "questions are shuffled")
+}
+
+func Test_ptrQuestions_invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+	}{
+		{name: "not CIDR", cidrs: []string{"10.0.0.1"}},
+		{name: "invalid address", cidrs: []string{"10.0.0.300/24"}},
+		{name: "too large IPv4 range", cidrs: []string{"10.0.0.0/8"}},
+		{name: "too large IPv6 range", cidrs: []string{"2001:db8::/64"}},
+		{name: "too large in total", cidrs: []string{"10.0.0.0/12", "192.0.2.0/24"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ptrQuestions(tt.cidrs, false, 0)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBenchmark_Run_ptrFromCIDR(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		if r.Question[0].Qtype == dns.TypePTR {
+			seen[r.Question[0].Name]++
+		}
+		mu.Unlock()
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = nil
+	bench.Concurrency = 1
+	bench.PtrFromCIDR = []string{"192.0.2.0/29"}
+	bench.PtrShuffle = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 1)
+	assert.Equal(t, int64(8), rs[0].Counters.Total)
+	assert.Equal(t, map[string]int64{"PTR": 8}, rs[0].Qtypes)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, seen, 8)
+	assert.Equal(t, 1, seen["7.2.0.192.in-addr.arpa."])
+}
+
+func TestBenchmark_normalize_ptrFromCIDR(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", PtrShuffle: true}
+	assert.Error(t, b.normalize(), "--ptr-shuffle requires --ptr-from-cidr")
+
+	b = Benchmark{Server: "8.8.8.8", PtrFromCIDR: []string{"10.0.0.0/24"}, Pcap: "queries.pcap"}
+	assert.Error(t, b.normalize(), "--ptr-from-cidr cannot be combined with --pcap")
+}
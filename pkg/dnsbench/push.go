@@ -0,0 +1,244 @@
+package dnsbench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPushInterval is the default interval of pushing the results to the metrics backends.
+	defaultPushInterval = 10 * time.Second
+	// defaultPushPrefix is the default prefix of Graphite and StatsD metric names.
+	defaultPushPrefix = "dnspyre"
+	pushTimeout       = 5 * time.Second
+)
+
+// pushPoint are the results of a single interval of the running benchmark pushed to the metrics backends.
+type pushPoint struct {
+	time   time.Time
+	server string
+	// qps, p50 and p99 are computed from the requests finished in the interval
+	qps      float64
+	p50      time.Duration
+	p99      time.Duration
+	inFlight int64
+	// requests and errors are totals since the start of the benchmark, newRequests and newErrors are the totals of the interval
+	requests    int64
+	errors      int64
+	newRequests int64
+	newErrors   int64
+}
+
+// pusher pushes the results of the running benchmark to a metrics backend.
+type pusher interface {
+	push(p pushPoint) error
+	close() error
+}
+
+// parsePushURL parses the URL of the metrics backend, supported URLs are influxdb://host:port/database (influxdbs:// for HTTPS),
+// graphite://host:port[/prefix] and statsd://host:port[/prefix].
+func parsePushURL(s string) (pusher, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid --push URL '%s', expected influxdb://host:port/database, graphite://host:port[/prefix] or statsd://host:port[/prefix]", s)
+	}
+	prefix := strings.Trim(strings.ReplaceAll(u.Path, "/", "."), ".")
+	if prefix == "" {
+		prefix = defaultPushPrefix
+	}
+
+	switch u.Scheme {
+	case "influxdb", "influxdbs":
+		db := strings.Trim(u.Path, "/")
+		if db == "" {
+			return nil, fmt.Errorf("invalid --push URL '%s', InfluxDB database has to be provided as the path of the URL", s)
+		}
+		scheme := "http"
+		if u.Scheme == "influxdbs" {
+			scheme = "https"
+		}
+		params := u.Query()
+		params.Set("db", db)
+		if u.User != nil {
+			params.Set("u", u.User.Username())
+			if p, ok := u.User.Password(); ok {
+				params.Set("p", p)
+			}
+		}
+		return &influxPusher{url: scheme + "://" + u.Host + "/write?" + params.Encode(), client: &http.Client{Timeout: pushTimeout}}, nil
+	case "graphite":
+		return &linePusher{network: "tcp", addr: u.Host, format: func(p pushPoint) []byte { return graphiteLines(prefix, p) }}, nil
+	case "statsd":
+		return &linePusher{network: "udp", addr: u.Host, format: func(p pushPoint) []byte { return statsdLines(prefix, p) }}, nil
+	}
+	return nil, fmt.Errorf("unsupported --push URL scheme '%s', supported schemes are influxdb, influxdbs, graphite and statsd", u.Scheme)
+}
+
+// influxPusher writes the points using InfluxDB line protocol over HTTP.
+type influxPusher struct {
+	url    string
+	client *http.Client
+}
+
+func (p *influxPusher) push(pt pushPoint) error {
+	resp, err := p.client.Post(p.url, "text/plain; charset=utf-8", bytes.NewReader(influxLine(pt)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("InfluxDB responded with status %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *influxPusher) close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+func influxLine(p pushPoint) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("dnspyre,server=")
+	buf.WriteString(influxEscape(p.server))
+	fmt.Fprintf(&buf, " qps=%s,p50_ms=%s,p99_ms=%s,in_flight=%di,requests=%di,errors=%di,interval_requests=%di,interval_errors=%di %d\n",
+		formatFloat(p.qps), formatMs(p.p50), formatMs(p.p99), p.inFlight, p.requests, p.errors, p.newRequests, p.newErrors, p.time.UnixNano())
+	return buf.Bytes()
+}
+
+// influxEscape escapes tag value of InfluxDB line protocol.
+func influxEscape(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
+
+// linePusher writes the lines of plaintext protocols like Graphite or StatsD, the connection is dialed again after failed write.
+type linePusher struct {
+	network string
+	addr    string
+	format  func(pushPoint) []byte
+	conn    net.Conn
+}
+
+func (p *linePusher) push(pt pushPoint) error {
+	if p.conn == nil {
+		conn, err := net.DialTimeout(p.network, p.addr, pushTimeout)
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(pushTimeout))
+	if _, err := p.conn.Write(p.format(pt)); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (p *linePusher) close() error {
+	if p.conn == nil {
+		return nil
+	}
+	return p.conn.Close()
+}
+
+func graphiteLines(prefix string, p pushPoint) []byte {
+	var buf bytes.Buffer
+	prefix += "." + metricName(p.server)
+	ts := p.time.Unix()
+	for _, m := range []struct {
+		name  string
+		value string
+	}{
+		{"qps", formatFloat(p.qps)},
+		{"latency.p50_ms", formatMs(p.p50)},
+		{"latency.p99_ms", formatMs(p.p99)},
+		{"in_flight", strconv.FormatInt(p.inFlight, 10)},
+		{"requests", strconv.FormatInt(p.requests, 10)},
+		{"errors", strconv.FormatInt(p.errors, 10)},
+	} {
+		fmt.Fprintf(&buf, "%s.%s %s %d\n", prefix, m.name, m.value, ts)
+	}
+	return buf.Bytes()
+}
+
+// statsdLines formats the point as StatsD gauges and counters, the packet is kept small enough to fit into a single UDP datagram.
+func statsdLines(prefix string, p pushPoint) []byte {
+	var buf bytes.Buffer
+	prefix += "." + metricName(p.server)
+	fmt.Fprintf(&buf, "%s.qps:%s|g\n", prefix, formatFloat(p.qps))
+	fmt.Fprintf(&buf, "%s.latency.p50_ms:%s|g\n", prefix, formatMs(p.p50))
+	fmt.Fprintf(&buf, "%s.latency.p99_ms:%s|g\n", prefix, formatMs(p.p99))
+	fmt.Fprintf(&buf, "%s.in_flight:%d|g\n", prefix, p.inFlight)
+	fmt.Fprintf(&buf, "%s.requests:%d|c\n", prefix, p.newRequests)
+	fmt.Fprintf(&buf, "%s.errors:%d|c\n", prefix, p.newErrors)
+	return buf.Bytes()
+}
+
+// redactURL hides the password of the URL in the logs.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
+
+// metricName replaces the characters separating the components of Graphite and StatsD metric names in the server address.
+func metricName(s string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "/", "_", " ", "_", "[", "", "]", "").Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+func formatMs(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// startPush pushes the snapshots of the running benchmark to the metrics backends in the push interval and once more when the benchmark ends,
+// the returned function stops pushing after the last snapshot is pushed. Failed pushes are logged and do not interrupt the benchmark.
+func (b *Benchmark) startPush(live *liveStats) func() {
+	interval := b.PushInterval
+	if interval <= 0 {
+		interval = defaultPushInterval
+	}
+	window := live.addWindow(b)
+	var lastRequests, lastErrors int64
+	push := func(s liveSnapshot) {
+		pt := pushPoint{
+			time: time.Now(), server: b.Server, qps: s.qps, p50: s.p50, p99: s.p99, inFlight: s.inFlight,
+			requests: s.requests, errors: s.errors, newRequests: s.requests - lastRequests, newErrors: s.errors - lastErrors,
+		}
+		lastRequests, lastErrors = s.requests, s.errors
+		for i, p := range b.pushers {
+			if err := p.push(pt); err != nil {
+				b.log.warn("failed to push results", "url", redactURL(b.Push[i]), "err", err)
+			}
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		live.run(window, stop, interval, push)
+		close(done)
+	}()
+	return func() {
+		close(stop)
+		<-done
+		for _, p := range b.pushers {
+			p.close()
+		}
+	}
+}
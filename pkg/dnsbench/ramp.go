@@ -0,0 +1,126 @@
+package dnsbench
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/ratelimit"
+)
+
+// rateStep is a single step of the rate ramp, the rate is applied for the duration of the step.
+type rateStep struct {
+	Rate     int
+	Duration time.Duration
+}
+
+// parseRateRamp parses rate ramp in format rate:duration[,rate:duration...], for example 100:30s,500:30s,1000:60s.
+func parseRateRamp(ramp string) ([]rateStep, error) {
+	var steps []rateStep
+	for _, s := range strings.Split(ramp, ",") {
+		rate, duration, ok := strings.Cut(strings.TrimSpace(s), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate ramp step '%s', expected format rate:duration", s)
+		}
+		r, err := strconv.Atoi(rate)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("invalid rate '%s' of rate ramp step '%s'", rate, s)
+		}
+		d, err := time.ParseDuration(duration)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid duration '%s' of rate ramp step '%s'", duration, s)
+		}
+		steps = append(steps, rateStep{Rate: r, Duration: d})
+	}
+	return steps, nil
+}
+
+// rampLimiter is a rate limiter changing the rate over time according to the steps of the rate ramp.
+// Until the ramp begins, the rate of the first step is used.
+type rampLimiter struct {
+	steps    []rateStep
+	limiters []ratelimit.Limiter
+	start    atomic.Int64
+}
+
+func newRampLimiter(steps []rateStep, newLimiter func(rate int) ratelimit.Limiter) *rampLimiter {
+	r := rampLimiter{steps: steps}
+	for _, s := range steps {
+		r.limiters = append(r.limiters, newLimiter(s.Rate))
+	}
+	return &r
+}
+
+// begin starts the ramp.
+func (r *rampLimiter) begin() {
+	r.start.Store(time.Now().UnixNano())
+}
+
+// duration returns the total duration of the ramp.
+func (r *rampLimiter) duration() time.Duration {
+	var d time.Duration
+	for _, s := range r.steps {
+		d += s.Duration
+	}
+	return d
+}
+
+// stepAt returns index of the step active at the provided time.
+func (r *rampLimiter) stepAt(t time.Time) int {
+	start := r.start.Load()
+	if start == 0 {
+		return 0
+	}
+	elapsed := t.Sub(time.Unix(0, start))
+	for i, s := range r.steps {
+		if elapsed < s.Duration {
+			return i
+		}
+		elapsed -= s.Duration
+	}
+	return len(r.steps) - 1
+}
+
+// Take blocks to meet the rate of the active step.
+func (r *rampLimiter) Take() time.Time {
+	return r.limiters[r.stepAt(time.Now())].Take()
+}
+
+func (r *rampLimiter) String() string {
+	var steps []string
+	for _, s := range r.steps {
+		steps = append(steps, fmt.Sprintf("%s QPS for %s", highlightStr(s.Rate), highlightStr(s.Duration)))
+	}
+	return strings.Join(steps, ", ")
+}
+
+// stepStats are results of queries issued during a single step of the rate ramp.
+type stepStats struct {
+	rateStep
+	Responses        int64
+	QueriesPerSecond float64
+	MeanMs           float64
+	P99Ms            float64
+}
+
+// rampStepStats computes results of each step of the rate ramp from the datapoints tagged with the steps.
+func rampStepStats(steps []rateStep, timings []Datapoint, weight float64) []stepStats {
+	durations := make([][]float64, len(steps))
+	for _, t := range timings {
+		if t.Step < len(steps) {
+			durations[t.Step] = append(durations[t.Step], t.Duration)
+		}
+	}
+
+	res := make([]stepStats, 0, len(steps))
+	for i, s := range steps {
+		st := stepStats{rateStep: s, Responses: int64(math.Round(float64(len(durations[i])) * weight))}
+		st.QueriesPerSecond = math.Round(float64(st.Responses)/s.Duration.Seconds()*100) / 100
+		st.MeanMs, st.P99Ms = meanP99(durations[i])
+		res = append(res, st)
+	}
+	return res
+}
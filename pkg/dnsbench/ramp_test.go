@@ -0,0 +1,50 @@
+package dnsbench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/ratelimit"
+)
+
+func Test_parseRateRamp(t *testing.T) {
+	steps, err := parseRateRamp("100:30s, 500:1m")
+
+	require.NoError(t, err)
+	assert.Equal(t, []rateStep{{Rate: 100, Duration: 30 * time.Second}, {Rate: 500, Duration: time.Minute}}, steps)
+
+	for _, ramp := range []string{"", "100", "abc:30s", "0:30s", "100:abc", "100:0s"} {
+		_, err := parseRateRamp(ramp)
+		assert.Error(t, err, ramp)
+	}
+}
+
+func Test_rampLimiter_stepAt(t *testing.T) {
+	r := newRampLimiter([]rateStep{{Rate: 100, Duration: time.Second}, {Rate: 200, Duration: time.Second}}, func(rate int) ratelimit.Limiter { return ratelimit.New(rate) })
+	assert.Equal(t, 0, r.stepAt(time.Now().Add(time.Hour)), "expected first step before the ramp begins")
+
+	r.begin()
+	now := time.Now()
+	assert.Equal(t, 0, r.stepAt(now))
+	assert.Equal(t, 1, r.stepAt(now.Add(1500*time.Millisecond)))
+	assert.Equal(t, 1, r.stepAt(now.Add(time.Hour)), "expected last step after the ramp ends")
+	assert.Equal(t, 2*time.Second, r.duration())
+}
+
+func Test_rampStepStats(t *testing.T) {
+	steps := []rateStep{{Rate: 2, Duration: time.Second}, {Rate: 4, Duration: time.Second}}
+	timings := []Datapoint{
+		{Duration: 10, Step: 0},
+		{Duration: 20, Step: 0},
+		{Duration: 5, Step: 1},
+	}
+
+	stats := rampStepStats(steps, timings, 1)
+
+	assert.Equal(t, []stepStats{
+		{rateStep: steps[0], Responses: 2, QueriesPerSecond: 2, MeanMs: 15, P99Ms: 20},
+		{rateStep: steps[1], Responses: 1, QueriesPerSecond: 1, MeanMs: 5, P99Ms: 5},
+	}, stats)
+}
@@ -0,0 +1,106 @@
+package dnsbench
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// rawRun is the configuration and the raw merged results of the run saved by Benchmark.SaveRaw, the datapoints and the histograms
+// are kept, so that the results of multiple runs can be merged and reported again.
+type rawRun struct {
+	Start       time.Time       `json:"start"`
+	Duration    time.Duration   `json:"durationNs"`
+	Interrupted bool            `json:"interrupted,omitempty"`
+	Config      json.RawMessage `json:"config"`
+	Stats       *remoteStats    `json:"stats"`
+}
+
+// RawResults are the results of the runs loaded by LoadRaw.
+type RawResults struct {
+	// Benchmark is the configuration of the first loaded run, the results are reported according to it.
+	Benchmark *Benchmark
+	Stats     []*ResultStats
+	// Duration is the time covered by the runs, overlapping runs like the runs of distributed workers are counted once
+	// and the gaps between the runs like between repeated trials are not counted.
+	Duration time.Duration
+	// Servers are the servers benchmarked by the runs in the order of the files.
+	Servers []string
+}
+
+// saveRaw saves the configuration and the merged results of the run, which took time t, to the JSON file.
+func (b *Benchmark) saveRaw(path string, stats *ResultStats, t time.Duration) error {
+	config, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to encode benchmark due to '%v'", err)
+	}
+	run := rawRun{
+		Start:       time.Now().Add(-t).UTC(),
+		Duration:    t,
+		Interrupted: b.interrupted,
+		Config:      config,
+		Stats:       toRemoteStats(stats),
+	}
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to encode results due to '%v'", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save raw results due to '%v'", err)
+	}
+	return nil
+}
+
+// LoadRaw loads the runs saved by Benchmark.SaveRaw, so that their results can be merged and reported using Benchmark.PrintReport.
+func LoadRaw(paths []string) (*RawResults, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no files with raw results provided")
+	}
+	res := &RawResults{}
+	intervals := make([][2]time.Time, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read raw results due to '%v'", err)
+		}
+		var run rawRun
+		if err := json.Unmarshal(data, &run); err != nil {
+			return nil, fmt.Errorf("failed to parse raw results '%s' due to '%v'", path, err)
+		}
+		if run.Stats == nil {
+			return nil, fmt.Errorf("no results found in '%s'", path)
+		}
+		b := &Benchmark{}
+		if err := json.Unmarshal(run.Config, b); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration of raw results '%s' due to '%v'", path, err)
+		}
+		if res.Benchmark == nil {
+			res.Benchmark = b
+		}
+		res.Servers = append(res.Servers, b.Server)
+		res.Stats = append(res.Stats, fromRemoteStats(run.Stats))
+		intervals = append(intervals, [2]time.Time{run.Start, run.Start.Add(run.Duration)})
+	}
+	res.Duration = coveredDuration(intervals)
+	return res, nil
+}
+
+// coveredDuration returns the length of the union of the time intervals.
+func coveredDuration(intervals [][2]time.Time) time.Duration {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i][0].Before(intervals[j][0]) })
+	var total time.Duration
+	var end time.Time
+	for _, in := range intervals {
+		if in[0].After(end) {
+			end = in[0]
+		}
+		if in[1].After(end) {
+			total += in[1].Sub(end)
+			end = in[1]
+		}
+	}
+	return total
+}
@@ -0,0 +1,71 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRaw(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "run1.json"), filepath.Join(dir, "run2.json")}
+	for _, f := range files {
+		bench := createBenchmark(s.Addr, false, 1)
+		bench.SaveRaw = f
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		rs, err := bench.Run(ctx)
+		cancel()
+		require.NoError(t, err, "expected no error from benchmark run")
+		require.NoError(t, bench.PrintReport(new(bytes.Buffer), rs, time.Second))
+	}
+
+	raw, err := LoadRaw(files)
+	require.NoError(t, err)
+
+	assert.Equal(t, s.Addr, raw.Benchmark.Server)
+	assert.Equal(t, []string{s.Addr, s.Addr}, raw.Servers)
+	require.Len(t, raw.Stats, 2)
+	assert.Greater(t, raw.Duration, time.Second, "the later run extends the covered time")
+	assert.LessOrEqual(t, raw.Duration, 2*time.Second)
+
+	merged := Merge(raw.Stats)
+	assert.Equal(t, int64(8), merged.Counters.Total)
+	assert.Equal(t, int64(8), merged.Hist.TotalCount())
+	assert.Len(t, merged.Timings, 8)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, raw.Benchmark.PrintReport(buf, raw.Stats, raw.Duration))
+	assert.Contains(t, buf.String(), "Total requests:\t\t8")
+}
+
+func TestLoadRaw_invalid(t *testing.T) {
+	_, err := LoadRaw(nil)
+	assert.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "run.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"config":{}}`), 0o644))
+	_, err = LoadRaw([]string{file})
+	assert.Error(t, err, "results are missing")
+
+	_, err = LoadRaw([]string{filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
+
+func Test_coveredDuration(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+
+	assert.Equal(t, 10*time.Second, coveredDuration([][2]time.Time{{at(0), at(10)}, {at(2), at(8)}}), "overlapping runs")
+	assert.Equal(t, 15*time.Second, coveredDuration([][2]time.Time{{at(20), at(25)}, {at(0), at(10)}}), "sequential runs")
+	assert.Equal(t, 12*time.Second, coveredDuration([][2]time.Time{{at(0), at(10)}, {at(5), at(12)}}), "partially overlapping runs")
+}
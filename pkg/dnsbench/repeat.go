@@ -0,0 +1,185 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// tCritical are the two-sided critical values of Student's t-distribution for 95% confidence by degrees of freedom starting at 1,
+// the normal approximation is used for more degrees of freedom.
+var tCritical = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// RunResult represents benchmark results of a single run, when the benchmark is repeated.
+type RunResult struct {
+	Stats    []*ResultStats
+	Duration time.Duration
+}
+
+// runStatistic is the mean, sample standard deviation and 95% confidence interval of the mean of a metric across the runs,
+// the lower bound of the interval is not below zero as none of the metrics can be negative.
+type runStatistic struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	CILow  float64 `json:"ci95Low"`
+	CIHigh float64 `json:"ci95High"`
+}
+
+func newRunStatistic(values []float64) runStatistic {
+	var s runStatistic
+	if len(values) == 0 {
+		return s
+	}
+	for _, v := range values {
+		s.Mean += v
+	}
+	s.Mean /= float64(len(values))
+	s.CILow, s.CIHigh = s.Mean, s.Mean
+	if len(values) < 2 {
+		return s
+	}
+	var sum float64
+	for _, v := range values {
+		sum += (v - s.Mean) * (v - s.Mean)
+	}
+	s.StdDev = math.Sqrt(sum / float64(len(values)-1))
+	t := 1.96
+	if df := len(values) - 1; df <= len(tCritical) {
+		t = tCritical[df-1]
+	}
+	margin := t * s.StdDev / math.Sqrt(float64(len(values)))
+	s.CILow, s.CIHigh = math.Max(s.Mean-margin, 0), s.Mean+margin
+	return s
+}
+
+type jsonRunStatistics struct {
+	QueriesPerSecond runStatistic `json:"queriesPerSecond"`
+	P50Ms            runStatistic `json:"p50Ms"`
+	P95Ms            runStatistic `json:"p95Ms"`
+	P99Ms            runStatistic `json:"p99Ms"`
+	ErrorRate        runStatistic `json:"errorRate"`
+}
+
+type jsonRunsResult struct {
+	Runs       []jsonSummary     `json:"runs"`
+	Statistics jsonRunStatistics `json:"statistics"`
+}
+
+// RunRepeated executes the benchmark the provided number of times one after another, waiting for cooldown between the runs.
+// If the benchmark is cancelled, the results of the runs executed so far are returned.
+func (b *Benchmark) RunRepeated(ctx context.Context, runs int, cooldown time.Duration) ([]RunResult, error) {
+	var results []RunResult
+	for i := 0; i < runs; i++ {
+		if i > 0 && cooldown > 0 {
+			if !b.Silent && !b.JSON {
+				fmt.Printf("Cooling down for %s before run %s\n", highlightStr(cooldown), highlightStr(i+1))
+			}
+			if err := waitUntil(ctx, time.Now().Add(cooldown)); err != nil {
+				break
+			}
+		}
+
+		bench := *b
+		start := time.Now()
+		stats, err := bench.Run(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute run %d: %w", i+1, err)
+		}
+		results = append(results, RunResult{Stats: stats, Duration: time.Since(start) - bench.warmupDuration})
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return results, nil
+}
+
+// runStatistics computes the statistics of throughput, latency percentiles and error rate across the runs.
+func runStatistics(summaries []jsonSummary, hists [][3]time.Duration) jsonRunStatistics {
+	qps := make([]float64, 0, len(summaries))
+	errorRates := make([]float64, 0, len(summaries))
+	var percentiles [3][]float64
+	for i, s := range summaries {
+		qps = append(qps, s.QueriesPerSecond)
+		errorRates = append(errorRates, s.ErrorRate)
+		for j := range percentiles {
+			percentiles[j] = append(percentiles[j], durationMs(hists[i][j]))
+		}
+	}
+	return jsonRunStatistics{
+		QueriesPerSecond: newRunStatistic(qps),
+		P50Ms:            newRunStatistic(percentiles[0]),
+		P95Ms:            newRunStatistic(percentiles[1]),
+		P99Ms:            newRunStatistic(percentiles[2]),
+		ErrorRate:        newRunStatistic(errorRates),
+	}
+}
+
+// PrintRepeated prints summary table of the repeated runs followed by mean, standard deviation and 95% confidence interval of the mean
+// of throughput, latency percentiles and error rate across the runs.
+func (b *Benchmark) PrintRepeated(w io.Writer, results []RunResult) error {
+	if b.Silent {
+		return nil
+	}
+
+	summaries := make([]jsonSummary, 0, len(results))
+	percentiles := make([][3]time.Duration, 0, len(results))
+	lines := make([][]string, 0, len(results))
+	for i, r := range results {
+		summary, row := summarize(r.Stats, r.Duration)
+		summaries = append(summaries, summary)
+		var p [3]time.Duration
+		if merged := Merge(r.Stats); merged.Hist != nil {
+			p = [3]time.Duration{
+				time.Duration(merged.Hist.ValueAtQuantile(50)), time.Duration(merged.Hist.ValueAtQuantile(95)), time.Duration(merged.Hist.ValueAtQuantile(99)),
+			}
+		}
+		percentiles = append(percentiles, p)
+		lines = append(lines, append([]string{strconv.Itoa(i + 1)}, row...))
+	}
+	stats := runStatistics(summaries, percentiles)
+
+	if b.JSON {
+		return json.NewEncoder(w).Encode(jsonRunsResult{Runs: summaries, Statistics: stats})
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Summary of", highlightStr(len(results)), "runs:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Run", "Requests", "QPS", "p50", "p95", "p99", "Errors", "Error rate"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+
+	ms := func(v float64) string { return roundDuration(time.Duration(v * float64(time.Millisecond))).String() }
+	rate := func(v float64) string { return fmt.Sprintf("%0.2f%%", v*100) }
+	qps := func(v float64) string { return fmt.Sprintf("%0.1f", v) }
+	row := func(metric string, s runStatistic, format func(float64) string) []string {
+		return []string{metric, format(s.Mean), format(s.StdDev), format(s.CILow) + " - " + format(s.CIHigh)}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Statistics across runs:")
+	table = tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Metric", "Mean", "Std dev", "95% CI"})
+	table.SetBorder(false)
+	table.AppendBulk([][]string{
+		row("QPS", stats.QueriesPerSecond, qps),
+		row("p50", stats.P50Ms, ms),
+		row("p95", stats.P95Ms, ms),
+		row("p99", stats.P99Ms, ms),
+		row("Error rate", stats.ErrorRate, rate),
+	})
+	table.Render()
+	return nil
+}
@@ -0,0 +1,147 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_RunRepeated(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 100)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	start := time.Now()
+	results, err := bench.RunRepeated(ctx, 3, 200*time.Millisecond)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, results, 3)
+	for _, r := range results {
+		assertResult(t, r.Stats)
+		assert.NotZero(t, r.Duration)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 3*100*time.Millisecond+2*200*time.Millisecond)
+}
+
+func TestBenchmark_RunRepeated_cancelled(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	results, err := bench.RunRepeated(ctx, 3, time.Minute)
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, int64(4), Merge(results[0].Stats).Counters.Total)
+}
+
+func TestNewRunStatistic(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   runStatistic
+	}{
+		{
+			name: "no values",
+		},
+		{
+			name:   "single value",
+			values: []float64{10},
+			want:   runStatistic{Mean: 10, CILow: 10, CIHigh: 10},
+		},
+		{
+			name:   "two values",
+			values: []float64{9, 11},
+			want:   runStatistic{Mean: 10, StdDev: 1.4142, CIHigh: 22.706},
+		},
+		{
+			name:   "five values",
+			values: []float64{98, 99, 100, 101, 102},
+			want:   runStatistic{Mean: 100, StdDev: 1.5811, CILow: 98.0368, CIHigh: 101.9632},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newRunStatistic(tt.values)
+
+			assert.InDelta(t, tt.want.Mean, got.Mean, 0.001)
+			assert.InDelta(t, tt.want.StdDev, got.StdDev, 0.001)
+			assert.InDelta(t, tt.want.CILow, got.CILow, 0.001)
+			assert.InDelta(t, tt.want.CIHigh, got.CIHigh, 0.001)
+		})
+	}
+}
+
+func TestBenchmark_PrintRepeated(t *testing.T) {
+	b := Benchmark{}
+	var buf bytes.Buffer
+
+	err := b.PrintRepeated(&buf, repeatedTestData())
+
+	require.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "Summary of 2 runs:")
+	assert.Regexp(t, `1 \|\s+2 \| 2\.0 \| 10\.\d+ms \| 10\.\d+ms \| 10\.\d+ms \|\s+1 \| 50\.00%`, out)
+	assert.Regexp(t, `2 \|\s+4 \| 4\.0 \| 20\.\d+ms \| 20\.\d+ms \| 20\.\d+ms \|\s+0 \| 0\.00%`, out)
+	assert.Contains(t, out, "Statistics across runs:")
+	assert.Regexp(t, `QPS\s+\|\s+3\.0 \|\s+1\.4 \| 0\.0 - 15\.7`, out)
+	assert.Regexp(t, `Error rate \| 25\.00%\s+\| 35\.36%\s+\| 0\.00% - 342\.65%`, out)
+}
+
+func TestBenchmark_PrintRepeated_json(t *testing.T) {
+	b := Benchmark{JSON: true}
+	var buf bytes.Buffer
+
+	err := b.PrintRepeated(&buf, repeatedTestData())
+
+	require.NoError(t, err)
+	var res jsonRunsResult
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	require.Len(t, res.Runs, 2)
+	assert.Equal(t, int64(2), res.Runs[0].TotalRequests)
+	assert.Equal(t, int64(4), res.Runs[1].TotalRequests)
+	assert.InDelta(t, 3, res.Statistics.QueriesPerSecond.Mean, 0.001)
+	assert.InDelta(t, 1.4142, res.Statistics.QueriesPerSecond.StdDev, 0.001)
+	assert.InDelta(t, 15, res.Statistics.P50Ms.Mean, 1)
+	assert.InDelta(t, 0.25, res.Statistics.ErrorRate.Mean, 0.001)
+}
+
+func repeatedTestData() []RunResult {
+	h1 := hdrhistogram.New(0, int64(time.Second), 1)
+	h1.RecordValue(int64(10 * time.Millisecond))
+	h2 := hdrhistogram.New(0, int64(time.Second), 1)
+	h2.RecordValue(int64(20 * time.Millisecond))
+
+	return []RunResult{
+		{
+			Stats:    []*ResultStats{{Hist: h1, Counters: &Counters{Total: 2, IOError: 1, Success: 1}}},
+			Duration: time.Second,
+		},
+		{
+			Stats:    []*ResultStats{{Hist: h2, Counters: &Counters{Total: 4, Success: 4}}},
+			Duration: time.Second,
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package dnsbench
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// replaySchedule dispatches the captured queries to the workers in the order in which they were captured and at the times
+// they were captured relative to the start of the replay, optionally speeded up or slowed down. The capture is replayed repeatedly,
+// each repetition starts after the previous one. The schedule is safe for concurrent use.
+type replaySchedule struct {
+	start   time.Time
+	offsets []time.Duration
+	// round is duration of single replay of the capture.
+	round time.Duration
+	speed float64
+	// rounds is number of replays of the capture, 0 means unlimited.
+	rounds int64
+	next   atomic.Int64
+}
+
+func (b *Benchmark) newReplaySchedule(start time.Time) *replaySchedule {
+	s := replaySchedule{start: start, speed: b.ReplaySpeed, rounds: b.Count}
+	for _, q := range b.capture {
+		s.offsets = append(s.offsets, q.offset)
+	}
+	if n := len(s.offsets); n > 1 {
+		last := s.offsets[n-1]
+		// the next replay starts after the mean interval between the captured queries
+		s.round = last + last/time.Duration(n-1)
+	}
+	return &s
+}
+
+// take returns index of the next captured query to be sent and the time at which it should be sent,
+// false is returned when all the replays of the capture were dispatched.
+func (s *replaySchedule) take() (int, time.Time, bool) {
+	n := s.next.Add(1) - 1
+	round, qi := n/int64(len(s.offsets)), int(n%int64(len(s.offsets)))
+	if s.rounds > 0 && round >= s.rounds {
+		return 0, time.Time{}, false
+	}
+	offset := time.Duration(round)*s.round + s.offsets[qi]
+	return qi, s.start.Add(time.Duration(float64(offset) / s.speed)), true
+}
+
+// captureSource returns pcap file or dnstap source of the replayed queries, empty string is returned if the queries are not replayed.
+func (b *Benchmark) captureSource() string {
+	if b.Dnstap != "" {
+		return b.Dnstap
+	}
+	return b.Pcap
+}
+
+// capturedOpt returns EDNS0 record of the captured query with the given index, nil is returned when the queries are not replayed
+// from the capture or the captured query did not use EDNS0.
+func (b *Benchmark) capturedOpt(qi int) *dns.OPT {
+	if qi >= len(b.capture) {
+		return nil
+	}
+	return b.capture[qi].opt
+}
@@ -0,0 +1,55 @@
+package dnsbench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_replaySchedule_take(t *testing.T) {
+	start := time.Now()
+	b := Benchmark{
+		Count:       2,
+		ReplaySpeed: 2,
+		capture: []capturedQuery{
+			{offset: 0},
+			{offset: 100 * time.Millisecond},
+			{offset: 200 * time.Millisecond},
+		},
+	}
+	s := b.newReplaySchedule(start)
+
+	want := []struct {
+		qi int
+		at time.Duration
+	}{
+		{qi: 0, at: 0},
+		{qi: 1, at: 50 * time.Millisecond},
+		{qi: 2, at: 100 * time.Millisecond},
+		// the second replay starts after the mean interval between the captured queries
+		{qi: 0, at: 150 * time.Millisecond},
+		{qi: 1, at: 200 * time.Millisecond},
+		{qi: 2, at: 250 * time.Millisecond},
+	}
+	for _, w := range want {
+		qi, at, ok := s.take()
+		assert.True(t, ok)
+		assert.Equal(t, w.qi, qi)
+		assert.Equal(t, start.Add(w.at), at)
+	}
+
+	_, _, ok := s.take()
+	assert.False(t, ok, "expected the schedule to end after two replays")
+}
+
+func TestBenchmark_capturedOpt(t *testing.T) {
+	b := Benchmark{}
+	assert.Nil(t, b.capturedOpt(0))
+
+	opt := &dns.OPT{}
+	b.capture = []capturedQuery{{}, {opt: opt}}
+	assert.Nil(t, b.capturedOpt(0))
+	assert.Same(t, opt, b.capturedOpt(1))
+}
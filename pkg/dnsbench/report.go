@@ -0,0 +1,190 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/fatih/color"
+)
+
+var (
+	errPrint     = color.New(color.FgRed).FprintfFunc()
+	successPrint = color.New(color.FgGreen).FprintfFunc()
+	highlightStr = color.New(color.FgYellow).SprintFunc()
+)
+
+type orderedMap struct {
+	m     map[string]int
+	order []string
+}
+
+// PrintReport print formatted benchmark results to stdout. If there is a fatal error while printing report, an error is returned.
+func (b *Benchmark) PrintReport(w io.Writer, stats []*ResultStats, t time.Duration) error {
+	merged := Merge(stats)
+	if b.WorkerStats {
+		merged.workers = summarizeWorkers(stats, t)
+	}
+
+	if merged.Hist == nil {
+		merged.Hist = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+	}
+	if merged.Codes == nil {
+		merged.Codes = make(map[int]int64)
+	}
+	if b.DiffServer != "" {
+		if merged.Diff == nil {
+			merged.Diff = &ResultStats{Counters: &Counters{}}
+		}
+		if merged.Diff.Hist == nil {
+			merged.Diff.Hist = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+		}
+	}
+
+	errs := make(map[string]int, 0)
+	top3errs := make(map[string]int)
+	top3errorsInOrder := make([]string, 0)
+
+	for _, err := range merged.Errors {
+		errs[err.Error()]++
+	}
+
+	for i := 0; i < 3; i++ {
+		max := 0
+		maxerr := ""
+		for k, v := range errs {
+			if _, ok := top3errs[k]; v > max && !ok {
+				maxerr = k
+				max = v
+			}
+		}
+		if max != 0 {
+			top3errs[maxerr] = max
+			top3errorsInOrder = append(top3errorsInOrder, maxerr)
+		}
+	}
+
+	if len(b.PlotDir) != 0 {
+		b.plot(b.PlotDir, merged)
+	}
+
+	if b.Csv != "" {
+		if err := b.exportCsv(b.Csv, merged.Hist); err != nil {
+			return err
+		}
+	}
+
+	if b.HistExport != "" {
+		if err := writeHgrm(b.HistExport, merged.Hist); err != nil {
+			return err
+		}
+	}
+
+	if b.HistLog != "" {
+		if err := writeHistLog(b.HistLog, merged.IntervalHists, b.HistLogInterval); err != nil {
+			return err
+		}
+	}
+
+	if b.ReportCsv != "" {
+		if err := b.writeReportCsv(b.ReportCsv, merged, t); err != nil {
+			return err
+		}
+	}
+
+	if b.Store != "" {
+		if err := b.storeRun(b.Store, merged, t); err != nil {
+			return err
+		}
+	}
+
+	if b.SaveRaw != "" {
+		if err := b.saveRaw(b.SaveRaw, merged, t); err != nil {
+			return err
+		}
+	}
+
+	if b.SeriesCsv != "" {
+		if err := writeSeriesCsv(b.SeriesCsv, timeSeries(merged.Timings, merged.ErrorTimes, b.SeriesInterval, merged.datapointWeight())); err != nil {
+			return err
+		}
+	}
+
+	if b.Silent {
+		return nil
+	}
+	topErrs := orderedMap{m: top3errs, order: top3errorsInOrder}
+	if b.JSON {
+		j := jsonReporter{}
+		return j.print(w, b, merged, topErrs, t)
+	}
+	s := standardReporter{}
+	return s.print(w, b, merged, topErrs, t)
+}
+
+// plot saves the plots of the results into a new graphs directory in the plotDir.
+func (b *Benchmark) plot(plotDir string, merged *ResultStats) {
+	now := time.Now().Format(time.RFC3339)
+	dir := fmt.Sprintf("%s/graphs-%s", plotDir, now)
+	if err := os.Mkdir(dir, os.ModePerm); err != nil {
+		panic(err)
+	}
+	plots := []struct {
+		name string
+		plot func(file string) error
+	}{
+		{"latency-histogram", func(file string) error { return plotHistogramLatency(file, merged.Timings, merged.datapointWeight()) }},
+		{"latency-boxplot", func(file string) error { return plotBoxPlotLatency(file, b.Server, merged.Timings) }},
+		{"responses-barchart", func(file string) error { return plotResponses(file, merged.Codes) }},
+		{"throughput-lineplot", func(file string) error {
+			return plotLineThroughput(file, merged.Timings, merged.ErrorTimes, merged.datapointWeight())
+		}},
+		{"latency-lineplot", func(file string) error { return plotLineLatencies(file, merged.Timings) }},
+		{"errorrate-lineplot", func(file string) error { return plotLineErrorRate(file, merged.Timings, merged.ErrorTimes) }},
+		{"responses-lineplot", func(file string) error { return plotLineResponses(file, merged.Timings, merged.datapointWeight()) }},
+		{"latency-heatmap", func(file string) error { return plotHeatmapLatency(file, merged.Timings) }},
+	}
+	if b.PlotFormat == htmlFormat {
+		// the interactive charts are exported into a single file instead of the images
+		plots = []struct {
+			name string
+			plot func(file string) error
+		}{
+			{"graphs", func(file string) error {
+				return plotHTML(file, b.Server, merged.Timings, merged.ErrorTimes, merged.datapointWeight())
+			}},
+		}
+	}
+	for _, p := range plots {
+		file := b.fileName(dir, p.name)
+		if err := p.plot(file); err != nil {
+			b.log.warn("failed to save plot", "file", file, "err", err)
+		}
+	}
+}
+
+// exportCsv writes the latency distribution of the histogram into the CSV file.
+func (b *Benchmark) exportCsv(file string, hist *hdrhistogram.Histogram) error {
+	csv, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create file for CSV export due to '%v'", err)
+	}
+	defer csv.Close()
+
+	writeBars(csv, b.distribution(hist))
+	return nil
+}
+
+func (b *Benchmark) fileName(dir, name string) string {
+	return dir + "/" + name + "." + b.PlotFormat
+}
+
+func writeBars(f *os.File, bars []hdrhistogram.Bar) {
+	f.WriteString("From (ns), To (ns), Count\n")
+
+	for _, b := range bars {
+		f.WriteString(b.String())
+	}
+}
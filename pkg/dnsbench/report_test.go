@@ -0,0 +1,124 @@
+package dnsbench
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Example_standard_printReport() {
+	b, rs := testData()
+
+	b.PrintReport(os.Stdout, []*ResultStats{&rs}, time.Second)
+
+	// Output: Total requests:		1
+	// Read/Write errors:	3
+	// ID mismatch errors:	6
+	// DNS success codes:	4
+	// Truncated responses:	7
+	//
+	// DNS response codes:
+	//	NOERROR:	2
+	//
+	// DNS question types:
+	//	A:	2
+	//
+	// Time taken for tests:	 1s
+	// Questions per second:	 1.0
+	// DNS timings, 2 datapoints
+	//	 min:		 5ns
+	//	 mean:		 7ns
+	//	 [+/-sd]:	 2ns
+	//	 max:		 10ns
+	//	 p99:		 10ns
+	//	 p95:		 10ns
+	//	 p90:		 10ns
+	//	 p75:		 10ns
+	//	 p50:		 5ns
+	//
+	// Total Errors: 3
+	// Top errors:
+	// test	2 (66.67)%
+	// test2	1 (33.33)%
+}
+
+func Example_json_printReport() {
+	b, rs := testData()
+	b.JSON = true
+	b.Rcodes = true
+	b.HistDisplay = true
+
+	b.PrintReport(os.Stdout, []*ResultStats{&rs}, time.Second)
+
+	// Output: {"totalRequests":1,"totalSuccessCodes":4,"totalErrors":3,"topErrors":[{"error":"test","count":2},{"error":"test2","count":1}],"TotalIDmismatch":6,"totalTruncatedResponses":7,"responseRcodes":{"NOERROR":2},"questionTypes":{"A":2},"queriesPerSecond":1,"benchmarkDurationSeconds":1,"latencyStats":{"minMs":0,"meanMs":0,"stdMs":0,"maxMs":0,"p99Ms":0,"p95Ms":0,"p90Ms":0,"p75Ms":0,"p50Ms":0},"latencyDistribution":[{"latencyMs":0,"count":0},{"latencyMs":0,"count":0},{"latencyMs":0,"count":0},{"latencyMs":0,"count":0},{"latencyMs":0,"count":0},{"latencyMs":0,"count":1},{"latencyMs":0,"count":0},{"latencyMs":0,"count":0},{"latencyMs":0,"count":0},{"latencyMs":0,"count":0},{"latencyMs":0,"count":1}]}
+}
+
+func TestBenchmark_PrintReport_interrupted(t *testing.T) {
+	b, rs := testData()
+	b.interrupted = true
+
+	buf := bytes.Buffer{}
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.Contains(t, buf.String(), "Benchmark was interrupted, reporting results collected so far.")
+
+	b.JSON = true
+	buf.Reset()
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.Contains(t, buf.String(), `{"interrupted":true,"totalRequests":1,`)
+}
+
+func TestBenchmark_PrintReport_timeouts(t *testing.T) {
+	b, rs := testData()
+	rs.Counters.Timeouts = 2
+
+	buf := bytes.Buffer{}
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.Contains(t, buf.String(), "Read/Write errors:\t3\nTimeouts:\t\t2\n")
+
+	b.JSON = true
+	buf.Reset()
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	assert.Contains(t, buf.String(), `"totalErrors":3,"totalTimeouts":2,`)
+}
+
+func testData() (Benchmark, ResultStats) {
+	b := Benchmark{
+		HistPre: 1,
+	}
+
+	h := hdrhistogram.New(0, 0, 1)
+	h.RecordValue(5)
+	h.RecordValue(10)
+	d1 := Datapoint{Duration: 5, Start: time.Unix(0, 0)}
+	d2 := Datapoint{Duration: 10, Start: time.Unix(0, 0)}
+	rs := ResultStats{
+		Codes: map[int]int64{
+			dns.RcodeSuccess: 2,
+		},
+		Qtypes: map[string]int64{
+			"A": 2,
+		},
+		Hist:    h,
+		Timings: []Datapoint{d1, d2},
+		Counters: &Counters{
+			Total:      1,
+			IOError:    3,
+			Success:    4,
+			IDmismatch: 6,
+			Truncated:  7,
+		},
+		Errors: []error{
+			errors.New("test"),
+			errors.New("test2"),
+			errors.New("test"),
+		},
+	}
+	return b, rs
+}
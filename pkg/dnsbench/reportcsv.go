@@ -0,0 +1,91 @@
+package dnsbench
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// reportCsvHeader are the columns of the summary of the run appended to Benchmark.ReportCsv, the columns are fixed,
+// so that the rows of different runs can be appended to the same file.
+var reportCsvHeader = []string{
+	"Time", "Server", "Concurrency", "Duration (s)", "Requests", "Success", "Errors", "Timeouts", "ID mismatch", "Truncated",
+	"QPS", "Min (ms)", "Mean (ms)", "P50 (ms)", "P90 (ms)", "P95 (ms)", "P99 (ms)", "Max (ms)",
+	"NOERROR", "NXDOMAIN", "SERVFAIL", "REFUSED", "Other rcodes", "Interrupted",
+}
+
+// writeReportCsv appends one row summarizing the results of the run to the file, the header is written only when the file is empty.
+func (b *Benchmark) writeReportCsv(file string, stats *ResultStats, t time.Duration) error {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open file for report CSV export due to '%v'", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open file for report CSV export due to '%v'", err)
+	}
+
+	w := csv.NewWriter(f)
+	if info.Size() == 0 {
+		w.Write(reportCsvHeader)
+	}
+	w.Write(b.reportCsvRow(stats, t, time.Now()))
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write report CSV export due to '%v'", err)
+	}
+	return nil
+}
+
+func (b *Benchmark) reportCsvRow(stats *ResultStats, t time.Duration, now time.Time) []string {
+	c := stats.Counters
+	ms := func(q float64) string {
+		return strconv.FormatFloat(float64(stats.Hist.ValueAtQuantile(q))/float64(time.Millisecond), 'f', 3, 64)
+	}
+	var qps float64
+	if t > 0 {
+		qps = float64(c.Total) / t.Seconds()
+	}
+
+	var other int64
+	for k, v := range stats.Codes {
+		switch k {
+		case dns.RcodeSuccess, dns.RcodeNameError, dns.RcodeServerFailure, dns.RcodeRefused:
+		default:
+			other += v
+		}
+	}
+
+	return []string{
+		now.Format(time.RFC3339),
+		b.Server,
+		strconv.FormatUint(uint64(b.Concurrency), 10),
+		strconv.FormatFloat(t.Seconds(), 'f', 3, 64),
+		strconv.FormatInt(c.Total, 10),
+		strconv.FormatInt(c.Success, 10),
+		strconv.FormatInt(c.IOError, 10),
+		strconv.FormatInt(c.Timeouts, 10),
+		strconv.FormatInt(c.IDmismatch, 10),
+		strconv.FormatInt(c.Truncated, 10),
+		strconv.FormatFloat(qps, 'f', 2, 64),
+		strconv.FormatFloat(float64(stats.Hist.Min())/float64(time.Millisecond), 'f', 3, 64),
+		strconv.FormatFloat(stats.Hist.Mean()/float64(time.Millisecond), 'f', 3, 64),
+		ms(50),
+		ms(90),
+		ms(95),
+		ms(99),
+		strconv.FormatFloat(float64(stats.Hist.Max())/float64(time.Millisecond), 'f', 3, 64),
+		strconv.FormatInt(stats.Codes[dns.RcodeSuccess], 10),
+		strconv.FormatInt(stats.Codes[dns.RcodeNameError], 10),
+		strconv.FormatInt(stats.Codes[dns.RcodeServerFailure], 10),
+		strconv.FormatInt(stats.Codes[dns.RcodeRefused], 10),
+		strconv.FormatInt(other, 10),
+		strconv.FormatBool(b.interrupted),
+	}
+}
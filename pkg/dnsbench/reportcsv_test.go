@@ -0,0 +1,57 @@
+package dnsbench
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_PrintReport_reportCsv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.csv")
+	b, rs := testData()
+	b.Server = "127.0.0.1:53"
+	b.Concurrency = 2
+	b.Silent = true
+	b.ReportCsv = file
+
+	// rows of consecutive runs are appended to the same file
+	require.NoError(t, b.PrintReport(io.Discard, []*ResultStats{&rs}, time.Second))
+	rs.Codes[dns.RcodeServerFailure] = 1
+	rs.Codes[dns.RcodeNotImplemented] = 2
+	require.NoError(t, b.PrintReport(io.Discard, []*ResultStats{&rs}, 2*time.Second))
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, reportCsvHeader, rows[0])
+	assert.Equal(t, []string{"127.0.0.1:53", "2", "1.000", "1", "4", "3", "0", "6", "7", "1.00"}, rows[1][1:11])
+	assert.Equal(t, []string{"2", "0", "0", "0", "0", "false"}, rows[1][18:])
+	assert.Equal(t, []string{"2.000"}, rows[2][3:4])
+	assert.Equal(t, []string{"2", "0", "1", "0", "2", "false"}, rows[2][18:])
+}
+
+func TestBenchmark_reportCsvRow_latencies(t *testing.T) {
+	h := hdrhistogram.New(1, int64(time.Second), 3)
+	for i := 1; i <= 100; i++ {
+		h.RecordValue(int64(i) * int64(time.Millisecond))
+	}
+	b := Benchmark{Server: "8.8.8.8"}
+	rs := ResultStats{Hist: h, Counters: &Counters{Total: 100}}
+
+	row := b.reportCsvRow(&rs, 10*time.Second, time.Unix(0, 0).UTC())
+	require.Len(t, row, len(reportCsvHeader))
+	assert.Equal(t, "1970-01-01T00:00:00Z", row[0])
+	assert.Equal(t, "10.00", row[10])
+	assert.Equal(t, []string{"1.000", "50.499", "50.004", "90.046", "95.027", "99.025", "100.008"}, row[11:18])
+}
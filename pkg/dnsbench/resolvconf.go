@@ -0,0 +1,56 @@
+package dnsbench
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SystemServer is a value of Benchmark.Server, which is replaced by the nameservers configured in the system, see SystemResolvers.
+const SystemServer = "system"
+
+// resolvConfPath is the path of the resolver configuration on systems other than Windows.
+const resolvConfPath = "/etc/resolv.conf"
+
+// SystemConfig is a configuration of the system resolvers.
+type SystemConfig struct {
+	// Servers are the configured nameservers in format IP:port in the order of preference.
+	Servers []string
+	// Search is a list of search domains, relative names with fewer than Ndots dots are qualified with the first one.
+	Search []string
+	Ndots  int
+}
+
+// loadResolvConf reads the configuration of the system resolvers from the file in resolv.conf format.
+func loadResolvConf(path string) (*SystemConfig, error) {
+	conf, err := dns.ClientConfigFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc := &SystemConfig{Search: conf.Search, Ndots: conf.Ndots}
+	for _, s := range conf.Servers {
+		sc.Servers = append(sc.Servers, net.JoinHostPort(s, conf.Port))
+	}
+	if len(sc.Servers) == 0 {
+		return nil, errors.New("no nameservers configured in " + path)
+	}
+	return sc, nil
+}
+
+// qualify returns fully qualified name, relative names with fewer dots than Benchmark.Ndots are qualified with the first search domain,
+// the same way stub resolvers try the search domains first. Relative names looked up with the search domains are kept and recorded,
+// see Benchmark.SearchExpansion.
+func (b *Benchmark) qualify(name string) string {
+	if b.relativeNames != nil {
+		if !dns.IsFqdn(name) {
+			b.relativeNames[dns.Fqdn(name)] = true
+		}
+		return dns.Fqdn(name)
+	}
+	if len(b.SearchDomains) == 0 || dns.IsFqdn(name) || strings.Count(name, ".") >= b.Ndots {
+		return dns.Fqdn(name)
+	}
+	return dns.Fqdn(name + "." + strings.Trim(b.SearchDomains[0], "."))
+}
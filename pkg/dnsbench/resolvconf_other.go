@@ -0,0 +1,8 @@
+//go:build !windows
+
+package dnsbench
+
+// SystemResolvers returns the configuration of the system resolvers read from /etc/resolv.conf.
+func SystemResolvers() (*SystemConfig, error) {
+	return loadResolvConf(resolvConfPath)
+}
@@ -0,0 +1,39 @@
+package dnsbench
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadResolvConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(t, os.WriteFile(path, []byte("# generated\nnameserver 10.0.0.1\nnameserver fd00::1\nsearch corp.example example.org\noptions ndots:2 timeout:1\n"), 0o600))
+
+	conf, err := loadResolvConf(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:53", "[fd00::1]:53"}, conf.Servers)
+	assert.Equal(t, []string{"corp.example", "example.org"}, conf.Search)
+	assert.Equal(t, 2, conf.Ndots)
+
+	require.NoError(t, os.WriteFile(path, []byte("search corp.example\n"), 0o600))
+	_, err = loadResolvConf(path)
+	assert.Error(t, err, "no nameservers")
+
+	_, err = loadResolvConf(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestBenchmark_qualify(t *testing.T) {
+	b := Benchmark{SearchDomains: []string{"corp.example"}, Ndots: 2}
+	assert.Equal(t, "intranet.corp.example.", b.qualify("intranet"))
+	assert.Equal(t, "www.intranet.corp.example.", b.qualify("www.intranet"))
+	assert.Equal(t, "www.example.org.", b.qualify("www.example.org"), "names with enough dots are not qualified")
+	assert.Equal(t, "intranet.", b.qualify("intranet."), "fully qualified names are not qualified")
+
+	b = Benchmark{}
+	assert.Equal(t, "intranet.", b.qualify("intranet"))
+}
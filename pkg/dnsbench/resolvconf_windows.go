@@ -0,0 +1,53 @@
+package dnsbench
+
+import (
+	"errors"
+	"net"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// SystemResolvers returns the configuration of the system resolvers, the nameservers and DNS suffixes of the network adapters which are up.
+func SystemResolvers() (*SystemConfig, error) {
+	size := uint32(15000)
+	var buf []byte
+	for {
+		buf = make([]byte, size)
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, windows.GAA_FLAG_INCLUDE_PREFIX, 0, (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])), &size)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, windows.ERROR_BUFFER_OVERFLOW) || size <= uint32(len(buf)) {
+			return nil, os.NewSyscallError("getadaptersaddresses", err)
+		}
+	}
+
+	sc := &SystemConfig{Ndots: 1}
+	seen := make(map[string]bool)
+	for aa := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); aa != nil; aa = aa.Next {
+		if aa.OperStatus != windows.IfOperStatusUp {
+			continue
+		}
+		for d := aa.FirstDnsServerAddress; d != nil; d = d.Next {
+			ip := d.Address.IP()
+			// deprecated site-local addresses are configured by default on adapters without DNS servers
+			if ip == nil || (ip.To4() == nil && ip[0] == 0xfe && ip[1]&0xc0 == 0xc0) {
+				continue
+			}
+			addr := net.JoinHostPort(ip.String(), "53")
+			if !seen[addr] {
+				seen[addr] = true
+				sc.Servers = append(sc.Servers, addr)
+			}
+		}
+		if suffix := windows.UTF16PtrToString(aa.DnsSuffix); suffix != "" {
+			sc.Search = append(sc.Search, suffix)
+		}
+	}
+	if len(sc.Servers) == 0 {
+		return nil, errors.New("no nameservers configured")
+	}
+	return sc, nil
+}
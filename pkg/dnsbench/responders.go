@@ -0,0 +1,78 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+// peerConn is unconnected UDP socket sending the queries to the server and receiving the responses from any address, the source address
+// of the last received response is remembered. Connected sockets drop the responses from other addresses than the server in the kernel,
+// so the responses of misconfigured servers or anycast instances answering from different address would not be seen at all.
+type peerConn struct {
+	*net.UDPConn
+	server *net.UDPAddr
+	peer   *net.UDPAddr
+}
+
+// dialResponders opens unconnected UDP socket for sending the queries to the server, see Benchmark.Responders.
+func (b *Benchmark) dialResponders(network, server string) (*dns.Conn, error) {
+	raddr, err := net.ResolveUDPAddr(network, server)
+	if err != nil {
+		return nil, err
+	}
+	laddr, _ := b.source.addr(network).(*net.UDPAddr)
+	conn, err := net.ListenUDP(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	return &dns.Conn{Conn: &peerConn{UDPConn: conn, server: raddr}}, nil
+}
+
+func (c *peerConn) Read(p []byte) (int, error) {
+	n, addr, err := c.ReadFromUDP(p)
+	if err == nil {
+		c.peer = addr
+	}
+	return n, err
+}
+
+func (c *peerConn) Write(p []byte) (int, error) {
+	return c.WriteToUDP(p, c.server)
+}
+
+func (c *peerConn) RemoteAddr() net.Addr {
+	return c.server
+}
+
+// unexpected returns whether the last response arrived from other address than the one the query was sent to.
+func (c *peerConn) unexpected() bool {
+	return c.peer != nil && (!c.peer.IP.Equal(c.server.IP) || c.peer.Port != c.server.Port)
+}
+
+// setResponder remembers the source address of the response received over the connection, with which the latency of the response is recorded,
+// the responses arriving from other address than the server are counted.
+func (rs *ResultStats) setResponder(co *dns.Conn) {
+	pc, ok := co.Conn.(*peerConn)
+	if !ok || pc.peer == nil {
+		return
+	}
+	rs.responder = pc.peer.String()
+	if pc.unexpected() {
+		rs.Counters.UnexpectedSources++
+	}
+}
+
+func printResponders(w io.Writer, hists map[string]*hdrhistogram.Histogram) {
+	if len(hists) == 1 {
+		for k := range hists {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "All responses were received from address", highlightStr(k))
+		}
+		return
+	}
+	printBreakdown(w, "DNS timings by responding address:", "Address", hists)
+}
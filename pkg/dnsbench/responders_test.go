@@ -0,0 +1,101 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_Run_responders(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Responders = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.Len(t, merged.ResponderHists, 1)
+	assert.Equal(t, int64(4), merged.ResponderHists[s.Addr].TotalCount())
+	assert.Zero(t, merged.Counters.UnexpectedSources)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "All responses were received from address "+s.Addr)
+}
+
+func TestBenchmark_Run_responders_unexpected_source(t *testing.T) {
+	listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer listener.Close()
+	// the responses are sent from other socket than the one receiving the queries
+	responder, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer responder.Close()
+	go func() {
+		buf := make([]byte, dns.MaxMsgSize)
+		for {
+			n, addr, err := listener.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req := new(dns.Msg)
+			if err := req.Unpack(buf[:n]); err != nil {
+				continue
+			}
+			ret := new(dns.Msg)
+			ret.SetReply(req)
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+			out, err := ret.Pack()
+			if err != nil {
+				continue
+			}
+			responder.WriteToUDP(out, addr)
+		}
+	}()
+
+	bench := createBenchmark(listener.LocalAddr().String(), false, 1)
+	bench.Responders = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.Success)
+	assert.Equal(t, int64(4), merged.Counters.UnexpectedSources)
+	require.Len(t, merged.ResponderHists, 1)
+	assert.Equal(t, int64(4), merged.ResponderHists[responder.LocalAddr().String()].TotalCount())
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "Unexpected sources:\t4")
+}
+
+func TestBenchmark_normalize_responders(t *testing.T) {
+	tests := []struct {
+		name  string
+		bench Benchmark
+	}{
+		{name: "tcp", bench: Benchmark{Server: "8.8.8.8", Responders: true, TCP: true}},
+		{name: "dot", bench: Benchmark{Server: "8.8.8.8", Responders: true, DOT: true}},
+		{name: "doh", bench: Benchmark{Server: "https://1.1.1.1/dns-query", Responders: true}},
+		{name: "batch", bench: Benchmark{Server: "8.8.8.8", Responders: true, Batch: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.bench.normalize())
+		})
+	}
+}
@@ -0,0 +1,665 @@
+package dnsbench
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+// Counters represents various counters of benchmark results.
+type Counters struct {
+	Total      int64
+	IOError    int64
+	Success    int64
+	IDmismatch int64
+	Truncated  int64
+
+	// Timeouts counts queries failed due to exceeded request timeout or read or write deadline, they are counted in IOError as well.
+	Timeouts int64
+
+	// Retries counts retries of queries failed due to I/O errors or timeouts, see Benchmark.Retries.
+	Retries int64
+
+	// TCPRetries counts queries retried over TCP due to truncated UDP response, see Benchmark.RetryTruncated.
+	TCPRetries int64
+
+	// StubCacheHits counts queries answered from the cache of simulated stub resolver without being sent, they are not counted in Total,
+	// CNAMEChases counts queries sent to follow CNAME records, see Benchmark.SimulateStub and Benchmark.FollowCNAMEs.
+	StubCacheHits int64
+	CNAMEChases   int64
+
+	// NXDomainQueries counts queries for generated nonexistent names, see Benchmark.NXDomainRatio.
+	NXDomainQueries int64
+
+	// UniqueQuestions counts queries asking the question for the first time in the benchmark, see Benchmark.CacheModel.
+	UniqueQuestions int64
+
+	// UnexpectedSources counts UDP responses received from other address than the one the query was sent to, which may be a sign
+	// of misconfigured server or spoofed responses, see Benchmark.Responders.
+	UnexpectedSources int64
+
+	// MalformedQueries counts malformed queries sent instead of the regular queries, they are not counted in Total, see Benchmark.MalformedRate.
+	MalformedQueries int64
+
+	// ServerCloses counts persistent connections closed by the server, the queries sent over them are resent over new connections,
+	// IdleCloses counts connections closed by the client, because they were idle for too long, see Benchmark.IdleTimeout and Benchmark.EDNSTCPKeepalive.
+	ServerCloses int64
+	IdleCloses   int64
+
+	// Failovers counts queries sent to the next server, because the previous server did not answer in time, see Benchmark.FailoverServers.
+	Failovers int64
+
+	// UDPRetransmits counts UDP queries retransmitted with fresh message ID and LateResponses counts responses to the retransmitted queries,
+	// which answered the message sent before the last retransmit, see Benchmark.UDPRetry.
+	UDPRetransmits int64
+	LateResponses  int64
+
+	// DuplicateResponses counts UDP responses to the queries already answered and ExpiredResponses counts UDP responses to the queries,
+	// which timed out, they are received while waiting for the response to the following queries over the same socket and they are skipped.
+	// UDP responses to none of the recent queries are counted in IDmismatch.
+	DuplicateResponses int64
+	ExpiredResponses   int64
+
+	// InjectedDelays and InjectedDrops count query attempts delayed and dropped by the client to simulate degraded network,
+	// InjectedFailures counts queries failed due to the dropped attempts, they are not counted in IOError, see Benchmark.DropRate and Benchmark.InjectDelay.
+	InjectedDelays   int64
+	InjectedDrops    int64
+	InjectedFailures int64
+
+	// CaseMismatch counts responses not echoing the randomized case of query name, see Benchmark.DNS0x20.
+	CaseMismatch int64
+
+	// ServerCookies counts responses with valid server cookie, CookieMissing counts responses without cookie and CookieMismatch
+	// counts responses echoing different client cookie than the one sent, see Benchmark.Cookies.
+	ServerCookies  int64
+	CookieMissing  int64
+	CookieMismatch int64
+
+	// ValidationOK and ValidationFailed count responses with valid and invalid DNSSEC signatures, see Benchmark.DNSSEC.
+	ValidationOK     int64
+	ValidationFailed int64
+
+	// RcodeMismatch, AnswerCountMismatch and IPMismatch count responses not matching the expectations,
+	// see Benchmark.ExpectRcode, Benchmark.ExpectAnswerCount and Benchmark.ExpectIP.
+	RcodeMismatch       int64
+	AnswerCountMismatch int64
+	IPMismatch          int64
+
+	// TSIGError counts responses failing TSIG verification, see Benchmark.TSIG.
+	TSIGError int64
+
+	// HookRejected counts responses rejected by the response hook, see Benchmark.ResponseHook.
+	HookRejected int64
+
+	// Unsent counts queries planned by Benchmark.Count or Benchmark.Total, which were never sent, and Unanswered counts queries sent,
+	// but not answered when the run was hard-stopped after Benchmark.MaxRuntime, they are not counted in Total.
+	Unsent     int64
+	Unanswered int64
+
+	// Agreements and Disagreements count compared answers of Benchmark.Server and Benchmark.DiffServer.
+	Agreements    int64
+	Disagreements int64
+}
+
+func (c *Counters) add(o *Counters) {
+	c.Total += o.Total
+	c.IOError += o.IOError
+	c.Success += o.Success
+	c.IDmismatch += o.IDmismatch
+	c.Truncated += o.Truncated
+	c.Timeouts += o.Timeouts
+	c.Retries += o.Retries
+	c.TCPRetries += o.TCPRetries
+	c.StubCacheHits += o.StubCacheHits
+	c.CNAMEChases += o.CNAMEChases
+	c.NXDomainQueries += o.NXDomainQueries
+	c.MalformedQueries += o.MalformedQueries
+	c.UnexpectedSources += o.UnexpectedSources
+	c.UniqueQuestions += o.UniqueQuestions
+	c.ServerCloses += o.ServerCloses
+	c.IdleCloses += o.IdleCloses
+	c.Failovers += o.Failovers
+	c.UDPRetransmits += o.UDPRetransmits
+	c.LateResponses += o.LateResponses
+	c.DuplicateResponses += o.DuplicateResponses
+	c.ExpiredResponses += o.ExpiredResponses
+	c.InjectedDelays += o.InjectedDelays
+	c.InjectedDrops += o.InjectedDrops
+	c.InjectedFailures += o.InjectedFailures
+	c.CaseMismatch += o.CaseMismatch
+	c.ServerCookies += o.ServerCookies
+	c.CookieMissing += o.CookieMissing
+	c.CookieMismatch += o.CookieMismatch
+	c.ValidationOK += o.ValidationOK
+	c.ValidationFailed += o.ValidationFailed
+	c.RcodeMismatch += o.RcodeMismatch
+	c.AnswerCountMismatch += o.AnswerCountMismatch
+	c.IPMismatch += o.IPMismatch
+	c.TSIGError += o.TSIGError
+	c.HookRejected += o.HookRejected
+	c.Unsent += o.Unsent
+	c.Unanswered += o.Unanswered
+	c.Agreements += o.Agreements
+	c.Disagreements += o.Disagreements
+}
+
+// Datapoint one datapoint of benchmark (single DNS request).
+type Datapoint struct {
+	Duration float64
+	Start    time.Time
+	// Step is index of the rate ramp step active when the request was sent, see Benchmark.RateRamp.
+	Step int
+	// Rcode is the response code of the response.
+	Rcode int
+}
+
+// ResultStats is a representation of benchmark results of single concurrent thread.
+type ResultStats struct {
+	Codes    map[int]int64
+	Qtypes   map[string]int64
+	Hist     *hdrhistogram.Histogram
+	Timings  []Datapoint
+	Counters *Counters
+	Errors   []error
+
+	// ErrorTimes are send times of the queries failed due to I/O errors, it is set only when time series are reported or plotted,
+	// see Benchmark.SeriesInterval and Benchmark.PlotDir.
+	ErrorTimes []time.Time
+	// DatapointWeight is a number of the queries represented by each datapoint of Timings and ErrorTimes, which are sampled by Benchmark.DatapointSampleRate
+	// and thinned to fit into Benchmark.MaxDatapoints, 0 means that each query is represented by its own datapoint.
+	DatapointWeight float64
+
+	// Sizes holds statistics of sizes of the queries and the responses by transport, QtypeSizes holds the same statistics by question type.
+	Sizes      map[string]*SizeStats
+	QtypeSizes map[string]*SizeStats
+
+	// TTLs holds statistics of TTLs of the answer records by question type.
+	TTLs map[string]*TTLStats
+
+	// QtypeHists holds latency histograms of the responses by question type.
+	QtypeHists map[string]*hdrhistogram.Histogram
+
+	// RcodeHists holds latency histograms of the responses by response code, it is set only when response codes are counted.
+	RcodeHists map[int]*hdrhistogram.Histogram
+
+	// FlagHists holds latency histograms of the responses by the combination of AA, TC, RA, AD and CD flags set in the response header.
+	FlagHists map[string]*hdrhistogram.Histogram
+
+	// CNAMEDepthHists holds latency histograms of the responses by the number of CNAME records followed to resolve the question,
+	// it is set only when CNAME records are followed, see Benchmark.FollowCNAMEs.
+	CNAMEDepthHists map[int]*hdrhistogram.Histogram
+
+	// FailoverHists holds latency histograms of the responses by the server which answered and FailoverUnanswered counts queries
+	// not answered by the server within the failover timeout, they are set only when the queries fail over, see Benchmark.FailoverServers.
+	FailoverHists      map[string]*hdrhistogram.Histogram
+	FailoverUnanswered map[string]int64
+
+	// BurstHists holds latency histograms of the responses by the quarter of the burst the query was sent in and Bursts holds the results
+	// of each burst keyed by the index of the burst, they are set only when the queries are sent in bursts, see Benchmark.Burst.
+	BurstHists map[int]*hdrhistogram.Histogram
+	Bursts     map[int64]*BurstStats
+
+	// Malformed counts the outcomes of the malformed queries by the mutation strategy, the outcome is either the response code
+	// of the response, or whether the server did not respond or closed the connection, see Benchmark.MalformedRate.
+	Malformed map[string]map[string]int64
+
+	// IntervalHists holds latency histograms of the responses by the interval in which the queries were sent, keyed by the start of the interval
+	// in unix milliseconds, it is set only when histogram log is exported, see Benchmark.HistLog.
+	IntervalHists map[int64]*hdrhistogram.Histogram
+
+	// DoHProtocols counts HTTP protocols negotiated for DoH responses.
+	DoHProtocols map[string]int64
+
+	// DoH holds statistics of the HTTP layer of DoH responses, it is set only when DoH server is benchmarked.
+	DoH *DoHStats
+
+	// IdentityHists holds latency histograms of the responses by the identity of the responding server, that is NSID of the response
+	// or the identity found by hostname.bind probes, it is set only when the servers are identified, see Benchmark.NSID and Benchmark.ChaosProbeInterval.
+	IdentityHists map[string]*hdrhistogram.Histogram
+
+	// ResponderHists holds latency histograms of the responses by the source address of the UDP responses, it is set only when
+	// the responding addresses are recorded, see Benchmark.Responders.
+	ResponderHists map[string]*hdrhistogram.Histogram
+
+	// CacheHists holds latency histograms of the responses to the first-seen and the repeated questions, it is set only when
+	// the cache is modeled, see Benchmark.CacheModel.
+	CacheHists map[string]*hdrhistogram.Histogram
+
+	// FirstByteHists holds latency histograms of the time until the first byte and until the full response was read since the query was written
+	// and of the time streaming the response in between, it is set only when the reads are timed, see Benchmark.FirstByte.
+	FirstByteHists map[string]*hdrhistogram.Histogram
+
+	// RRL holds the outcomes of the queries by the interval in which the queries were sent, keyed by the start of the interval in unix milliseconds,
+	// it is set only when the outcomes are classified, see Benchmark.RRLInterval.
+	RRL map[int64]*RRLStats
+
+	// EDNSSizeHists holds latency histograms of the responses by the EDNS buffer size advertised by the queries, it is set only when
+	// the buffer sizes are swept, see Benchmark.EDNSSweep.
+	EDNSSizeHists map[int]*hdrhistogram.Histogram
+
+	// EDNSSizes counts the outcomes of the queries by the EDNS buffer size advertised by the queries, it is set only when
+	// the buffer sizes are swept, see Benchmark.EDNSSweep.
+	EDNSSizes map[int]*EDNSSizeStats
+
+	// DoHMethodHists holds latency histograms of DoH responses by HTTP method, it is set only when both GET and POST methods are used.
+	DoHMethodHists map[string]*hdrhistogram.Histogram
+
+	// Families counts established connections by address family.
+	Families map[string]int64
+
+	// KeepaliveTimeouts counts responses by the idle timeout advertised in edns-tcp-keepalive option, see Benchmark.EDNSTCPKeepalive.
+	KeepaliveTimeouts map[string]int64
+
+	// ErrorCategories counts queries failed due to I/O errors or timeouts by the category of the error, for example dial, write, read timeout,
+	// connection reset, tls or http status.
+	ErrorCategories map[string]int64
+
+	// HookErrors counts responses rejected by the response hook by the returned error, it is set only when the response hook is registered,
+	// see Benchmark.ResponseHook.
+	HookErrors map[string]int64
+
+	// Attempts counts queries by the number of attempts needed, it is set only when failed queries are retried, see Benchmark.Retries.
+	Attempts map[int]int64
+
+	// Connections holds statistics of established connections, it is set only for connection oriented protocols.
+	Connections *ConnectionStats
+
+	// Transfer holds the results of zone transfers, it is set only when zone transfers are benchmarked.
+	Transfer *TransferStats
+
+	// Dual holds the results of A and AAAA queries sent concurrently, it is set only when Benchmark.DualAAAA is enabled.
+	Dual *DualStats
+
+	// Search holds the results of the lookups of relative names expanded with the search domains, it is set only when Benchmark.SearchExpansion is enabled.
+	Search *SearchStats
+
+	// RTT holds the network RTT to the server measured before the benchmark, it is set only when Benchmark.NetworkRTT is set.
+	RTT *RTTStats
+
+	// Diff holds the results of queries sent to Benchmark.DiffServer, it is set only when answers are compared.
+	Diff *ResultStats
+
+	// tcpRetried is set by withTCPRetry when the last response was received over TCP after truncated UDP response.
+	tcpRetried bool
+
+	// workers are the summaries of the merged results of each worker, they are set only for reporting, see Benchmark.WorkerStats.
+	workers []workerSummary
+
+	// dualAnswers are set by withDualAAAA to the last A and AAAA answers.
+	dualAnswers []dualAnswer
+
+	// burst is the burst the last query was sent in, with which the results of the query are recorded.
+	burst burstTag
+
+	// answeredBy is set by withFailover to the server, which answered the last query.
+	answeredBy string
+
+	// responder is the source address of the last UDP response, see Benchmark.Responders.
+	responder string
+
+	// firstByte holds the times of the reads of the last response, see Benchmark.FirstByte.
+	firstByte readTimer
+
+	// ednsSize is the EDNS buffer size advertised by the last query, see Benchmark.EDNSSweep.
+	ednsSize int
+
+	// step is the active rate ramp step, with which the recorded datapoints are tagged.
+	step int
+
+	// histInterval is the length of intervals of IntervalHists.
+	histInterval time.Duration
+
+	// rrlInterval is the length of intervals of RRL.
+	rrlInterval time.Duration
+
+	// noDatapoints disables recording of Timings, see Benchmark.NoDatapoints.
+	noDatapoints bool
+
+	// sampleRate is a fraction of the queries recorded in Timings and ErrorTimes, see Benchmark.DatapointSampleRate, it is halved
+	// whenever the datapoints are thinned to fit into maxDatapoints, see Benchmark.MaxDatapoints.
+	sampleRate    float64
+	maxDatapoints int
+	sampler       *rand.Rand
+}
+
+func (rs *ResultStats) record(req *dns.Msg, resp *dns.Msg, time time.Time, timing time.Duration) {
+	if resp.Truncated {
+		rs.Counters.Truncated++
+	}
+
+	if resp.Rcode == dns.RcodeSuccess {
+		if resp.Id != req.Id {
+			rs.Counters.IDmismatch++
+			return
+		}
+		rs.Counters.Success++
+	}
+
+	if rs.Codes != nil {
+		var c int64
+		if v, ok := rs.Codes[resp.Rcode]; ok {
+			c = v
+		}
+		c++
+		rs.Codes[resp.Rcode] = c
+	}
+	if rs.Qtypes != nil {
+		rs.Qtypes[dns.TypeToString[req.Question[0].Qtype]]++
+	}
+	rs.recordTTLs(req, resp)
+
+	rs.Hist.RecordValue(timing.Nanoseconds())
+	if rs.QtypeHists != nil {
+		recordKeyed(rs.QtypeHists, dns.TypeToString[req.Question[0].Qtype], rs.Hist, timing.Nanoseconds())
+	}
+	if rs.RcodeHists != nil {
+		recordKeyed(rs.RcodeHists, resp.Rcode, rs.Hist, timing.Nanoseconds())
+	}
+	if rs.FlagHists != nil {
+		recordKeyed(rs.FlagHists, responseFlags(resp), rs.Hist, timing.Nanoseconds())
+	}
+	if rs.FailoverHists != nil && rs.answeredBy != "" {
+		recordKeyed(rs.FailoverHists, rs.answeredBy, rs.Hist, timing.Nanoseconds())
+	}
+	if rs.ResponderHists != nil && rs.responder != "" {
+		recordKeyed(rs.ResponderHists, rs.responder, rs.Hist, timing.Nanoseconds())
+	}
+	rs.recordFirstByte()
+	if rs.EDNSSizeHists != nil {
+		recordKeyed(rs.EDNSSizeHists, rs.ednsSize, rs.Hist, timing.Nanoseconds())
+	}
+	if rs.BurstHists != nil {
+		recordKeyed(rs.BurstHists, rs.burst.quarter, rs.Hist, timing.Nanoseconds())
+	}
+	if rs.CNAMEDepthHists != nil {
+		_, depth, _ := cnameChain(resp.Answer, req.Question[0])
+		recordKeyed(rs.CNAMEDepthHists, depth, rs.Hist, timing.Nanoseconds())
+	}
+	if rs.IntervalHists != nil {
+		recordKeyed(rs.IntervalHists, time.Truncate(rs.histInterval).UnixMilli(), rs.Hist, timing.Nanoseconds())
+	}
+	if rs.noDatapoints || !rs.sampled() {
+		return
+	}
+	rs.addDatapoint(Datapoint{Duration: float64(timing.Milliseconds()), Start: time, Step: rs.step, Rcode: resp.Rcode})
+}
+
+func (rs *ResultStats) addDatapoint(d Datapoint) {
+	rs.Timings = append(rs.Timings, d)
+	rs.thin()
+}
+
+func (rs *ResultStats) addErrorTime(t time.Time) {
+	rs.ErrorTimes = append(rs.ErrorTimes, t)
+	rs.thin()
+}
+
+// sampled decides whether the datapoint of the query is stored.
+func (rs *ResultStats) sampled() bool {
+	return rs.sampler == nil || rs.sampler.Float64() < rs.sampleRate
+}
+
+// thin halves the sample rate and drops each stored datapoint with probability of one half until the datapoints fit into maxDatapoints,
+// so that the memory used by the datapoints does not grow with the number of the queries.
+func (rs *ResultStats) thin() {
+	keep := func() bool { return rs.sampler.Intn(2) == 0 }
+	for rs.maxDatapoints > 0 && len(rs.Timings)+len(rs.ErrorTimes) > rs.maxDatapoints {
+		rs.sampleRate /= 2
+		rs.DatapointWeight = 1 / rs.sampleRate
+		rs.Timings = filterInPlace(rs.Timings, keep)
+		rs.ErrorTimes = filterInPlace(rs.ErrorTimes, keep)
+	}
+}
+
+// datapointWeight returns the number of the queries represented by each datapoint, see ResultStats.DatapointWeight.
+func (rs *ResultStats) datapointWeight() float64 {
+	if rs.DatapointWeight > 0 {
+		return rs.DatapointWeight
+	}
+	return 1
+}
+
+// filterInPlace keeps the elements of the slice, for which keep returns true, reusing the backing array of the slice.
+func filterInPlace[T any](s []T, keep func() bool) []T {
+	kept := s[:0]
+	for _, v := range s {
+		if keep() {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// everyNth returns every n-th element of the slice.
+func everyNth[T any](s []T, n int) []T {
+	if n <= 1 {
+		return s
+	}
+	res := make([]T, 0, len(s)/n+1)
+	for i := 0; i < len(s); i += n {
+		res = append(res, s[i])
+	}
+	return res
+}
+
+// Merge merges results of parallel benchmark goroutines into single aggregated result. Counters, codes and question types are summed,
+// histograms are merged and timings are concatenated and sorted from the oldest to the latest datapoint. Timings of the results with lower
+// datapoint weight are thinned, so that all the merged datapoints represent the same number of queries.
+func Merge(stats []*ResultStats) *ResultStats {
+	merged := &ResultStats{
+		Qtypes:   make(map[string]int64),
+		Counters: &Counters{},
+		Timings:  make([]Datapoint, 0),
+	}
+	for _, s := range stats {
+		if w := s.datapointWeight(); w > merged.datapointWeight() {
+			merged.DatapointWeight = w
+		}
+	}
+
+	var diffs []*ResultStats
+	for _, s := range stats {
+		if s == nil {
+			continue
+		}
+		if s.Hist != nil {
+			if merged.Hist == nil {
+				merged.Hist = hdrhistogram.New(s.Hist.LowestTrackableValue(), s.Hist.HighestTrackableValue(), int(s.Hist.SignificantFigures()))
+			}
+			merged.Hist.Merge(s.Hist)
+		}
+		if s.Codes != nil {
+			if merged.Codes == nil {
+				merged.Codes = make(map[int]int64)
+			}
+			for k, v := range s.Codes {
+				merged.Codes[k] += v
+			}
+		}
+		for k, v := range s.Qtypes {
+			merged.Qtypes[k] += v
+		}
+		merged.TTLs = mergeTTLs(merged.TTLs, s.TTLs)
+		merged.Sizes = mergeSizes(merged.Sizes, s.Sizes)
+		merged.QtypeSizes = mergeSizes(merged.QtypeSizes, s.QtypeSizes)
+		merged.QtypeHists = mergeKeyed(merged.QtypeHists, s.QtypeHists)
+		merged.RcodeHists = mergeKeyed(merged.RcodeHists, s.RcodeHists)
+		merged.FlagHists = mergeKeyed(merged.FlagHists, s.FlagHists)
+		merged.CNAMEDepthHists = mergeKeyed(merged.CNAMEDepthHists, s.CNAMEDepthHists)
+		merged.FailoverHists = mergeKeyed(merged.FailoverHists, s.FailoverHists)
+		merged.BurstHists = mergeKeyed(merged.BurstHists, s.BurstHists)
+		merged.IntervalHists = mergeKeyed(merged.IntervalHists, s.IntervalHists)
+		merged.DoHMethodHists = mergeKeyed(merged.DoHMethodHists, s.DoHMethodHists)
+		merged.IdentityHists = mergeKeyed(merged.IdentityHists, s.IdentityHists)
+		merged.ResponderHists = mergeKeyed(merged.ResponderHists, s.ResponderHists)
+		merged.CacheHists = mergeKeyed(merged.CacheHists, s.CacheHists)
+		merged.FirstByteHists = mergeKeyed(merged.FirstByteHists, s.FirstByteHists)
+		merged.EDNSSizeHists = mergeKeyed(merged.EDNSSizeHists, s.EDNSSizeHists)
+		if s.DoHProtocols != nil {
+			if merged.DoHProtocols == nil {
+				merged.DoHProtocols = make(map[string]int64)
+			}
+			for k, v := range s.DoHProtocols {
+				merged.DoHProtocols[k] += v
+			}
+		}
+		if s.DoH != nil {
+			if merged.DoH == nil {
+				merged.DoH = newDoHStats()
+			}
+			merged.DoH.add(s.DoH)
+		}
+		if s.Families != nil {
+			if merged.Families == nil {
+				merged.Families = make(map[string]int64)
+			}
+			for k, v := range s.Families {
+				merged.Families[k] += v
+			}
+		}
+		if s.KeepaliveTimeouts != nil {
+			if merged.KeepaliveTimeouts == nil {
+				merged.KeepaliveTimeouts = make(map[string]int64)
+			}
+			for k, v := range s.KeepaliveTimeouts {
+				merged.KeepaliveTimeouts[k] += v
+			}
+		}
+		if s.FailoverUnanswered != nil {
+			if merged.FailoverUnanswered == nil {
+				merged.FailoverUnanswered = make(map[string]int64)
+			}
+			for k, v := range s.FailoverUnanswered {
+				merged.FailoverUnanswered[k] += v
+			}
+		}
+		if s.Bursts != nil {
+			if merged.Bursts == nil {
+				merged.Bursts = make(map[int64]*BurstStats)
+			}
+			for k, v := range s.Bursts {
+				if _, ok := merged.Bursts[k]; !ok {
+					merged.Bursts[k] = &BurstStats{}
+				}
+				merged.Bursts[k].add(v)
+			}
+		}
+		if s.RRL != nil {
+			if merged.RRL == nil {
+				merged.RRL = make(map[int64]*RRLStats)
+			}
+			for k, v := range s.RRL {
+				if _, ok := merged.RRL[k]; !ok {
+					merged.RRL[k] = &RRLStats{}
+				}
+				merged.RRL[k].add(v)
+			}
+		}
+		if s.EDNSSizes != nil {
+			if merged.EDNSSizes == nil {
+				merged.EDNSSizes = make(map[int]*EDNSSizeStats)
+			}
+			for k, v := range s.EDNSSizes {
+				if _, ok := merged.EDNSSizes[k]; !ok {
+					merged.EDNSSizes[k] = &EDNSSizeStats{}
+				}
+				merged.EDNSSizes[k].add(v)
+			}
+		}
+		if s.Malformed != nil {
+			if merged.Malformed == nil {
+				merged.Malformed = make(map[string]map[string]int64)
+			}
+			for k, v := range s.Malformed {
+				if merged.Malformed[k] == nil {
+					merged.Malformed[k] = make(map[string]int64)
+				}
+				for o, c := range v {
+					merged.Malformed[k][o] += c
+				}
+			}
+		}
+		if s.ErrorCategories != nil {
+			if merged.ErrorCategories == nil {
+				merged.ErrorCategories = make(map[string]int64)
+			}
+			for k, v := range s.ErrorCategories {
+				merged.ErrorCategories[k] += v
+			}
+		}
+		if s.HookErrors != nil {
+			if merged.HookErrors == nil {
+				merged.HookErrors = make(map[string]int64)
+			}
+			for k, v := range s.HookErrors {
+				merged.HookErrors[k] += v
+			}
+		}
+		if s.Attempts != nil {
+			if merged.Attempts == nil {
+				merged.Attempts = make(map[int]int64)
+			}
+			for k, v := range s.Attempts {
+				merged.Attempts[k] += v
+			}
+		}
+		if s.Counters != nil {
+			merged.Counters.add(s.Counters)
+		}
+		if s.Connections != nil {
+			if merged.Connections == nil {
+				merged.Connections = &ConnectionStats{}
+			}
+			merged.Connections.add(s.Connections)
+		}
+		if s.Transfer != nil {
+			if merged.Transfer == nil {
+				merged.Transfer = &TransferStats{}
+			}
+			merged.Transfer.add(s.Transfer)
+		}
+		if s.Dual != nil {
+			if merged.Dual == nil {
+				merged.Dual = &DualStats{}
+			}
+			merged.Dual.add(s.Dual)
+		}
+		if s.Search != nil {
+			if merged.Search == nil {
+				merged.Search = &SearchStats{}
+			}
+			merged.Search.add(s.Search)
+		}
+		if s.RTT != nil {
+			if merged.RTT == nil {
+				merged.RTT = &RTTStats{}
+			}
+			merged.RTT.add(s.RTT)
+		}
+		// the weights are the sample rates halved by thinning, so their ratios are whole numbers
+		nth := int(math.Round(merged.datapointWeight() / s.datapointWeight()))
+		merged.Timings = append(merged.Timings, everyNth(s.Timings, nth)...)
+		merged.Errors = append(merged.Errors, s.Errors...)
+		merged.ErrorTimes = append(merged.ErrorTimes, everyNth(s.ErrorTimes, nth)...)
+		if s.Diff != nil {
+			diffs = append(diffs, s.Diff)
+		}
+	}
+
+	// sort data points from the oldest to the earliest so we can better plot time dependant graphs (like line)
+	sort.SliceStable(merged.Timings, func(i, j int) bool {
+		return merged.Timings[i].Start.Before(merged.Timings[j].Start)
+	})
+	sort.Slice(merged.ErrorTimes, func(i, j int) bool {
+		return merged.ErrorTimes[i].Before(merged.ErrorTimes[j])
+	})
+
+	if len(diffs) > 0 {
+		merged.Diff = Merge(diffs)
+	}
+	return merged
+}
@@ -0,0 +1,64 @@
+package dnsbench
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	h1 := hdrhistogram.New(0, 1000, 1)
+	h1.RecordValue(5)
+	h2 := hdrhistogram.New(0, 1000, 1)
+	h2.RecordValue(10)
+	h2.RecordValue(20)
+
+	d1 := Datapoint{Duration: 5, Start: time.Unix(2, 0)}
+	d2 := Datapoint{Duration: 10, Start: time.Unix(1, 0)}
+	d3 := Datapoint{Duration: 20, Start: time.Unix(3, 0)}
+
+	stats := []*ResultStats{
+		{
+			Codes:    map[int]int64{dns.RcodeSuccess: 1},
+			Qtypes:   map[string]int64{"A": 1},
+			Hist:     h1,
+			Timings:  []Datapoint{d1},
+			Counters: &Counters{Total: 2, IOError: 1, Success: 1},
+			Errors:   []error{errors.New("test")},
+		},
+		{
+			Codes:    map[int]int64{dns.RcodeSuccess: 1, dns.RcodeNameError: 1},
+			Qtypes:   map[string]int64{"A": 1, "AAAA": 1},
+			Hist:     h2,
+			Timings:  []Datapoint{d2, d3},
+			Counters: &Counters{Total: 2, Success: 1, Truncated: 1, Agreements: 1},
+		},
+	}
+
+	merged := Merge(stats)
+
+	assert.Equal(t, map[int]int64{dns.RcodeSuccess: 2, dns.RcodeNameError: 1}, merged.Codes)
+	assert.Equal(t, map[string]int64{"A": 2, "AAAA": 1}, merged.Qtypes)
+	assert.Equal(t, []Datapoint{d2, d1, d3}, merged.Timings)
+	assert.Equal(t, Counters{Total: 4, IOError: 1, Success: 2, Truncated: 1, Agreements: 1}, *merged.Counters)
+	assert.Equal(t, []error{errors.New("test")}, merged.Errors)
+	require.NotNil(t, merged.Hist)
+	assert.Equal(t, int64(3), merged.Hist.TotalCount())
+	assert.Nil(t, merged.Diff)
+	// merging must not modify the merged stats
+	assert.Equal(t, int64(1), h1.TotalCount())
+}
+
+func TestMerge_empty(t *testing.T) {
+	merged := Merge(nil)
+
+	assert.Nil(t, merged.Hist)
+	assert.Nil(t, merged.Codes)
+	assert.Equal(t, Counters{}, *merged.Counters)
+	assert.Empty(t, merged.Timings)
+}
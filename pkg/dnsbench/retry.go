@@ -0,0 +1,22 @@
+package dnsbench
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// withTCPRetry returns query function, which retries the query using tcpQuery when the response of udpQuery is truncated, the same way
+// the stub resolvers do. The latency of such query therefore includes both the UDP and the TCP exchange.
+func withTCPRetry(udpQuery, tcpQuery queryFunc, st *ResultStats) queryFunc {
+	return func(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+		st.tcpRetried = false
+		r, err := udpQuery(ctx, server, msg)
+		if err != nil || !r.Truncated {
+			return r, err
+		}
+		st.Counters.TCPRetries++
+		st.tcpRetried = true
+		return tcpQuery(ctx, server, msg)
+	}
+}
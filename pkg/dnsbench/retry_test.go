@@ -0,0 +1,98 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_do_classic_dns_retry_truncated(t *testing.T) {
+	var tcpQueries atomic.Int64
+	handler := func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if w.RemoteAddr().Network() == "udp" {
+			ret.Truncated = true
+		} else {
+			tcpQueries.Add(1)
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	}
+	s := NewServer(udp, handler)
+	defer s.Close()
+
+	// UDP server reserves TCP listener on the same port, so TCP server can be started using it
+	tcpServer := dns.Server{Listener: s.inner.Listener, Handler: dns.HandlerFunc(handler)}
+	go tcpServer.ActivateAndServe()
+	defer tcpServer.Shutdown()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.RetryTruncated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2, "Run(ctx) rstats")
+	for _, r := range rs {
+		assert.Equal(t, int64(2), r.Counters.Success, "Run(ctx) success counter")
+		assert.Zero(t, r.Counters.Truncated, "Run(ctx) truncated counter")
+		assert.Equal(t, int64(2), r.Counters.TCPRetries, "Run(ctx) TCP retries counter")
+	}
+	assert.Equal(t, int64(4), tcpQueries.Load())
+}
+
+func TestBenchmark_normalize_retry_truncated(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", RetryTruncated: true, TCP: true}
+
+	assert.Error(t, b.normalize())
+}
+
+func Test_do_classic_dns_retries(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		key := fmt.Sprintf("%d/%d", r.Id, r.Question[0].Qtype)
+		seen[key]++
+		first := seen[key] == 1
+		mu.Unlock()
+		if first {
+			// first attempt of each query times out
+			return
+		}
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.RequestTimeout = 200 * time.Millisecond
+	bench.ReadTimeout = 200 * time.Millisecond
+	bench.Retries = 2
+	bench.RetryBackoff = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 2, "Run(ctx) rstats")
+	for _, r := range rs {
+		assert.Equal(t, int64(2), r.Counters.Success, "Run(ctx) success counter")
+		assert.Zero(t, r.Counters.IOError, "Run(ctx) error counter")
+		assert.Equal(t, int64(2), r.Counters.Retries, "Run(ctx) retries counter")
+		assert.Equal(t, map[int]int64{2: 2}, r.Attempts, "Run(ctx) attempts")
+	}
+}
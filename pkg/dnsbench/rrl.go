@@ -0,0 +1,169 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/olekukonko/tablewriter"
+)
+
+// RRLStats classifies the outcomes of the queries sent in a single interval the way response rate limiting (RRL) of authoritative
+// servers treats the responses, see Benchmark.RRLInterval.
+type RRLStats struct {
+	// Answered counts the queries answered normally.
+	Answered int64
+	// Slipped counts the queries answered by truncated response without answers, which RRL sends instead of some of the limited responses
+	// to make the legitimate clients retry over TCP. Truncated responses retried over TCP are counted as well, see Benchmark.RetryTruncated.
+	Slipped int64
+	// Dropped counts the queries, which timed out, RRL does not respond to the rest of the limited queries.
+	Dropped int64
+}
+
+func (s *RRLStats) add(o *RRLStats) {
+	s.Answered += o.Answered
+	s.Slipped += o.Slipped
+	s.Dropped += o.Dropped
+}
+
+func (s *RRLStats) queries() int64 {
+	return s.Answered + s.Slipped + s.Dropped
+}
+
+// recordRRL classifies the outcome of the query sent at the time start, err is the error of the query. Queries failed due to other errors
+// than timeouts are not classified.
+func (rs *ResultStats) recordRRL(start time.Time, resp *dns.Msg, err error) {
+	if rs.RRL == nil {
+		return
+	}
+	if err != nil && !isTimeout(err) {
+		return
+	}
+	key := start.Truncate(rs.rrlInterval).UnixMilli()
+	s, ok := rs.RRL[key]
+	if !ok {
+		s = &RRLStats{}
+		rs.RRL[key] = s
+	}
+	switch {
+	case err != nil:
+		s.Dropped++
+	case rs.tcpRetried || (resp.Truncated && len(resp.Answer) == 0):
+		s.Slipped++
+	default:
+		s.Answered++
+	}
+}
+
+// rrlSummary sums the outcomes across the intervals, the intervals are returned sorted by time.
+func rrlSummary(intervals map[int64]*RRLStats) (RRLStats, []int64) {
+	var total RRLStats
+	keys := make([]int64, 0, len(intervals))
+	for k, s := range intervals {
+		total.add(s)
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return total, keys
+}
+
+// inferredSlip estimates the slip setting of RRL, that is one of how many limited responses is slipped instead of being dropped,
+// zero is returned when no responses were both slipped and dropped.
+func inferredSlip(s RRLStats) int64 {
+	if s.Slipped == 0 || s.Dropped == 0 {
+		return 0
+	}
+	return int64(math.Round(float64(s.Slipped+s.Dropped) / float64(s.Slipped)))
+}
+
+func rate(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func printRRL(w io.Writer, interval time.Duration, intervals map[int64]*RRLStats) {
+	if intervals == nil {
+		return
+	}
+	total, keys := rrlSummary(intervals)
+	q := total.queries()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Response rate limiting:")
+	fmt.Fprintf(w, "\tAnswered:\t\t%s (%0.2f%%)\n", highlightStr(total.Answered), rate(total.Answered, q)*100)
+	fmt.Fprintf(w, "\tSlipped:\t\t%s (%0.2f%%)\n", highlightStr(total.Slipped), rate(total.Slipped, q)*100)
+	fmt.Fprintf(w, "\tDropped:\t\t%s (%0.2f%%)\n", highlightStr(total.Dropped), rate(total.Dropped, q)*100)
+	if slip := inferredSlip(total); slip > 0 {
+		fmt.Fprintf(w, "\tInferred slip:\t\t1 of %s limited responses\n", highlightStr(slip))
+	}
+	if len(keys) < 2 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Response rate limiting every", highlightStr(interval)+":")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Time", "Queries", "Answered", "Slipped", "Dropped"})
+	table.SetBorder(false)
+	for _, k := range keys {
+		s := intervals[k]
+		n := s.queries()
+		table.Append([]string{
+			(time.Duration(k-keys[0]) * time.Millisecond).String(),
+			strconv.FormatInt(n, 10),
+			fmt.Sprintf("%d (%0.1f%%)", s.Answered, rate(s.Answered, n)*100),
+			fmt.Sprintf("%d (%0.1f%%)", s.Slipped, rate(s.Slipped, n)*100),
+			fmt.Sprintf("%d (%0.1f%%)", s.Dropped, rate(s.Dropped, n)*100),
+		})
+	}
+	table.Render()
+}
+
+type jsonRRL struct {
+	IntervalSeconds float64           `json:"intervalSeconds"`
+	TotalAnswered   int64             `json:"totalAnswered"`
+	TotalSlipped    int64             `json:"totalSlipped"`
+	TotalDropped    int64             `json:"totalDropped"`
+	SlipRate        float64           `json:"slipRate"`
+	DropRate        float64           `json:"dropRate"`
+	InferredSlip    int64             `json:"inferredSlip,omitempty"`
+	Intervals       []jsonRRLInterval `json:"intervals"`
+}
+
+type jsonRRLInterval struct {
+	OffsetSeconds float64 `json:"offsetSeconds"`
+	Answered      int64   `json:"answered"`
+	Slipped       int64   `json:"slipped"`
+	Dropped       int64   `json:"dropped"`
+}
+
+func newJSONRRL(interval time.Duration, intervals map[int64]*RRLStats) *jsonRRL {
+	total, keys := rrlSummary(intervals)
+	q := total.queries()
+	res := &jsonRRL{
+		IntervalSeconds: interval.Seconds(),
+		TotalAnswered:   total.Answered,
+		TotalSlipped:    total.Slipped,
+		TotalDropped:    total.Dropped,
+		SlipRate:        math.Round(rate(total.Slipped, q)*10000) / 10000,
+		DropRate:        math.Round(rate(total.Dropped, q)*10000) / 10000,
+		InferredSlip:    inferredSlip(total),
+		Intervals:       make([]jsonRRLInterval, 0, len(keys)),
+	}
+	for _, k := range keys {
+		s := intervals[k]
+		res.Intervals = append(res.Intervals, jsonRRLInterval{
+			OffsetSeconds: float64(k-keys[0]) / 1000,
+			Answered:      s.Answered,
+			Slipped:       s.Slipped,
+			Dropped:       s.Dropped,
+		})
+	}
+	return res
+}
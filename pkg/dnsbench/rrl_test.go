@@ -0,0 +1,117 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rrlHandler answers the queries the way RRL with slip 2 limits them, the first two queries are answered normally and then the queries
+// are alternately dropped and slipped.
+func rrlHandler() dns.HandlerFunc {
+	var mu sync.Mutex
+	var n int
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		i := n
+		n++
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		switch {
+		case i < 2:
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		case i%2 == 0:
+			return
+		default:
+			ret.Truncated = true
+		}
+		w.WriteMsg(ret)
+	}
+}
+
+func TestBenchmark_Run_rrl(t *testing.T) {
+	s := NewServer(udp, rrlHandler())
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	// single worker, so that the queries are received by the server in order
+	bench.Concurrency = 1
+	bench.Count = 3
+	bench.ReadTimeout = 100 * time.Millisecond
+	bench.RequestTimeout = 100 * time.Millisecond
+	bench.RRLInterval = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.Len(t, merged.RRL, 1)
+	total, _ := rrlSummary(merged.RRL)
+	assert.Equal(t, RRLStats{Answered: 2, Slipped: 2, Dropped: 2}, total)
+	assert.Equal(t, int64(2), inferredSlip(total))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	out := buf.String()
+	assert.Contains(t, out, "Response rate limiting:")
+	assert.Contains(t, out, "\tDropped:\t\t2 (33.33%)")
+	assert.Contains(t, out, "\tInferred slip:\t\t1 of 2 limited responses")
+	assert.NotContains(t, out, "Response rate limiting every", "single interval is not broken down")
+
+	bench.JSON = true
+	buf.Reset()
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	var res struct {
+		RRL jsonRRL `json:"rrl"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	assert.Equal(t, int64(2), res.RRL.TotalAnswered)
+	assert.Equal(t, int64(2), res.RRL.TotalSlipped)
+	assert.Equal(t, int64(2), res.RRL.TotalDropped)
+	assert.InDelta(t, 0.3333, res.RRL.SlipRate, 0.0001)
+	assert.Equal(t, int64(2), res.RRL.InferredSlip)
+	require.Len(t, res.RRL.Intervals, 1)
+}
+
+func TestPrintRRL_intervals(t *testing.T) {
+	intervals := map[int64]*RRLStats{
+		1000: {Answered: 10},
+		2000: {Answered: 4, Slipped: 2, Dropped: 4},
+	}
+
+	buf := bytes.Buffer{}
+	printRRL(&buf, time.Second, intervals)
+	out := buf.String()
+	assert.Contains(t, out, "\tAnswered:\t\t14 (70.00%)")
+	assert.Contains(t, out, "\tInferred slip:\t\t1 of 3 limited responses")
+	assert.Contains(t, out, "Response rate limiting every 1s:")
+	assert.Regexp(t, `1s\s+\|\s+10 \| 4 \(40\.0%\)\s+\| 2 \(20\.0%\)\s+\| 4 \(40\.0%\)`, out)
+
+	j := newJSONRRL(time.Second, intervals)
+	require.Len(t, j.Intervals, 2)
+	assert.Equal(t, 0.0, j.Intervals[0].OffsetSeconds)
+	assert.Equal(t, 1.0, j.Intervals[1].OffsetSeconds)
+}
+
+func TestResultStats_recordRRL_errors(t *testing.T) {
+	rs := ResultStats{RRL: make(map[int64]*RRLStats), rrlInterval: time.Second}
+
+	rs.recordRRL(time.Now(), nil, errInjectedDrop)
+	assert.Empty(t, rs.RRL, "errors other than timeouts are not classified")
+
+	rs.tcpRetried = true
+	rs.recordRRL(time.Now(), new(dns.Msg), nil)
+	total, _ := rrlSummary(rs.RRL)
+	assert.Equal(t, RRLStats{Slipped: 1}, total)
+}
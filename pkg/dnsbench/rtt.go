@@ -0,0 +1,280 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// tcpRTT measures the network RTT by the time of TCP connect to the port of the server.
+	tcpRTT = "tcp"
+	// icmpRTT measures the network RTT by the time of ICMP echo request and reply.
+	icmpRTT = "icmp"
+
+	// defaultRTTSamples is a number of network RTT samples measured when Benchmark.NetworkRTTSamples is not set.
+	defaultRTTSamples = 5
+)
+
+// RTTStats holds the network RTT to the server measured before the benchmark, see Benchmark.NetworkRTT.
+type RTTStats struct {
+	// Method is the method of the measurement, either tcp or icmp, and Target is the measured address.
+	Method string
+	Target string
+	// Hist holds the measured RTT samples and Failed counts the samples, which failed or timed out.
+	Hist   *hdrhistogram.Histogram
+	Failed int64
+}
+
+func (r *RTTStats) add(o *RTTStats) {
+	if r.Method == "" {
+		r.Method = o.Method
+		r.Target = o.Target
+	}
+	r.Failed += o.Failed
+	if o.Hist != nil {
+		if r.Hist == nil {
+			r.Hist = hdrhistogram.New(o.Hist.LowestTrackableValue(), o.Hist.HighestTrackableValue(), int(o.Hist.SignificantFigures()))
+		}
+		r.Hist.Merge(o.Hist)
+	}
+}
+
+// baseline returns the median of the measured RTT samples, which is subtracted from the latencies to get the server processing latency.
+func (r *RTTStats) baseline() time.Duration {
+	if r.Hist == nil || r.Hist.TotalCount() == 0 {
+		return 0
+	}
+	return time.Duration(r.Hist.ValueAtPercentile(50))
+}
+
+// processing returns the server processing latency, that is the latency minus the network RTT baseline, it is never negative.
+func (r *RTTStats) processing(latency time.Duration) time.Duration {
+	if p := latency - r.baseline(); p > 0 {
+		return p
+	}
+	return 0
+}
+
+// rttTarget returns the host and the port of the server, to which the network RTT is measured.
+func (b *Benchmark) rttTarget() (string, string, error) {
+	if b.useDoH {
+		u, err := url.Parse(b.Server)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse DoH server URL due to '%v'", err)
+		}
+		port := u.Port()
+		if port == "" {
+			port = "443"
+			if u.Scheme == "http" {
+				port = "80"
+			}
+		}
+		return u.Hostname(), port, nil
+	}
+	return net.SplitHostPort(b.Server)
+}
+
+// measureRTT measures the network RTT to the server by NetworkRTTSamples sequential samples using NetworkRTT method,
+// each sample is bounded by ConnectTimeout.
+func (b *Benchmark) measureRTT(ctx context.Context) (*RTTStats, error) {
+	host, port, err := b.rttTarget()
+	if err != nil {
+		return nil, err
+	}
+	ip, err := net.ResolveIPAddr(b.network("ip"), host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve address of '%s' for measuring network RTT due to '%v'", host, err)
+	}
+
+	rtt := &RTTStats{
+		Method: b.NetworkRTT,
+		Target: ip.String(),
+		Hist:   hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+	}
+	sample := func(ctx context.Context, _ int) (time.Duration, error) {
+		return b.tcpConnectRTT(ctx, net.JoinHostPort(ip.String(), port))
+	}
+	if b.NetworkRTT == icmpRTT {
+		pinger, err := newICMPPinger(ip)
+		if err != nil {
+			return nil, err
+		}
+		defer pinger.close()
+		sample = pinger.ping
+	} else {
+		rtt.Target = net.JoinHostPort(ip.String(), port)
+	}
+
+	for i := 0; i < b.NetworkRTTSamples && !ended(ctx); i++ {
+		sampleCtx, cancel := context.WithTimeout(ctx, b.ConnectTimeout)
+		d, err := sample(sampleCtx, i)
+		cancel()
+		if err != nil {
+			b.log.debug("network RTT sample failed", "target", rtt.Target, "error", err)
+			rtt.Failed++
+			continue
+		}
+		rtt.Hist.RecordValue(d.Nanoseconds())
+	}
+	return rtt, nil
+}
+
+// tcpConnectRTT returns the time of TCP connect to the address, refused connection measures the RTT as well,
+// so that the servers not listening on TCP can be measured.
+func (b *Benchmark) tcpConnectRTT(ctx context.Context, addr string) (time.Duration, error) {
+	network := b.network("tcp")
+	start := time.Now()
+	conn, err := b.dialer(network).DialContext(ctx, network, addr)
+	d := time.Since(start)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return d, nil
+		}
+		return 0, err
+	}
+	conn.Close()
+	return d, nil
+}
+
+// icmpPinger sends ICMP echo requests to the address, unprivileged ICMP sockets are used where permitted, raw sockets otherwise.
+type icmpPinger struct {
+	conn  *icmp.PacketConn
+	dst   net.Addr
+	proto int
+	typ   icmp.Type
+	reply icmp.Type
+	id    int
+}
+
+func newICMPPinger(ip *net.IPAddr) (*icmpPinger, error) {
+	p := &icmpPinger{id: os.Getpid() & 0xffff}
+	network, raw, addr := "udp4", "ip4:icmp", "0.0.0.0"
+	p.proto, p.typ, p.reply = 1, ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if ip.IP.To4() == nil {
+		network, raw, addr = "udp6", "ip6:ipv6-icmp", "::"
+		p.proto, p.typ, p.reply = 58, ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	conn, err := icmp.ListenPacket(network, addr)
+	p.dst = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}
+	if err != nil {
+		var rawErr error
+		conn, rawErr = icmp.ListenPacket(raw, addr)
+		if rawErr != nil {
+			return nil, fmt.Errorf("ICMP is not permitted, failed to open ICMP socket due to '%v'", err)
+		}
+		p.dst = ip
+	}
+	p.conn = conn
+	return p, nil
+}
+
+// ping returns the time until the echo reply to the echo request with the sequence number is received.
+func (p *icmpPinger) ping(ctx context.Context, seq int) (time.Duration, error) {
+	req, err := (&icmp.Message{Type: p.typ, Body: &icmp.Echo{ID: p.id, Seq: seq, Data: []byte("dnspyre")}}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		p.conn.SetDeadline(deadline)
+	}
+	start := time.Now()
+	if _, err := p.conn.WriteTo(req, p.dst); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := p.conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		msg, err := icmp.ParseMessage(p.proto, buf[:n])
+		if err != nil || msg.Type != p.reply {
+			continue
+		}
+		// the identifier is rewritten by the kernel for unprivileged sockets, so only the sequence number is matched
+		if echo, ok := msg.Body.(*icmp.Echo); ok && echo.Seq == seq {
+			return time.Since(start), nil
+		}
+	}
+}
+
+func (p *icmpPinger) close() {
+	p.conn.Close()
+}
+
+func printRTT(w io.Writer, r *RTTStats, timings *hdrhistogram.Histogram, percentiles []float64) {
+	fmt.Fprintln(w)
+	if r.Hist == nil || r.Hist.TotalCount() == 0 {
+		errPrint(w, "Network RTT to %s could not be measured by %s, all %d samples failed\n", r.Target, r.Method, r.Failed)
+		return
+	}
+	fmt.Fprintln(w, "Network RTT baseline to", highlightStr(r.Target), "by", highlightStr(r.Method)+",", highlightStr(r.Hist.TotalCount()), "samples")
+	fmt.Fprintln(w, "\t min:\t\t", highlightStr(roundDuration(time.Duration(r.Hist.Min()))))
+	fmt.Fprintln(w, "\t median:\t", highlightStr(roundDuration(r.baseline())))
+	fmt.Fprintln(w, "\t max:\t\t", highlightStr(roundDuration(time.Duration(r.Hist.Max()))))
+	if r.Failed > 0 {
+		errPrint(w, "\t failed:\t %d\n", r.Failed)
+	}
+	if timings.TotalCount() == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Server processing timings (DNS timings minus median network RTT)")
+	fmt.Fprintln(w, "\t min:\t\t", highlightStr(roundDuration(r.processing(time.Duration(timings.Min())))))
+	fmt.Fprintln(w, "\t mean:\t\t", highlightStr(roundDuration(r.processing(time.Duration(timings.Mean())))))
+	fmt.Fprintln(w, "\t max:\t\t", highlightStr(roundDuration(r.processing(time.Duration(timings.Max())))))
+	for _, p := range percentiles {
+		label := " " + percentileLabel(p) + ":"
+		tabs := "\t\t"
+		if len(label) >= 8 {
+			tabs = "\t"
+		}
+		fmt.Fprintln(w, "\t"+label+tabs, highlightStr(roundDuration(r.processing(time.Duration(timings.ValueAtPercentile(p))))))
+	}
+}
+
+type jsonRTT struct {
+	Method                string           `json:"method"`
+	Target                string           `json:"target"`
+	TotalSamples          int64            `json:"totalSamples"`
+	TotalFailed           int64            `json:"totalFailed"`
+	MinMs                 float64          `json:"minMs"`
+	MedianMs              float64          `json:"medianMs"`
+	MaxMs                 float64          `json:"maxMs"`
+	ProcessingMeanMs      float64          `json:"processingMeanMs"`
+	ProcessingPercentiles []jsonPercentile `json:"processingPercentiles,omitempty"`
+}
+
+func newJSONRTT(r *RTTStats, timings *hdrhistogram.Histogram, percentiles []float64) *jsonRTT {
+	res := &jsonRTT{Method: r.Method, Target: r.Target, TotalFailed: r.Failed}
+	if r.Hist == nil || r.Hist.TotalCount() == 0 {
+		return res
+	}
+	res.TotalSamples = r.Hist.TotalCount()
+	res.MinMs = durationMs(time.Duration(r.Hist.Min()))
+	res.MedianMs = durationMs(r.baseline())
+	res.MaxMs = durationMs(time.Duration(r.Hist.Max()))
+	if timings.TotalCount() == 0 {
+		return res
+	}
+	res.ProcessingMeanMs = durationMs(r.processing(time.Duration(timings.Mean())))
+	for _, p := range percentiles {
+		res.ProcessingPercentiles = append(res.ProcessingPercentiles, jsonPercentile{
+			Percentile: p,
+			LatencyMs:  durationMs(r.processing(time.Duration(timings.ValueAtPercentile(p)))),
+		})
+	}
+	return res
+}
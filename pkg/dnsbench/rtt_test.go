@@ -0,0 +1,93 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_Run_networkRTT(t *testing.T) {
+	s := NewServer("tcp", func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, true, 1)
+	bench.NetworkRTT = "tcp"
+	bench.NetworkRTTSamples = 3
+
+	rs, err := bench.Run(context.Background())
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.NotNil(t, merged.RTT)
+	assert.Equal(t, "tcp", merged.RTT.Method)
+	assert.Equal(t, s.Addr, merged.RTT.Target)
+	assert.Equal(t, int64(3), merged.RTT.Hist.TotalCount())
+	assert.Zero(t, merged.RTT.Failed)
+	assert.Equal(t, int64(4), merged.Counters.Total, "RTT samples are not counted as queries")
+}
+
+func TestBenchmark_tcpConnectRTT_refused(t *testing.T) {
+	// the port of the closed listener refuses connections, which still measures the RTT
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	b := Benchmark{ConnectTimeout: time.Second}
+	d, err := b.tcpConnectRTT(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Positive(t, d)
+}
+
+func TestRTTStats_processing(t *testing.T) {
+	r := RTTStats{Method: "tcp", Target: "127.0.0.1:53", Hist: hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Second.Nanoseconds(), 3)}
+	for _, v := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond} {
+		require.NoError(t, r.Hist.RecordValue(v.Nanoseconds()))
+	}
+	assert.InDelta(t, 20*time.Millisecond, r.baseline(), float64(100*time.Microsecond))
+	assert.InDelta(t, 5*time.Millisecond, r.processing(25*time.Millisecond), float64(100*time.Microsecond))
+	assert.Zero(t, r.processing(15*time.Millisecond), "processing latency is never negative")
+
+	timings := hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Second.Nanoseconds(), 3)
+	require.NoError(t, timings.RecordValue((25 * time.Millisecond).Nanoseconds()))
+	var buf bytes.Buffer
+	printRTT(&buf, &r, timings, []float64{50})
+	assert.Contains(t, buf.String(), "Network RTT baseline to 127.0.0.1:53 by tcp, 3 samples")
+	assert.Contains(t, buf.String(), "\t p50:\t\t 5ms\n")
+
+	j := newJSONRTT(&r, timings, []float64{50})
+	assert.Equal(t, int64(3), j.TotalSamples)
+	assert.InDelta(t, 5, j.ProcessingMeanMs, 0.1)
+	require.Len(t, j.ProcessingPercentiles, 1)
+}
+
+func TestBenchmark_normalize_networkRTT(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", NetworkRTT: "tcp"}
+	require.NoError(t, b.normalize())
+	assert.Equal(t, defaultRTTSamples, b.NetworkRTTSamples)
+
+	b = Benchmark{Server: "8.8.8.8", NetworkRTT: "udp"}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", NetworkRTT: "icmp", NetworkRTTSamples: -1}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "https://dns.example/dns-query", NetworkRTT: "tcp"}
+	require.NoError(t, b.normalize())
+	host, port, err := b.rttTarget()
+	require.NoError(t, err)
+	assert.Equal(t, "dns.example", host)
+	assert.Equal(t, "443", port)
+}
@@ -0,0 +1,132 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+// SearchStats holds statistics of the lookups of relative names expanded with the search domains, see Benchmark.SearchExpansion.
+type SearchStats struct {
+	// Lookups counts the lookups of relative names and Queries counts the queries sent by the lookups.
+	Lookups int64
+	Queries int64
+	// Misses counts the queries answered by NXDOMAIN or without answer, after which the next name of the search list was tried.
+	Misses int64
+	// LookupHist holds latencies of the whole lookups and AnswerHist holds latencies of the last query of each lookup,
+	// the difference is the overhead of the search domain expansion.
+	LookupHist *hdrhistogram.Histogram
+	AnswerHist *hdrhistogram.Histogram
+}
+
+func newSearchStats(template *hdrhistogram.Histogram) *SearchStats {
+	return &SearchStats{
+		LookupHist: hdrhistogram.New(template.LowestTrackableValue(), template.HighestTrackableValue(), int(template.SignificantFigures())),
+		AnswerHist: hdrhistogram.New(template.LowestTrackableValue(), template.HighestTrackableValue(), int(template.SignificantFigures())),
+	}
+}
+
+func (s *SearchStats) add(o *SearchStats) {
+	s.Lookups += o.Lookups
+	s.Queries += o.Queries
+	s.Misses += o.Misses
+	if o.LookupHist != nil {
+		if s.LookupHist == nil {
+			s.LookupHist = hdrhistogram.New(o.LookupHist.LowestTrackableValue(), o.LookupHist.HighestTrackableValue(), int(o.LookupHist.SignificantFigures()))
+		}
+		s.LookupHist.Merge(o.LookupHist)
+	}
+	if o.AnswerHist != nil {
+		if s.AnswerHist == nil {
+			s.AnswerHist = hdrhistogram.New(o.AnswerHist.LowestTrackableValue(), o.AnswerHist.HighestTrackableValue(), int(o.AnswerHist.SignificantFigures()))
+		}
+		s.AnswerHist.Merge(o.AnswerHist)
+	}
+}
+
+// overhead returns the mean time added to the lookups by the queries of the names missed before the answered one.
+func (s *SearchStats) overhead() time.Duration {
+	if s.LookupHist == nil || s.LookupHist.TotalCount() == 0 {
+		return 0
+	}
+	return time.Duration(s.LookupHist.Mean() - s.AnswerHist.Mean())
+}
+
+// searchNames returns the names tried by the lookup of the relative name in order, names with fewer than Benchmark.Ndots dots are qualified
+// with the search domains first and tried as they are last, other names are tried as they are first, the same way glibc stub resolver does.
+func (b *Benchmark) searchNames(name string) []string {
+	relative := strings.TrimSuffix(name, ".")
+	names := make([]string, 0, len(b.SearchDomains)+1)
+	for _, d := range b.SearchDomains {
+		names = append(names, dns.Fqdn(relative+"."+strings.Trim(d, ".")))
+	}
+	if strings.Count(relative, ".") >= b.Ndots {
+		return append([]string{name}, names...)
+	}
+	return append(names, name)
+}
+
+// searchMiss checks whether the next name of the search list is tried after the response.
+func searchMiss(resp *dns.Msg) bool {
+	return resp.Rcode == dns.RcodeNameError || (resp.Rcode == dns.RcodeSuccess && len(resp.Answer) == 0)
+}
+
+// search looks up the relative name of the query by trying the names of the search list until the response is not a miss, the response
+// of the last name tried is returned. Returned attempts include the attempts of all the queries sent.
+func (b *Benchmark) search(ctx context.Context, query queryFunc, m *dns.Msg, rando *rand.Rand, st *ResultStats, log *logger) (*dns.Msg, int, error) {
+	start := time.Now()
+	names := b.searchNames(m.Question[0].Name)
+	st.Search.Lookups++
+
+	var resp *dns.Msg
+	var attempts int
+	var queryStart time.Time
+	for i, name := range names {
+		if i > 0 {
+			if !searchMiss(resp) {
+				break
+			}
+			st.Search.Misses++
+			if !b.useQuic {
+				m.Id = uint16(rando.Uint32())
+			}
+		}
+		m.Question[0].Name = name
+		st.Search.Queries++
+		queryStart = time.Now()
+		r, a, err := b.exchange(ctx, query, m, log)
+		attempts += a
+		if err != nil {
+			return r, attempts, err
+		}
+		resp = r
+	}
+	st.Search.AnswerHist.RecordValue(time.Since(queryStart).Nanoseconds())
+	st.Search.LookupHist.RecordValue(time.Since(start).Nanoseconds())
+	return resp, attempts, nil
+}
+
+func printSearch(w io.Writer, s *SearchStats) {
+	if s.Lookups == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Search domain expansion:")
+	fmt.Fprintf(w, "\tLookups of relative names:\t%s\n", highlightStr(s.Lookups))
+	fmt.Fprintf(w, "\tQueries per lookup:\t\t%s\n", highlightStr(fmt.Sprintf("%0.2f", float64(s.Queries)/float64(s.Lookups))))
+	fmt.Fprintf(w, "\tSearch misses:\t\t\t%s\n", highlightStr(s.Misses))
+	if s.LookupHist == nil || s.LookupHist.TotalCount() == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\tMean expansion overhead:\t%s\n", highlightStr(roundDuration(s.overhead())))
+	fmt.Fprintln(w, "Lookup timings,", highlightStr(s.LookupHist.TotalCount()), "datapoints")
+	printTimings(w, s.LookupHist)
+	fmt.Fprintln(w, "Timings of the answered names,", highlightStr(s.AnswerHist.TotalCount()), "datapoints")
+	printTimings(w, s.AnswerHist)
+}
@@ -0,0 +1,81 @@
+package dnsbench
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_searchNames(t *testing.T) {
+	b := Benchmark{SearchDomains: []string{"default.svc.cluster.local", "svc.cluster.local."}, Ndots: 5}
+	assert.Equal(t, []string{"api.default.svc.cluster.local.", "api.svc.cluster.local.", "api."}, b.searchNames("api."))
+
+	b.Ndots = 1
+	assert.Equal(t, []string{"example.com.", "example.com.default.svc.cluster.local.", "example.com.svc.cluster.local."}, b.searchNames("example.com."),
+		"names with at least ndots dots are tried as they are first")
+}
+
+func TestBenchmark_Run_searchExpansion(t *testing.T) {
+	var mu sync.Mutex
+	queried := make(map[string]int)
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		queried[r.Question[0].Name]++
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Name == "api.svc.cluster.local." || r.Question[0].Name == "example.org." {
+			ret.Answer = append(ret.Answer, A(r.Question[0].Name+" 30 IN A 127.0.0.1"))
+		} else {
+			ret.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = []string{"api", "example.org", "fqdn.example.org."}
+	bench.Types = []string{"A"}
+	bench.Concurrency = 1
+	bench.SearchDomains = []string{"default.svc.cluster.local", "svc.cluster.local"}
+	bench.Ndots = 5
+	bench.SearchExpansion = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(3), merged.Counters.Total)
+	require.NotNil(t, merged.Search)
+	assert.Equal(t, int64(2), merged.Search.Lookups, "fully qualified names are not expanded")
+	// api is answered for the second search domain, example.org after both search domains are missed
+	assert.Equal(t, int64(5), merged.Search.Queries)
+	assert.Equal(t, int64(3), merged.Search.Misses)
+	assert.Equal(t, int64(2), merged.Search.LookupHist.TotalCount())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{
+		"api.default.svc.cluster.local.":         1,
+		"api.svc.cluster.local.":                 1,
+		"example.org.default.svc.cluster.local.": 1,
+		"example.org.svc.cluster.local.":         1,
+		"example.org.":                           1,
+		"fqdn.example.org.":                      1,
+	}, queried)
+}
+
+func TestBenchmark_normalize_searchExpansion(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", SearchExpansion: true}
+	assert.Error(t, b.normalize(), "search domains are required")
+
+	b = Benchmark{Server: "8.8.8.8", SearchExpansion: true, SearchDomains: []string{"svc.cluster.local"}, SimulateStub: true}
+	assert.Error(t, b.normalize())
+}
@@ -0,0 +1,25 @@
+package dnsbench
+
+import "time"
+
+// random streams of the benchmark, the generators of different streams are seeded differently, so that their sequences are not correlated.
+const (
+	workerStream int64 = iota
+	workerLimitStream
+	globalLimitStream
+	rampStream
+	scheduleStream
+	ptrStream
+	faultStream
+	samplerStream
+)
+
+// randSeed returns the seed of the random generator of the stream, index distinguishes the generators of the same stream like the generators of the workers.
+// When Benchmark.Seed is not set, the seed is derived from the current time.
+func (b *Benchmark) randSeed(stream, index int64) int64 {
+	seed := b.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return seed + stream<<32 + index
+}
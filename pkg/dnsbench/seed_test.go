@@ -0,0 +1,81 @@
+package dnsbench
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_Run_seed(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		queries = append(queries, fmt.Sprintf("%d %s %s", r.Id, r.Question[0].Name, dns.TypeToString[r.Question[0].Qtype]))
+		mu.Unlock()
+		replyHandler(w, r)
+	})
+	defer s.Close()
+
+	run := func(seed int64) []string {
+		mu.Lock()
+		queries = nil
+		mu.Unlock()
+
+		bench := createBenchmark(s.Addr, false, 0.5)
+		bench.Queries = []string{"{rand:8}.example.org"}
+		bench.Types = []string{"A:50", "AAAA:30", "MX:20"}
+		bench.Count = 20
+		bench.DNS0x20 = true
+		bench.Seed = seed
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_, err := bench.Run(ctx)
+		require.NoError(t, err, "expected no error from benchmark run")
+
+		mu.Lock()
+		defer mu.Unlock()
+		// the queries of the concurrent workers interleave differently in each run
+		sort.Strings(queries)
+		return append([]string(nil), queries...)
+	}
+
+	first := run(42)
+	require.NotEmpty(t, first)
+	assert.Less(t, len(first), 40, "some queries are skipped based on probability")
+	assert.Equal(t, first, run(42), "runs with the same seed issue identical queries")
+	assert.NotEqual(t, first, run(43))
+}
+
+func TestBenchmark_Run_seed_datapointSampleRate(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	run := func(seed int64) []int {
+		bench := createBenchmark(s.Addr, false, 1)
+		bench.Count = 100
+		bench.DatapointSampleRate = 0.3
+		bench.Seed = seed
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		rs, err := bench.Run(ctx)
+		require.NoError(t, err, "expected no error from benchmark run")
+
+		var sampled []int
+		for _, st := range rs {
+			sampled = append(sampled, len(st.Timings))
+		}
+		return sampled
+	}
+
+	assert.Equal(t, run(42), run(42), "runs with the same seed sample the same datapoints")
+}
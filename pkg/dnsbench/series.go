@@ -0,0 +1,128 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// seriesBucket are results of queries sent during a single interval of the benchmark.
+type seriesBucket struct {
+	// Offset is the start of the interval relative to the start of the benchmark.
+	Offset           time.Duration
+	Responses        int64
+	Errors           int64
+	QueriesPerSecond float64
+	ErrorRate        float64
+	MeanMs           float64
+	P99Ms            float64
+}
+
+// timeSeries aggregates the datapoints and the times of failed queries into fixed intervals starting at the earliest of them,
+// weight is the number of queries represented by each of them.
+func timeSeries(timings []Datapoint, errorTimes []time.Time, interval time.Duration, weight float64) []seriesBucket {
+	if interval <= 0 || (len(timings) == 0 && len(errorTimes) == 0) {
+		return nil
+	}
+	var start time.Time
+	for _, t := range timings {
+		if start.IsZero() || t.Start.Before(start) {
+			start = t.Start
+		}
+	}
+	for _, t := range errorTimes {
+		if start.IsZero() || t.Before(start) {
+			start = t
+		}
+	}
+
+	var durations [][]float64
+	var errs []int64
+	grow := func(i int) {
+		for len(durations) <= i {
+			durations = append(durations, nil)
+			errs = append(errs, 0)
+		}
+	}
+	for _, t := range timings {
+		i := int(t.Start.Sub(start) / interval)
+		grow(i)
+		durations[i] = append(durations[i], t.Duration)
+	}
+	for _, t := range errorTimes {
+		i := int(t.Sub(start) / interval)
+		grow(i)
+		errs[i]++
+	}
+
+	res := make([]seriesBucket, 0, len(durations))
+	for i, d := range durations {
+		b := seriesBucket{Offset: time.Duration(i) * interval, Responses: int64(math.Round(float64(len(d)) * weight)), Errors: int64(math.Round(float64(errs[i]) * weight))}
+		total := b.Responses + b.Errors
+		b.QueriesPerSecond = math.Round(float64(total)/interval.Seconds()*100) / 100
+		if total > 0 {
+			b.ErrorRate = math.Round(float64(b.Errors)/float64(total)*10000) / 10000
+		}
+		b.MeanMs, b.P99Ms = meanP99(d)
+		res = append(res, b)
+	}
+	return res
+}
+
+// meanP99 returns mean and 99th percentile of the durations in milliseconds, the durations are sorted in place.
+func meanP99(d []float64) (float64, float64) {
+	if len(d) == 0 {
+		return 0, 0
+	}
+	sort.Float64s(d)
+	var sum float64
+	for _, v := range d {
+		sum += v
+	}
+	return math.Round(sum/float64(len(d))*100) / 100, d[int(math.Ceil(0.99*float64(len(d))))-1]
+}
+
+func printSeries(w io.Writer, interval time.Duration, series []seriesBucket) {
+	if len(series) == 0 {
+		return
+	}
+	lines := make([][]string, 0, len(series))
+	for _, b := range series {
+		lines = append(lines, []string{
+			b.Offset.String(),
+			fmt.Sprintf("%0.1f", b.QueriesPerSecond),
+			strconv.FormatInt(b.Errors, 10),
+			fmt.Sprintf("%0.2f%%", b.ErrorRate*100),
+			fmt.Sprintf("%0.2fms", b.MeanMs),
+			fmt.Sprintf("%0.2fms", b.P99Ms),
+		})
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Time series,", highlightStr(interval), "intervals:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Time", "QPS", "Errors", "Error rate", "Mean", "p99"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+}
+
+func writeSeriesCsv(file string, series []seriesBucket) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create file for time series export due to '%v'", err)
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, "Time (s), Responses, Errors, QPS, Error rate, Mean (ms), P99 (ms)")
+	for _, b := range series {
+		fmt.Fprintf(f, "%g, %d, %d, %g, %g, %g, %g\n", b.Offset.Seconds(), b.Responses, b.Errors, b.QueriesPerSecond, b.ErrorRate, b.MeanMs, b.P99Ms)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package dnsbench
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_timeSeries(t *testing.T) {
+	start := time.Now()
+	timings := []Datapoint{
+		{Duration: 10, Start: start},
+		{Duration: 20, Start: start.Add(500 * time.Millisecond)},
+		{Duration: 30, Start: start.Add(2500 * time.Millisecond)},
+	}
+	errs := []time.Time{start.Add(2 * time.Second)}
+
+	series := timeSeries(timings, errs, time.Second, 1)
+
+	require.Len(t, series, 3)
+	assert.Equal(t, seriesBucket{Offset: 0, Responses: 2, QueriesPerSecond: 2, MeanMs: 15, P99Ms: 20}, series[0])
+	assert.Equal(t, seriesBucket{Offset: time.Second}, series[1])
+	assert.Equal(t, seriesBucket{Offset: 2 * time.Second, Responses: 1, Errors: 1, QueriesPerSecond: 2, ErrorRate: 0.5, MeanMs: 30, P99Ms: 30}, series[2])
+}
+
+func Test_timeSeries_disabled(t *testing.T) {
+	assert.Nil(t, timeSeries([]Datapoint{{Duration: 10, Start: time.Now()}}, nil, 0, 1))
+	assert.Nil(t, timeSeries(nil, nil, time.Second, 1))
+}
+
+func Test_printSeries(t *testing.T) {
+	buf := bytes.Buffer{}
+	printSeries(&buf, time.Second, []seriesBucket{{Offset: time.Second, Responses: 9, Errors: 1, QueriesPerSecond: 10, ErrorRate: 0.1, MeanMs: 5, P99Ms: 7}})
+
+	out := buf.String()
+	assert.Contains(t, out, "Time series, 1s intervals:")
+	assert.Contains(t, out, "10.00%")
+	assert.Contains(t, out, "7.00ms")
+}
+
+func Test_writeSeriesCsv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "series.csv")
+
+	err := writeSeriesCsv(file, []seriesBucket{{Offset: time.Second, Responses: 9, Errors: 1, QueriesPerSecond: 10, ErrorRate: 0.1, MeanMs: 5, P99Ms: 7}})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "Time (s), Responses, Errors, QPS, Error rate, Mean (ms), P99 (ms)\n1, 9, 1, 10, 0.1, 5, 7\n", string(content))
+}
+
+func Test_timeSeries_weight(t *testing.T) {
+	now := time.Now()
+	timings := []Datapoint{{Duration: 10, Start: now}, {Duration: 20, Start: now.Add(100 * time.Millisecond)}}
+
+	series := timeSeries(timings, []time.Time{now}, time.Second, 10)
+
+	require.Len(t, series, 1)
+	assert.Equal(t, int64(20), series[0].Responses)
+	assert.Equal(t, int64(10), series[0].Errors)
+	assert.Equal(t, float64(30), series[0].QueriesPerSecond)
+}
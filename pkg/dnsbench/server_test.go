@@ -0,0 +1,168 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// Server represents simple DNS server.
+type Server struct {
+	Addr  string
+	inner *dns.Server
+}
+
+// Close shuts down running DNS server instance.
+func (s *Server) Close() {
+	s.inner.Shutdown()
+}
+
+// NewServer creates and starts new DNS server instance.
+func NewServer(network string, f dns.HandlerFunc) *Server {
+	return NewTsigServer(network, nil, f)
+}
+
+// NewTsigServer creates and starts new DNS server instance using the TSIG secrets for signing responses.
+func NewTsigServer(network string, tsigSecret map[string]string, f dns.HandlerFunc) *Server {
+	ch := make(chan bool)
+	s := &dns.Server{TsigSecret: tsigSecret, MsgAcceptFunc: acceptUpdates}
+	s.Handler = f
+
+	for i := 0; i < 10; i++ {
+		s.Listener, _ = net.Listen("tcp", "127.0.0.1:0")
+		if network == "udp" {
+			if s.Listener == nil {
+				continue
+			}
+			s.PacketConn, _ = net.ListenPacket("udp", s.Listener.Addr().String())
+			if s.PacketConn != nil {
+				break
+			}
+		}
+		if s.Listener != nil {
+			break
+		}
+	}
+	if s.Listener == nil {
+		panic("failed to create new client")
+	}
+
+	s.NotifyStartedFunc = func() { close(ch) }
+	go func() {
+		s.ActivateAndServe()
+	}()
+
+	<-ch
+	return &Server{inner: s, Addr: s.Listener.Addr().String()}
+}
+
+// acceptUpdates accepts UPDATE messages rejected by the default accept function of the server, other messages are accepted as usual.
+func acceptUpdates(dh dns.Header) dns.MsgAcceptAction {
+	if opcode := int(dh.Bits>>11) & 0xF; opcode == dns.OpcodeUpdate && dh.Bits&(1<<15) == 0 {
+		return dns.MsgAccept
+	}
+	return dns.DefaultMsgAcceptFunc(dh)
+}
+
+// DoQServer represents simple DoQ (DNS over QUIC) server.
+type DoQServer struct {
+	Addr     string
+	listener *quic.Listener
+}
+
+// Close shuts down running DoQ server instance.
+func (s *DoQServer) Close() {
+	s.listener.Close()
+}
+
+// NewDoQServer creates and starts new DoQ server instance, each query is answered with the response returned by f.
+func NewDoQServer(f func(r *dns.Msg) *dns.Msg) *DoQServer {
+	listener, err := quic.ListenAddr("127.0.0.1:0", generateTLSConfig("doq"), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					go serveDoQStream(stream, f)
+				}
+			}()
+		}
+	}()
+
+	return &DoQServer{Addr: listener.Addr().String(), listener: listener}
+}
+
+func serveDoQStream(stream quic.Stream, f func(r *dns.Msg) *dns.Msg) {
+	defer stream.Close()
+
+	var length uint16
+	if err := binary.Read(stream, binary.BigEndian, &length); err != nil {
+		return
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		return
+	}
+	req := dns.Msg{}
+	if err := req.Unpack(buf); err != nil {
+		return
+	}
+	pack, err := f(&req).Pack()
+	if err != nil {
+		return
+	}
+	resp := make([]byte, 2+len(pack))
+	binary.BigEndian.PutUint16(resp, uint16(len(pack)))
+	copy(resp[2:], pack)
+	stream.Write(resp)
+}
+
+// generateTLSConfig generates TLS configuration with self-signed certificate for localhost.
+func generateTLSConfig(nextProtos ...string) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		NextProtos:   nextProtos,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
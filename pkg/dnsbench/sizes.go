@@ -0,0 +1,213 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/olekukonko/tablewriter"
+)
+
+// maxMessageSize is the largest size of DNS message.
+const maxMessageSize = dns.MaxMsgSize
+
+// SizeStats holds statistics of sizes of the queries and the responses in bytes.
+type SizeStats struct {
+	// Responses holds the sizes of the responses.
+	Responses     *hdrhistogram.Histogram
+	QueryBytes    int64
+	ResponseBytes int64
+
+	// QueryPadding and ResponsePadding are the bytes of the EDNS0 Padding options included in QueryBytes and ResponseBytes.
+	QueryPadding    int64
+	ResponsePadding int64
+}
+
+func newSizeStats() *SizeStats {
+	return &SizeStats{Responses: hdrhistogram.New(1, maxMessageSize, 3)}
+}
+
+func (s *SizeStats) add(o *SizeStats) {
+	s.Responses.Merge(o.Responses)
+	s.QueryBytes += o.QueryBytes
+	s.ResponseBytes += o.ResponseBytes
+	s.QueryPadding += o.QueryPadding
+	s.ResponsePadding += o.ResponsePadding
+}
+
+// Amplification returns the ratio of the bytes of the responses to the bytes of the queries.
+func (s *SizeStats) Amplification() float64 {
+	if s.QueryBytes == 0 {
+		return 0
+	}
+	return float64(s.ResponseBytes) / float64(s.QueryBytes)
+}
+
+// transport returns the transport of the queries used as the key of the sizes, the transport of DNSCrypt is the underlying UDP or TCP.
+func (b *Benchmark) transport() string {
+	switch {
+	case b.useDoH:
+		return "https"
+	case b.useQuic:
+		return "quic"
+	case b.DOT:
+		return "tls"
+	case b.TCP:
+		return "tcp"
+	}
+	return "udp"
+}
+
+// recordSizes records the sizes of the query and its response by the transport and by the question type.
+func (rs *ResultStats) recordSizes(req, resp *dns.Msg, transport string) {
+	if rs.Sizes == nil {
+		return
+	}
+	if rs.tcpRetried {
+		transport = "tcp"
+	}
+	q, r := int64(req.Len()), int64(resp.Len())
+	qp, rp := paddingSize(req), paddingSize(resp)
+	recordSize(rs.Sizes, transport, q, r, qp, rp)
+	recordSize(rs.QtypeSizes, dns.TypeToString[req.Question[0].Qtype], q, r, qp, rp)
+}
+
+func recordSize(sizes map[string]*SizeStats, key string, q, r, qp, rp int64) {
+	s, ok := sizes[key]
+	if !ok {
+		s = newSizeStats()
+		sizes[key] = s
+	}
+	s.Responses.RecordValue(r)
+	s.QueryBytes += q
+	s.ResponseBytes += r
+	s.QueryPadding += qp
+	s.ResponsePadding += rp
+}
+
+func mergeSizes(dst, src map[string]*SizeStats) map[string]*SizeStats {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]*SizeStats)
+	}
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = newSizeStats()
+		}
+		dst[k].add(v)
+	}
+	return dst
+}
+
+// printSizes prints the sizes of the responses by transport and by question type, the sizes by question type are printed
+// only when there are at least two question types.
+func printSizes(w io.Writer, byTransport, byQtype map[string]*SizeStats) {
+	if len(byTransport) == 0 {
+		return
+	}
+	printSizesTable(w, "DNS response sizes:", "Transport", byTransport)
+	if len(byQtype) > 1 {
+		printSizesTable(w, "DNS response sizes by question type:", "Type", byQtype)
+	}
+}
+
+func printSizesTable(w io.Writer, title, keyHeader string, sizes map[string]*SizeStats) {
+	keys := make([]string, 0, len(sizes))
+	for k := range sizes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, title)
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{keyHeader, "Responses", "Avg", "p50", "p95", "p99", "Max", "Amplification"})
+	table.SetBorder(false)
+	for _, k := range keys {
+		h := sizes[k].Responses
+		table.Append([]string{
+			k,
+			strconv.FormatInt(h.TotalCount(), 10),
+			fmt.Sprintf("%0.1f B", h.Mean()),
+			fmt.Sprintf("%d B", h.ValueAtQuantile(50)),
+			fmt.Sprintf("%d B", h.ValueAtQuantile(95)),
+			fmt.Sprintf("%d B", h.ValueAtQuantile(99)),
+			fmt.Sprintf("%d B", h.Max()),
+			fmt.Sprintf("%0.2fx", sizes[k].Amplification()),
+		})
+	}
+	table.Render()
+}
+
+type jsonSizeStats struct {
+	Responses     int64   `json:"responses"`
+	AvgBytes      float64 `json:"avgBytes"`
+	P50Bytes      int64   `json:"p50Bytes"`
+	P95Bytes      int64   `json:"p95Bytes"`
+	P99Bytes      int64   `json:"p99Bytes"`
+	MaxBytes      int64   `json:"maxBytes"`
+	Amplification float64 `json:"amplification"`
+}
+
+func jsonSizes(sizes map[string]*SizeStats) map[string]jsonSizeStats {
+	if len(sizes) == 0 {
+		return nil
+	}
+	res := make(map[string]jsonSizeStats, len(sizes))
+	for k, s := range sizes {
+		h := s.Responses
+		res[k] = jsonSizeStats{
+			Responses:     h.TotalCount(),
+			AvgBytes:      math.Round(h.Mean()*10) / 10,
+			P50Bytes:      h.ValueAtQuantile(50),
+			P95Bytes:      h.ValueAtQuantile(95),
+			P99Bytes:      h.ValueAtQuantile(99),
+			MaxBytes:      h.Max(),
+			Amplification: math.Round(s.Amplification()*100) / 100,
+		}
+	}
+	return res
+}
+
+type remoteSizes struct {
+	Responses     *hdrhistogram.Snapshot `json:"responses"`
+	QueryBytes    int64                  `json:"queryBytes"`
+	ResponseBytes int64                  `json:"responseBytes"`
+
+	QueryPadding    int64 `json:"queryPadding,omitempty"`
+	ResponsePadding int64 `json:"responsePadding,omitempty"`
+}
+
+func exportSizes(sizes map[string]*SizeStats) map[string]*remoteSizes {
+	if sizes == nil {
+		return nil
+	}
+	res := make(map[string]*remoteSizes, len(sizes))
+	for k, s := range sizes {
+		res[k] = &remoteSizes{
+			Responses: s.Responses.Export(), QueryBytes: s.QueryBytes, ResponseBytes: s.ResponseBytes,
+			QueryPadding: s.QueryPadding, ResponsePadding: s.ResponsePadding,
+		}
+	}
+	return res
+}
+
+func importSizes(sizes map[string]*remoteSizes) map[string]*SizeStats {
+	if sizes == nil {
+		return nil
+	}
+	res := make(map[string]*SizeStats, len(sizes))
+	for k, s := range sizes {
+		res[k] = &SizeStats{
+			Responses: hdrhistogram.Import(s.Responses), QueryBytes: s.QueryBytes, ResponseBytes: s.ResponseBytes,
+			QueryPadding: s.QueryPadding, ResponsePadding: s.ResponsePadding,
+		}
+	}
+	return res
+}
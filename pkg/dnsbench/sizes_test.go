@@ -0,0 +1,79 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultStats_recordSizes(t *testing.T) {
+	rs := ResultStats{Sizes: make(map[string]*SizeStats), QtypeSizes: make(map[string]*SizeStats)}
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, 1)})
+
+	rs.recordSizes(req, resp, "udp")
+	rs.tcpRetried = true
+	rs.recordSizes(req, resp, "udp")
+
+	require.Contains(t, rs.Sizes, "udp")
+	require.Contains(t, rs.Sizes, "tcp", "retried responses are recorded as TCP")
+	assert.Equal(t, int64(req.Len()), rs.Sizes["udp"].QueryBytes)
+	assert.Equal(t, int64(resp.Len()), rs.Sizes["udp"].ResponseBytes)
+	assert.Equal(t, float64(resp.Len())/float64(req.Len()), rs.Sizes["udp"].Amplification())
+	assert.Equal(t, int64(2), rs.QtypeSizes["A"].Responses.TotalCount())
+
+	merged := mergeSizes(nil, rs.Sizes)
+	merged = mergeSizes(merged, rs.Sizes)
+	assert.Equal(t, int64(2), merged["udp"].Responses.TotalCount())
+	assert.Equal(t, 2*int64(resp.Len()), merged["udp"].ResponseBytes)
+	assert.Equal(t, rs.Sizes["udp"].Amplification(), merged["udp"].Amplification())
+}
+
+func TestBenchmark_Run_sizes(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		q := r.Question[0]
+		if q.Qtype == dns.TypeA {
+			for i := 0; i < 10; i++ {
+				ret.Answer = append(ret.Answer, &dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, byte(i))})
+			}
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.Len(t, merged.Sizes, 1)
+	assert.Equal(t, int64(4), merged.Sizes["udp"].Responses.TotalCount())
+	require.Len(t, merged.QtypeSizes, 2)
+	assert.Greater(t, merged.QtypeSizes["A"].Amplification(), 3.0)
+	assert.InDelta(t, 1.0, merged.QtypeSizes["AAAA"].Amplification(), 0.01)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "DNS response sizes:")
+	assert.Contains(t, buf.String(), "DNS response sizes by question type:")
+
+	bench.JSON = true
+	buf.Reset()
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), `"responseSizesByTransport":{"udp":{"responses":4,`)
+	assert.Contains(t, buf.String(), `"responseSizesByQuestionType":{"A":{"responses":2,`)
+}
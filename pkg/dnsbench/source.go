@@ -0,0 +1,121 @@
+package dnsbench
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// maxSourceRange is a maximum number of addresses rotated from the source address range.
+const maxSourceRange = 1 << 16
+
+// sourceAddrs are local addresses from which the queries are sent, the addresses are rotated for each new connection.
+type sourceAddrs struct {
+	base net.IP
+	// size is a number of the rotated addresses, offset is added to base address to get the first rotated address.
+	size   uint64
+	offset uint64
+	next   atomic.Uint64
+}
+
+// parseSource parses source IP address, source address range in CIDR notation or a name of the network interface, whose address is used.
+// Only one of them can be specified, nil is returned if none is specified.
+func parseSource(ip, iface, ipRange string) (*sourceAddrs, error) {
+	specified := 0
+	for _, s := range []string{ip, iface, ipRange} {
+		if s != "" {
+			specified++
+		}
+	}
+	if specified == 0 {
+		return nil, nil
+	}
+	if specified > 1 {
+		return nil, errors.New("only one of --source-ip, --interface and --source-ip-range can be specified")
+	}
+
+	switch {
+	case ip != "":
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, fmt.Errorf("invalid source IP '%s'", ip)
+		}
+		return &sourceAddrs{base: parsed, size: 1}, nil
+	case iface != "":
+		parsed, err := interfaceIP(iface)
+		if err != nil {
+			return nil, err
+		}
+		return &sourceAddrs{base: parsed, size: 1}, nil
+	default:
+		_, subnet, err := net.ParseCIDR(ipRange)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source IP range '%s', expected subnet in CIDR notation", ipRange)
+		}
+		ones, bits := subnet.Mask.Size()
+		s := sourceAddrs{base: subnet.IP, size: maxSourceRange}
+		if bits-ones < 16 {
+			s.size = 1 << (bits - ones)
+		}
+		if bits == 32 && bits-ones >= 2 {
+			// skip network and broadcast addresses of IPv4 subnets
+			s.size -= 2
+			s.offset = 1
+		}
+		return &s, nil
+	}
+}
+
+// interfaceIP returns address of the network interface, IPv4 addresses are preferred.
+func interfaceIP(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown network interface '%s'", name)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses of network interface '%s' due to '%v'", name, err)
+	}
+	var res net.IP
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok {
+			if ipNet.IP.To4() != nil {
+				return ipNet.IP, nil
+			}
+			if res == nil {
+				res = ipNet.IP
+			}
+		}
+	}
+	if res == nil {
+		return nil, fmt.Errorf("network interface '%s' has no address", name)
+	}
+	return res, nil
+}
+
+// addr returns next local address for the network, nil is returned if no source addresses are specified.
+func (s *sourceAddrs) addr(network string) net.Addr {
+	if s == nil {
+		return nil
+	}
+	ip := make(net.IP, len(s.base))
+	copy(ip, s.base)
+	carry := s.offset + (s.next.Add(1)-1)%s.size
+	for i := len(ip) - 1; i >= 0 && carry > 0; i-- {
+		sum := uint64(ip[i]) + carry
+		ip[i] = byte(sum)
+		carry = sum >> 8
+	}
+
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: ip}
+	}
+	return &net.TCPAddr{IP: ip}
+}
+
+// dialer returns dialer for the network, which binds connections to the source addresses.
+func (b *Benchmark) dialer(network string) *net.Dialer {
+	return &net.Dialer{Timeout: b.ConnectTimeout, KeepAlive: b.TCPKeepalive, LocalAddr: b.source.addr(network)}
+}
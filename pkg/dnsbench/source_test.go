@@ -0,0 +1,81 @@
+package dnsbench
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseSource(t *testing.T) {
+	s, err := parseSource("", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	assert.Nil(t, s.addr("udp"))
+
+	s, err = parseSource("127.0.0.2", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, &net.UDPAddr{IP: net.ParseIP("127.0.0.2")}, s.addr("udp"))
+	assert.Equal(t, &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}, s.addr("tcp"))
+
+	s, err = parseSource("", "", "192.0.2.0/30")
+	require.NoError(t, err)
+	var ips []string
+	for i := 0; i < 3; i++ {
+		ips = append(ips, s.addr("tcp").(*net.TCPAddr).IP.String())
+	}
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2", "192.0.2.1"}, ips)
+
+	s, err = parseSource("", "", "2001:db8::/120")
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::", s.addr("tcp").(*net.TCPAddr).IP.String())
+	assert.Equal(t, "2001:db8::1", s.addr("tcp").(*net.TCPAddr).IP.String())
+
+	for _, tt := range []struct{ ip, iface, ipRange string }{
+		{ip: "foo"},
+		{ipRange: "192.0.2.0"},
+		{iface: "nonexistent0"},
+		{ip: "127.0.0.1", ipRange: "127.0.0.0/24"},
+	} {
+		_, err := parseSource(tt.ip, tt.iface, tt.ipRange)
+		assert.Error(t, err, tt)
+	}
+}
+
+func Test_do_classic_dns_source_ip_range(t *testing.T) {
+	var mu sync.Mutex
+	sources := make(map[string]int)
+	s := NewServer(tcp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		sources[w.RemoteAddr().(*net.TCPAddr).IP.String()]++
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, true, 1)
+	bench.SourceIPRange = "127.0.0.0/29"
+	bench.QperConn = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	for _, r := range rs {
+		assert.Equal(t, int64(2), r.Counters.Success, "Run(ctx) success counter")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, sources, 4)
+	assert.NotContains(t, sources, "127.0.0.0")
+}
@@ -0,0 +1,93 @@
+package dnsbench
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// jsonStatsInterval is a single line of Benchmark.StatsFile, the results of the requests finished in the interval, or since the start
+// of the benchmark when Benchmark.StatsCumulative is set.
+type jsonStatsInterval struct {
+	Time             time.Time        `json:"time"`
+	OffsetSeconds    float64          `json:"offsetSeconds"`
+	TotalRequests    int64            `json:"totalRequests"`
+	TotalErrors      int64            `json:"totalErrors"`
+	QueriesPerSecond float64          `json:"queriesPerSecond"`
+	InFlight         int64            `json:"inFlight"`
+	MeanMs           float64          `json:"meanMs"`
+	P50Ms            float64          `json:"p50Ms"`
+	P99Ms            float64          `json:"p99Ms"`
+	MaxMs            float64          `json:"maxMs"`
+	ResponseRcodes   map[string]int64 `json:"responseRcodes,omitempty"`
+	Final            bool             `json:"final,omitempty"`
+}
+
+// startStatsInterval writes the results of the running benchmark to Benchmark.StatsFile in the interval of Benchmark.StatsInterval
+// and once more when the benchmark ends, the returned function stops the writing.
+func (b *Benchmark) startStatsInterval(live *liveStats) (func(), error) {
+	f, err := os.Create(b.StatsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file for interval stats due to '%v'", err)
+	}
+	enc := json.NewEncoder(f)
+	window := live.addWindow(b)
+	window.cumulative = b.StatsCumulative
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	var last liveSnapshot
+	write := func(s liveSnapshot) {
+		line := jsonStatsInterval{
+			Time:             time.Now(),
+			OffsetSeconds:    s.elapsed.Seconds(),
+			TotalRequests:    s.requests,
+			TotalErrors:      s.errors,
+			QueriesPerSecond: s.qps,
+			InFlight:         s.inFlight,
+			MeanMs:           durationMs(s.mean),
+			P50Ms:            durationMs(s.p50),
+			P99Ms:            durationMs(s.p99),
+			MaxMs:            durationMs(s.max),
+			ResponseRcodes:   make(map[string]int64, len(s.codes)),
+		}
+		select {
+		case <-stop:
+			line.Final = true
+		default:
+		}
+		for i, k := range s.codes {
+			line.ResponseRcodes[dns.RcodeToString[k]] = s.counts[i]
+		}
+		if !b.StatsCumulative {
+			line.TotalRequests -= last.requests
+			line.TotalErrors -= last.errors
+			for i, k := range last.codes {
+				name := dns.RcodeToString[k]
+				if line.ResponseRcodes[name] -= last.counts[i]; line.ResponseRcodes[name] == 0 {
+					delete(line.ResponseRcodes, name)
+				}
+			}
+		}
+		last = s
+		if err := enc.Encode(line); err != nil {
+			b.log.warn("failed to write interval stats", "file", b.StatsFile, "err", err)
+		}
+	}
+	go func() {
+		live.run(window, stop, b.StatsInterval, write)
+		close(done)
+	}()
+	return func() {
+		close(stop)
+		<-done
+		f.Close()
+	}, nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,74 @@
+package dnsbench
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readStatsIntervals(t *testing.T, path string) []jsonStatsInterval {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var lines []jsonStatsInterval
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var l jsonStatsInterval
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &l))
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func TestBenchmark_Run_statsInterval(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	for _, cumulative := range []bool{false, true} {
+		bench := createBenchmark(s.Addr, false, 1)
+		bench.Count = 0
+		bench.Duration = 1100 * time.Millisecond
+		bench.Rate = 100
+		bench.StatsInterval = 300 * time.Millisecond
+		bench.StatsFile = filepath.Join(t.TempDir(), "stats.jsonl")
+		bench.StatsCumulative = cumulative
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		rs, err := bench.Run(ctx)
+		cancel()
+		require.NoError(t, err, "expected no error from benchmark run")
+
+		lines := readStatsIntervals(t, bench.StatsFile)
+		require.GreaterOrEqual(t, len(lines), 3)
+		last := lines[len(lines)-1]
+		assert.True(t, last.Final, "the results are written once more at the end")
+
+		total := Merge(rs).Counters.Total
+		if cumulative {
+			assert.Equal(t, total, last.TotalRequests, "cumulative results contain all the requests")
+			assert.Equal(t, total, last.ResponseRcodes["NOERROR"])
+			continue
+		}
+		var sum int64
+		for _, l := range lines {
+			sum += l.TotalRequests
+		}
+		assert.Equal(t, total, sum, "interval results add up to all the requests")
+		assert.Greater(t, lines[0].P99Ms, 0.0)
+	}
+}
+
+func TestBenchmark_normalize_statsInterval(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", StatsInterval: time.Second}
+	assert.Error(t, b.normalize(), "stats file is required")
+
+	b = Benchmark{Server: "8.8.8.8", StatsInterval: time.Second, StatsFile: "stats.jsonl"}
+	assert.NoError(t, b.normalize())
+}
@@ -0,0 +1,566 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/olekukonko/tablewriter"
+)
+
+type standardReporter struct{}
+
+func (s *standardReporter) print(w io.Writer, b *Benchmark, stats *ResultStats, topErrs orderedMap, t time.Duration) error {
+	timings := stats.Hist
+	codeTotals := stats.Codes
+	totalCounters := *stats.Counters
+	qtypeTotals := stats.Qtypes
+	diff := stats.Diff
+
+	if b.interrupted {
+		errPrint(w, "\nBenchmark was interrupted, reporting results collected so far.\n")
+	}
+	if b.maxRuntimeExceeded {
+		errPrint(w, "\nBenchmark was stopped after max runtime %s, reporting results collected so far.\n", b.MaxRuntime)
+	}
+	printClientWarnings(w, b.usage)
+
+	b.printProgress(w, totalCounters)
+
+	if len(codeTotals) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DNS response codes:")
+		for i := dns.RcodeSuccess; i <= dns.RcodeBadCookie; i++ {
+			printFn := errPrint
+			if i == dns.RcodeSuccess {
+				printFn = successPrint
+			}
+			if c, ok := codeTotals[i]; ok {
+				printFn(w, "\t%s:\t%d\n", dns.RcodeToString[i], c)
+			}
+		}
+	}
+
+	if len(qtypeTotals) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DNS question types:")
+		for k, v := range qtypeTotals {
+			successPrint(w, "\t%s:\t%d\n", k, v)
+		}
+	}
+
+	if len(stats.DoHProtocols) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DoH HTTP protocols:")
+		for k, v := range stats.DoHProtocols {
+			successPrint(w, "\t%s:\t%d\n", k, v)
+		}
+	}
+
+	if stats.DoH != nil {
+		printDoHStats(w, stats.DoH)
+	}
+
+	if len(stats.Families) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Connections by address family:")
+		for k, v := range stats.Families {
+			successPrint(w, "\t%s:\t%d\n", k, v)
+		}
+	}
+
+	if len(stats.KeepaliveTimeouts) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "EDNS TCP keepalive timeouts advertised:")
+		for k, v := range stats.KeepaliveTimeouts {
+			successPrint(w, "\t%s:\t%d\n", k, v)
+		}
+	}
+
+	if len(stats.Attempts) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Attempts per query:")
+		attempts := make([]int, 0, len(stats.Attempts))
+		for k := range stats.Attempts {
+			attempts = append(attempts, k)
+		}
+		sort.Ints(attempts)
+		for _, k := range attempts {
+			printFn := successPrint
+			if k > 1 {
+				printFn = errPrint
+			}
+			printFn(w, "\t%d:\t%d\n", k, stats.Attempts[k])
+		}
+	}
+
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Time taken for tests:\t", highlightStr(roundDuration(t).String()))
+	fmt.Fprintf(w, "Questions per second:\t %s", highlightStr(fmt.Sprintf("%0.1f", float64(totalCounters.Total)/t.Seconds())))
+
+	if tc := timings.TotalCount(); tc > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DNS timings,", highlightStr(tc), "datapoints")
+		printTimingsAt(w, timings, b.reportedPercentiles())
+
+		dist := b.distribution(timings)
+		if b.HistDisplay && tc > 1 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "DNS distribution,", highlightStr(tc), "datapoints")
+
+			printBars(w, dist)
+		}
+	}
+
+	if stats.RTT != nil {
+		printRTT(w, stats.RTT, timings, b.reportedPercentiles())
+	}
+
+	printBreakdown(w, "DNS timings by question type:", "Type", stats.QtypeHists)
+	printTTLs(w, stats.TTLs)
+	printSizes(w, stats.Sizes, stats.QtypeSizes)
+	printPadding(w, stats.Sizes)
+	printBreakdown(w, "DNS timings by response code:", "Rcode", rcodeHistsByName(stats.RcodeHists))
+	printBreakdown(w, "DNS timings by response flags:", "Flags", stats.FlagHists)
+	printCNAMEDepths(w, stats.CNAMEDepthHists)
+	printFailover(w, b.FailoverServers, stats.FailoverHists, stats.FailoverUnanswered)
+	printBursts(w, b.Burst, b.BurstInterval, stats.Bursts, stats.BurstHists)
+	printMalformed(w, stats.Malformed)
+	printBreakdown(w, "DoH timings by HTTP method:", "Method", stats.DoHMethodHists)
+	printBreakdown(w, "DNS timings of the first byte and the full response since the query was written:", "Read", stats.FirstByteHists)
+	printIdentities(w, stats.IdentityHists)
+	printResponders(w, stats.ResponderHists)
+	printCacheModel(w, stats.CacheHists, stats.Counters)
+	printRRL(w, b.RRLInterval, stats.RRL)
+	printEDNSSizes(w, stats.EDNSSizes, stats.EDNSSizeHists)
+	if b.ClientUsage {
+		printClientUsage(w, b.usage)
+	}
+
+	if b.RateRamp != "" {
+		printRampSteps(w, b, stats.Timings, stats.datapointWeight())
+	}
+	printConcurrencySteps(w, b.concurrencySteps)
+	printWorkers(w, stats.workers)
+
+	if b.SeriesInterval > 0 {
+		printSeries(w, b.SeriesInterval, timeSeries(stats.Timings, stats.ErrorTimes, b.SeriesInterval, stats.datapointWeight()))
+	}
+
+	if stats.Connections != nil {
+		printConnections(w, stats.Connections, t)
+		if b.DoHConnections > 0 {
+			fmt.Fprintf(w, "DoH connection pool:\t\t%s connections\n", highlightStr(b.DoHConnections))
+		}
+	}
+
+	if stats.Transfer != nil {
+		printTransfer(w, stats.Transfer, totalCounters)
+	}
+
+	if stats.Dual != nil {
+		printDual(w, stats.Dual)
+	}
+
+	if stats.Search != nil {
+		printSearch(w, stats.Search)
+	}
+
+	sumerrs := 0
+	for _, v := range topErrs.m {
+		sumerrs += v
+	}
+
+	if len(topErrs.m) > 0 {
+		errPrint(w, "\nTotal Errors: %d\n", sumerrs)
+		errPrint(w, "Top errors:\n")
+		for _, err := range topErrs.order {
+			errPrint(w, "%s\t%d (%.2f)%%\n", err, topErrs.m[err], (float64(topErrs.m[err])/float64(sumerrs))*100)
+		}
+	}
+
+	if len(stats.ErrorCategories) > 0 {
+		errPrint(w, "Errors by category:\n")
+		categories := make([]string, 0, len(stats.ErrorCategories))
+		for k := range stats.ErrorCategories {
+			categories = append(categories, k)
+		}
+		// the most frequent categories first
+		sort.Slice(categories, func(i, j int) bool {
+			ci, cj := stats.ErrorCategories[categories[i]], stats.ErrorCategories[categories[j]]
+			return ci > cj || (ci == cj && categories[i] < categories[j])
+		})
+		for _, k := range categories {
+			errPrint(w, "\t%s:\t%d\n", k, stats.ErrorCategories[k])
+		}
+	}
+
+	if len(stats.HookErrors) > 0 {
+		errPrint(w, "\nResponses rejected by hook:\n")
+		reasons := make([]string, 0, len(stats.HookErrors))
+		for k := range stats.HookErrors {
+			reasons = append(reasons, k)
+		}
+		// the most frequent errors first
+		sort.Slice(reasons, func(i, j int) bool {
+			ci, cj := stats.HookErrors[reasons[i]], stats.HookErrors[reasons[j]]
+			return ci > cj || (ci == cj && reasons[i] < reasons[j])
+		})
+		for _, k := range reasons {
+			errPrint(w, "\t%s:\t%d\n", k, stats.HookErrors[k])
+		}
+	}
+
+	if diff != nil {
+		printDiff(w, b, totalCounters, diff)
+	}
+
+	return nil
+}
+
+func printDiff(w io.Writer, b *Benchmark, totalCounters Counters, diff *ResultStats) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Answers compared with", highlightStr(b.DiffServer))
+	successPrint(w, "Agreements:\t\t%d\n", totalCounters.Agreements)
+	if totalCounters.Disagreements > 0 {
+		errPrint(w, "Disagreements:\t\t%d\n", totalCounters.Disagreements)
+	} else {
+		successPrint(w, "Disagreements:\t\t%d\n", totalCounters.Disagreements)
+	}
+	b.printProgress(w, *diff.Counters)
+
+	if tc := diff.Hist.TotalCount(); tc > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DNS timings of", highlightStr(b.DiffServer)+",", highlightStr(tc), "datapoints")
+		printTimingsAt(w, diff.Hist, b.reportedPercentiles())
+	}
+}
+
+func printRampSteps(w io.Writer, b *Benchmark, timings []Datapoint, weight float64) {
+	steps, err := parseRateRamp(b.RateRamp)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Rate ramp steps:")
+	for _, s := range rampStepStats(steps, timings, weight) {
+		fmt.Fprintf(w, "\t%s QPS for %s:\t%s responses, %s QPS, mean %s, p99 %s\n", highlightStr(s.Rate), highlightStr(s.Duration),
+			highlightStr(s.Responses), highlightStr(fmt.Sprintf("%0.1f", s.QueriesPerSecond)),
+			highlightStr(fmt.Sprintf("%0.2fms", s.MeanMs)), highlightStr(fmt.Sprintf("%0.2fms", s.P99Ms)))
+	}
+}
+
+func printConnections(w io.Writer, c *ConnectionStats, t time.Duration) {
+	fmt.Fprintln(w)
+	perWorker := 0.0
+	if c.Workers > 0 {
+		perWorker = float64(c.Count) / float64(c.Workers)
+	}
+	fmt.Fprintf(w, "Connections established:\t%s (%s per worker)\n", highlightStr(c.Count), highlightStr(fmt.Sprintf("%0.1f", perWorker)))
+	fmt.Fprintf(w, "Handshakes per second:\t\t%s\n", highlightStr(fmt.Sprintf("%0.1f", float64(c.Count)/t.Seconds())))
+	if c.Setup != nil && c.Setup.TotalCount() > 0 {
+		fmt.Fprintln(w, "Connection setup timings,", highlightStr(c.Setup.TotalCount()), "datapoints")
+		printTimings(w, c.Setup)
+	}
+	if c.ProxySetup != nil && c.ProxySetup.TotalCount() > 0 {
+		fmt.Fprintln(w, "Proxy connection setup timings,", highlightStr(c.ProxySetup.TotalCount()), "datapoints")
+		printTimings(w, c.ProxySetup)
+	}
+}
+
+func printTransfer(w io.Writer, t *TransferStats, c Counters) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Zone transfers:")
+	fmt.Fprintf(w, "\tRecords transferred:\t%s\n", highlightStr(t.Records))
+	fmt.Fprintf(w, "\tBytes transferred:\t%s\n", highlightStr(t.Bytes))
+	if c.Success > 0 {
+		fmt.Fprintf(w, "\tRecords per transfer:\t%s\n", highlightStr(t.Records/c.Success))
+		fmt.Fprintf(w, "\tBytes per transfer:\t%s\n", highlightStr(t.Bytes/c.Success))
+	}
+	if t.FirstRecord != nil && t.FirstRecord.TotalCount() > 0 {
+		fmt.Fprintln(w, "Time to first record,", highlightStr(t.FirstRecord.TotalCount()), "datapoints")
+		printTimings(w, t.FirstRecord)
+	}
+}
+
+func printTimings(w io.Writer, timings *hdrhistogram.Histogram) {
+	printTimingsAt(w, timings, defaultPercentiles)
+}
+
+// printTimingsAt prints the timings with the latencies at the provided percentiles.
+func printTimingsAt(w io.Writer, timings *hdrhistogram.Histogram, percentiles []float64) {
+	min := time.Duration(timings.Min())
+	mean := time.Duration(timings.Mean())
+	sd := time.Duration(timings.StdDev())
+	max := time.Duration(timings.Max())
+
+	fmt.Fprintln(w, "\t min:\t\t", highlightStr(roundDuration(min)))
+	fmt.Fprintln(w, "\t mean:\t\t", highlightStr(roundDuration(mean)))
+	fmt.Fprintln(w, "\t [+/-sd]:\t", highlightStr(roundDuration(sd)))
+	fmt.Fprintln(w, "\t max:\t\t", highlightStr(roundDuration(max)))
+	printPercentiles(w, timings, percentiles)
+}
+
+func (b *Benchmark) printProgress(w io.Writer, c Counters) {
+	fmt.Fprintf(w, "\nTotal requests:\t\t%s\n", highlightStr(c.Total))
+
+	if c.IOError > 0 {
+		errPrint(w, "Read/Write errors:\t%d\n", c.IOError)
+	}
+
+	if c.Timeouts > 0 {
+		errPrint(w, "Timeouts:\t\t%d\n", c.Timeouts)
+	}
+
+	if c.IDmismatch > 0 {
+		errPrint(w, "ID mismatch errors:\t%d\n", c.IDmismatch)
+	}
+
+	if c.Success > 0 {
+		successPrint(w, "DNS success codes:\t%d\n", c.Success)
+	}
+
+	if c.Truncated > 0 {
+		errPrint(w, "Truncated responses:\t%d\n", c.Truncated)
+	}
+
+	if c.Unsent > 0 {
+		errPrint(w, "Unsent queries:\t\t%d\n", c.Unsent)
+	}
+
+	if c.Unanswered > 0 {
+		errPrint(w, "Unanswered queries:\t%d\n", c.Unanswered)
+	}
+
+	if c.Retries > 0 {
+		errPrint(w, "Retries:\t\t%d\n", c.Retries)
+	}
+
+	if c.TCPRetries > 0 {
+		errPrint(w, "TCP retries:\t\t%d\n", c.TCPRetries)
+	}
+
+	if c.StubCacheHits > 0 {
+		successPrint(w, "Stub cache hits:\t%d (%0.2f%%)\n", c.StubCacheHits, stubCacheHitRate(c)*100)
+	}
+
+	if c.ServerCloses > 0 {
+		fmt.Fprintf(w, "Closed by server:\t%d\n", c.ServerCloses)
+	}
+
+	if c.IdleCloses > 0 {
+		fmt.Fprintf(w, "Closed when idle:\t%d\n", c.IdleCloses)
+	}
+
+	if c.Failovers > 0 {
+		errPrint(w, "Failovers:\t\t%d\n", c.Failovers)
+	}
+
+	if c.UDPRetransmits > 0 {
+		errPrint(w, "UDP retransmits:\t%d (%d late responses)\n", c.UDPRetransmits, c.LateResponses)
+	}
+
+	if c.DuplicateResponses > 0 {
+		errPrint(w, "Duplicate responses:\t%d\n", c.DuplicateResponses)
+	}
+
+	if c.ExpiredResponses > 0 {
+		errPrint(w, "Expired responses:\t%d\n", c.ExpiredResponses)
+	}
+
+	if c.UnexpectedSources > 0 {
+		errPrint(w, "Unexpected sources:\t%d\n", c.UnexpectedSources)
+	}
+
+	if c.InjectedDelays > 0 {
+		fmt.Fprintf(w, "Injected delays:\t%d\n", c.InjectedDelays)
+	}
+
+	if c.InjectedDrops > 0 {
+		fmt.Fprintf(w, "Injected drops:\t\t%d (%d queries failed)\n", c.InjectedDrops, c.InjectedFailures)
+	}
+
+	if c.NXDomainQueries > 0 {
+		fmt.Fprintf(w, "Nonexistent names:\t%d\n", c.NXDomainQueries)
+	}
+
+	if c.MalformedQueries > 0 {
+		fmt.Fprintf(w, "Malformed queries:\t%d\n", c.MalformedQueries)
+	}
+
+	if c.CNAMEChases > 0 {
+		fmt.Fprintf(w, "CNAME chases:\t\t%d\n", c.CNAMEChases)
+	}
+
+	if c.CaseMismatch > 0 {
+		errPrint(w, "Case mismatch errors:\t%d\n", c.CaseMismatch)
+	}
+
+	if c.ServerCookies > 0 {
+		successPrint(w, "Server cookies:\t\t%d\n", c.ServerCookies)
+	}
+
+	if c.CookieMissing > 0 {
+		errPrint(w, "Missing cookies:\t%d\n", c.CookieMissing)
+	}
+
+	if c.CookieMismatch > 0 {
+		errPrint(w, "Cookie mismatch:\t%d\n", c.CookieMismatch)
+	}
+
+	if c.RcodeMismatch > 0 {
+		errPrint(w, "Rcode mismatch:\t\t%d\n", c.RcodeMismatch)
+	}
+
+	if c.AnswerCountMismatch > 0 {
+		errPrint(w, "Answer count mismatch:\t%d\n", c.AnswerCountMismatch)
+	}
+
+	if c.IPMismatch > 0 {
+		errPrint(w, "IP mismatch:\t\t%d\n", c.IPMismatch)
+	}
+
+	if c.TSIGError > 0 {
+		errPrint(w, "TSIG errors:\t\t%d\n", c.TSIGError)
+	}
+
+	if c.ValidationOK > 0 {
+		successPrint(w, "DNSSEC valid:\t\t%d\n", c.ValidationOK)
+	}
+
+	if c.ValidationFailed > 0 {
+		errPrint(w, "DNSSEC invalid:\t\t%d\n", c.ValidationFailed)
+	}
+
+	if c.HookRejected > 0 {
+		errPrint(w, "Rejected by hook:\t%d\n", c.HookRejected)
+	}
+}
+
+func printBars(w io.Writer, bars []hdrhistogram.Bar) {
+	counts := make([]int64, 0, len(bars))
+	lines := make([][]string, 0, len(bars))
+	added := false
+	var max int64
+
+	for _, b := range bars {
+		if b.Count == 0 && !added {
+			// trim the start
+			continue
+		}
+		if b.Count > max {
+			max = b.Count
+		}
+
+		added = true
+
+		line := make([]string, 3)
+		lines = append(lines, line)
+		counts = append(counts, b.Count)
+
+		line[0] = roundDuration(time.Duration(b.To/2 + b.From/2)).String()
+		line[2] = strconv.FormatInt(b.Count, 10)
+	}
+
+	for i, l := range lines {
+		l[1] = makeBar(counts[i], max)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Latency", "", "Count"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+}
+
+func makeBar(c int64, max int64) string {
+	if c == 0 {
+		return ""
+	}
+	t := int((43 * float64(c) / float64(max)) + 0.5)
+	return strings.Repeat(highlightStr("▄"), t)
+}
+
+func roundDuration(dur time.Duration) time.Duration {
+	if dur > time.Minute {
+		return dur.Round(10 * time.Second)
+	}
+	if dur > time.Second {
+		return dur.Round(10 * time.Millisecond)
+	}
+	if dur > time.Millisecond {
+		return dur.Round(10 * time.Microsecond)
+	}
+	if dur > time.Microsecond {
+		return dur.Round(10 * time.Nanosecond)
+	}
+	return dur
+}
+
+func printDoHStats(w io.Writer, doh *DoHStats) {
+	if len(doh.Statuses) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DoH HTTP status codes:")
+		codes := make([]int, 0, len(doh.Statuses))
+		for k := range doh.Statuses {
+			codes = append(codes, k)
+		}
+		sort.Ints(codes)
+		for _, k := range codes {
+			printFn := successPrint
+			if k != http.StatusOK {
+				printFn = errPrint
+			}
+			printFn(w, "\t%d:\t%d\n", k, doh.Statuses[k])
+		}
+	}
+
+	if len(doh.Servers) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DoH Server headers:")
+		for k, v := range doh.Servers {
+			successPrint(w, "\t%s:\t%d\n", k, v)
+		}
+	}
+
+	if len(doh.Via) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DoH Via headers:")
+		for k, v := range doh.Via {
+			successPrint(w, "\t%s:\t%d\n", k, v)
+		}
+	}
+
+	if doh.ReusedConnections > 0 || doh.NewConnections > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DoH requests by connection:")
+		successPrint(w, "\treused:\t%d\n", doh.ReusedConnections)
+		successPrint(w, "\tnew:\t%d\n", doh.NewConnections)
+	}
+
+	if len(doh.Methods) > 0 {
+		methods := make([]string, 0, len(doh.Methods))
+		for k := range doh.Methods {
+			methods = append(methods, k)
+		}
+		sort.Strings(methods)
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DoH responses by HTTP method:")
+		table := tablewriter.NewWriter(w)
+		table.SetHeader([]string{"Method", "Responses", "Cacheable", "Cached"})
+		table.SetBorder(false)
+		for _, k := range methods {
+			m := doh.Methods[k]
+			table.Append([]string{k, strconv.FormatInt(m.Responses, 10), strconv.FormatInt(m.Cacheable, 10), strconv.FormatInt(m.Cached, 10)})
+		}
+		table.Render()
+	}
+}
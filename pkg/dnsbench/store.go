@@ -0,0 +1,194 @@
+package dnsbench
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	// registers pure Go SQLite driver, so that the binaries can be still built without cgo
+	_ "modernc.org/sqlite"
+)
+
+const storeSchema = `CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	time TEXT NOT NULL,
+	server TEXT NOT NULL,
+	duration_ns INTEGER NOT NULL,
+	interrupted INTEGER NOT NULL,
+	requests INTEGER NOT NULL,
+	errors INTEGER NOT NULL,
+	qps REAL NOT NULL,
+	min_ns INTEGER NOT NULL,
+	mean_ns INTEGER NOT NULL,
+	max_ns INTEGER NOT NULL,
+	p50_ns INTEGER NOT NULL,
+	p75_ns INTEGER NOT NULL,
+	p90_ns INTEGER NOT NULL,
+	p95_ns INTEGER NOT NULL,
+	p99_ns INTEGER NOT NULL,
+	config TEXT NOT NULL,
+	stats TEXT NOT NULL
+)`
+
+// StoredRun is a benchmark run stored by Benchmark.Store, the configuration of the benchmark and the merged results are kept as JSON.
+type StoredRun struct {
+	ID          int64           `json:"id"`
+	Time        time.Time       `json:"time"`
+	Duration    time.Duration   `json:"durationNs"`
+	Interrupted bool            `json:"interrupted,omitempty"`
+	Config      json.RawMessage `json:"config"`
+	Baseline
+}
+
+func openStore(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store due to '%v'", err)
+	}
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open store '%s' due to '%v'", path, err)
+	}
+	return db, nil
+}
+
+// storeRun appends the configuration and the merged results of the run to the SQLite file.
+func (b *Benchmark) storeRun(path string, stats *ResultStats, t time.Duration) error {
+	config, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to encode benchmark due to '%v'", err)
+	}
+	data, err := json.Marshal(toRemoteStats(stats))
+	if err != nil {
+		return fmt.Errorf("failed to encode results due to '%v'", err)
+	}
+	db, err := openStore(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s := NewBaseline(b.Server, []*ResultStats{stats}, t)
+	_, err = db.Exec(`INSERT INTO runs (time, server, duration_ns, interrupted, requests, errors, qps, min_ns, mean_ns, max_ns,
+		p50_ns, p75_ns, p90_ns, p95_ns, p99_ns, config, stats) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC().Format(time.RFC3339Nano), s.Server, t.Nanoseconds(), b.interrupted, s.TotalRequests, s.TotalErrors, s.QueriesPerSecond,
+		s.Min.Nanoseconds(), s.Mean.Nanoseconds(), s.Max.Nanoseconds(), s.P50.Nanoseconds(), s.P75.Nanoseconds(), s.P90.Nanoseconds(),
+		s.P95.Nanoseconds(), s.P99.Nanoseconds(), string(config), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to store results due to '%v'", err)
+	}
+	return nil
+}
+
+// LoadRuns loads the last runs stored by Benchmark.Store from the SQLite file, all the runs are loaded when last is not positive.
+// The runs are returned from the oldest to the newest.
+func LoadRuns(path string, last int) ([]StoredRun, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open store due to '%v'", err)
+	}
+	db, err := openStore(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if last <= 0 {
+		last = -1
+	}
+	rows, err := db.Query(`SELECT id, time, server, duration_ns, interrupted, requests, errors, qps, min_ns, mean_ns, max_ns,
+		p50_ns, p75_ns, p90_ns, p95_ns, p99_ns, config FROM runs ORDER BY id DESC LIMIT ?`, last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runs due to '%v'", err)
+	}
+	defer rows.Close()
+
+	var runs []StoredRun
+	for rows.Next() {
+		var r StoredRun
+		var at, config string
+		if err := rows.Scan(&r.ID, &at, &r.Server, &r.Duration, &r.Interrupted, &r.TotalRequests, &r.TotalErrors, &r.QueriesPerSecond,
+			&r.Min, &r.Mean, &r.Max, &r.P50, &r.P75, &r.P90, &r.P95, &r.P99, &config); err != nil {
+			return nil, fmt.Errorf("failed to load runs due to '%v'", err)
+		}
+		if r.Time, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return nil, fmt.Errorf("failed to parse time of run %d due to '%v'", r.ID, err)
+		}
+		r.Config = json.RawMessage(config)
+		runs = append(runs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load runs due to '%v'", err)
+	}
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs, nil
+}
+
+// PrintRuns prints the stored runs as a table, the throughput and latency percentiles are compared with the previous run of the same server.
+func PrintRuns(w io.Writer, runs []StoredRun, jsonOutput bool) error {
+	if jsonOutput {
+		if runs == nil {
+			runs = []StoredRun{}
+		}
+		return json.NewEncoder(w).Encode(runs)
+	}
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "No stored runs")
+		return nil
+	}
+
+	previous := make(map[string]StoredRun)
+	lines := make([][]string, 0, len(runs))
+	for _, r := range runs {
+		prev, ok := previous[r.Server]
+		duration := roundDuration(r.Duration).String()
+		if r.Interrupted {
+			duration += " (interrupted)"
+		}
+		line := []string{
+			strconv.FormatInt(r.ID, 10),
+			r.Time.Local().Format(time.DateTime),
+			r.Server,
+			duration,
+			strconv.FormatInt(r.TotalRequests, 10),
+			strconv.FormatInt(r.TotalErrors, 10),
+			fmt.Sprintf("%0.1f", r.QueriesPerSecond),
+		}
+		if ok {
+			line[6] += formatDelta(prev.QueriesPerSecond, r.QueriesPerSecond)
+		}
+		for _, p := range [][2]time.Duration{{prev.P50, r.P50}, {prev.P95, r.P95}, {prev.P99, r.P99}} {
+			cell := roundDuration(p[1]).String()
+			if ok {
+				cell += formatDelta(float64(p[0]), float64(p[1]))
+			}
+			line = append(line, cell)
+		}
+		lines = append(lines, line)
+		previous[r.Server] = r
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Stored runs compared with the previous run of the same server:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"ID", "Time", "Server", "Duration", "Requests", "Errors", "QPS", "p50", "p95", "p99"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	table.AppendBulk(lines)
+	table.Render()
+	return nil
+}
+
+// formatDelta formats the relative change of the value against the previous value.
+func formatDelta(prev, cur float64) string {
+	if prev == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%+0.2f%%)", (cur-prev)/prev*100)
+}
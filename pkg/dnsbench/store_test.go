@@ -0,0 +1,73 @@
+package dnsbench
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_PrintReport_store(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "results.db")
+	b, rs := testData()
+	b.Server = "127.0.0.1:53"
+	b.Silent = true
+	b.Store = file
+
+	require.NoError(t, b.PrintReport(io.Discard, []*ResultStats{&rs}, time.Second))
+	require.NoError(t, b.PrintReport(io.Discard, []*ResultStats{&rs}, 2*time.Second))
+	b.Server = "8.8.8.8:53"
+	require.NoError(t, b.PrintReport(io.Discard, []*ResultStats{&rs}, time.Second))
+
+	runs, err := LoadRuns(file, 2)
+	require.NoError(t, err)
+	require.Len(t, runs, 2, "only the last runs are loaded")
+	assert.Equal(t, int64(2), runs[0].ID)
+	assert.Equal(t, "127.0.0.1:53", runs[0].Server)
+	assert.Equal(t, 2*time.Second, runs[0].Duration)
+	assert.Equal(t, int64(3), runs[1].ID)
+	assert.Equal(t, "8.8.8.8:53", runs[1].Server)
+	assert.Equal(t, NewBaseline("8.8.8.8:53", []*ResultStats{&rs}, time.Second), runs[1].Baseline)
+
+	var config Benchmark
+	require.NoError(t, json.Unmarshal(runs[1].Config, &config))
+	assert.Equal(t, "8.8.8.8:53", config.Server)
+
+	all, err := LoadRuns(file, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
+
+func TestLoadRuns_missing(t *testing.T) {
+	_, err := LoadRuns(filepath.Join(t.TempDir(), "results.db"), 10)
+	assert.Error(t, err)
+}
+
+func TestPrintRuns(t *testing.T) {
+	at := time.Date(2024, 5, 20, 10, 0, 0, 0, time.Local)
+	runs := []StoredRun{
+		{ID: 1, Time: at, Duration: 10 * time.Second, Baseline: Baseline{Server: "8.8.8.8", TotalRequests: 1000, QueriesPerSecond: 100, P50: 10 * time.Millisecond, P95: 20 * time.Millisecond, P99: 40 * time.Millisecond}},
+		{ID: 2, Time: at, Duration: time.Second, Baseline: Baseline{Server: "1.1.1.1", TotalRequests: 10, QueriesPerSecond: 10, P50: time.Millisecond, P95: time.Millisecond, P99: time.Millisecond}},
+		{ID: 3, Time: at, Duration: 5 * time.Second, Interrupted: true, Baseline: Baseline{Server: "8.8.8.8", TotalRequests: 550, TotalErrors: 5, QueriesPerSecond: 110, P50: 12 * time.Millisecond, P95: 20 * time.Millisecond, P99: 30 * time.Millisecond}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, PrintRuns(&buf, runs, false))
+	assert.Equal(t, `
+Stored runs compared with the previous run of the same server:
+  ID |        TIME         | SERVER  |     DURATION     | REQUESTS | ERRORS |       QPS       |      P50       |      P95      |      P99        
+-----+---------------------+---------+------------------+----------+--------+-----------------+----------------+---------------+-----------------
+   1 | 2024-05-20 10:00:00 | 8.8.8.8 | 10s              |     1000 |      0 |           100.0 | 10ms           | 20ms          | 40ms            
+   2 | 2024-05-20 10:00:00 | 1.1.1.1 | 1s               |       10 |      0 |            10.0 | 1ms            | 1ms           | 1ms             
+   3 | 2024-05-20 10:00:00 | 8.8.8.8 | 5s (interrupted) |      550 |      5 | 110.0 (+10.00%) | 12ms (+20.00%) | 20ms (+0.00%) | 30ms (-25.00%)  
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, PrintRuns(&buf, nil, true))
+	assert.Equal(t, "[]\n", buf.String())
+}
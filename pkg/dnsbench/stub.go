@@ -0,0 +1,181 @@
+package dnsbench
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// stubRetransmitTimeout is the timeout of the first attempt of the query sent by simulated stub resolver, the timeout is doubled
+	// with each retransmission, like the stub resolvers of operating systems do.
+	stubRetransmitTimeout = time.Second
+	// stubRetransmits is the number of retransmissions of the query sent by simulated stub resolver.
+	stubRetransmits = 2
+	// maxCNAMEChain is the highest number of CNAME records followed by simulated stub resolver or by Benchmark.FollowCNAMEs.
+	maxCNAMEChain = 8
+)
+
+type stubCacheKey struct {
+	name  string
+	qtype uint16
+}
+
+// stubCacheEntry is a cached answer of the question, negative answers are cached without records.
+type stubCacheEntry struct {
+	answer  []dns.RR
+	expires time.Time
+}
+
+// stubResolver simulates client side behaviour of stub resolvers of a single host, the answers are cached according to their TTLs
+// and CNAME records are followed, when the server does not answer the target of the CNAME. Each worker simulates its own host.
+// Resolver without the cache only follows CNAME records, see Benchmark.FollowCNAMEs.
+type stubResolver struct {
+	cache map[stubCacheKey]stubCacheEntry
+	rando *rand.Rand
+}
+
+func newStubResolver(rando *rand.Rand) *stubResolver {
+	return &stubResolver{cache: make(map[stubCacheKey]stubCacheEntry), rando: rando}
+}
+
+func stubKey(q dns.Question) stubCacheKey {
+	return stubCacheKey{name: strings.ToLower(q.Name), qtype: q.Qtype}
+}
+
+// cached returns the cached answer of the question, if there is any not expired yet.
+func (s *stubResolver) cached(q dns.Question, now time.Time) ([]dns.RR, bool) {
+	k := stubKey(q)
+	e, ok := s.cache[k]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(e.expires) {
+		delete(s.cache, k)
+		return nil, false
+	}
+	return e.answer, true
+}
+
+// store caches the answer of the response for the lowest TTL of its records, negative answers are cached for the TTL
+// of SOA record (RFC 2308), responses without any TTL are not cached.
+func (s *stubResolver) store(q dns.Question, resp *dns.Msg, now time.Time) {
+	if s.cache == nil || resp.Truncated || (resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError) {
+		return
+	}
+	var ttl uint32
+	var found bool
+	min := func(t uint32) {
+		if !found || t < ttl {
+			ttl, found = t, true
+		}
+	}
+	if resp.Rcode == dns.RcodeSuccess && len(resp.Answer) > 0 {
+		for _, rr := range resp.Answer {
+			min(rr.Header().Ttl)
+		}
+	} else {
+		for _, rr := range resp.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				min(soa.Hdr.Ttl)
+				min(soa.Minttl)
+			}
+		}
+	}
+	if !found || ttl == 0 {
+		return
+	}
+	e := stubCacheEntry{expires: now.Add(time.Duration(ttl) * time.Second)}
+	if resp.Rcode == dns.RcodeSuccess {
+		e.answer = resp.Answer
+	}
+	s.cache[stubKey(q)] = e
+}
+
+// danglingCNAME returns the target of CNAME chain of the answer, when the answer does not contain records of the question type for the target.
+func danglingCNAME(answer []dns.RR, q dns.Question) string {
+	target, depth, complete := cnameChain(answer, q)
+	if complete || depth == 0 {
+		return ""
+	}
+	return target
+}
+
+// cnameChain follows CNAME chain of the answer starting at the question name, it returns the last name of the chain, the number of followed CNAME records
+// and whether the answer contains records of the question type for the last name.
+func cnameChain(answer []dns.RR, q dns.Question) (string, int, bool) {
+	if q.Qtype == dns.TypeCNAME || q.Qtype == dns.TypeANY {
+		return q.Name, 0, true
+	}
+	name := q.Name
+	var depth int
+	for ; depth < maxCNAMEChain; depth++ {
+		var next string
+		for _, rr := range answer {
+			if !strings.EqualFold(rr.Header().Name, name) {
+				continue
+			}
+			if rr.Header().Rrtype == q.Qtype {
+				return name, depth, true
+			}
+			if cname, ok := rr.(*dns.CNAME); ok {
+				next = cname.Target
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	return name, depth, false
+}
+
+// resolve sends the query and follows the CNAME records of the answers not containing the records of the target, the answers of the followed
+// targets are appended to the returned response. The answers are cached, cached targets are not queried again. Returned attempts include
+// the attempts of all the queries sent.
+func (s *stubResolver) resolve(ctx context.Context, b *Benchmark, query queryFunc, m *dns.Msg, st *ResultStats, log *logger) (*dns.Msg, int, error) {
+	resp, attempts, err := b.exchange(ctx, query, m, log)
+	if err != nil {
+		return resp, attempts, err
+	}
+	q := m.Question[0]
+	s.store(q, resp, time.Now())
+
+	answer := resp.Answer
+	for i := 0; resp.Rcode == dns.RcodeSuccess && i < maxCNAMEChain; i++ {
+		target := danglingCNAME(answer, q)
+		if target == "" {
+			break
+		}
+		tq := dns.Question{Name: target, Qtype: q.Qtype, Qclass: q.Qclass}
+		rrs, ok := s.cached(tq, time.Now())
+		if !ok {
+			chase := m.Copy()
+			chase.Id = uint16(s.rando.Uint32())
+			chase.Question[0] = tq
+			st.Counters.CNAMEChases++
+			r, a, err := b.exchange(ctx, query, chase, log)
+			attempts += a
+			if err != nil {
+				return nil, attempts, err
+			}
+			s.store(tq, r, time.Now())
+			resp.Rcode = r.Rcode
+			rrs = r.Answer
+		}
+		answer = append(answer, rrs...)
+	}
+	resp.Answer = answer
+	return resp, attempts, nil
+}
+
+// stubCacheHitRate returns the fraction of the queries answered from the cache of simulated stub resolver.
+func stubCacheHitRate(c Counters) float64 {
+	if c.StubCacheHits == 0 {
+		return 0
+	}
+	return float64(c.StubCacheHits) / float64(c.StubCacheHits+c.Total)
+}
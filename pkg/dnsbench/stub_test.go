@@ -0,0 +1,206 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustRR(t *testing.T, s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	require.NoError(t, err)
+	return rr
+}
+
+func Test_stubResolver_cache(t *testing.T) {
+	s := newStubResolver(rand.New(rand.NewSource(1)))
+	now := time.Now()
+	q := dns.Question{Name: "Example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+
+	resp := &dns.Msg{Answer: []dns.RR{mustRR(t, "example.org. 300 IN A 127.0.0.1"), mustRR(t, "example.org. 60 IN A 127.0.0.2")}}
+	s.store(q, resp, now)
+
+	rrs, ok := s.cached(dns.Question{Name: "example.org.", Qtype: dns.TypeA}, now.Add(59*time.Second))
+	assert.True(t, ok, "the answer is cached for the lowest TTL")
+	assert.Len(t, rrs, 2)
+	_, ok = s.cached(q, now.Add(60*time.Second))
+	assert.False(t, ok, "the answer expired")
+	_, ok = s.cached(dns.Question{Name: "example.org.", Qtype: dns.TypeAAAA}, now)
+	assert.False(t, ok)
+
+	nx := &dns.Msg{Ns: []dns.RR{mustRR(t, "org. 3600 IN SOA a0.org.afilias-nst.info. hostmaster.donuts.email. 1 7200 900 1209600 30")}}
+	nx.Rcode = dns.RcodeNameError
+	nq := dns.Question{Name: "nx.org.", Qtype: dns.TypeA}
+	s.store(nq, nx, now)
+	rrs, ok = s.cached(nq, now.Add(29*time.Second))
+	assert.True(t, ok, "negative answer is cached for the SOA minimum")
+	assert.Empty(t, rrs)
+	_, ok = s.cached(nq, now.Add(30*time.Second))
+	assert.False(t, ok)
+
+	servfail := &dns.Msg{Answer: resp.Answer}
+	servfail.Rcode = dns.RcodeServerFailure
+	sq := dns.Question{Name: "servfail.org.", Qtype: dns.TypeA}
+	s.store(sq, servfail, now)
+	_, ok = s.cached(sq, now)
+	assert.False(t, ok, "failures are not cached")
+
+	s.store(dns.Question{Name: "empty.org.", Qtype: dns.TypeA}, &dns.Msg{}, now)
+	_, ok = s.cached(dns.Question{Name: "empty.org.", Qtype: dns.TypeA}, now)
+	assert.False(t, ok, "negative answers without SOA are not cached")
+}
+
+func Test_danglingCNAME(t *testing.T) {
+	q := dns.Question{Name: "www.example.org.", Qtype: dns.TypeA}
+	cname := mustRR(t, "www.example.org. 60 IN CNAME edge.example.net.")
+	chained := mustRR(t, "edge.example.net. 60 IN CNAME edge.cdn.example.")
+
+	assert.Equal(t, "edge.example.net.", danglingCNAME([]dns.RR{cname}, q))
+	assert.Equal(t, "edge.cdn.example.", danglingCNAME([]dns.RR{cname, chained}, q))
+	assert.Empty(t, danglingCNAME([]dns.RR{cname, mustRR(t, "edge.example.net. 60 IN A 127.0.0.1")}, q))
+	assert.Empty(t, danglingCNAME([]dns.RR{mustRR(t, "www.example.org. 60 IN A 127.0.0.1")}, q))
+	assert.Empty(t, danglingCNAME([]dns.RR{cname}, dns.Question{Name: "www.example.org.", Qtype: dns.TypeCNAME}))
+	assert.Empty(t, danglingCNAME(nil, q))
+}
+
+func TestBenchmark_Run_simulateStub(t *testing.T) {
+	var received atomic.Int64
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		received.Add(1)
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Name == "www.example.org." && q.Qtype == dns.TypeA:
+			ret.Answer = append(ret.Answer, mustRR(t, "www.example.org. 300 IN CNAME edge.example.net."))
+		case q.Name == "edge.example.net." && q.Qtype == dns.TypeA:
+			ret.Answer = append(ret.Answer, mustRR(t, "edge.example.net. 300 IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = []string{"www.example.org"}
+	bench.Types = []string{"A"}
+	bench.Count = 3
+	bench.SimulateStub = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	c := Merge(rs).Counters
+	assert.Equal(t, int64(2), c.Total, "each worker sends the query once")
+	assert.Equal(t, int64(4), c.StubCacheHits, "following queries are answered from the cache")
+	assert.Equal(t, int64(2), c.CNAMEChases, "each worker follows the CNAME")
+	assert.Equal(t, int64(4), received.Load())
+	assert.InDelta(t, 2.0/3, stubCacheHitRate(*c), 0.0001)
+}
+
+func TestBenchmark_Run_simulateStub_total(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, mustRR(t, "www.example.org. 300 IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = []string{"www.example.org"}
+	bench.Types = []string{"A"}
+	bench.Count = 0
+	bench.Total = 10
+	bench.SimulateStub = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.NoError(t, ctx.Err(), "benchmark ends even when all the names are cached")
+
+	c := Merge(rs).Counters
+	assert.Equal(t, int64(2), c.Total, "each worker sends the query once, then it is answered from the cache")
+	assert.Positive(t, c.StubCacheHits)
+}
+
+func TestBenchmark_normalize_simulateStub(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", SimulateStub: true}
+	require.NoError(t, b.normalize())
+	assert.True(t, b.RetryTruncated, "truncated responses are retried over TCP")
+
+	b = Benchmark{Server: "8.8.8.8", SimulateStub: true, TCP: true}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", SimulateStub: true, Retries: 2}
+	assert.Error(t, b.normalize())
+}
+
+func Test_cnameChain(t *testing.T) {
+	q := dns.Question{Name: "www.example.org.", Qtype: dns.TypeA}
+	cname := mustRR(t, "www.example.org. 60 IN CNAME edge.example.net.")
+	chained := mustRR(t, "edge.example.net. 60 IN CNAME edge.cdn.example.")
+	a := mustRR(t, "edge.cdn.example. 60 IN A 127.0.0.1")
+
+	name, depth, complete := cnameChain([]dns.RR{cname, chained, a}, q)
+	assert.Equal(t, "edge.cdn.example.", name)
+	assert.Equal(t, 2, depth)
+	assert.True(t, complete)
+
+	_, depth, complete = cnameChain([]dns.RR{mustRR(t, "www.example.org. 60 IN A 127.0.0.1")}, q)
+	assert.Zero(t, depth)
+	assert.True(t, complete)
+
+	name, depth, complete = cnameChain([]dns.RR{cname}, q)
+	assert.Equal(t, "edge.example.net.", name)
+	assert.Equal(t, 1, depth)
+	assert.False(t, complete)
+}
+
+func TestBenchmark_Run_followCNAMEs(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Name == "www.example.org." && q.Qtype == dns.TypeA:
+			ret.Answer = append(ret.Answer, mustRR(t, "www.example.org. 300 IN CNAME edge.example.net."))
+		case q.Name == "edge.example.net." && q.Qtype == dns.TypeA:
+			ret.Answer = append(ret.Answer, mustRR(t, "edge.example.net. 300 IN CNAME edge.cdn.example."))
+		case q.Name == "edge.cdn.example." && q.Qtype == dns.TypeA:
+			ret.Answer = append(ret.Answer, mustRR(t, "edge.cdn.example. 300 IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = []string{"www.example.org", "edge.cdn.example"}
+	bench.Types = []string{"A"}
+	bench.FollowCNAMEs = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.Total)
+	assert.Equal(t, int64(4), merged.Counters.CNAMEChases, "each worker follows two CNAME records")
+	require.Len(t, merged.CNAMEDepthHists, 2)
+	assert.Equal(t, int64(2), merged.CNAMEDepthHists[0].TotalCount())
+	assert.Equal(t, int64(2), merged.CNAMEDepthHists[2].TotalCount())
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "DNS timings by CNAME chain depth:")
+}
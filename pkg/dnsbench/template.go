@@ -0,0 +1,65 @@
+package dnsbench
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+)
+
+const (
+	templateChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	// nonexistentLabelLen is a length of random label of the names generated by Benchmark.NXDomainRatio.
+	nonexistentLabelLen = 16
+)
+
+var (
+	templatePlaceholder = regexp.MustCompile(`\{(rand:\d+|seq)\}`)
+	randPlaceholder     = regexp.MustCompile(`\{rand:(\d+)\}`)
+)
+
+// isTemplate checks whether the query name contains placeholders, which are expanded with each request.
+func isTemplate(name string) bool {
+	return templatePlaceholder.MatchString(name)
+}
+
+// validateTemplate checks that placeholders of the query name generate valid DNS labels.
+func validateTemplate(name string) error {
+	for _, m := range randPlaceholder.FindAllStringSubmatch(name, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > 63 {
+			return fmt.Errorf("invalid placeholder '%s' in query '%s', length of random label has to be between 1 and 63", m[0], name)
+		}
+	}
+	return nil
+}
+
+// nonexistentName returns the name prefixed with random label, which is not expected to exist under the name.
+func nonexistentName(rando *rand.Rand, name string) string {
+	b := make([]byte, nonexistentLabelLen)
+	for i := range b {
+		b[i] = templateChars[rando.Intn(len(templateChars))]
+	}
+	if name == "." {
+		return string(b) + "."
+	}
+	return string(b) + "." + name
+}
+
+// expandTemplate expands placeholders of the query name, {rand:N} is replaced by N random letters and digits
+// and {seq} is replaced by sequence number shared by all benchmark workers.
+func expandTemplate(rando *rand.Rand, seq *atomic.Int64, name string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(name, func(p string) string {
+		if p == "{seq}" {
+			return strconv.FormatInt(seq.Add(1), 10)
+		}
+		// length is already validated by validateTemplate
+		n, _ := strconv.Atoi(p[len("{rand:") : len(p)-1])
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = templateChars[rando.Intn(len(templateChars))]
+		}
+		return string(b)
+	})
+}
@@ -0,0 +1,32 @@
+package dnsbench
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_expandTemplate(t *testing.T) {
+	rando := rand.New(rand.NewSource(0))
+	var seq atomic.Int64
+
+	assert.Regexp(t, `^[a-z0-9]{12}\.example\.org\.$`, expandTemplate(rando, &seq, "{rand:12}.example.org."))
+	assert.Equal(t, "1.example.org.", expandTemplate(rando, &seq, "{seq}.example.org."))
+	assert.Regexp(t, `^2-[a-z0-9]{3}\.example\.org\.$`, expandTemplate(rando, &seq, "{seq}-{rand:3}.example.org."))
+	assert.Equal(t, "{foo}.example.org.", expandTemplate(rando, &seq, "{foo}.example.org."))
+}
+
+func Test_validateTemplate(t *testing.T) {
+	assert.NoError(t, validateTemplate("{rand:63}.{seq}.example.org"))
+	assert.Error(t, validateTemplate("{rand:0}.example.org"))
+	assert.Error(t, validateTemplate("{rand:64}.example.org"))
+}
+
+func Test_nonexistentName(t *testing.T) {
+	rando := rand.New(rand.NewSource(0))
+
+	assert.Regexp(t, `^[a-z0-9]{16}\.example\.org\.$`, nonexistentName(rando, "example.org."))
+	assert.Regexp(t, `^[a-z0-9]{16}\.$`, nonexistentName(rando, "."))
+}
@@ -0,0 +1,103 @@
+package dnsbench
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseCipherSuites parses names of cipher suites like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 into their IDs.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	suites := make(map[string]uint16)
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[s.Name] = s.ID
+	}
+	var res []uint16
+	for _, name := range names {
+		id, ok := suites[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS cipher suite '%s'", name)
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+// loadTLS loads client certificate and CA certificates and parses TLS versions and cipher suites used for DoT, DoH and DoQ connections.
+func (b *Benchmark) loadTLS() error {
+	b.tlsCerts, b.tlsRootCAs = nil, nil
+	b.tlsMinVersion, b.tlsMaxVersion = 0, 0
+	for _, v := range []struct {
+		version string
+		target  *uint16
+	}{{b.TLSMinVersion, &b.tlsMinVersion}, {b.TLSMaxVersion, &b.tlsMaxVersion}} {
+		if v.version == "" {
+			continue
+		}
+		parsed, ok := tlsVersions[v.version]
+		if !ok {
+			return fmt.Errorf("unknown TLS version '%s', supported versions are 1.0, 1.1, 1.2 and 1.3", v.version)
+		}
+		*v.target = parsed
+	}
+	if b.tlsMinVersion != 0 && b.tlsMaxVersion != 0 && b.tlsMinVersion > b.tlsMaxVersion {
+		return errors.New("--tls-min-version cannot be higher than --tls-max-version")
+	}
+
+	suites, err := parseCipherSuites(b.TLSCipherSuites)
+	if err != nil {
+		return err
+	}
+	b.tlsCipherSuites = suites
+
+	if (b.TLSCert == "") != (b.TLSKey == "") {
+		return errors.New("--tls-cert and --tls-key have to be specified together")
+	}
+	if b.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(b.TLSCert, b.TLSKey)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS client certificate due to '%v'", err)
+		}
+		b.tlsCerts = []tls.Certificate{cert}
+	}
+	if b.TLSCA != "" {
+		data, err := os.ReadFile(b.TLSCA)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS CA certificates due to '%v'", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no PEM encoded certificates found in '%s'", b.TLSCA)
+		}
+		b.tlsRootCAs = pool
+	}
+	return nil
+}
+
+// tlsConfig returns TLS configuration of DoT, DoH and DoQ connections, if serverName is empty, it is derived from the dialed address.
+// The server name is overridden by Benchmark.TLSServerName.
+func (b *Benchmark) tlsConfig(serverName string) *tls.Config {
+	if b.TLSServerName != "" {
+		serverName = b.TLSServerName
+	}
+	// nolint:gosec
+	return &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: b.Insecure,
+		Certificates:       b.tlsCerts,
+		RootCAs:            b.tlsRootCAs,
+		MinVersion:         b.tlsMinVersion,
+		MaxVersion:         b.tlsMaxVersion,
+		CipherSuites:       b.tlsCipherSuites,
+	}
+}
@@ -0,0 +1,199 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate generates self-signed certificate for 127.0.0.1 and dns.example.com usable by both servers and clients and writes it with its key to dir.
+func writeCertificate(t *testing.T, dir, name string) (string, string, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"dns.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	return certFile, keyFile, cert
+}
+
+func Test_do_dot_mutual_tls(t *testing.T) {
+	dir := t.TempDir()
+	serverCertFile, _, serverCert := writeCertificate(t, dir, "server")
+	clientCertFile, clientKeyFile, clientCert := writeCertificate(t, dir, "client")
+
+	leaf, err := x509.ParseCertificate(clientCert.Certificate[0])
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+	server := dns.Server{Listener: listener, Net: "tcp-tls", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	tests := []struct {
+		name        string
+		clientCert  bool
+		wantSuccess int64
+		wantErrors  int64
+	}{
+		{name: "with client certificate", clientCert: true, wantSuccess: 2},
+		{name: "without client certificate", wantErrors: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bench := createBenchmark(listener.Addr().String(), false, 1)
+			bench.DOT = true
+			bench.TLSCA = serverCertFile
+			if tt.clientCert {
+				bench.TLSCert = clientCertFile
+				bench.TLSKey = clientKeyFile
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			for _, r := range rs {
+				assert.Equal(t, tt.wantSuccess, r.Counters.Success, "Run(ctx) success counter")
+				assert.Equal(t, tt.wantErrors, r.Counters.IOError, "Run(ctx) error counter")
+			}
+		})
+	}
+}
+
+func TestBenchmark_loadTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCertificate(t, dir, "client")
+
+	b := Benchmark{TLSCert: certFile, TLSKey: keyFile, TLSCA: certFile}
+	require.NoError(t, b.loadTLS())
+	assert.Len(t, b.tlsCerts, 1)
+	assert.NotNil(t, b.tlsRootCAs)
+
+	for _, b := range []Benchmark{
+		{TLSCert: certFile},
+		{TLSCert: filepath.Join(dir, "missing.crt"), TLSKey: keyFile},
+		{TLSCA: keyFile},
+	} {
+		assert.Error(t, b.loadTLS())
+	}
+}
+
+func Test_do_dot_tls_options(t *testing.T) {
+	dir := t.TempDir()
+	serverCertFile, _, serverCert := writeCertificate(t, dir, "server")
+
+	var mu sync.Mutex
+	var serverNames []string
+	var versions []uint16
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		MinVersion:   tls.VersionTLS12,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			mu.Lock()
+			defer mu.Unlock()
+			serverNames = append(serverNames, state.ServerName)
+			versions = append(versions, state.Version)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	server := dns.Server{Listener: listener, Net: "tcp-tls", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	bench := createBenchmark(listener.Addr().String(), false, 1)
+	bench.DOT = true
+	bench.TLSCA = serverCertFile
+	bench.TLSServerName = "dns.example.com"
+	bench.TLSMaxVersion = "1.2"
+	bench.TLSCipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	for _, r := range rs {
+		assert.Equal(t, int64(2), r.Counters.Success, "Run(ctx) success counter")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, serverNames)
+	for i := range serverNames {
+		assert.Equal(t, "dns.example.com", serverNames[i])
+		assert.Equal(t, uint16(tls.VersionTLS12), versions[i])
+	}
+}
+
+func TestBenchmark_loadTLS_versions(t *testing.T) {
+	b := Benchmark{TLSMinVersion: "1.2", TLSMaxVersion: "1.3", TLSCipherSuites: []string{"tls_ecdhe_rsa_with_aes_128_gcm_sha256"}}
+	require.NoError(t, b.loadTLS())
+	assert.Equal(t, uint16(tls.VersionTLS12), b.tlsMinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS13), b.tlsMaxVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, b.tlsCipherSuites)
+
+	for _, b := range []Benchmark{
+		{TLSMinVersion: "1.4"},
+		{TLSMinVersion: "1.3", TLSMaxVersion: "1.2"},
+		{TLSCipherSuites: []string{"TLS_FOO"}},
+	} {
+		assert.Error(t, b.loadTLS())
+	}
+}
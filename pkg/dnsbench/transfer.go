@@ -0,0 +1,110 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+// TransferStats are results of zone transfers, see Benchmark.Transfer.
+type TransferStats struct {
+	// FirstRecord is a histogram of times to the first record of the transfer.
+	FirstRecord *hdrhistogram.Histogram
+	Records     int64
+	Bytes       int64
+}
+
+func (t *TransferStats) add(o *TransferStats) {
+	if o.FirstRecord != nil {
+		if t.FirstRecord == nil {
+			t.FirstRecord = hdrhistogram.New(o.FirstRecord.LowestTrackableValue(), o.FirstRecord.HighestTrackableValue(), int(o.FirstRecord.SignificantFigures()))
+		}
+		t.FirstRecord.Merge(o.FirstRecord)
+	}
+	t.Records += o.Records
+	t.Bytes += o.Bytes
+}
+
+// countingConn counts bytes read from the connection.
+type countingConn struct {
+	net.Conn
+	read atomic.Int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+// getTransferClient returns query function performing zone transfer of the queried zone, the results of the transfer are recorded to st.
+// The returned response contains no records, since the records of the transfer are not needed for the benchmark results.
+func (b *Benchmark) getTransferClient(st *ResultStats) queryFunc {
+	return func(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+		start := time.Now()
+
+		network := b.network("tcp")
+		dialer := b.dialer(network)
+		var conn net.Conn
+		var err error
+		if b.DOT {
+			conn, err = (&tls.Dialer{NetDialer: dialer, Config: b.tlsConfig("")}).DialContext(ctx, network, server)
+		} else {
+			conn, err = dialer.DialContext(ctx, network, server)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cc := &countingConn{Conn: conn}
+
+		// transfer does not support context, so the connection is closed when the context is done
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+			case <-done:
+			}
+		}()
+
+		t := dns.Transfer{Conn: &dns.Conn{Conn: cc}, ReadTimeout: b.ReadTimeout, WriteTimeout: b.WriteTimeout}
+		if b.tsig != nil {
+			t.TsigSecret = map[string]string{b.tsig.name: b.tsig.secret}
+		}
+		env, err := t.In(msg, server)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		var firstRecord time.Duration
+		var records int64
+		for e := range env {
+			if e.Error != nil {
+				err = e.Error
+				continue
+			}
+			if firstRecord == 0 {
+				firstRecord = time.Since(start)
+			}
+			records += int64(len(e.RR))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		st.Transfer.FirstRecord.RecordValue(firstRecord.Nanoseconds())
+		st.Transfer.Records += records
+		st.Transfer.Bytes += cc.read.Load()
+
+		resp := new(dns.Msg)
+		resp.SetReply(msg)
+		return resp, nil
+	}
+}
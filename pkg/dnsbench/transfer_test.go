@@ -0,0 +1,72 @@
+package dnsbench
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func transferHandler(w dns.ResponseWriter, r *dns.Msg) {
+	soa, _ := dns.NewRR("example.org. 3600 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 60")
+	records := []dns.RR{soa, A("example.org. 3600 IN A 127.0.0.1"), A("www.example.org. 3600 IN A 127.0.0.2"), soa}
+
+	ch := make(chan *dns.Envelope)
+	tr := new(dns.Transfer)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tr.Out(w, r, ch)
+	}()
+
+	// wait some time to actually have some observable duration
+	time.Sleep(100 * time.Millisecond)
+
+	ch <- &dns.Envelope{RR: records[:2]}
+	ch <- &dns.Envelope{RR: records[2:]}
+	close(ch)
+	wg.Wait()
+	w.Hijack()
+}
+
+func Test_do_transfer(t *testing.T) {
+	for _, transfer := range []string{"axfr", "ixfr"} {
+		t.Run(transfer, func(t *testing.T) {
+			s := NewServer(tcp, transferHandler)
+			defer s.Close()
+
+			bench := createBenchmark(s.Addr, false, 1)
+			bench.Transfer = transfer
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 2)
+			for _, st := range rs {
+				assert.Equal(t, int64(1), st.Counters.Total)
+				assert.Equal(t, int64(1), st.Counters.Success)
+				assert.Zero(t, st.Counters.IOError, st.Errors)
+				assert.Equal(t, int64(4), st.Transfer.Records)
+				assert.NotZero(t, st.Transfer.Bytes)
+				assert.Equal(t, int64(1), st.Transfer.FirstRecord.TotalCount())
+				assert.NotZero(t, st.Transfer.FirstRecord.Min())
+			}
+		})
+	}
+}
+
+func Test_transfer_doh(t *testing.T) {
+	bench := createBenchmark("https://127.0.0.1/dns-query", false, 1)
+	bench.Transfer = "axfr"
+
+	_, err := bench.Run(context.Background())
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package dnsbench
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var tsigAlgorithms = map[string]string{
+	"hmac-md5":    dns.HmacMD5,
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha224": dns.HmacSHA224,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha384": dns.HmacSHA384,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// tsigKey is a key used for signing queries and verifying responses, see https://datatracker.ietf.org/doc/html/rfc8945.
+type tsigKey struct {
+	name      string
+	algorithm string
+	secret    string
+}
+
+// parseTsigKey parses TSIG key in format name:algorithm:secret, where the secret is base64 encoded.
+func parseTsigKey(s string) (*tsigKey, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return nil, errors.New("invalid TSIG key, expected format name:algorithm:secret")
+	}
+	alg, ok := tsigAlgorithms[strings.ToLower(strings.TrimSuffix(parts[1], "."))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TSIG algorithm '%s'", parts[1])
+	}
+	if _, err := base64.StdEncoding.DecodeString(parts[2]); err != nil {
+		return nil, fmt.Errorf("invalid TSIG secret, expected base64 encoded secret: %v", err)
+	}
+	return &tsigKey{name: dns.CanonicalName(parts[0]), algorithm: alg, secret: parts[2]}, nil
+}
+
+// isTsigError checks whether the error is caused by failed TSIG verification of the response.
+func isTsigError(err error) bool {
+	return errors.Is(err, dns.ErrSig) || errors.Is(err, dns.ErrTime) || errors.Is(err, dns.ErrSecret) || errors.Is(err, dns.ErrKeyAlg)
+}
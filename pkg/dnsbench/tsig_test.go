@@ -0,0 +1,75 @@
+package dnsbench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseTsigKey(t *testing.T) {
+	key, err := parseTsigKey("Key:hmac-sha256:c2VjcmV0")
+
+	require.NoError(t, err)
+	assert.Equal(t, &tsigKey{name: "key.", algorithm: dns.HmacSHA256, secret: "c2VjcmV0"}, key)
+
+	for _, s := range []string{"key", "key:hmac-sha256", "key:foo:c2VjcmV0", "key:hmac-sha256:%%%"} {
+		_, err := parseTsigKey(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_do_classic_dns_tsig(t *testing.T) {
+	tests := []struct {
+		name         string
+		serverSecret string
+		sign         bool
+		wantSuccess  int64
+		wantTSIGErrs int64
+	}{
+		{name: "valid TSIG", serverSecret: "c2VjcmV0", sign: true, wantSuccess: 2},
+		{name: "invalid TSIG", serverSecret: "b3RoZXI=", sign: true, wantTSIGErrs: 2},
+		{name: "unsigned response", serverSecret: "c2VjcmV0", sign: false, wantTSIGErrs: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewTsigServer(udp, map[string]string{"key.": tt.serverSecret}, func(w dns.ResponseWriter, r *dns.Msg) {
+				ret := new(dns.Msg)
+				ret.SetReply(r)
+				if tt.sign && r.IsTsig() != nil {
+					ret.SetTsig("key.", dns.HmacSHA256, 300, time.Now().Unix())
+				}
+				w.WriteMsg(ret)
+			})
+			defer s.Close()
+
+			bench := createBenchmark(s.Addr, false, 1)
+			bench.TSIG = "key.:hmac-sha256:c2VjcmV0"
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			for _, st := range rs {
+				assert.Equal(t, int64(2), st.Counters.Total)
+				assert.Equal(t, tt.wantSuccess, st.Counters.Success)
+				assert.Equal(t, tt.wantTSIGErrs, st.Counters.TSIGError)
+				assert.Zero(t, st.Counters.IOError)
+			}
+		})
+	}
+}
+
+func Test_tsig_doh(t *testing.T) {
+	bench := createBenchmark("https://127.0.0.1/dns-query", false, 1)
+	bench.TSIG = "key.:hmac-sha256:c2VjcmV0"
+
+	_, err := bench.Run(context.Background())
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/miekg/dns"
+	"github.com/olekukonko/tablewriter"
+)
+
+// ttlBuckets are the upper bounds of the buckets of TTL distribution, TTLs above the last bound are counted in the last bucket.
+var ttlBuckets = []struct {
+	label string
+	max   uint32
+}{
+	{label: "0", max: 0},
+	{label: "1s-59s", max: 59},
+	{label: "1m-5m", max: 299},
+	{label: "5m-1h", max: 3599},
+	{label: "1h-1d", max: 86399},
+	{label: "1d+", max: math.MaxUint32},
+}
+
+// TTLStats holds statistics of TTLs of the answer records of the responses to the queries of a single question type.
+type TTLStats struct {
+	// Answers is the number of the answer records.
+	Answers int64
+	Min     uint32
+	Max     uint32
+	Sum     uint64
+	// ZeroResponses counts the responses with at least one answer record with zero TTL, which cannot be cached.
+	ZeroResponses int64
+	// Buckets counts the answer records by TTL buckets, see ttlBuckets.
+	Buckets []int64
+}
+
+func newTTLStats() *TTLStats {
+	return &TTLStats{Min: math.MaxUint32, Buckets: make([]int64, len(ttlBuckets))}
+}
+
+func (t *TTLStats) add(o *TTLStats) {
+	if o.Answers == 0 {
+		t.ZeroResponses += o.ZeroResponses
+		return
+	}
+	t.Answers += o.Answers
+	if o.Min < t.Min {
+		t.Min = o.Min
+	}
+	if o.Max > t.Max {
+		t.Max = o.Max
+	}
+	t.Sum += o.Sum
+	t.ZeroResponses += o.ZeroResponses
+	for i := range t.Buckets {
+		if i < len(o.Buckets) {
+			t.Buckets[i] += o.Buckets[i]
+		}
+	}
+}
+
+// Avg returns the average TTL of the answer records.
+func (t *TTLStats) Avg() float64 {
+	if t.Answers == 0 {
+		return 0
+	}
+	return float64(t.Sum) / float64(t.Answers)
+}
+
+// recordTTLs records TTLs of the answer records of the response by the question type of the query.
+func (rs *ResultStats) recordTTLs(req, resp *dns.Msg) {
+	if rs.TTLs == nil || len(resp.Answer) == 0 {
+		return
+	}
+	qtype := dns.TypeToString[req.Question[0].Qtype]
+	t, ok := rs.TTLs[qtype]
+	if !ok {
+		t = newTTLStats()
+		rs.TTLs[qtype] = t
+	}
+
+	var zero bool
+	for _, rr := range resp.Answer {
+		ttl := rr.Header().Ttl
+		t.Answers++
+		t.Sum += uint64(ttl)
+		if ttl < t.Min {
+			t.Min = ttl
+		}
+		if ttl > t.Max {
+			t.Max = ttl
+		}
+		for i, b := range ttlBuckets {
+			if ttl <= b.max {
+				t.Buckets[i]++
+				break
+			}
+		}
+		zero = zero || ttl == 0
+	}
+	if zero {
+		t.ZeroResponses++
+	}
+}
+
+func mergeTTLs(dst, src map[string]*TTLStats) map[string]*TTLStats {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]*TTLStats)
+	}
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = newTTLStats()
+		}
+		dst[k].add(v)
+	}
+	return dst
+}
+
+// printTTLs prints TTL statistics and distribution of the answer records by question type, responses with zero TTLs are highlighted.
+func printTTLs(w io.Writer, ttls map[string]*TTLStats) {
+	if len(ttls) == 0 {
+		return
+	}
+	qtypes := make([]string, 0, len(ttls))
+	for k := range ttls {
+		qtypes = append(qtypes, k)
+	}
+	sort.Strings(qtypes)
+
+	header := []string{"Type", "Answers", "Min", "Avg", "Max"}
+	for _, b := range ttlBuckets {
+		header = append(header, "TTL "+b.label)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Answer TTLs by question type:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(header)
+	table.SetBorder(false)
+	var zeros int64
+	for _, k := range qtypes {
+		t := ttls[k]
+		if t.Answers == 0 {
+			continue
+		}
+		line := []string{k, strconv.FormatInt(t.Answers, 10), fmt.Sprint(t.Min), fmt.Sprintf("%0.1f", t.Avg()), fmt.Sprint(t.Max)}
+		for _, c := range t.Buckets {
+			line = append(line, strconv.FormatInt(c, 10))
+		}
+		table.Append(line)
+		zeros += t.ZeroResponses
+	}
+	table.Render()
+
+	if zeros > 0 {
+		errPrint(w, "Responses with zero TTL answers:\t%d\n", zeros)
+	}
+}
+
+type jsonTTLStats struct {
+	Answers       int64            `json:"answers"`
+	Min           uint32           `json:"min"`
+	Avg           float64          `json:"avg"`
+	Max           uint32           `json:"max"`
+	ZeroResponses int64            `json:"zeroTTLResponses,omitempty"`
+	Distribution  map[string]int64 `json:"distribution"`
+}
+
+func jsonTTLs(ttls map[string]*TTLStats) map[string]jsonTTLStats {
+	if len(ttls) == 0 {
+		return nil
+	}
+	res := make(map[string]jsonTTLStats, len(ttls))
+	for k, t := range ttls {
+		if t.Answers == 0 {
+			continue
+		}
+		j := jsonTTLStats{Answers: t.Answers, Min: t.Min, Avg: math.Round(t.Avg()*10) / 10, Max: t.Max, ZeroResponses: t.ZeroResponses,
+			Distribution: make(map[string]int64, len(ttlBuckets))}
+		for i, b := range ttlBuckets {
+			j.Distribution[b.label] = t.Buckets[i]
+		}
+		res[k] = j
+	}
+	return res
+}
@@ -0,0 +1,87 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultStats_recordTTLs(t *testing.T) {
+	rs := ResultStats{TTLs: make(map[string]*TTLStats)}
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	for _, ttl := range []uint32{0, 30, 300, 100000} {
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl}, A: net.IPv4(127, 0, 0, 1)})
+	}
+	rs.recordTTLs(req, resp)
+	resp.Answer = resp.Answer[2:3]
+	rs.recordTTLs(req, resp)
+	// responses without answers are not recorded
+	resp.Answer = nil
+	rs.recordTTLs(req, resp)
+
+	ttls := rs.TTLs["A"]
+	require.NotNil(t, ttls)
+	assert.Equal(t, int64(5), ttls.Answers)
+	assert.Equal(t, uint32(0), ttls.Min)
+	assert.Equal(t, uint32(100000), ttls.Max)
+	assert.InDelta(t, 20126.0, ttls.Avg(), 0.1)
+	assert.Equal(t, int64(1), ttls.ZeroResponses)
+	assert.Equal(t, []int64{1, 1, 0, 2, 0, 1}, ttls.Buckets)
+
+	merged := mergeTTLs(nil, map[string]*TTLStats{"A": ttls})
+	merged = mergeTTLs(merged, map[string]*TTLStats{"A": ttls})
+	assert.Equal(t, int64(10), merged["A"].Answers)
+	assert.Equal(t, uint32(0), merged["A"].Min)
+	assert.Equal(t, uint32(100000), merged["A"].Max)
+	assert.Equal(t, int64(2), merged["A"].ZeroResponses)
+	assert.Equal(t, []int64{2, 2, 0, 4, 0, 2}, merged["A"].Buckets)
+}
+
+func TestBenchmark_Run_ttls(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		q := r.Question[0]
+		if q.Qtype == dns.TypeA {
+			ret.Answer = append(ret.Answer, &dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.IPv4(127, 0, 0, 1)})
+		} else {
+			ret.Answer = append(ret.Answer, &dns.AAAA{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 0}, AAAA: net.IPv6loopback})
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	require.Len(t, merged.TTLs, 2)
+	assert.Equal(t, int64(2), merged.TTLs["A"].Answers)
+	assert.Equal(t, uint32(300), merged.TTLs["A"].Min)
+	assert.Equal(t, int64(0), merged.TTLs["A"].ZeroResponses)
+	assert.Equal(t, int64(2), merged.TTLs["AAAA"].ZeroResponses)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "Answer TTLs by question type:")
+	assert.Contains(t, buf.String(), "Responses with zero TTL answers:\t2\n")
+
+	bench.JSON = true
+	buf.Reset()
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), `"A":{"answers":2,"min":300,"avg":300,"max":300,"distribution":{"0":0,"1d+":0,"1h-1d":0,"1m-5m":0,"1s-59s":0,"5m-1h":2}}`)
+}
@@ -0,0 +1,127 @@
+package dnsbench
+
+import (
+	"context"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// recentIDs is a number of message IDs of the queries recently sent over UDP connection, which are remembered by udpIDs.
+const recentIDs = 256
+
+// udpIDs remembers the message IDs of the queries recently sent over the UDP connection and whether they were answered, so that the responses
+// not matching the current query can be told apart. Such responses are either duplicates of the responses already received, or late responses
+// to the queries which timed out, or responses to none of the recent queries.
+type udpIDs struct {
+	answered map[uint16]bool
+	order    []uint16
+}
+
+func (u *udpIDs) add(id uint16, answered bool) {
+	if u.answered == nil {
+		u.answered = make(map[uint16]bool)
+	}
+	if _, ok := u.answered[id]; !ok {
+		u.order = append(u.order, id)
+		if len(u.order) > recentIDs {
+			delete(u.answered, u.order[0])
+			u.order = u.order[1:]
+		}
+	}
+	u.answered[id] = answered
+}
+
+// recordUnmatched counts the response, which does not match the current query, by the state of the recent query with the same message ID.
+func (u *udpIDs) recordUnmatched(id uint16, c *Counters) {
+	answered, ok := u.answered[id]
+	switch {
+	case !ok:
+		c.IDmismatch++
+	case answered:
+		c.DuplicateResponses++
+	default:
+		c.ExpiredResponses++
+	}
+}
+
+// exchangeUDP sends the query over the UDP connection and reads the responses until the response to the query arrives or the context is done,
+// the responses not matching the query are counted by ids and skipped. When Benchmark.UDPRetry is set, the query is retransmitted with fresh
+// message ID whenever Benchmark.UDPRetryInterval elapses without response, up to Benchmark.UDPRetry times, the same way the stub resolvers do.
+// The response to any of the sent messages is accepted, the response to other message than the last one sent is late, it arrived only after
+// the query was retransmitted. The message ID of the query is set to the ID of the last sent message, so that the accepted response has
+// the same ID as the query.
+func (b *Benchmark) exchangeUDP(ctx context.Context, co *dns.Conn, msg *dns.Msg, ids *udpIDs, st *ResultStats) (*dns.Msg, error) {
+	co.UDPSize = dns.MinMsgSize
+	if opt := msg.IsEdns0(); opt != nil && opt.UDPSize() >= dns.MinMsgSize {
+		co.UDPSize = opt.UDPSize()
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(b.RequestTimeout)
+	}
+
+	sent := []uint16{msg.Id}
+	defer func() {
+		for _, id := range sent {
+			ids.add(id, false)
+		}
+	}()
+	for retransmits := 0; ; retransmits++ {
+		if err := co.SetWriteDeadline(deadline); err != nil {
+			return nil, err
+		}
+		if err := co.WriteMsg(msg); err != nil {
+			return nil, err
+		}
+		retransmitAt := time.Now().Add(b.UDPRetryInterval)
+		readDeadline := deadline
+		if retransmits < b.UDPRetry && retransmitAt.Before(deadline) {
+			readDeadline = retransmitAt
+		}
+		if err := co.SetReadDeadline(readDeadline); err != nil {
+			return nil, err
+		}
+		if r, err := readResponse(co, msg.Id, sent, ids, st.Counters, readDeadline.Before(deadline)); r != nil || err != nil {
+			if r != nil {
+				// the other sent messages are answered by the accepted response as well
+				for _, id := range sent {
+					ids.add(id, true)
+				}
+				sent = nil
+			}
+			return r, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg.Id = dns.Id()
+		sent = append(sent, msg.Id)
+		st.Counters.UDPRetransmits++
+	}
+}
+
+// readResponse reads the responses until the response to any of the sent messages arrives, nil response and nil error are returned
+// when the read deadline is exceeded and the query is going to be retransmitted.
+func readResponse(co *dns.Conn, last uint16, sent []uint16, ids *udpIDs, c *Counters, retransmit bool) (*dns.Msg, error) {
+	for {
+		r, err := co.ReadMsg()
+		if err != nil && isTimeout(err) && retransmit {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range sent {
+			if r.Id != id {
+				continue
+			}
+			if id != last {
+				c.LateResponses++
+				r.Id = last
+			}
+			return r, nil
+		}
+		ids.recordUnmatched(r.Id, c)
+	}
+}
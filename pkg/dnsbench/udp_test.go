@@ -0,0 +1,141 @@
+package dnsbench
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_Run_udpRetry(t *testing.T) {
+	var seen sync.Map
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		// the first message of each question is lost
+		if _, loaded := seen.LoadOrStore(r.Question[0].String(), true); !loaded {
+			return
+		}
+		replyHandler(w, r)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Concurrency = 1
+	bench.UDPRetry = 2
+	bench.UDPRetryInterval = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	c := Merge(rs).Counters
+	assert.Equal(t, int64(2), c.Success)
+	assert.Zero(t, c.IOError)
+	assert.Equal(t, int64(2), c.UDPRetransmits)
+	assert.Zero(t, c.LateResponses)
+}
+
+func TestBenchmark_Run_udpRetryLateResponses(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		time.Sleep(120 * time.Millisecond)
+		replyHandler(w, r)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.UDPRetry = 2
+	bench.UDPRetryInterval = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	c := Merge(rs).Counters
+	assert.Equal(t, int64(4), c.Success, "responses to the previous queries are ignored")
+	assert.Zero(t, c.IDmismatch)
+	assert.Equal(t, int64(8), c.UDPRetransmits)
+	assert.Equal(t, int64(4), c.LateResponses, "the first sent message is answered first")
+}
+
+func TestBenchmark_normalize_udpRetry(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", UDPRetry: 2}
+	assert.Error(t, b.normalize(), "retry interval is required")
+
+	b = Benchmark{Server: "8.8.8.8", UDPRetry: 2, UDPRetryInterval: time.Second, TCP: true}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", UDPRetry: 2, UDPRetryInterval: time.Second}
+	assert.NoError(t, b.normalize())
+}
+
+func Test_udpIDs(t *testing.T) {
+	var ids udpIDs
+	ids.add(1, true)
+	ids.add(2, false)
+	for i := 3; i < recentIDs+2; i++ {
+		ids.add(uint16(i), true)
+	}
+
+	c := Counters{}
+	ids.recordUnmatched(1, &c)
+	ids.recordUnmatched(2, &c)
+	ids.recordUnmatched(3, &c)
+	assert.Equal(t, int64(1), c.IDmismatch, "the oldest ID is forgotten")
+	assert.Equal(t, int64(1), c.ExpiredResponses)
+	assert.Equal(t, int64(1), c.DuplicateResponses)
+}
+
+func TestBenchmark_Run_duplicateResponses(t *testing.T) {
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		replyHandler(w, r)
+		replyHandler(w, r)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Concurrency = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	c := Merge(rs).Counters
+	assert.Equal(t, int64(2), c.Success)
+	assert.Equal(t, int64(1), c.DuplicateResponses, "the duplicate of the first response is received while waiting for the second one")
+	assert.Zero(t, c.IDmismatch)
+}
+
+func TestBenchmark_Run_expiredResponses(t *testing.T) {
+	var received atomic.Int64
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		delay := 100 * time.Millisecond
+		if received.Add(1) == 1 {
+			delay = 250 * time.Millisecond
+		}
+		time.Sleep(delay)
+		replyHandler(w, r)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Concurrency = 1
+	bench.RequestTimeout = 200 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	c := Merge(rs).Counters
+	assert.Equal(t, int64(1), c.Timeouts)
+	assert.Equal(t, int64(1), c.Success)
+	assert.Equal(t, int64(1), c.ExpiredResponses, "the response to the first query arrives after it timed out")
+	assert.Zero(t, c.IDmismatch)
+}
@@ -0,0 +1,229 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+// liveStats are statistics of the running benchmark used for live dashboard and progress reporting,
+// the statistics are safe for concurrent use by benchmark workers.
+type liveStats struct {
+	start time.Time
+
+	inFlight atomic.Int64
+
+	mu       sync.Mutex
+	requests int64
+	errors   int64
+	codes    map[int]int64
+	// windows are consumers of the snapshots, each of them takes the snapshots in its own interval, windows[0] is used by the progress and the dashboard
+	windows []*liveWindow
+}
+
+// liveWindow holds latencies and requests finished since the previous snapshot of a single consumer of the snapshots.
+type liveWindow struct {
+	hist         *hdrhistogram.Histogram
+	lastRequests int64
+	lastSnapshot time.Time
+	// cumulative windows are not reset by the snapshots, so the snapshots contain the latencies and QPS since the start of the benchmark
+	cumulative bool
+}
+
+// liveSnapshot is a state of the running benchmark, rolling latencies and QPS are computed from the requests finished since the previous snapshot.
+type liveSnapshot struct {
+	elapsed  time.Duration
+	qps      float64
+	inFlight int64
+	requests int64
+	errors   int64
+	mean     time.Duration
+	p50      time.Duration
+	p99      time.Duration
+	max      time.Duration
+	codes    []int
+	counts   []int64
+}
+
+func newLiveStats(b *Benchmark) *liveStats {
+	l := &liveStats{start: time.Now(), codes: make(map[int]int64)}
+	l.addWindow(b)
+	return l
+}
+
+// addWindow adds consumer of the snapshots, it has to be called before the benchmark workers start.
+func (l *liveStats) addWindow(b *Benchmark) *liveWindow {
+	w := &liveWindow{hist: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre), lastSnapshot: l.start}
+	l.windows = append(l.windows, w)
+	return w
+}
+
+// sent is called when a request is sent.
+func (l *liveStats) sent() {
+	if l == nil {
+		return
+	}
+	l.inFlight.Add(1)
+}
+
+// cancelled is called when a request is cancelled by the end of the benchmark.
+func (l *liveStats) cancelled() {
+	if l == nil {
+		return
+	}
+	l.inFlight.Add(-1)
+}
+
+// done is called when a request is finished, either with the response or the error.
+func (l *liveStats) done(resp *dns.Msg, latency time.Duration, err error) {
+	if l == nil {
+		return
+	}
+	l.inFlight.Add(-1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests++
+	if err != nil || resp == nil {
+		l.errors++
+		return
+	}
+	l.codes[resp.Rcode]++
+	for _, w := range l.windows {
+		w.hist.RecordValue(latency.Nanoseconds())
+	}
+}
+
+// snapshot takes snapshot for the progress and the dashboard.
+func (l *liveStats) snapshot() liveSnapshot {
+	return l.snapshotOf(l.windows[0])
+}
+
+func (l *liveStats) snapshotOf(w *liveWindow) liveSnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	s := liveSnapshot{
+		elapsed:  now.Sub(l.start),
+		qps:      float64(l.requests-w.lastRequests) / now.Sub(w.lastSnapshot).Seconds(),
+		inFlight: l.inFlight.Load(),
+		requests: l.requests,
+		errors:   l.errors,
+	}
+	if w.hist.TotalCount() > 0 {
+		s.mean = time.Duration(w.hist.Mean())
+		s.p50 = time.Duration(w.hist.ValueAtQuantile(50))
+		s.p99 = time.Duration(w.hist.ValueAtQuantile(99))
+		s.max = time.Duration(w.hist.Max())
+	}
+	for k := range l.codes {
+		s.codes = append(s.codes, k)
+	}
+	sort.Ints(s.codes)
+	for _, k := range s.codes {
+		s.counts = append(s.counts, l.codes[k])
+	}
+
+	if w.cumulative {
+		return s
+	}
+	w.hist.Reset()
+	w.lastRequests = l.requests
+	w.lastSnapshot = now
+	return s
+}
+
+// ProgressStats is a progress of the running benchmark passed to Benchmark.ProgressFunc.
+type ProgressStats struct {
+	// Elapsed is time since the start of the benchmark.
+	Elapsed time.Duration
+	// QueriesPerSecond is computed from the requests finished since the previous progress.
+	QueriesPerSecond float64
+	InFlight         int64
+	Requests         int64
+	Errors           int64
+	// P50 and P99 are latency percentiles of the requests finished since the previous progress, they are zero if no request finished.
+	P50, P99 time.Duration
+	// Rcodes counts the response codes of all the responses received so far.
+	Rcodes map[int]int64
+}
+
+func (s liveSnapshot) export() ProgressStats {
+	p := ProgressStats{
+		Elapsed:          s.elapsed,
+		QueriesPerSecond: s.qps,
+		InFlight:         s.inFlight,
+		Requests:         s.requests,
+		Errors:           s.errors,
+		P50:              s.p50,
+		P99:              s.p99,
+		Rcodes:           make(map[int]int64, len(s.codes)),
+	}
+	for i, k := range s.codes {
+		p.Rcodes[k] = s.counts[i]
+	}
+	return p
+}
+
+// run renders the snapshots of the window in the interval until the stop channel is closed.
+func (l *liveStats) run(w *liveWindow, stop <-chan struct{}, interval time.Duration, render func(liveSnapshot)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			render(l.snapshotOf(w))
+		case <-stop:
+			render(l.snapshotOf(w))
+			return
+		}
+	}
+}
+
+// renderDashboard draws live dashboard of the running benchmark.
+func renderDashboard(w io.Writer, server string, s liveSnapshot) {
+	// clear the screen and move the cursor to the top left corner
+	fmt.Fprint(w, "\033[H\033[2J")
+	fmt.Fprintf(w, "Benchmarking %s, elapsed %s\n\n", highlightStr(server), highlightStr(roundDuration(s.elapsed).String()))
+	fmt.Fprintf(w, "Questions per second:\t%s\n", highlightStr(fmt.Sprintf("%0.1f", s.qps)))
+	fmt.Fprintf(w, "In-flight requests:\t%s\n", highlightStr(s.inFlight))
+	fmt.Fprintf(w, "Total requests:\t\t%s\n", highlightStr(s.requests))
+	if s.errors > 0 {
+		errPrint(w, "Errors:\t\t\t%d\n", s.errors)
+	} else {
+		successPrint(w, "Errors:\t\t\t%d\n", s.errors)
+	}
+	fmt.Fprintf(w, "Latency p50:\t\t%s\n", highlightStr(formatLiveLatency(s.p50)))
+	fmt.Fprintf(w, "Latency p99:\t\t%s\n", highlightStr(formatLiveLatency(s.p99)))
+
+	if len(s.codes) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DNS response codes:")
+		for i, k := range s.codes {
+			printFn := errPrint
+			if k == dns.RcodeSuccess {
+				printFn = successPrint
+			}
+			printFn(w, "\t%s:\t%d\n", dns.RcodeToString[k], s.counts[i])
+		}
+	}
+}
+
+func formatLiveLatency(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return roundDuration(d).String()
+}
+
+// renderProgress prints single line with progress of the running benchmark.
+func renderProgress(w io.Writer, s liveSnapshot) {
+	fmt.Fprintf(w, "[%s] requests: %d, questions per second: %0.1f, errors: %d\n", roundDuration(s.elapsed), s.requests, s.qps, s.errors)
+}
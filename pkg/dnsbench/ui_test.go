@@ -0,0 +1,64 @@
+package dnsbench
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_renderDashboard(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLiveStats(&Benchmark{HistMin: 0, HistMax: time.Second, HistPre: 1})
+
+	resp := dns.Msg{}
+	resp.Rcode = dns.RcodeNameError
+	l.sent()
+	l.done(&resp, 10*time.Millisecond, nil)
+	l.sent()
+	l.done(nil, 0, errors.New("test"))
+	l.sent()
+
+	renderDashboard(&buf, "127.0.0.1:53", l.snapshot())
+
+	out := buf.String()
+	assert.Contains(t, out, "Benchmarking 127.0.0.1:53")
+	assert.Contains(t, out, "In-flight requests:\t1")
+	assert.Contains(t, out, "Total requests:\t\t2")
+	assert.Contains(t, out, "Errors:\t\t\t1")
+	assert.Regexp(t, `Latency p50:\t\t10\.\d+ms`, out)
+	assert.Contains(t, out, "NXDOMAIN:\t1")
+
+	buf.Reset()
+	renderDashboard(&buf, "127.0.0.1:53", l.snapshot())
+	assert.Contains(t, buf.String(), "Latency p50:\t\t-", "expected no latencies since the previous snapshot")
+}
+
+func Test_renderProgress(t *testing.T) {
+	var buf bytes.Buffer
+
+	renderProgress(&buf, liveSnapshot{elapsed: 10 * time.Second, requests: 100, qps: 10, errors: 2})
+
+	assert.Equal(t, "[10s] requests: 100, questions per second: 10.0, errors: 2\n", buf.String())
+}
+
+func Test_liveStats_nil(t *testing.T) {
+	var l *liveStats
+
+	assert.NotPanics(t, func() {
+		l.sent()
+		l.done(&dns.Msg{}, time.Second, nil)
+		l.cancelled()
+	})
+}
+
+func Test_liveSnapshot_export(t *testing.T) {
+	s := liveSnapshot{elapsed: time.Second, qps: 10, inFlight: 1, requests: 11, errors: 1, p50: time.Millisecond, p99: 5 * time.Millisecond,
+		codes: []int{dns.RcodeSuccess, dns.RcodeNameError}, counts: []int64{8, 2}}
+
+	assert.Equal(t, ProgressStats{Elapsed: time.Second, QueriesPerSecond: 10, InFlight: 1, Requests: 11, Errors: 1, P50: time.Millisecond, P99: 5 * time.Millisecond,
+		Rcodes: map[int]int64{dns.RcodeSuccess: 8, dns.RcodeNameError: 2}}, s.export())
+}
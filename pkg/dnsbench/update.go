@@ -0,0 +1,80 @@
+package dnsbench
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// operations of the UPDATE messages, see Benchmark.UpdateOp.
+const (
+	updateAdd     = "add"
+	updateDelete  = "delete"
+	updateReplace = "replace"
+)
+
+// updateTypes are the types of the records, whose data are generated for the UPDATE messages.
+var updateTypes = map[uint16]bool{dns.TypeA: true, dns.TypeAAAA: true, dns.TypeTXT: true}
+
+// checkUpdateQuestions checks that the questions of the benchmark can be turned into UPDATE messages of the zone,
+// the names have to be within the zone and the records of the question types have to be supported.
+func checkUpdateQuestions(questions []dns.Question, qtypes []uint16, zone string) error {
+	for _, q := range questions {
+		if !dns.IsSubDomain(zone, q.Name) {
+			return fmt.Errorf("name '%s' is not within the updated zone '%s'", q.Name, zone)
+		}
+		if q.Qtype == dns.TypeNone {
+			continue
+		}
+		if !updateTypes[q.Qtype] {
+			return fmt.Errorf("records of type %s cannot be updated, only A, AAAA and TXT records are supported", dns.TypeToString[q.Qtype])
+		}
+	}
+	for _, qt := range qtypes {
+		if !updateTypes[qt] {
+			return fmt.Errorf("records of type %s cannot be updated, only A, AAAA and TXT records are supported", dns.TypeToString[qt])
+		}
+	}
+	return nil
+}
+
+// setUpdate turns the query into UPDATE message of the zone according to Benchmark.UpdateOp, the name and type of the question
+// are the owner name and type of the updated record and the data of the added record are random.
+func (b *Benchmark) setUpdate(m *dns.Msg, rando *rand.Rand) {
+	q := m.Question[0]
+	m.Question[0] = dns.Question{Name: b.UpdateZone, Qtype: dns.TypeSOA, Qclass: q.Qclass}
+	m.Opcode = dns.OpcodeUpdate
+	m.RecursionDesired = false
+
+	rr := updateRecord(q, b.UpdateTTL, rando)
+	switch b.UpdateOp {
+	case updateDelete:
+		m.RemoveRRset([]dns.RR{rr})
+	case updateReplace:
+		m.RemoveRRset([]dns.RR{rr})
+		m.Insert([]dns.RR{rr})
+	default:
+		m.Insert([]dns.RR{rr})
+	}
+}
+
+// updateRecord generates the record of the question with random data, A records are drawn from the benchmarking range 198.18.0.0/15
+// and AAAA records from the documentation range 2001:db8::/32.
+func updateRecord(q dns.Question, ttl uint32, rando *rand.Rand) dns.RR {
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: q.Qclass, Ttl: ttl}
+	switch q.Qtype {
+	case dns.TypeAAAA:
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, net.ParseIP("2001:db8::"))
+		rando.Read(ip[4:])
+		return &dns.AAAA{Hdr: hdr, AAAA: ip}
+	case dns.TypeTXT:
+		return &dns.TXT{Hdr: hdr, Txt: []string{"dnspyre-" + strconv.FormatUint(rando.Uint64(), 16)}}
+	default:
+		r := rando.Uint32()
+		return &dns.A{Hdr: hdr, A: net.IPv4(198, 18|byte(r>>16)&1, byte(r>>8), byte(r))}
+	}
+}
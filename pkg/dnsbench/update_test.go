@@ -0,0 +1,132 @@
+package dnsbench
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_setUpdate(t *testing.T) {
+	tests := []struct {
+		op        string
+		wantClass []uint16
+	}{
+		{op: updateAdd, wantClass: []uint16{dns.ClassINET}},
+		{op: updateDelete, wantClass: []uint16{dns.ClassANY}},
+		{op: updateReplace, wantClass: []uint16{dns.ClassANY, dns.ClassINET}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			b := Benchmark{UpdateZone: "example.org.", UpdateOp: tt.op, UpdateTTL: 60}
+			m := new(dns.Msg)
+			m.SetQuestion("host.example.org.", dns.TypeAAAA)
+
+			b.setUpdate(m, rand.New(rand.NewSource(1)))
+
+			assert.Equal(t, dns.OpcodeUpdate, m.Opcode)
+			assert.Equal(t, dns.Question{Name: "example.org.", Qtype: dns.TypeSOA, Qclass: dns.ClassINET}, m.Question[0])
+			require.Len(t, m.Ns, len(tt.wantClass))
+			for i, rr := range m.Ns {
+				assert.Equal(t, "host.example.org.", rr.Header().Name)
+				assert.Equal(t, dns.TypeAAAA, rr.Header().Rrtype)
+				assert.Equal(t, tt.wantClass[i], rr.Header().Class)
+			}
+			if aaaa, ok := m.Ns[len(m.Ns)-1].(*dns.AAAA); ok {
+				assert.Equal(t, uint32(60), aaaa.Hdr.Ttl)
+				assert.True(t, (&net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(32, 128)}).Contains(aaaa.AAAA))
+			}
+			_, err := m.Pack()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_updateRecord(t *testing.T) {
+	rando := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		a := updateRecord(dns.Question{Name: "a.", Qtype: dns.TypeA, Qclass: dns.ClassINET}, 300, rando).(*dns.A)
+		assert.Equal(t, byte(198), a.A.To4()[0])
+		assert.Contains(t, []byte{18, 19}, a.A.To4()[1], "A records are from 198.18.0.0/15")
+	}
+	aaaa := updateRecord(dns.Question{Name: "a.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}, 300, rando).(*dns.AAAA)
+	assert.Equal(t, []byte{0x20, 0x01, 0x0d, 0xb8}, []byte(aaaa.AAAA[:4]))
+	txt := updateRecord(dns.Question{Name: "a.", Qtype: dns.TypeTXT, Qclass: dns.ClassINET}, 300, rando).(*dns.TXT)
+	assert.Len(t, txt.Txt, 1)
+}
+
+func TestBenchmark_Run_update(t *testing.T) {
+	var mu sync.Mutex
+	var updates []*dns.Msg
+	s := NewTsigServer(udp, map[string]string{"key.": "c2VjcmV0"}, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.IsTsig() == nil || w.TsigStatus() != nil {
+			ret.Rcode = dns.RcodeNotAuth
+		}
+		mu.Lock()
+		updates = append(updates, r)
+		mu.Unlock()
+		ret.SetTsig("key.", dns.HmacSHA256, 300, time.Now().Unix())
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = []string{"{rand:8}.example.org"}
+	bench.UpdateZone = "example.org"
+	bench.UpdateOp = updateReplace
+	bench.TSIG = "key.:hmac-sha256:c2VjcmV0"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	merged := Merge(rs)
+	assert.Equal(t, int64(4), merged.Counters.Total)
+	assert.Equal(t, int64(4), merged.Codes[dns.RcodeSuccess], "updates are signed")
+	assert.Zero(t, merged.Counters.TSIGError)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, updates, 4)
+	names := make(map[string]bool)
+	for _, r := range updates {
+		assert.Equal(t, dns.OpcodeUpdate, r.Opcode)
+		assert.Equal(t, "example.org.", r.Question[0].Name)
+		require.Len(t, r.Ns, 2)
+		names[r.Ns[0].Header().Name] = true
+	}
+	assert.Len(t, names, 4, "template names are expanded")
+}
+
+func TestBenchmark_normalize_update(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", UpdateZone: "example.org", UpdateOp: "upsert"}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", UpdateZone: "example.org", Types: []string{"MX"}}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", UpdateZone: "example.org", Opcode: "NOTIFY"}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", UpdateZone: "example.org", Transfer: "axfr"}
+	assert.Error(t, b.normalize())
+
+	b = Benchmark{Server: "8.8.8.8", UpdateZone: "example.org"}
+	require.NoError(t, b.normalize())
+	assert.Equal(t, "example.org.", b.UpdateZone)
+	assert.Equal(t, updateAdd, b.UpdateOp)
+
+	bench := createBenchmark("127.0.0.1:53", false, 1)
+	bench.Queries = []string{"example.com"}
+	bench.UpdateZone = "example.org"
+	_, err := bench.Run(context.Background())
+	assert.Error(t, err, "names have to be within the zone")
+}
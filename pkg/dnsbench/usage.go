@@ -0,0 +1,314 @@
+package dnsbench
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// usageInterval is the interval in which the resource usage of the load generator is sampled during the benchmark.
+	usageInterval = time.Second
+	// saturatedCPU is the share of the available CPUs, above which the load generator is considered to be the bottleneck of the benchmark.
+	saturatedCPU = 0.9
+	// saturatedLimit is the share of the memory and open files limits, above which the load generator is considered to be short of them.
+	saturatedLimit = 0.9
+	// saturatedGC is the share of the run spent in GC pauses, above which the pauses noticeably inflate the measured latencies.
+	saturatedGC = 0.01
+)
+
+// cgroup files limiting the resources of the containerized load generator, cgroup v2 files are tried first.
+var (
+	cgroupCPUMax      = "/sys/fs/cgroup/cpu.max"
+	cgroupCPUQuota    = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	cgroupCPUPeriod   = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+	cgroupMemoryMax   = "/sys/fs/cgroup/memory.max"
+	cgroupMemoryLimit = "/sys/fs/cgroup/memory/memory.limit_in_bytes"
+)
+
+var errCgroupUnlimited = errors.New("resource of the cgroup is not limited")
+
+// clientUsage is the resource usage of the load generator itself during the benchmark, so that the saturation of the client can be told apart
+// from the latency of the server.
+type clientUsage struct {
+	duration time.Duration
+	// cpus is the number of CPUs available to the benchmark, that is GOMAXPROCS further limited by the CPU quota of the container.
+	cpus float64
+	// cpuAvg and cpuPeak are the average and the peak shares of the available CPUs used by the load generator.
+	cpuAvg  float64
+	cpuPeak float64
+	// memPeak is the peak memory obtained from the OS and heapPeak is the peak memory of the heap in use, memLimit is the memory limit
+	// of the container, if any.
+	memPeak  uint64
+	heapPeak uint64
+	memLimit uint64
+	// socketsPeak is the peak number of the open sockets, -1 when they cannot be counted on the platform, filesLimit is the limit
+	// of the open files of the process, if any.
+	socketsPeak int
+	filesLimit  uint64
+	gcCount     uint32
+	gcPause     time.Duration
+	gcPauseMax  time.Duration
+}
+
+// warnings returns the reasons, why the load generator and not the server is likely the bottleneck of the benchmark. The runs shorter
+// than the sampling interval are not warned about, since the usage of such runs is dominated by the start and the end of the benchmark.
+func (u *clientUsage) warnings() []string {
+	if u.duration < usageInterval {
+		return nil
+	}
+	var res []string
+	if u.cpuPeak >= saturatedCPU {
+		res = append(res, fmt.Sprintf("load generator used %0.1f%% of %s available CPUs (%0.1f%% on average), "+
+			"latencies are likely inflated by the client, lower the concurrency or rate, or spread the load across more machines",
+			u.cpuPeak*100, formatCPUs(u.cpus), u.cpuAvg*100))
+	}
+	if u.memLimit > 0 && float64(u.memPeak) >= saturatedLimit*float64(u.memLimit) {
+		res = append(res, fmt.Sprintf("load generator used %s of %s memory limit of the container", formatMiB(u.memPeak), formatMiB(u.memLimit)))
+	}
+	if u.filesLimit > 0 && u.socketsPeak > 0 && float64(u.socketsPeak) >= saturatedLimit*float64(u.filesLimit) {
+		res = append(res, fmt.Sprintf("load generator had %d open sockets of %d open files limit", u.socketsPeak, u.filesLimit))
+	}
+	if float64(u.gcPause) >= saturatedGC*float64(u.duration) {
+		res = append(res, fmt.Sprintf("load generator spent %s in GC pauses (%0.2f%% of the run), latencies are likely inflated by the client",
+			roundDuration(u.gcPause), float64(u.gcPause)/float64(u.duration)*100))
+	}
+	return res
+}
+
+// usageMonitor samples the resource usage of the load generator in the interval until it is stopped.
+type usageMonitor struct {
+	usage   clientUsage
+	start   time.Time
+	last    time.Time
+	cpu0    time.Duration
+	lastCPU time.Duration
+	numGC   uint32
+	pause0  uint64
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func startUsageMonitor(interval time.Duration) *usageMonitor {
+	m := &usageMonitor{stop: make(chan struct{}), done: make(chan struct{})}
+	m.usage.cpus = availableCPUs()
+	m.usage.memLimit = memoryLimit()
+	m.usage.filesLimit = openFilesLimit()
+	m.usage.socketsPeak = -1
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	m.numGC, m.pause0 = ms.NumGC, ms.PauseTotalNs
+	m.start, m.cpu0 = time.Now(), processCPUTime()
+	m.last, m.lastCPU = m.start, m.cpu0
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.sample(true)
+			}
+		}
+	}()
+	return m
+}
+
+// sample records the usage since the last sample, the CPU usage of the sample contributes to the peak only for the whole intervals.
+func (m *usageMonitor) sample(interval bool) {
+	now, cpu := time.Now(), processCPUTime()
+	if wall := now.Sub(m.last); interval && wall > 0 && m.usage.cpus > 0 {
+		m.usage.cpuPeak = math.Max(m.usage.cpuPeak, float64(cpu-m.lastCPU)/float64(wall)/m.usage.cpus)
+	}
+	m.last, m.lastCPU = now, cpu
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.Sys > m.usage.memPeak {
+		m.usage.memPeak = ms.Sys
+	}
+	if ms.HeapInuse > m.usage.heapPeak {
+		m.usage.heapPeak = ms.HeapInuse
+	}
+	// the pauses of the most recent 256 garbage collections are kept by the runtime
+	for gc := ms.NumGC; gc > m.numGC && ms.NumGC-gc < uint32(len(ms.PauseNs)); gc-- {
+		if p := time.Duration(ms.PauseNs[(gc+255)%256]); p > m.usage.gcPauseMax {
+			m.usage.gcPauseMax = p
+		}
+	}
+	m.usage.gcCount += ms.NumGC - m.numGC
+	m.usage.gcPause = time.Duration(ms.PauseTotalNs - m.pause0)
+	m.numGC = ms.NumGC
+
+	if s := openSockets(); s > m.usage.socketsPeak {
+		m.usage.socketsPeak = s
+	}
+}
+
+// finish stops the sampling and returns the usage during the whole run.
+func (m *usageMonitor) finish() *clientUsage {
+	close(m.stop)
+	<-m.done
+	m.sample(false)
+	m.usage.duration = m.last.Sub(m.start)
+	if m.usage.duration > 0 && m.usage.cpus > 0 {
+		m.usage.cpuAvg = float64(m.lastCPU-m.cpu0) / float64(m.usage.duration) / m.usage.cpus
+	}
+	m.usage.cpuPeak = math.Max(m.usage.cpuPeak, m.usage.cpuAvg)
+	return &m.usage
+}
+
+// availableCPUs returns the number of CPUs the benchmark can use, that is GOMAXPROCS limited by the CPU quota of the container, if any.
+func availableCPUs() float64 {
+	cpus := float64(runtime.GOMAXPROCS(0))
+	if quota, err := cgroupCPULimit(); err == nil && quota > 0 && quota < cpus {
+		cpus = quota
+	}
+	return cpus
+}
+
+// cgroupCPULimit returns the CPU quota of the cgroup in CPUs.
+func cgroupCPULimit() (float64, error) {
+	if b, err := os.ReadFile(cgroupCPUMax); err == nil {
+		fields := strings.Fields(string(b))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("invalid content of %s", cgroupCPUMax)
+		}
+		if fields[0] == "max" {
+			return 0, errCgroupUnlimited
+		}
+		return parseQuota(fields[0], fields[1])
+	}
+	quota, err := os.ReadFile(cgroupCPUQuota)
+	if err != nil {
+		return 0, err
+	}
+	period, err := os.ReadFile(cgroupCPUPeriod)
+	if err != nil {
+		return 0, err
+	}
+	return parseQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func parseQuota(quota, period string) (float64, error) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if q < 0 {
+		return 0, errCgroupUnlimited
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if p <= 0 {
+		return 0, fmt.Errorf("invalid CPU period %d", p)
+	}
+	return float64(q) / float64(p), nil
+}
+
+// memoryLimit returns the memory limit of the cgroup in bytes, zero is returned when the memory is not limited.
+func memoryLimit() uint64 {
+	for _, f := range []string{cgroupMemoryMax, cgroupMemoryLimit} {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+		// cgroup v1 reports unlimited memory as a huge number rounded down to the page size
+		if err != nil || limit >= math.MaxInt64/2 {
+			return 0
+		}
+		return limit
+	}
+	return 0
+}
+
+func formatCPUs(cpus float64) string {
+	return strconv.FormatFloat(cpus, 'f', -1, 64)
+}
+
+func formatMiB(b uint64) string {
+	return fmt.Sprintf("%0.1f MiB", float64(b)/(1<<20))
+}
+
+// printClientWarnings prints the warnings of the saturated load generator, they are printed even if the usage is not reported.
+func printClientWarnings(w io.Writer, u *clientUsage) {
+	if u == nil {
+		return
+	}
+	for _, warning := range u.warnings() {
+		errPrint(w, "\nWarning: %s.\n", warning)
+	}
+}
+
+func printClientUsage(w io.Writer, u *clientUsage) {
+	if u == nil {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Load generator resources:")
+	fmt.Fprintf(w, "\tCPU:\t\t\t%s avg, %s peak of %s CPUs\n", highlightStr(fmt.Sprintf("%0.1f%%", u.cpuAvg*100)),
+		highlightStr(fmt.Sprintf("%0.1f%%", u.cpuPeak*100)), highlightStr(formatCPUs(u.cpus)))
+	mem := fmt.Sprintf("\tMemory:\t\t\t%s peak, %s heap in use", highlightStr(formatMiB(u.memPeak)), highlightStr(formatMiB(u.heapPeak)))
+	if u.memLimit > 0 {
+		mem += fmt.Sprintf(" of %s limit", highlightStr(formatMiB(u.memLimit)))
+	}
+	fmt.Fprintln(w, mem)
+	if u.socketsPeak >= 0 {
+		sockets := fmt.Sprintf("\tOpen sockets:\t\t%s peak", highlightStr(u.socketsPeak))
+		if u.filesLimit > 0 {
+			sockets += fmt.Sprintf(" of %s open files limit", highlightStr(u.filesLimit))
+		}
+		fmt.Fprintln(w, sockets)
+	}
+	fmt.Fprintf(w, "\tGC pauses:\t\t%s in %s collections, %s max\n", highlightStr(roundDuration(u.gcPause)), highlightStr(u.gcCount),
+		highlightStr(roundDuration(u.gcPauseMax)))
+}
+
+type jsonClientUsage struct {
+	CPUs             float64  `json:"cpus"`
+	CPUAvg           float64  `json:"cpuAvg"`
+	CPUPeak          float64  `json:"cpuPeak"`
+	MemoryPeakBytes  uint64   `json:"memoryPeakBytes"`
+	HeapPeakBytes    uint64   `json:"heapPeakBytes"`
+	MemoryLimitBytes uint64   `json:"memoryLimitBytes,omitempty"`
+	SocketsPeak      *int     `json:"socketsPeak,omitempty"`
+	OpenFilesLimit   uint64   `json:"openFilesLimit,omitempty"`
+	GCCount          uint32   `json:"gcCount"`
+	GCPauseMs        float64  `json:"gcPauseMs"`
+	GCPauseMaxMs     float64  `json:"gcPauseMaxMs"`
+	Warnings         []string `json:"warnings,omitempty"`
+}
+
+func newJSONClientUsage(u *clientUsage) *jsonClientUsage {
+	res := &jsonClientUsage{
+		CPUs:             u.cpus,
+		CPUAvg:           math.Round(u.cpuAvg*10000) / 10000,
+		CPUPeak:          math.Round(u.cpuPeak*10000) / 10000,
+		MemoryPeakBytes:  u.memPeak,
+		HeapPeakBytes:    u.heapPeak,
+		MemoryLimitBytes: u.memLimit,
+		OpenFilesLimit:   u.filesLimit,
+		GCCount:          u.gcCount,
+		GCPauseMs:        durationMs(u.gcPause),
+		GCPauseMaxMs:     durationMs(u.gcPauseMax),
+		Warnings:         u.warnings(),
+	}
+	if u.socketsPeak >= 0 {
+		s := u.socketsPeak
+		res.SocketsPeak = &s
+	}
+	return res
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package dnsbench
+
+import (
+	"math"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns the user and system CPU time consumed by the process.
+func processCPUTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
+
+// openSockets returns the number of the sockets open by the process, -1 is returned when the file descriptors of the process cannot be listed,
+// which is the case on the systems without /proc filesystem.
+func openSockets() int {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	var n int
+	for _, fd := range fds {
+		if target, err := os.Readlink("/proc/self/fd/" + fd.Name()); err == nil && strings.HasPrefix(target, "socket:") {
+			n++
+		}
+	}
+	return n
+}
+
+// openFilesLimit returns the soft limit of the open files of the process, zero is returned when the open files are not limited.
+func openFilesLimit() uint64 {
+	var rl syscall.Rlimit
+	// unlimited open files are reported as the maximum value of the field, which differs across the systems
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil || rl.Cur > math.MaxInt32 {
+		return 0
+	}
+	return uint64(rl.Cur)
+}
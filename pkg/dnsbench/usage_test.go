@@ -0,0 +1,127 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_Run_clientUsage(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.ClientUsage = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	require.NotNil(t, bench.usage)
+	assert.Positive(t, bench.usage.cpus)
+	assert.Positive(t, bench.usage.memPeak)
+	if runtime.GOOS == "linux" {
+		assert.Positive(t, bench.usage.socketsPeak)
+	}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	out := buf.String()
+	assert.Contains(t, out, "Load generator resources:")
+	assert.Contains(t, out, "\tGC pauses:")
+
+	bench.JSON = true
+	buf.Reset()
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	var res struct {
+		ClientUsage *jsonClientUsage `json:"clientUsage"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	require.NotNil(t, res.ClientUsage)
+	assert.Equal(t, bench.usage.cpus, res.ClientUsage.CPUs)
+	assert.Positive(t, res.ClientUsage.MemoryPeakBytes)
+}
+
+func TestBenchmark_PrintReport_clientWarnings(t *testing.T) {
+	b, rs := testData()
+	b.usage = &clientUsage{duration: time.Second, cpus: 2, cpuAvg: 0.8, cpuPeak: 0.97, socketsPeak: -1}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, b.PrintReport(&buf, []*ResultStats{&rs}, time.Second))
+	out := buf.String()
+	assert.Contains(t, out, "Warning: load generator used 97.0% of 2 available CPUs (80.0% on average)")
+	assert.NotContains(t, out, "Load generator resources:", "usage is reported only when enabled")
+}
+
+func TestClientUsage_warnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage clientUsage
+		want  int
+	}{
+		{name: "idle", usage: clientUsage{duration: time.Second, cpus: 4, cpuAvg: 0.1, cpuPeak: 0.2, socketsPeak: 10, filesLimit: 1024}},
+		{name: "cpu", usage: clientUsage{duration: time.Second, cpus: 4, cpuAvg: 0.5, cpuPeak: 0.95}, want: 1},
+		{name: "memory", usage: clientUsage{duration: time.Second, memPeak: 95 << 20, memLimit: 100 << 20}, want: 1},
+		{name: "sockets", usage: clientUsage{duration: time.Second, socketsPeak: 1000, filesLimit: 1024}, want: 1},
+		{name: "gc", usage: clientUsage{duration: time.Second, gcPause: 20 * time.Millisecond}, want: 1},
+		{name: "unknown sockets", usage: clientUsage{duration: time.Second, socketsPeak: -1, filesLimit: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, tt.usage.warnings(), tt.want)
+		})
+	}
+}
+
+func TestCgroupLimits(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		f := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(f, []byte(content), 0o600))
+		return f
+	}
+	defer func(cpuMax, cpuQuota, cpuPeriod, memMax, memLimit string) {
+		cgroupCPUMax, cgroupCPUQuota, cgroupCPUPeriod, cgroupMemoryMax, cgroupMemoryLimit = cpuMax, cpuQuota, cpuPeriod, memMax, memLimit
+	}(cgroupCPUMax, cgroupCPUQuota, cgroupCPUPeriod, cgroupMemoryMax, cgroupMemoryLimit)
+
+	missing := filepath.Join(dir, "missing")
+
+	// cgroup v2
+	cgroupCPUMax = write("cpu.max", "150000 100000\n")
+	cgroupMemoryMax = write("memory.max", "268435456\n")
+	cpus, err := cgroupCPULimit()
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, cpus)
+	assert.Equal(t, uint64(256<<20), memoryLimit())
+
+	cgroupCPUMax = write("cpu.max", "max 100000\n")
+	cgroupMemoryMax = write("memory.max", "max\n")
+	_, err = cgroupCPULimit()
+	assert.ErrorIs(t, err, errCgroupUnlimited)
+	assert.Zero(t, memoryLimit())
+
+	// cgroup v1
+	cgroupCPUMax, cgroupMemoryMax = missing, missing
+	cgroupCPUQuota = write("cpu.cfs_quota_us", "50000\n")
+	cgroupCPUPeriod = write("cpu.cfs_period_us", "100000\n")
+	cgroupMemoryLimit = write("memory.limit_in_bytes", "9223372036854771712\n")
+	cpus, err = cgroupCPULimit()
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, cpus)
+	assert.Equal(t, 0.5, availableCPUs())
+	assert.Zero(t, memoryLimit(), "cgroup v1 reports unlimited memory as a huge number")
+
+	cgroupCPUQuota = write("cpu.cfs_quota_us", "-1\n")
+	_, err = cgroupCPULimit()
+	assert.ErrorIs(t, err, errCgroupUnlimited)
+	assert.Equal(t, float64(runtime.GOMAXPROCS(0)), availableCPUs())
+}
@@ -0,0 +1,27 @@
+package dnsbench
+
+import (
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// processCPUTime returns the user and kernel CPU time consumed by the process.
+func processCPUTime() time.Duration {
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(windows.CurrentProcess(), &creation, &exit, &kernel, &user); err != nil {
+		return 0
+	}
+	// the times are in 100-nanosecond units
+	return time.Duration((uint64(kernel.HighDateTime)<<32|uint64(kernel.LowDateTime))+(uint64(user.HighDateTime)<<32|uint64(user.LowDateTime))) * 100
+}
+
+// openSockets returns -1, the sockets open by the process are not counted on Windows.
+func openSockets() int {
+	return -1
+}
+
+// openFilesLimit returns zero, Windows does not limit the number of the open handles of the process the way Unix systems do.
+func openFilesLimit() uint64 {
+	return 0
+}
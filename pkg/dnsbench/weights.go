@@ -0,0 +1,99 @@
+package dnsbench
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// parseWeightedQuery parses query in format hostname,type,weight, the type can be empty.
+func parseWeightedQuery(entry string) (name, qtype string, weight float64, err error) {
+	fields := strings.Split(entry, ",")
+	if len(fields) != 3 {
+		return "", "", 0, fmt.Errorf("invalid query '%s', expected format hostname,type,weight", entry)
+	}
+	name, qtype = strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+	weight, err = strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+	if err != nil || weight <= 0 || math.IsInf(weight, 0) {
+		return "", "", 0, fmt.Errorf("invalid weight of query '%s', expected positive number", entry)
+	}
+	if name == "" {
+		return "", "", 0, fmt.Errorf("invalid query '%s', hostname is missing", entry)
+	}
+	return name, qtype, weight, nil
+}
+
+// parseTypeMix parses query types, each type can be followed by weight in format TYPE:weight and multiple types can be separated by commas,
+// for example A:70,AAAA:25,HTTPS:5. Weights are returned only if the types are weighted, then either all types or none of them have to be weighted.
+func parseTypeMix(types []string) ([]uint16, []float64, error) {
+	var qtypes []uint16
+	var weights []float64
+	unweighted := 0
+	for _, entry := range types {
+		for _, part := range strings.Split(entry, ",") {
+			name, w, hasWeight := strings.Cut(strings.TrimSpace(part), ":")
+			qt, ok := dns.StringToType[strings.ToUpper(name)]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown query type '%s'", name)
+			}
+			qtypes = append(qtypes, qt)
+			if !hasWeight {
+				unweighted++
+				continue
+			}
+			weight, err := strconv.ParseFloat(w, 64)
+			if err != nil || weight <= 0 || math.IsInf(weight, 0) {
+				return nil, nil, fmt.Errorf("invalid weight of query type '%s', expected positive number", part)
+			}
+			weights = append(weights, weight)
+		}
+	}
+	if len(weights) > 0 && unweighted > 0 {
+		return nil, nil, errors.New("either all query types or none of them have to be weighted")
+	}
+	return qtypes, weights, nil
+}
+
+// zipfWeights returns weights of n queries with Zipf distributed popularity with exponent s, the first query is the most popular.
+func zipfWeights(n int, s float64) []float64 {
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1 / math.Pow(float64(i+1), s)
+	}
+	return weights
+}
+
+// querySampler draws indexes of questions randomly with probabilities proportional to their weights.
+type querySampler struct {
+	cumulative []float64
+}
+
+func newQuerySampler(weights []float64) *querySampler {
+	if len(weights) == 0 {
+		return nil
+	}
+	cumulative := make([]float64, len(weights))
+	var sum float64
+	for i, w := range weights {
+		sum += w
+		cumulative[i] = sum
+	}
+	return &querySampler{cumulative: cumulative}
+}
+
+// pick returns index of randomly drawn question.
+func (s *querySampler) pick(rando *rand.Rand) int {
+	r := rando.Float64() * s.cumulative[len(s.cumulative)-1]
+	i := sort.SearchFloat64s(s.cumulative, r)
+	if i == len(s.cumulative) {
+		// guard against rounding errors
+		i--
+	}
+	return i
+}
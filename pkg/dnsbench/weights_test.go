@@ -0,0 +1,96 @@
+package dnsbench
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseWeightedQuery(t *testing.T) {
+	tests := []struct {
+		entry      string
+		wantName   string
+		wantType   string
+		wantWeight float64
+		wantErr    bool
+	}{
+		{entry: "example.com,A,10", wantName: "example.com", wantType: "A", wantWeight: 10},
+		{entry: " example.com , aaaa , 0.5 ", wantName: "example.com", wantType: "aaaa", wantWeight: 0.5},
+		{entry: "example.com,,2", wantName: "example.com", wantType: "", wantWeight: 2},
+		{entry: "example.com,A", wantErr: true},
+		{entry: "example.com,A,1,2", wantErr: true},
+		{entry: "example.com,A,0", wantErr: true},
+		{entry: "example.com,A,-1", wantErr: true},
+		{entry: "example.com,A,heavy", wantErr: true},
+		{entry: ",A,1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			name, qtype, weight, err := parseWeightedQuery(tt.entry)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantType, qtype)
+			assert.Equal(t, tt.wantWeight, weight)
+		})
+	}
+}
+
+func Test_parseTypeMix(t *testing.T) {
+	tests := []struct {
+		name        string
+		types       []string
+		wantTypes   []uint16
+		wantWeights []float64
+		wantErr     bool
+	}{
+		{name: "unweighted", types: []string{"A", "aaaa"}, wantTypes: []uint16{dns.TypeA, dns.TypeAAAA}},
+		{name: "weighted", types: []string{"A:70,AAAA:25,HTTPS:5"}, wantTypes: []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeHTTPS}, wantWeights: []float64{70, 25, 5}},
+		{name: "weighted repeated", types: []string{"A:0.5", " MX:1.5 "}, wantTypes: []uint16{dns.TypeA, dns.TypeMX}, wantWeights: []float64{0.5, 1.5}},
+		{name: "partially weighted", types: []string{"A:70,AAAA"}, wantErr: true},
+		{name: "unknown type", types: []string{"FOO:1"}, wantErr: true},
+		{name: "zero weight", types: []string{"A:0"}, wantErr: true},
+		{name: "invalid weight", types: []string{"A:heavy"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qtypes, weights, err := parseTypeMix(tt.types)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTypes, qtypes)
+			assert.Equal(t, tt.wantWeights, weights)
+		})
+	}
+}
+
+func Test_zipfWeights(t *testing.T) {
+	assert.InDeltaSlice(t, []float64{1, 0.5, 1.0 / 3, 0.25}, zipfWeights(4, 1), 1e-9)
+	assert.InDeltaSlice(t, []float64{1, 0.25, 1.0 / 9}, zipfWeights(3, 2), 1e-9)
+}
+
+func Test_querySampler_pick(t *testing.T) {
+	assert.Nil(t, newQuerySampler(nil))
+
+	s := newQuerySampler([]float64{8, 0, 2})
+	// nolint:gosec
+	rando := rand.New(rand.NewSource(1))
+	counts := make([]int, 3)
+	for i := 0; i < 10000; i++ {
+		counts[s.pick(rando)]++
+	}
+
+	assert.InDelta(t, 8000, counts[0], 300)
+	assert.Zero(t, counts[1], "question with zero weight should never be picked")
+	assert.InDelta(t, 2000, counts[2], 300)
+}
@@ -0,0 +1,96 @@
+package dnsbench
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// workerSummary summarizes the results of a single benchmark worker, see Benchmark.WorkerStats.
+type workerSummary struct {
+	worker      int
+	queries     int64
+	errors      int64
+	connections int64
+	qps         float64
+	p50         time.Duration
+	p99         time.Duration
+	max         time.Duration
+}
+
+// summarizeWorkers summarizes the results of each worker, so that the workers lagging behind the others can be spotted.
+func summarizeWorkers(stats []*ResultStats, t time.Duration) []workerSummary {
+	var res []workerSummary
+	for i, st := range stats {
+		if st == nil || st.Counters == nil {
+			continue
+		}
+		s := workerSummary{worker: i, queries: st.Counters.Total, errors: st.Counters.IOError}
+		if t > 0 {
+			s.qps = float64(st.Counters.Total) / t.Seconds()
+		}
+		if st.Connections != nil {
+			s.connections = st.Connections.Count
+		}
+		if st.Hist != nil && st.Hist.TotalCount() > 0 {
+			s.p50 = time.Duration(st.Hist.ValueAtQuantile(50))
+			s.p99 = time.Duration(st.Hist.ValueAtQuantile(99))
+			s.max = time.Duration(st.Hist.Max())
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// workerSkew returns the ratio of the highest throughput of a worker to the lowest one, 0 is returned if any of the workers sent no queries.
+func workerSkew(workers []workerSummary) float64 {
+	if len(workers) == 0 {
+		return 0
+	}
+	min, max := workers[0].qps, workers[0].qps
+	for _, w := range workers[1:] {
+		if w.qps < min {
+			min = w.qps
+		}
+		if w.qps > max {
+			max = w.qps
+		}
+	}
+	if min == 0 {
+		return 0
+	}
+	return max / min
+}
+
+func printWorkers(w io.Writer, workers []workerSummary) {
+	if len(workers) == 0 {
+		return
+	}
+	lines := make([][]string, 0, len(workers))
+	for _, s := range workers {
+		lines = append(lines, []string{
+			strconv.Itoa(s.worker),
+			strconv.FormatInt(s.queries, 10),
+			fmt.Sprintf("%0.2f", s.qps),
+			strconv.FormatInt(s.errors, 10),
+			strconv.FormatInt(s.connections, 10),
+			roundDuration(s.p50).String(),
+			roundDuration(s.p99).String(),
+			roundDuration(s.max).String(),
+		})
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Results by worker:")
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Worker", "Queries", "QPS", "Errors", "Connections", "p50", "p99", "Max"})
+	table.SetBorder(false)
+	table.AppendBulk(lines)
+	table.Render()
+	if skew := workerSkew(workers); skew > 0 {
+		fmt.Fprintf(w, "Throughput skew (fastest to slowest worker):\t%s\n", highlightStr(fmt.Sprintf("%0.2fx", skew)))
+	}
+}
@@ -0,0 +1,63 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_summarizeWorkers(t *testing.T) {
+	fast := &ResultStats{Hist: hdrhistogram.New(1, time.Second.Nanoseconds(), 3), Counters: &Counters{Total: 100}, Connections: &ConnectionStats{Count: 1}}
+	fast.Hist.RecordValue(time.Millisecond.Nanoseconds())
+	slow := &ResultStats{Hist: hdrhistogram.New(1, time.Second.Nanoseconds(), 3), Counters: &Counters{Total: 25, IOError: 5}}
+	slow.Hist.RecordValue(100 * time.Millisecond.Nanoseconds())
+
+	workers := summarizeWorkers([]*ResultStats{fast, nil, slow}, 10*time.Second)
+	require.Len(t, workers, 2)
+	assert.Equal(t, 0, workers[0].worker)
+	assert.Equal(t, int64(100), workers[0].queries)
+	assert.Equal(t, int64(1), workers[0].connections)
+	assert.InDelta(t, 10, workers[0].qps, 0.0001)
+	assert.Equal(t, time.Millisecond, roundDuration(workers[0].p99))
+	assert.Equal(t, 2, workers[1].worker)
+	assert.Equal(t, int64(5), workers[1].errors)
+	assert.InDelta(t, 2.5, workers[1].qps, 0.0001)
+	assert.InDelta(t, 4, workerSkew(workers), 0.0001)
+
+	assert.Zero(t, workerSkew([]workerSummary{{qps: 1}, {}}), "skew is not defined when a worker sent no queries")
+	assert.Zero(t, workerSkew(nil))
+}
+
+func TestBenchmark_PrintReport_workerStats(t *testing.T) {
+	s := NewServer(udp, replyHandler)
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.WorkerStats = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	buf := bytes.Buffer{}
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	assert.Contains(t, buf.String(), "Results by worker:")
+	assert.Contains(t, buf.String(), "Throughput skew (fastest to slowest worker):")
+
+	bench.JSON = true
+	buf.Reset()
+	require.NoError(t, bench.PrintReport(&buf, rs, time.Second))
+	var res struct {
+		Workers []jsonWorker `json:"workers"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	require.Len(t, res.Workers, 2)
+	assert.Equal(t, int64(2), res.Workers[1].TotalRequests)
+}
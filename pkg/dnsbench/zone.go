@@ -0,0 +1,73 @@
+package dnsbench
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// zoneSkippedTypes are the types of the records, which are not queried on their own, but are returned together with the other records
+// of DNSSEC signed zones.
+var zoneSkippedTypes = map[uint16]bool{dns.TypeRRSIG: true, dns.TypeNSEC: true, dns.TypeNSEC3: true}
+
+// zoneQuestions returns questions of the owner names and types of the records in the zone file, each pair of the owner name and type
+// is asked once in order of the first record with them in the file. When types are provided, only the records of the types are queried,
+// when the types are weighted, the weight of each type is split evenly among the questions of the type and the weights of the questions
+// are returned as well.
+func zoneQuestions(path string, types []uint16, typeWeights []float64) ([]dns.Question, []float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open zone file '%s' due to '%v'", path, err)
+	}
+	defer f.Close()
+
+	var allowed map[uint16]int
+	if len(types) > 0 {
+		allowed = make(map[uint16]int, len(types))
+		for i, t := range types {
+			allowed[t] = i
+		}
+	}
+
+	type question struct {
+		name  string
+		qtype uint16
+	}
+	seen := make(map[question]bool)
+	var questions []dns.Question
+	perType := make(map[uint16]int)
+	zp := dns.NewZoneParser(f, "", path)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		h := rr.Header()
+		if zoneSkippedTypes[h.Rrtype] {
+			continue
+		}
+		if _, ok := allowed[h.Rrtype]; allowed != nil && !ok {
+			continue
+		}
+		q := question{name: strings.ToLower(h.Name), qtype: h.Rrtype}
+		if seen[q] {
+			continue
+		}
+		seen[q] = true
+		questions = append(questions, dns.Question{Name: h.Name, Qtype: h.Rrtype, Qclass: h.Class})
+		perType[h.Rrtype]++
+	}
+	if err := zp.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse zone file '%s' due to '%v'", path, err)
+	}
+	if len(questions) == 0 {
+		return nil, nil, fmt.Errorf("no records to query found in zone file '%s'", path)
+	}
+
+	if len(typeWeights) == 0 {
+		return questions, nil, nil
+	}
+	weights := make([]float64, len(questions))
+	for i, q := range questions {
+		weights[i] = typeWeights[allowed[q.Qtype]] / float64(perType[q.Qtype])
+	}
+	return questions, weights, nil
+}
@@ -0,0 +1,144 @@
+package dnsbench
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testZone = `$ORIGIN example.org.
+$TTL 3600
+@	IN	SOA	ns1 hostmaster 1 7200 3600 1209600 3600
+@	IN	NS	ns1
+@	IN	MX	10 mail
+ns1	IN	A	192.0.2.1
+www	IN	A	192.0.2.2
+www	IN	A	192.0.2.3
+WWW	IN	AAAA	2001:db8::2
+mail	IN	A	192.0.2.4
+www	IN	RRSIG	A 13 3 3600 20300101000000 20200101000000 12345 example.org. dGVzdA==
+`
+
+func writeTestZone(t *testing.T) string {
+	f := filepath.Join(t.TempDir(), "example.org.zone")
+	require.NoError(t, os.WriteFile(f, []byte(testZone), 0o600))
+	return f
+}
+
+func Test_zoneQuestions(t *testing.T) {
+	questions, weights, err := zoneQuestions(writeTestZone(t), nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, weights)
+	assert.Equal(t, []dns.Question{
+		{Name: "example.org.", Qtype: dns.TypeSOA, Qclass: dns.ClassINET},
+		{Name: "example.org.", Qtype: dns.TypeNS, Qclass: dns.ClassINET},
+		{Name: "example.org.", Qtype: dns.TypeMX, Qclass: dns.ClassINET},
+		{Name: "ns1.example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+		{Name: "www.example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+		{Name: "WWW.example.org.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET},
+		{Name: "mail.example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+	}, questions)
+}
+
+func Test_zoneQuestions_types(t *testing.T) {
+	zone := writeTestZone(t)
+
+	questions, weights, err := zoneQuestions(zone, []uint16{dns.TypeAAAA, dns.TypeMX}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, weights)
+	assert.Len(t, questions, 2)
+
+	questions, weights, err = zoneQuestions(zone, []uint16{dns.TypeA, dns.TypeAAAA}, []float64{80, 20})
+	require.NoError(t, err)
+	require.Len(t, questions, 4)
+	for i, q := range questions {
+		if q.Qtype == dns.TypeA {
+			assert.InDelta(t, 80.0/3, weights[i], 0.0001, "weight of A is split among the A questions")
+		} else {
+			assert.Equal(t, 20.0, weights[i])
+		}
+	}
+}
+
+func Test_zoneQuestions_invalid(t *testing.T) {
+	dir := t.TempDir()
+	relative := filepath.Join(dir, "relative.zone")
+	require.NoError(t, os.WriteFile(relative, []byte("www 3600 IN A 192.0.2.1\n"), 0o600))
+	signatures := filepath.Join(dir, "signatures.zone")
+	require.NoError(t, os.WriteFile(signatures, []byte("$ORIGIN example.org.\nwww 3600 IN NSEC example.org. A RRSIG NSEC\n"), 0o600))
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "missing", path: filepath.Join(dir, "missing.zone")},
+		{name: "relative names without origin", path: relative},
+		{name: "no records to query", path: signatures},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := zoneQuestions(tt.path, nil, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBenchmark_Run_zoneFile(t *testing.T) {
+	var mu sync.Mutex
+	asked := make(map[string]int)
+	s := NewServer(udp, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		asked[r.Question[0].Name+" "+dns.TypeToString[r.Question[0].Qtype]]++
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := createBenchmark(s.Addr, false, 1)
+	bench.Queries = nil
+	bench.Concurrency = 1
+	bench.ZoneFile = writeTestZone(t)
+	bench.ZoneTypes = []string{"A", "AAAA"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+	require.NoError(t, err, "expected no error from benchmark run")
+
+	assert.Equal(t, int64(4), Merge(rs).Counters.Total)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{
+		"ns1.example.org. A":    1,
+		"www.example.org. A":    1,
+		"WWW.example.org. AAAA": 1,
+		"mail.example.org. A":   1,
+	}, asked)
+}
+
+func TestBenchmark_normalize_zoneFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		bench Benchmark
+	}{
+		{name: "zone types without zone file", bench: Benchmark{Server: "8.8.8.8", ZoneTypes: []string{"A"}}},
+		{name: "invalid zone types", bench: Benchmark{Server: "8.8.8.8", ZoneFile: "example.org.zone", ZoneTypes: []string{"A:70,AAAA"}}},
+		{name: "ptr", bench: Benchmark{Server: "8.8.8.8", ZoneFile: "example.org.zone", PtrFromCIDR: []string{"10.0.0.0/24"}}},
+		{name: "weighted zipf", bench: Benchmark{Server: "8.8.8.8", ZoneFile: "example.org.zone", ZoneTypes: []string{"A:70,AAAA:30"}, Zipf: 1.1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.bench.normalize())
+		})
+	}
+}